@@ -6,6 +6,7 @@
 
 :-(op(1200, xfx, [:-, -->])).
 :-(op(1200, fx, [:-, ?-])).
+:-(op(1150, fx, table)).
 :-(op(1105, xfy, '|')).
 :-(op(1100, xfy, ;)).
 :-(op(1050, xfy, ->)).
@@ -15,9 +16,11 @@
 :-(op(700, xfx, [==, \==, @<, @=<, @>, @>=])).
 :-(op(700, xfx, =..)).
 :-(op(700, xfx, [is, =:=, =\=, <, =<, >, >=])).
+:-(op(700, xfx, [#=, #\=, #<, #=<, #>, #>=, in, ins])).
 :-(op(600, xfy, :)).
 :-(op(500, yfx, [+, -, /\, \/])).
-:-(op(400, yfx, [*, /, //, div, rem, mod, <<, >>])).
+:-(op(450, xfx, ..)).
+:-(op(400, yfx, [*, /, //, div, rdiv, rem, mod, <<, >>])).
 :-(op(200, xfx, **)).
 :-(op(200, xfy, ^)).
 :-(op(200, fy, [+, -, \])).
@@ -52,7 +55,7 @@ atomic(X) :-
 nonvar(X) :- \+var(X).
 
 number(X) :- float(X).
-number(X) :- integer(X).
+number(X) :- rational(X).
 
 callable(X) :- atom(X).
 callable(X) :- compound(X).
@@ -75,13 +78,6 @@ X @> Y :- compare(>, X, Y).
 X @>= Y :- compare(>, X, Y).
 X @>= Y :- compare(=, X, Y).
 
-% Clause creation and destruction
-
-retractall(Head) :-
-  retract((Head :- _)),
-  fail.
-retractall(_).
-
 % Stream selection and control
 
 open(Filename, Mode, Stream) :-
@@ -194,12 +190,34 @@ write_canonical(Term) :-
 
 write_canonical(Stream, Term) :- write_term(Stream, Term, [quoted(true), ignore_ops(true)]).
 
+format(Format) :- format(Format, []).
+
+format(Format, Args) :-
+  current_output(S),
+  format(S, Format, Args).
+
+json_read(Stream, Term) :- json_read(Stream, Term, []).
+
+json_write(Stream, Term) :- json_write(Stream, Term, []).
+
+json_read_dict(Stream, Dict) :- json_read_dict(Stream, Dict, []).
+
+json_write_dict(Stream, Dict) :- json_write_dict(Stream, Dict, []).
+
 % Logic and control
 
 once(P) :- P, !.
 
 false :- fail.
 
+% Recorded database
+
+recorda(Key, Value) :- recorda(Key, Value, _).
+
+recordz(Key, Value) :- recordz(Key, Value, _).
+
+recorded(Key, Value) :- recorded(Key, Value, _).
+
 % Atomic term processing
 
 % Implementation defined hooks
@@ -223,37 +241,3 @@ select(E, [E|Xs], Xs).
 select(E, [X|Xs], [X|Ys]) :-
   select(E, Xs, Ys).
 
-maplist(_Cont_1, []).
-maplist(Cont_1, [E1|E1s]) :-
-  call(Cont_1, E1),
-  maplist(Cont_1, E1s).
-
-maplist(_Cont_2, [], []).
-maplist(Cont_2, [E1|E1s], [E2|E2s]) :-
-  call(Cont_2, E1, E2),
-  maplist(Cont_2, E1s, E2s).
-
-maplist(_Cont_3, [], [], []).
-maplist(Cont_3, [E1|E1s], [E2|E2s], [E3|E3s]) :-
-  call(Cont_3, E1, E2, E3),
-  maplist(Cont_3, E1s, E2s, E3s).
-
-maplist(_Cont_4, [], [], [], []).
-maplist(Cont_4, [E1|E1s], [E2|E2s], [E3|E3s], [E4|E4s]) :-
-  call(Cont_4, E1, E2, E3, E4),
-  maplist(Cont_4, E1s, E2s, E3s, E4s).
-
-maplist(_Cont_5, [], [], [], [], []).
-maplist(Cont_5, [E1|E1s], [E2|E2s], [E3|E3s], [E4|E4s], [E5|E5s]) :-
-  call(Cont_5, E1, E2, E3, E4, E5),
-  maplist(Cont_5, E1s, E2s, E3s, E4s, E5s).
-
-maplist(_Cont_6, [], [], [], [], [], []).
-maplist(Cont_6, [E1|E1s], [E2|E2s], [E3|E3s], [E4|E4s], [E5|E5s], [E6|E6s]) :-
-  call(Cont_6, E1, E2, E3, E4, E5, E6),
-  maplist(Cont_6, E1s, E2s, E3s, E4s, E5s, E6s).
-
-maplist(_Cont_7, [], [], [], [], [], [], []).
-maplist(Cont_7, [E1|E1s], [E2|E2s], [E3|E3s], [E4|E4s], [E5|E5s], [E6|E6s], [E7|E7s]) :-
-  call(Cont_7, E1, E2, E3, E4, E5, E6, E7),
-  maplist(Cont_7, E1s, E2s, E3s, E4s, E5s, E6s, E7s).
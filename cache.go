@@ -0,0 +1,97 @@
+package prolog
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/axone-protocol/prolog/v3/engine"
+)
+
+// CompileCache caches the compiled Prolog texts executed by interpreters so that executing an identical text again,
+// e.g. on a new interpreter, skips parsing and compiling it. Texts are identified by their SHA-256 digests.
+// It's safe for concurrent use by multiple interpreters.
+type CompileCache struct {
+	mu      sync.Mutex
+	size    int
+	lru     *list.List // of *compileCacheEntry, the most recently used first.
+	entries map[[sha256.Size]byte]*list.Element
+	stats   CompileCacheStats
+}
+
+type compileCacheEntry struct {
+	key     [sha256.Size]byte
+	program *engine.Program
+}
+
+// CompileCacheStats are the metrics of a CompileCache.
+type CompileCacheStats struct {
+	// Hits is the number of texts loaded from the cache.
+	Hits uint64
+	// Misses is the number of texts compiled because they were not in the cache or couldn't be loaded from it.
+	Misses uint64
+	// Evictions is the number of texts removed from the cache to make room for others.
+	Evictions uint64
+	// Len is the number of texts in the cache.
+	Len int
+}
+
+// NewCompileCache returns a CompileCache which keeps at most size compiled texts, evicting the least recently used
+// ones first.
+func NewCompileCache(size int) *CompileCache {
+	return &CompileCache{
+		size:    size,
+		lru:     list.New(),
+		entries: map[[sha256.Size]byte]*list.Element{},
+	}
+}
+
+// Stats returns the metrics of the cache.
+func (c *CompileCache) Stats() CompileCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Len = c.lru.Len()
+	return s
+}
+
+func (c *CompileCache) get(key [sha256.Size]byte) (*engine.Program, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*compileCacheEntry).program, true
+}
+
+func (c *CompileCache) add(key [sha256.Size]byte, p *engine.Program) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*compileCacheEntry).program = p
+		c.lru.MoveToFront(e)
+		return
+	}
+	if c.size <= 0 {
+		return
+	}
+	for c.lru.Len() >= c.size {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.entries, e.Value.(*compileCacheEntry).key)
+		c.stats.Evictions++
+	}
+	c.entries[key] = c.lru.PushFront(&compileCacheEntry{key: key, program: p})
+}
+
+func (c *CompileCache) count(hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hit {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+}
@@ -0,0 +1,84 @@
+package prolog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileCache(t *testing.T) {
+	const text = `
+:- dynamic(counter/1).
+counter(0).
+human(socrates).
+mortal(X) :- human(X).
+`
+
+	t.Run("hit", func(t *testing.T) {
+		c := NewCompileCache(1)
+
+		i := New(nil, nil)
+		i.CompileCache = c
+		assert.NoError(t, i.Exec(text))
+		assert.Equal(t, CompileCacheStats{Misses: 1, Len: 1}, c.Stats())
+
+		j := New(nil, nil)
+		j.CompileCache = c
+		assert.NoError(t, j.Exec(text))
+		assert.Equal(t, CompileCacheStats{Hits: 1, Misses: 1, Len: 1}, c.Stats())
+
+		assert.NoError(t, j.QuerySolution(`retract(counter(0)).`).Err())
+		assert.NoError(t, i.QuerySolution(`mortal(socrates), counter(0).`).Err())
+		assert.NoError(t, j.QuerySolution(`mortal(socrates), \+ counter(0).`).Err())
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		c := NewCompileCache(1)
+
+		i := New(nil, nil)
+		i.CompileCache = c
+		assert.NoError(t, i.Exec(`foo.`))
+		assert.NoError(t, i.Exec(`bar.`))
+		assert.NoError(t, i.Exec(`foo.`))
+		assert.Equal(t, CompileCacheStats{Misses: 3, Evictions: 2, Len: 1}, c.Stats())
+	})
+
+	t.Run("arguments", func(t *testing.T) {
+		c := NewCompileCache(1)
+
+		i := New(nil, nil)
+		i.CompileCache = c
+		assert.NoError(t, i.Exec(`foo(?).`, "a"))
+		assert.Equal(t, CompileCacheStats{}, c.Stats())
+	})
+
+	t.Run("syntax changed", func(t *testing.T) {
+		c := NewCompileCache(2)
+
+		i := New(nil, nil)
+		i.CompileCache = c
+		assert.NoError(t, i.Exec(`foo("a").`))
+
+		j := New(nil, nil)
+		j.CompileCache = c
+		assert.NoError(t, j.Exec(`:- set_prolog_flag(double_quotes, atom).`))
+		assert.NoError(t, j.Exec(`foo("a").`))
+		assert.NoError(t, j.QuerySolution(`foo(a).`).Err())
+		assert.Equal(t, CompileCacheStats{Misses: 3, Len: 2}, c.Stats())
+	})
+
+	t.Run("expansion", func(t *testing.T) {
+		c := NewCompileCache(2)
+
+		i := New(nil, nil)
+		i.CompileCache = c
+		assert.NoError(t, i.Exec(`foo(a).`))
+
+		j := New(nil, nil)
+		j.CompileCache = c
+		assert.NoError(t, j.Exec(`term_expansion(foo(a), foo(b)).`))
+		assert.NoError(t, j.Exec(`foo(a).`))
+		assert.NoError(t, j.QuerySolution(`foo(b), \+ foo(a).`).Err())
+		assert.Equal(t, CompileCacheStats{Misses: 3, Len: 2}, c.Stats())
+	})
+}
@@ -70,6 +70,8 @@ Type Ctrl-C or 'halt.' to exit.
 
 	i := New(&userInput{t: t}, t)
 	i.Register1(engine.NewAtom("halt"), halt)
+	i.SetBacktraces(true)
+	i.SetGoalHistory(16)
 	i.Unknown = func(name engine.Atom, args []engine.Term, env *engine.Env) {
 		var sb strings.Builder
 		s := engine.NewOutputTextStream(&sb)
@@ -173,7 +175,7 @@ func handleLine(ctx context.Context, buf *strings.Builder, p *prolog.Interpreter
 		if _, halted := engine.IsHalt(err); halted {
 			return err
 		}
-		log.Print(err)
+		renderError(t, &p.VM, err, t.Escape)
 		return nil
 	}
 
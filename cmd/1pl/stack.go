@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/axone-protocol/prolog/v3/engine"
+)
+
+// renderError writes err as an uncaught exception followed by the goal which raised it and the call stack if the
+// exception carries them. The goal is highlighted with esc unless esc is nil.
+func renderError(w io.Writer, vm *engine.VM, err error, esc *terminal.EscapeCodes) {
+	var e engine.Exception
+	if !errors.As(err, &e) {
+		_, _ = fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	formal, errCtx := e.Term(), engine.Term(nil)
+	if c, ok := formal.(engine.Compound); ok && c.Functor() == engine.NewAtom("error") && c.Arity() == 2 {
+		formal, errCtx = c.Arg(0), c.Arg(1)
+		// The backtrace in the context is rendered as the call stack below.
+		if c, ok := errCtx.(engine.Compound); ok && c.Functor() == engine.NewAtom("context") && c.Arity() == 2 {
+			errCtx = c.Arg(0)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "uncaught exception: %s\n", writeTerm(vm, formal))
+	if _, ok := errCtx.(engine.Variable); errCtx != nil && !ok {
+		_, _ = fmt.Fprintf(w, "  context: %s\n", writeTerm(vm, errCtx))
+	}
+
+	if h := e.GoalHistory(); len(h) > 0 {
+		goal := h[len(h)-1]
+		if esc != nil {
+			goal = string(esc.Red) + goal + string(esc.Reset)
+		}
+		_, _ = fmt.Fprintf(w, "  in goal: %s\n", goal)
+	}
+
+	trace := e.Trace()
+	if len(trace) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "call stack, innermost first:\n")
+	for i, f := range trace {
+		loc := "no source"
+		if f.File != "" {
+			loc = fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		_, _ = fmt.Fprintf(w, "  #%d %s (%s)\n", i, writeTerm(vm, f.PI), loc)
+	}
+}
+
+func writeTerm(vm *engine.VM, t engine.Term) string {
+	var sb strings.Builder
+	s := engine.NewOutputTextStream(&sb)
+	_, _ = engine.WriteTerm(vm, s, t, engine.List(engine.NewAtom("quoted").Apply(engine.NewAtom("true"))), engine.Success, nil).Force(context.Background())
+	return sb.String()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+func TestRenderError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "foo.pl")
+	assert.NoError(t, os.WriteFile(file, []byte(`foo(X) :- bar(X), true.
+bar(X) :- Y is X + 1, Y > 0.
+`), 0o600))
+
+	p := New(nil, nil)
+	p.SetBacktraces(true)
+	p.SetGoalHistory(4)
+	assert.NoError(t, p.QuerySolution(`consult('`+file+`').`).Err())
+
+	t.Run("uncaught exception", func(t *testing.T) {
+		var sb strings.Builder
+		renderError(&sb, &p.VM, p.QuerySolution(`foo(a).`).Err(), &terminal.EscapeCodes{Red: []byte("<"), Reset: []byte(">")})
+		assert.Regexp(t, `^uncaught exception: type_error\(evaluable,a/0\)
+  context: \(is\)/2
+  in goal: <_\d+ is a\+1>
+call stack, innermost first:
+  #0 \(is\)/2 \(no source\)
+  #1 bar/1 \(.*foo\.pl:2\)
+  #2 foo/1 \(.*foo\.pl:1\)
+$`, sb.String())
+	})
+
+	t.Run("thrown term", func(t *testing.T) {
+		var sb strings.Builder
+		renderError(&sb, &p.VM, p.QuerySolution(`throw(oops).`).Err(), nil)
+		assert.Regexp(t, `^uncaught exception: oops
+  in goal: throw\(oops\)
+`, sb.String())
+	})
+
+	t.Run("not an exception", func(t *testing.T) {
+		var sb strings.Builder
+		renderError(&sb, &p.VM, errors.New("foo"), nil)
+		assert.Equal(t, "foo\n", sb.String())
+	})
+}
@@ -0,0 +1,176 @@
+// Package conformance runs ISO conformance test suites against the interpreter and reports the results per section.
+//
+// A suite is a directory of Prolog texts, one per section, in the format of the INRIA suite: each clause is a test
+// case [Goal, Expected] where Expected is success, failure, a list of substitutions [[X <-- T, ...], ...] with one
+// element per solution, or the formal part of the error Goal raises.
+package conformance
+
+import (
+	"context"
+	_ "embed" // for go:embed
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/axone-protocol/prolog/v3"
+)
+
+//go:embed conformance.pl
+var driver string
+
+// Options configures Run and RunSection.
+type Options struct {
+	// New returns a new interpreter for each section. It defaults to prolog.New(nil, nil).
+	New func() *prolog.Interpreter
+	// Timeout is the maximum duration of each test case. It defaults to 5 seconds.
+	Timeout time.Duration
+}
+
+// Report is the result of a suite.
+type Report struct {
+	Sections []Section `json:"sections"`
+	Passed   int       `json:"passed"`
+	Failed   int       `json:"failed"`
+}
+
+// Section is the result of the test cases of a Prolog text of a suite.
+type Section struct {
+	Name   string `json:"name"`
+	Passed int    `json:"passed"`
+	Failed int    `json:"failed"`
+	Cases  []Case `json:"cases"`
+}
+
+// Case is the result of a test case.
+type Case struct {
+	Goal     string `json:"goal"`
+	Expected string `json:"expected"`
+	Got      string `json:"got,omitempty"`
+	Passed   bool   `json:"passed"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Run runs the sections of the suite in the root directory of fsys in the lexical order of their file names.
+// The name of a section is the file name without its extension.
+func Run(ctx context.Context, fsys fs.FS, opts Options) (*Report, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	var r Report
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(e.Name(), path.Ext(e.Name()))
+		s, err := RunSection(ctx, name, string(b), opts)
+		if err != nil {
+			return nil, err
+		}
+		r.Sections = append(r.Sections, s)
+		r.Passed += s.Passed
+		r.Failed += s.Failed
+	}
+	return &r, nil
+}
+
+// RunSection runs the test cases in text on a new interpreter.
+// A test case which doesn't complete in time or raises an error outside of its goal fails with Case.Error set.
+func RunSection(ctx context.Context, name, text string, opts Options) (Section, error) {
+	s := Section{Name: name}
+
+	i := newInterpreter(opts)
+	if err := i.Exec(driver); err != nil {
+		return s, err
+	}
+
+	cases, err := readCases(ctx, i, text)
+	if err != nil {
+		return s, err
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	for _, c := range cases {
+		res := c.Case
+		if err := runCase(ctx, i, c.text, timeout, &res); err != nil {
+			if ctx.Err() != nil {
+				return s, ctx.Err()
+			}
+			res.Error = err.Error()
+		}
+		if res.Passed {
+			s.Passed++
+		} else {
+			s.Failed++
+		}
+		s.Cases = append(s.Cases, res)
+	}
+	return s, nil
+}
+
+func newInterpreter(opts Options) *prolog.Interpreter {
+	if opts.New != nil {
+		return opts.New()
+	}
+	return prolog.New(nil, nil)
+}
+
+type testCase struct {
+	Case
+	text string
+}
+
+func readCases(ctx context.Context, i *prolog.Interpreter, text string) ([]testCase, error) {
+	sols, err := i.QueryContext(ctx, `'$conformance_case'(?, Case, Goal, Expected).`, text)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = sols.Close()
+	}()
+
+	var cases []testCase
+	for sols.Next() {
+		var s struct {
+			Case, Goal, Expected string
+		}
+		if err := sols.Scan(&s); err != nil {
+			return nil, err
+		}
+		cases = append(cases, testCase{
+			Case: Case{Goal: s.Goal, Expected: s.Expected},
+			text: s.Case + " .",
+		})
+	}
+	return cases, sols.Err()
+}
+
+func runCase(ctx context.Context, i *prolog.Interpreter, text string, timeout time.Duration, res *Case) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	sol := i.QuerySolutionContext(ctx, `'$conformance_check'(?, Verdict, Got).`, text)
+	var s struct {
+		Verdict string
+		Got     prolog.TermString
+	}
+	if err := sol.Scan(&s); err != nil {
+		return err
+	}
+	res.Got = string(s.Got)
+	res.Passed = s.Verdict == "pass"
+	return nil
+}
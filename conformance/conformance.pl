@@ -0,0 +1,78 @@
+/*
+ * Driver of the conformance runner.
+ *
+ * A test case is a term [Goal, Expected] where Expected is one of:
+ *   success                      Goal succeeds.
+ *   failure                      Goal fails.
+ *   [[X <-- T, ...], ...]        Goal succeeds with these bindings, one list per solution.
+ *   Error                        Goal raises error(Error, _).
+ */
+
+:- op(700, xfx, <--).
+
+'$conformance_case'(Text, Case, Goal, Expected) :-
+  open_string(Text, S),
+  '$conformance_read'(S, T),
+  T = [G, E],
+  with_output_to(string(Case), write_term(T, [quoted(true), ignore_ops(true)])),
+  with_output_to(string(Goal), writeq(G)),
+  with_output_to(string(Expected), writeq(E)).
+
+'$conformance_read'(S, T) :-
+  read(S, T0),
+  (  T0 == end_of_file
+  -> close(S), fail
+  ;  T = T0
+  ;  '$conformance_read'(S, T)
+  ).
+
+'$conformance_check'(Case, Verdict, Got) :-
+  open_string(Case, S),
+  read(S, [Goal, Expected]),
+  close(S),
+  '$conformance_outcome'(Goal, Expected, Got),
+  (  '$conformance_match'(Expected, Got)
+  -> Verdict = pass
+  ;  Verdict = fail
+  ).
+
+'$conformance_outcome'(Goal, Expected, Outcome) :-
+  '$conformance_template'(Expected, Vars),
+  catch(findall(Vars, call(Goal), Sols), Error, true),
+  (  nonvar(Error)
+  -> (  Error = error(E, _)
+     -> Outcome = E
+     ;  Outcome = Error
+     )
+  ;  Sols == []
+  -> Outcome = failure
+  ;  Vars == []
+  -> Outcome = success
+  ;  '$conformance_substitutions'(Sols, Vars, Outcome)
+  ).
+
+'$conformance_template'([Bindings|_], Vars) :-
+  !,
+  '$conformance_lhs'(Bindings, Vars).
+'$conformance_template'(_, []).
+
+'$conformance_lhs'([], []).
+'$conformance_lhs'([X <-- _|Bs], [X|Xs]) :-
+  '$conformance_lhs'(Bs, Xs).
+
+'$conformance_substitutions'([], _, []).
+'$conformance_substitutions'([Sol|Sols], Vars, [Bs|Bss]) :-
+  '$conformance_bindings'(Vars, Sol, Bs),
+  '$conformance_substitutions'(Sols, Vars, Bss).
+
+'$conformance_bindings'([], [], []).
+'$conformance_bindings'([X|Xs], [V|Vs], [X <-- V|Bs]) :-
+  '$conformance_bindings'(Xs, Vs, Bs).
+
+'$conformance_match'(Expected, Outcome) :-
+  Expected = [_|_],
+  !,
+  subsumes_term(Expected, Outcome),
+  subsumes_term(Outcome, Expected).
+'$conformance_match'(Expected, Outcome) :-
+  subsumes_term(Expected, Outcome).
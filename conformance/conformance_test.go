@@ -0,0 +1,81 @@
+package conformance
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/axone-protocol/prolog/v3"
+)
+
+func TestRun(t *testing.T) {
+	fsys := fstest.MapFS{
+		"atom_length.pl": &fstest.MapFile{Data: []byte(`
+[atom_length('enchanted evening', N), [[N <-- 17]]].
+[atom_length('', N), [[N <-- 0]]].
+[atom_length('scarlet', 5), failure].
+[atom_length(Atom, 4), instantiation_error].
+[atom_length(123, N), type_error(atom, 123)].
+`)},
+		"call.pl": &fstest.MapFile{Data: []byte(`
+[call(!), success].
+[call((fail, 1)), type_error(callable, (fail, 1))].
+[(X = 1 ; X = 2), [[X <-- 1], [X <-- 2]]].
+[call(1), success].
+`)},
+		"README": &fstest.MapFile{Data: []byte(`[true, success].`)},
+		"sub":    &fstest.MapFile{Mode: 0o755 | 1<<31},
+	}
+
+	r, err := Run(context.Background(), fsys, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, 9, r.Passed)
+	assert.Equal(t, 1, r.Failed)
+
+	assert.Len(t, r.Sections, 3)
+	assert.Equal(t, "README", r.Sections[0].Name)
+	assert.Equal(t, "atom_length", r.Sections[1].Name)
+	assert.Equal(t, 5, r.Sections[1].Passed)
+	assert.Equal(t, "call", r.Sections[2].Name)
+	assert.Equal(t, 3, r.Sections[2].Passed)
+	assert.Equal(t, 1, r.Sections[2].Failed)
+	assert.Equal(t, Case{
+		Goal:     "call(1)",
+		Expected: "success",
+		Got:      "type_error(callable,1)",
+		Passed:   false,
+	}, r.Sections[2].Cases[3])
+}
+
+func TestRunSection(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		s, err := RunSection(context.Background(), "loop", `
+[(repeat, fail), failure].
+[true, success].
+`, Options{Timeout: 50 * time.Millisecond})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, s.Passed)
+		assert.Equal(t, 1, s.Failed)
+		assert.Equal(t, context.DeadlineExceeded.Error(), s.Cases[0].Error)
+	})
+
+	t.Run("custom interpreter", func(t *testing.T) {
+		s, err := RunSection(context.Background(), "foo", `[foo(X), [[X <-- bar]]].`, Options{
+			New: func() *prolog.Interpreter {
+				i := prolog.New(nil, nil)
+				assert.NoError(t, i.Exec(`foo(bar).`))
+				return i
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, s.Passed)
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		_, err := RunSection(context.Background(), "broken", `[foo(, success].`, Options{})
+		assert.Error(t, err)
+	})
+}
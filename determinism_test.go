@@ -0,0 +1,67 @@
+//go:build prolog_determinism
+
+package prolog
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/axone-protocol/prolog/v3/engine"
+)
+
+// TestDeterminism checks that the enumerations visible to queries don't depend on the random iteration order of maps
+// by running them on many clones of a VM and comparing the answers. Run it with go test -tags prolog_determinism.
+func TestDeterminism(t *testing.T) {
+	p := New(nil, nil)
+	assert.NoError(t, p.Exec(`
+:- op(700, xfx, ===>).
+:- op(200, xfy, ^^).
+:- op(100, fy, @@).
+:- set_prolog_flag(char_conversion, on).
+:- char_conversion('&', '^').
+:- char_conversion('~', '-').
+:- dynamic(foo/1).
+foo(1).
+foo(2).
+bar(x).
+baz.
+:- initialization((
+	recordz(k, 1), recordz(j, 2), recorda(k, 0), recordz(42, 3),
+	nb_setval(g, 1), nb_setval(h, 2)
+)).
+`))
+	assert.Empty(t, engine.DeterminismAudit(&p.VM))
+
+	queries := []string{
+		`current_op(P, T, N)`,
+		`current_prolog_flag(F, V)`,
+		`current_char_conversion(X, Y)`,
+		`current_predicate(PI)`,
+		`recorded(K, V)`,
+		`source_file(F)`,
+	}
+	for _, q := range queries {
+		t.Run(q, func(t *testing.T) {
+			var want string
+			for i := 0; i < 32; i++ {
+				got := answers(t, &Interpreter{VM: *p.VM.Clone()}, q)
+				if i == 0 {
+					want = got
+					continue
+				}
+				assert.Equal(t, want, got)
+			}
+		})
+	}
+}
+
+// answers returns the answers of goal written in order.
+func answers(t *testing.T, p *Interpreter, goal string) string {
+	t.Helper()
+	var s struct{ S string }
+	sol := p.QuerySolution(fmt.Sprintf(`findall(%[1]s, %[1]s, Gs), with_output_to(string(S), writeq(Gs)).`, goal))
+	assert.NoError(t, sol.Scan(&s))
+	return s.S
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"context"
+)
+
+// MapList2 succeeds if closure succeeds with each element of list1.
+func MapList2(vm *VM, closure, list1 Term, k Cont, env *Env) *Promise {
+	return mapList(vm, closure, []Term{list1}, k, env)
+}
+
+// MapList3 succeeds if closure succeeds with each pair of the elements of list1 and list2 at the same position.
+func MapList3(vm *VM, closure, list1, list2 Term, k Cont, env *Env) *Promise {
+	return mapList(vm, closure, []Term{list1, list2}, k, env)
+}
+
+// MapList4 succeeds if closure succeeds with the elements of list1, ..., list3 at each position.
+func MapList4(vm *VM, closure, list1, list2, list3 Term, k Cont, env *Env) *Promise {
+	return mapList(vm, closure, []Term{list1, list2, list3}, k, env)
+}
+
+// MapList5 succeeds if closure succeeds with the elements of list1, ..., list4 at each position.
+func MapList5(vm *VM, closure, list1, list2, list3, list4 Term, k Cont, env *Env) *Promise {
+	return mapList(vm, closure, []Term{list1, list2, list3, list4}, k, env)
+}
+
+// MapList6 succeeds if closure succeeds with the elements of list1, ..., list5 at each position.
+func MapList6(vm *VM, closure, list1, list2, list3, list4, list5 Term, k Cont, env *Env) *Promise {
+	return mapList(vm, closure, []Term{list1, list2, list3, list4, list5}, k, env)
+}
+
+// MapList7 succeeds if closure succeeds with the elements of list1, ..., list6 at each position.
+func MapList7(vm *VM, closure, list1, list2, list3, list4, list5, list6 Term, k Cont, env *Env) *Promise {
+	return mapList(vm, closure, []Term{list1, list2, list3, list4, list5, list6}, k, env)
+}
+
+// mapList calls closure with the heads of lists and proceeds with their tails as maplist/N defined by these clauses:
+//
+//	maplist(_, [], ..., []).
+//	maplist(G, [E1|E1s], ..., [En|Ens]) :- call(G, E1, ..., En), maplist(G, E1s, ..., Ens).
+//
+// Unlike the clauses, it leaves no choice point if any of lists is either [] or [_|_].
+func mapList(vm *VM, closure Term, lists []Term, k Cont, env *Env) *Promise {
+	return foldList(vm, closure, lists, nil, nil, k, env)
+}
+
+// FoldL4 folds list from the left with closure as in call(closure, E, V0, V1) and unifies the result with v.
+func FoldL4(vm *VM, closure, list, v0, v Term, k Cont, env *Env) *Promise {
+	return foldList(vm, closure, []Term{list}, v0, v, k, env)
+}
+
+// FoldL5 folds list1 and list2 from the left with closure as in call(closure, E1, E2, V0, V1) and unifies the result
+// with v.
+func FoldL5(vm *VM, closure, list1, list2, v0, v Term, k Cont, env *Env) *Promise {
+	return foldList(vm, closure, []Term{list1, list2}, v0, v, k, env)
+}
+
+// FoldL6 folds list1, list2, and list3 from the left with closure as in call(closure, E1, E2, E3, V0, V1) and unifies
+// the result with v.
+func FoldL6(vm *VM, closure, list1, list2, list3, v0, v Term, k Cont, env *Env) *Promise {
+	return foldList(vm, closure, []Term{list1, list2, list3}, v0, v, k, env)
+}
+
+// foldList is mapList with the accumulator v0 which results in v if v0 isn't nil.
+func foldList(vm *VM, closure Term, lists []Term, v0, v Term, k Cont, env *Env) *Promise {
+	var empty, cons bool
+	for _, l := range lists {
+		switch l := env.Resolve(l).(type) {
+		case Variable:
+			continue
+		case Compound:
+			if l.Functor() != atomDot || l.Arity() != 2 {
+				return Bool(false)
+			}
+			cons = true
+		default:
+			if l != atomEmptyList {
+				return Bool(false)
+			}
+			empty = true
+		}
+	}
+
+	var ks []func(context.Context) *Promise
+	if !cons {
+		ks = append(ks, func(context.Context) *Promise {
+			ls, es := make([]Term, len(lists)), make([]Term, len(lists))
+			for i, l := range lists {
+				ls[i], es[i] = l, atomEmptyList
+			}
+			if v0 != nil {
+				ls, es = append(ls, v), append(es, v0)
+			}
+			return Unify(vm, tuple(ls...), tuple(es...), k, env)
+		})
+	}
+	if !empty {
+		ks = append(ks, func(context.Context) *Promise {
+			var (
+				heads, tails = make([]Term, len(lists)), make([]Term, len(lists))
+				vs, cs       []Term // the partial lists and the cons cells they're unified with.
+			)
+			for i, l := range lists {
+				if c, ok := env.Resolve(l).(Compound); ok {
+					heads[i], tails[i] = c.Arg(0), c.Arg(1)
+					continue
+				}
+				heads[i], tails[i] = NewVariable(), NewVariable()
+				vs, cs = append(vs, l), append(cs, Cons(heads[i], tails[i]))
+			}
+			args := heads
+			var v1 Term
+			if v0 != nil {
+				v1 = NewVariable()
+				args = append(args, v0, v1)
+			}
+			call := func(env *Env) *Promise {
+				return callClosure(vm, closure, args, func(env *Env) *Promise {
+					return foldList(vm, closure, tails, v1, v, k, env)
+				}, env)
+			}
+			if len(vs) == 0 {
+				return call(env)
+			}
+			return Unify(vm, tuple(vs...), tuple(cs...), call, env)
+		})
+	}
+	return Delay(ks...)
+}
+
+// Include unifies included with the elements of list for which closure succeeds.
+func Include(vm *VM, closure, list, included Term, k Cont, env *Env) *Promise {
+	return filter(vm, closure, list, included, true, k, env)
+}
+
+// Exclude unifies excluded with the elements of list for which closure fails.
+func Exclude(vm *VM, closure, list, excluded Term, k Cont, env *Env) *Promise {
+	return filter(vm, closure, list, excluded, false, k, env)
+}
+
+// filter unifies result with the elements of list for which closure succeeds if keep is true, or fails otherwise.
+// closure is called once for each element as in (call(closure, E) -> ... ; ...), so the bindings of its first solution
+// are kept.
+func filter(vm *VM, closure, list, result Term, keep bool, k Cont, env *Env) *Promise {
+	var elems []Term
+	iter := ListIterator{List: list, Env: env}
+	for iter.Next() {
+		elems = append(elems, iter.Current())
+	}
+	if err := iter.Err(); err != nil {
+		return Error(err)
+	}
+
+	return Delay(func(ctx context.Context) *Promise {
+		var kept []Term
+		for _, e := range elems {
+			var solution *Env
+			ok, err := callClosure(vm, closure, []Term{e}, func(env *Env) *Promise {
+				solution = env
+				return Bool(true)
+			}, env).Force(ctx)
+			if err != nil {
+				return Error(err)
+			}
+			if ok {
+				env = solution
+			}
+			if ok == keep {
+				kept = append(kept, e)
+			}
+		}
+		return Unify(vm, result, List(kept...), k, env)
+	})
+}
+
+// callClosure calls closure with the additional arguments as call/N does. Since the goal has arguments, it's not a cut
+// and it's called directly without being compiled.
+func callClosure(vm *VM, closure Term, additional []Term, k Cont, env *Env) *Promise {
+	goal, err := extendClosure(closure, additional, env)
+	if err != nil {
+		return Error(err)
+	}
+	g := goal.(Compound)
+	args := make([]Term, g.Arity())
+	for i := range args {
+		args[i] = g.Arg(i)
+	}
+	return vm.Arrive(g.Functor(), args, k, env)
+}
@@ -0,0 +1,176 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newApplyVM(t *testing.T) *VM {
+	var vm VM
+	vm.Register2(atomEqual, Unify)
+	vm.Register2(NewAtom("is"), Is)
+	vm.Register2(NewAtom("succ"), Succ)
+	vm.Register1(NewAtom("integer"), TypeInteger)
+	vm.Register1(NewAtom("throw"), Throw)
+	assert.NoError(t, vm.Compile(context.Background(), `
+:-(add(X, V0, V), is(V, +(V0, X))).
+:-(add3(X, Y, Z, V0, V), is(V, +(V0, +(X, +(Y, Z))))).
+small(1).
+small(2).
+color(red, X, X).
+:-(oops(_), throw(oops)).
+`))
+	return &vm
+}
+
+func TestMapList(t *testing.T) {
+	vm := newApplyVM(t)
+
+	t.Run("deterministic", func(t *testing.T) {
+		ys := NewVariable()
+		ok, err := MapList3(vm, NewAtom("succ"), List(Integer(1), Integer(2), Integer(3)), ys, func(env *Env) *Promise {
+			assert.Equal(t, Cons(Integer(2), Cons(Integer(3), Cons(Integer(4), atomEmptyList))), env.simplify(ys))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("backward", func(t *testing.T) {
+		xs := NewVariable()
+		ok, err := MapList3(vm, NewAtom("succ"), xs, List(Integer(2), Integer(3)), func(env *Env) *Promise {
+			assert.Equal(t, Cons(Integer(1), Cons(Integer(2), atomEmptyList)), env.simplify(xs))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("partial list", func(t *testing.T) {
+		l := NewVariable()
+		var ls []Term
+		ok, err := MapList2(vm, atomEqual.Apply(NewAtom("a")), l, func(env *Env) *Promise {
+			ls = append(ls, env.simplify(l))
+			return Bool(len(ls) == 3)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []Term{
+			atomEmptyList,
+			Cons(NewAtom("a"), atomEmptyList),
+			Cons(NewAtom("a"), Cons(NewAtom("a"), atomEmptyList)),
+		}, ls)
+	})
+
+	t.Run("nondeterministic", func(t *testing.T) {
+		xs := NewVariable()
+		var sols []Term
+		ok, err := MapList2(vm, NewAtom("small"), List(NewVariable(), NewVariable()), Failure, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		ok, err = MapList3(vm, atomEqual, xs, List(NewVariable(), NewVariable()), func(env *Env) *Promise {
+			sols = append(sols, env.simplify(xs))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Len(t, sols, 1)
+	})
+
+	t.Run("many lists", func(t *testing.T) {
+		ok, err := MapList4(vm, NewAtom("color"), List(NewAtom("red")), List(Integer(1)), List(Integer(1)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = MapList7(vm, NewAtom("add3"), List(Integer(1)), List(Integer(2)), List(Integer(3)), List(Integer(0)), List(Integer(6)), List(), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("different lengths", func(t *testing.T) {
+		ok, err := MapList3(vm, NewAtom("succ"), List(Integer(1), Integer(2)), List(Integer(2)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("not a list", func(t *testing.T) {
+		ok, err := MapList2(vm, NewAtom("integer"), NewAtom("foo"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("closure is a variable", func(t *testing.T) {
+		_, err := MapList2(vm, NewVariable(), List(Integer(1)), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+	})
+
+	t.Run("closure is not callable", func(t *testing.T) {
+		_, err := MapList2(vm, Integer(0), List(Integer(1)), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeCallable, Integer(0), nil), err)
+	})
+}
+
+func TestFoldL(t *testing.T) {
+	vm := newApplyVM(t)
+
+	v := NewVariable()
+	ok, err := FoldL4(vm, NewAtom("add"), List(Integer(1), Integer(2), Integer(3)), Integer(0), v, func(env *Env) *Promise {
+		assert.Equal(t, Integer(6), env.Resolve(v))
+		return Bool(true)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = FoldL4(vm, NewAtom("add"), List(), Integer(0), Integer(0), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = FoldL6(vm, NewAtom("add3"), List(Integer(1), Integer(2)), List(Integer(3), Integer(4)), List(Integer(5), Integer(6)), Integer(0), Integer(21), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = FoldL5(vm, NewAtom("add3").Apply(Integer(1)), List(Integer(2)), List(Integer(3)), Integer(0), Integer(7), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestInclude(t *testing.T) {
+	vm := newApplyVM(t)
+
+	l := NewVariable()
+	ok, err := Include(vm, NewAtom("integer"), List(Integer(1), NewAtom("a"), Integer(2)), l, func(env *Env) *Promise {
+		assert.Equal(t, List(Integer(1), Integer(2)), env.simplify(l))
+		return Bool(true)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	x := NewVariable()
+	ok, err = Include(vm, NewAtom("small"), List(x, Integer(3)), l, func(env *Env) *Promise {
+		assert.Equal(t, Integer(1), env.Resolve(x))
+		assert.Equal(t, List(Integer(1)), env.simplify(l))
+		return Bool(false)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = Include(vm, NewAtom("integer"), PartialList(NewVariable(), Integer(1)), l, Success, nil).Force(context.Background())
+	assert.Equal(t, InstantiationError(nil), err)
+
+	_, err = Include(vm, NewAtom("oops"), List(Integer(1)), l, Success, nil).Force(context.Background())
+	assert.Equal(t, NewException(NewAtom("oops"), nil), err)
+}
+
+func TestExclude(t *testing.T) {
+	vm := newApplyVM(t)
+
+	l := NewVariable()
+	ok, err := Exclude(vm, NewAtom("integer"), List(Integer(1), NewAtom("a"), Integer(2)), l, func(env *Env) *Promise {
+		assert.Equal(t, List(NewAtom("a")), env.simplify(l))
+		return Bool(true)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
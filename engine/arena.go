@@ -0,0 +1,89 @@
+package engine
+
+import "slices"
+
+// TermArena builds compounds out of large chunks of memory instead of allocating each of them, which reduces the
+// allocations and the objects the garbage collector tracks when a host loads millions of small facts.
+// The memory is never reused, so the terms stay valid as long as they're referenced. A chunk is freed as a whole once
+// neither the arena nor any term built out of it is referenced, e.g. when the procedures of the facts asserted by
+// VM.AssertFacts are abolished.
+// A TermArena isn't safe for concurrent use.
+type TermArena struct {
+	compounds    []compound
+	args         []Term
+	compoundsCap int
+	argsCap      int
+}
+
+// NewTermArena returns an arena which allocates chunks of about compounds compounds and args arguments. If they're
+// the numbers of the compounds and the arguments to build, the arena allocates only once for each.
+func NewTermArena(compounds, args int) *TermArena {
+	return &TermArena{
+		compoundsCap: compounds,
+		argsCap:      args,
+	}
+}
+
+// Apply returns the compound name(args...) or name if args is empty, like Atom.Apply.
+func (a *TermArena) Apply(name Atom, args ...Term) Term {
+	if len(args) == 0 {
+		return name
+	}
+
+	if len(a.compounds) == 0 {
+		a.compounds = make([]compound, a.compoundsCap+1)
+	}
+	if len(a.args) < len(args) {
+		a.args = make([]Term, a.argsCap+len(args))
+	}
+
+	c := &a.compounds[0]
+	a.compounds = a.compounds[1:]
+	c.functor = name
+	c.args = a.args[:len(args):len(args)]
+	a.args = a.args[len(args):]
+	copy(c.args, args)
+	return c
+}
+
+// Release makes the arena forget its current chunks so that they're freed once the terms built out of them are
+// unreferenced. The terms already built are unaffected and the arena allocates new chunks if it's used again.
+func (a *TermArena) Release() {
+	a.compounds, a.args = nil, nil
+}
+
+// AssertFacts appends the facts built by a to the database in order like assertz/1. Unlike assertz/1, it shares the
+// terms of the facts instead of copying them so that the facts are stored in the chunks of a. The procedures of the
+// facts hold a from then on: abolishing one of them or retracting all its clauses releases a, and the chunks are freed
+// at once when no clause refers to them anymore.
+func (vm *VM) AssertFacts(a *TermArena, facts []Term) error {
+	for _, f := range facts {
+		pi, _, err := assertCompiled(vm, f, false, compileFact, nil)
+		if err != nil {
+			return err
+		}
+		vm.record(atomAssertz, []Term{f}, nil)
+		if !slices.Contains(vm.arenas[pi], a) {
+			if vm.arenas == nil {
+				vm.arenas = map[procedureIndicator][]*TermArena{}
+			}
+			vm.arenas[pi] = append(vm.arenas[pi], a)
+		}
+	}
+	return nil
+}
+
+// compileFact compiles the fact t into a clause which shares t.
+func compileFact(t Term, _ *Env) (clauses, error) {
+	c, err := compileClause(t, nil, nil)
+	c.raw = t
+	return clauses{&c}, err
+}
+
+// releaseArenas releases the arenas held by the procedure pi.
+func (vm *VM) releaseArenas(pi procedureIndicator) {
+	for _, a := range vm.arenas[pi] {
+		a.Release()
+	}
+	delete(vm.arenas, pi)
+}
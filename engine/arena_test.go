@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTermArena_Apply(t *testing.T) {
+	f := NewAtom("f")
+	a := NewTermArena(2, 3)
+	assert.Equal(t, f, a.Apply(f))
+
+	x := a.Apply(f, Integer(1), Integer(2))
+	y := a.Apply(f, x)
+	z := a.Apply(f, Integer(3), Integer(4)) // Beyond the first chunks.
+	assert.Equal(t, f.Apply(Integer(1), Integer(2)), x)
+	assert.Equal(t, f.Apply(f.Apply(Integer(1), Integer(2))), y)
+	assert.Equal(t, f.Apply(Integer(3), Integer(4)), z)
+
+	t.Run("allocations", func(t *testing.T) {
+		a := NewTermArena(1000, 2000)
+		args := []Term{Integer(1), Integer(2)}
+		assert.LessOrEqual(t, testing.AllocsPerRun(1, func() {
+			for range 1000 {
+				_ = a.Apply(f, args...)
+			}
+		}), 2.0)
+	})
+
+	t.Run("arguments are copied", func(t *testing.T) {
+		args := []Term{Integer(1)}
+		c := a.Apply(f, args...)
+		args[0] = Integer(2)
+		assert.Equal(t, f.Apply(Integer(1)), c)
+	})
+
+	t.Run("asserted facts are copied", func(t *testing.T) {
+		var vm VM
+		fact := a.Apply(f, Integer(5))
+		_, err := Assertz(&vm, fact, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		p, ok := vm.getProcedure(procedureIndicator{name: f, arity: 1})
+		assert.True(t, ok)
+		raw := p.(*userDefined).clauses[0].raw
+		assert.Equal(t, fact, raw)
+		assert.NotSame(t, fact.(*compound), raw.(*compound))
+	})
+}
+
+func TestVM_AssertFacts(t *testing.T) {
+	edge := NewAtom("edge")
+	newVM := func() (*VM, *TermArena) {
+		a := NewTermArena(10, 20)
+		var vm VM
+		assert.NoError(t, vm.AssertFacts(a, []Term{
+			a.Apply(edge, Integer(1), Integer(2)),
+			a.Apply(edge, Integer(2), Integer(3)),
+		}))
+		return &vm, a
+	}
+
+	t.Run("shared", func(t *testing.T) {
+		vm, a := newVM()
+		fact := a.Apply(edge, Integer(2), Integer(3))
+		assert.NoError(t, vm.AssertFacts(a, []Term{fact}))
+		p, ok := vm.getProcedure(procedureIndicator{name: edge, arity: 2})
+		assert.True(t, ok)
+		cs := p.(*userDefined).clauses
+		assert.Len(t, cs, 3)
+		assert.Same(t, fact, cs[2].raw)
+		assert.Len(t, vm.arenas[procedureIndicator{name: edge, arity: 2}], 1)
+
+		x := NewVariable()
+		var got []Term
+		_, err := Call(vm, edge.Apply(Integer(2), x), func(env *Env) *Promise {
+			got = append(got, env.Resolve(x))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []Term{Integer(3), Integer(3)}, got)
+	})
+
+	t.Run("abolish", func(t *testing.T) {
+		vm, a := newVM()
+		_, err := Abolish(vm, PI("edge", 2), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Nil(t, a.compounds)
+		assert.Nil(t, a.args)
+		assert.Empty(t, vm.arenas)
+	})
+
+	t.Run("retractall", func(t *testing.T) {
+		vm, a := newVM()
+		_, err := RetractAll(vm, edge.Apply(Integer(1), NewVariable()), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.NotNil(t, a.compounds, "a clause is left")
+
+		_, err = RetractAll(vm, edge.Apply(NewVariable(), NewVariable()), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Nil(t, a.compounds)
+		assert.Empty(t, vm.arenas)
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		vm, _ := newVM()
+		c := vm.Clone()
+		b := NewTermArena(1, 2)
+		assert.NoError(t, c.AssertFacts(b, []Term{b.Apply(edge, Integer(3), Integer(4))}))
+		assert.Len(t, vm.arenas[procedureIndicator{name: edge, arity: 2}], 1)
+		assert.Len(t, c.arenas[procedureIndicator{name: edge, arity: 2}], 2)
+	})
+
+	t.Run("not callable", func(t *testing.T) {
+		var vm VM
+		assert.Equal(t, typeError(validTypeCallable, Integer(0), nil), vm.AssertFacts(NewTermArena(1, 1), []Term{Integer(0)}))
+		assert.Empty(t, vm.arenas)
+	})
+}
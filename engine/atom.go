@@ -1,10 +1,13 @@
 package engine
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"regexp"
 	"strings"
+	"sync"
+	"unsafe"
 )
 
 var (
@@ -42,35 +45,59 @@ var (
 	atomBitwiseAnd        = NewAtom(`/\`)
 	atomBitwiseOr         = NewAtom(`\/`)
 	atomElipsis           = NewAtom(`...`)
+	atomAtSign            = NewAtom("@")
 
+	atomAbolish                 = NewAtom("abolish")
 	atomAbs                     = NewAtom("abs")
 	atomAccess                  = NewAtom("access")
+	atomAcyclicTerm             = NewAtom("acyclic_term")
 	atomAlias                   = NewAtom("alias")
+	atomAll                     = NewAtom("all")
 	atomAppend                  = NewAtom("append")
+	atomAsserta                 = NewAtom("asserta")
+	atomAssertz                 = NewAtom("assertz")
 	atomAt                      = NewAtom("at")
+	atomAtan                    = NewAtom("atan")
+	atomAtan2                   = NewAtom("atan2")
+	atomAttrUnifyHook           = NewAtom("attr_unify_hook")
 	atomAtom                    = NewAtom("atom")
 	atomAtomic                  = NewAtom("atomic")
+	atomBag                     = NewAtom("bag")
+	atomBase64                  = NewAtom("base64")
+	atomBase64URL               = NewAtom("base64url")
+	atomBech32                  = NewAtom("bech32")
 	atomBinary                  = NewAtom("binary")
 	atomBinaryStream            = NewAtom("binary_stream")
 	atomBounded                 = NewAtom("bounded")
+	atomBuiltIn                 = NewAtom("built_in")
 	atomByte                    = NewAtom("byte")
 	atomCall                    = NewAtom("call")
+	atomCBOR                    = NewAtom("cbor")
 	atomCallable                = NewAtom("callable")
+	atomCalls                   = NewAtom("calls")
 	atomCeiling                 = NewAtom("ceiling")
+	atomChanged                 = NewAtom("changed")
 	atomCharConversion          = NewAtom("char_conversion")
 	atomCharacter               = NewAtom("character")
 	atomCharacterCode           = NewAtom("character_code")
 	atomCharacterCodeList       = NewAtom("character_code_list")
+	atomCharacterCount          = NewAtom("character_count")
 	atomChars                   = NewAtom("chars")
 	atomCloseOption             = NewAtom("close_option")
 	atomCodes                   = NewAtom("codes")
 	atomCompound                = NewAtom("compound")
+	atomContext                 = NewAtom("context")
+	atomCopysign                = NewAtom("copysign")
 	atomCreate                  = NewAtom("create")
+	atomDBReference             = NewAtom("db_reference")
 	atomDebug                   = NewAtom("debug")
+	atomDec10                   = NewAtom("dec10")
+	atomDefined                 = NewAtom("defined")
 	atomDictKey                 = NewAtom("dict_key")
 	atomDiscontiguous           = NewAtom("discontiguous")
 	atomDiv                     = NewAtom("div")
 	atomDomainError             = NewAtom("domain_error")
+	atomDOS                     = NewAtom("dos")
 	atomDoubleQuotes            = NewAtom("double_quotes")
 	atomDynamic                 = NewAtom("dynamic")
 	atomE                       = NewAtom("E")
@@ -79,17 +106,23 @@ var (
 	atomEndOfFile               = NewAtom("end_of_file")
 	atomEndOfStream             = NewAtom("end_of_stream")
 	atomEnsureLoaded            = NewAtom("ensure_loaded")
+	atomErase                   = NewAtom("erase")
 	atomError                   = NewAtom("error")
 	atomEvaluable               = NewAtom("evaluable")
 	atomEvaluationError         = NewAtom("evaluation_error")
+	atomException               = NewAtom("exception")
 	atomExistenceError          = NewAtom("existence_error")
+	atomExit                    = NewAtom("exit")
 	atomExp                     = NewAtom("exp")
+	atomExtended                = NewAtom("extended")
+	atomFullStop                = NewAtom("fullstop")
 	atomFX                      = NewAtom("fx")
 	atomFY                      = NewAtom("fy")
 	atomFail                    = NewAtom("fail")
 	atomFalse                   = NewAtom("false")
 	atomFileName                = NewAtom("file_name")
 	atomFiniteMemory            = NewAtom("finite_memory")
+	atomFirst                   = NewAtom("first")
 	atomFlag                    = NewAtom("flag")
 	atomFlagValue               = NewAtom("flag_value")
 	atomFloat                   = NewAtom("float")
@@ -97,21 +130,44 @@ var (
 	atomFloatIntegerPart        = NewAtom("float_integer_part")
 	atomFloatOverflow           = NewAtom("float_overflow")
 	atomFloor                   = NewAtom("floor")
+	atomFrame                   = NewAtom("frame")
 	atomForce                   = NewAtom("force")
+	atomFormat                  = NewAtom("format")
+	atomGas                     = NewAtom("gas")
+	atomGcd                     = NewAtom("gcd")
+	atomGoalExpansion           = NewAtom("goal_expansion")
+	atomHex                     = NewAtom("hex")
+	atomIfOption                = NewAtom("if")
+	atomImports                 = NewAtom("imports")
+	atomIndex                   = NewAtom("index")
+	atomInferences              = NewAtom("inferences")
 	atomIOMode                  = NewAtom("io_mode")
 	atomIgnoreOps               = NewAtom("ignore_ops")
 	atomInByte                  = NewAtom("in_byte")
 	atomInCharacter             = NewAtom("in_character")
 	atomInCharacterCode         = NewAtom("in_character_code")
 	atomInclude                 = NewAtom("include")
+	atomInfo                    = NewAtom("info")
+	atomInfinite                = NewAtom("infinite")
+	atomInformational           = NewAtom("informational")
 	atomInitialization          = NewAtom("initialization")
 	atomInput                   = NewAtom("input")
 	atomInstantiationError      = NewAtom("instantiation_error")
+	atomInstructions            = NewAtom("instructions")
 	atomIntOverflow             = NewAtom("int_overflow")
 	atomInteger                 = NewAtom("integer")
 	atomIntegerRoundingFunction = NewAtom("integer_rounding_function")
+	atomInternalError           = NewAtom("internal_error")
+	atomJSON                    = NewAtom("json")
+	atomJSONOption              = NewAtom("json_option")
+	atomKey                     = NewAtom("key")
+	atomLattice                 = NewAtom("lattice")
+	atomLineCount               = NewAtom("line_count")
+	atomLinePosition            = NewAtom("line_position")
 	atomList                    = NewAtom("list")
+	atomLoadOption              = NewAtom("load_option")
 	atomLog                     = NewAtom("log")
+	atomLogLevel                = NewAtom("log_level")
 	atomMax                     = NewAtom("max")
 	atomMaxArity                = NewAtom("max_arity")
 	atomMaxDepth                = NewAtom("max_depth")
@@ -122,19 +178,33 @@ var (
 	atomMod                     = NewAtom("mod")
 	atomMode                    = NewAtom("mode")
 	atomModify                  = NewAtom("modify")
+	atomModule                  = NewAtom("module")
+	atomModuleFile              = NewAtom("module_file")
+	atomMsb                     = NewAtom("msb")
 	atomMultifile               = NewAtom("multifile")
+	atomMustBeModule            = NewAtom("must_be_module")
+	atomNbSetval                = NewAtom("nb_setval")
+	atomNewline                 = NewAtom("newline")
+	atomNl                      = NewAtom("nl")
 	atomNonEmptyList            = NewAtom("non_empty_list")
 	atomNot                     = NewAtom("not")
 	atomNotLessThanZero         = NewAtom("not_less_than_zero")
+	atomNotLoaded               = NewAtom("not_loaded")
+	atomNull                    = NewAtom("null")
 	atomNumber                  = NewAtom("number")
+	atomNumberOfClauses         = NewAtom("number_of_clauses")
+	atomNumberSyntax            = NewAtom("number_syntax")
 	atomNumberVars              = NewAtom("numbervars")
+	atomOccursCheck             = NewAtom("occurs_check")
 	atomOff                     = NewAtom("off")
 	atomOn                      = NewAtom("on")
+	atomOp                      = NewAtom("op")
 	atomOpen                    = NewAtom("open")
 	atomOperator                = NewAtom("operator")
 	atomOperatorPriority        = NewAtom("operator_priority")
 	atomOperatorSpecifier       = NewAtom("operator_specifier")
 	atomOrder                   = NewAtom("order")
+	atomOutputSink              = NewAtom("output_sink")
 	atomOutput                  = NewAtom("output")
 	atomPair                    = NewAtom("pair")
 	atomPast                    = NewAtom("past")
@@ -142,36 +212,69 @@ var (
 	atomPermissionError         = NewAtom("permission_error")
 	atomPhrase                  = NewAtom("phrase")
 	atomPi                      = NewAtom("pi")
+	atomPo                      = NewAtom("po")
+	atomPortray                 = NewAtom("portray")
+	atomPOSIX                   = NewAtom("posix")
 	atomPosition                = NewAtom("position")
+	atomPredicate               = NewAtom("predicate")
 	atomPredicateIndicator      = NewAtom("predicate_indicator")
+	atomPreferRationals         = NewAtom("prefer_rationals")
 	atomPrivateProcedure        = NewAtom("private_procedure")
 	atomProcedure               = NewAtom("procedure")
+	atomProfile                 = NewAtom("profile")
 	atomPrologFlag              = NewAtom("prolog_flag")
+	atomQuiet                   = NewAtom("quiet")
 	atomQuoted                  = NewAtom("quoted")
+	atomRational                = NewAtom("rational")
+	atomRdiv                    = NewAtom("rdiv")
 	atomRead                    = NewAtom("read")
 	atomReadWrite               = NewAtom("read_write")
 	atomReadOption              = NewAtom("read_option")
+	atomRecorda                 = NewAtom("recorda")
+	atomRecorded                = NewAtom("recorded")
+	atomRecordz                 = NewAtom("recordz")
+	atomRedos                   = NewAtom("redos")
 	atomRem                     = NewAtom("rem")
+	atomReplacePredicate        = NewAtom("replace_predicate")
 	atomReposition              = NewAtom("reposition")
 	atomRepresentationError     = NewAtom("representation_error")
 	atomReset                   = NewAtom("reset")
 	atomResourceError           = NewAtom("resource_error")
+	atomRetract                 = NewAtom("retract")
+	atomRetractAll              = NewAtom("retractall")
 	atomRound                   = NewAtom("round")
+	atomSeed                    = NewAtom("seed")
+	atomSetPrologFlag           = NewAtom("set_prolog_flag")
+	atomSetRandom               = NewAtom("set_random")
 	atomSign                    = NewAtom("sign")
+	atomSilent                  = NewAtom("silent")
 	atomSingletons              = NewAtom("singletons")
 	atomSmallE                  = NewAtom("e")
 	atomSourceSink              = NewAtom("source_sink")
 	atomSqrt                    = NewAtom("sqrt")
+	atomStandard                = NewAtom("standard")
+	atomStatic                  = NewAtom("static")
 	atomStaticProcedure         = NewAtom("static_procedure")
+	atomStatisticsKey           = NewAtom("statistics_key")
 	atomStream                  = NewAtom("stream")
 	atomStreamOption            = NewAtom("stream_option")
 	atomStreamOrAlias           = NewAtom("stream_or_alias")
 	atomStreamPosition          = NewAtom("stream_position")
 	atomStreamProperty          = NewAtom("stream_property")
+	atomStrict                  = NewAtom("strict")
+	atomString                  = NewAtom("string")
+	atomStringBuilder           = NewAtom("string_builder")
 	atomSyntaxError             = NewAtom("syntax_error")
+	atomSyntaxErrors            = NewAtom("syntax_errors")
+	atomTable                   = NewAtom("table")
+	atomTabled                  = NewAtom("tabled")
+	atomTableMode               = NewAtom("table_mode")
+	atomTag                     = NewAtom("tag")
 	atomTermExpansion           = NewAtom("term_expansion")
 	atomText                    = NewAtom("text")
 	atomTextStream              = NewAtom("text_stream")
+	atomTime                    = NewAtom("time")
+	atomTimeLimitExceeded       = NewAtom("time_limit_exceeded")
 	atomTowardZero              = NewAtom("toward_zero")
 	atomTrue                    = NewAtom("true")
 	atomTruncate                = NewAtom("truncate")
@@ -180,12 +283,19 @@ var (
 	atomUnbounded               = NewAtom("unbounded")
 	atomUndefined               = NewAtom("undefined")
 	atomUnderflow               = NewAtom("underflow")
+	atomUninstantiationError    = NewAtom("uninstantiation_error")
 	atomUnknown                 = NewAtom("unknown")
+	atomUnknownProcedure        = NewAtom("unknown_procedure")
+	atomUseModule               = NewAtom("use_module")
+	atomUser                    = NewAtom("user")
 	atomUserInput               = NewAtom("user_input")
 	atomUserOutput              = NewAtom("user_output")
 	atomVar                     = NewAtom("$VAR")
+	atomVariable                = NewAtom("variable")
 	atomVariableNames           = NewAtom("variable_names")
 	atomVariables               = NewAtom("variables")
+	atomWall                    = NewAtom("wall")
+	atomWalltime                = NewAtom("walltime")
 	atomWarning                 = NewAtom("warning")
 	atomWrite                   = NewAtom("write")
 	atomWriteOption             = NewAtom("write_option")
@@ -210,6 +320,57 @@ func NewAtomRune(v rune) Atom {
 	return Atom(v)
 }
 
+// internedAtoms is the set of atoms NewAtomFromBytes returns without allocating.
+var internedAtoms = struct {
+	sync.RWMutex
+	atoms map[string]Atom
+}{
+	atoms: func() map[string]Atom {
+		m := make(map[string]Atom, 128)
+		for c := 0; c < 128; c++ {
+			a := NewAtomRune(rune(c))
+			m[a.String()] = a
+		}
+		return m
+	}(),
+}
+
+// InternAtom registers the atom name so that NewAtomFromBytes returns it without allocating.
+// It's meant for the atoms a host expects to create repeatedly, e.g. the keys of network payloads.
+func InternAtom(name string) Atom {
+	internedAtoms.Lock()
+	defer internedAtoms.Unlock()
+	a, ok := internedAtoms.atoms[name]
+	if !ok {
+		a = NewAtom(name)
+		internedAtoms.atoms[name] = a
+	}
+	return a
+}
+
+// NewAtomFromBytes returns an Atom of the given bytes.
+// It doesn't allocate if the atom is a single ASCII character or is registered by InternAtom.
+// The returned Atom doesn't refer to b so that b can be reused afterwards.
+func NewAtomFromBytes(b []byte) Atom {
+	internedAtoms.RLock()
+	a, ok := internedAtoms.atoms[string(b)] // Doesn't allocate.
+	internedAtoms.RUnlock()
+	if ok {
+		return a
+	}
+	return Atom(b)
+}
+
+// EqualBytes reports whether the name of the Atom is b without allocating.
+func (a Atom) EqualBytes(b []byte) bool {
+	return string(a) == string(b)
+}
+
+// CompareBytes compares the name of the Atom with b lexicographically without allocating.
+func (a Atom) CompareBytes(b []byte) int {
+	return bytes.Compare(unsafe.Slice(unsafe.StringData(string(a)), len(a)), b)
+}
+
 // WriteTerm outputs the Atom to an io.Writer.
 func (a Atom) WriteTerm(w io.Writer, opts *WriteOptions, _ *Env) error {
 	ew := errWriter{w: w}
@@ -252,7 +413,7 @@ func (a Atom) WriteTerm(w io.Writer, opts *WriteOptions, _ *Env) error {
 func (a Atom) Compare(t Term, env *Env) int {
 	env.charge(MeterCompareStep, 1)
 	switch t := env.Resolve(t).(type) {
-	case Variable, Float, Integer:
+	case Variable, Float, Integer, Rational:
 		return 1
 	case Atom:
 		switch d := strings.Compare(a.String(), t.String()); {
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"testing"
+)
+
+var benchmarkAtom Atom
+
+func BenchmarkNewAtomFromBytes(b *testing.B) {
+	payload := []byte("uusd")
+	InternAtom("uusd")
+
+	b.Run("string_conversion", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchmarkAtom = NewAtom(string(payload))
+		}
+	})
+
+	b.Run("interned", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchmarkAtom = NewAtomFromBytes(payload)
+		}
+	})
+
+	b.Run("not_interned", func(b *testing.B) {
+		payload := []byte("uatom_not_interned")
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchmarkAtom = NewAtomFromBytes(payload)
+		}
+	})
+}
+
+func BenchmarkAtom_EqualBytes(b *testing.B) {
+	payload := []byte("uusd")
+	a := NewAtom("uusd")
+
+	b.Run("string_conversion", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchmarkFound = a == NewAtom(string(payload))
+		}
+	})
+
+	b.Run("equal_bytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchmarkFound = a.EqualBytes(payload)
+		}
+	})
+}
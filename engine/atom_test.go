@@ -66,3 +66,34 @@ func TestAtom_Compare(t *testing.T) {
 		})
 	}
 }
+
+func TestNewAtomFromBytes(t *testing.T) {
+	b := []byte("foo")
+	a := NewAtomFromBytes(b)
+	assert.Equal(t, NewAtom("foo"), a)
+	b[0] = 'b'
+	assert.Equal(t, NewAtom("foo"), a, "the atom doesn't refer to the bytes")
+
+	assert.Equal(t, NewAtom(""), NewAtomFromBytes(nil))
+	assert.Equal(t, NewAtom("a"), NewAtomFromBytes([]byte("a")))
+
+	assert.Equal(t, NewAtom("uatom"), InternAtom("uatom"))
+	assert.Equal(t, NewAtom("uatom"), NewAtomFromBytes([]byte("uatom")))
+	assert.Zero(t, testing.AllocsPerRun(10, func() {
+		_ = NewAtomFromBytes([]byte("uatom")[:5])
+	}))
+}
+
+func TestAtom_EqualBytes(t *testing.T) {
+	assert.True(t, NewAtom("foo").EqualBytes([]byte("foo")))
+	assert.False(t, NewAtom("foo").EqualBytes([]byte("bar")))
+	assert.True(t, NewAtom("").EqualBytes(nil))
+}
+
+func TestAtom_CompareBytes(t *testing.T) {
+	assert.Equal(t, 0, NewAtom("foo").CompareBytes([]byte("foo")))
+	assert.Equal(t, 1, NewAtom("foo").CompareBytes([]byte("bar")))
+	assert.Equal(t, -1, NewAtom("bar").CompareBytes([]byte("foo")))
+	assert.Equal(t, -1, NewAtom("").CompareBytes([]byte("a")))
+	assert.Equal(t, 0, NewAtom("").CompareBytes(nil))
+}
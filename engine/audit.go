@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// SetAuditMode turns the audit mode on or off. In audit mode, the VM verifies the bytecode of clauses as they're
+// loaded and converts panics during execution into internal_error exceptions instead of crashing.
+// The audit mode is always on when built with the prolog_audit tag.
+func (vm *VM) SetAuditMode(on bool) {
+	vm.audit = on
+}
+
+func (vm *VM) auditing() bool {
+	return auditByDefault || vm.audit
+}
+
+// internalError creates a new internal error exception error(internal_error(Message), Context).
+func internalError(msg string, context Term) Exception {
+	return NewException(atomError.Apply(atomInternalError.Apply(NewAtom(msg)), context), nil)
+}
+
+// recoverExec converts a panic during the execution of op into an exception.
+func recoverExec(p **Promise, op *instruction) {
+	r := recover()
+	switch r.(type) {
+	case nil:
+		return
+	case meterPanic, Exception:
+		*p = Error(panicError(r))
+	default:
+		*p = Error(internalError(fmt.Sprint(r), op.term()))
+	}
+}
+
+func (i instruction) term() Term {
+	name := NewAtom(i.opcode.String())
+	if i.operand == nil {
+		return name
+	}
+	return name.Apply(i.operand)
+}
+
+// verify checks the bytecode of the clauses if the VM is in audit mode.
+func (vm *VM) verify(cs clauses) error {
+	if !vm.auditing() {
+		return nil
+	}
+	for _, c := range cs {
+		if err := c.verify(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verify checks the invariants the execution relies on: operands are of the expected types, variables are within
+// bounds, arguments match arities, and the bytecode ends with exit.
+func (c *clause) verify() error {
+	var (
+		body bool
+		// The number of arguments to get in the head, the number of arguments put for the next call in the body,
+		// and the number of arguments left for the nested compound terms.
+		levels = []int{int(c.pi.arity)}
+	)
+	for i, op := range c.bytecode {
+		fail := func(format string, args ...interface{}) error {
+			return internalError(fmt.Sprintf("invalid bytecode: "+format, args...), atomSlash.Apply(c.pi.Term(), Integer(i)))
+		}
+
+		arg := func() error {
+			top := len(levels) - 1
+			if body && top == 0 {
+				levels[top]++
+				return nil
+			}
+			if levels[top] == 0 {
+				return fail("too many arguments for %s", op)
+			}
+			levels[top]--
+			return nil
+		}
+
+		switch op.opcode {
+		case OpGetConst, OpGetVar, OpGetFunctor, OpGetList, OpGetPartial, OpGetDict:
+			if body {
+				return fail("%s in body", op)
+			}
+		case OpPutConst, OpPutVar, OpPutFunctor, OpPutList, OpPutPartial, OpPutDict:
+			if !body {
+				return fail("%s in head", op)
+			}
+		}
+
+		switch op.opcode {
+		case OpGetConst, OpPutConst:
+			switch op.operand.(type) {
+			case nil, Variable:
+				return fail("%s without constant", op)
+			case charList, codeList:
+			case Compound:
+				return fail("%s with compound", op)
+			}
+			if err := arg(); err != nil {
+				return err
+			}
+		case OpGetVar, OpPutVar:
+			n, ok := op.operand.(Integer)
+			if !ok || n < 0 || int(n) >= len(c.vars) {
+				return fail("%s out of %d variables", op, len(c.vars))
+			}
+			if err := arg(); err != nil {
+				return err
+			}
+		case OpGetFunctor, OpPutFunctor:
+			pi, ok := op.operand.(procedureIndicator)
+			if !ok || pi.arity < 0 {
+				return fail("%s without functor", op)
+			}
+			if err := arg(); err != nil {
+				return err
+			}
+			levels = append(levels, int(pi.arity))
+		case OpGetList, OpPutList, OpGetDict, OpPutDict, OpGetPartial, OpPutPartial:
+			n, ok := op.operand.(Integer)
+			if !ok || n < 0 {
+				return fail("%s without length", op)
+			}
+			if err := arg(); err != nil {
+				return err
+			}
+			if op.opcode == OpGetPartial || op.opcode == OpPutPartial {
+				n++
+			}
+			levels = append(levels, int(n))
+		case OpPop:
+			if len(levels) == 1 || levels[len(levels)-1] != 0 {
+				return fail("unbalanced %s", op)
+			}
+			levels = levels[:len(levels)-1]
+		case OpEnter:
+			if body || len(levels) != 1 || levels[0] != 0 {
+				return fail("unexpected %s", op)
+			}
+			body = true
+		case OpCall:
+			pi, ok := op.operand.(procedureIndicator)
+			if !ok {
+				return fail("%s without procedure indicator", op)
+			}
+			if !body || len(levels) != 1 || levels[0] != int(pi.arity) {
+				return fail("unexpected %s", op)
+			}
+			levels[0] = 0
+		case OpCut:
+			if !body || len(levels) != 1 || levels[0] != 0 {
+				return fail("unexpected %s", op)
+			}
+		case OpExit:
+			if i != len(c.bytecode)-1 || len(levels) != 1 || levels[0] != 0 {
+				return fail("unexpected %s", op)
+			}
+		default:
+			return fail("unknown opcode %s", op.opcode)
+		}
+	}
+	if len(c.bytecode) == 0 || c.bytecode[len(c.bytecode)-1].opcode != OpExit {
+		return internalError("invalid bytecode: missing exit", c.pi.Term())
+	}
+	return nil
+}
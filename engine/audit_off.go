@@ -0,0 +1,6 @@
+//go:build !prolog_audit
+
+package engine
+
+// auditByDefault turns the audit mode on for every VM built with the prolog_audit tag.
+const auditByDefault = false
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_SetAuditMode(t *testing.T) {
+	foo := NewAtom("foo")
+	malformed := &userDefined{clauses: clauses{
+		{
+			pi:   procedureIndicator{name: foo, arity: 1},
+			vars: []Variable{NewVariable()},
+			bytecode: bytecode{
+				{opcode: OpGetVar, operand: Integer(3)},
+				{opcode: OpExit},
+			},
+		},
+	}}
+
+	t.Run("on", func(t *testing.T) {
+		var vm VM
+		vm.SetAuditMode(true)
+		vm.setProcedure(procedureIndicator{name: foo, arity: 1}, malformed)
+
+		_, err := Call(&vm, foo.Apply(NewAtom("a")), Success, nil).Force(context.Background())
+		assert.Equal(t, internalError("runtime error: index out of range [3] with length 1", NewAtom("get_var").Apply(Integer(3))), err)
+	})
+
+	t.Run("meter", func(t *testing.T) {
+		var vm VM
+		vm.SetAuditMode(true)
+		vm.Register0(foo, func(_ *VM, k Cont, env *Env) *Promise {
+			return k(env)
+		})
+		vm.InstallMeter(func(MeterKind, uint64) Term {
+			return NewAtom("out_of_gas")
+		})
+
+		_, err := Call(&vm, foo, Success, nil).Force(context.Background())
+		assert.ErrorContains(t, err, "out_of_gas")
+	})
+
+	t.Run("verify at load time", func(t *testing.T) {
+		var vm VM
+		vm.SetAuditMode(true)
+		assert.NoError(t, vm.Compile(context.Background(), `
+:-(foo(X, [a|T], f(X, g)), ','(bar(X, [T]), ','(!, baz))).
+`))
+		assert.Error(t, vm.verify(malformed.clauses))
+
+		vm.SetAuditMode(false)
+		assert.Equal(t, auditByDefault, vm.verify(malformed.clauses) != nil)
+	})
+}
+
+func TestClause_verify(t *testing.T) {
+	foo := procedureIndicator{name: NewAtom("foo"), arity: 1}
+	bar := procedureIndicator{name: NewAtom("bar"), arity: 1}
+
+	tests := []struct {
+		title    string
+		bytecode bytecode
+		err      string
+	}{
+		{title: "ok", bytecode: bytecode{
+			{opcode: OpGetFunctor, operand: bar},
+			{opcode: OpGetVar, operand: Integer(0)},
+			{opcode: OpPop},
+			{opcode: OpEnter},
+			{opcode: OpPutList, operand: Integer(1)},
+			{opcode: OpPutConst, operand: NewAtom("a")},
+			{opcode: OpPop},
+			{opcode: OpCall, operand: bar},
+			{opcode: OpCut},
+			{opcode: OpExit},
+		}},
+		{title: "missing exit", bytecode: bytecode{
+			{opcode: OpGetVar, operand: Integer(0)},
+		}, err: "missing exit"},
+		{title: "variable out of bounds", bytecode: bytecode{
+			{opcode: OpGetVar, operand: Integer(1)},
+			{opcode: OpExit},
+		}, err: "get_var(1) out of 1 variables"},
+		{title: "operand type", bytecode: bytecode{
+			{opcode: OpGetList, operand: NewAtom("a")},
+			{opcode: OpExit},
+		}, err: "get_list(a) without length"},
+		{title: "too many arguments", bytecode: bytecode{
+			{opcode: OpGetVar, operand: Integer(0)},
+			{opcode: OpGetVar, operand: Integer(0)},
+			{opcode: OpExit},
+		}, err: "too many arguments"},
+		{title: "put in head", bytecode: bytecode{
+			{opcode: OpPutVar, operand: Integer(0)},
+			{opcode: OpExit},
+		}, err: "put_var(0) in head"},
+		{title: "unbalanced pop", bytecode: bytecode{
+			{opcode: OpGetVar, operand: Integer(0)},
+			{opcode: OpPop},
+			{opcode: OpExit},
+		}, err: "unbalanced pop()"},
+		{title: "arity mismatch", bytecode: bytecode{
+			{opcode: OpGetVar, operand: Integer(0)},
+			{opcode: OpEnter},
+			{opcode: OpCall, operand: bar},
+			{opcode: OpExit},
+		}, err: "unexpected call(bar/1)"},
+		{title: "unknown opcode", bytecode: bytecode{
+			{opcode: Opcode(255)},
+		}, err: "unknown opcode (255)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			c := clause{pi: foo, vars: []Variable{NewVariable()}, bytecode: tt.bytecode}
+			err := c.verify()
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+var atomAutoload = NewAtom("autoload")
+
+// Autoload makes the VM load the Prolog text file from FS on the first call to the procedure identified by the
+// predicate indicator pi e.g. PI("foo", 2) if it's not defined yet.
+func (vm *VM) Autoload(pi Term, file string) error {
+	key, err := toProcedureIndicator(pi, nil)
+	if err != nil {
+		return err
+	}
+	if vm.autoloads == nil {
+		vm.autoloads = map[procedureIndicator]string{}
+	}
+	vm.autoloads[key] = file
+	return nil
+}
+
+// LoadAutoloadIndex reads the index file name from FS and registers the procedures it lists to be loaded on their
+// first calls. The index file consists of facts of the form autoload(PI, File), e.g. autoload(foo/2, 'lib/foo.pl').
+// Large rule bases split into files along with such an index start fast since only the called procedures are
+// compiled.
+func (vm *VM) LoadAutoloadIndex(name string) error {
+	if vm.FS == nil {
+		return permissionError(operationOpen, permissionTypeSourceSink, NewAtom(name), nil)
+	}
+	b, err := fs.ReadFile(vm.FS, name)
+	if err != nil {
+		return existenceError(objectTypeSourceSink, NewAtom(name), nil)
+	}
+
+	p := NewParser(vm, strings.NewReader(string(b)))
+	for p.More() {
+		t, err := p.Term()
+		if err != nil {
+			return err
+		}
+		c, ok := t.(Compound)
+		if !ok || c.Functor() != atomAutoload || c.Arity() != 2 {
+			return fmt.Errorf("%s: malformed autoload entry", name)
+		}
+		file, ok := c.Arg(1).(Atom)
+		if !ok {
+			return typeError(validTypeAtom, c.Arg(1), nil)
+		}
+		if err := vm.Autoload(c.Arg(0), file.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// autoload loads the file which defines pi, if any, and calls the procedure.
+// The entry is consumed once the file is loaded so that a file which doesn't define pi after all is loaded only once,
+// while a file which fails to load is tried again on the next call.
+func (vm *VM) autoload(m Atom, pi procedureIndicator, args []Term, k Cont, env *Env) (*Promise, bool) {
+	file, ok := vm.autoloads[pi]
+	if !ok {
+		return nil, false
+	}
+	return Delay(func(ctx context.Context) *Promise {
+		if _, err := vm.ensureLoaded(ctx, NewAtom(file), env); err != nil {
+			return Error(err)
+		}
+		delete(vm.autoloads, pi)
+		return vm.Arrive(atomColon, []Term{m, pi.name.Apply(args...)}, k, env)
+	}), true
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_LoadAutoloadIndex(t *testing.T) {
+	fsys := fstest.MapFS{
+		"INDEX.pl": {Data: []byte(`
+autoload('/'(foo, 1), 'lib/foo.pl').
+autoload('/'(bar, 0), 'lib/bar.pl').
+autoload('/'(baz, 0), 'lib/missing.pl').
+`)},
+		"lib/foo.pl":  {Data: []byte(`foo(a). foo(b).`)},
+		"lib/bar.pl":  {Data: []byte(`other.`)},
+		"bad.pl":      {Data: []byte(`foo.`)},
+		"bad_file.pl": {Data: []byte(`autoload('/'(foo, 1), 1).`)},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		vm := VM{FS: fsys}
+		assert.NoError(t, vm.LoadAutoloadIndex("INDEX.pl"))
+		assert.Empty(t, vm.LoadedSources())
+
+		var got []Term
+		x := NewVariable()
+		ok, err := vm.Arrive(NewAtom("foo"), []Term{x}, func(env *Env) *Promise {
+			got = append(got, env.Resolve(x))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, []Term{NewAtom("a"), NewAtom("b")}, got)
+		assert.Equal(t, []string{"lib/foo.pl"}, vm.LoadedSources())
+
+		_, err = vm.Arrive(NewAtom("bar"), nil, Success, nil).Force(context.Background())
+		assert.Equal(t, existenceError(objectTypeProcedure, atomSlash.Apply(NewAtom("bar"), Integer(0)), nil), err)
+
+		_, err = vm.Arrive(NewAtom("baz"), nil, Success, nil).Force(context.Background())
+		assert.Equal(t, existenceError(objectTypeSourceSink, NewAtom("lib/missing.pl"), nil), err)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		fsys := fstest.MapFS{}
+		vm := VM{FS: fsys}
+		assert.NoError(t, vm.Autoload(atomSlash.Apply(NewAtom("baz"), Integer(0)), "lib/missing.pl"))
+
+		_, err := vm.Arrive(NewAtom("baz"), nil, Success, nil).Force(context.Background())
+		assert.Equal(t, existenceError(objectTypeSourceSink, NewAtom("lib/missing.pl"), nil), err)
+
+		fsys["lib/missing.pl"] = &fstest.MapFile{Data: []byte(`baz.`)}
+		ok, err := vm.Arrive(NewAtom("baz"), nil, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Empty(t, vm.autoloads)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		vm := VM{FS: fsys}
+		assert.Error(t, vm.LoadAutoloadIndex("bad.pl"))
+		assert.Equal(t, typeError(validTypeAtom, Integer(1), nil), vm.LoadAutoloadIndex("bad_file.pl"))
+		assert.Equal(t, existenceError(objectTypeSourceSink, NewAtom("not_found.pl"), nil), vm.LoadAutoloadIndex("not_found.pl"))
+	})
+}
@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+)
+
+// bagIDCounter is a counter for generating unique bag IDs.
+var bagIDCounter uint64
+
+// bag is an opaque term holding a multiset of terms ordered by the standard order of terms.
+// It's a pairing heap so that adding an element takes constant time and removing the minimum takes amortized
+// logarithmic time. Like a string builder, it's updated in place and the updates aren't undone on backtracking.
+type bag struct {
+	id   uint64
+	root *bagNode
+	len  int
+}
+
+type bagNode struct {
+	elem           Term
+	child, sibling *bagNode
+}
+
+// WriteTerm outputs the bag to an io.Writer.
+func (b *bag) WriteTerm(w io.Writer, _ *WriteOptions, _ *Env) error {
+	_, err := fmt.Fprintf(w, "<bag>(0x%x)", b.id)
+	return err
+}
+
+// Compare compares the bag with a Term.
+func (b *bag) Compare(t Term, env *Env) int {
+	return CompareAtomic[*bag](b, t, func(b *bag, t *bag) int {
+		switch {
+		case b.id > t.id:
+			return 1
+		case b.id < t.id:
+			return -1
+		default:
+			return 0
+		}
+	}, env)
+}
+
+func newBag() *bag {
+	return &bag{id: atomic.AddUint64(&bagIDCounter, 1)}
+}
+
+func (b *bag) add(t Term) {
+	b.root = meldBagNodes(b.root, &bagNode{elem: t})
+	b.len++
+}
+
+func (b *bag) removeMin() (Term, bool) {
+	if b.root == nil {
+		return nil, false
+	}
+	min := b.root.elem
+	b.root = mergeBagPairs(b.root.child)
+	b.len--
+	return min, true
+}
+
+func (b *bag) elems() []Term {
+	ret := make([]Term, 0, b.len)
+	stack := []*bagNode{b.root}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for ; n != nil; n = n.sibling {
+			ret = append(ret, n.elem)
+			stack = append(stack, n.child)
+		}
+	}
+	return ret
+}
+
+func meldBagNodes(a, b *bagNode) *bagNode {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	if b.elem.Compare(a.elem, nil) < 0 {
+		a, b = b, a
+	}
+	b.sibling = a.child
+	a.child = b
+	return a
+}
+
+// mergeBagPairs melds the siblings from n in two passes: from left to right by pairs, and then from right to left.
+func mergeBagPairs(n *bagNode) *bagNode {
+	var pairs []*bagNode
+	for n != nil {
+		a, b := n, n.sibling
+		if b == nil {
+			a.sibling = nil
+			pairs = append(pairs, a)
+			break
+		}
+		n = b.sibling
+		a.sibling, b.sibling = nil, nil
+		pairs = append(pairs, meldBagNodes(a, b))
+	}
+	var ret *bagNode
+	for i := len(pairs) - 1; i >= 0; i-- {
+		ret = meldBagNodes(pairs[i], ret)
+	}
+	return ret
+}
+
+// BagCreate unifies bag with a new empty bag.
+func BagCreate(vm *VM, bag Term, k Cont, env *Env) *Promise {
+	if _, ok := env.Resolve(bag).(Variable); !ok {
+		return Error(UninstantiationError(env.Resolve(bag), env))
+	}
+
+	return Unify(vm, bag, newBag(), k, env)
+}
+
+// BagAdd adds a copy of elem to bag.
+func BagAdd(_ *VM, bag, elem Term, k Cont, env *Env) *Promise {
+	b, err := bagOf(bag, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	c, err := renamedCopy(elem, nil, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	b.add(c)
+	return k(env)
+}
+
+// BagMin removes the least element of bag in the standard order of terms and unifies it with min.
+// It fails if bag is empty.
+func BagMin(vm *VM, bag, min Term, k Cont, env *Env) *Promise {
+	b, err := bagOf(bag, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	m, ok := b.removeMin()
+	if !ok {
+		return Bool(false)
+	}
+	return Unify(vm, min, m, k, env)
+}
+
+// BagToList unifies list with the elements of bag sorted in the standard order of terms, duplicates included.
+func BagToList(vm *VM, bag, list Term, k Cont, env *Env) *Promise {
+	b, err := bagOf(bag, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	elems := b.elems()
+	sort.SliceStable(elems, func(i, j int) bool {
+		return elems[i].Compare(elems[j], nil) == -1
+	})
+	return Unify(vm, list, List(elems...), k, env)
+}
+
+func bagOf(b Term, env *Env) (*bag, error) {
+	switch b := env.Resolve(b).(type) {
+	case Variable:
+		return nil, InstantiationError(env)
+	case *bag:
+		return b, nil
+	default:
+		return nil, typeError(validTypeBag, b, env)
+	}
+}
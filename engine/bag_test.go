@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBag(t *testing.T) {
+	var vm VM
+
+	t.Run("ok", func(t *testing.T) {
+		b, l, m := NewVariable(), NewVariable(), NewVariable()
+		ok, err := BagCreate(&vm, b, func(env *Env) *Promise {
+			for _, e := range []Term{Integer(3), NewAtom("a"), Integer(1), Integer(3), Integer(2)} {
+				if _, err := BagAdd(&vm, b, e, Success, env).Force(context.Background()); err != nil {
+					return Error(err)
+				}
+			}
+			return BagToList(&vm, b, l, func(env *Env) *Promise {
+				assert.Equal(t, List(Integer(1), Integer(2), Integer(3), Integer(3), NewAtom("a")), env.Resolve(l))
+				return BagMin(&vm, b, m, func(env *Env) *Promise {
+					assert.Equal(t, Integer(1), env.Resolve(m))
+					return Bool(true)
+				}, env)
+			}, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("heap order", func(t *testing.T) {
+		b := newBag()
+		for _, n := range rand.New(rand.NewSource(0)).Perm(1000) {
+			b.add(Integer(n))
+		}
+		for i := 0; i < 1000; i++ {
+			m, ok := b.removeMin()
+			assert.True(t, ok)
+			assert.Equal(t, Integer(i), m)
+		}
+		_, ok := b.removeMin()
+		assert.False(t, ok)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		ok, err := BagMin(&vm, newBag(), NewVariable(), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("elements are copied", func(t *testing.T) {
+		b, x := newBag(), NewVariable()
+		env := NewEnv().bind(x, NewAtom("a"))
+		ok, err := BagAdd(&vm, b, NewAtom("f").Apply(x), Success, env).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []Term{NewAtom("f").Apply(NewAtom("a"))}, b.elems())
+	})
+
+	t.Run("bag is not a variable", func(t *testing.T) {
+		ok, err := BagCreate(&vm, NewAtom("b"), Success, nil).Force(context.Background())
+		assert.Equal(t, UninstantiationError(NewAtom("b"), nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("bag is a variable", func(t *testing.T) {
+		ok, err := BagAdd(&vm, NewVariable(), NewAtom("a"), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("bag is not a bag", func(t *testing.T) {
+		ok, err := BagToList(&vm, NewAtom("b"), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeBag, NewAtom("b"), nil), err)
+		assert.False(t, ok)
+	})
+}
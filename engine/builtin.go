@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"slices"
 	"sort"
 	"strings"
 	"unicode"
@@ -36,6 +37,29 @@ func Negate(vm *VM, goal Term, k Cont, env *Env) *Promise {
 	})
 }
 
+// ForAll succeeds if action succeeds for every solution of cond as \+ (cond, \+ action) does. Neither cond nor action
+// leaves bindings, and a cut inside either of them doesn't affect outside of ForAll.
+func ForAll(vm *VM, cond, action Term, k Cont, env *Env) *Promise {
+	return Delay(func(ctx context.Context) *Promise {
+		ok, err := Call(vm, cond, func(env *Env) *Promise {
+			return Delay(func(ctx context.Context) *Promise {
+				ok, err := Call(vm, action, Success, env).Force(ctx)
+				if err != nil {
+					return Error(err)
+				}
+				return Bool(!ok)
+			})
+		}, env).Force(ctx)
+		if err != nil {
+			return Error(err)
+		}
+		if ok {
+			return Bool(false)
+		}
+		return k(env)
+	})
+}
+
 // Call executes goal. it succeeds if goal followed by k succeeds. A cut inside goal doesn't affect outside of Call.
 func Call(vm *VM, goal Term, k Cont, env *Env) (promise *Promise) {
 	defer ensurePromise(&promise)
@@ -97,20 +121,37 @@ func Call7(vm *VM, closure, arg1, arg2, arg3, arg4, arg5, arg6, arg7 Term, k Con
 }
 
 func callN(vm *VM, closure Term, additional []Term, k Cont, env *Env) *Promise {
-	pi, arg, err := piArg(closure, env)
+	goal, err := extendClosure(closure, additional, env)
 	if err != nil {
 		return Error(err)
 	}
+	return Call(vm, goal, k, env)
+}
+
+// extendClosure returns the goal of closure with the additional arguments. A module qualified closure results in a
+// module qualified goal.
+func extendClosure(closure Term, additional []Term, env *Env) (Term, error) {
+	if c, ok := env.Resolve(closure).(Compound); ok && c.Functor() == atomColon && c.Arity() == 2 {
+		g, err := extendClosure(c.Arg(1), additional, env)
+		if err != nil {
+			return nil, err
+		}
+		return atomColon.Apply(c.Arg(0), g), nil
+	}
+	pi, arg, err := piArg(closure, env)
+	if err != nil {
+		return nil, err
+	}
 	args, err := makeSlice(int(pi.arity) + len(additional))
 	if err != nil {
-		return Error(resourceError(resourceMemory, env))
+		return nil, resourceError(resourceMemory, env)
 	}
 	args = args[:pi.arity]
 	for i := 0; i < int(pi.arity); i++ {
 		args[i] = arg(i)
 	}
 	args = append(args, additional...)
-	return Call(vm, pi.name.Apply(args...), k, env)
+	return pi.name.Apply(args...), nil
 }
 
 // CallNth succeeds iff goal succeeds and nth unifies with the number of re-execution.
@@ -154,22 +195,22 @@ func CallNth(vm *VM, goal, nth Term, k Cont, env *Env) *Promise {
 	return p
 }
 
-// Unify unifies x and y without occurs check (i.e., X = f(X) is allowed).
-func Unify(_ *VM, x, y Term, k Cont, env *Env) *Promise {
+// Unify unifies x and y without occurs check (i.e., X = f(X) is allowed) unless the prolog flag occurs_check is true.
+func Unify(vm *VM, x, y Term, k Cont, env *Env) *Promise {
 	env, ok := env.Unify(x, y)
 	if !ok {
 		return Bool(false)
 	}
-	return k(env)
+	return vm.wakeUp(k, env)
 }
 
 // UnifyWithOccursCheck unifies x and y with occurs check (i.e., X = f(X) is not allowed).
-func UnifyWithOccursCheck(_ *VM, x, y Term, k Cont, env *Env) *Promise {
+func UnifyWithOccursCheck(vm *VM, x, y Term, k Cont, env *Env) *Promise {
 	env, ok := env.unifyWithOccursCheck(x, y)
 	if !ok {
 		return Bool(false)
 	}
-	return k(env)
+	return vm.wakeUp(k, env)
 }
 
 // SubsumesTerm succeeds if general and specific are unifiable without binding variables in specific.
@@ -210,6 +251,16 @@ func TypeInteger(_ *VM, t Term, k Cont, env *Env) *Promise {
 	return k(env)
 }
 
+// TypeRational checks if t is a rational number, either an integer or a rational.
+func TypeRational(_ *VM, t Term, k Cont, env *Env) *Promise {
+	switch env.Resolve(t).(type) {
+	case Integer, Rational:
+		return k(env)
+	default:
+		return Bool(false)
+	}
+}
+
 // TypeAtom checks if t is an atom.
 func TypeAtom(_ *VM, t Term, k Cont, env *Env) *Promise {
 	if _, ok := env.Resolve(t).(Atom); !ok {
@@ -382,6 +433,70 @@ func Univ(vm *VM, t, list Term, k Cont, env *Env) *Promise {
 	}
 }
 
+// PutAttr sets the value of the attribute module of the variable v.
+// Once v gets unified, module:attr_unify_hook(Value, Other) is called.
+func PutAttr(vm *VM, v, module, value Term, k Cont, env *Env) *Promise {
+	x, ok := env.Resolve(v).(Variable)
+	if !ok {
+		return Error(UninstantiationError(env.Resolve(v), env))
+	}
+	m, err := attrModule(module, env)
+	if err != nil {
+		return Error(err)
+	}
+	return k(env.putAttr(x, m, env.Resolve(value)))
+}
+
+// GetAttr succeeds if the variable v has the attribute module whose value unifies with value.
+func GetAttr(vm *VM, v, module, value Term, k Cont, env *Env) *Promise {
+	m, err := attrModule(module, env)
+	if err != nil {
+		return Error(err)
+	}
+	x, ok := env.Resolve(v).(Variable)
+	if !ok {
+		return Bool(false)
+	}
+	a, ok := env.getAttr(x, m)
+	if !ok {
+		return Bool(false)
+	}
+	return Unify(vm, value, a, k, env)
+}
+
+// DelAttr removes the attribute module from the variable v, if any.
+func DelAttr(vm *VM, v, module Term, k Cont, env *Env) *Promise {
+	m, err := attrModule(module, env)
+	if err != nil {
+		return Error(err)
+	}
+	x, ok := env.Resolve(v).(Variable)
+	if !ok {
+		return k(env)
+	}
+	return k(env.delAttr(x, m))
+}
+
+// AttVar succeeds if t is a variable with at least one attribute.
+func AttVar(_ *VM, t Term, k Cont, env *Env) *Promise {
+	x, ok := env.Resolve(t).(Variable)
+	if !ok || len(env.getAttrs(x)) == 0 {
+		return Bool(false)
+	}
+	return k(env)
+}
+
+func attrModule(module Term, env *Env) (Atom, error) {
+	switch m := env.Resolve(module).(type) {
+	case Variable:
+		return "", InstantiationError(env)
+	case Atom:
+		return m, nil
+	default:
+		return "", typeError(validTypeAtom, m, env)
+	}
+}
+
 // CopyTerm clones in as out.
 func CopyTerm(vm *VM, in, out Term, k Cont, env *Env) *Promise {
 	c, err := renamedCopy(in, nil, env)
@@ -472,6 +587,7 @@ func renamedCopy(t Term, copied map[termID]Term, env *Env) (Term, error) {
 func TermVariables(vm *VM, term, vars Term, k Cont, env *Env) *Promise {
 	var (
 		witness  = map[Variable]struct{}{}
+		seen     = map[termID]struct{}{} // compounds might be cyclic.
 		ret      []Term
 		t        Term
 		traverse = []Term{term}
@@ -485,6 +601,11 @@ func TermVariables(vm *VM, term, vars Term, k Cont, env *Env) *Promise {
 			}
 			witness[t] = struct{}{}
 		case Compound:
+			// The variables of a compound seen before are already in ret.
+			if _, ok := seen[id(t)]; ok {
+				continue
+			}
+			seen[id(t)] = struct{}{}
 			args, err := makeSlice(t.Arity())
 			if err != nil {
 				return Error(resourceError(resourceMemory, env))
@@ -570,6 +691,12 @@ func Op(vm *VM, priority, specifier, op Term, k Cont, env *Env) *Promise {
 		}
 	}
 
+	for _, name := range names {
+		if err := vm.checkFrozen(permissionTypeOperator, name, env); err != nil {
+			return Error(err)
+		}
+	}
+
 	for _, name := range names {
 		if class := spec.class(); vm.getOperators().definedInClass(name, spec.class()) {
 			vm.getOperators().remove(name, class)
@@ -578,6 +705,7 @@ func Op(vm *VM, priority, specifier, op Term, k Cont, env *Env) *Promise {
 		vm.getOperators().define(p, spec, name)
 	}
 
+	vm.record(atomOp, []Term{priority, specifier, op}, env)
 	return k(env)
 }
 
@@ -624,6 +752,8 @@ func appendUniqNewAtom(slice []Atom, elem Atom) []Atom {
 }
 
 // CurrentOp succeeds if operator is defined with priority and specifier.
+// It enumerates the operators ordered by priority, specifier (fx, fy, xf, yf, xfx, xfy, yfx), and name so that the
+// solutions don't depend on the order in which the operators were defined.
 func CurrentOp(vm *VM, priority, specifier, op Term, k Cont, env *Env) *Promise {
 	switch p := env.Resolve(priority).(type) {
 	case Variable:
@@ -663,16 +793,12 @@ func CurrentOp(vm *VM, priority, specifier, op Term, k Cont, env *Env) *Promise
 	}
 
 	pattern := tuple(priority, specifier, op)
-	ks := make([]func(context.Context) *Promise, 0, vm.getOperators().Len()*int(_operatorClassLen))
-	for ops := vm.getOperators().Oldest(); ops != nil; ops = ops.Next() {
-		for _, op := range ops.Value {
-			op := op
-			if op == (operator{}) {
-				continue
-			}
-			ks = append(ks, func(context.Context) *Promise {
-				return Unify(vm, pattern, tuple(op.priority, op.specifier.term(), op.name), k, env)
-			})
+	ops := vm.getOperators().canonical()
+	ks := make([]func(context.Context) *Promise, len(ops))
+	for i := range ops {
+		op := ops[i]
+		ks[i] = func(context.Context) *Promise {
+			return Unify(vm, pattern, tuple(op.priority, op.specifier.term(), op.name), k, env)
 		}
 	}
 	return Delay(ks...)
@@ -680,37 +806,46 @@ func CurrentOp(vm *VM, priority, specifier, op Term, k Cont, env *Env) *Promise
 
 // Assertz appends t to the database.
 func Assertz(vm *VM, t Term, k Cont, env *Env) *Promise {
-	if err := assertMerge(vm, t, func(existing, new []clause) []clause {
-		return append(existing, new...)
-	}, env); err != nil {
+	if _, _, err := assertMerge(vm, t, false, env); err != nil {
 		return Error(err)
 	}
+	vm.record(atomAssertz, []Term{t}, env)
 	return k(env)
 }
 
 // Asserta prepends t to the database.
 func Asserta(vm *VM, t Term, k Cont, env *Env) *Promise {
-	if err := assertMerge(vm, t, func(existing, new []clause) []clause {
-		return append(new, existing...)
-	}, env); err != nil {
+	if _, _, err := assertMerge(vm, t, true, env); err != nil {
 		return Error(err)
 	}
+	vm.record(atomAsserta, []Term{t}, env)
 	return k(env)
 }
 
-func assertMerge(vm *VM, t Term, merge func([]clause, []clause) []clause, env *Env) error {
+// assertMerge adds the clauses of t to the database before the existing ones if front or after them otherwise, and
+// returns the procedure and the added clauses.
+func assertMerge(vm *VM, t Term, front bool, env *Env) (procedureIndicator, clauses, error) {
+	return assertCompiled(vm, t, front, compile, env)
+}
+
+// assertCompiled is assertMerge with the clauses of t compiled by compile.
+func assertCompiled(vm *VM, t Term, front bool, compile func(Term, *Env) (clauses, error), env *Env) (procedureIndicator, clauses, error) {
 	pi, arg, err := piArg(t, env)
 	if err != nil {
-		return err
+		return pi, nil, err
 	}
 
 	if pi == (procedureIndicator{name: atomIf, arity: 2}) {
 		pi, _, err = piArg(arg(0), env)
 		if err != nil {
-			return err
+			return pi, nil, err
 		}
 	}
 
+	if err := vm.checkFrozen(permissionTypeStaticProcedure, pi.Term(), env); err != nil {
+		return pi, nil, err
+	}
+
 	if vm.procedures == nil {
 		vm.procedures = orderedmap.New[procedureIndicator, procedure]()
 	}
@@ -722,16 +857,26 @@ func assertMerge(vm *VM, t Term, merge func([]clause, []clause) []clause, env *E
 
 	added, err := compile(t, env)
 	if err != nil {
-		return err
+		return pi, nil, err
+	}
+	if err := vm.verify(added); err != nil {
+		return pi, nil, err
 	}
 
 	u, ok := p.(*userDefined)
 	if !ok || !u.dynamic {
-		return permissionError(operationModify, permissionTypeStaticProcedure, pi.Term(), env)
+		return pi, nil, permissionError(operationModify, permissionTypeStaticProcedure, pi.Term(), env)
 	}
 
-	u.clauses = merge(u.clauses, added)
-	return nil
+	if err := vm.store(pi, u, clauseChange{added: added, front: front}); err != nil {
+		return pi, nil, err
+	}
+	if front {
+		u.setClauses(append(slices.Clone(added), u.clauses...))
+	} else {
+		u.setClauses(append(u.clauses, added...))
+	}
+	return pi, added, nil
 }
 
 // BagOf collects all the solutions of goal as instances, which unify with template. instances may contain duplications.
@@ -911,6 +1056,7 @@ func Compare(vm *VM, order, term1, term2 Term, k Cont, env *Env) *Promise {
 
 // Between succeeds when lower, upper, and value are all integers, and lower <= value <= upper.
 // If value is a variable, it is unified with successive integers from lower to upper.
+// upper can also be inf or infinite so that value is unified with every integer from lower on.
 func Between(vm *VM, lower, upper, value Term, k Cont, env *Env) *Promise {
 	var low, high Integer
 
@@ -926,6 +1072,11 @@ func Between(vm *VM, lower, upper, value Term, k Cont, env *Env) *Promise {
 	switch upper := env.Resolve(upper).(type) {
 	case Integer:
 		high = upper
+	case Atom:
+		if upper != atomInf && upper != atomInfinite {
+			return Error(typeError(validTypeInteger, upper, env))
+		}
+		high = maxInt
 	case Variable:
 		return Error(InstantiationError(env))
 	default:
@@ -979,6 +1130,31 @@ func Sort(vm *VM, list, sorted Term, k Cont, env *Env) *Promise {
 	return Unify(vm, sorted, env.set(elems...), k, env)
 }
 
+// MSort succeeds if sorted unifies with the elements of list in the standard order of terms. Unlike Sort, it keeps
+// the duplicates.
+func MSort(vm *VM, list, sorted Term, k Cont, env *Env) *Promise {
+	var elems []Term
+	iter := ListIterator{List: list, Env: env}
+	for iter.Next() {
+		elems = append(elems, env.Resolve(iter.Current()))
+	}
+	if err := iter.Err(); err != nil {
+		return Error(err)
+	}
+
+	iter = ListIterator{List: sorted, Env: env, AllowPartial: true}
+	for iter.Next() {
+	}
+	if err := iter.Err(); err != nil {
+		return Error(err)
+	}
+
+	sort.SliceStable(elems, func(i, j int) bool {
+		return elems[i].Compare(elems[j], env) == -1
+	})
+	return Unify(vm, sorted, List(elems...), k, env)
+}
+
 // KeySort succeeds if sorted is a sorted list of pairs based on their keys.
 func KeySort(vm *VM, pairs, sorted Term, k Cont, env *Env) *Promise {
 	var elems []Term
@@ -1092,6 +1268,78 @@ func CurrentPredicate(vm *VM, pi Term, k Cont, env *Env) *Promise {
 	return Delay(ks...)
 }
 
+// PredicateProperty succeeds iff property is a property of the procedure of head: defined, built_in for a builtin or
+// a procedure marked by MarkBuiltIn, dynamic or static, multifile, discontiguous, tabled, or number_of_clauses(N) for
+// the other user-defined procedures. It enumerates the procedures with their most general heads if head is a variable.
+func PredicateProperty(vm *VM, head, property Term, k Cont, env *Env) *Promise {
+	var pis []procedureIndicator
+	switch h := env.Resolve(head).(type) {
+	case Variable:
+		if vm.procedures != nil {
+			for e := vm.procedures.Oldest(); e != nil; e = e.Next() {
+				pis = append(pis, e.Key)
+			}
+		}
+	case Atom, Compound:
+		pi, _, err := piArg(h, env)
+		if err != nil {
+			return Error(err)
+		}
+		pis = append(pis, pi)
+	default:
+		return Error(typeError(validTypeCallable, h, env))
+	}
+
+	var ks []func(context.Context) *Promise
+	for _, pi := range pis {
+		p, ok := vm.getProcedure(pi)
+		if !ok {
+			continue
+		}
+		args := make([]Term, pi.arity)
+		for i := range args {
+			args[i] = NewVariable()
+		}
+		h := pi.name.Apply(args...)
+		for _, prop := range procedureProperties(p) {
+			ks = append(ks, func(context.Context) *Promise {
+				return Unify(vm, tuple(head, property), tuple(h, prop), k, env)
+			})
+		}
+	}
+	return Delay(ks...)
+}
+
+func procedureProperties(p procedure) []Term {
+	u, ok := p.(*userDefined)
+	if !ok {
+		return []Term{atomDefined, atomBuiltIn, atomStatic}
+	}
+
+	props := []Term{atomDefined}
+	if u.builtIn {
+		props = append(props, atomBuiltIn)
+	}
+	if u.dynamic {
+		props = append(props, atomDynamic)
+	} else {
+		props = append(props, atomStatic)
+	}
+	if u.multifile {
+		props = append(props, atomMultifile)
+	}
+	if u.discontiguous {
+		props = append(props, atomDiscontiguous)
+	}
+	if u.tabled {
+		props = append(props, atomTabled)
+	}
+	if u.builtIn {
+		return props
+	}
+	return append(props, atomNumberOfClauses.Apply(Integer(len(u.clauses)-u.retired)))
+}
+
 // Retract removes the first clause that matches with t.
 func Retract(vm *VM, t Term, k Cont, env *Env) *Promise {
 	t = rulify(t, env)
@@ -1102,6 +1350,10 @@ func Retract(vm *VM, t Term, k Cont, env *Env) *Promise {
 		return Error(err)
 	}
 
+	if err := vm.checkFrozen(permissionTypeStaticProcedure, pi.Term(), env); err != nil {
+		return Error(err)
+	}
+
 	p, ok := vm.getProcedure(pi)
 	if !ok {
 		return Bool(false)
@@ -1112,16 +1364,20 @@ func Retract(vm *VM, t Term, k Cont, env *Env) *Promise {
 		return Error(permissionError(operationModify, permissionTypeStaticProcedure, pi.Term(), env))
 	}
 
-	deleted := 0
+	gen := vm.generation
 	ks := make([]func(context.Context) *Promise, len(u.clauses))
 	for i, c := range u.clauses {
-		i := i
 		raw := rulify(c.raw, env)
 		ks[i] = func(_ context.Context) *Promise {
+			if c.erased != 0 || !c.live(gen) {
+				return Bool(false)
+			}
 			return Unify(vm, t, raw, func(env *Env) *Promise {
-				j := i - deleted
-				u.clauses, u.clauses[len(u.clauses)-1] = append(u.clauses[:j], u.clauses[j+1:]...), clause{}
-				deleted++
+				if err := vm.store(pi, u, clauseChange{removed: clauses{c}}); err != nil {
+					return Error(err)
+				}
+				vm.retire(u, c)
+				vm.record(atomRetract, []Term{t}, env)
 				return k(env)
 			}, env)
 		}
@@ -1129,45 +1385,78 @@ func Retract(vm *VM, t Term, k Cont, env *Env) *Promise {
 	return Delay(ks...)
 }
 
-// Abolish removes the procedure indicated by pi from the database.
-func Abolish(vm *VM, pi Term, k Cont, env *Env) *Promise {
-	switch pi := env.Resolve(pi).(type) {
-	case Variable:
-		return Error(InstantiationError(env))
-	case Compound:
-		if pi.Functor() != atomSlash || pi.Arity() != 2 {
-			return Error(typeError(validTypePredicateIndicator, pi, env))
-		}
+// RetractAll removes all the clauses whose heads unify with head. It succeeds even if there's no such clause.
+// If the procedure of head doesn't exist, it's created as a dynamic procedure.
+func RetractAll(vm *VM, head Term, k Cont, env *Env) *Promise {
+	pi, _, err := piArg(head, env)
+	if err != nil {
+		return Error(err)
+	}
 
-		name, arity := pi.Arg(0), pi.Arg(1)
+	if err := vm.checkFrozen(permissionTypeStaticProcedure, pi.Term(), env); err != nil {
+		return Error(err)
+	}
 
-		switch name := env.Resolve(name).(type) {
-		case Variable:
-			return Error(InstantiationError(env))
-		case Atom:
-			switch arity := env.Resolve(arity).(type) {
-			case Variable:
-				return Error(InstantiationError(env))
-			case Integer:
-				if arity < 0 {
-					return Error(domainError(validDomainNotLessThanZero, arity, env))
-				}
-				key := procedureIndicator{name: name, arity: arity}
-				p, _ := vm.getProcedure(key)
-				if u, ok := p.(*userDefined); !ok || !u.dynamic {
-					return Error(permissionError(operationModify, permissionTypeStaticProcedure, key.Term(), env))
-				}
-				vm.procedures.Delete(key)
-				return k(env)
-			default:
-				return Error(typeError(validTypeInteger, arity, env))
-			}
-		default:
-			return Error(typeError(validTypeAtom, name, env))
+	p, ok := vm.getProcedure(pi)
+	if !ok {
+		vm.setProcedure(pi, &userDefined{public: true, dynamic: true})
+		vm.record(atomRetractAll, []Term{head}, env)
+		return k(env)
+	}
+
+	u, ok := p.(*userDefined)
+	if !ok || !u.dynamic {
+		return Error(permissionError(operationModify, permissionTypeStaticProcedure, pi.Term(), env))
+	}
+
+	var matched clauses
+	for _, c := range u.clauses {
+		if c.erased != 0 {
+			continue
 		}
-	default:
-		return Error(typeError(validTypePredicateIndicator, pi, env))
+		if _, ok := env.Unify(head, rulify(c.raw, env).(Compound).Arg(0)); ok {
+			matched = append(matched, c)
+		}
+	}
+	if len(matched) == 0 {
+		return k(env)
+	}
+
+	if err := vm.store(pi, u, clauseChange{removed: matched}); err != nil {
+		return Error(err)
 	}
+	for _, c := range matched {
+		vm.retire(u, c)
+	}
+	if !slices.ContainsFunc(u.clauses, func(c *clause) bool { return c.erased == 0 }) {
+		vm.releaseArenas(pi)
+	}
+	vm.record(atomRetractAll, []Term{head}, env)
+	return k(env)
+}
+
+// Abolish removes the procedure indicated by pi from the database.
+func Abolish(vm *VM, pi Term, k Cont, env *Env) *Promise {
+	key, err := toProcedureIndicator(pi, env)
+	if err != nil {
+		return Error(err)
+	}
+	if err := vm.checkFrozen(permissionTypeStaticProcedure, key.Term(), env); err != nil {
+		return Error(err)
+	}
+	p, _ := vm.getProcedure(key)
+	u, ok := p.(*userDefined)
+	if !ok || !u.dynamic {
+		return Error(permissionError(operationModify, permissionTypeStaticProcedure, key.Term(), env))
+	}
+	if err := vm.store(key, &userDefined{}, clauseChange{reset: true}); err != nil {
+		return Error(err)
+	}
+	vm.collect(u)
+	vm.releaseArenas(key)
+	vm.procedures.Delete(key)
+	vm.record(atomAbolish, []Term{pi}, env)
+	return k(env)
 }
 
 // CurrentInput unifies stream with the current input stream.
@@ -1382,6 +1671,8 @@ func handleStreamOption(vm *VM, s *Stream, option Term, env *Env) error {
 			return handleStreamOptionReposition(vm, s, o, env)
 		case atomEOFAction:
 			return handleStreamOptionEOFAction(vm, s, o, env)
+		case atomNewline:
+			return handleStreamOptionNewline(vm, s, o, env)
 		}
 	}
 	return domainError(validDomainStreamOption, option, env)
@@ -1457,6 +1748,23 @@ func handleStreamOptionEOFAction(_ *VM, s *Stream, o Compound, env *Env) error {
 	return domainError(validDomainStreamOption, o, env)
 }
 
+func handleStreamOptionNewline(_ *VM, s *Stream, o Compound, env *Env) error {
+	switch n := env.Resolve(o.Arg(0)).(type) {
+	case Variable:
+		return InstantiationError(env)
+	case Atom:
+		switch n {
+		case atomPOSIX:
+			s.newline = newlinePOSIX
+			return nil
+		case atomDOS:
+			s.newline = newlineDOS
+			return nil
+		}
+	}
+	return domainError(validDomainStreamOption, o, env)
+}
+
 // Close closes a stream specified by streamOrAlias.
 func Close(vm *VM, streamOrAlias, options Term, k Cont, env *Env) *Promise {
 	s, err := stream(vm, streamOrAlias, env)
@@ -1554,11 +1862,41 @@ func WriteTerm(vm *VM, streamOrAlias, t, options Term, k Cont, env *Env) *Promis
 		return Error(err)
 	}
 
-	if err := env.Resolve(t).WriteTerm(w, &opts, env); err != nil {
-		return Error(err)
+	if !opts.portray && !opts.fullstop && !opts.nl {
+		if err := env.Resolve(t).WriteTerm(w, &opts, env); err != nil {
+			return Error(err)
+		}
+		return k(env)
 	}
 
-	return k(env)
+	return Delay(func(ctx context.Context) *Promise {
+		t := env.Resolve(t)
+		if opts.portray {
+			var err error
+			t, err = vm.portray(ctx, t, opts.maxDepth, env)
+			if err != nil {
+				return Error(err)
+			}
+		}
+
+		var sb strings.Builder
+		if err := t.WriteTerm(&sb, &opts, env); err != nil {
+			return Error(err)
+		}
+		if opts.fullstop {
+			if r, _ := utf8.DecodeLastRuneInString(sb.String()); isGraphicChar(r) || r == '\\' {
+				_, _ = sb.WriteString(" ")
+			}
+			_, _ = sb.WriteString(".")
+		}
+		if opts.nl {
+			_, _ = sb.WriteString("\n")
+		}
+		if _, err := io.WriteString(w, sb.String()); err != nil {
+			return Error(err)
+		}
+		return k(env)
+	})
 }
 
 func writeTermOption(opts *WriteOptions, option Term, env *Env) error {
@@ -1589,8 +1927,23 @@ func writeTermOption(opts *WriteOptions, option Term, env *Env) error {
 			return err
 		case atomMaxDepth:
 			n, err := writeTermOptionInteger(o, env)
+			if err == nil && n < 0 {
+				err = domainError(validDomainWriteOption, o, env)
+			}
 			opts.maxDepth = n
 			return err
+		case atomPortray:
+			b, err := writeTermOptionBool(o, env)
+			opts.portray = b
+			return err
+		case atomFullStop:
+			b, err := writeTermOptionBool(o, env)
+			opts.fullstop = b
+			return err
+		case atomNl:
+			b, err := writeTermOptionBool(o, env)
+			opts.nl = b
+			return err
 		}
 	}
 	return domainError(validDomainWriteOption, option, env)
@@ -1777,6 +2130,41 @@ type readTermOptions struct {
 	singletons    Term
 	variables     Term
 	variableNames Term
+	syntaxErrors  syntaxErrors
+}
+
+// syntaxErrors is what read_term/3 does on a syntax error.
+type syntaxErrors int
+
+const (
+	syntaxErrorsError syntaxErrors = iota // raises the syntax error.
+	syntaxErrorsFail                      // prints the syntax error and fails.
+	syntaxErrorsQuiet                     // fails silently.
+	syntaxErrorsDec10                     // prints the syntax error and reads the next term.
+)
+
+func (s syntaxErrors) String() string {
+	return [...]string{
+		syntaxErrorsError: "error",
+		syntaxErrorsFail:  "fail",
+		syntaxErrorsQuiet: "quiet",
+		syntaxErrorsDec10: "dec10",
+	}[s]
+}
+
+func parseSyntaxErrors(a Atom) (syntaxErrors, bool) {
+	switch a {
+	case atomError:
+		return syntaxErrorsError, true
+	case atomFail:
+		return syntaxErrorsFail, true
+	case atomQuiet:
+		return syntaxErrorsQuiet, true
+	case atomDec10:
+		return syntaxErrorsDec10, true
+	default:
+		return 0, false
+	}
 }
 
 // ReadTerm reads from the stream represented by streamOrAlias and unifies with stream.
@@ -1790,6 +2178,7 @@ func ReadTerm(vm *VM, streamOrAlias, out, options Term, k Cont, env *Env) *Promi
 		singletons:    NewVariable(),
 		variables:     NewVariable(),
 		variableNames: NewVariable(),
+		syntaxErrors:  vm.syntaxErrors,
 	}
 	iter := ListIterator{List: options, Env: env}
 	for iter.Next() {
@@ -1801,25 +2190,52 @@ func ReadTerm(vm *VM, streamOrAlias, out, options Term, k Cont, env *Env) *Promi
 		return Error(err)
 	}
 
-	p := NewParser(vm, s)
-	defer func() {
+	var (
+		p *Parser
+		t Term
+	)
+	for {
+		p = NewParser(vm, s)
+		p.doubleQuotes = vm.flags(env).doubleQuotes
+		p.lexer.input.next = position{line: int(s.counts.lines) + 1, column: int(s.counts.linePos) + 1}
+		t, err = p.Term()
+		switch err {
+		case nil, io.EOF, errWrongIOMode, errWrongStreamType, errPastEndOfStream:
+			break
+		default:
+			if opts.syntaxErrors == syntaxErrorsDec10 {
+				p.SkipToEnd()
+			}
+		}
 		_ = s.UnreadRune()
-	}()
 
-	t, err := p.Term()
-	switch err {
-	case nil:
+		switch err {
+		case nil:
+			break
+		case io.EOF:
+			return Unify(vm, out, atomEndOfFile, k, env)
+		case errWrongIOMode:
+			return Error(permissionError(operationInput, permissionTypeStream, streamOrAlias, env))
+		case errWrongStreamType:
+			return Error(permissionError(operationInput, permissionTypeBinaryStream, streamOrAlias, env))
+		case errPastEndOfStream:
+			return Error(permissionError(operationInput, permissionTypePastEndOfStream, streamOrAlias, env))
+		default:
+			e := syntaxError(err, env)
+			switch opts.syntaxErrors {
+			case syntaxErrorsFail:
+				vm.printMessage(context.Background(), atomError, e.Term(), env)
+				return Bool(false)
+			case syntaxErrorsQuiet:
+				return Bool(false)
+			case syntaxErrorsDec10:
+				vm.printMessage(context.Background(), atomError, e.Term(), env)
+				continue
+			default:
+				return Error(e)
+			}
+		}
 		break
-	case io.EOF:
-		return Unify(vm, out, atomEndOfFile, k, env)
-	case errWrongIOMode:
-		return Error(permissionError(operationInput, permissionTypeStream, streamOrAlias, env))
-	case errWrongStreamType:
-		return Error(permissionError(operationInput, permissionTypeBinaryStream, streamOrAlias, env))
-	case errPastEndOfStream:
-		return Error(permissionError(operationInput, permissionTypePastEndOfStream, streamOrAlias, env))
-	default:
-		return Error(syntaxError(err, env))
 	}
 
 	var singletons, variables, variableNames []Term
@@ -1861,6 +2277,14 @@ func readTermOption(opts *readTermOptions, option Term, env *Env) error {
 			opts.variables = v
 		case atomVariableNames:
 			opts.variableNames = v
+		case atomSyntaxErrors:
+			a, ok := v.(Atom)
+			if !ok {
+				return domainError(validDomainReadOption, option, env)
+			}
+			if opts.syntaxErrors, ok = parseSyntaxErrors(a); !ok {
+				return domainError(validDomainReadOption, option, env)
+			}
 		default:
 			return domainError(validDomainReadOption, option, env)
 		}
@@ -2042,11 +2466,18 @@ func IsHalt(err error) (int64, bool) {
 }
 
 // Halt signals a VM halt with the integer exit code n.
-func Halt(_ *VM, n Term, _ Cont, env *Env) *Promise {
+// If vm.OnHalt is set, the decision is delegated to the host instead.
+func Halt(vm *VM, n Term, k Cont, env *Env) *Promise {
 	switch code := env.Resolve(n).(type) {
 	case Variable:
 		return Error(InstantiationError(env))
 	case Integer:
+		if vm != nil && vm.OnHalt != nil {
+			if err := vm.OnHalt(int(code)); err != nil {
+				return Error(err)
+			}
+			return k(env)
+		}
 		return Error(HaltError{Code: int64(code)})
 	default:
 		return Error(typeError(validTypeInteger, n, env))
@@ -2055,6 +2486,11 @@ func Halt(_ *VM, n Term, _ Cont, env *Env) *Promise {
 
 // Clause unifies head and body with H and B respectively where H :- B is in the database.
 func Clause(vm *VM, head, body Term, k Cont, env *Env) *Promise {
+	return clauseOf(vm, head, body, nil, k, env)
+}
+
+// clauseOf enumerates the clauses which unify with head :- body and unifies ref with their references unless ref is nil.
+func clauseOf(vm *VM, head, body, ref Term, k Cont, env *Env) *Promise {
 	pi, _, err := piArg(head, env)
 	if err != nil {
 		return Error(err)
@@ -2077,16 +2513,22 @@ func Clause(vm *VM, head, body Term, k Cont, env *Env) *Promise {
 		return Error(permissionError(operationAccess, permissionTypePrivateProcedure, pi.Term(), env))
 	}
 
-	ks := make([]func(context.Context) *Promise, len(u.clauses))
-	for i, c := range u.clauses {
+	ks := make([]func(context.Context) *Promise, 0, len(u.clauses))
+	for _, c := range u.clauses {
+		if c.erased != 0 {
+			continue
+		}
 		cp, err := renamedCopy(c.raw, nil, env)
 		if err != nil {
 			return Error(err)
 		}
 		r := rulify(cp, env)
-		ks[i] = func(context.Context) *Promise {
-			return Unify(vm, atomIf.Apply(head, body), r, k, env)
-		}
+		ks = append(ks, func(context.Context) *Promise {
+			if ref == nil {
+				return Unify(vm, atomIf.Apply(head, body), r, k, env)
+			}
+			return Unify(vm, tuple(atomIf.Apply(head, body), ref), tuple(r, vm.refOf(pi, u, clauses{c})), k, env)
+		})
 	}
 	return Delay(ks...)
 }
@@ -2343,6 +2785,15 @@ func AtomCodes(vm *VM, atom, codes Term, k Cont, env *Env) *Promise {
 // NumberChars breaks up an atom representation of a number num into a list of characters and unifies it with chars, or
 // constructs a number from a list of characters chars and unifies it with num.
 func NumberChars(vm *VM, num, chars Term, k Cont, env *Env) *Promise {
+	if vm.numberSyntax() == numberSyntaxStrict {
+		switch n := env.Resolve(num).(type) {
+		case Variable, Number:
+			break
+		default:
+			return Error(typeError(validTypeNumber, n, env))
+		}
+	}
+
 	var sb strings.Builder
 	iter := ListIterator{List: chars, Env: env, AllowPartial: true}
 	for iter.Next() {
@@ -2366,12 +2817,7 @@ func NumberChars(vm *VM, num, chars Term, k Cont, env *Env) *Promise {
 		return numberCharsWrite(vm, num, chars, k, env)
 	}
 
-	p := Parser{
-		lexer: Lexer{
-			input: newRuneRingBuffer(strings.NewReader(sb.String())),
-		},
-	}
-	t, err := p.number()
+	t, err := parseNumber(sb.String(), vm.numberSyntax())
 	if err != nil {
 		return Error(syntaxError(err, env))
 	}
@@ -2426,6 +2872,15 @@ func numberCharsWrite(vm *VM, num, chars Term, k Cont, env *Env) *Promise {
 // NumberCodes breaks up an atom representation of a number num into a list of runes and unifies it with codes, or
 // constructs a number from a list of runes codes and unifies it with num.
 func NumberCodes(vm *VM, num, codes Term, k Cont, env *Env) *Promise {
+	if vm.numberSyntax() == numberSyntaxStrict {
+		switch n := env.Resolve(num).(type) {
+		case Variable, Number:
+			break
+		default:
+			return Error(typeError(validTypeNumber, n, env))
+		}
+	}
+
 	var sb strings.Builder
 	iter := ListIterator{List: codes, Env: env, AllowPartial: true}
 	for iter.Next() {
@@ -2448,12 +2903,7 @@ func NumberCodes(vm *VM, num, codes Term, k Cont, env *Env) *Promise {
 		return numberCodesWrite(vm, num, codes, k, env)
 	}
 
-	p := Parser{
-		lexer: Lexer{
-			input: newRuneRingBuffer(strings.NewReader(sb.String())),
-		},
-	}
-	t, err := p.number()
+	t, err := parseNumber(sb.String(), vm.numberSyntax())
 	if err != nil {
 		return Error(syntaxError(err, env))
 	}
@@ -2546,9 +2996,9 @@ func isStreamProperty(property Term, env *Env) bool {
 		}
 		arg := p.Arg(0)
 		switch p.Functor() {
-		case atomFileName, atomMode, atomAlias, atomEndOfStream, atomEOFAction, atomReposition:
+		case atomFileName, atomMode, atomAlias, atomEndOfStream, atomEOFAction, atomReposition, atomNewline:
 			return isAtom(arg, env)
-		case atomPosition:
+		case atomPosition, atomLineCount, atomLinePosition, atomCharacterCount:
 			return isInteger(arg, env)
 		}
 		return false
@@ -2599,6 +3049,44 @@ func SetStreamPosition(vm *VM, streamOrAlias, position Term, k Cont, env *Env) *
 	}
 }
 
+// LineCount succeeds iff count unifies with the number of the current line of the stream represented by
+// streamOrAlias, starting from 1.
+func LineCount(vm *VM, streamOrAlias, count Term, k Cont, env *Env) *Promise {
+	return streamCount(vm, streamOrAlias, count, func(c streamCounts) int64 {
+		return c.lines + 1
+	}, k, env)
+}
+
+// LinePosition succeeds iff position unifies with the number of the characters read or written since the last newline
+// in the stream represented by streamOrAlias.
+func LinePosition(vm *VM, streamOrAlias, position Term, k Cont, env *Env) *Promise {
+	return streamCount(vm, streamOrAlias, position, func(c streamCounts) int64 {
+		return c.linePos
+	}, k, env)
+}
+
+// CharacterCount succeeds iff count unifies with the number of the characters, or the bytes for a binary stream, read
+// or written through the stream represented by streamOrAlias.
+func CharacterCount(vm *VM, streamOrAlias, count Term, k Cont, env *Env) *Promise {
+	return streamCount(vm, streamOrAlias, count, func(c streamCounts) int64 {
+		return c.chars
+	}, k, env)
+}
+
+func streamCount(vm *VM, streamOrAlias, count Term, get func(streamCounts) int64, k Cont, env *Env) *Promise {
+	s, err := stream(vm, streamOrAlias, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	switch c := env.Resolve(count).(type) {
+	case Variable, Integer:
+		return Unify(vm, count, Integer(get(s.counts)), k, env)
+	default:
+		return Error(typeError(validTypeInteger, c, env))
+	}
+}
+
 // CharConversion registers a character conversion from inChar to outChar, or remove the conversion if inChar = outChar.
 func CharConversion(vm *VM, inChar, outChar Term, k Cont, env *Env) *Promise {
 	switch in := env.Resolve(inChar).(type) {
@@ -2687,12 +3175,17 @@ func CurrentCharConversion(vm *VM, inChar, outChar Term, k Cont, env *Env) *Prom
 }
 
 // SetPrologFlag sets flag to value.
+// The flags unknown, double_quotes, and occurs_check are set only for the rest of the query unless it's executed as a
+// directive. Use VM.SetDefaultFlag to change them for the VM.
 func SetPrologFlag(vm *VM, flag, value Term, k Cont, env *Env) *Promise {
 	switch f := env.Resolve(flag).(type) {
 	case Variable:
 		return Error(InstantiationError(env))
 	case Atom:
-		var modify func(vm *VM, value Atom) error
+		var (
+			modify      func(vm *VM, value Atom) error
+			modifyQuery func(flags *queryFlags, value Atom) error
+		)
 		switch f {
 		case atomBounded, atomMaxInteger, atomMinInteger, atomIntegerRoundingFunction, atomMaxArity:
 			return Error(permissionError(operationModify, permissionTypeFlag, f, env))
@@ -2701,9 +3194,17 @@ func SetPrologFlag(vm *VM, flag, value Term, k Cont, env *Env) *Promise {
 		case atomDebug:
 			modify = modifyDebug
 		case atomUnknown:
-			modify = modifyUnknown
+			modifyQuery = modifyUnknown
 		case atomDoubleQuotes:
-			modify = modifyDoubleQuotes
+			modifyQuery = modifyDoubleQuotes
+		case atomOccursCheck:
+			modifyQuery = modifyOccursCheck
+		case atomPreferRationals:
+			modify = modifyPreferRationals
+		case atomNumberSyntax:
+			modify = modifyNumberSyntax
+		case atomSyntaxErrors:
+			modify = modifySyntaxErrors
 		default:
 			return Error(domainError(validDomainPrologFlag, f, env))
 		}
@@ -2712,9 +3213,17 @@ func SetPrologFlag(vm *VM, flag, value Term, k Cont, env *Env) *Promise {
 		case Variable:
 			return Error(InstantiationError(env))
 		case Atom:
+			if modifyQuery != nil {
+				flags := vm.flags(env)
+				if err := modifyQuery(&flags, v); err != nil {
+					return Error(err)
+				}
+				return k(env.withFlags(&flags))
+			}
 			if err := modify(vm, v); err != nil {
 				return Error(err)
 			}
+			vm.record(atomSetPrologFlag, []Term{f, v}, env)
 			return k(env)
 		default:
 			return Error(domainError(validDomainFlagValue, atomPlus.Apply(flag, value), env))
@@ -2748,34 +3257,91 @@ func modifyDebug(vm *VM, value Atom) error {
 	return nil
 }
 
-func modifyUnknown(vm *VM, value Atom) error {
+func modifyUnknown(flags *queryFlags, value Atom) error {
 	switch value {
 	case atomError:
-		vm.unknown = unknownError
+		flags.unknown = unknownError
 	case atomWarning:
-		vm.unknown = unknownWarning
+		flags.unknown = unknownWarning
 	case atomFail:
-		vm.unknown = unknownFail
+		flags.unknown = unknownFail
 	default:
 		return domainError(validDomainFlagValue, atomPlus.Apply(atomUnknown, value), nil)
 	}
 	return nil
 }
 
-func modifyDoubleQuotes(vm *VM, value Atom) error {
+func modifyDoubleQuotes(flags *queryFlags, value Atom) error {
 	switch value {
 	case atomCodes:
-		vm.doubleQuotes = doubleQuotesCodes
+		flags.doubleQuotes = doubleQuotesCodes
 	case atomChars:
-		vm.doubleQuotes = doubleQuotesChars
+		flags.doubleQuotes = doubleQuotesChars
 	case atomAtom:
-		vm.doubleQuotes = doubleQuotesAtom
+		flags.doubleQuotes = doubleQuotesAtom
+	case atomString:
+		flags.doubleQuotes = doubleQuotesString
 	default:
 		return domainError(validDomainFlagValue, atomPlus.Apply(atomDoubleQuotes, value), nil)
 	}
 	return nil
 }
 
+func modifyOccursCheck(flags *queryFlags, value Atom) error {
+	switch value {
+	case atomTrue:
+		flags.occursCheck = true
+	case atomFalse:
+		flags.occursCheck = false
+	default:
+		return domainError(validDomainFlagValue, atomPlus.Apply(atomOccursCheck, value), nil)
+	}
+	return nil
+}
+
+func modifyPreferRationals(vm *VM, value Atom) error {
+	switch value {
+	case atomTrue:
+		vm.preferRationals = true
+	case atomFalse:
+		vm.preferRationals = false
+	default:
+		return domainError(validDomainFlagValue, atomPlus.Apply(atomPreferRationals, value), nil)
+	}
+	return nil
+}
+
+func modifyNumberSyntax(vm *VM, value Atom) error {
+	switch value {
+	case atomStandard:
+		vm.numberSyntaxMode = numberSyntaxStandard
+	case atomStrict:
+		vm.numberSyntaxMode = numberSyntaxStrict
+	case atomExtended:
+		vm.numberSyntaxMode = numberSyntaxExtended
+	default:
+		return domainError(validDomainFlagValue, atomPlus.Apply(atomNumberSyntax, value), nil)
+	}
+	return nil
+}
+
+func modifySyntaxErrors(vm *VM, value Atom) error {
+	s, ok := parseSyntaxErrors(value)
+	if !ok {
+		return domainError(validDomainFlagValue, atomPlus.Apply(atomSyntaxErrors, value), nil)
+	}
+	vm.syntaxErrors = s
+	return nil
+}
+
+// numberSyntax returns the value of the prolog flag number_syntax.
+func (vm *VM) numberSyntax() numberSyntax {
+	if vm == nil {
+		return numberSyntaxStandard
+	}
+	return vm.numberSyntaxMode
+}
+
 // CurrentPrologFlag succeeds iff flag is set to value.
 func CurrentPrologFlag(vm *VM, flag, value Term, k Cont, env *Env) *Promise {
 	switch f := env.Resolve(flag).(type) {
@@ -2783,7 +3349,7 @@ func CurrentPrologFlag(vm *VM, flag, value Term, k Cont, env *Env) *Promise {
 		break
 	case Atom:
 		switch f {
-		case atomBounded, atomMaxInteger, atomMinInteger, atomIntegerRoundingFunction, atomCharConversion, atomDebug, atomMaxArity, atomUnknown, atomDoubleQuotes:
+		case atomBounded, atomMaxInteger, atomMinInteger, atomIntegerRoundingFunction, atomCharConversion, atomDebug, atomMaxArity, atomUnknown, atomDoubleQuotes, atomPreferRationals, atomNumberSyntax, atomOccursCheck, atomSyntaxErrors:
 			break
 		default:
 			return Error(domainError(validDomainPrologFlag, f, env))
@@ -2792,6 +3358,7 @@ func CurrentPrologFlag(vm *VM, flag, value Term, k Cont, env *Env) *Promise {
 		return Error(typeError(validTypeAtom, f, env))
 	}
 
+	qf := vm.flags(env)
 	pattern := tuple(flag, value)
 	flags := []Term{
 		tuple(atomBounded, atomTrue),
@@ -2801,8 +3368,12 @@ func CurrentPrologFlag(vm *VM, flag, value Term, k Cont, env *Env) *Promise {
 		tuple(atomCharConversion, onOff(vm.charConvEnabled)),
 		tuple(atomDebug, onOff(vm.debug)),
 		tuple(atomMaxArity, atomUnbounded),
-		tuple(atomUnknown, NewAtom(vm.unknown.String())),
-		tuple(atomDoubleQuotes, NewAtom(vm.doubleQuotes.String())),
+		tuple(atomUnknown, NewAtom(qf.unknown.String())),
+		tuple(atomDoubleQuotes, NewAtom(qf.doubleQuotes.String())),
+		tuple(atomPreferRationals, trueFalse(vm.preferRationals)),
+		tuple(atomNumberSyntax, NewAtom(vm.numberSyntaxMode.String())),
+		tuple(atomOccursCheck, trueFalse(qf.occursCheck)),
+		tuple(atomSyntaxErrors, NewAtom(vm.syntaxErrors.String())),
 	}
 	ks := make([]func(context.Context) *Promise, len(flags))
 	for i := range flags {
@@ -2821,24 +3392,33 @@ func onOff(b bool) Atom {
 	return atomOff
 }
 
+func trueFalse(b bool) Atom {
+	if b {
+		return atomTrue
+	}
+	return atomFalse
+}
+
 // ExpandTerm transforms term1 according to term_expansion/2 and DCG rules then unifies with term2.
 func ExpandTerm(vm *VM, term1, term2 Term, k Cont, env *Env) *Promise {
-	t, err := expand(vm, term1, env)
-	if err != nil {
-		return Error(err)
-	}
+	return Delay(func(ctx context.Context) *Promise {
+		t, err := expand(ctx, vm, term1, env)
+		if err != nil {
+			return Error(err)
+		}
 
-	return Unify(vm, t, term2, k, env)
+		return Unify(vm, t, term2, k, env)
+	})
 }
 
-func expand(vm *VM, term Term, env *Env) (Term, error) {
+func expand(ctx context.Context, vm *VM, term Term, env *Env) (Term, error) {
 	if _, ok := vm.getProcedure(procedureIndicator{name: atomTermExpansion, arity: 2}); ok {
 		var ret Term
 		v := NewVariable()
 		ok, err := Call(vm, atomTermExpansion.Apply(term, v), func(env *Env) *Promise {
 			ret = env.simplify(v)
 			return Bool(true)
-		}, env).Force(context.Background())
+		}, env).Force(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -2854,6 +3434,86 @@ func expand(vm *VM, term Term, env *Env) (Term, error) {
 	return t, err
 }
 
+// ExpandGoal transforms goal1 according to goal_expansion/2 then unifies with goal2.
+func ExpandGoal(vm *VM, goal1, goal2 Term, k Cont, env *Env) *Promise {
+	return Delay(func(ctx context.Context) *Promise {
+		g, env, err := expandGoal(ctx, vm, goal1, env)
+		if err != nil {
+			return Error(err)
+		}
+
+		return Unify(vm, g, goal2, k, env)
+	})
+}
+
+// goalExpansionMaxDepth is the maximum number of successive expansions of a goal and the goals it's part of.
+const goalExpansionMaxDepth = 1 << 6
+
+// expandGoal applies goal_expansion/2 to goal as long as it succeeds and changes the goal, and then to the subgoals of
+// the control constructs in the result.
+// A goal isn't expanded again if it's a variant of a goal it results from so that goal_expansion(a, b) and
+// goal_expansion(b, a) stop at a, and neither is a goal after goalExpansionMaxDepth expansions.
+// It also returns env with the bindings goal_expansion/2 made so that the variables goal shares with the other terms,
+// e.g. the head of a clause, are kept.
+func expandGoal(ctx context.Context, vm *VM, goal Term, env *Env) (Term, *Env, error) {
+	if _, ok := vm.getProcedure(procedureIndicator{name: atomGoalExpansion, arity: 2}); !ok {
+		return goal, env, nil
+	}
+	return expandGoalFrom(ctx, vm, goal, nil, env)
+}
+
+// expandGoalFrom expands goal which results from the goals in seen.
+func expandGoalFrom(ctx context.Context, vm *VM, goal Term, seen []Term, env *Env) (Term, *Env, error) {
+	for {
+		if _, ok := env.Resolve(goal).(Variable); ok {
+			return goal, env, nil
+		}
+		if len(seen) >= goalExpansionMaxDepth || slices.ContainsFunc(seen, func(s Term) bool {
+			return variant(s, goal, env)
+		}) {
+			break
+		}
+		seen = append(seen, goal)
+
+		var next *Env
+		v := NewVariable()
+		ok, err := Call(vm, atomGoalExpansion.Apply(goal, v), func(env *Env) *Promise {
+			next = env
+			return Bool(true)
+		}, env).Force(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			break
+		}
+		goal, env = v, next
+	}
+
+	c, ok := env.Resolve(goal).(Compound)
+	if !ok {
+		return goal, env, nil
+	}
+	switch pi := (procedureIndicator{name: c.Functor(), arity: Integer(c.Arity())}); pi {
+	case procedureIndicator{name: atomComma, arity: 2},
+		procedureIndicator{name: atomSemiColon, arity: 2},
+		procedureIndicator{name: atomThen, arity: 2},
+		procedureIndicator{name: atomNegation, arity: 1},
+		procedureIndicator{name: atomCall, arity: 1}:
+		args := make([]Term, c.Arity())
+		for i := range args {
+			a, next, err := expandGoalFrom(ctx, vm, c.Arg(i), slices.Clip(seen), env)
+			if err != nil {
+				return nil, nil, err
+			}
+			args[i], env = a, next
+		}
+		return pi.name.Apply(args...), env, nil
+	default:
+		return goal, env, nil
+	}
+}
+
 // Nth0 succeeds if elem is the n-th element of list, counting from 0.
 func Nth0(vm *VM, n, list, elem Term, k Cont, env *Env) *Promise {
 	return nth(vm, 0, n, list, elem, k, env)
@@ -2947,6 +3607,54 @@ func Succ(vm *VM, x, s Term, k Cont, env *Env) *Promise {
 	}
 }
 
+// Plus succeeds if z = x + y where at least two of x, y, and z are integers.
+func Plus(vm *VM, x, y, z Term, k Cont, env *Env) *Promise {
+	var (
+		ns    [3]Integer
+		known [3]bool
+		n     int
+	)
+	for i, t := range []Term{x, y, z} {
+		switch t := env.Resolve(t).(type) {
+		case Variable:
+			continue
+		case Integer:
+			ns[i], known[i] = t, true
+			n++
+		default:
+			return Error(typeError(validTypeInteger, t, env))
+		}
+	}
+	if n < 2 {
+		return Error(InstantiationError(env))
+	}
+
+	var (
+		r   Integer
+		err error
+		t   Term
+	)
+	switch {
+	case known[0] && known[1]:
+		r, err = addI(ns[0], ns[1])
+		t = z
+	case known[0]:
+		r, err = subI(ns[2], ns[0])
+		t = y
+	default:
+		r, err = subI(ns[2], ns[1])
+		t = x
+	}
+	if err != nil {
+		var ev exceptionalValue
+		if errors.As(err, &ev) {
+			return Error(evaluationError(ev, env))
+		}
+		return Error(err)
+	}
+	return Unify(vm, t, r, k, env)
+}
+
 // Length succeeds iff list is a list of length.
 func Length(vm *VM, list, length Term, k Cont, env *Env) *Promise {
 	// https://github.com/mthom/scryer-prolog/issues/1325#issue-1160713156
@@ -41,7 +41,7 @@ func TestCall(t *testing.T) {
 		panic(errors.New("told you"))
 	})
 	vm.Register0(NewAtom("do_not_call_exception"), func(*VM, Cont, *Env) *Promise {
-		panic(Exception{NewAtom("error").Apply(NewAtom("panic_error").Apply(NewAtom("told you")))})
+		panic(Exception{term: NewAtom("error").Apply(NewAtom("panic_error").Apply(NewAtom("told you")))})
 	})
 	vm.Register0(NewAtom("do_not_call_misc_error"), func(*VM, Cont, *Env) *Promise {
 		panic(42)
@@ -73,11 +73,11 @@ f(g([a, [b, c|X], Y{x:5}])).
 
 		{title: `cover all`, goal: atomComma.Apply(atomCut, NewAtom("f").Apply(NewAtom("g").Apply(List(NewAtom("a"), PartialList(NewVariable(), NewAtom("b"), NewAtom("c")), makeDict(NewAtom("foo"), NewAtom("x"), Integer(5)))))), ok: true},
 		{title: `out of memory`, goal: NewAtom("foo").Apply(NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable()), err: resourceError(resourceMemory, nil), mem: 1},
-		{title: `panic`, goal: NewAtom("do_not_call"), err: Exception{NewAtom("error").Apply(NewAtom("panic_error").Apply(NewAtom("told you")))}},
-		{title: `panic (lazy)`, goal: NewAtom("lazy_do_not_call"), err: Exception{NewAtom("error").Apply(NewAtom("panic_error").Apply(NewAtom("told you")))}},
-		{title: `panic (wrapped)`, goal: NewAtom("do_not_call_wrapped"), err: Exception{NewAtom("error").Apply(NewAtom("panic_error").Apply(NewAtom("told you")))}},
-		{title: `panic (exception)`, goal: NewAtom("do_not_call_exception"), err: Exception{NewAtom("error").Apply(NewAtom("panic_error").Apply(NewAtom("told you")))}},
-		{title: `panic (misc)`, goal: NewAtom("do_not_call_misc_error"), err: Exception{NewAtom("error").Apply(NewAtom("panic_error").Apply(NewAtom("42")))}},
+		{title: `panic`, goal: NewAtom("do_not_call"), err: Exception{term: NewAtom("error").Apply(NewAtom("panic_error").Apply(NewAtom("told you")))}},
+		{title: `panic (lazy)`, goal: NewAtom("lazy_do_not_call"), err: Exception{term: NewAtom("error").Apply(NewAtom("panic_error").Apply(NewAtom("told you")))}},
+		{title: `panic (wrapped)`, goal: NewAtom("do_not_call_wrapped"), err: Exception{term: NewAtom("error").Apply(NewAtom("panic_error").Apply(NewAtom("told you")))}},
+		{title: `panic (exception)`, goal: NewAtom("do_not_call_exception"), err: Exception{term: NewAtom("error").Apply(NewAtom("panic_error").Apply(NewAtom("told you")))}},
+		{title: `panic (misc)`, goal: NewAtom("do_not_call_misc_error"), err: Exception{term: NewAtom("error").Apply(NewAtom("panic_error").Apply(NewAtom("42")))}},
 	}
 
 	for _, tt := range tests {
@@ -860,6 +860,95 @@ func TestUniv(t *testing.T) {
 	}
 }
 
+func TestPutAttr(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		x := NewVariable()
+		ok, err := PutAttr(nil, x, NewAtom("foo"), Integer(1), func(env *Env) *Promise {
+			return PutAttr(nil, x, NewAtom("foo"), Integer(2), func(env *Env) *Promise {
+				a, ok := env.getAttr(x, NewAtom("foo"))
+				assert.True(t, ok)
+				assert.Equal(t, Integer(2), a)
+				return Bool(true)
+			}, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("v is not a variable", func(t *testing.T) {
+		ok, err := PutAttr(nil, NewAtom("a"), NewAtom("foo"), Integer(1), Success, nil).Force(context.Background())
+		assert.Equal(t, UninstantiationError(NewAtom("a"), nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("module is a variable", func(t *testing.T) {
+		ok, err := PutAttr(nil, NewVariable(), NewVariable(), Integer(1), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("module is not an atom", func(t *testing.T) {
+		ok, err := PutAttr(nil, NewVariable(), Integer(0), Integer(1), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeAtom, Integer(0), nil), err)
+		assert.False(t, ok)
+	})
+}
+
+func TestGetAttr(t *testing.T) {
+	x := NewVariable()
+	env := NewEnv().putAttr(x, NewAtom("foo"), Integer(1))
+
+	t.Run("ok", func(t *testing.T) {
+		v := NewVariable()
+		ok, err := GetAttr(nil, x, NewAtom("foo"), v, func(env *Env) *Promise {
+			assert.Equal(t, Integer(1), env.Resolve(v))
+			return Bool(true)
+		}, env).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("no such attribute", func(t *testing.T) {
+		ok, err := GetAttr(nil, x, NewAtom("bar"), NewVariable(), Success, env).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("v is not a variable", func(t *testing.T) {
+		ok, err := GetAttr(nil, NewAtom("a"), NewAtom("foo"), NewVariable(), Success, env).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestDelAttr(t *testing.T) {
+	x := NewVariable()
+	env := NewEnv().putAttr(x, NewAtom("foo"), Integer(1)).putAttr(x, NewAtom("bar"), Integer(2))
+
+	ok, err := DelAttr(nil, x, NewAtom("foo"), func(env *Env) *Promise {
+		assert.Equal(t, list{pair(NewAtom("bar"), Integer(2))}, env.getAttrs(x))
+		return DelAttr(nil, x, NewAtom("bar"), func(env *Env) *Promise {
+			assert.Empty(t, env.getAttrs(x))
+			return Bool(true)
+		}, env)
+	}, env).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestAttVar(t *testing.T) {
+	x := NewVariable()
+	env := NewEnv().putAttr(x, NewAtom("foo"), Integer(1))
+
+	ok, err := AttVar(nil, x, Success, env).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = AttVar(nil, NewVariable(), Success, env).Force(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
 func TestCopyTerm(t *testing.T) {
 	x, y := NewVariable(), NewVariable()
 	a, b := NewVariable(), NewVariable()
@@ -914,7 +1003,7 @@ func TestCopyTerm(t *testing.T) {
 
 func TestTermVariables(t *testing.T) {
 	vars := NewVariable()
-	vs, vt := NewVariable(), NewVariable()
+	vs, vt, vc := NewVariable(), NewVariable(), NewVariable()
 	a, b, c, d := NewVariable(), NewVariable(), NewVariable(), NewVariable()
 
 	tests := []struct {
@@ -952,12 +1041,16 @@ func TestTermVariables(t *testing.T) {
 			vars: List(b),
 		}},
 
+		{title: "cyclic", term: NewAtom("g").Apply(vc, d), vars: vars, ok: true, env: map[Variable]Term{
+			vars: List(a, d),
+		}},
 		{title: "out of memory", term: NewAtom("f").Apply(NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable()), vars: vars, ok: false, err: resourceError(resourceMemory, nil), mem: 1},
 	}
 
 	env := NewEnv().
 		bind(vs, atomPlus.Apply(b, vt)).
-		bind(vt, NewAtom("*").Apply(a, b))
+		bind(vt, NewAtom("*").Apply(a, b)).
+		bind(vc, NewAtom("f").Apply(vc, a, vc))
 	for _, tt := range tests {
 		t.Run(tt.title, func(t *testing.T) {
 			defer setMemFree(tt.mem)()
@@ -1340,6 +1433,29 @@ func TestCurrentOp(t *testing.T) {
 		assert.False(t, ok)
 	})
 
+	t.Run("canonical order", func(t *testing.T) {
+		vm := VM{_operators: newOperators()}
+		vm.getOperators().define(700, operatorSpecifierXFX, NewAtom(`b`))
+		vm.getOperators().define(200, operatorSpecifierXFY, NewAtom(`c`))
+		vm.getOperators().define(700, operatorSpecifierXFX, NewAtom(`a`))
+		vm.getOperators().define(700, operatorSpecifierFY, NewAtom(`b`))
+
+		var ops []Term
+		priority, specifier, operator := NewVariable(), NewVariable(), NewVariable()
+		ok, err := CurrentOp(&vm, priority, specifier, operator, func(env *Env) *Promise {
+			ops = append(ops, tuple(env.Resolve(priority), env.Resolve(specifier), env.Resolve(operator)))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, []Term{
+			tuple(Integer(200), atomXFY, NewAtom(`c`)),
+			tuple(Integer(700), atomFY, NewAtom(`b`)),
+			tuple(Integer(700), atomXFX, NewAtom(`a`)),
+			tuple(Integer(700), atomXFX, NewAtom(`b`)),
+		}, ops)
+	})
+
 	t.Run("priority is not an operator priority", func(t *testing.T) {
 		t.Run("priority is not an integer", func(t *testing.T) {
 			ok, err := CurrentOp(&vm, NewAtom("foo"), atomXFX, atomPlus, Success, nil).Force(context.Background())
@@ -2301,9 +2417,34 @@ func TestBetween(t *testing.T) {
 		assert.Equal(t, typeError(validTypeInteger, NewAtom("inf"), nil), err)
 	})
 
+	t.Run("upper is inf", func(t *testing.T) {
+		for _, upper := range []Atom{NewAtom("inf"), NewAtom("infinite")} {
+			var n int
+			value := NewVariable()
+			ok, err := Between(nil, Integer(1), upper, value, func(env *Env) *Promise {
+				n++
+				assert.Equal(t, Integer(n), env.Resolve(value))
+				return Bool(n == 100)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = Between(nil, Integer(1), upper, Integer(math.MaxInt64), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = Between(nil, Integer(1), upper, Integer(0), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		}
+	})
+
 	t.Run("upper is not an integer", func(t *testing.T) {
-		_, err := Between(nil, Integer(1), NewAtom("inf"), Integer(1), Success, nil).Force(context.Background())
-		assert.Equal(t, typeError(validTypeInteger, NewAtom("inf"), nil), err)
+		_, err := Between(nil, Integer(1), NewAtom("foo"), Integer(1), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeInteger, NewAtom("foo"), nil), err)
+
+		_, err = Between(nil, Integer(1), NewFloatFromInt64(2), Integer(1), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeInteger, NewFloatFromInt64(2), nil), err)
 	})
 
 	t.Run("value is not an integer or variable", func(t *testing.T) {
@@ -2354,6 +2495,29 @@ func TestSort(t *testing.T) {
 	})
 }
 
+func TestMSort(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		half, one := newFloatFromFloat64Must(0.5), newFloatFromFloat64Must(1)
+		sorted := NewVariable()
+		ok, err := MSort(nil, List(NewAtom("a"), Integer(1), mustRational(1, 3), one, Integer(1), half, Integer(0)), sorted, func(env *Env) *Promise {
+			assert.Equal(t, List(Integer(0), mustRational(1, 3), half, one, Integer(1), Integer(1), NewAtom("a")), env.Resolve(sorted))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("list is a partial list", func(t *testing.T) {
+		_, err := MSort(nil, PartialList(NewVariable(), NewAtom("a"), NewAtom("b")), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+	})
+
+	t.Run("sorted is neither a partial list nor a list", func(t *testing.T) {
+		_, err := MSort(nil, List(NewAtom("a")), NewAtom("a"), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeList, NewAtom("a"), nil), err)
+	})
+}
+
 func TestKeySort(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		t.Run("variable", func(t *testing.T) {
@@ -2711,6 +2875,69 @@ func TestCurrentPredicate(t *testing.T) {
 	})
 }
 
+func TestPredicateProperty(t *testing.T) {
+	foo, bar := NewAtom("foo"), NewAtom("bar")
+	vm := VM{
+		procedures: buildOrderedMap(
+			procedurePair{
+				Key: procedureIndicator{name: foo, arity: 1},
+				Value: &userDefined{dynamic: true, multifile: true, clauses: clauses{
+					{pi: procedureIndicator{name: foo, arity: 1}},
+					{pi: procedureIndicator{name: foo, arity: 1}, erased: 1},
+					{pi: procedureIndicator{name: foo, arity: 1}},
+				}, retired: 1},
+			},
+			procedurePair{
+				Key:   procedureIndicator{name: bar, arity: 0},
+				Value: &userDefined{discontiguous: true, tabled: true},
+			},
+			procedurePair{
+				Key:   procedureIndicator{name: atomTrue, arity: 0},
+				Value: Predicate0(func(_ *VM, k Cont, env *Env) *Promise { return k(env) }),
+			},
+			procedurePair{
+				Key: procedureIndicator{name: NewAtom("once"), arity: 1},
+				Value: &userDefined{builtIn: true, clauses: clauses{
+					{pi: procedureIndicator{name: NewAtom("once"), arity: 1}},
+				}},
+			},
+		),
+	}
+	properties := func(head Term) []Term {
+		var ret []Term
+		p := NewVariable()
+		_, err := PredicateProperty(&vm, head, p, func(env *Env) *Promise {
+			ret = append(ret, env.Resolve(p))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		return ret
+	}
+
+	assert.Equal(t, []Term{atomDefined, atomDynamic, atomMultifile, atomNumberOfClauses.Apply(Integer(2))}, properties(foo.Apply(NewAtom("a"))))
+	assert.Equal(t, []Term{atomDefined, atomStatic, atomDiscontiguous, atomTabled, atomNumberOfClauses.Apply(Integer(0))}, properties(bar))
+	assert.Equal(t, []Term{atomDefined, atomBuiltIn, atomStatic}, properties(atomTrue))
+	assert.Equal(t, []Term{atomDefined, atomBuiltIn, atomStatic}, properties(NewAtom("once").Apply(NewVariable())))
+	assert.Empty(t, properties(NewAtom("baz")))
+
+	t.Run("enumerate", func(t *testing.T) {
+		var heads []Term
+		h := NewVariable()
+		_, err := PredicateProperty(&vm, h, atomDynamic, func(env *Env) *Promise {
+			heads = append(heads, env.Resolve(h))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, heads, 1)
+		assert.Equal(t, foo, heads[0].(Compound).Functor())
+	})
+
+	t.Run("not callable", func(t *testing.T) {
+		_, err := PredicateProperty(&vm, Integer(1), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeCallable, Integer(1), nil), err)
+	})
+}
+
 func TestAssertz(t *testing.T) {
 	t.Run("append", func(t *testing.T) {
 		var vm VM
@@ -2729,7 +2956,7 @@ func TestAssertz(t *testing.T) {
 		assert.NoError(t, err)
 		assert.True(t, ok)
 
-		assert.Equal(t, &userDefined{public: true, dynamic: true, clauses: []clause{
+		assert.Equal(t, &userDefined{public: true, dynamic: true, clauses: clauses{
 			{
 				pi: procedureIndicator{
 					name:  NewAtom("foo"),
@@ -2862,7 +3089,7 @@ func TestAsserta(t *testing.T) {
 		assert.NoError(t, err)
 		assert.True(t, ok)
 
-		assert.Equal(t, &userDefined{public: true, dynamic: true, clauses: []clause{
+		assert.Equal(t, &userDefined{public: true, dynamic: true, clauses: clauses{
 			{
 				pi: procedureIndicator{name: NewAtom("foo"), arity: 1},
 				raw: &compound{
@@ -2913,7 +3140,7 @@ func TestAsserta(t *testing.T) {
 		assert.NoError(t, err)
 		assert.True(t, ok)
 
-		assert.Equal(t, &userDefined{public: true, dynamic: true, clauses: []clause{
+		assert.Equal(t, &userDefined{public: true, dynamic: true, clauses: clauses{
 			{
 				pi: procedureIndicator{name: NewAtom("foo"), arity: 0},
 				raw: &compound{
@@ -3070,7 +3297,7 @@ func TestRetract(t *testing.T) {
 			procedures: buildOrderedMap(
 				procedurePair{
 					Key: procedureIndicator{name: NewAtom("foo"), arity: 1},
-					Value: &userDefined{dynamic: true, clauses: []clause{
+					Value: &userDefined{dynamic: true, clauses: clauses{
 						{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("a")}}},
 						{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("b")}}},
 						{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("c")}}},
@@ -3086,10 +3313,11 @@ func TestRetract(t *testing.T) {
 		assert.NoError(t, err)
 		assert.True(t, ok)
 
-		assert.Equal(t, &userDefined{dynamic: true, clauses: []clause{
+		assert.Equal(t, &userDefined{dynamic: true, clauses: clauses{
+			{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("a")}}, erased: 1},
 			{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("b")}}},
 			{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("c")}}},
-		}}, vm.procedures.GetPair(procedureIndicator{name: NewAtom("foo"), arity: 1}).Value)
+		}, retired: 1}, vm.procedures.GetPair(procedureIndicator{name: NewAtom("foo"), arity: 1}).Value)
 	})
 
 	t.Run("retract the specific one", func(t *testing.T) {
@@ -3097,7 +3325,7 @@ func TestRetract(t *testing.T) {
 			procedures: buildOrderedMap(
 				procedurePair{
 					Key: procedureIndicator{name: NewAtom("foo"), arity: 1},
-					Value: &userDefined{dynamic: true, clauses: []clause{
+					Value: &userDefined{dynamic: true, clauses: clauses{
 						{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("a")}}},
 						{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("b")}}},
 						{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("c")}}},
@@ -3113,10 +3341,11 @@ func TestRetract(t *testing.T) {
 		assert.NoError(t, err)
 		assert.True(t, ok)
 
-		assert.Equal(t, &userDefined{dynamic: true, clauses: []clause{
+		assert.Equal(t, &userDefined{dynamic: true, clauses: clauses{
 			{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("a")}}},
+			{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("b")}}, erased: 1},
 			{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("c")}}},
-		}}, vm.procedures.GetPair(procedureIndicator{name: NewAtom("foo"), arity: 1}).Value)
+		}, retired: 1}, vm.procedures.GetPair(procedureIndicator{name: NewAtom("foo"), arity: 1}).Value)
 	})
 
 	t.Run("retract all", func(t *testing.T) {
@@ -3124,7 +3353,7 @@ func TestRetract(t *testing.T) {
 			procedures: buildOrderedMap(
 				procedurePair{
 					Key: procedureIndicator{name: NewAtom("foo"), arity: 1},
-					Value: &userDefined{dynamic: true, clauses: []clause{
+					Value: &userDefined{dynamic: true, clauses: clauses{
 						{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("a")}}},
 						{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("b")}}},
 						{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("c")}}},
@@ -3189,7 +3418,7 @@ func TestRetract(t *testing.T) {
 			procedures: buildOrderedMap(
 				procedurePair{
 					Key: procedureIndicator{name: NewAtom("foo"), arity: 1},
-					Value: &userDefined{dynamic: true, clauses: []clause{
+					Value: &userDefined{dynamic: true, clauses: clauses{
 						{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("a")}}},
 					}},
 				},
@@ -3211,13 +3440,105 @@ func TestRetract(t *testing.T) {
 	})
 }
 
+func TestRetractAll(t *testing.T) {
+	foo := NewAtom("foo")
+	newVM := func() *VM {
+		var vm VM
+		for i := range 2 * minIndexedClauses {
+			_, err := Assertz(&vm, foo.Apply(Integer(i%4), Integer(i)), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+		}
+		return &vm
+	}
+	solutions := func(vm *VM, goal Term, v Variable) []Term {
+		var ret []Term
+		_, err := Call(vm, goal, func(env *Env) *Promise {
+			ret = append(ret, env.Resolve(v))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		return ret
+	}
+
+	t.Run("matching clauses", func(t *testing.T) {
+		vm := newVM()
+		x, y := NewVariable(), NewVariable()
+		// Build the first argument index before retracting.
+		assert.Len(t, solutions(vm, foo.Apply(Integer(1), y), y), 4)
+
+		ok, err := RetractAll(vm, foo.Apply(Integer(1), x), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		assert.Empty(t, solutions(vm, foo.Apply(Integer(1), y), y))
+		assert.Equal(t, []Term{Integer(2), Integer(6), Integer(10), Integer(14)}, solutions(vm, foo.Apply(Integer(2), y), y))
+		assert.Len(t, solutions(vm, foo.Apply(x, y), y), 12)
+	})
+
+	t.Run("no matching clauses", func(t *testing.T) {
+		vm := newVM()
+		ok, err := RetractAll(vm, foo.Apply(Integer(5), NewVariable()), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, ClauseGCStats{}, vm.ClauseGCStats())
+	})
+
+	t.Run("logical update view", func(t *testing.T) {
+		vm := newVM()
+		y := NewVariable()
+		var got []Term
+		_, err := Call(vm, foo.Apply(Integer(0), y), func(env *Env) *Promise {
+			got = append(got, env.Resolve(y))
+			if len(got) == 1 {
+				_, err := RetractAll(vm, foo.Apply(NewVariable(), NewVariable()), Success, nil).Force(context.Background())
+				assert.NoError(t, err)
+			}
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []Term{Integer(0), Integer(4), Integer(8), Integer(12)}, got)
+		assert.Empty(t, solutions(vm, foo.Apply(Integer(0), y), y))
+	})
+
+	t.Run("unknown procedure", func(t *testing.T) {
+		var vm VM
+		ok, err := RetractAll(&vm, NewAtom("bar"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		p, ok := vm.getProcedure(procedureIndicator{name: NewAtom("bar"), arity: 0})
+		assert.True(t, ok)
+		assert.True(t, p.(*userDefined).dynamic)
+	})
+
+	t.Run("head is a variable", func(t *testing.T) {
+		var vm VM
+		_, err := RetractAll(&vm, NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+	})
+
+	t.Run("head is not callable", func(t *testing.T) {
+		var vm VM
+		_, err := RetractAll(&vm, Integer(0), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeCallable, Integer(0), nil), err)
+	})
+
+	t.Run("static procedure", func(t *testing.T) {
+		vm := VM{procedures: buildOrderedMap(procedurePair{
+			Key:   procedureIndicator{name: foo, arity: 0},
+			Value: &userDefined{},
+		})}
+		_, err := RetractAll(&vm, foo, Success, nil).Force(context.Background())
+		assert.Equal(t, permissionError(operationModify, permissionTypeStaticProcedure, atomSlash.Apply(foo, Integer(0)), nil), err)
+	})
+}
+
 func TestAbolish(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		vm := VM{
 			procedures: buildOrderedMap(
 				procedurePair{
 					Key: procedureIndicator{name: NewAtom("foo"), arity: 1},
-					Value: &userDefined{dynamic: true, clauses: []clause{
+					Value: &userDefined{dynamic: true, clauses: clauses{
 						{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("a")}}},
 						{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("b")}}},
 						{raw: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("c")}}},
@@ -3611,6 +3932,25 @@ func TestOpen(t *testing.T) {
 			assert.True(t, ok)
 		})
 
+		t.Run("newline dos", func(t *testing.T) {
+			v := NewVariable()
+			ok, err := Open(&vm, NewAtom(f.Name()), atomRead, v, List(atomNewline.Apply(atomDOS)), func(env *Env) *Promise {
+				ref, ok := env.lookup(v)
+				assert.True(t, ok)
+				s, ok := ref.(*Stream)
+				assert.True(t, ok)
+				assert.Equal(t, newlineDOS, s.newline)
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+
+		t.Run("newline unknown", func(t *testing.T) {
+			_, err := Open(&vm, NewAtom(f.Name()), atomRead, NewVariable(), List(atomNewline.Apply(NewAtom("mac"))), Success, nil).Force(context.Background())
+			assert.Equal(t, domainError(validDomainStreamOption, atomNewline.Apply(NewAtom("mac")), nil), err)
+		})
+
 		t.Run("unknown option", func(t *testing.T) {
 			v := NewVariable()
 			ok, err := Open(&vm, NewAtom(f.Name()), atomRead, v, List(&compound{
@@ -4391,6 +4731,14 @@ func TestWriteTerm(t *testing.T) {
 		{title: `write_term(S, _, [max_depth(_)]).`, sOrA: w, term: NewVariable(), options: List(atomMaxDepth.Apply(NewVariable())), err: InstantiationError(nil)},
 		{title: `write_term(S, _, [max_depth(foo)]).`, sOrA: w, term: NewVariable(), options: List(atomMaxDepth.Apply(NewAtom("foo"))), err: domainError(validDomainWriteOption, atomMaxDepth.Apply(NewAtom("foo")), nil)},
 		{title: `L = [a, b|L], write_term(S, L, [max_depth(9)]).`, sOrA: w, term: l, options: List(atomMaxDepth.Apply(Integer(9))), env: NewEnv().bind(l, PartialList(l, NewAtom("a"), NewAtom("b"))), ok: true, output: `[a,b,a,b,a,b,a,b,a|...]`}, // https://github.com/ichiban/prolog/issues/297#issuecomment-1646750461
+		{title: `write_term(S, _, [max_depth(-1)]).`, sOrA: w, term: NewVariable(), options: List(atomMaxDepth.Apply(Integer(-1))), err: domainError(validDomainWriteOption, atomMaxDepth.Apply(Integer(-1)), nil)},
+
+		{title: `write_term(S, f(a), [fullstop(true)]).`, sOrA: w, term: NewAtom("f").Apply(NewAtom("a")), options: List(atomFullStop.Apply(atomTrue)), ok: true, output: `f(a).`},
+		{title: `write_term(S, -, [fullstop(true), nl(true)]).`, sOrA: w, term: atomMinus, options: List(atomFullStop.Apply(atomTrue), atomNl.Apply(atomTrue)), ok: true, output: "- .\n"},
+		{title: `write_term(S, a, [nl(true)]).`, sOrA: w, term: NewAtom("a"), options: List(atomNl.Apply(atomTrue)), ok: true, output: "a\n"},
+		{title: `write_term(S, a, [portray(true)]).`, sOrA: w, term: NewAtom("a"), options: List(atomPortray.Apply(atomTrue)), ok: true, output: "a"},
+		{title: `write_term(S, a, [fullstop(yes)]).`, sOrA: w, term: NewAtom("a"), options: List(atomFullStop.Apply(NewAtom("yes"))), err: domainError(validDomainWriteOption, atomFullStop.Apply(NewAtom("yes")), nil)},
+		{title: `write_term(S, a, [nl(_)]).`, sOrA: w, term: NewAtom("a"), options: List(atomNl.Apply(NewVariable())), err: InstantiationError(nil)},
 	}
 
 	var vm VM
@@ -4415,6 +4763,64 @@ func TestWriteTerm(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("portray", func(t *testing.T) {
+		var vm VM
+		vm.Register1(atomPortray, func(vm *VM, t Term, k Cont, env *Env) *Promise {
+			if env.Resolve(t) != NewAtom("secret") {
+				return Bool(false)
+			}
+			return WriteTerm(vm, vm.output, NewAtom("***"), List(), k, env)
+		})
+
+		buf.Reset()
+		term := NewAtom("f").Apply(NewAtom("secret"), List(NewAtom("a"), NewAtom("secret")), x)
+		ok, err := WriteTerm(&vm, w, term, List(atomPortray.Apply(atomTrue), atomFullStop.Apply(atomTrue)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Regexp(t, regexp.MustCompile(`^f\(\*\*\*,\[a,\*\*\*\],_\d+\)\.$`), buf.String())
+	})
+
+	t.Run("portray cyclic", func(t *testing.T) {
+		var vm VM
+		vm.Register1(atomPortray, func(vm *VM, t Term, k Cont, env *Env) *Promise {
+			if env.Resolve(t) != NewAtom("secret") {
+				return Bool(false)
+			}
+			return WriteTerm(vm, vm.output, NewAtom("***"), List(), k, env)
+		})
+
+		buf.Reset()
+		f := NewAtom("f").Apply(x)
+		env := NewEnv().bind(x, f)
+		ok, err := WriteTerm(&vm, w, NewAtom("g").Apply(NewAtom("secret"), f), List(atomPortray.Apply(atomTrue)), Success, env).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "g(***,f(...))", buf.String())
+	})
+
+	t.Run("portray max_depth", func(t *testing.T) {
+		var (
+			vm    VM
+			calls int
+		)
+		vm.Register1(atomPortray, func(vm *VM, t Term, k Cont, env *Env) *Promise {
+			calls++
+			if env.Resolve(t) != NewAtom("secret") {
+				return Bool(false)
+			}
+			return WriteTerm(vm, vm.output, NewAtom("***"), List(), k, env)
+		})
+
+		buf.Reset()
+		secret := NewAtom("secret")
+		term := List(secret, secret, NewAtom("f").Apply(secret))
+		ok, err := WriteTerm(&vm, w, term, List(atomPortray.Apply(atomTrue), atomMaxDepth.Apply(Integer(2))), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "[***,***|...]", buf.String())
+		assert.Equal(t, 3, calls)
+	})
 }
 
 type mockTerm struct {
@@ -5005,7 +5411,7 @@ func TestReadTerm(t *testing.T) {
 
 			var vm VM
 			ok, err := ReadTerm(&vm, s, NewVariable(), List(), Success, nil).Force(context.Background())
-			assert.Equal(t, syntaxError(unexpectedTokenError{actual: Token{kind: tokenLetterDigit, val: "bar"}}, nil), err)
+			assert.Equal(t, syntaxError(unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "bar"}, pos: position{line: 1, column: 5}}, nil), err)
 			assert.False(t, ok)
 		})
 
@@ -5041,34 +5447,122 @@ func TestReadTerm(t *testing.T) {
 
 		var vm VM
 		ok, err := ReadTerm(&vm, s, NewVariable(), List(), Success, nil).Force(context.Background())
-		assert.Equal(t, syntaxError(unexpectedTokenError{actual: Token{kind: tokenGraphic, val: "="}}, nil), err)
+		assert.Equal(t, syntaxError(unexpectedTokenError{actual: Token{kind: TokenGraphic, val: "="}, pos: position{line: 1, column: 3}}, nil), err)
 		assert.False(t, ok)
 	})
-}
 
-func TestGetByte(t *testing.T) {
-	t.Run("stream", func(t *testing.T) {
-		f, err := os.Open("testdata/a.txt")
-		assert.NoError(t, err)
-		defer func() {
-			assert.NoError(t, f.Close())
-		}()
+	t.Run("syntax_errors", func(t *testing.T) {
+		read := func(vm *VM, s *Stream, mode Atom) (Term, bool, error) {
+			v := NewVariable()
+			var ret Term
+			ok, err := ReadTerm(vm, s, v, List(atomSyntaxErrors.Apply(mode)), func(env *Env) *Promise {
+				ret = env.Resolve(v)
+				return Bool(true)
+			}, nil).Force(context.Background())
+			return ret, ok, err
+		}
 
-		s := &Stream{source: f, mode: ioModeRead, streamType: streamTypeBinary}
+		t.Run("error", func(t *testing.T) {
+			var vm VM
+			_, ok, err := read(&vm, NewMemoryStream("foo bar. baz."), atomError)
+			assert.Equal(t, syntaxError(unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "bar"}, pos: position{line: 1, column: 5}}, nil), err)
+			assert.False(t, ok)
+		})
 
-		v := NewVariable()
+		t.Run("position", func(t *testing.T) {
+			var vm VM
+			s := NewMemoryStream("foo.\n  bar baz.")
+			_, ok, err := read(&vm, s, atomError)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			_, _, err = read(&vm, s, atomError)
+			assert.Equal(t, syntaxError(unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "baz"}, pos: position{line: 2, column: 7}}, nil), err)
+			assert.Equal(t, "unexpected token: letter digit(baz) at line 2, column 7", err.(Exception).Term().(Compound).Arg(0).(Compound).Arg(0).(Atom).String())
+			assert.Equal(t, atomPosition.Apply(Integer(2), Integer(7)), err.(Exception).Term().(Compound).Arg(1))
+		})
 
-		var vm VM
-		ok, err := GetByte(&vm, s, v, func(env *Env) *Promise {
-			assert.Equal(t, Integer(97), env.Resolve(v))
-			return Bool(true)
-		}, nil).Force(context.Background())
-		assert.NoError(t, err)
-		assert.True(t, ok)
-	})
+		t.Run("fail", func(t *testing.T) {
+			var kinds []Term
+			vm := VM{MessageHook: func(kind, _ Term, _ *Env) bool {
+				kinds = append(kinds, kind)
+				return true
+			}}
+			_, ok, err := read(&vm, NewMemoryStream("foo bar. baz."), atomFail)
+			assert.NoError(t, err)
+			assert.False(t, ok)
+			assert.Equal(t, []Term{atomError}, kinds)
+		})
 
-	t.Run("valid stream alias", func(t *testing.T) {
-		f, err := os.Open("testdata/a.txt")
+		t.Run("quiet", func(t *testing.T) {
+			var kinds []Term
+			vm := VM{MessageHook: func(kind, _ Term, _ *Env) bool {
+				kinds = append(kinds, kind)
+				return true
+			}}
+			_, ok, err := read(&vm, NewMemoryStream("foo bar. baz."), atomQuiet)
+			assert.NoError(t, err)
+			assert.False(t, ok)
+			assert.Empty(t, kinds)
+		})
+
+		t.Run("dec10", func(t *testing.T) {
+			var messages []Term
+			vm := VM{MessageHook: func(_, message Term, _ *Env) bool {
+				messages = append(messages, message)
+				return true
+			}}
+			s := NewMemoryStream("foo bar. f(. baz. % comment\n")
+			term, ok, err := read(&vm, s, atomDec10)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, NewAtom("baz"), term)
+			assert.Len(t, messages, 2)
+
+			term, ok, err = read(&vm, s, atomDec10)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, atomEndOfFile, term)
+		})
+
+		t.Run("flag", func(t *testing.T) {
+			vm := VM{syntaxErrors: syntaxErrorsQuiet}
+			ok, err := ReadTerm(&vm, NewMemoryStream("foo bar."), NewVariable(), List(), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			var vm VM
+			_, ok, err := read(&vm, NewMemoryStream("foo."), NewAtom("foo"))
+			assert.Equal(t, domainError(validDomainReadOption, atomSyntaxErrors.Apply(NewAtom("foo")), nil), err)
+			assert.False(t, ok)
+		})
+	})
+}
+
+func TestGetByte(t *testing.T) {
+	t.Run("stream", func(t *testing.T) {
+		f, err := os.Open("testdata/a.txt")
+		assert.NoError(t, err)
+		defer func() {
+			assert.NoError(t, f.Close())
+		}()
+
+		s := &Stream{source: f, mode: ioModeRead, streamType: streamTypeBinary}
+
+		v := NewVariable()
+
+		var vm VM
+		ok, err := GetByte(&vm, s, v, func(env *Env) *Promise {
+			assert.Equal(t, Integer(97), env.Resolve(v))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("valid stream alias", func(t *testing.T) {
+		f, err := os.Open("testdata/a.txt")
 		assert.NoError(t, err)
 		defer func() {
 			assert.NoError(t, f.Close())
@@ -5715,6 +6209,37 @@ func Test_Halt(t *testing.T) {
 		assert.Equal(t, int64(2), code)
 	})
 
+	t.Run("on halt", func(t *testing.T) {
+		t.Run("intercepted", func(t *testing.T) {
+			var got int
+			vm := VM{OnHalt: func(code int) error {
+				got = code
+				return nil
+			}}
+			ok, err := Halt(&vm, Integer(3), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, 3, got)
+		})
+
+		t.Run("catchable", func(t *testing.T) {
+			vm := VM{OnHalt: func(code int) error {
+				return NewException(NewAtom("halted").Apply(Integer(code)), nil)
+			}}
+			vm.Register1(NewAtom("halt"), Halt)
+			vm.Register0(atomTrue, func(_ *VM, k Cont, env *Env) *Promise {
+				return k(env)
+			})
+			x := NewVariable()
+			ok, err := Catch(&vm, NewAtom("halt").Apply(Integer(4)), x, atomTrue, func(env *Env) *Promise {
+				assert.Equal(t, NewAtom("halted").Apply(Integer(4)), env.Resolve(x))
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+	})
+
 	t.Run("halt error string", func(t *testing.T) {
 		assert.Equal(t, "halt(7)", HaltError{Code: 7}.Error())
 	})
@@ -5751,7 +6276,7 @@ func TestClause(t *testing.T) {
 			procedures: buildOrderedMap(
 				procedurePair{
 					Key: procedureIndicator{name: NewAtom("green"), arity: 1},
-					Value: &userDefined{public: true, clauses: []clause{
+					Value: &userDefined{public: true, clauses: clauses{
 						{raw: &compound{
 							functor: atomIf, args: []Term{
 								&compound{functor: NewAtom("green"), args: []Term{x}},
@@ -5845,7 +6370,7 @@ func TestClause(t *testing.T) {
 			procedures: buildOrderedMap(
 				procedurePair{
 					Key: procedureIndicator{name: NewAtom("green"), arity: 1},
-					Value: &userDefined{public: true, clauses: []clause{
+					Value: &userDefined{public: true, clauses: clauses{
 						{raw: NewAtom("green").Apply(NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable())},
 					}},
 				},
@@ -6357,6 +6882,62 @@ func TestNumberChars(t *testing.T) {
 			})
 		})
 	})
+
+	t.Run("strict", func(t *testing.T) {
+		vm := VM{numberSyntaxMode: numberSyntaxStrict}
+
+		t.Run("num is neither a variable nor a number and chars is not parsable", func(t *testing.T) {
+			ok, err := NumberChars(&vm, NewAtom("foo"), List(NewAtom("f"), NewAtom("o"), NewAtom("o")), Success, nil).Force(context.Background())
+			assert.Equal(t, typeError(validTypeNumber, NewAtom("foo"), nil), err)
+			assert.False(t, ok)
+		})
+
+		t.Run("num is neither a variable nor a number and chars is not a list of chars", func(t *testing.T) {
+			ok, err := NumberChars(&vm, NewAtom("foo"), List(Integer(0)), Success, nil).Force(context.Background())
+			assert.Equal(t, typeError(validTypeNumber, NewAtom("foo"), nil), err)
+			assert.False(t, ok)
+		})
+
+		t.Run("layout after a minus", func(t *testing.T) {
+			ok, err := NumberChars(&vm, NewVariable(), List(atomMinus, NewAtom(" "), NewAtom("1")), Success, nil).Force(context.Background())
+			assert.Equal(t, syntaxError(errNotANumber, nil), err)
+			assert.False(t, ok)
+		})
+
+		t.Run("empty", func(t *testing.T) {
+			ok, err := NumberChars(&vm, NewVariable(), atomEmptyList, Success, nil).Force(context.Background())
+			assert.Equal(t, syntaxError(errNotANumber, nil), err)
+			assert.False(t, ok)
+		})
+
+		t.Run("leading layout", func(t *testing.T) {
+			ok, err := NumberChars(&vm, Integer(-1), List(NewAtom(" "), atomMinus, NewAtom("1")), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+	})
+
+	t.Run("extended", func(t *testing.T) {
+		vm := VM{numberSyntaxMode: numberSyntaxExtended}
+
+		t.Run("leading plus", func(t *testing.T) {
+			ok, err := NumberChars(&vm, Integer(1), List(atomPlus, NewAtom("1")), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+
+		t.Run("digit groups", func(t *testing.T) {
+			ok, err := NumberChars(&vm, Integer(-1000), List(atomMinus, NewAtom("1"), NewAtom("_"), NewAtom("0"), NewAtom("0"), NewAtom("0")), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+
+		t.Run("trailing underscore", func(t *testing.T) {
+			ok, err := NumberChars(&vm, NewVariable(), List(NewAtom("1"), NewAtom("_")), Success, nil).Force(context.Background())
+			assert.Equal(t, syntaxError(errNotANumber, nil), err)
+			assert.False(t, ok)
+		})
+	})
 }
 
 func TestNumberCodes(t *testing.T) {
@@ -6429,6 +7010,13 @@ func TestNumberCodes(t *testing.T) {
 			assert.Equal(t, tt.ok, ok)
 		})
 	}
+
+	t.Run("strict", func(t *testing.T) {
+		vm := VM{numberSyntaxMode: numberSyntaxStrict}
+		ok, err := NumberCodes(&vm, NewAtom("foo"), List(Integer('f'), Integer('o'), Integer('o')), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeNumber, NewAtom("foo"), nil), err)
+		assert.False(t, ok)
+	})
 }
 
 func TestStreamProperty(t *testing.T) {
@@ -6469,10 +7057,14 @@ func TestStreamProperty(t *testing.T) {
 				{p: atomInput},
 				{p: atomAlias.Apply(NewAtom("null"))},
 				{p: atomPosition.Apply(Integer(0))},
+				{p: atomLineCount.Apply(Integer(1))},
+				{p: atomLinePosition.Apply(Integer(0))},
+				{p: atomCharacterCount.Apply(Integer(0))},
 				{p: atomEndOfStream.Apply(atomNot)},
 				{p: atomEOFAction.Apply(atomEOFCode)},
 				{p: atomReposition.Apply(atomTrue)},
 				{p: atomType.Apply(atomText)},
+				{p: atomNewline.Apply(atomPOSIX)},
 			},
 		},
 		{
@@ -6656,6 +7248,42 @@ func TestSetStreamPosition(t *testing.T) {
 	})
 }
 
+func TestLineCount(t *testing.T) {
+	s := NewMemoryStream("foo.\nbar.\n")
+	s.alias = NewAtom("in")
+	var vm VM
+	vm.streams.add(s)
+	for range 6 {
+		_, _, err := s.ReadRune()
+		assert.NoError(t, err)
+	}
+
+	tests := []struct {
+		title  string
+		pred   func(*VM, Term, Term, Cont, *Env) *Promise
+		stream Term
+		count  Term
+		ok     bool
+		err    error
+	}{
+		{title: "line_count", pred: LineCount, stream: s, count: Integer(2), ok: true},
+		{title: "line_count with alias", pred: LineCount, stream: NewAtom("in"), count: Integer(2), ok: true},
+		{title: "line_position", pred: LinePosition, stream: s, count: Integer(1), ok: true},
+		{title: "character_count", pred: CharacterCount, stream: s, count: Integer(6), ok: true},
+		{title: "different count", pred: CharacterCount, stream: s, count: Integer(5), ok: false},
+		{title: "stream is a variable", pred: LineCount, stream: NewVariable(), count: Integer(2), err: InstantiationError(nil)},
+		{title: "count is not an integer", pred: LineCount, stream: s, count: NewAtom("two"), err: typeError(validTypeInteger, NewAtom("two"), nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			ok, err := tt.pred(&vm, tt.stream, tt.count, Success, nil).Force(context.Background())
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
 func TestCharConversion(t *testing.T) {
 	t.Run("register", func(t *testing.T) {
 		var vm VM
@@ -6898,29 +7526,44 @@ func TestSetPrologFlag(t *testing.T) {
 	t.Run("unknown", func(t *testing.T) {
 		t.Run("error", func(t *testing.T) {
 			vm := VM{unknown: unknownFail}
-			ok, err := SetPrologFlag(&vm, atomUnknown, atomError, Success, nil).Force(context.Background())
+			var flags queryFlags
+			ok, err := SetPrologFlag(&vm, atomUnknown, atomError, func(env *Env) *Promise {
+				flags = vm.flags(env)
+				return Bool(true)
+			}, nil).Force(context.Background())
 			assert.NoError(t, err)
 			assert.True(t, ok)
-			assert.Equal(t, unknownError, vm.unknown)
+			assert.Equal(t, unknownError, flags.unknown)
+			assert.Equal(t, unknownFail, vm.unknown)
 		})
 
 		t.Run("warning", func(t *testing.T) {
 			var vm VM
-			ok, err := SetPrologFlag(&vm, atomUnknown, atomWarning, Success, nil).Force(context.Background())
+			var flags queryFlags
+			ok, err := SetPrologFlag(&vm, atomUnknown, atomWarning, func(env *Env) *Promise {
+				flags = vm.flags(env)
+				return Bool(true)
+			}, nil).Force(context.Background())
 			assert.NoError(t, err)
 			assert.True(t, ok)
-			assert.Equal(t, unknownWarning, vm.unknown)
+			assert.Equal(t, unknownWarning, flags.unknown)
+			assert.Equal(t, unknownError, vm.unknown)
 		})
 
 		t.Run("fail", func(t *testing.T) {
 			var vm VM
-			ok, err := SetPrologFlag(&vm, atomUnknown, atomFail, Success, nil).Force(context.Background())
+			var flags queryFlags
+			ok, err := SetPrologFlag(&vm, atomUnknown, atomFail, func(env *Env) *Promise {
+				flags = vm.flags(env)
+				return Bool(true)
+			}, nil).Force(context.Background())
 			assert.NoError(t, err)
 			assert.True(t, ok)
-			assert.Equal(t, unknownFail, vm.unknown)
+			assert.Equal(t, unknownFail, flags.unknown)
+			assert.Equal(t, unknownError, vm.unknown)
 		})
 
-		t.Run("fail", func(t *testing.T) {
+		t.Run("unknown", func(t *testing.T) {
 			var vm VM
 			ok, err := SetPrologFlag(&vm, atomUnknown, NewAtom("foo"), Success, nil).Force(context.Background())
 			assert.Error(t, err)
@@ -6929,38 +7572,133 @@ func TestSetPrologFlag(t *testing.T) {
 	})
 
 	t.Run("double_quotes", func(t *testing.T) {
-		t.Run("codes", func(t *testing.T) {
+		tests := []struct {
+			value        Atom
+			doubleQuotes doubleQuotes
+		}{
+			{value: atomCodes, doubleQuotes: doubleQuotesCodes},
+			{value: atomChars, doubleQuotes: doubleQuotesChars},
+			{value: atomAtom, doubleQuotes: doubleQuotesAtom},
+		}
+		for _, tt := range tests {
+			t.Run(tt.value.String(), func(t *testing.T) {
+				vm := VM{doubleQuotes: doubleQuotesString}
+				var flags queryFlags
+				ok, err := SetPrologFlag(&vm, atomDoubleQuotes, tt.value, func(env *Env) *Promise {
+					flags = vm.flags(env)
+					return Bool(true)
+				}, nil).Force(context.Background())
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, tt.doubleQuotes, flags.doubleQuotes)
+				assert.Equal(t, doubleQuotesString, vm.doubleQuotes)
+			})
+		}
+
+		t.Run("unknown", func(t *testing.T) {
+			var vm VM
+			ok, err := SetPrologFlag(&vm, atomDoubleQuotes, NewAtom("foo"), Success, nil).Force(context.Background())
+			assert.Error(t, err)
+			assert.False(t, ok)
+		})
+	})
+
+	t.Run("occurs_check", func(t *testing.T) {
+		t.Run("true", func(t *testing.T) {
 			var vm VM
-			ok, err := SetPrologFlag(&vm, atomDoubleQuotes, atomCodes, Success, nil).Force(context.Background())
+			x := NewVariable()
+			ok, err := SetPrologFlag(&vm, atomOccursCheck, atomTrue, func(env *Env) *Promise {
+				return Unify(&vm, x, NewAtom("f").Apply(x), Success, env)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.False(t, ok)
+			assert.False(t, vm.occursCheck)
+		})
+
+		t.Run("false", func(t *testing.T) {
+			vm := VM{occursCheck: true}
+			var flags queryFlags
+			ok, err := SetPrologFlag(&vm, atomOccursCheck, atomFalse, func(env *Env) *Promise {
+				flags = vm.flags(env)
+				return Bool(true)
+			}, nil).Force(context.Background())
 			assert.NoError(t, err)
 			assert.True(t, ok)
-			assert.Equal(t, doubleQuotesCodes, vm.doubleQuotes)
+			assert.False(t, flags.occursCheck)
+			assert.True(t, vm.occursCheck)
 		})
 
-		t.Run("chars", func(t *testing.T) {
+		t.Run("unknown", func(t *testing.T) {
 			var vm VM
-			ok, err := SetPrologFlag(&vm, atomDoubleQuotes, atomChars, Success, nil).Force(context.Background())
+			ok, err := SetPrologFlag(&vm, atomOccursCheck, NewAtom("foo"), Success, nil).Force(context.Background())
+			assert.Equal(t, domainError(validDomainFlagValue, atomPlus.Apply(atomOccursCheck, NewAtom("foo")), nil), err)
+			assert.False(t, ok)
+		})
+	})
+
+	t.Run("prefer_rationals", func(t *testing.T) {
+		t.Run("true", func(t *testing.T) {
+			var vm VM
+			ok, err := SetPrologFlag(&vm, atomPreferRationals, atomTrue, Success, nil).Force(context.Background())
 			assert.NoError(t, err)
 			assert.True(t, ok)
-			assert.Equal(t, doubleQuotesChars, vm.doubleQuotes)
+			assert.True(t, vm.preferRationals)
 		})
 
-		t.Run("atom", func(t *testing.T) {
-			var vm VM
-			ok, err := SetPrologFlag(&vm, atomDoubleQuotes, atomAtom, Success, nil).Force(context.Background())
+		t.Run("false", func(t *testing.T) {
+			vm := VM{preferRationals: true}
+			ok, err := SetPrologFlag(&vm, atomPreferRationals, atomFalse, Success, nil).Force(context.Background())
 			assert.NoError(t, err)
 			assert.True(t, ok)
-			assert.Equal(t, doubleQuotesAtom, vm.doubleQuotes)
+			assert.False(t, vm.preferRationals)
 		})
 
 		t.Run("unknown", func(t *testing.T) {
 			var vm VM
-			ok, err := SetPrologFlag(&vm, atomDoubleQuotes, NewAtom("foo"), Success, nil).Force(context.Background())
+			ok, err := SetPrologFlag(&vm, atomPreferRationals, NewAtom("foo"), Success, nil).Force(context.Background())
+			assert.Error(t, err)
+			assert.False(t, ok)
+		})
+	})
+
+	t.Run("number_syntax", func(t *testing.T) {
+		for _, v := range []numberSyntax{numberSyntaxStandard, numberSyntaxStrict, numberSyntaxExtended} {
+			t.Run(v.String(), func(t *testing.T) {
+				var vm VM
+				ok, err := SetPrologFlag(&vm, atomNumberSyntax, NewAtom(v.String()), Success, nil).Force(context.Background())
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, v, vm.numberSyntaxMode)
+			})
+		}
+
+		t.Run("unknown", func(t *testing.T) {
+			var vm VM
+			ok, err := SetPrologFlag(&vm, atomNumberSyntax, NewAtom("foo"), Success, nil).Force(context.Background())
 			assert.Error(t, err)
 			assert.False(t, ok)
 		})
 	})
 
+	t.Run("syntax_errors", func(t *testing.T) {
+		for _, v := range []syntaxErrors{syntaxErrorsError, syntaxErrorsFail, syntaxErrorsQuiet, syntaxErrorsDec10} {
+			t.Run(v.String(), func(t *testing.T) {
+				var vm VM
+				ok, err := SetPrologFlag(&vm, atomSyntaxErrors, NewAtom(v.String()), Success, nil).Force(context.Background())
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, v, vm.syntaxErrors)
+			})
+		}
+
+		t.Run("unknown", func(t *testing.T) {
+			var vm VM
+			ok, err := SetPrologFlag(&vm, atomSyntaxErrors, NewAtom("foo"), Success, nil).Force(context.Background())
+			assert.Equal(t, domainError(validDomainFlagValue, atomPlus.Apply(atomSyntaxErrors, NewAtom("foo")), nil), err)
+			assert.False(t, ok)
+		})
+	})
+
 	t.Run("flag is a variable", func(t *testing.T) {
 		var vm VM
 		ok, err := SetPrologFlag(&vm, NewVariable(), atomFail, Success, nil).Force(context.Background())
@@ -7076,6 +7814,18 @@ func TestCurrentPrologFlag(t *testing.T) {
 			case 8:
 				assert.Equal(t, atomDoubleQuotes, env.Resolve(flag))
 				assert.Equal(t, NewAtom(vm.doubleQuotes.String()), env.Resolve(value))
+			case 9:
+				assert.Equal(t, atomPreferRationals, env.Resolve(flag))
+				assert.Equal(t, atomFalse, env.Resolve(value))
+			case 10:
+				assert.Equal(t, atomNumberSyntax, env.Resolve(flag))
+				assert.Equal(t, atomStandard, env.Resolve(value))
+			case 11:
+				assert.Equal(t, atomOccursCheck, env.Resolve(flag))
+				assert.Equal(t, atomFalse, env.Resolve(value))
+			case 12:
+				assert.Equal(t, atomSyntaxErrors, env.Resolve(flag))
+				assert.Equal(t, atomError, env.Resolve(value))
 			default:
 				assert.Fail(t, "unreachable")
 			}
@@ -7084,7 +7834,7 @@ func TestCurrentPrologFlag(t *testing.T) {
 		}, nil).Force(context.Background())
 		assert.NoError(t, err)
 		assert.False(t, ok)
-		assert.Equal(t, 9, c)
+		assert.Equal(t, 13, c)
 	})
 
 	t.Run("flag is neither a variable nor an atom", func(t *testing.T) {
@@ -7407,6 +8157,146 @@ term_expansion(f(X), g(X)).
 	}
 }
 
+func TestExpandGoal(t *testing.T) {
+	f, g, h := NewAtom("f"), NewAtom("g"), NewAtom("h")
+	a := NewAtom("a")
+
+	x := NewVariable()
+
+	var vm VM
+	assert.NoError(t, vm.Compile(context.Background(), `
+goal_expansion(f(X), g(X)).
+goal_expansion(g(X), g(X)).
+`))
+
+	tests := []struct {
+		title string
+		in    Term
+		out   Term
+	}{
+		{title: "not applicable", in: h.Apply(a), out: h.Apply(a)},
+		{title: "applicable", in: f.Apply(a), out: g.Apply(a)},
+		{title: "conjunction", in: atomComma.Apply(f.Apply(a), h.Apply(a)), out: atomComma.Apply(g.Apply(a), h.Apply(a))},
+		{
+			title: "nested control constructs",
+			in:    atomSemiColon.Apply(atomThen.Apply(atomNegation.Apply(f.Apply(a)), atomCall.Apply(f.Apply(a))), x),
+			out:   atomSemiColon.Apply(atomThen.Apply(atomNegation.Apply(g.Apply(a)), atomCall.Apply(g.Apply(a))), x),
+		},
+		{title: "not a control construct", in: h.Apply(f.Apply(a)), out: h.Apply(f.Apply(a))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			out := NewVariable()
+			ok, err := ExpandGoal(&vm, tt.in, out, func(env *Env) *Promise {
+				assert.Equal(t, tt.out, env.simplify(out))
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+	}
+
+	t.Run("clause bodies", func(t *testing.T) {
+		var vm VM
+		vm.Register1(NewAtom("ok"), func(_ *VM, t Term, k Cont, env *Env) *Promise {
+			return k(env)
+		})
+		vm.Register1(atomCall, Call)
+		assert.NoError(t, vm.Compile(context.Background(), `
+goal_expansion(missing(X), ok(X)).
+`))
+		assert.NoError(t, vm.Compile(context.Background(), `
+:-(foo(X), ','(missing(X), call(missing(X)))).
+:-(missing(a)).
+`))
+		ok, err := vm.Arrive(NewAtom("foo"), []Term{a}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("shared variables", func(t *testing.T) {
+		var vm VM
+		var got []Term
+		vm.Register1(NewAtom("out"), func(_ *VM, t Term, k Cont, env *Env) *Promise {
+			got = append(got, env.Resolve(t))
+			return k(env)
+		})
+		vm.Register2(NewAtom("=="), Equal)
+		assert.NoError(t, vm.Compile(context.Background(), `
+goal_expansion(foo(X), bar(X)).
+bar(1).
+`))
+		assert.NoError(t, vm.Compile(context.Background(), `
+:-(q(X), foo(X)).
+:-(r(X), ','(foo(X), ==(X, 1))).
+:-(','(foo(X), out(X))).
+`))
+		assert.Equal(t, []Term{Integer(1)}, got)
+
+		for _, name := range []string{"q", "r"} {
+			y := NewVariable()
+			ok, err := vm.Arrive(NewAtom(name), []Term{y}, func(env *Env) *Promise {
+				assert.Equal(t, Integer(1), env.Resolve(y))
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+
+		out := NewVariable()
+		ok, err := ExpandGoal(&vm, NewAtom("foo").Apply(x), out, func(env *Env) *Promise {
+			assert.Equal(t, NewAtom("bar").Apply(env.Resolve(x)), env.simplify(out))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("loops", func(t *testing.T) {
+		var vm VM
+		assert.NoError(t, vm.Compile(context.Background(), `
+goal_expansion(a, b).
+goal_expansion(b, a).
+goal_expansion(c, ','(c, c)).
+goal_expansion(d(X), d(s(X))).
+`))
+		d := NewAtom("d")
+		deep := Term(x)
+		for i := 0; i < goalExpansionMaxDepth; i++ {
+			deep = NewAtom("s").Apply(deep)
+		}
+
+		for _, tt := range []struct {
+			in, out Term
+		}{
+			{in: a, out: a},
+			{in: NewAtom("b"), out: NewAtom("b")},
+			{in: NewAtom("c"), out: atomComma.Apply(NewAtom("c"), NewAtom("c"))},
+			{in: d.Apply(x), out: d.Apply(deep)},
+		} {
+			out := NewVariable()
+			ok, err := ExpandGoal(&vm, tt.in, out, func(env *Env) *Promise {
+				assert.True(t, variant(tt.out, out, env))
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		var vm VM
+		assert.NoError(t, vm.Compile(context.Background(), `
+goal_expansion(a, b).
+`))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := ExpandGoal(&vm, a, NewVariable(), Success, nil).Force(ctx)
+		assert.Equal(t, context.Canceled, err)
+	})
+}
+
 func TestNth0(t *testing.T) {
 	t.Run("n is a variable", func(t *testing.T) {
 		t.Run("list is a proper list", func(t *testing.T) {
@@ -7611,6 +8501,43 @@ func TestSucc(t *testing.T) {
 	})
 }
 
+func TestPlus(t *testing.T) {
+	x, y, z := NewVariable(), NewVariable(), NewVariable()
+	tests := []struct {
+		title   string
+		x, y, z Term
+		ok      bool
+		err     error
+		env     map[Variable]Term
+	}{
+		{title: "plus(1, 2, Z)", x: Integer(1), y: Integer(2), z: z, ok: true, env: map[Variable]Term{z: Integer(3)}},
+		{title: "plus(1, Y, 3)", x: Integer(1), y: y, z: Integer(3), ok: true, env: map[Variable]Term{y: Integer(2)}},
+		{title: "plus(X, 2, 3)", x: x, y: Integer(2), z: Integer(3), ok: true, env: map[Variable]Term{x: Integer(1)}},
+		{title: "plus(1, 2, 3)", x: Integer(1), y: Integer(2), z: Integer(3), ok: true},
+		{title: "plus(1, 2, 4)", x: Integer(1), y: Integer(2), z: Integer(4), ok: false},
+		{title: "plus(-1, Y, 3)", x: Integer(-1), y: y, z: Integer(3), ok: true, env: map[Variable]Term{y: Integer(4)}},
+		{title: "plus(X, Y, 3)", x: x, y: y, z: Integer(3), err: InstantiationError(nil)},
+		{title: "plus(1, Y, Z)", x: Integer(1), y: y, z: z, err: InstantiationError(nil)},
+		{title: "plus(1.0, 2, Z)", x: NewFloatFromInt64(1), y: Integer(2), z: z, err: typeError(validTypeInteger, NewFloatFromInt64(1), nil)},
+		{title: "plus(X, foo, 3)", x: x, y: NewAtom("foo"), z: Integer(3), err: typeError(validTypeInteger, NewAtom("foo"), nil)},
+		{title: "plus(max_integer, 1, Z)", x: Integer(math.MaxInt64), y: Integer(1), z: z, err: evaluationError(exceptionalValueIntOverflow, nil)},
+		{title: "plus(X, 1, min_integer)", x: x, y: Integer(1), z: Integer(math.MinInt64), err: evaluationError(exceptionalValueIntOverflow, nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			ok, err := Plus(nil, tt.x, tt.y, tt.z, func(env *Env) *Promise {
+				for k, v := range tt.env {
+					assert.Equal(t, v, env.Resolve(k))
+				}
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
 func TestLength(t *testing.T) {
 	t.Run("list is a list", func(t *testing.T) {
 		t.Run("length is a variable", func(t *testing.T) {
@@ -7831,6 +8758,60 @@ func TestNegation(t *testing.T) {
 	assert.Equal(t, e, err)
 }
 
+func TestForAll(t *testing.T) {
+	e := errors.New("failed")
+
+	var vm VM
+	vm.Register0(atomError, func(*VM, Cont, *Env) *Promise {
+		return Error(e)
+	})
+	vm.Register1(NewAtom("integer"), TypeInteger)
+	assert.NoError(t, vm.Compile(context.Background(), `
+p(1).
+p(2).
+p(a).
+q(1).
+q(2).
+`))
+
+	x := NewVariable()
+
+	t.Run("every solution", func(t *testing.T) {
+		ok, err := ForAll(&vm, NewAtom("q").Apply(x), NewAtom("integer").Apply(x), func(env *Env) *Promise {
+			assert.Equal(t, x, env.Resolve(x))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("counterexample", func(t *testing.T) {
+		ok, err := ForAll(&vm, NewAtom("p").Apply(x), NewAtom("integer").Apply(x), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("no solutions", func(t *testing.T) {
+		ok, err := ForAll(&vm, NewAtom("p").Apply(NewAtom("b")), atomError, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("cut", func(t *testing.T) {
+		ok, err := ForAll(&vm, atomCut, NewAtom("q").Apply(Integer(1)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := ForAll(&vm, NewAtom("q").Apply(x), atomError, Success, nil).Force(context.Background())
+		assert.Equal(t, e, err)
+
+		_, err = ForAll(&vm, x, atomError, Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+	})
+}
+
 func TestAppend(t *testing.T) {
 	xs, ys, zs := NewVariable(), NewVariable(), NewVariable()
 	tests := []struct {
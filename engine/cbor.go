@@ -0,0 +1,650 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"slices"
+
+	"github.com/cockroachdb/apd/v3"
+)
+
+// Terms are serialized in CBOR (RFC 8949) with the core deterministic encoding requirements: the arguments are in
+// their shortest forms, the lengths are definite, and the keys of the maps are sorted by their encodings.
+//
+//	integer	integer
+//	float	decimal fraction, tag 4 [Exponent, Mantissa] where Mantissa may be a bignum, tag 2 or 3, without trailing zeros
+//	rational	rational number, tag 30 [Numerator, Denominator]
+//	atom	identifier, tag 39 with the text of the name
+//	string	text string
+//	variable	identifier, tag 39 with the number of the variable in the order of their first occurrences
+//	list	array of the elements, [] being the empty array
+//	dict	generic object, tag 27 ["dict", Tag, Map] where Tag is a variable or an atom and Map maps the keys to the values
+//	compound	generic object, tag 27 [Name, Arg1, ..., ArgN]
+//
+// So, the variants of a term have the same encoding, and so do the floats which compare equal, e.g. 1.0 and 1.00.
+// Cyclic terms have no encoding.
+
+const (
+	cborUint        = 0
+	cborNegInt      = 1
+	cborByteString  = 2
+	cborTextString  = 3
+	cborArray       = 4
+	cborMap         = 5
+	cborTag         = 6
+	cborTagBignum   = 2
+	cborTagNegBig   = 3
+	cborTagDecimal  = 4
+	cborTagObject   = 27
+	cborTagRational = 30
+	cborTagIdent    = 39
+)
+
+// cborMaxNesting is the maximum depth of the decoded items so that a malicious input can't exhaust the stack.
+const cborMaxNesting = 1 << 10
+
+var (
+	errCBORMalformed  = errors.New("malformed CBOR")
+	errCBORTrailing   = errors.New("trailing data after CBOR item")
+	errCBORNotCanonic = errors.New("non-deterministic CBOR")
+)
+
+// EncodeTerm returns the deterministic CBOR encoding of t.
+func EncodeTerm(t Term, env *Env) ([]byte, error) {
+	e := cborEncoder{vars: map[Variable]uint64{}, visiting: map[termID]struct{}{}, env: env}
+	if err := e.term(t); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// DecodeTerm returns the term encoded in CBOR by EncodeTerm. The variables are fresh.
+// It rejects the encodings that EncodeTerm wouldn't produce so that a term has exactly one encoding.
+func DecodeTerm(b []byte) (Term, error) {
+	d := cborDecoder{data: b}
+	t, err := d.term(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, errCBORTrailing
+	}
+	return t, nil
+}
+
+type cborEncoder struct {
+	buf      bytes.Buffer
+	vars     map[Variable]uint64
+	visiting map[termID]struct{} // the compounds enclosing the term being encoded.
+	env      *Env
+}
+
+func (e *cborEncoder) head(major byte, n uint64) {
+	switch {
+	case n < 24:
+		_ = e.buf.WriteByte(major<<5 | byte(n))
+	case n <= math.MaxUint8:
+		_, _ = e.buf.Write([]byte{major<<5 | 24, byte(n)})
+	case n <= math.MaxUint16:
+		_ = e.buf.WriteByte(major<<5 | 25)
+		_, _ = e.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= math.MaxUint32:
+		_ = e.buf.WriteByte(major<<5 | 26)
+		_, _ = e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		_ = e.buf.WriteByte(major<<5 | 27)
+		_, _ = e.buf.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
+
+func (e *cborEncoder) int(i int64) {
+	if i < 0 {
+		e.head(cborNegInt, uint64(-(i + 1)))
+		return
+	}
+	e.head(cborUint, uint64(i))
+}
+
+func (e *cborEncoder) text(s string) {
+	e.head(cborTextString, uint64(len(s)))
+	_, _ = e.buf.WriteString(s)
+}
+
+func (e *cborEncoder) term(t Term) error {
+	t = e.env.Resolve(t)
+	if _, ok := t.(Compound); ok {
+		if _, ok := e.visiting[id(t)]; ok {
+			return domainError(validDomainAcyclicTerm, t, e.env)
+		}
+		e.visiting[id(t)] = struct{}{}
+		defer delete(e.visiting, id(t))
+	}
+
+	switch t := t.(type) {
+	case Variable:
+		n, ok := e.vars[t]
+		if !ok {
+			n = uint64(len(e.vars))
+			e.vars[t] = n
+		}
+		e.head(cborTag, cborTagIdent)
+		e.head(cborUint, n)
+	case Integer:
+		e.int(int64(t))
+	case Float:
+		e.float(t)
+	case Rational:
+		e.head(cborTag, cborTagRational)
+		e.head(cborArray, 2)
+		e.int(int64(t.num))
+		e.int(int64(t.den))
+	case Atom:
+		if t == atomEmptyList {
+			e.head(cborArray, 0)
+			break
+		}
+		e.head(cborTag, cborTagIdent)
+		e.text(t.String())
+	case String:
+		e.text(string(t))
+	case Dict:
+		switch tag := e.env.Resolve(t.Tag()).(type) {
+		case Variable, Atom:
+			break
+		default:
+			return typeError(validTypeCBOR, tag, e.env)
+		}
+		e.head(cborTag, cborTagObject)
+		e.head(cborArray, 3)
+		e.text(atomDict.String())
+		if err := e.term(t.Tag()); err != nil {
+			return err
+		}
+		return e.dict(t)
+	case Compound:
+		if t.Functor() == atomDot && t.Arity() == 2 {
+			if ok, err := e.list(t); ok || err != nil {
+				return err
+			}
+		}
+		e.head(cborTag, cborTagObject)
+		e.head(cborArray, uint64(t.Arity())+1)
+		e.text(t.Functor().String())
+		for i := 0; i < t.Arity(); i++ {
+			if err := e.term(t.Arg(i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return typeError(validTypeCBOR, t, e.env)
+	}
+	return nil
+}
+
+// float encodes f without the trailing zeros of its mantissa so that the floats which compare equal have the same
+// encoding.
+func (e *cborEncoder) float(f Float) {
+	var dec apd.Decimal
+	dec.Reduce(f.dec)
+	if dec.IsZero() {
+		dec.Negative = false
+	}
+
+	e.head(cborTag, cborTagDecimal)
+	e.head(cborArray, 2)
+	e.int(int64(dec.Exponent))
+	if dec.Coeff.IsInt64() {
+		m := dec.Coeff.Int64()
+		if dec.Negative {
+			m = -m
+		}
+		e.int(m)
+		return
+	}
+	m := dec.Coeff.MathBigInt()
+	if dec.Negative {
+		e.head(cborTag, cborTagNegBig)
+		m.Sub(m, big.NewInt(1))
+	} else {
+		e.head(cborTag, cborTagBignum)
+	}
+	b := m.Bytes()
+	e.head(cborByteString, uint64(len(b)))
+	_, _ = e.buf.Write(b)
+}
+
+// list encodes t as an array if it's a proper list.
+func (e *cborEncoder) list(t Term) (bool, error) {
+	var elems []Term
+	iter := ListIterator{List: t, Env: e.env}
+	for iter.Next() {
+		elems = append(elems, iter.Current())
+	}
+	if iter.Err() != nil {
+		return false, nil
+	}
+	e.head(cborArray, uint64(len(elems)))
+	for _, elem := range elems {
+		if err := e.term(elem); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// dict encodes the pairs of d in the order of the encodings of their keys. The values are encoded in the same order so
+// that the variables are numbered in the order the decoder meets them.
+func (e *cborEncoder) dict(d Dict) error {
+	type entry struct {
+		key   []byte
+		value Term
+	}
+	entries := make([]entry, 0, d.Len())
+	for k, v := range d.All() {
+		ke := cborEncoder{env: e.env}
+		_ = ke.term(k)
+		entries = append(entries, entry{key: ke.buf.Bytes(), value: v})
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		return bytes.Compare(a.key, b.key)
+	})
+	e.head(cborMap, uint64(len(entries)))
+	for _, en := range entries {
+		_, _ = e.buf.Write(en.key)
+		if err := e.term(en.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+	vars []Variable
+}
+
+// head reads the major type and the argument of the next item and checks that the argument is in its shortest form.
+func (d *cborDecoder) head() (byte, uint64, error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, errCBORMalformed
+	}
+	b := d.data[d.pos]
+	d.pos++
+	major, info := b>>5, b&0x1f
+	var (
+		n   uint64
+		min uint64
+	)
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		if d.pos+1 > len(d.data) {
+			return 0, 0, errCBORMalformed
+		}
+		n, min = uint64(d.data[d.pos]), 24
+		d.pos++
+	case info == 25:
+		if d.pos+2 > len(d.data) {
+			return 0, 0, errCBORMalformed
+		}
+		n, min = uint64(binary.BigEndian.Uint16(d.data[d.pos:])), math.MaxUint8+1
+		d.pos += 2
+	case info == 26:
+		if d.pos+4 > len(d.data) {
+			return 0, 0, errCBORMalformed
+		}
+		n, min = uint64(binary.BigEndian.Uint32(d.data[d.pos:])), math.MaxUint16+1
+		d.pos += 4
+	case info == 27:
+		if d.pos+8 > len(d.data) {
+			return 0, 0, errCBORMalformed
+		}
+		n, min = binary.BigEndian.Uint64(d.data[d.pos:]), math.MaxUint32+1
+		d.pos += 8
+	default:
+		return 0, 0, errCBORMalformed
+	}
+	if n < min {
+		return 0, 0, errCBORNotCanonic
+	}
+	return major, n, nil
+}
+
+func (d *cborDecoder) int() (int64, error) {
+	major, n, err := d.head()
+	if err != nil {
+		return 0, err
+	}
+	return d.intOf(major, n)
+}
+
+func (d *cborDecoder) intOf(major byte, n uint64) (int64, error) {
+	if n > math.MaxInt64 {
+		return 0, errCBORMalformed
+	}
+	switch major {
+	case cborUint:
+		return int64(n), nil
+	case cborNegInt:
+		return -1 - int64(n), nil
+	default:
+		return 0, errCBORMalformed
+	}
+}
+
+func (d *cborDecoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errCBORMalformed
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+func (d *cborDecoder) text() (string, error) {
+	major, n, err := d.head()
+	if err != nil {
+		return "", err
+	}
+	if major != cborTextString {
+		return "", errCBORMalformed
+	}
+	b, err := d.bytes(n)
+	return string(b), err
+}
+
+// length returns n if there're at least n more bytes since every item takes at least one.
+func (d *cborDecoder) length(n uint64) (int, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return 0, errCBORMalformed
+	}
+	return int(n), nil
+}
+
+func (d *cborDecoder) term(depth int) (Term, error) {
+	if depth > cborMaxNesting {
+		return nil, errCBORMalformed
+	}
+	major, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint, cborNegInt:
+		i, err := d.intOf(major, n)
+		return Integer(i), err
+	case cborTextString:
+		b, err := d.bytes(n)
+		return String(b), err
+	case cborArray:
+		l, err := d.length(n)
+		if err != nil {
+			return nil, err
+		}
+		elems := make([]Term, l)
+		for i := range elems {
+			if elems[i], err = d.term(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return List(elems...), nil
+	case cborTag:
+		switch n {
+		case cborTagIdent:
+			return d.ident()
+		case cborTagDecimal:
+			return d.float()
+		case cborTagRational:
+			return d.rational()
+		case cborTagObject:
+			return d.object(depth)
+		}
+	}
+	return nil, errCBORMalformed
+}
+
+func (d *cborDecoder) ident() (Term, error) {
+	major, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborTextString:
+		b, err := d.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+		if a := NewAtom(string(b)); a != atomEmptyList {
+			return a, nil
+		}
+	case cborUint:
+		switch {
+		case n < uint64(len(d.vars)):
+			return d.vars[n], nil
+		case n == uint64(len(d.vars)):
+			v := NewVariable()
+			d.vars = append(d.vars, v)
+			return v, nil
+		}
+	}
+	return nil, errCBORMalformed
+}
+
+func (d *cborDecoder) float() (Term, error) {
+	if major, n, err := d.head(); err != nil || major != cborArray || n != 2 {
+		return nil, errCBORMalformed
+	}
+	exp, err := d.int()
+	if err != nil {
+		return nil, err
+	}
+	if exp < math.MinInt32 || exp > math.MaxInt32 {
+		return nil, errCBORMalformed
+	}
+
+	var dec apd.Decimal
+	dec.Exponent = int32(exp)
+	major, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint, cborNegInt:
+		m, err := d.intOf(major, n)
+		if err != nil {
+			return nil, err
+		}
+		if (m == 0 && exp != 0) || (m != 0 && m%10 == 0) {
+			return nil, errCBORNotCanonic
+		}
+		dec.SetFinite(m, int32(exp))
+	case cborTag:
+		if n != cborTagBignum && n != cborTagNegBig {
+			return nil, errCBORMalformed
+		}
+		bm, bn, err := d.head()
+		if err != nil || bm != cborByteString {
+			return nil, errCBORMalformed
+		}
+		b, err := d.bytes(bn)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) == 0 || b[0] == 0 {
+			return nil, errCBORNotCanonic
+		}
+		m := new(big.Int).SetBytes(b)
+		if n == cborTagNegBig {
+			m.Add(m, big.NewInt(1))
+			dec.Negative = true
+		}
+		if m.IsInt64() || new(big.Int).Mod(m, big.NewInt(10)).Sign() == 0 {
+			return nil, errCBORNotCanonic
+		}
+		dec.Coeff.SetMathBigInt(m)
+	default:
+		return nil, errCBORMalformed
+	}
+	return Float{dec: &dec}, nil
+}
+
+func (d *cborDecoder) rational() (Term, error) {
+	if major, n, err := d.head(); err != nil || major != cborArray || n != 2 {
+		return nil, errCBORMalformed
+	}
+	num, err := d.int()
+	if err != nil {
+		return nil, err
+	}
+	den, err := d.int()
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewRational(Integer(num), Integer(den))
+	if err != nil {
+		return nil, errCBORMalformed
+	}
+	if r, ok := r.(Rational); !ok || r.num != Integer(num) || r.den != Integer(den) {
+		return nil, errCBORNotCanonic
+	}
+	return r, nil
+}
+
+func (d *cborDecoder) object(depth int) (Term, error) {
+	major, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if major != cborArray || n < 2 {
+		return nil, errCBORMalformed
+	}
+	arity, err := d.length(n - 1)
+	if err != nil {
+		return nil, err
+	}
+	name, err := d.text()
+	if err != nil {
+		return nil, err
+	}
+	functor := NewAtom(name)
+
+	if functor == atomDict && arity == 2 {
+		tag, ok, err := d.dictTag()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return d.dict(tag, depth)
+		}
+	}
+
+	args := make([]Term, arity)
+	for i := range args {
+		if args[i], err = d.term(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	if functor == atomDot && arity == 2 {
+		iter := ListIterator{List: args[1]}
+		for iter.Next() {
+		}
+		if iter.Err() == nil {
+			return nil, errCBORNotCanonic // a proper list would have been an array.
+		}
+	}
+	return functor.Apply(args...), nil
+}
+
+// dictTag reads the tag of a dict if the next items are a variable or an atom followed by a map. Otherwise, it reads
+// nothing and returns false. The map of a dict can't be mistaken for an argument since maps only encode dicts, and
+// the tag is a single item so that it's read at most twice.
+func (d *cborDecoder) dictTag() (Term, bool, error) {
+	start, vars := d.pos, len(d.vars)
+	rewind := func() (Term, bool, error) {
+		d.pos, d.vars = start, d.vars[:vars]
+		return nil, false, nil
+	}
+
+	major, n, err := d.head()
+	if err != nil {
+		return nil, false, err
+	}
+	var tag Term
+	switch {
+	case major == cborArray && n == 0:
+		tag = atomEmptyList
+	case major == cborTag && n == cborTagIdent:
+		if tag, err = d.ident(); err != nil {
+			return nil, false, err
+		}
+	default:
+		return rewind()
+	}
+	if d.pos >= len(d.data) || d.data[d.pos]>>5 != cborMap {
+		return rewind()
+	}
+	return tag, true, nil
+}
+
+func (d *cborDecoder) dict(tag Term, depth int) (Term, error) {
+	_, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	l, err := d.length(n)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]Term, 0, 2*l+1)
+	args = append(args, tag)
+	var prev []byte
+	for i := 0; i < l; i++ {
+		start := d.pos
+		k, err := d.term(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key := d.data[start:d.pos]
+		if i > 0 && bytes.Compare(prev, key) >= 0 {
+			return nil, errCBORNotCanonic
+		}
+		prev = key
+		if _, ok := k.(Atom); !ok {
+			return nil, errCBORMalformed
+		}
+		v, err := d.term(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, k, v)
+	}
+	return NewDict(args)
+}
+
+// TermToCBOR succeeds iff bytes is the list of the bytes of the CBOR encoding of term.
+// If bytes is bound, it's decoded and term is unified with the decoded term. Otherwise, bytes is unified with the
+// encoding of term.
+func TermToCBOR(vm *VM, term, bytes Term, k Cont, env *Env) *Promise {
+	if _, ok := env.Resolve(bytes).(Variable); !ok {
+		b, err := bytesOf(bytes, env)
+		if err != nil {
+			return Error(err)
+		}
+		t, err := DecodeTerm(b)
+		if err != nil {
+			return Error(domainError(validDomainCBOR, bytes, env))
+		}
+		return Unify(vm, term, t, k, env)
+	}
+
+	b, err := EncodeTerm(term, env)
+	if err != nil {
+		return Error(err)
+	}
+	return Unify(vm, bytes, byteList(b), k, env)
+}
@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeTerm(t *testing.T) {
+	x, y := NewVariable(), NewVariable()
+	big, err := NewFloatFromString("123456789012345678901234567890.5")
+	assert.NoError(t, err)
+	negBig, err := NewFloatFromString("-9223372036854775808")
+	assert.NoError(t, err)
+	f, err := NewFloatFromString("-1.25")
+	assert.NoError(t, err)
+	r, err := NewRational(-1, 3)
+	assert.NoError(t, err)
+	d, err := NewDict([]Term{NewAtom("t"), NewAtom("b"), x, NewAtom("aa"), y})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		title string
+		term  Term
+		cbor  []byte
+	}{
+		{title: "integer", term: Integer(500), cbor: []byte{0x19, 0x01, 0xf4}},
+		{title: "negative integer", term: Integer(-1), cbor: []byte{0x20}},
+		{title: "atom", term: NewAtom("a"), cbor: []byte{0xd8, 0x27, 0x61, 'a'}},
+		{title: "string", term: String("a"), cbor: []byte{0x61, 'a'}},
+		{title: "empty list", term: atomEmptyList, cbor: []byte{0x80}},
+		{title: "list", term: List(Integer(1), Integer(2)), cbor: []byte{0x82, 0x01, 0x02}},
+		{title: "float", term: f, cbor: []byte{0xc4, 0x82, 0x21, 0x38, 0x7c}},
+		{title: "rational", term: r, cbor: []byte{0xd8, 0x1e, 0x82, 0x20, 0x03}},
+		{title: "compound", term: NewAtom("f").Apply(x, y, x), cbor: []byte{0xd8, 0x1b, 0x84, 0x61, 'f', 0xd8, 0x27, 0x00, 0xd8, 0x27, 0x01, 0xd8, 0x27, 0x00}},
+		{title: "partial list", term: PartialList(x, Integer(1)), cbor: []byte{0xd8, 0x1b, 0x83, 0x61, '.', 0x01, 0xd8, 0x27, 0x00}},
+		{title: "dict", term: d, cbor: []byte{
+			0xd8, 0x1b, 0x83, 0x64, 'd', 'i', 'c', 't', 0xd8, 0x27, 0x61, 't',
+			0xa2,
+			0xd8, 0x27, 0x61, 'b', 0xd8, 0x27, 0x00,
+			0xd8, 0x27, 0x62, 'a', 'a', 0xd8, 0x27, 0x01,
+		}},
+		{title: "bignum", term: big},
+		{title: "negative bignum", term: negBig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			b, err := EncodeTerm(tt.term, nil)
+			assert.NoError(t, err)
+			if tt.cbor != nil {
+				assert.Equal(t, tt.cbor, b)
+			}
+
+			d, err := DecodeTerm(b)
+			assert.NoError(t, err)
+			assert.Equal(t, variantKey(tt.term, nil), variantKey(d, nil))
+			again, err := EncodeTerm(d, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, b, again)
+		})
+	}
+
+	t.Run("variants", func(t *testing.T) {
+		a, err := EncodeTerm(NewAtom("f").Apply(x, y), nil)
+		assert.NoError(t, err)
+		b, err := EncodeTerm(NewAtom("f").Apply(NewVariable(), NewVariable()), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, a, b)
+	})
+
+	t.Run("bindings", func(t *testing.T) {
+		env := NewEnv().bind(x, NewAtom("a"))
+		b, err := EncodeTerm(x, env)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0xd8, 0x27, 0x61, 'a'}, b)
+	})
+
+	t.Run("not serializable", func(t *testing.T) {
+		s := &Stream{}
+		_, err := EncodeTerm(List(s), nil)
+		assert.Equal(t, typeError(validTypeCBOR, s, nil), err)
+	})
+
+	t.Run("equal floats", func(t *testing.T) {
+		for _, s := range []string{"1.0", "1.00", "100.0", "0.0", "-0.0"} {
+			f, err := NewFloatFromString(s)
+			assert.NoError(t, err)
+			g, err := NewFloatFromString(s + "0")
+			assert.NoError(t, err)
+			a, err := EncodeTerm(f, nil)
+			assert.NoError(t, err)
+			b, err := EncodeTerm(g, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, a, b, s)
+		}
+	})
+
+	t.Run("cyclic", func(t *testing.T) {
+		x := NewVariable()
+		f := NewAtom("f").Apply(x)
+		env := NewEnv().bind(x, f)
+		_, err := EncodeTerm(f, env)
+		assert.Equal(t, domainError(validDomainAcyclicTerm, f, env), err)
+	})
+
+	t.Run("dict with a compound tag", func(t *testing.T) {
+		tag := NewAtom("f").Apply(Integer(1))
+		d, err := NewDict([]Term{tag})
+		assert.NoError(t, err)
+		_, err = EncodeTerm(d, nil)
+		assert.Equal(t, typeError(validTypeCBOR, tag, nil), err)
+	})
+}
+
+func TestDecodeTerm(t *testing.T) {
+	tests := []struct {
+		title string
+		cbor  []byte
+		err   error
+	}{
+		{title: "empty", cbor: nil, err: errCBORMalformed},
+		{title: "truncated", cbor: []byte{0x82, 0x01}, err: errCBORMalformed},
+		{title: "trailing", cbor: []byte{0x01, 0x01}, err: errCBORTrailing},
+		{title: "long form", cbor: []byte{0x18, 0x01}, err: errCBORNotCanonic},
+		{title: "indefinite length", cbor: []byte{0x9f, 0xff}, err: errCBORMalformed},
+		{title: "huge length", cbor: []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, err: errCBORMalformed},
+		{title: "byte string", cbor: []byte{0x41, 0x00}, err: errCBORMalformed},
+		{title: "unknown variable", cbor: []byte{0xd8, 0x27, 0x01}, err: errCBORMalformed},
+		{title: "empty list atom", cbor: []byte{0xd8, 0x27, 0x62, '[', ']'}, err: errCBORMalformed},
+		{title: "list as compound", cbor: []byte{0xd8, 0x1b, 0x83, 0x61, '.', 0x01, 0x80}, err: errCBORNotCanonic},
+		{title: "unsorted keys", cbor: []byte{
+			0xd8, 0x1b, 0x83, 0x64, 'd', 'i', 'c', 't', 0xd8, 0x27, 0x00,
+			0xa2,
+			0xd8, 0x27, 0x61, 'b', 0x01,
+			0xd8, 0x27, 0x61, 'a', 0x02,
+		}, err: errCBORNotCanonic},
+		{title: "rational not in canonical form", cbor: []byte{0xd8, 0x1e, 0x82, 0x02, 0x04}, err: errCBORNotCanonic},
+		{title: "small bignum", cbor: []byte{0xc4, 0x82, 0x00, 0xc2, 0x41, 0x01}, err: errCBORNotCanonic},
+		{title: "trailing zero", cbor: []byte{0xc4, 0x82, 0x20, 0x0a}, err: errCBORNotCanonic},
+		{title: "zero with exponent", cbor: []byte{0xc4, 0x82, 0x20, 0x00}, err: errCBORNotCanonic},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			_, err := DecodeTerm(tt.cbor)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+
+	t.Run("too deep", func(t *testing.T) {
+		b := make([]byte, cborMaxNesting+2)
+		for i := range b {
+			b[i] = 0x81
+		}
+		b[len(b)-1] = 0x01
+		_, err := DecodeTerm(b)
+		assert.Equal(t, errCBORMalformed, err)
+	})
+
+	t.Run("nested dict compounds", func(t *testing.T) {
+		// dict(dict(...(dict(1, 1)...), 1), 1) whose first arguments could be taken for the tags of dicts.
+		var b []byte
+		const n = 64
+		for i := 0; i < n; i++ {
+			b = append(b, 0xd8, 0x1b, 0x83, 0x64, 'd', 'i', 'c', 't')
+		}
+		b = append(b, 0x01)
+		for i := 0; i < n; i++ {
+			b = append(b, 0x01)
+		}
+		d, err := DecodeTerm(b)
+		assert.NoError(t, err)
+		again, err := EncodeTerm(d, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, b, again)
+	})
+}
+
+func TestTermToCBOR(t *testing.T) {
+	t.Run("encode", func(t *testing.T) {
+		v := NewVariable()
+		ok, err := TermToCBOR(nil, NewAtom("a"), v, func(env *Env) *Promise {
+			assert.Equal(t, List(Integer(0xd8), Integer(0x27), Integer(0x61), Integer('a')), env.Resolve(v))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		ok, err := TermToCBOR(nil, String("a"), List(Integer(0x61), Integer('a')), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		l := List(Integer(0x18), Integer(0x01))
+		_, err := TermToCBOR(nil, NewVariable(), l, Success, nil).Force(context.Background())
+		assert.Equal(t, domainError(validDomainCBOR, l, nil), err)
+	})
+}
@@ -3,6 +3,7 @@ package engine
 import (
 	"context"
 	"errors"
+	"slices"
 )
 
 type userDefined struct {
@@ -10,24 +11,50 @@ type userDefined struct {
 	dynamic       bool
 	multifile     bool
 	discontiguous bool
+	tabled        bool
+	builtIn       bool        // defined by a bootstrap library. See MarkBuiltIn.
+	modes         []tableMode // the modes of the arguments if the answers are aggregated, e.g. path(_, _, min).
 
 	// 7.4.3 says "If no clauses are defined for a procedure indicated by a directive ... then the procedure shall exist but have no clauses."
 	clauses
+	retired  int    // the number of retracted clauses still in clauses.
+	storeGen uint64 // the generation of the clause store which has the clauses, if any.
+
+	index      clauseIndex[Term] // on the first argument.
+	argIndexes []*argIndex       // declared by index/1.
+}
+
+func (u *userDefined) call(vm *VM, args []Term, k Cont, env *Env) *Promise {
+	if u.tabled {
+		return vm.callTabled(u, args, k, env)
+	}
+	return u.candidates(args, env).call(vm, args, k, env)
 }
 
-type clauses []clause
+type clauses []*clause
 
 func (cs clauses) call(vm *VM, args []Term, k Cont, env *Env) *Promise {
+	// The logical update view: the clauses retracted after the call are still tried.
+	gen := vm.generation
 	var p *Promise
 	ks := make([]func(context.Context) *Promise, len(cs))
 	for i := range cs {
 		i, c := i, cs[i]
-		ks[i] = func(context.Context) *Promise {
+		ks[i] = func(ctx context.Context) *Promise {
+			if !c.live(gen) {
+				return Bool(false)
+			}
+			if vm.slicing != nil {
+				vm.slicing.entered(c)
+			}
+			if vm.covering {
+				vm.coverage.tried(c)
+			}
 			vars := make([]Variable, len(c.vars))
 			for i := range vars {
 				vars[i] = NewVariable()
 			}
-			return vm.exec(c.bytecode, vars, k, args, nil, env, p)
+			return vm.exec(c.bytecode, vars, k, args, nil, vm.enterClause(c, args, vars, env), p)
 		}
 	}
 	p = Delay(ks...)
@@ -46,21 +73,37 @@ func compile(t Term, env *Env) (clauses, error) {
 				return nil, typeError(validTypeCallable, body, env)
 			}
 			c.raw = t
-			cs = append(cs, c)
+			c.alt = len(cs)
+			cs = append(cs, &c)
 		}
 		return cs, nil
 	}
 
 	c, err := compileClause(t, nil, env)
 	c.raw = env.simplify(t)
-	return []clause{c}, err
+	return clauses{&c}, err
 }
 
 type clause struct {
 	pi       procedureIndicator
 	raw      Term
+	alt      int // the index of the clause among the ones compiled from the alternatives of the body of raw.
 	vars     []Variable
 	bytecode bytecode
+
+	// file and line are the position of the clause in the Prolog text it's read from, if any.
+	file string
+	line int
+
+	storeKey int64 // the key of the clause in the clause store, if it's stored.
+
+	// erased is the generation of the database in which the clause was retracted, or 0 if it's not retracted.
+	erased uint64
+}
+
+// live reports whether the clause is visible to the calls made in the generation gen of the database.
+func (c *clause) live(gen uint64) bool {
+	return c.erased == 0 || c.erased > gen
 }
 
 func compileClause(head Term, body Term, env *Env) (clause, error) {
@@ -116,18 +159,32 @@ func desugarBody(body Term, env *Env) Term {
 }
 
 func desugarPred(term Term, acc []Term, env *Env) (Term, []Term) {
+	return desugarTerm(term, acc, nil, env)
+}
+
+// desugarTerm desugars term. path is the bound variables whose values are being desugared. If term is one of them,
+// term is cyclic and it's kept as is.
+func desugarTerm(term Term, acc []Term, path []Variable, env *Env) (Term, []Term) {
+	if v, ok := term.(Variable); ok {
+		if slices.Contains(path, v) {
+			return v, acc
+		}
+		if _, ok := env.lookup(v); ok {
+			path = append(path, v)
+		}
+	}
 	switch t := env.Resolve(term).(type) {
 	case charList, codeList:
 		return t, acc
 	case list:
 		l := make(list, len(t))
 		for i, e := range t {
-			l[i], acc = desugarPred(e, acc, env)
+			l[i], acc = desugarTerm(e, acc, path, env)
 		}
 		return l, acc
 	case *partial:
-		c, acc := desugarPred(t.Compound, acc, env)
-		tail, acc := desugarPred(*t.tail, acc, env)
+		c, acc := desugarTerm(t.Compound, acc, path, env)
+		tail, acc := desugarTerm(*t.tail, acc, path, env)
 		return &partial{
 			Compound: c.(Compound),
 			tail:     &tail,
@@ -135,8 +192,8 @@ func desugarPred(term Term, acc []Term, env *Env) (Term, []Term) {
 	case Compound:
 		if t.Functor() == atomSpecialDot && t.Arity() == 2 {
 			tempV := NewVariable()
-			lhs, acc := desugarPred(t.Arg(0), acc, env)
-			rhs, acc := desugarPred(t.Arg(1), acc, env)
+			lhs, acc := desugarTerm(t.Arg(0), acc, path, env)
+			rhs, acc := desugarTerm(t.Arg(1), acc, path, env)
 
 			return tempV, append(acc, atomDot.Apply(lhs, rhs, tempV))
 		}
@@ -146,7 +203,7 @@ func desugarPred(term Term, acc []Term, env *Env) (Term, []Term) {
 			args:    make([]Term, t.Arity()),
 		}
 		for i := 0; i < t.Arity(); i++ {
-			c.args[i], acc = desugarPred(t.Arg(i), acc, env)
+			c.args[i], acc = desugarTerm(t.Arg(i), acc, path, env)
 		}
 
 		if _, ok := t.(Dict); ok {
@@ -170,7 +227,7 @@ func (c *clause) compileHead(head Term, env *Env) {
 	case Compound:
 		c.pi = procedureIndicator{name: head.Functor(), arity: Integer(head.Arity())}
 		for i := 0; i < head.Arity(); i++ {
-			c.compileHeadArg(head.Arg(i), env)
+			c.compileHeadArg(head.Arg(i), nil, env)
 		}
 	}
 }
@@ -202,7 +259,7 @@ func (c *clause) compilePred(p Term, env *Env) error {
 		return nil
 	case Compound:
 		for i := 0; i < p.Arity(); i++ {
-			c.compileBodyArg(p.Arg(i), env)
+			c.compileBodyArg(p.Arg(i), nil, env)
 		}
 		c.emit(instruction{opcode: OpCall, operand: procedureIndicator{name: p.Functor(), arity: Integer(p.Arity())}})
 		return nil
@@ -211,7 +268,17 @@ func (c *clause) compilePred(p Term, env *Env) error {
 	}
 }
 
-func (c *clause) compileHeadArg(a Term, env *Env) {
+func (c *clause) compileHeadArg(a Term, path []Variable, env *Env) {
+	if v, ok := a.(Variable); ok {
+		if slices.Contains(path, v) {
+			// a is cyclic. Since a is bound, it's the same in the environment where the clause is executed.
+			c.emit(instruction{opcode: OpGetConst, operand: v})
+			return
+		}
+		if _, ok := env.lookup(v); ok {
+			path = append(path, v)
+		}
+	}
 	switch a := env.Resolve(a).(type) {
 	case Variable:
 		c.emit(instruction{opcode: OpGetVar, operand: c.varOffset(a)})
@@ -220,15 +287,15 @@ func (c *clause) compileHeadArg(a Term, env *Env) {
 	case list:
 		c.emit(instruction{opcode: OpGetList, operand: Integer(len(a))})
 		for _, arg := range a {
-			c.compileHeadArg(arg, env)
+			c.compileHeadArg(arg, path, env)
 		}
 		c.emit(instruction{opcode: OpPop})
 	case *partial:
 		prefix := a.Compound.(list)
 		c.emit(instruction{opcode: OpGetPartial, operand: Integer(len(prefix))})
-		c.compileHeadArg(*a.tail, env)
+		c.compileHeadArg(*a.tail, path, env)
 		for _, arg := range prefix {
-			c.compileHeadArg(arg, env)
+			c.compileHeadArg(arg, path, env)
 		}
 		c.emit(instruction{opcode: OpPop})
 	case Compound:
@@ -240,7 +307,7 @@ func (c *clause) compileHeadArg(a Term, env *Env) {
 		}
 
 		for i := 0; i < a.Arity(); i++ {
-			c.compileHeadArg(a.Arg(i), env)
+			c.compileHeadArg(a.Arg(i), path, env)
 		}
 		c.emit(instruction{opcode: OpPop})
 	default:
@@ -248,7 +315,17 @@ func (c *clause) compileHeadArg(a Term, env *Env) {
 	}
 }
 
-func (c *clause) compileBodyArg(a Term, env *Env) {
+func (c *clause) compileBodyArg(a Term, path []Variable, env *Env) {
+	if v, ok := a.(Variable); ok {
+		if slices.Contains(path, v) {
+			// a is cyclic. Since a is bound, it's the same in the environment where the clause is executed.
+			c.emit(instruction{opcode: OpPutConst, operand: v})
+			return
+		}
+		if _, ok := env.lookup(v); ok {
+			path = append(path, v)
+		}
+	}
 	switch a := env.Resolve(a).(type) {
 	case Variable:
 		c.emit(instruction{opcode: OpPutVar, operand: c.varOffset(a)})
@@ -257,13 +334,13 @@ func (c *clause) compileBodyArg(a Term, env *Env) {
 	case list:
 		c.emit(instruction{opcode: OpPutList, operand: Integer(len(a))})
 		for _, arg := range a {
-			c.compileBodyArg(arg, env)
+			c.compileBodyArg(arg, path, env)
 		}
 		c.emit(instruction{opcode: OpPop})
 	case Dict:
 		c.emit(instruction{opcode: OpPutDict, operand: Integer(a.Arity())})
 		for i := 0; i < a.Arity(); i++ {
-			c.compileBodyArg(a.Arg(i), env)
+			c.compileBodyArg(a.Arg(i), path, env)
 		}
 		c.emit(instruction{opcode: OpPop})
 	case *partial:
@@ -273,10 +350,10 @@ func (c *clause) compileBodyArg(a Term, env *Env) {
 			l++
 		}
 		c.emit(instruction{opcode: OpPutPartial, operand: Integer(l)})
-		c.compileBodyArg(*a.tail, env)
+		c.compileBodyArg(*a.tail, path, env)
 		iter = ListIterator{List: a.Compound}
 		for iter.Next() {
-			c.compileBodyArg(iter.Current(), env)
+			c.compileBodyArg(iter.Current(), path, env)
 		}
 		c.emit(instruction{opcode: OpPop})
 	case Compound:
@@ -287,7 +364,7 @@ func (c *clause) compileBodyArg(a Term, env *Env) {
 			c.emit(instruction{opcode: OpPutFunctor, operand: procedureIndicator{name: a.Functor(), arity: Integer(a.Arity())}})
 		}
 		for i := 0; i < a.Arity(); i++ {
-			c.compileBodyArg(a.Arg(i), env)
+			c.compileBodyArg(a.Arg(i), path, env)
 		}
 		c.emit(instruction{opcode: OpPop})
 	default:
@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync/atomic"
+)
+
+var clauseRefIDCounter uint64
+
+// clauseRef is a reference to the clauses added by an assertion, or to a clause loaded from a Prolog text.
+// It's valid only in the VM it's obtained from.
+type clauseRef struct {
+	id      uint64
+	pi      procedureIndicator
+	u       *userDefined
+	clauses clauses
+}
+
+// refOf returns the reference to the clauses cs of the procedure pi defined by u.
+// The references are kept by the VM rather than by the clauses since the clones of a frozen VM share the clauses.
+func (vm *VM) refOf(pi procedureIndicator, u *userDefined, cs clauses) *clauseRef {
+	if len(cs) > 0 {
+		if r, ok := vm.clauseRefs[cs[0]]; ok {
+			return r
+		}
+	}
+	r := clauseRef{id: atomic.AddUint64(&clauseRefIDCounter, 1), pi: pi, u: u, clauses: cs}
+	if vm.clauseRefs == nil {
+		vm.clauseRefs = map[*clause]*clauseRef{}
+	}
+	for _, c := range cs {
+		vm.clauseRefs[c] = &r
+	}
+	return &r
+}
+
+// WriteTerm outputs the clauseRef to an io.Writer.
+func (r *clauseRef) WriteTerm(w io.Writer, _ *WriteOptions, _ *Env) error {
+	_, err := fmt.Fprintf(w, "<clause>(0x%x)", r.id)
+	return err
+}
+
+// Compare compares the clauseRef with a Term.
+func (r *clauseRef) Compare(t Term, env *Env) int {
+	return CompareAtomic[*clauseRef](r, t, func(r *clauseRef, s *clauseRef) int {
+		switch {
+		case r.id > s.id:
+			return 1
+		case r.id < s.id:
+			return -1
+		default:
+			return 0
+		}
+	}, env)
+}
+
+// Asserta2 is like Asserta but also unifies ref with the reference to the added clauses.
+func Asserta2(vm *VM, t, ref Term, k Cont, env *Env) *Promise {
+	return assertRef(vm, atomAsserta, t, ref, true, k, env)
+}
+
+// Assertz2 is like Assertz but also unifies ref with the reference to the added clauses.
+func Assertz2(vm *VM, t, ref Term, k Cont, env *Env) *Promise {
+	return assertRef(vm, atomAssertz, t, ref, false, k, env)
+}
+
+func assertRef(vm *VM, name Atom, t, ref Term, front bool, k Cont, env *Env) *Promise {
+	if _, ok := env.Resolve(ref).(Variable); !ok {
+		return Error(UninstantiationError(ref, env))
+	}
+	pi, added, err := assertMerge(vm, t, front, env)
+	if err != nil {
+		return Error(err)
+	}
+	vm.record(name, []Term{t}, env)
+	p, _ := vm.getProcedure(pi)
+	return Unify(vm, ref, vm.refOf(pi, p.(*userDefined), added), k, env)
+}
+
+// Clause3 is like Clause but also unifies ref with the reference to the clause. If ref is a reference, it unifies
+// head and body with the clause it references in constant time.
+func Clause3(vm *VM, head, body, ref Term, k Cont, env *Env) *Promise {
+	switch r := env.Resolve(ref).(type) {
+	case Variable:
+		break
+	case *clauseRef:
+		var c *clause
+		if p, ok := vm.getProcedure(r.pi); ok && p == procedure(r.u) {
+			c = r.live()
+		}
+		if c == nil {
+			return Bool(false)
+		}
+		cp, err := renamedCopy(c.raw, nil, env)
+		if err != nil {
+			return Error(err)
+		}
+		return Unify(vm, atomIf.Apply(head, body), rulify(cp, env), k, env)
+	default:
+		return Error(typeError(validTypeDBReference, ref, env))
+	}
+
+	return clauseOf(vm, head, body, ref, k, env)
+}
+
+// Erase removes the clauses referenced by ref in constant time unless the VM has a clause store or journals, or the
+// record referenced by ref. It fails if they're already removed.
+func Erase(vm *VM, ref Term, k Cont, env *Env) *Promise {
+	var r *clauseRef
+	switch ref := env.Resolve(ref).(type) {
+	case Variable:
+		return Error(InstantiationError(env))
+	case *clauseRef:
+		r = ref
+	case *recordRef:
+		return vm.eraseRecord(ref, k, env)
+	default:
+		return Error(typeError(validTypeDBReference, ref, env))
+	}
+
+	if err := vm.checkFrozen(permissionTypeStaticProcedure, r.pi.Term(), env); err != nil {
+		return Error(err)
+	}
+
+	p, ok := vm.getProcedure(r.pi)
+	if !ok {
+		return Bool(false)
+	}
+	u, ok := p.(*userDefined)
+	if !ok || !u.dynamic {
+		return Error(permissionError(operationModify, permissionTypeStaticProcedure, r.pi.Term(), env))
+	}
+
+	// The reference is obtained from another VM, e.g. the original of a clone, or the procedure was abolished.
+	if u != r.u || r.live() == nil {
+		return Bool(false)
+	}
+	if err := vm.erase(r.pi, u, r.clauses); err != nil {
+		return Error(err)
+	}
+	return k(env)
+}
+
+// eraseNth removes the n-th clause, from 1, of the dynamic procedure of clause if it's a variant of clause.
+// It's how the journal redoes erase/1 since a reference doesn't survive a replay while the clause at the same position
+// is the one it referenced.
+func eraseNth(vm *VM, clause, n Term, k Cont, env *Env) *Promise {
+	c, ok := env.Resolve(clause).(Compound)
+	if !ok || c.Functor() != atomIf || c.Arity() != 2 {
+		return Error(typeError(validTypeCallable, clause, env))
+	}
+	i, ok := env.Resolve(n).(Integer)
+	if !ok {
+		return Error(typeError(validTypeInteger, n, env))
+	}
+	pi, _, err := piArg(c.Arg(0), env)
+	if err != nil {
+		return Error(err)
+	}
+	if err := vm.checkFrozen(permissionTypeStaticProcedure, pi.Term(), env); err != nil {
+		return Error(err)
+	}
+
+	p, ok := vm.getProcedure(pi)
+	if !ok {
+		return Bool(false)
+	}
+	u, ok := p.(*userDefined)
+	if !ok || !u.dynamic {
+		return Error(permissionError(operationModify, permissionTypeStaticProcedure, pi.Term(), env))
+	}
+	for _, e := range u.clauses {
+		if e.erased != 0 {
+			continue
+		}
+		if i--; i > 0 {
+			continue
+		}
+		if !variant(rulify(e.raw, nil), c, env) {
+			return Bool(false)
+		}
+		if err := vm.erase(pi, u, clauses{e}); err != nil {
+			return Error(err)
+		}
+		return k(env)
+	}
+	return Bool(false)
+}
+
+// erase removes the clauses cs of the procedure pi defined by u and journals them by their positions among the
+// clauses of u if the VM is journaling.
+func (vm *VM) erase(pi procedureIndicator, u *userDefined, cs clauses) error {
+	cs = slices.DeleteFunc(slices.Clone(cs), func(c *clause) bool {
+		return c.erased != 0
+	})
+	if err := vm.store(pi, u, clauseChange{removed: cs}); err != nil {
+		return err
+	}
+	for _, c := range cs {
+		if !vm.journaling {
+			vm.retire(u, c)
+			continue
+		}
+		n := 1 // Finding the position takes linear time, so it's done only for the journal.
+		for _, e := range u.clauses {
+			if e == c {
+				break
+			}
+			if e.erased == 0 {
+				n++
+			}
+		}
+		vm.retire(u, c)
+		vm.record(atomErase, []Term{rulify(c.raw, nil), Integer(n)}, nil)
+	}
+	return nil
+}
+
+// live returns the first clause referenced by r which is not removed, or nil if there's no such clause.
+func (r *clauseRef) live() *clause {
+	for _, c := range r.clauses {
+		if c.erased == 0 {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClauseRef(t *testing.T) {
+	foo := NewAtom("foo")
+	newVM := func() (*VM, []Term) {
+		var (
+			vm   VM
+			refs []Term
+		)
+		for _, c := range []Term{
+			foo.Apply(Integer(1)),
+			foo.Apply(Integer(2)),
+			atomIf.Apply(foo.Apply(Integer(3)), atomSemiColon.Apply(atomTrue, atomFail)),
+		} {
+			ref := NewVariable()
+			_, err := Assertz2(&vm, c, ref, func(env *Env) *Promise {
+				refs = append(refs, env.Resolve(ref))
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+		}
+		ref := NewVariable()
+		_, err := Asserta2(&vm, foo.Apply(Integer(0)), ref, func(env *Env) *Promise {
+			refs = append([]Term{env.Resolve(ref)}, refs...)
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		return &vm, refs
+	}
+	heads := func(vm *VM) []Term {
+		var ret []Term
+		x := NewVariable()
+		_, err := Clause(vm, foo.Apply(x), NewVariable(), func(env *Env) *Promise {
+			ret = append(ret, env.Resolve(x))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		return ret
+	}
+
+	t.Run("clause/3 enumerates the references", func(t *testing.T) {
+		vm, refs := newVM()
+		var got []Term
+		ref := NewVariable()
+		_, err := Clause3(vm, foo.Apply(NewVariable()), NewVariable(), ref, func(env *Env) *Promise {
+			got = append(got, env.Resolve(ref))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		// The alternatives of the disjunction share the reference.
+		assert.Equal(t, []Term{refs[0], refs[1], refs[2], refs[3], refs[3]}, got)
+	})
+
+	t.Run("clause/3 with a reference", func(t *testing.T) {
+		vm, refs := newVM()
+		h, b := NewVariable(), NewVariable()
+		ok, err := Clause3(vm, h, b, refs[2], func(env *Env) *Promise {
+			assert.Equal(t, foo.Apply(Integer(2)), env.Resolve(h))
+			assert.Equal(t, atomTrue, env.Resolve(b))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("erase", func(t *testing.T) {
+		vm, refs := newVM()
+		ok, err := Erase(vm, refs[1], Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []Term{Integer(0), Integer(2), Integer(3), Integer(3)}, heads(vm))
+
+		ok, err = Erase(vm, refs[3], Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []Term{Integer(0), Integer(2)}, heads(vm))
+
+		ok, err = Erase(vm, refs[1], Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		ok, err = Clause3(vm, NewVariable(), NewVariable(), refs[1], Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		vm, refs := newVM()
+		c := vm.Clone()
+		ok, err := Erase(c, refs[0], Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Len(t, heads(vm), 5)
+		assert.Len(t, heads(c), 5)
+	})
+
+	t.Run("journal", func(t *testing.T) {
+		vm, refs := newVM()
+		vm.SetJournaling(true)
+		_, err := Erase(vm, refs[3], Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		e := atomErase.Apply(atomIf.Apply(foo.Apply(Integer(3)), atomSemiColon.Apply(atomTrue, atomFail)), Integer(4))
+		assert.Equal(t, []Term{e, e}, vm.Journal())
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		var vm VM
+		vm.SetJournaling(true)
+		x, ref := NewVariable(), NewVariable()
+		_, err := Assertz(&vm, foo.Apply(x), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		_, err = Assertz2(&vm, foo.Apply(Integer(1)), ref, func(env *Env) *Promise {
+			return Erase(&vm, ref, Success, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+
+		var replica VM
+		assert.NoError(t, replica.Replay(context.Background(), vm.Journal()))
+		ok, err := Clause(&replica, foo.Apply(Integer(2)), atomTrue, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		ok, err = Clause(&replica, foo.Apply(Integer(1)), atomTrue, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok, "foo(_) is kept")
+		assert.Len(t, heads(&replica), 1)
+
+		err = replica.Replay(context.Background(), []Term{atomErase.Apply(atomIf.Apply(foo.Apply(Integer(1)), atomTrue), Integer(1))})
+		assert.ErrorIs(t, err, errJournalDiverged)
+	})
+
+	t.Run("clones of a frozen VM", func(t *testing.T) {
+		vm, _ := newVM()
+		vm.Freeze()
+		var wg sync.WaitGroup
+		refs := make([]Term, 4)
+		for i := range refs {
+			wg.Add(1)
+			go func(c *VM) {
+				defer wg.Done()
+				ref := NewVariable()
+				_, err := Clause3(c, foo.Apply(Integer(0)), NewVariable(), ref, func(env *Env) *Promise {
+					refs[i] = env.Resolve(ref)
+					return Bool(true)
+				}, nil).Force(context.Background())
+				assert.NoError(t, err)
+			}(vm.Clone())
+		}
+		wg.Wait()
+		for _, r := range refs[1:] {
+			assert.NotEqual(t, refs[0], r, "every clone has its own references")
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		vm, _ := newVM()
+		_, err := Assertz2(vm, foo.Apply(Integer(4)), NewAtom("ref"), Success, nil).Force(context.Background())
+		assert.Equal(t, UninstantiationError(NewAtom("ref"), nil), err)
+		_, err = Erase(vm, NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		_, err = Erase(vm, NewAtom("ref"), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeDBReference, NewAtom("ref"), nil), err)
+		_, err = Clause3(vm, NewVariable(), NewVariable(), NewAtom("ref"), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeDBReference, NewAtom("ref"), nil), err)
+	})
+}
+
+func TestClauseRef_WriteTerm(t *testing.T) {
+	var sb bytes.Buffer
+	assert.NoError(t, (&clauseRef{id: 42}).WriteTerm(&sb, nil, nil))
+	assert.Equal(t, "<clause>(0x2a)", sb.String())
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"maps"
+	"slices"
+
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+)
+
+// Freeze makes the database of the VM read-only. Asserting, retracting, or abolishing clauses, loading Prolog texts,
+// and defining operators raise permission errors afterwards.
+// Since the database of a frozen VM never changes, its clones share it instead of copying it.
+func (vm *VM) Freeze() {
+	vm.CollectClauses()
+	vm.frozen = true
+}
+
+// Frozen reports whether the database of the VM is read-only.
+func (vm *VM) Frozen() bool {
+	return vm.frozen
+}
+
+// Clone returns a copy of the VM which runs queries independently of it, e.g. in another goroutine.
+// The database, the recorded database, the global variables, the flags, the random number generator, the journal, and
+// the stream table are copied while the streams themselves, the file system, the callbacks, the hooks, the meter, the
+// logger, the tracer, and the metrics sink are shared. The tables of tabled predicates, the goal history, the profiles,
+// the coverage, the metrics counters, and the clause references are not copied.
+// The clone has no clause store so that its changes of the dynamic procedures are never persisted; call SetClauseStore
+// on the clone to persist them in another one.
+func (vm *VM) Clone() *VM {
+	c := *vm
+
+	c.tables, c.tableStack, c.tableGen = nil, nil, 0
+	c.clauseRefs = nil // The references are valid only in the VM they're obtained from.
+	c.clauseStore = nil
+	if vm.history != nil {
+		c.history = &goalHistory{goals: make([]enteredGoal, len(vm.history.goals))}
+	}
+	c.charConversions = maps.Clone(vm.charConversions)
+	if vm.profiler != nil {
+		c.profiler = &profiler{}
+	}
+	if vm.coverage != nil {
+		c.coverage = &coverage{}
+	}
+	if vm.metrics != nil {
+		c.metrics = &metrics{sink: vm.metrics.sink}
+		c.metrics.published[0] = c.instructions
+	}
+	c.journal = slices.Clip(vm.journal) // The appends to either journal don't affect the other.
+	c.traced = maps.Clone(vm.traced)
+	c.spied = maps.Clone(vm.spied)
+	c.autoloads = maps.Clone(vm.autoloads)
+	c.globals = maps.Clone(vm.globals)
+	c.arenas = make(map[procedureIndicator][]*TermArena, len(vm.arenas))
+	for pi, as := range vm.arenas {
+		c.arenas[pi] = slices.Clip(as) // The appends to either slice don't affect the other.
+	}
+	if vm.records != nil {
+		c.records = orderedmap.New[recordKey, *recordList]()
+		for p := vm.records.Oldest(); p != nil; p = p.Next() {
+			c.records.Set(p.Key, cloneRecordList(p.Value))
+		}
+	}
+	c.streams = streams{
+		elems:   append([]*Stream(nil), vm.streams.elems...),
+		aliases: maps.Clone(vm.streams.aliases),
+	}
+
+	if vm.frozen {
+		return &c
+	}
+
+	c.procedures = cloneProcedures(vm.procedures)
+	if vm.modules != nil {
+		c.modules = make(map[Atom]*module, len(vm.modules))
+		for n, m := range vm.modules {
+			c.modules[n] = &module{
+				file:       m.file,
+				exports:    m.exports,
+				procedures: cloneProcedures(m.procedures),
+			}
+		}
+	}
+	c.moduleFiles = maps.Clone(vm.moduleFiles)
+	if vm.imports != nil {
+		c.imports = make(map[Atom]map[procedureIndicator]Atom, len(vm.imports))
+		for m, is := range vm.imports {
+			c.imports[m] = maps.Clone(is)
+		}
+	}
+	if vm.loaded != nil {
+		c.loaded = orderedmap.New[string, [sha256.Size]byte]()
+		for p := vm.loaded.Oldest(); p != nil; p = p.Next() {
+			c.loaded.Set(p.Key, p.Value)
+		}
+	}
+	if vm._operators != nil {
+		c._operators = vm._operators.clone()
+	}
+	return &c
+}
+
+func cloneProcedures(ps *orderedmap.OrderedMap[procedureIndicator, procedure]) *orderedmap.OrderedMap[procedureIndicator, procedure] {
+	if ps == nil {
+		return nil
+	}
+	c := orderedmap.New[procedureIndicator, procedure]()
+	for p := ps.Oldest(); p != nil; p = p.Next() {
+		u, ok := p.Value.(*userDefined)
+		if !ok {
+			c.Set(p.Key, p.Value)
+			continue
+		}
+		cu := *u
+		cu.index = clauseIndex[Term]{}
+		cu.argIndexes = make([]*argIndex, len(u.argIndexes))
+		for i, a := range u.argIndexes {
+			cu.argIndexes[i] = &argIndex{args: a.args}
+		}
+		cu.clauses = make(clauses, len(u.clauses))
+		for i, cl := range u.clauses {
+			ccl := *cl
+			cu.clauses[i] = &ccl
+		}
+		cu.reindex()
+		c.Set(p.Key, &cu)
+	}
+	return c
+}
+
+// checkFrozen returns a permission error to modify culprit of type permissionType if the VM is frozen.
+func (vm *VM) checkFrozen(permissionType permissionType, culprit Term, env *Env) error {
+	if !vm.frozen {
+		return nil
+	}
+	return permissionError(operationModify, permissionType, culprit, env)
+}
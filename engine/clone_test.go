@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_Clone(t *testing.T) {
+	foo := NewAtom("foo")
+	pi := procedureIndicator{name: foo, arity: 1}
+
+	var vm VM
+	_, err := Assertz(&vm, foo.Apply(Integer(1)), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+
+	t.Run("copy", func(t *testing.T) {
+		c := vm.Clone()
+		_, err := Assertz(c, foo.Apply(Integer(2)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		ok, err := Retract(c, foo.Apply(Integer(1)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = Call(&vm, foo.Apply(Integer(1)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		ok, err = Call(&vm, foo.Apply(Integer(2)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("frozen", func(t *testing.T) {
+		vm := vm.Clone()
+		vm.Freeze()
+		assert.True(t, vm.Frozen())
+
+		c := vm.Clone()
+		assert.True(t, c.Frozen())
+		p, _ := vm.getProcedure(pi)
+		q, _ := c.getProcedure(pi)
+		assert.Same(t, p, q)
+
+		ok, err := Call(c, foo.Apply(Integer(1)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		_, err = Assertz(c, foo.Apply(Integer(2)), Success, nil).Force(context.Background())
+		assert.Equal(t, permissionError(operationModify, permissionTypeStaticProcedure, pi.Term(), nil), err)
+		_, err = Retract(c, foo.Apply(Integer(1)), Success, nil).Force(context.Background())
+		assert.Equal(t, permissionError(operationModify, permissionTypeStaticProcedure, pi.Term(), nil), err)
+		_, err = Abolish(c, pi.Term(), Success, nil).Force(context.Background())
+		assert.Equal(t, permissionError(operationModify, permissionTypeStaticProcedure, pi.Term(), nil), err)
+		_, err = Op(c, Integer(200), atomXFX, NewAtom("++"), Success, nil).Force(context.Background())
+		assert.Equal(t, permissionError(operationModify, permissionTypeOperator, NewAtom("++"), nil), err)
+		assert.Equal(t, permissionError(operationModify, permissionTypeStaticProcedure, atomUser, nil), c.Compile(context.Background(), `bar.`))
+	})
+}
@@ -0,0 +1,834 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"math"
+	"sort"
+)
+
+var (
+	atomClpfd           = NewAtom("clpfd")
+	atomClpfdDomain     = NewAtom("clpfd_domain")
+	atomClpfdExpression = NewAtom("clpfd_expression")
+	atomDotDot          = NewAtom("..")
+	atomInf             = NewAtom("inf")
+	atomSup             = NewAtom("sup")
+)
+
+func init() {
+	attrUnifyHooks = map[Atom]attrUnifyHook{
+		atomClpfd: fdUnifyHook,
+	}
+}
+
+// fdInf and fdSup are the unbounded ends of a finite domain.
+const (
+	fdInf = Integer(math.MinInt64)
+	fdSup = Integer(math.MaxInt64)
+)
+
+type fdInterval struct {
+	min, max Integer
+}
+
+// fdDomain is a set of integers represented as sorted, disjoint and non-adjacent intervals.
+type fdDomain []fdInterval
+
+var fdUniverse = fdDomain{{min: fdInf, max: fdSup}}
+
+func (d fdDomain) min() Integer {
+	return d[0].min
+}
+
+func (d fdDomain) max() Integer {
+	return d[len(d)-1].max
+}
+
+func (d fdDomain) finite() bool {
+	return len(d) > 0 && d.min() != fdInf && d.max() != fdSup
+}
+
+func (d fdDomain) singleton() (Integer, bool) {
+	if len(d) != 1 || d[0].min != d[0].max || !d.finite() {
+		return 0, false
+	}
+	return d[0].min, true
+}
+
+func (d fdDomain) contains(n Integer) bool {
+	i := sort.Search(len(d), func(i int) bool {
+		return d[i].max >= n
+	})
+	return i < len(d) && d[i].min <= n
+}
+
+func (d fdDomain) equal(o fdDomain) bool {
+	if len(d) != len(o) {
+		return false
+	}
+	for i := range d {
+		if d[i] != o[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (d fdDomain) intersect(o fdDomain) fdDomain {
+	var ret fdDomain
+	for i, j := 0, 0; i < len(d) && j < len(o); {
+		lo, hi := d[i].min, d[i].max
+		if o[j].min > lo {
+			lo = o[j].min
+		}
+		if o[j].max < hi {
+			hi = o[j].max
+		}
+		if lo <= hi {
+			ret = append(ret, fdInterval{min: lo, max: hi})
+		}
+		if d[i].max < o[j].max {
+			i++
+		} else {
+			j++
+		}
+	}
+	return ret
+}
+
+func (d fdDomain) union(o fdDomain) fdDomain {
+	all := make(fdDomain, 0, len(d)+len(o))
+	all = append(all, d...)
+	all = append(all, o...)
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].min < all[j].min
+	})
+	var ret fdDomain
+	for _, in := range all {
+		if n := len(ret); n > 0 && (ret[n-1].max == fdSup || in.min <= ret[n-1].max+1) {
+			if in.max > ret[n-1].max {
+				ret[n-1].max = in.max
+			}
+			continue
+		}
+		ret = append(ret, in)
+	}
+	return ret
+}
+
+func (d fdDomain) remove(n Integer) fdDomain {
+	if !d.contains(n) {
+		return d
+	}
+	ret := make(fdDomain, 0, len(d)+1)
+	for _, in := range d {
+		switch {
+		case n < in.min || n > in.max:
+			ret = append(ret, in)
+		case in.min == in.max:
+			break
+		case n == in.min:
+			ret = append(ret, fdInterval{min: n + 1, max: in.max})
+		case n == in.max:
+			ret = append(ret, fdInterval{min: in.min, max: n - 1})
+		default:
+			ret = append(ret, fdInterval{min: in.min, max: n - 1}, fdInterval{min: n + 1, max: in.max})
+		}
+	}
+	return ret
+}
+
+// term returns the domain in the syntax of in/2 e.g. 1..3\/5..sup.
+func (d fdDomain) term() Term {
+	bound := func(n Integer) Term {
+		switch n {
+		case fdInf:
+			return atomInf
+		case fdSup:
+			return atomSup
+		default:
+			return n
+		}
+	}
+	var ret Term
+	for _, in := range d {
+		var t Term
+		if in.min == in.max {
+			t = in.min
+		} else {
+			t = atomDotDot.Apply(bound(in.min), bound(in.max))
+		}
+		if ret == nil {
+			ret = t
+		} else {
+			ret = atomBitwiseOr.Apply(ret, t)
+		}
+	}
+	if ret == nil {
+		return atomDotDot.Apply(Integer(1), Integer(0))
+	}
+	return ret
+}
+
+func parseFdDomain(t Term, env *Env) (fdDomain, error) {
+	switch t := env.Resolve(t).(type) {
+	case Variable:
+		return nil, InstantiationError(env)
+	case Integer:
+		return fdDomain{{min: t, max: t}}, nil
+	case Compound:
+		if t.Arity() != 2 {
+			break
+		}
+		switch t.Functor() {
+		case atomDotDot:
+			lo, err := parseFdBound(t.Arg(0), atomInf, fdInf, env)
+			if err != nil {
+				return nil, err
+			}
+			hi, err := parseFdBound(t.Arg(1), atomSup, fdSup, env)
+			if err != nil {
+				return nil, err
+			}
+			if lo > hi {
+				return fdDomain{}, nil
+			}
+			return fdDomain{{min: lo, max: hi}}, nil
+		case atomBitwiseOr:
+			x, err := parseFdDomain(t.Arg(0), env)
+			if err != nil {
+				return nil, err
+			}
+			y, err := parseFdDomain(t.Arg(1), env)
+			if err != nil {
+				return nil, err
+			}
+			return x.union(y), nil
+		}
+	}
+	return nil, TypeError(atomClpfdDomain, t, env)
+}
+
+func parseFdBound(t Term, unbounded Atom, n Integer, env *Env) (Integer, error) {
+	switch t := env.Resolve(t).(type) {
+	case Variable:
+		return 0, InstantiationError(env)
+	case Integer:
+		return t, nil
+	case Atom:
+		if t == unbounded {
+			return n, nil
+		}
+	}
+	return 0, TypeError(atomClpfdDomain, t, env)
+}
+
+// fdAttribute is the value of the clpfd attribute of a constrained variable.
+type fdAttribute struct {
+	dom   fdDomain
+	props []*fdPropagator
+}
+
+// WriteTerm outputs the domain of the constrained variable to an io.Writer.
+func (a *fdAttribute) WriteTerm(w io.Writer, opts *WriteOptions, env *Env) error {
+	return a.dom.term().WriteTerm(w, opts, env)
+}
+
+// Compare compares the fdAttribute with a Term.
+func (a *fdAttribute) Compare(t Term, env *Env) int {
+	return CompareAtomic[*fdAttribute](a, t, func(a, b *fdAttribute) int {
+		return a.dom.term().Compare(b.dom.term(), env)
+	}, env)
+}
+
+// fdState returns the domain and the propagators of t.
+func fdState(t Term, env *Env) (fdDomain, []*fdPropagator) {
+	switch t := env.Resolve(t).(type) {
+	case Integer:
+		return fdDomain{{min: t, max: t}}, nil
+	case Variable:
+		if a, ok := env.getAttr(t, atomClpfd); ok {
+			if a, ok := a.(*fdAttribute); ok {
+				return a.dom, a.props
+			}
+		}
+		return fdUniverse, nil
+	default:
+		return nil, nil
+	}
+}
+
+type fdRelation uint8
+
+const (
+	fdRelationEqual    fdRelation = iota // sum = c
+	fdRelationNotEqual                   // sum ≠ c
+	fdRelationLessEq                     // sum ≤ c
+	fdRelationAllDifferent
+)
+
+// fdPropagator is a constraint over vars: either a linear relation sum(coeffs[i]*vars[i]) rel c or all_different(vars).
+type fdPropagator struct {
+	rel    fdRelation
+	coeffs []Integer
+	vars   []Term
+	c      Integer
+}
+
+// fdSolver narrows domains until the scheduled propagators reach a fixpoint.
+type fdSolver struct {
+	env    *Env
+	queue  []*fdPropagator
+	queued map[*fdPropagator]struct{}
+}
+
+func newFdSolver(env *Env) *fdSolver {
+	return &fdSolver{env: env, queued: map[*fdPropagator]struct{}{}}
+}
+
+func (s *fdSolver) schedule(props ...*fdPropagator) {
+	for _, p := range props {
+		if _, ok := s.queued[p]; ok {
+			continue
+		}
+		s.queued[p] = struct{}{}
+		s.queue = append(s.queue, p)
+	}
+}
+
+// attach makes the variables of p wake p up when their domains change.
+func (s *fdSolver) attach(p *fdPropagator) {
+	for _, t := range p.vars {
+		v, ok := s.env.Resolve(t).(Variable)
+		if !ok {
+			continue
+		}
+		dom, props := fdState(v, s.env)
+		if len(props) > 0 && props[len(props)-1] == p {
+			continue
+		}
+		s.env = s.env.putAttr(v, atomClpfd, &fdAttribute{
+			dom:   dom,
+			props: append(props[:len(props):len(props)], p),
+		})
+	}
+}
+
+// narrow restricts the domain of t to d and fails if it becomes empty.
+func (s *fdSolver) narrow(t Term, d fdDomain) bool {
+	switch t := s.env.Resolve(t).(type) {
+	case Integer:
+		return d.contains(t)
+	case Variable:
+		dom, props := fdState(t, s.env)
+		nd := dom.intersect(d)
+		switch {
+		case len(nd) == 0:
+			return false
+		case nd.equal(dom):
+			return true
+		}
+		if n, ok := nd.singleton(); ok {
+			s.env = s.env.delAttr(t, atomClpfd).bindVariable(t, n)
+		} else {
+			s.env = s.env.putAttr(t, atomClpfd, &fdAttribute{dom: nd, props: props})
+		}
+		s.schedule(props...)
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *fdSolver) propagate() bool {
+	for len(s.queue) > 0 {
+		p := s.queue[0]
+		s.queue = s.queue[1:]
+		delete(s.queued, p)
+
+		var ok bool
+		switch p.rel {
+		case fdRelationEqual:
+			ok = s.propagateLessEq(p.coeffs, p.vars, p.c) && s.propagateGreaterEq(p.coeffs, p.vars, p.c)
+		case fdRelationNotEqual:
+			ok = s.propagateNotEqual(p.coeffs, p.vars, p.c)
+		case fdRelationLessEq:
+			ok = s.propagateLessEq(p.coeffs, p.vars, p.c)
+		case fdRelationAllDifferent:
+			ok = s.propagateAllDifferent(p.vars)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// propagateLessEq enforces bounds consistency of sum(coeffs[i]*vars[i]) ≤ c.
+// Whenever an intermediate value doesn't fit in an Integer, it gives up pruning which is sound.
+func (s *fdSolver) propagateLessEq(coeffs []Integer, vars []Term, c Integer) bool {
+	var (
+		mins      = make([]Integer, len(vars))
+		unbounded = make([]bool, len(vars))
+		n         int
+		sum       Integer
+	)
+	for i, x := range vars {
+		dom, _ := fdState(x, s.env)
+		b := dom.min()
+		if coeffs[i] < 0 {
+			b = dom.max()
+		}
+		if b == fdInf || b == fdSup {
+			unbounded[i] = true
+			n++
+			continue
+		}
+		m, err := mulI(coeffs[i], b)
+		if err != nil {
+			return true
+		}
+		mins[i] = m
+		if sum, err = addI(sum, m); err != nil {
+			return true
+		}
+	}
+	if n == 0 && sum > c {
+		return false
+	}
+
+	for i, x := range vars {
+		rest := sum
+		switch {
+		case unbounded[i] && n > 1, !unbounded[i] && n > 0:
+			continue
+		case !unbounded[i]:
+			var err error
+			if rest, err = subI(sum, mins[i]); err != nil {
+				continue
+			}
+		}
+		bound, err := subI(c, rest)
+		if err != nil {
+			continue
+		}
+		var d fdDomain
+		switch a := coeffs[i]; {
+		case a > 0:
+			d = fdDomain{{min: fdInf, max: fdFloorDiv(bound, a)}}
+		case a == -1 && bound == fdInf:
+			continue
+		default:
+			d = fdDomain{{min: fdCeilDiv(bound, a), max: fdSup}}
+		}
+		if !s.narrow(x, d) {
+			return false
+		}
+	}
+	return true
+}
+
+// propagateGreaterEq enforces bounds consistency of sum(coeffs[i]*vars[i]) ≥ c.
+func (s *fdSolver) propagateGreaterEq(coeffs []Integer, vars []Term, c Integer) bool {
+	neg := make([]Integer, len(coeffs))
+	for i, a := range coeffs {
+		n, err := subI(0, a)
+		if err != nil {
+			return true
+		}
+		neg[i] = n
+	}
+	nc, err := subI(0, c)
+	if err != nil {
+		return true
+	}
+	return s.propagateLessEq(neg, vars, nc)
+}
+
+// propagateNotEqual removes the forbidden value once all the variables but one are instantiated.
+func (s *fdSolver) propagateNotEqual(coeffs []Integer, vars []Term, c Integer) bool {
+	var (
+		sum  Integer
+		free = -1
+	)
+	for i, x := range vars {
+		switch x := s.env.Resolve(x).(type) {
+		case Integer:
+			m, err := mulI(coeffs[i], x)
+			if err != nil {
+				return true
+			}
+			if sum, err = addI(sum, m); err != nil {
+				return true
+			}
+		default:
+			if free >= 0 {
+				return true
+			}
+			free = i
+		}
+	}
+	if free < 0 {
+		return sum != c
+	}
+	r, err := subI(c, sum)
+	if err != nil || r%coeffs[free] != 0 {
+		return true
+	}
+	dom, _ := fdState(vars[free], s.env)
+	return s.narrow(vars[free], dom.remove(r/coeffs[free]))
+}
+
+// propagateAllDifferent removes the values of the instantiated variables from the domains of the others.
+func (s *fdSolver) propagateAllDifferent(vars []Term) bool {
+	for i, x := range vars {
+		n, ok := s.env.Resolve(x).(Integer)
+		if !ok {
+			continue
+		}
+		for j, y := range vars {
+			if i == j {
+				continue
+			}
+			switch y := s.env.Resolve(y).(type) {
+			case Integer:
+				if y == n {
+					return false
+				}
+			default:
+				dom, _ := fdState(y, s.env)
+				if !s.narrow(y, dom.remove(n)) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func fdFloorDiv(x, y Integer) Integer {
+	q := x / y
+	if x%y != 0 && (x < 0) != (y < 0) {
+		q--
+	}
+	return q
+}
+
+func fdCeilDiv(x, y Integer) Integer {
+	q := x / y
+	if x%y != 0 && (x < 0) == (y < 0) {
+		q++
+	}
+	return q
+}
+
+// fdUnifyHook checks that the constrained variable got bound to a value of its domain and wakes its propagators up.
+func fdUnifyHook(vm *VM, value, other Term, k Cont, env *Env) *Promise {
+	a, ok := value.(*fdAttribute)
+	if !ok {
+		return k(env)
+	}
+	s := newFdSolver(env)
+	switch o := env.Resolve(other).(type) {
+	case Integer:
+		if !a.dom.contains(o) {
+			return Bool(false)
+		}
+	case Variable:
+		dom, props := fdState(o, env)
+		props = append(props[:len(props):len(props)], a.props...)
+		s.env = s.env.putAttr(o, atomClpfd, &fdAttribute{dom: dom, props: props})
+		if !s.narrow(o, a.dom) {
+			return Bool(false)
+		}
+	default:
+		return Error(typeError(validTypeInteger, o, env))
+	}
+	s.schedule(a.props...)
+	if !s.propagate() {
+		return Bool(false)
+	}
+	return vm.wakeUp(k, s.env)
+}
+
+// fdLinear is a linear expression sum(coeffs[i]*vars[i]) + c.
+type fdLinear struct {
+	coeffs []Integer
+	vars   []Variable
+	c      Integer
+}
+
+func (l *fdLinear) add(v Variable, a Integer) error {
+	for i, w := range l.vars {
+		if w == v {
+			n, err := addI(l.coeffs[i], a)
+			if err != nil {
+				return err
+			}
+			l.coeffs[i] = n
+			return nil
+		}
+	}
+	l.vars = append(l.vars, v)
+	l.coeffs = append(l.coeffs, a)
+	return nil
+}
+
+// linearize adds a*t to l.
+func (l *fdLinear) linearize(t Term, a Integer, env *Env) error {
+	switch t := env.Resolve(t).(type) {
+	case Variable:
+		return l.add(t, a)
+	case Integer:
+		m, err := mulI(a, t)
+		if err != nil {
+			return err
+		}
+		l.c, err = addI(l.c, m)
+		return err
+	case Compound:
+		switch {
+		case t.Functor() == atomPlus && t.Arity() == 2:
+			if err := l.linearize(t.Arg(0), a, env); err != nil {
+				return err
+			}
+			return l.linearize(t.Arg(1), a, env)
+		case t.Functor() == atomMinus && t.Arity() == 2:
+			if err := l.linearize(t.Arg(0), a, env); err != nil {
+				return err
+			}
+			neg, err := subI(0, a)
+			if err != nil {
+				return err
+			}
+			return l.linearize(t.Arg(1), neg, env)
+		case t.Functor() == atomMinus && t.Arity() == 1:
+			neg, err := subI(0, a)
+			if err != nil {
+				return err
+			}
+			return l.linearize(t.Arg(0), neg, env)
+		case t.Functor() == atomPlus && t.Arity() == 1:
+			return l.linearize(t.Arg(0), a, env)
+		case t.Functor() == atomAsterisk && t.Arity() == 2:
+			var x, y fdLinear
+			if err := x.linearize(t.Arg(0), 1, env); err != nil {
+				return err
+			}
+			if err := y.linearize(t.Arg(1), 1, env); err != nil {
+				return err
+			}
+			switch {
+			case len(x.vars) == 0:
+				m, err := mulI(a, x.c)
+				if err != nil {
+					return err
+				}
+				return l.linearize(t.Arg(1), m, env)
+			case len(y.vars) == 0:
+				m, err := mulI(a, y.c)
+				if err != nil {
+					return err
+				}
+				return l.linearize(t.Arg(0), m, env)
+			default:
+				return DomainError(atomClpfdExpression, t, env)
+			}
+		default:
+			return typeError(validTypeEvaluable, atomSlash.Apply(t.Functor(), Integer(t.Arity())), env)
+		}
+	case Atom:
+		return typeError(validTypeEvaluable, atomSlash.Apply(t, Integer(0)), env)
+	default:
+		return typeError(validTypeInteger, t, env)
+	}
+}
+
+// fdPost posts the propagator p and propagates the consequences.
+func fdPost(vm *VM, p *fdPropagator, k Cont, env *Env) *Promise {
+	s := newFdSolver(env)
+	s.attach(p)
+	s.schedule(p)
+	if !s.propagate() {
+		return Bool(false)
+	}
+	return vm.wakeUp(k, s.env)
+}
+
+// fdPostLinear posts sign*(x - y) rel offset.
+func fdPostLinear(vm *VM, rel fdRelation, x, y Term, sign, offset Integer, k Cont, env *Env) *Promise {
+	var l fdLinear
+	if err := l.linearize(x, sign, env); err != nil {
+		return Error(fdError(err, env))
+	}
+	if err := l.linearize(y, -sign, env); err != nil {
+		return Error(fdError(err, env))
+	}
+	c, err := subI(offset, l.c)
+	if err != nil {
+		return Error(fdError(err, env))
+	}
+
+	p := fdPropagator{rel: rel, c: c}
+	for i, v := range l.vars {
+		if l.coeffs[i] == 0 {
+			continue
+		}
+		p.vars = append(p.vars, v)
+		p.coeffs = append(p.coeffs, l.coeffs[i])
+	}
+	return fdPost(vm, &p, k, env)
+}
+
+func fdError(err error, env *Env) error {
+	if ev, ok := err.(exceptionalValue); ok {
+		return evaluationError(ev, env)
+	}
+	return err
+}
+
+// FDEqual constrains the integer expressions x and y to be equal.
+func FDEqual(vm *VM, x, y Term, k Cont, env *Env) *Promise {
+	return fdPostLinear(vm, fdRelationEqual, x, y, 1, 0, k, env)
+}
+
+// FDNotEqual constrains the integer expressions x and y to be different.
+func FDNotEqual(vm *VM, x, y Term, k Cont, env *Env) *Promise {
+	return fdPostLinear(vm, fdRelationNotEqual, x, y, 1, 0, k, env)
+}
+
+// FDLessThan constrains the integer expression x to be less than y.
+func FDLessThan(vm *VM, x, y Term, k Cont, env *Env) *Promise {
+	return fdPostLinear(vm, fdRelationLessEq, x, y, 1, -1, k, env)
+}
+
+// FDLessThanOrEqual constrains the integer expression x to be less than or equal to y.
+func FDLessThanOrEqual(vm *VM, x, y Term, k Cont, env *Env) *Promise {
+	return fdPostLinear(vm, fdRelationLessEq, x, y, 1, 0, k, env)
+}
+
+// FDGreaterThan constrains the integer expression x to be greater than y.
+func FDGreaterThan(vm *VM, x, y Term, k Cont, env *Env) *Promise {
+	return fdPostLinear(vm, fdRelationLessEq, x, y, -1, -1, k, env)
+}
+
+// FDGreaterThanOrEqual constrains the integer expression x to be greater than or equal to y.
+func FDGreaterThanOrEqual(vm *VM, x, y Term, k Cont, env *Env) *Promise {
+	return fdPostLinear(vm, fdRelationLessEq, x, y, -1, 0, k, env)
+}
+
+// FDIn constrains x to be an integer of the domain dom e.g. 1..3\/5..sup.
+func FDIn(vm *VM, x, dom Term, k Cont, env *Env) *Promise {
+	d, err := parseFdDomain(dom, env)
+	if err != nil {
+		return Error(err)
+	}
+	return fdIn(vm, []Term{x}, d, k, env)
+}
+
+// FDIns constrains the elements of the list xs to be integers of the domain dom.
+func FDIns(vm *VM, xs, dom Term, k Cont, env *Env) *Promise {
+	d, err := parseFdDomain(dom, env)
+	if err != nil {
+		return Error(err)
+	}
+	var ts []Term
+	iter := ListIterator{List: xs, Env: env}
+	for iter.Next() {
+		ts = append(ts, iter.Current())
+	}
+	if err := iter.Err(); err != nil {
+		return Error(err)
+	}
+	return fdIn(vm, ts, d, k, env)
+}
+
+func fdIn(vm *VM, xs []Term, d fdDomain, k Cont, env *Env) *Promise {
+	s := newFdSolver(env)
+	for _, x := range xs {
+		switch x := env.Resolve(x).(type) {
+		case Variable, Integer:
+			if !s.narrow(x, d) {
+				return Bool(false)
+			}
+		default:
+			return Error(typeError(validTypeInteger, x, env))
+		}
+	}
+	if !s.propagate() {
+		return Bool(false)
+	}
+	return vm.wakeUp(k, s.env)
+}
+
+// FDAllDifferent constrains the elements of the list xs to be pairwise different integers.
+func FDAllDifferent(vm *VM, xs Term, k Cont, env *Env) *Promise {
+	var p fdPropagator
+	p.rel = fdRelationAllDifferent
+	iter := ListIterator{List: xs, Env: env}
+	for iter.Next() {
+		switch x := env.Resolve(iter.Current()).(type) {
+		case Variable, Integer:
+			p.vars = append(p.vars, x)
+		default:
+			return Error(typeError(validTypeInteger, x, env))
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return Error(err)
+	}
+	return fdPost(vm, &p, k, env)
+}
+
+// FDLabel assigns values of their domains to the variables of the list xs, smallest first, on backtracking.
+func FDLabel(vm *VM, xs Term, k Cont, env *Env) *Promise {
+	var vs []Term
+	iter := ListIterator{List: xs, Env: env}
+	for iter.Next() {
+		switch x := env.Resolve(iter.Current()).(type) {
+		case Variable:
+			if dom, _ := fdState(x, env); !dom.finite() {
+				return Error(InstantiationError(env))
+			}
+			vs = append(vs, x)
+		case Integer:
+			continue
+		default:
+			return Error(typeError(validTypeInteger, x, env))
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return Error(err)
+	}
+	return fdLabel(vm, vs, k, env)
+}
+
+func fdLabel(vm *VM, vs []Term, k Cont, env *Env) *Promise {
+	for len(vs) > 0 {
+		if _, ok := env.Resolve(vs[0]).(Variable); ok {
+			break
+		}
+		vs = vs[1:]
+	}
+	if len(vs) == 0 {
+		return k(env)
+	}
+
+	v := vs[0]
+	dom, _ := fdState(v, env)
+	n := dom.min()
+	return Delay(func(context.Context) *Promise {
+		return Unify(vm, v, n, func(env *Env) *Promise {
+			return fdLabel(vm, vs[1:], k, env)
+		}, env)
+	}, func(context.Context) *Promise {
+		s := newFdSolver(env)
+		if !s.narrow(v, dom.remove(n)) || !s.propagate() {
+			return Bool(false)
+		}
+		return vm.wakeUp(func(env *Env) *Promise {
+			return fdLabel(vm, vs, k, env)
+		}, s.env)
+	})
+}
@@ -0,0 +1,186 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFdDomain(t *testing.T) {
+	d := fdDomain{{min: 1, max: 3}, {min: 5, max: 7}}
+
+	t.Run("contains", func(t *testing.T) {
+		assert.True(t, d.contains(1))
+		assert.True(t, d.contains(6))
+		assert.False(t, d.contains(4))
+		assert.False(t, d.contains(8))
+	})
+
+	t.Run("intersect", func(t *testing.T) {
+		assert.Equal(t, fdDomain{{min: 2, max: 3}, {min: 5, max: 5}}, d.intersect(fdDomain{{min: 2, max: 5}}))
+		assert.Empty(t, d.intersect(fdDomain{{min: 4, max: 4}}))
+	})
+
+	t.Run("union", func(t *testing.T) {
+		assert.Equal(t, fdDomain{{min: 1, max: 7}}, d.union(fdDomain{{min: 4, max: 4}}))
+		assert.Equal(t, fdDomain{{min: fdInf, max: 3}, {min: 5, max: fdSup}}, d.union(fdDomain{{min: fdInf, max: 0}, {min: 6, max: fdSup}}))
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		assert.Equal(t, fdDomain{{min: 1, max: 1}, {min: 3, max: 3}, {min: 5, max: 7}}, d.remove(2))
+		assert.Equal(t, fdDomain{{min: 2, max: 3}, {min: 5, max: 7}}, d.remove(1))
+		assert.Equal(t, d, d.remove(4))
+	})
+
+	t.Run("term", func(t *testing.T) {
+		assert.Equal(t, atomBitwiseOr.Apply(atomDotDot.Apply(Integer(1), Integer(3)), atomDotDot.Apply(Integer(5), Integer(7))), d.term())
+		assert.Equal(t, atomDotDot.Apply(atomInf, atomSup), fdUniverse.term())
+	})
+}
+
+func TestFDIn(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		x := NewVariable()
+		ok, err := FDIn(nil, x, atomBitwiseOr.Apply(atomDotDot.Apply(Integer(1), Integer(3)), Integer(5)), func(env *Env) *Promise {
+			dom, _ := fdState(x, env)
+			assert.Equal(t, fdDomain{{min: 1, max: 3}, {min: 5, max: 5}}, dom)
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("singleton", func(t *testing.T) {
+		x := NewVariable()
+		ok, err := FDIn(nil, x, atomDotDot.Apply(Integer(2), Integer(2)), func(env *Env) *Promise {
+			assert.Equal(t, Integer(2), env.Resolve(x))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("out of domain", func(t *testing.T) {
+		ok, err := FDIn(nil, Integer(4), atomDotDot.Apply(Integer(1), Integer(3)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("domain is a variable", func(t *testing.T) {
+		ok, err := FDIn(nil, NewVariable(), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("domain is not a domain", func(t *testing.T) {
+		ok, err := FDIn(nil, NewVariable(), NewAtom("foo"), Success, nil).Force(context.Background())
+		assert.Equal(t, TypeError(atomClpfdDomain, NewAtom("foo"), nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("x is not an integer", func(t *testing.T) {
+		ok, err := FDIn(nil, NewAtom("foo"), Integer(1), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeInteger, NewAtom("foo"), nil), err)
+		assert.False(t, ok)
+	})
+}
+
+func TestFDEqual(t *testing.T) {
+	t.Run("solve", func(t *testing.T) {
+		x := NewVariable()
+		ok, err := FDEqual(nil, atomAsterisk.Apply(Integer(3), x), atomPlus.Apply(Integer(5), Integer(7)), func(env *Env) *Promise {
+			assert.Equal(t, Integer(4), env.Resolve(x))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("no integer solution", func(t *testing.T) {
+		x := NewVariable()
+		ok, err := FDEqual(nil, atomAsterisk.Apply(Integer(2), x), Integer(5), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("wakes up on unification", func(t *testing.T) {
+		x, y := NewVariable(), NewVariable()
+		ok, err := FDEqual(nil, x, atomPlus.Apply(y, Integer(1)), func(env *Env) *Promise {
+			return Unify(nil, y, Integer(2), func(env *Env) *Promise {
+				assert.Equal(t, Integer(3), env.Resolve(x))
+				return Bool(true)
+			}, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("non-linear", func(t *testing.T) {
+		x, y := NewVariable(), NewVariable()
+		ok, err := FDEqual(nil, atomAsterisk.Apply(x, y), Integer(6), Success, nil).Force(context.Background())
+		assert.ErrorContains(t, err, "domain_error(clpfd_expression,")
+		assert.False(t, ok)
+	})
+
+	t.Run("not evaluable", func(t *testing.T) {
+		ok, err := FDEqual(nil, NewAtom("foo"), Integer(6), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeEvaluable, atomSlash.Apply(NewAtom("foo"), Integer(0)), nil), err)
+		assert.False(t, ok)
+	})
+}
+
+func TestFDLessThan(t *testing.T) {
+	x := NewVariable()
+	ok, err := FDIn(nil, x, atomDotDot.Apply(Integer(1), Integer(10)), func(env *Env) *Promise {
+		return FDLessThan(nil, x, Integer(5), func(env *Env) *Promise {
+			return FDGreaterThanOrEqual(nil, x, Integer(3), func(env *Env) *Promise {
+				dom, _ := fdState(x, env)
+				assert.Equal(t, fdDomain{{min: 3, max: 4}}, dom)
+				return Bool(true)
+			}, env)
+		}, env)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestFDAllDifferent(t *testing.T) {
+	x, y, z := NewVariable(), NewVariable(), NewVariable()
+	var got [][]Term
+	ok, err := FDIns(nil, List(x, y, z), atomDotDot.Apply(Integer(1), Integer(3)), func(env *Env) *Promise {
+		return FDAllDifferent(nil, List(x, y, z), func(env *Env) *Promise {
+			return FDNotEqual(nil, x, Integer(1), func(env *Env) *Promise {
+				return FDLabel(nil, List(x, y, z), func(env *Env) *Promise {
+					got = append(got, []Term{env.Resolve(x), env.Resolve(y), env.Resolve(z)})
+					return Bool(false)
+				}, env)
+			}, env)
+		}, env)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, [][]Term{
+		{Integer(2), Integer(1), Integer(3)},
+		{Integer(2), Integer(3), Integer(1)},
+		{Integer(3), Integer(1), Integer(2)},
+		{Integer(3), Integer(2), Integer(1)},
+	}, got)
+}
+
+func TestFDLabel(t *testing.T) {
+	t.Run("unbounded", func(t *testing.T) {
+		x := NewVariable()
+		ok, err := FDGreaterThan(nil, x, Integer(0), func(env *Env) *Promise {
+			return FDLabel(nil, List(x), Success, env)
+		}, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("not an integer", func(t *testing.T) {
+		ok, err := FDLabel(nil, List(NewAtom("foo")), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeInteger, NewAtom("foo"), nil), err)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Instruction is an instruction of the bytecode of a compiled clause.
+type Instruction struct {
+	Opcode  Opcode
+	Operand Term
+}
+
+// CompiledClause is a clause compiled into bytecode so that it can be stored as an artifact and loaded later with
+// LoadCompiled.
+type CompiledClause struct {
+	// Vars is the number of variables of the clause.
+	Vars     int
+	Bytecode []Instruction
+}
+
+// CompiledClauses returns the compiled clauses of the user-defined procedure identified by the predicate indicator pi.
+func (vm *VM) CompiledClauses(pi Term) ([]CompiledClause, error) {
+	key, err := toProcedureIndicator(pi, nil)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := vm.getProcedure(key)
+	if !ok {
+		return nil, existenceError(objectTypeProcedure, key.Term(), nil)
+	}
+	u, ok := p.(*userDefined)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errNotUserDefined, key)
+	}
+
+	ret := make([]CompiledClause, 0, len(u.clauses))
+	for _, c := range u.clauses {
+		if c.erased != 0 {
+			continue
+		}
+		bc := make([]Instruction, len(c.bytecode))
+		for j, op := range c.bytecode {
+			bc[j] = Instruction{Opcode: op.opcode, Operand: op.operand}
+		}
+		ret = append(ret, CompiledClause{Vars: len(c.vars), Bytecode: bc})
+	}
+	return ret, nil
+}
+
+// LoadCompiled defines the procedure identified by the predicate indicator pi with the compiled clauses cs.
+// Since cs may come from an untrusted artifact, the bytecode is verified beforehand: the variables of a clause are the
+// ones its bytecode refers to, and its constants are atomic terms which a Prolog text can denote, e.g. not streams.
+// cs is refused as a whole if any of its clauses is malformed. The loaded procedure is private so that its clauses
+// can't be inspected. LoadCompiled raises a permission error instead of replacing an existing procedure.
+func (vm *VM) LoadCompiled(pi Term, cs []CompiledClause) error {
+	key, err := toProcedureIndicator(pi, nil)
+	if err != nil {
+		return err
+	}
+	if err := vm.checkFrozen(permissionTypeStaticProcedure, key.Term(), nil); err != nil {
+		return err
+	}
+	if _, ok := vm.getProcedure(key); ok {
+		return permissionError(operationModify, permissionTypeStaticProcedure, key.Term(), nil)
+	}
+
+	u := userDefined{clauses: make(clauses, len(cs))}
+	for i, cc := range cs {
+		if err := cc.check(key); err != nil {
+			return err
+		}
+		c := clause{pi: key, vars: make([]Variable, cc.Vars), bytecode: make(bytecode, len(cc.Bytecode))}
+		for j, op := range cc.Bytecode {
+			c.bytecode[j] = instruction{opcode: op.Opcode, operand: op.Operand}
+		}
+		if err := c.verify(); err != nil {
+			return err
+		}
+		u.clauses[i] = &c
+	}
+	u.reindex()
+	vm.setProcedure(key, &u)
+	return nil
+}
+
+// check returns an error if cc has more variables than its bytecode refers to or a constant which is a host object.
+// The rest of the bytecode is verified once cc is converted to a clause.
+func (cc CompiledClause) check(pi procedureIndicator) error {
+	var refs int
+	for i, op := range cc.Bytecode {
+		switch op.Opcode {
+		case OpGetVar, OpPutVar:
+			refs++
+		case OpGetConst, OpPutConst:
+			switch op.Operand.(type) {
+			case nil, Variable, Atom, Integer, Float, Rational, String, Compound: // verify rejects the variables and the compounds.
+			default:
+				return internalError(fmt.Sprintf("invalid bytecode: %s with host object", op.Opcode), atomSlash.Apply(pi.Term(), Integer(i)))
+			}
+		}
+	}
+	if cc.Vars < 0 || cc.Vars > refs {
+		return internalError(fmt.Sprintf("invalid bytecode: %d variables", cc.Vars), pi.Term())
+	}
+	return nil
+}
+
+var errNotUserDefined = errors.New("not a user-defined predicate")
@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_LoadCompiled(t *testing.T) {
+	var src VM
+	assert.NoError(t, src.Compile(context.Background(), `
+:-(rev(L, R), rev(L, [], R)).
+rev([], A, A).
+:-(rev([X|Xs], A, R), rev(Xs, [X|A], R)).
+`))
+
+	t.Run("round trip", func(t *testing.T) {
+		var vm VM
+		for _, pi := range []Term{PI("rev", 2), PI("rev", 3)} {
+			cs, err := src.CompiledClauses(pi)
+			assert.NoError(t, err)
+			assert.NoError(t, vm.LoadCompiled(pi, cs))
+		}
+
+		ok, err := Call(&vm, NewAtom("rev").Apply(List(NewAtom("a"), NewAtom("b")), List(NewAtom("b"), NewAtom("a"))), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		tests := []struct {
+			title string
+			cs    []CompiledClause
+			err   string
+		}{
+			{title: "variable slot", cs: []CompiledClause{{Vars: 1, Bytecode: []Instruction{
+				{Opcode: OpGetVar, Operand: Integer(0)},
+				{Opcode: OpGetVar, Operand: Integer(1)},
+				{Opcode: OpExit},
+			}}}, err: "get_var(1) out of 1 variables"},
+			{title: "operand type", cs: []CompiledClause{{Vars: 1, Bytecode: []Instruction{
+				{Opcode: OpGetVar, Operand: NewAtom("x")},
+				{Opcode: OpGetVar, Operand: Integer(0)},
+				{Opcode: OpExit},
+			}}}, err: "get_var(x) out of 1 variables"},
+			{title: "compound constant", cs: []CompiledClause{{Vars: 1, Bytecode: []Instruction{
+				{Opcode: OpGetConst, Operand: NewAtom("f").Apply(NewVariable())},
+				{Opcode: OpGetVar, Operand: Integer(0)},
+				{Opcode: OpExit},
+			}}}, err: "with compound"},
+			{title: "unbalanced pop", cs: []CompiledClause{{Vars: 1, Bytecode: []Instruction{
+				{Opcode: OpGetFunctor, Operand: PI("f", 2)},
+				{Opcode: OpGetVar, Operand: Integer(0)},
+				{Opcode: OpPop},
+				{Opcode: OpExit},
+			}}}, err: "unbalanced pop()"},
+			{title: "missing terminator", cs: []CompiledClause{{Vars: 2, Bytecode: []Instruction{
+				{Opcode: OpGetVar, Operand: Integer(0)},
+				{Opcode: OpGetVar, Operand: Integer(1)},
+			}}}, err: "missing exit"},
+			{title: "negative variables", cs: []CompiledClause{{Vars: -1}}, err: "-1 variables"},
+			{title: "too many variables", cs: []CompiledClause{{Vars: 1 << 40, Bytecode: []Instruction{
+				{Opcode: OpGetVar, Operand: Integer(0)},
+				{Opcode: OpGetVar, Operand: Integer(1 << 39)},
+				{Opcode: OpExit},
+			}}}, err: "1099511627776 variables"},
+			{title: "host object", cs: []CompiledClause{{Vars: 1, Bytecode: []Instruction{
+				{Opcode: OpGetConst, Operand: &Stream{}},
+				{Opcode: OpGetVar, Operand: Integer(0)},
+				{Opcode: OpExit},
+			}}}, err: "get_const with host object"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.title, func(t *testing.T) {
+				var vm VM
+				assert.ErrorContains(t, vm.LoadCompiled(PI("foo", 2), tt.cs), tt.err)
+				_, ok := vm.getProcedure(procedureIndicator{name: NewAtom("foo"), arity: 2})
+				assert.False(t, ok)
+			})
+		}
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		var vm VM
+		assert.NoError(t, vm.Compile(context.Background(), `foo.`))
+		cs, err := src.CompiledClauses(PI("rev", 2))
+		assert.NoError(t, err)
+		assert.Equal(t, permissionError(operationModify, permissionTypeStaticProcedure, PI("foo", 0), nil), vm.LoadCompiled(PI("foo", 0), cs[:0]))
+		assert.NoError(t, vm.LoadCompiled(PI("rev", 2), cs))
+		assert.Error(t, vm.LoadCompiled(PI("rev", 2), cs))
+
+		ok, err := Call(&vm, NewAtom("foo"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("foreign", func(t *testing.T) {
+		var vm VM
+		vm.Register0(NewAtom("foo"), func(_ *VM, k Cont, env *Env) *Promise {
+			return k(env)
+		})
+		assert.Equal(t, permissionError(operationModify, permissionTypeStaticProcedure, PI("foo", 0), nil), vm.LoadCompiled(PI("foo", 0), nil))
+		_, err := vm.CompiledClauses(PI("foo", 0))
+		assert.ErrorIs(t, err, errNotUserDefined)
+		_, err = vm.CompiledClauses(PI("bar", 0))
+		assert.Error(t, err)
+	})
+}
@@ -263,28 +263,78 @@ func writeCompoundFunctionalNotation(w io.Writer, c Compound, opts *WriteOptions
 
 // CompareCompound compares the Compound with a Term.
 func CompareCompound(c Compound, t Term, env *Env) int {
-	env.charge(MeterCompareStep, 1)
-	switch t := env.Resolve(t).(type) {
-	case Compound:
-		switch x, y := c.Arity(), t.Arity(); {
-		case x > y:
+	// The pairs of arguments yet to compare are kept in an explicit stack instead of Go recursion so that deeply
+	// nested terms e.g. long lists don't overflow the goroutine stack.
+	var (
+		stack []termPair
+		guard cycleGuard
+	)
+	for {
+		env.charge(MeterCompareStep, 1)
+		switch t := env.Resolve(t).(type) {
+		case Compound:
+			if guard.visit(c, t) {
+				break // c and t are already being compared, i.e. they're cyclic.
+			}
+
+			switch x, y := c.Arity(), t.Arity(); {
+			case x > y:
+				return 1
+			case x < y:
+				return -1
+			}
+
+			if o := c.Functor().Compare(t.Functor(), env); o != 0 {
+				return o
+			}
+
+			for i := c.Arity() - 1; i >= 0; i-- {
+				stack = append(stack, termPair{x: c.Arg(i), y: t.Arg(i)})
+			}
+		default:
 			return 1
-		case x < y:
-			return -1
 		}
 
-		if o := c.Functor().Compare(t.Functor(), env); o != 0 {
-			return o
+		for {
+			if len(stack) == 0 {
+				return 0
+			}
+			var p termPair
+			p, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			x, ok := structural(p.x, env)
+			if !ok {
+				if o := p.x.Compare(p.y, env); o != 0 {
+					return o
+				}
+				continue
+			}
+			c, t = x, p.y
+			break
 		}
+	}
+}
 
-		for i := 0; i < c.Arity(); i++ {
-			if o := c.Arg(i).Compare(t.Arg(i), env); o != 0 {
-				return o
-			}
+// structural returns the compound t refers to if it's compared by CompareCompound. A bound variable is charged
+// as Variable.Compare does before it delegates the comparison to its value.
+func structural(t Term, env *Env) (Compound, bool) {
+	if v, ok := t.(Variable); ok {
+		w := env.Resolve(v)
+		if _, ok := w.(Variable); ok {
+			return nil, false
 		}
-		return 0
+		c, ok := structural(w, env)
+		if ok {
+			env.charge(MeterCompareStep, 1)
+		}
+		return c, ok
+	}
+	switch t := t.(type) {
+	case *compound, list, *partial, charList, codeList:
+		return t.(Compound), true
+	case *dict:
+		return &t.compound, true
 	default:
-		return 1
+		return nil, false
 	}
 }
 
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"bytes"
+	"runtime/debug"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -197,3 +198,30 @@ func TestCodeList_WriteTerm(t *testing.T) {
 func TestCodeList_Compare(t *testing.T) {
 	assert.Equal(t, 0, CodeList("abc").Compare(List(Integer('a'), Integer('b'), Integer('c')), nil))
 }
+
+func TestCompareCompound_deep(t *testing.T) {
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	var env *Env
+	var x, y Term = NewAtom("a"), NewAtom("b")
+	for i := 0; i < 100000; i++ {
+		v := NewVariable()
+		env = env.bind(v, y)
+		x, y = Cons(Integer(i), x), Cons(Integer(i), v)
+	}
+	assert.Equal(t, -1, x.Compare(y, env))
+	assert.Equal(t, 1, y.Compare(x, env))
+	assert.Equal(t, 0, x.Compare(x, env))
+}
+
+func TestCompareCompound_cyclic(t *testing.T) {
+	x, y, z := NewVariable(), NewVariable(), NewVariable()
+	env := NewEnv().
+		bind(x, NewAtom("f").Apply(x, NewAtom("a"))).
+		bind(y, NewAtom("f").Apply(NewAtom("f").Apply(y, NewAtom("a")), NewAtom("a"))).
+		bind(z, NewAtom("f").Apply(z, NewAtom("b")))
+
+	assert.Equal(t, 0, x.Compare(y, env))
+	assert.Equal(t, -1, x.Compare(z, env))
+	assert.Equal(t, 1, z.Compare(y, env))
+}
@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// CoverageEntry is the coverage of a user-defined predicate.
+type CoverageEntry struct {
+	Predicate string // the predicate indicator, e.g. foo/1.
+
+	// Calls, Exits, Redos, and Fails are the numbers of the times the goals of the predicate passed through the ports.
+	Calls, Exits, Redos, Fails uint64
+
+	// Clauses are the coverages of the clauses of the predicate in the order of the database, so that the index of a
+	// clause in Clauses is its index in the predicate. A clause whose body is a disjunction at the top level, e.g.
+	// (foo :- a ; b), counts once even though its alternatives are tried one after the other.
+	Clauses []ClauseCoverage
+}
+
+// ClauseCoverage is the coverage of a clause.
+type ClauseCoverage struct {
+	// File and Line locate the clause in the Prolog text it's loaded from. File is "" if the clause isn't loaded from a
+	// file, e.g. by VM.Compile or assertz/1, and Line is 0 if the clause isn't compiled from a text, e.g. by assertz/1.
+	File string
+	Line int
+	// Tries is the number of the times the clause was tried for a goal, whether its head unified with the goal or not.
+	Tries uint64
+}
+
+// CoverageReport is the coverage of the user-defined predicates of the module user while the VM was collecting it.
+type CoverageReport struct {
+	Entries []CoverageEntry // sorted by Predicate, including the predicates never called.
+}
+
+// Clauses returns the number of the clauses tried at least once and the number of all the clauses.
+func (r CoverageReport) Clauses() (covered, total int) {
+	for _, e := range r.Entries {
+		for _, c := range e.Clauses {
+			if c.Tries > 0 {
+				covered++
+			}
+			total++
+		}
+	}
+	return covered, total
+}
+
+// coverage collects the ports the goals passed through and the clauses tried.
+type coverage struct {
+	mu      sync.Mutex
+	ports   map[procedureIndicator]*[4]uint64 // the counts indexed by Port.
+	clauses map[*clause]uint64
+}
+
+// SetCoverage makes the VM collect the ports the goals of the user-defined predicates pass through and the clauses it
+// tries if on is true, e.g. to measure the coverage of a rule set by its tests. The collected coverage is kept until
+// ResetCoverage.
+func (vm *VM) SetCoverage(on bool) {
+	if on && vm.coverage == nil {
+		vm.coverage = &coverage{}
+	}
+	vm.covering = on
+}
+
+// ResetCoverage discards the coverage collected so far.
+func (vm *VM) ResetCoverage() {
+	if vm.coverage == nil {
+		return
+	}
+	vm.coverage.mu.Lock()
+	defer vm.coverage.mu.Unlock()
+	vm.coverage.ports = nil
+	vm.coverage.clauses = nil
+}
+
+// Coverage returns the coverage collected so far of the user-defined predicates currently in the module user.
+func (vm *VM) Coverage() CoverageReport {
+	var r CoverageReport
+	if vm.procedures == nil {
+		return r
+	}
+	cov := vm.coverage
+	if cov == nil {
+		cov = &coverage{}
+	}
+	cov.mu.Lock()
+	defer cov.mu.Unlock()
+	for p := vm.procedures.Oldest(); p != nil; p = p.Next() {
+		u, ok := p.Value.(*userDefined)
+		if !ok {
+			continue
+		}
+		e := CoverageEntry{Predicate: p.Key.String()}
+		if ports, ok := cov.ports[p.Key]; ok {
+			e.Calls = atomic.LoadUint64(&ports[PortCall])
+			e.Exits = atomic.LoadUint64(&ports[PortExit])
+			e.Redos = atomic.LoadUint64(&ports[PortRedo])
+			e.Fails = atomic.LoadUint64(&ports[PortFail])
+		}
+		var last *clause // the clause of the last element of e.Clauses.
+		for _, c := range u.clauses {
+			if c.erased != 0 {
+				continue
+			}
+			if last != nil && c.alt > last.alt && variant(c.raw, last.raw, nil) {
+				// Another alternative of the body of the last clause, which is tried whenever the clause is.
+				if cc := &e.Clauses[len(e.Clauses)-1]; cov.clauses[c] > cc.Tries {
+					cc.Tries = cov.clauses[c]
+				}
+				continue
+			}
+			last = c
+			e.Clauses = append(e.Clauses, ClauseCoverage{
+				File:  c.file,
+				Line:  c.line,
+				Tries: cov.clauses[c],
+			})
+		}
+		r.Entries = append(r.Entries, e)
+	}
+	slices.SortFunc(r.Entries, func(a, b CoverageEntry) int {
+		return cmp.Compare(a.Predicate, b.Predicate)
+	})
+	return r
+}
+
+func (c *coverage) portCounts(pi procedureIndicator) *[4]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ps, ok := c.ports[pi]
+	if !ok {
+		if c.ports == nil {
+			c.ports = map[procedureIndicator]*[4]uint64{}
+		}
+		ps = &[4]uint64{}
+		c.ports[pi] = ps
+	}
+	return ps
+}
+
+func (c *coverage) tried(cl *clause) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.clauses == nil {
+		c.clauses = map[*clause]uint64{}
+	}
+	c.clauses[cl]++
+}
+
+// coverCall calls p by call and counts the ports the goal passes through to the coverage of pi.
+// The goal exits without a choice point of its own, so that a deterministic goal leaves none behind. Its exits after the
+// first one are counted as redos since the execution backtracked into it to get them.
+func (vm *VM) coverCall(pi procedureIndicator, call procedureCaller, p procedure, args []Term, traced bool, k Cont, env *Env) *Promise {
+	ports := vm.coverage.portCounts(pi)
+	atomic.AddUint64(&ports[PortCall], 1)
+	var exited bool
+	return Delay(func(context.Context) *Promise {
+		return call(pi, p, args, traced, func(env *Env) *Promise {
+			if exited {
+				atomic.AddUint64(&ports[PortRedo], 1)
+			}
+			exited = true
+			atomic.AddUint64(&ports[PortExit], 1)
+			return k(env)
+		}, env)
+	}, func(context.Context) *Promise {
+		atomic.AddUint64(&ports[PortFail], 1)
+		return Bool(false)
+	})
+}
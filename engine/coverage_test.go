@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_SetCoverage(t *testing.T) {
+	var vm VM
+	assert.NoError(t, vm.CompileReader(context.Background(), "rules.pl", strings.NewReader(`
+:-(foo(X), bar(X)).
+bar(a).
+bar(b).
+bar(c).
+:-(baz, ;(bar(d), bar(c))).
+`)))
+	assert.Equal(t, CoverageReport{Entries: []CoverageEntry{
+		{Predicate: "bar/1", Clauses: []ClauseCoverage{{File: "rules.pl", Line: 3}, {File: "rules.pl", Line: 4}, {File: "rules.pl", Line: 5}}},
+		{Predicate: "baz/0", Clauses: []ClauseCoverage{{File: "rules.pl", Line: 6}}},
+		{Predicate: "foo/1", Clauses: []ClauseCoverage{{File: "rules.pl", Line: 2}}},
+	}}, vm.Coverage())
+
+	vm.SetCoverage(true)
+	ok, err := Call(&vm, NewAtom("foo").Apply(NewVariable()), func(env *Env) *Promise {
+		return Bool(false)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = Call(&vm, NewAtom("bar").Apply(NewAtom("b")), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	vm.SetCoverage(false)
+	ok, err = Call(&vm, NewAtom("baz"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	r := vm.Coverage()
+	assert.Equal(t, CoverageReport{Entries: []CoverageEntry{
+		{Predicate: "bar/1", Calls: 2, Exits: 4, Redos: 2, Fails: 1, Clauses: []ClauseCoverage{{File: "rules.pl", Line: 3, Tries: 2}, {File: "rules.pl", Line: 4, Tries: 2}, {File: "rules.pl", Line: 5, Tries: 1}}},
+		{Predicate: "baz/0", Clauses: []ClauseCoverage{{File: "rules.pl", Line: 6}}},
+		{Predicate: "foo/1", Calls: 1, Exits: 3, Redos: 2, Fails: 1, Clauses: []ClauseCoverage{{File: "rules.pl", Line: 2, Tries: 1}}},
+	}}, r)
+	covered, total := r.Clauses()
+	assert.Equal(t, 4, covered)
+	assert.Equal(t, 5, total)
+
+	vm.ResetCoverage()
+	covered, _ = vm.Coverage().Clauses()
+	assert.Zero(t, covered)
+
+	t.Run("disjunction", func(t *testing.T) {
+		vm.SetCoverage(true)
+		defer vm.SetCoverage(false)
+		ok, err := Call(&vm, NewAtom("baz"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		e := vm.Coverage().Entries[1]
+		assert.Equal(t, "baz/0", e.Predicate)
+		assert.Equal(t, []ClauseCoverage{{File: "rules.pl", Line: 6, Tries: 1}}, e.Clauses)
+	})
+
+	t.Run("deterministic exit", func(t *testing.T) {
+		vm.ResetCoverage()
+		vm.SetCoverage(true)
+		defer vm.SetCoverage(false)
+		var exits int
+		ok, err := Call(&vm, NewAtom("bar").Apply(NewAtom("c")), func(*Env) *Promise {
+			exits++
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, 1, exits)
+		e := vm.Coverage().Entries[0]
+		assert.Equal(t, "bar/1", e.Predicate)
+		assert.Equal(t, [4]uint64{1, 1, 0, 1}, [4]uint64{e.Calls, e.Exits, e.Redos, e.Fails})
+	})
+}
@@ -131,8 +131,12 @@ func init() {
 		{name: atomEmptyBlock, arity: 1}: func(args []Term, list, rest Term, env *Env) (Term, error) {
 			return atomComma.Apply(args[0], atomEqual.Apply(list, rest)), nil
 		},
-		{name: atomCall, arity: 1}: func(args []Term, list, rest Term, env *Env) (Term, error) {
-			return atomCall.Apply(args[0], list, rest), nil
+		{name: atomColon, arity: 2}: func(args []Term, list, rest Term, env *Env) (Term, error) {
+			g, err := dcgBody(args[1], list, rest, env)
+			if err != nil {
+				return nil, err
+			}
+			return atomColon.Apply(args[0], g), nil
 		},
 		{name: atomPhrase, arity: 1}: func(args []Term, list, rest Term, env *Env) (Term, error) {
 			return atomPhrase.Apply(args[0], list, rest), nil
@@ -161,6 +165,13 @@ func init() {
 			return atomThen.Apply(cond, then), nil
 		},
 	}
+
+	// call//N calls the closure with the additional arguments and the difference list.
+	for n := 1; n <= 6; n++ {
+		dcgConstr[procedureIndicator{name: atomCall, arity: Integer(n)}] = func(args []Term, list, rest Term, env *Env) (Term, error) {
+			return atomCall.Apply(append(args, list, rest)...), nil
+		}
+	}
 }
 
 func dcgBody(term, list, rest Term, env *Env) (Term, error) {
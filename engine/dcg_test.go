@@ -37,3 +37,26 @@ func TestVM_Phrase(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestDCGBody(t *testing.T) {
+	s0, s := NewVariable(), NewVariable()
+	foo, m := NewAtom("foo"), NewAtom("m")
+
+	tests := []struct {
+		title string
+		body  Term
+		goal  Term
+	}{
+		{title: "call//1", body: atomCall.Apply(foo), goal: atomCall.Apply(foo, s0, s)},
+		{title: "call//3", body: atomCall.Apply(foo, Integer(1), Integer(2)), goal: atomCall.Apply(foo, Integer(1), Integer(2), s0, s)},
+		{title: "qualified", body: atomColon.Apply(m, foo.Apply(Integer(1))), goal: atomColon.Apply(m, foo.Apply(Integer(1), s0, s))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			goal, err := dcgBody(tt.body, s0, s, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.goal, goal)
+		})
+	}
+}
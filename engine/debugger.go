@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Port is a point where the control flows into or out of a goal.
+type Port int
+
+// Ports of the 4-port model.
+const (
+	PortCall Port = iota // the goal is called.
+	PortExit             // the goal succeeded.
+	PortRedo             // the execution backtracks into the goal to find another solution.
+	PortFail             // the goal has no more solutions.
+)
+
+func (p Port) String() string {
+	return [...]string{
+		PortCall: "call",
+		PortExit: "exit",
+		PortRedo: "redo",
+		PortFail: "fail",
+	}[p]
+}
+
+// PortHookFunc is a type for a hook function that is triggered when a goal passes through a port while the VM is in
+// trace mode or the procedure of the goal is spied. depth is the number of the goals in progress including goal.
+// If the hook function returns an error, the VM halts execution and returns the error.
+type PortHookFunc func(port Port, goal Term, depth int, env *Env) error
+
+// DebugPortHookFn is a function that returns a port hook function that prints the ports the goals pass through.
+func DebugPortHookFn(w io.Writer) PortHookFunc {
+	return func(port Port, goal Term, depth int, env *Env) error {
+		_, _ = fmt.Fprintf(w, "%s(%d) ", port, depth)
+		_ = goal.WriteTerm(w, &defaultWriteOptions, env)
+		_, _ = io.WriteString(w, "\n")
+		return nil
+	}
+}
+
+// InstallPortHook sets the given port hook function in the VM.
+func (vm *VM) InstallPortHook(f PortHookFunc) {
+	vm.portHook = f
+}
+
+// ClearPortHook removes the installed port hook function from the VM.
+func (vm *VM) ClearPortHook() {
+	vm.portHook = nil
+}
+
+// debugging reports whether the VM observes the ports of the goals.
+func (vm *VM) debugging() bool {
+	return vm.portHook != nil && (vm.tracing || len(vm.spied) > 0)
+}
+
+// debugCall calls p and triggers the port hook as the control flows through the goal if the VM is in trace mode or p
+// is spied. Otherwise, it only keeps track of the depth.
+func (vm *VM) debugCall(pi procedureIndicator, p procedure, args []Term, traced bool, k Cont, env *Env) *Promise {
+	_, spied := vm.spied[pi]
+	observed := vm.tracing || spied
+	goal := pi.name.Apply(args...)
+	depth := env.callDepth() + 1
+	port := func(port Port, env *Env) error {
+		if !observed {
+			return nil
+		}
+		return vm.portHook(port, goal, depth, env)
+	}
+
+	if err := port(PortCall, env); err != nil {
+		return Error(err)
+	}
+	exit := func(env *Env) *Promise {
+		if err := port(PortExit, env); err != nil {
+			return Error(err)
+		}
+		return Delay(func(context.Context) *Promise {
+			return k(env.withCallDepth(depth - 1))
+		}, func(context.Context) *Promise {
+			if err := port(PortRedo, env); err != nil {
+				return Error(err)
+			}
+			return Bool(false)
+		})
+	}
+	return Delay(func(context.Context) *Promise {
+		env := env.withCallDepth(depth)
+		if traced {
+			return vm.traceCall(pi, p, args, exit, env)
+		}
+		return p.call(vm, args, exit, env)
+	}, func(context.Context) *Promise {
+		if err := port(PortFail, env); err != nil {
+			return Error(err)
+		}
+		return Bool(false)
+	})
+}
+
+func (e *Env) callDepth() int {
+	if e == nil {
+		return 0
+	}
+	return e.depth
+}
+
+func (e *Env) withCallDepth(depth int) *Env {
+	var ret Env
+	if e == nil {
+		ret = *rootEnv
+	} else {
+		ret = *e
+	}
+	ret.depth = depth
+	return &ret
+}
+
+// Trace puts the VM in trace mode so that every goal triggers the port hook installed by InstallPortHook.
+func Trace(vm *VM, k Cont, env *Env) *Promise {
+	vm.tracing = true
+	return k(env)
+}
+
+// NoTrace puts the VM out of trace mode. The spied procedures keep triggering the port hook.
+func NoTrace(vm *VM, k Cont, env *Env) *Promise {
+	vm.tracing = false
+	return k(env)
+}
+
+// Spy makes the goals of the procedure identified by the predicate indicator pi trigger the port hook installed by
+// InstallPortHook even if the VM is not in trace mode.
+func Spy(vm *VM, pi Term, k Cont, env *Env) *Promise {
+	key, err := toProcedureIndicator(pi, env)
+	if err != nil {
+		return Error(err)
+	}
+	if vm.spied == nil {
+		vm.spied = map[procedureIndicator]struct{}{}
+	}
+	vm.spied[key] = struct{}{}
+	return k(env)
+}
+
+// NoSpy removes the spy point of the procedure identified by the predicate indicator pi.
+func NoSpy(vm *VM, pi Term, k Cont, env *Env) *Promise {
+	key, err := toProcedureIndicator(pi, env)
+	if err != nil {
+		return Error(err)
+	}
+	delete(vm.spied, key)
+	return k(env)
+}
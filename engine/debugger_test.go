@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_InstallPortHook(t *testing.T) {
+	foo, bar := NewAtom("foo"), NewAtom("bar")
+
+	newVM := func(buf *bytes.Buffer) *VM {
+		var vm VM
+		vm.InstallPortHook(DebugPortHookFn(buf))
+		assert.NoError(t, vm.Compile(context.Background(), `
+:-(foo(X), bar(X)).
+bar(a).
+bar(b).
+`))
+		return &vm
+	}
+
+	t.Run("trace", func(t *testing.T) {
+		var buf bytes.Buffer
+		vm := newVM(&buf)
+
+		ok, err := Trace(vm, func(env *Env) *Promise {
+			return Call(vm, foo.Apply(NewAtom("b")), Failure, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, `call(1) foo(b)
+call(2) bar(b)
+exit(2) bar(b)
+exit(1) foo(b)
+redo(1) foo(b)
+redo(2) bar(b)
+fail(2) bar(b)
+fail(1) foo(b)
+`, buf.String())
+	})
+
+	t.Run("notrace", func(t *testing.T) {
+		var buf bytes.Buffer
+		vm := newVM(&buf)
+
+		ok, err := Trace(vm, func(env *Env) *Promise {
+			return NoTrace(vm, func(env *Env) *Promise {
+				return Call(vm, foo.Apply(NewAtom("a")), Success, env)
+			}, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Empty(t, buf.String())
+	})
+
+	t.Run("spy", func(t *testing.T) {
+		var buf bytes.Buffer
+		vm := newVM(&buf)
+
+		ok, err := Spy(vm, atomSlash.Apply(bar, Integer(1)), func(env *Env) *Promise {
+			return Call(vm, foo.Apply(NewAtom("a")), Success, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, `call(2) bar(a)
+exit(2) bar(a)
+`, buf.String())
+
+		buf.Reset()
+		ok, err = NoSpy(vm, atomSlash.Apply(bar, Integer(1)), func(env *Env) *Promise {
+			return Call(vm, foo.Apply(NewAtom("a")), Success, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Empty(t, buf.String())
+	})
+
+	t.Run("depth of siblings", func(t *testing.T) {
+		var depths []int
+		var vm VM
+		vm.InstallPortHook(func(port Port, _ Term, depth int, _ *Env) error {
+			if port == PortCall {
+				depths = append(depths, depth)
+			}
+			return nil
+		})
+		vm.Register0(NewAtom("true"), func(_ *VM, k Cont, env *Env) *Promise {
+			return k(env)
+		})
+		assert.NoError(t, vm.Compile(context.Background(), `
+:-(foo, ','(bar, bar)).
+:-(bar, true).
+`))
+
+		ok, err := Trace(&vm, func(env *Env) *Promise {
+			return Call(&vm, foo, Success, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []int{1, 2, 3, 2, 3}, depths)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		errStop := errors.New("stop")
+		var vm VM
+		vm.InstallPortHook(func(Port, Term, int, *Env) error {
+			return errStop
+		})
+		assert.NoError(t, vm.Compile(context.Background(), `bar(a).`))
+
+		_, err := Trace(&vm, func(env *Env) *Promise {
+			return Call(&vm, bar.Apply(NewAtom("a")), Success, env)
+		}, nil).Force(context.Background())
+		assert.Equal(t, errStop, err)
+
+		vm.ClearPortHook()
+		ok, err := Call(&vm, bar.Apply(NewAtom("a")), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("spy with invalid predicate indicator", func(t *testing.T) {
+		var vm VM
+		_, err := Spy(&vm, NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+	})
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// deterministicMaps are the plain maps in the state of a VM which don't make queries nondeterministic, along with the
+// reasons why. A new map in the state of a VM has to be vetted here or DeterminismAudit reports it.
+var deterministicMaps = map[string]string{
+	"arenas":             "not visible to queries",
+	"globals":            "looked up by key only",
+	"autoloads":          "looked up by key only",
+	"charConversions":    "enumerated in the order of the code points by current_char_conversion/2",
+	"unaryFunctions":     "looked up by key only",
+	"binaryFunctions":    "looked up by key only",
+	"streams.aliases":    "looked up by key only, the streams are enumerated in the order they're added",
+	"traced":             "looked up by key only",
+	"spied":              "looked up by key only",
+	"slicing.procedures": "looked up by key only",
+	"slicing.clauses":    "looked up by key only",
+	"modules":            "looked up by key only",
+	"moduleFiles":        "looked up by key only",
+	"imports":            "looked up by key only",
+	"tables":             "looked up by key or enumerated to delete entries",
+	"profiler.entries":   "sorted by VM.Profile",
+}
+
+// DeterminismAudit walks the state of vm and reports the plain maps holding entries which aren't vetted as
+// deterministic. Since the iteration order of a map is random, enumerating one in a query would make the query answer
+// differently on different runs, e.g. on the nodes of a chain. An empty report means that the state of vm is backed by
+// ordered structures or by maps which are never enumerated in a way visible to queries.
+func DeterminismAudit(vm *VM) []string {
+	var report []string
+	auditMaps(reflect.ValueOf(vm), "", deterministicMaps, map[uintptr]bool{}, &report)
+	return report
+}
+
+// auditMaps appends to report the non-empty maps in v which aren't in vetted. It descends into the structs and the
+// pointers to structs defined in the engine package but neither into the maps, the slices, nor the other VMs, e.g. the
+// one a stream is bound to.
+func auditMaps(v reflect.Value, path string, vetted map[string]string, seen map[uintptr]bool, report *[]string) {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Len() == 0 {
+			return
+		}
+		if _, ok := vetted[path]; ok {
+			return
+		}
+		*report = append(*report, fmt.Sprintf("%s: %s with %d entries", path, v.Type(), v.Len()))
+	case reflect.Pointer:
+		if v.IsNil() || seen[v.Pointer()] || (path != "" && v.Type() == reflect.TypeOf((*VM)(nil))) {
+			return
+		}
+		seen[v.Pointer()] = true
+		auditMaps(v.Elem(), path, vetted, seen, report)
+	case reflect.Struct:
+		if v.Type().PkgPath() != reflect.TypeOf(VM{}).PkgPath() {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			p := v.Type().Field(i).Name
+			if path != "" {
+				p = path + "." + p
+			}
+			auditMaps(v.Field(i), p, vetted, seen, report)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeterminismAudit(t *testing.T) {
+	t.Run("vetted", func(t *testing.T) {
+		var vm VM
+		vm.SetProfiling(true)
+		_, err := NbSetval(&vm, NewAtom("k"), Integer(1), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		vm.Register0(NewAtom("foo"), func(*VM, Cont, *Env) *Promise {
+			return Bool(true)
+		})
+		_, err = Call(&vm, NewAtom("foo"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Empty(t, DeterminismAudit(&vm))
+	})
+
+	t.Run("not vetted", func(t *testing.T) {
+		type inner struct {
+			vetted   map[Atom]int
+			unvetted map[Atom]int
+		}
+		type state struct {
+			empty  map[Atom]int
+			inner  inner
+			ptr    *inner
+			cyclic *state
+		}
+		s := state{
+			empty: map[Atom]int{},
+			inner: inner{vetted: map[Atom]int{"a": 1}, unvetted: map[Atom]int{"a": 1, "b": 2}},
+		}
+		s.ptr = &s.inner
+		s.cyclic = &s
+
+		var report []string
+		auditMaps(reflect.ValueOf(&s), "", map[string]string{"inner.vetted": "test"}, map[uintptr]bool{}, &report)
+		assert.Equal(t, []string{
+			"inner.unvetted: map[engine.Atom]int with 2 entries",
+			"ptr.vetted: map[engine.Atom]int with 1 entries",
+			"ptr.unvetted: map[engine.Atom]int with 2 entries",
+		}, report)
+	})
+}
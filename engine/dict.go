@@ -7,11 +7,13 @@ import (
 	"io"
 	"iter"
 	"sort"
+	"sync"
 )
 
 var (
-	errInvalidDict = errors.New("invalid dict")
-	errKeyExpected = errors.New("key expected")
+	errInvalidDict          = errors.New("invalid dict")
+	errKeyExpected          = errors.New("key expected")
+	errReservedDictFunction = errors.New("reserved dict function")
 )
 
 var (
@@ -21,6 +23,10 @@ var (
 // dictFunction is the type for (predefined) functions that can be called on a Dict.
 type dictFunction func(*VM, []Term, Term, Term, Cont, *Env) *Promise
 
+// DictFunction is the type for user-defined functions that can be called on a Dict with `./3`.
+// args are the arguments of the function call, dict is the receiver and result the term to unify with the outcome.
+type DictFunction func(vm *VM, args []Term, dict, result Term, k Cont, env *Env) *Promise
+
 var (
 	// predefinedFuncs are the predefined (reserved) functions that can be called on a Dict.
 	predefinedFuncs = map[Atom]map[int]dictFunction{
@@ -39,12 +45,66 @@ var (
 		},
 		// TODO: to continue (https://www.swi-prolog.org/pldoc/man?section=ext-dicts-predefined)
 	}
+
+	userFuncsMu sync.RWMutex
+	// userFuncs are the functions registered by the host with RegisterDictFunction.
+	userFuncs = map[Atom]DictFunction{}
 )
 
+// RegisterDictFunction registers a function that can be called on a Dict with `./3`, e.g. `X = Dict.address()`.
+// The function receives the arguments of the call whatever its arity.
+// Predefined functions (get/1, get/2, put/1) are reserved and can't be overridden.
+// Registering a function with the same name twice replaces the previous one, and a nil fn removes it.
+func RegisterDictFunction(name Atom, fn DictFunction) error {
+	if _, ok := predefinedFuncs[name]; ok {
+		return fmt.Errorf("%w: %s", errReservedDictFunction, name)
+	}
+
+	userFuncsMu.Lock()
+	defer userFuncsMu.Unlock()
+	if fn == nil {
+		delete(userFuncs, name)
+		return nil
+	}
+	userFuncs[name] = fn
+	return nil
+}
+
+// DictFunctions returns the names of the functions that can be called on a Dict, both predefined and registered,
+// in the standard order of terms.
+func DictFunctions() []Atom {
+	userFuncsMu.RLock()
+	defer userFuncsMu.RUnlock()
+
+	names := make([]Atom, 0, len(predefinedFuncs)+len(userFuncs))
+	for name := range predefinedFuncs {
+		names = append(names, name)
+	}
+	for name := range userFuncs {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func lookupDictFunction(name Atom) (DictFunction, bool) {
+	userFuncsMu.RLock()
+	defer userFuncsMu.RUnlock()
+	fn, ok := userFuncs[name]
+	return fn, ok
+}
+
 // Dict is a term that represents a dictionary.
 //
 // Dicts are currently represented as a compound term using the functor `dict`.
 // The first argument is the tag. The remaining arguments create an array of sorted key-value pairs.
+//
+// A Dict is the same term as the compound dict(Tag, K1, V1, ..., Kn, Vn) whose keys K1, ..., Kn are in the standard
+// order: they unify, compare as equal, and are variants of each other, so that sort/2 and setof/2 keep only one of
+// them. A dict/N compound whose keys aren't in order or are duplicated isn't equivalent to any Dict. DictCompound
+// converts between both forms.
 type Dict interface {
 	Compound
 
@@ -256,6 +316,13 @@ func Op3(vm *VM, dict, function, result Term, cont Cont, env *Env) *Promise {
 				}
 				return Error(existenceError(objectTypeProcedure, function, env))
 			}
+			if f, ok := lookupDictFunction(function.Functor()); ok {
+				args := make([]Term, function.Arity())
+				for i := 0; i < function.Arity(); i++ {
+					args[i] = function.Arg(i)
+				}
+				return f(vm, args, dict, result, cont, env)
+			}
 			return Error(existenceError(objectTypeProcedure, function, env))
 		default:
 			return Error(typeError(validTypeCallable, function, env))
@@ -410,6 +477,56 @@ func DelDict4(vm *VM, key Term, dictIn Term, value Term, dictOut Term, cont Cont
 	}
 }
 
+// DictCompound converts between dict and compound, the compound dict(Tag, K1, V1, ..., Kn, Vn) of the same tag and
+// key-value pairs. If dict is a Dict, compound unifies with its pairs in the standard order of the keys. Otherwise,
+// the pairs of compound may be in any order and dict unifies with the Dict built out of them.
+func DictCompound(vm *VM, dict, compound Term, k Cont, env *Env) *Promise {
+	switch d := env.Resolve(dict).(type) {
+	case Variable:
+		break
+	case Dict:
+		args := make([]Term, d.Arity())
+		for i := range args {
+			args[i] = d.Arg(i)
+		}
+		return Unify(vm, compound, atomDict.Apply(args...), k, env)
+	default:
+		return Error(typeError(validTypeDict, d, env))
+	}
+
+	switch c := env.Resolve(compound).(type) {
+	case Variable:
+		return Error(InstantiationError(env))
+	case Compound:
+		if c.Functor() != atomDict || c.Arity()%2 == 0 {
+			return Error(typeError(validTypeDict, c, env))
+		}
+		args := make([]Term, c.Arity())
+		args[0] = c.Arg(0)
+		for i := 1; i < c.Arity(); i += 2 {
+			switch key := env.Resolve(c.Arg(i)).(type) {
+			case Variable:
+				return Error(InstantiationError(env))
+			case Atom:
+				args[i], args[i+1] = key, c.Arg(i+1)
+			default:
+				return Error(domainError(validDomainDictKey, key, env))
+			}
+		}
+		d, err := NewDict(args)
+		if err != nil {
+			var dup duplicateKeyError
+			if errors.As(err, &dup) {
+				return Error(domainError(validDomainDictKey, dup.key, env))
+			}
+			return Error(err)
+		}
+		return Unify(vm, dict, d, k, env)
+	default:
+		return Error(typeError(validTypeDict, c, env))
+	}
+}
+
 // mergeDict merge n into d returning a new Dict.
 func mergeDict(n Dict, d Dict) Dict {
 	totalLen := d.Len() + n.Len()
@@ -586,6 +586,52 @@ func TestOp3(t *testing.T) {
 	}
 }
 
+func TestRegisterDictFunction(t *testing.T) {
+	point := makeDict(NewAtom("point"), NewAtom("x"), Integer(1), NewAtom("y"), Integer(2))
+	sum := func(vm *VM, args []Term, dict, result Term, k Cont, env *Env) *Promise {
+		d := env.Resolve(dict).(Dict)
+		x, _ := d.Value(NewAtom("x"))
+		y, _ := d.Value(NewAtom("y"))
+		n := x.(Integer) + y.(Integer)
+		for _, a := range args {
+			n += env.Resolve(a).(Integer)
+		}
+		return Unify(vm, result, n, k, env)
+	}
+
+	t.Run("reserved", func(t *testing.T) {
+		assert.ErrorIs(t, RegisterDictFunction(NewAtom("get"), sum), errReservedDictFunction)
+		assert.ErrorIs(t, RegisterDictFunction(NewAtom("put"), sum), errReservedDictFunction)
+	})
+
+	t.Run("registered", func(t *testing.T) {
+		assert.NoError(t, RegisterDictFunction(NewAtom("sum"), sum))
+		defer func() {
+			assert.NoError(t, RegisterDictFunction(NewAtom("sum"), nil))
+		}()
+
+		assert.Equal(t, []Atom{NewAtom("get"), NewAtom("put"), NewAtom("sum")}, DictFunctions())
+
+		var vm VM
+		result := NewVariable()
+		ok, err := Op3(&vm, point, NewAtom("sum").Apply(Integer(3)), result, func(env *Env) *Promise {
+			assert.Equal(t, Integer(6), env.Resolve(result))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("unregistered", func(t *testing.T) {
+		assert.Equal(t, []Atom{NewAtom("get"), NewAtom("put")}, DictFunctions())
+
+		var vm VM
+		ok, err := Op3(&vm, point, NewAtom("sum").Apply(Integer(3)), NewVariable(), Success, nil).Force(context.Background())
+		assert.EqualError(t, err, "error(existence_error(procedure,sum(3)),root)")
+		assert.False(t, ok)
+	})
+}
+
 func TestDelDict4(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -716,6 +762,124 @@ func TestDelDict4(t *testing.T) {
 	}
 }
 
+func TestDictCompound(t *testing.T) {
+	point, x, y := NewAtom("point"), NewAtom("x"), NewAtom("y")
+	tests := []struct {
+		name      string
+		dict      Term
+		compound  Term
+		wantOK    bool
+		wantDict  Term
+		wantTerm  Term
+		wantError string
+	}{
+		{
+			name:     "dict to compound",
+			dict:     makeDict(point, x, Integer(1), y, Integer(2)),
+			compound: NewVariable(),
+			wantOK:   true,
+			wantTerm: &compound{functor: atomDict, args: []Term{point, x, Integer(1), y, Integer(2)}},
+		},
+		{
+			name:     "compound to dict",
+			dict:     NewVariable(),
+			compound: atomDict.Apply(point, y, Integer(2), x, Integer(1)),
+			wantOK:   true,
+			wantDict: makeDict(point, x, Integer(1), y, Integer(2)),
+		},
+		{
+			name:     "both",
+			dict:     makeDict(point, x, Integer(1)),
+			compound: atomDict.Apply(point, x, Integer(1)),
+			wantOK:   true,
+		},
+		{
+			name:     "unsorted compound",
+			dict:     makeDict(point, x, Integer(1), y, Integer(2)),
+			compound: atomDict.Apply(point, y, Integer(2), x, Integer(1)),
+		},
+		{
+			name:      "both variables",
+			dict:      NewVariable(),
+			compound:  NewVariable(),
+			wantError: "error(instantiation_error,root)",
+		},
+		{
+			name:      "not a dict",
+			dict:      Integer(42),
+			compound:  NewVariable(),
+			wantError: "error(type_error(dict,42),root)",
+		},
+		{
+			name:      "not a dict compound",
+			dict:      NewVariable(),
+			compound:  NewAtom("foo").Apply(point, x, Integer(1)),
+			wantError: "error(type_error(dict,foo(point,x,1)),root)",
+		},
+		{
+			name:      "missing value",
+			dict:      NewVariable(),
+			compound:  atomDict.Apply(point, x),
+			wantError: "error(type_error(dict,dict(point,x)),root)",
+		},
+		{
+			name:      "invalid key",
+			dict:      NewVariable(),
+			compound:  atomDict.Apply(point, Integer(1), Integer(2)),
+			wantError: "error(domain_error(dict_key,1),root)",
+		},
+		{
+			name:      "duplicate key",
+			dict:      NewVariable(),
+			compound:  atomDict.Apply(point, x, Integer(1), x, Integer(2)),
+			wantError: "error(domain_error(dict_key,x),root)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var vm VM
+			var contEnv *Env
+			ok, err := DictCompound(&vm, tt.dict, tt.compound, func(env *Env) *Promise {
+				contEnv = env
+				return Bool(true)
+			}, nil).Force(context.Background())
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantDict != nil {
+				assert.Equal(t, tt.wantDict, contEnv.Resolve(tt.dict))
+			}
+			if tt.wantTerm != nil {
+				assert.Equal(t, tt.wantTerm, contEnv.Resolve(tt.compound))
+			}
+		})
+	}
+
+	t.Run("setof", func(t *testing.T) {
+		var vm VM
+		vm.Register1(NewAtom("data"), func(vm *VM, t Term, k Cont, env *Env) *Promise {
+			return Delay(func(context.Context) *Promise {
+				return Unify(vm, t, makeDict(point, x, Integer(1)), k, env)
+			}, func(context.Context) *Promise {
+				return Unify(vm, t, atomDict.Apply(point, x, Integer(1)), k, env)
+			})
+		})
+		d, s := NewVariable(), NewVariable()
+		ok, err := SetOf(&vm, d, NewAtom("data").Apply(d), s, func(env *Env) *Promise {
+			l, err := slice(s, env)
+			assert.NoError(t, err)
+			assert.Len(t, l, 1)
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
 func TestWriteDict(t *testing.T) {
 	tests := []struct {
 		name    string
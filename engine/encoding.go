@@ -0,0 +1,257 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// HexBytes succeeds iff hex is the hexadecimal encoding of the list of bytes bytes.
+// If hex is bound, it's decoded from an atom, a string, or a list of characters or codes. Otherwise, hex is unified
+// with the atom of the lowercase encoding of bytes.
+func HexBytes(vm *VM, hx, bytes Term, k Cont, env *Env) *Promise {
+	if _, ok := env.Resolve(hx).(Variable); !ok {
+		s, err := textOf(hx, env)
+		if err != nil {
+			return Error(err)
+		}
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return Error(domainError(validDomainHex, hx, env))
+		}
+		return Unify(vm, bytes, byteList(b), k, env)
+	}
+
+	b, err := bytesOf(bytes, env)
+	if err != nil {
+		return Error(err)
+	}
+	return Unify(vm, hx, NewAtom(hex.EncodeToString(b)), k, env)
+}
+
+// Base64 succeeds iff encoded is the base64 encoding of the UTF-8 text plain.
+// If plain is bound, encoded is unified with the atom of its encoding. Otherwise, plain is unified with the atom
+// decoded from encoded, or a representation error is raised if the decoded bytes aren't UTF-8 text.
+func Base64(vm *VM, plain, encoded Term, k Cont, env *Env) *Promise {
+	return base64Text(vm, base64.StdEncoding, validDomainBase64, plain, encoded, k, env)
+}
+
+// Base64URL is like Base64 but with the URL and filename safe alphabet and without padding.
+func Base64URL(vm *VM, plain, encoded Term, k Cont, env *Env) *Promise {
+	return base64Text(vm, base64.RawURLEncoding, validDomainBase64URL, plain, encoded, k, env)
+}
+
+func base64Text(vm *VM, enc *base64.Encoding, domain validDomain, plain, encoded Term, k Cont, env *Env) *Promise {
+	if _, ok := env.Resolve(plain).(Variable); !ok {
+		s, err := textOf(plain, env)
+		if err != nil {
+			return Error(err)
+		}
+		return Unify(vm, encoded, NewAtom(enc.EncodeToString([]byte(s))), k, env)
+	}
+
+	s, err := textOf(encoded, env)
+	if err != nil {
+		return Error(err)
+	}
+	b, err := enc.DecodeString(s)
+	if err != nil {
+		return Error(domainError(domain, encoded, env))
+	}
+	if !utf8.Valid(b) {
+		return Error(representationError(flagCharacter, env))
+	}
+	return Unify(vm, plain, NewAtom(string(b)), k, env)
+}
+
+// Bech32Address succeeds iff address is the bech32 encoding of the list of bytes data with the human-readable part
+// hrp, e.g. a Cosmos account address.
+// If address is bound, it's decoded and hrp and data are unified with the atom of the human-readable part and the
+// bytes. Otherwise, address is unified with the atom of the encoding of hrp and data.
+func Bech32Address(vm *VM, address, hrp, data Term, k Cont, env *Env) *Promise {
+	if _, ok := env.Resolve(address).(Variable); !ok {
+		s, err := textOf(address, env)
+		if err != nil {
+			return Error(err)
+		}
+		h, b, err := bech32Decode(s)
+		if err != nil {
+			return Error(domainError(validDomainBech32, address, env))
+		}
+		return Unify(vm, tuple(hrp, data), tuple(NewAtom(h), byteList(b)), k, env)
+	}
+
+	h, err := textOf(hrp, env)
+	if err != nil {
+		return Error(err)
+	}
+	b, err := bytesOf(data, env)
+	if err != nil {
+		return Error(err)
+	}
+	s, err := bech32Encode(h, b)
+	if err != nil {
+		return Error(domainError(validDomainBech32, hrp, env))
+	}
+	return Unify(vm, address, NewAtom(s), k, env)
+}
+
+// bytesOf returns the bytes of the list of integers from 0 to 255.
+func bytesOf(list Term, env *Env) ([]byte, error) {
+	var b []byte
+	iter := ListIterator{List: list, Env: env}
+	for iter.Next() {
+		switch e := env.Resolve(iter.Current()).(type) {
+		case Variable:
+			return nil, InstantiationError(env)
+		case Integer:
+			if e < 0 || e > 255 {
+				return nil, typeError(validTypeByte, e, env)
+			}
+			b = append(b, byte(e))
+		default:
+			return nil, typeError(validTypeByte, e, env)
+		}
+	}
+	return b, iter.Err()
+}
+
+func byteList(b []byte) Term {
+	ts := make([]Term, len(b))
+	for i, c := range b {
+		ts[i] = Integer(c)
+	}
+	return List(ts...)
+}
+
+// Bech32 as specified by BIP-173.
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var errBech32 = errors.New("invalid bech32")
+
+func bech32Polymod(values []byte) uint32 {
+	gen := [...]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i, g := range gen {
+			if (b>>i)&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	ret := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		ret = append(ret, hrp[i]>>5)
+	}
+	ret = append(ret, 0)
+	for i := 0; i < len(hrp); i++ {
+		ret = append(ret, hrp[i]&31)
+	}
+	return ret
+}
+
+func bech32Encode(hrp string, data []byte) (string, error) {
+	if len(hrp) == 0 {
+		return "", errBech32
+	}
+	for _, r := range hrp {
+		if r < 33 || r > 126 {
+			return "", errBech32
+		}
+	}
+	hrp = strings.ToLower(hrp)
+	values, err := convertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	if len(hrp)+1+len(values)+6 > 90 {
+		return "", errBech32
+	}
+
+	polymod := bech32Polymod(append(append(bech32HRPExpand(hrp), values...), 0, 0, 0, 0, 0, 0)) ^ 1
+	var sb strings.Builder
+	_, _ = sb.WriteString(hrp)
+	_ = sb.WriteByte('1')
+	for _, v := range values {
+		_ = sb.WriteByte(bech32Charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		_ = sb.WriteByte(bech32Charset[(polymod>>(5*(5-i)))&31])
+	}
+	return sb.String(), nil
+}
+
+func bech32Decode(s string) (string, []byte, error) {
+	if len(s) > 90 {
+		return "", nil, errBech32
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errBech32
+	}
+	s = strings.ToLower(s)
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, errBech32
+	}
+	hrp := s[:pos]
+	for _, r := range hrp {
+		if r < 33 || r > 126 {
+			return "", nil, errBech32
+		}
+	}
+	values := make([]byte, 0, len(s)-pos-1)
+	for _, r := range s[pos+1:] {
+		i := strings.IndexRune(bech32Charset, r)
+		if i < 0 {
+			return "", nil, errBech32
+		}
+		values = append(values, byte(i))
+	}
+	if bech32Polymod(append(bech32HRPExpand(hrp), values...)) != 1 {
+		return "", nil, errBech32
+	}
+	data, err := convertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
+
+// convertBits regroups the bits of data from groups of from bits to groups of to bits.
+func convertBits(data []byte, from, to uint, pad bool) ([]byte, error) {
+	var (
+		acc  uint32
+		bits uint
+		ret  []byte
+		maxv = uint32(1)<<to - 1
+	)
+	for _, v := range data {
+		if uint32(v)>>from != 0 {
+			return nil, errBech32
+		}
+		acc = acc<<from | uint32(v)
+		bits += from
+		for bits >= to {
+			bits -= to
+			ret = append(ret, byte(acc>>bits&maxv))
+		}
+	}
+	switch {
+	case pad:
+		if bits > 0 {
+			ret = append(ret, byte(acc<<(to-bits)&maxv))
+		}
+	case bits >= from || acc<<(to-bits)&maxv != 0:
+		return nil, errBech32
+	}
+	return ret, nil
+}
@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHexBytes(t *testing.T) {
+	tests := []struct {
+		title     string
+		hex, list Term
+		ok        bool
+		err       error
+	}{
+		{title: "decode", hex: NewAtom("00ff7F"), list: List(Integer(0), Integer(255), Integer(127)), ok: true},
+		{title: "decode codes", hex: CodeList("0a"), list: List(Integer(10)), ok: true},
+		{title: "encode", hex: NewAtom("00ff7f"), list: List(Integer(0), Integer(255), Integer(127)), ok: true},
+		{title: "encode empty", hex: NewAtom(""), list: List(), ok: true},
+		{title: "mismatch", hex: NewAtom("00"), list: List(Integer(1)), ok: false},
+		{title: "invalid hex", hex: NewAtom("0g"), list: NewVariable(), err: domainError(validDomainHex, NewAtom("0g"), nil)},
+		{title: "odd length", hex: NewAtom("abc"), list: NewVariable(), err: domainError(validDomainHex, NewAtom("abc"), nil)},
+		{title: "not a byte", hex: NewVariable(), list: List(Integer(256)), err: typeError(validTypeByte, Integer(256), nil)},
+		{title: "partial list", hex: NewVariable(), list: PartialList(NewVariable(), Integer(1)), err: InstantiationError(nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			ok, err := HexBytes(nil, tt.hex, tt.list, Success, nil).Force(context.Background())
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+
+	t.Run("encode into a variable", func(t *testing.T) {
+		v := NewVariable()
+		ok, err := HexBytes(nil, v, List(Integer(0xca), Integer(0xfe)), func(env *Env) *Promise {
+			assert.Equal(t, NewAtom("cafe"), env.Resolve(v))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func TestBase64(t *testing.T) {
+	tests := []struct {
+		title          string
+		pred           Predicate2
+		plain, encoded Term
+		ok             bool
+		err            error
+	}{
+		{title: "encode", pred: Base64, plain: NewAtom("hello?>"), encoded: NewAtom("aGVsbG8/Pg=="), ok: true},
+		{title: "decode", pred: Base64, plain: NewVariable(), encoded: String("aGVsbG8/Pg=="), ok: true},
+		{title: "encode url", pred: Base64URL, plain: NewAtom("hello?>"), encoded: NewAtom("aGVsbG8_Pg"), ok: true},
+		{title: "decode url", pred: Base64URL, plain: NewAtom("hello?>"), encoded: NewAtom("aGVsbG8_Pg"), ok: true},
+		{title: "invalid", pred: Base64, plain: NewVariable(), encoded: NewAtom("a!"), err: domainError(validDomainBase64, NewAtom("a!"), nil)},
+		{title: "invalid url", pred: Base64URL, plain: NewVariable(), encoded: NewAtom("aGVsbG8/Pg=="), err: domainError(validDomainBase64URL, NewAtom("aGVsbG8/Pg=="), nil)},
+		{title: "binary", pred: Base64, plain: NewVariable(), encoded: NewAtom("/w=="), err: representationError(flagCharacter, nil)},
+		{title: "both variables", pred: Base64, plain: NewVariable(), encoded: NewVariable(), err: InstantiationError(nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			ok, err := tt.pred(nil, tt.plain, tt.encoded, Success, nil).Force(context.Background())
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+
+	t.Run("decoded", func(t *testing.T) {
+		v := NewVariable()
+		ok, err := Base64(nil, v, NewAtom("aGVsbG8/Pg=="), func(env *Env) *Promise {
+			assert.Equal(t, NewAtom("hello?>"), env.Resolve(v))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func TestBech32Address(t *testing.T) {
+	t.Run("decode", func(t *testing.T) {
+		hrp, data := NewVariable(), NewVariable()
+		ok, err := Bech32Address(nil, NewAtom("A12UEL5L"), hrp, data, func(env *Env) *Promise {
+			assert.Equal(t, NewAtom("a"), env.Resolve(hrp))
+			assert.Equal(t, List(), env.Resolve(data))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		bytes := make([]Term, 20)
+		for i := range bytes {
+			bytes[i] = Integer(i * 13)
+		}
+		address := NewVariable()
+		ok, err := Bech32Address(nil, address, NewAtom("cosmos"), List(bytes...), func(env *Env) *Promise {
+			a := env.Resolve(address).(Atom)
+			assert.Regexp(t, "^cosmos1[a-z0-9]{38}$", a.String())
+			return Bech32Address(nil, a, NewAtom("cosmos"), List(bytes...), Success, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("encode", func(t *testing.T) {
+		zeros := make([]Term, 20)
+		for i := range zeros {
+			zeros[i] = Integer(0)
+		}
+		address := NewVariable()
+		ok, err := Bech32Address(nil, address, NewAtom("cosmos"), List(zeros...), func(env *Env) *Promise {
+			assert.Equal(t, NewAtom("cosmos1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqnrql8a"), env.Resolve(address))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	for _, s := range []string{"a12uel5m", "A12uel5l", "12uel5l", "a1qqqqqqqqqqq", "a1b2uel5l"} {
+		t.Run("invalid "+s, func(t *testing.T) {
+			_, err := Bech32Address(nil, NewAtom(s), NewVariable(), NewVariable(), Success, nil).Force(context.Background())
+			assert.Equal(t, domainError(validDomainBech32, NewAtom(s), nil), err)
+		})
+	}
+
+	t.Run("invalid hrp", func(t *testing.T) {
+		_, err := Bech32Address(nil, NewVariable(), NewAtom(""), List(Integer(1)), Success, nil).Force(context.Background())
+		assert.Equal(t, domainError(validDomainBech32, NewAtom(""), nil), err)
+	})
+}
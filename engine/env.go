@@ -1,5 +1,7 @@
 package engine
 
+import "slices"
+
 var varContext = NewVariable()
 
 var rootContext = NewAtom("root")
@@ -16,6 +18,14 @@ func newEnvKey(v Variable) envKey {
 	return k
 }
 
+// variable returns the variable of which k is the key.
+func (k envKey) variable() Variable {
+	if k < 0 {
+		return Variable(-k)
+	}
+	return Variable(k)
+}
+
 type color uint8
 
 const (
@@ -29,13 +39,30 @@ type Env struct {
 	color       color
 	left, right *Env
 	binding
-	meter MeterFunc
+	meter   MeterFunc
+	attrs   *attributes   // only meaningful for the root.
+	flags   *queryFlags   // only meaningful for the root.
+	globals map[Atom]Term // the backtrackable global variables, only meaningful for the root.
+	frames  *frame        // only meaningful for the root.
+	depth   int           // only meaningful for the root.
 }
 
 type binding struct {
 	key   envKey
 	value Term
-	// attributes?
+}
+
+// attributes is a persistent store of variable attributes along with the attributed variables
+// that got bound since the last wake-up.
+type attributes struct {
+	vars    *Env // a tree from variables to lists of Module-Value pairs.
+	pending []wakeup
+}
+
+// wakeup is a pending call to the unification hooks of an attributed variable which got bound to value.
+type wakeup struct {
+	attrs list
+	value Term
 }
 
 var rootEnv = &Env{
@@ -87,12 +114,15 @@ func (e *Env) meterFunc() MeterFunc {
 
 // lookup returns a term that the given variable is bound to.
 func (e *Env) lookup(v Variable) (Term, bool) {
-	k := newEnvKey(v)
-
 	node := e
 	if node == nil {
 		node = rootEnv
 	}
+	return node.find(newEnvKey(v))
+}
+
+func (e *Env) find(k envKey) (Term, bool) {
+	node := e
 	for {
 		if node == nil {
 			return nil, false
@@ -116,12 +146,140 @@ func (e *Env) bind(v Variable, t Term) *Env {
 	if node == nil {
 		node = rootEnv
 	}
+	node.metrics().countEnvNode()
 	ret := *node.insert(k, t, node.meter)
 	ret.color = black
 	ret.meter = node.meter
+	ret.attrs = node.attrs
+	ret.flags = node.flags
+	ret.globals = node.globals
+	ret.frames = node.frames
+	ret.depth = node.depth
+	return &ret
+}
+
+func (e *Env) withFlags(f *queryFlags) *Env {
+	var ret Env
+	if e == nil {
+		ret = *rootEnv
+	} else {
+		ret = *e
+	}
+	ret.flags = f
+	return &ret
+}
+
+func (e *Env) occursCheck() bool {
+	return e != nil && e.flags != nil && e.flags.occursCheck
+}
+
+func (e *Env) withAttributes(a *attributes) *Env {
+	var ret Env
+	if e == nil {
+		ret = *rootEnv
+	} else {
+		ret = *e
+	}
+	ret.attrs = a
 	return &ret
 }
 
+// getAttrs returns the attributes of v as Module-Value pairs.
+func (e *Env) getAttrs(v Variable) list {
+	if e == nil || e.attrs == nil {
+		return nil
+	}
+	t, ok := e.attrs.vars.find(newEnvKey(v))
+	if !ok {
+		return nil
+	}
+	return t.(list)
+}
+
+// getAttr returns the value of the attribute module of v.
+func (e *Env) getAttr(v Variable, module Atom) (Term, bool) {
+	for _, a := range e.getAttrs(v) {
+		if p := a.(Compound); p.Arg(0) == module {
+			return p.Arg(1), true
+		}
+	}
+	return nil, false
+}
+
+// putAttr sets the value of the attribute module of v.
+func (e *Env) putAttr(v Variable, module Atom, value Term) *Env {
+	attrs := e.getAttrs(v)
+	ret := make(list, 0, len(attrs)+1)
+	found := false
+	for _, a := range attrs {
+		if a.(Compound).Arg(0) == module {
+			a, found = pair(module, value), true
+		}
+		ret = append(ret, a)
+	}
+	if !found {
+		ret = append(ret, pair(module, value))
+	}
+	return e.setAttrs(v, ret)
+}
+
+// delAttr removes the attribute module of v.
+func (e *Env) delAttr(v Variable, module Atom) *Env {
+	attrs := e.getAttrs(v)
+	ret := make(list, 0, len(attrs))
+	for _, a := range attrs {
+		if a.(Compound).Arg(0) != module {
+			ret = append(ret, a)
+		}
+	}
+	if len(ret) == len(attrs) {
+		return e
+	}
+	return e.setAttrs(v, ret)
+}
+
+func (e *Env) setAttrs(v Variable, attrs list) *Env {
+	var a attributes
+	if e != nil && e.attrs != nil {
+		a = *e.attrs
+	}
+	vars := *a.vars.insert(newEnvKey(v), attrs, nil)
+	vars.color = black
+	a.vars = &vars
+	return e.withAttributes(&a)
+}
+
+// bindVariable binds x to t and schedules a wake-up if x is an attributed variable.
+// If t is a plain variable, t is bound to x instead so that x keeps its attributes.
+func (e *Env) bindVariable(x Variable, t Term) *Env {
+	attrs := e.getAttrs(x)
+	if len(attrs) == 0 {
+		return e.bind(x, t)
+	}
+	if y, ok := t.(Variable); ok && len(e.getAttrs(y)) == 0 {
+		return e.bind(y, x)
+	}
+	e = e.bind(x, t)
+	a := *e.attrs
+	a.pending = append(a.pending[:len(a.pending):len(a.pending)], wakeup{attrs: attrs, value: t})
+	return e.withAttributes(&a)
+}
+
+func (e *Env) hasWakeups() bool {
+	return e != nil && e.attrs != nil && len(e.attrs.pending) > 0
+}
+
+// popWakeups returns the pending wake-ups and an environment without them.
+func (e *Env) popWakeups() ([]wakeup, *Env) {
+	if !e.hasWakeups() {
+		return nil, e
+	}
+	a := *e.attrs
+	ws := a.pending
+	a.pending = nil
+	return ws, e.withAttributes(&a)
+}
+
 func (e *Env) insert(k envKey, v Term, meter MeterFunc) *Env {
 	if e == nil {
 		return &Env{color: red, binding: binding{key: k, value: v}, meter: meter}
@@ -290,25 +448,36 @@ func (e *Env) freeVariables(t Term) []Variable {
 }
 
 func (e *Env) appendFreeVariables(fvs variables, t Term) variables {
-	switch t := e.Resolve(t).(type) {
-	case Variable:
-		for _, v := range fvs {
-			if v == t {
-				return fvs
+	var (
+		stack []Term
+		guard cycleGuard
+	)
+	for {
+		switch t := e.Resolve(t).(type) {
+		case Variable:
+			if !slices.Contains(fvs, t) {
+				fvs = append(fvs, t)
+			}
+		case Compound:
+			if guard.visit(t, nil) {
+				break
+			}
+			for i := t.Arity() - 1; i >= 0; i-- {
+				stack = append(stack, t.Arg(i))
 			}
 		}
-		return append(fvs, t)
-	case Compound:
-		for i := 0; i < t.Arity(); i++ {
-			fvs = e.appendFreeVariables(fvs, t.Arg(i))
+
+		if len(stack) == 0 {
+			return fvs
 		}
+		t, stack = stack[len(stack)-1], stack[:len(stack)-1]
 	}
-	return fvs
 }
 
 // Unify unifies 2 terms.
+// It performs the occurs check if the prolog flag occurs_check is true.
 func (e *Env) Unify(x, y Term) (*Env, bool) {
-	return e.unify(x, y, false)
+	return e.unify(x, y, e.occursCheck())
 }
 
 func (e *Env) unifyWithOccursCheck(x, y Term) (*Env, bool) {
@@ -316,83 +485,137 @@ func (e *Env) unifyWithOccursCheck(x, y Term) (*Env, bool) {
 }
 
 func (e *Env) unify(x, y Term, occursCheck bool) (*Env, bool) {
-	e.charge(MeterUnifyStep, 1)
-	x, y = e.Resolve(x), e.Resolve(y)
-	switch x := x.(type) {
-	case Variable:
-		switch {
-		case x == y:
-			return e, true
-		case occursCheck && contains(y, x, e):
-			return e, false
-		default:
-			return e.bind(x, y), true
+	e.metrics().countUnification()
+
+	// The pairs of arguments yet to unify are kept in an explicit stack instead of Go recursion so that deeply nested
+	// terms e.g. long lists don't overflow the goroutine stack.
+	var (
+		stack []termPair
+		guard cycleGuard
+	)
+	for {
+		e.charge(MeterUnifyStep, 1)
+		x, y = e.Resolve(x), e.Resolve(y)
+		if _, ok := x.(Variable); !ok {
+			if _, ok := y.(Variable); ok {
+				e.charge(MeterUnifyStep, 1)
+				x, y = y, x
+			}
 		}
-	case Compound:
-		switch y := y.(type) {
+		switch x := x.(type) {
 		case Variable:
-			return e.unify(y, x, occursCheck)
-		case Compound:
-			if x.Functor() != y.Functor() {
+			switch {
+			case x == y:
+			case occursCheck && contains(y, x, e):
 				return e, false
+			default:
+				e = e.bindVariable(x, y)
 			}
-			if x.Arity() != y.Arity() {
+		case Compound:
+			y, ok := y.(Compound)
+			if !ok || x.Functor() != y.Functor() || x.Arity() != y.Arity() {
 				return e, false
 			}
-			var ok bool
-			for i := 0; i < x.Arity(); i++ {
-				e, ok = e.unify(x.Arg(i), y.Arg(i), occursCheck)
-				if !ok {
+			if guard.visit(x, y) {
+				break // x and y are already being unified, i.e. they're cyclic.
+			}
+			for i := x.Arity() - 1; i >= 0; i-- {
+				stack = append(stack, termPair{x: x.Arg(i), y: y.Arg(i)})
+			}
+		default: // atomic
+			switch y := y.(type) {
+			case Float:
+				if x, ok := x.(Float); !ok || !y.Eq(x) {
+					return e, false
+				}
+			case Integer:
+				if x, ok := x.(Integer); !ok || y != x {
+					return e, false
+				}
+			default:
+				if x != y {
 					return e, false
 				}
 			}
+		}
+
+		if len(stack) == 0 {
 			return e, true
-		default:
-			return e, false
 		}
-	default: // atomic
-		switch y := y.(type) {
+		var p termPair
+		p, stack = stack[len(stack)-1], stack[:len(stack)-1]
+		x, y = p.x, p.y
+	}
+}
+
+// termPair is a pair of terms to unify or compare.
+type termPair struct {
+	x, y Term
+}
+
+// cycleGuardThreshold is the number of steps after which cycleGuard starts tracking the visited pairs.
+const cycleGuardThreshold = 1 << 10
+
+// cycleGuard tells the pairs of compounds which were already visited by an iterative algorithm on 2 terms so that it
+// terminates on cyclic terms i.e. rational trees. Since it starts tracking after cycleGuardThreshold steps, small
+// finite terms don't pay for it.
+type cycleGuard struct {
+	steps   int
+	visited map[[2]termID]struct{}
+}
+
+// visit reports whether the pair of x and y was already visited.
+func (g *cycleGuard) visit(x, y Term) bool {
+	if g.steps < cycleGuardThreshold {
+		g.steps++
+		return false
+	}
+	if g.visited == nil {
+		g.visited = map[[2]termID]struct{}{}
+	}
+	k := [2]termID{id(x), id(y)}
+	if _, ok := g.visited[k]; ok {
+		return true
+	}
+	g.visited[k] = struct{}{}
+	return false
+}
+
+func contains(t, s Term, env *Env) bool {
+	var (
+		stack []Term
+		guard cycleGuard
+	)
+	for {
+		env.charge(MeterUnifyStep, 1)
+		switch u := t.(type) {
 		case Variable:
-			return e.unify(y, x, occursCheck)
-		case Float:
-			if x, ok := x.(Float); ok {
-				return e, y.Eq(x)
+			if u == s {
+				return true
+			}
+			if ref, ok := env.lookup(u); ok {
+				t = ref
+				continue
+			}
+		case Compound:
+			if s, ok := s.(Atom); ok && u.Functor() == s {
+				return true
+			}
+			if guard.visit(u, nil) {
+				break
 			}
-			return e, false
-		case Integer:
-			if x, ok := x.(Integer); ok {
-				return e, y == x
+			for i := u.Arity() - 1; i >= 0; i-- {
+				stack = append(stack, u.Arg(i))
 			}
-			return e, false
 		default:
-			return e, x == y
+			if t == s {
+				return true
+			}
 		}
-	}
-}
 
-func contains(t, s Term, env *Env) bool {
-	env.charge(MeterUnifyStep, 1)
-	switch t := t.(type) {
-	case Variable:
-		if t == s {
-			return true
-		}
-		ref, ok := env.lookup(t)
-		if !ok {
+		if len(stack) == 0 {
 			return false
 		}
-		return contains(ref, s, env)
-	case Compound:
-		if s, ok := s.(Atom); ok && t.Functor() == s {
-			return true
-		}
-		for i := 0; i < t.Arity(); i++ {
-			if contains(t.Arg(i), s, env) {
-				return true
-			}
-		}
-		return false
-	default:
-		return t == s
+		t, stack = stack[len(stack)-1], stack[:len(stack)-1]
 	}
 }
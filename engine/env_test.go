@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand"
 	"reflect"
+	"runtime/debug"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -151,3 +152,48 @@ func TestContains(t *testing.T) {
 	assert.True(t, contains(&compound{functor: NewAtom("f"), args: []Term{NewAtom("a")}}, NewAtom("a"), env))
 	assert.False(t, contains(&compound{functor: NewAtom("f")}, NewAtom("a"), env))
 }
+
+func TestEnv_Unify_deep(t *testing.T) {
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	// deep returns f(f(...f(leaf)...)) and the same nested through a bound variable at each level.
+	deep := func(n int, leaf Term) (Term, Term, *Env) {
+		var env *Env
+		var x, y Term = leaf, leaf
+		for i := 0; i < n; i++ {
+			v := NewVariable()
+			env = env.bind(v, y)
+			x, y = NewAtom("f").Apply(x), NewAtom("f").Apply(v)
+		}
+		return x, y, env
+	}
+
+	x, y, env := deep(100000, NewAtom("a"))
+	_, ok := env.Unify(x, y)
+	assert.True(t, ok)
+	_, ok = env.Unify(x, NewAtom("f").Apply(y))
+	assert.False(t, ok)
+
+	leaf := NewVariable()
+	_, y, env = deep(100000, leaf)
+	assert.True(t, contains(y, leaf, env))
+	assert.False(t, contains(y, NewVariable(), env))
+	_, ok = env.unifyWithOccursCheck(leaf, y)
+	assert.False(t, ok)
+}
+
+func TestEnv_Unify_cyclic(t *testing.T) {
+	x, y := NewVariable(), NewVariable()
+	env := NewEnv().bind(x, NewAtom("f").Apply(x, NewAtom("a"))).bind(y, NewAtom("f").Apply(y, NewAtom("a")))
+
+	_, ok := env.Unify(x, y)
+	assert.True(t, ok)
+
+	z := NewVariable()
+	env = env.bind(z, NewAtom("f").Apply(z, NewAtom("b")))
+	_, ok = env.Unify(x, z)
+	assert.False(t, ok)
+
+	assert.False(t, contains(x, NewVariable(), env))
+	assert.Equal(t, []Variable(nil), env.freeVariables(x))
+}
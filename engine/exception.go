@@ -2,20 +2,35 @@ package engine
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
 )
 
 // Exception is an error represented by a prolog term.
 type Exception struct {
-	term Term
+	term    Term
+	frames  *frame
+	history *goalTrail
 }
 
 // NewException creates an Exception from a copy of the given Term.
+// If the VM records backtraces, the Exception captures the calls in progress in env and an error(Formal, Context)
+// term raised by a builtin gets context(Context, Backtrace) as its context instead, where Backtrace is the list of
+// the innermost frames as described by Exception.Trace.
 func NewException(term Term, env *Env) Exception {
+	f := env.topFrame()
+	if f != nil && !f.backtrace {
+		f = nil
+	}
+	if c, ok := term.(Compound); ok && f != nil && c.Functor() == atomError && c.Arity() == 2 && c.Arg(1) == varContext {
+		term = atomError.Apply(c.Arg(0), atomContext.Apply(varContext, backtraceTerm(f)))
+	}
 	c, err := renamedCopy(term, nil, env)
 	if err != nil {
 		return err.(Exception) // Must be error(resource_error(memory), _).
 	}
-	return Exception{term: c}
+	return Exception{term: c, frames: f}
 }
 
 // Term returns the underlying Term of the Exception.
@@ -23,17 +38,309 @@ func (e Exception) Term() Term {
 	return e.term
 }
 
+// StackFrame is a call in progress when an Exception was raised.
+type StackFrame struct {
+	// PI is the predicate indicator of the called procedure, e.g. foo/1.
+	PI Term
+	// File and Line are the position of the running clause in the Prolog text it was read from, or the zero values if
+	// they're unknown, e.g. for foreign predicates or asserted clauses.
+	File string
+	Line int
+}
+
+// Trace returns the calls in progress when the Exception was raised, the innermost first, if the VM records
+// backtraces. The calls which were last calls of their clauses are not part of it.
+func (e Exception) Trace() []StackFrame {
+	var ret []StackFrame
+	for f := e.frames; f != nil; f = f.parent {
+		if sf, ok := f.stackFrame(); ok {
+			ret = append(ret, sf)
+		}
+	}
+	return ret
+}
+
+// backtraceDepth is the maximum number of frames in the backtraces added to error terms.
+const backtraceDepth = 32
+
+// SetBacktraces makes the VM record the calls in progress so that exceptions carry their backtraces.
+// It costs an allocation per call.
+func (vm *VM) SetBacktraces(enabled bool) {
+	vm.backtraces = enabled
+}
+
+func (f *frame) stackFrame() (StackFrame, bool) {
+	switch {
+	case !f.backtrace:
+		return StackFrame{}, false
+	case f.clause != nil && f.clause.pi != procedureIndicator{}: // Not the clause of a goal called by call/1.
+		return StackFrame{PI: f.clause.pi.Term(), File: f.clause.file, Line: f.clause.line}, true
+	case f.pi != procedureIndicator{}:
+		return StackFrame{PI: f.pi.Term()}, true
+	default:
+		return StackFrame{}, false
+	}
+}
+
+// backtraceTerm returns a list of frame(PI, File, Line) for the innermost frames from f.
+func backtraceTerm(f *frame) Term {
+	var ret []Term
+	for ; f != nil && len(ret) < backtraceDepth; f = f.parent {
+		if sf, ok := f.stackFrame(); ok {
+			ret = append(ret, atomFrame.Apply(sf.PI, NewAtom(sf.File), Integer(sf.Line)))
+		}
+	}
+	return List(ret...)
+}
+
 func (e Exception) Error() string {
 	var buf bytes.Buffer
 	_ = e.term.WriteTerm(&buf, &defaultWriteOptions, nil)
 	return buf.String()
 }
 
+// errorClass is a sentinel error which an Exception error(Formal, Context) matches with errors.Is if the name of
+// Formal is the errorClass.
+type errorClass Atom
+
+func (c errorClass) Error() string {
+	return Atom(c).String()
+}
+
+// Sentinel errors of the ISO error classes.
+// errors.Is(err, ErrType) reports whether err is or wraps an Exception error(type_error(_, _), _) and so on.
+var (
+	ErrInstantiation   error = errorClass(atomInstantiationError)
+	ErrUninstantiation error = errorClass(atomUninstantiationError)
+	ErrType            error = errorClass(atomTypeError)
+	ErrDomain          error = errorClass(atomDomainError)
+	ErrExistence       error = errorClass(atomExistenceError)
+	ErrPermission      error = errorClass(atomPermissionError)
+	ErrRepresentation  error = errorClass(atomRepresentationError)
+	ErrEvaluation      error = errorClass(atomEvaluationError)
+	ErrResource        error = errorClass(atomResourceError)
+	ErrSyntax          error = errorClass(atomSyntaxError)
+)
+
+// Is reports whether the Exception belongs to the error class target, one of ErrInstantiation, ErrType, etc.
+func (e Exception) Is(target error) bool {
+	c, ok := target.(errorClass)
+	if !ok {
+		return false
+	}
+	name, _, ok := e.formal()
+	return ok && name == Atom(c)
+}
+
+// formal returns the name and the arguments of Formal if the Exception is error(Formal, Context).
+func (e Exception) formal() (Atom, []Term, bool) {
+	c, ok := e.term.(Compound)
+	if !ok || c.Functor() != atomError || c.Arity() != 2 {
+		return "", nil, false
+	}
+	switch f := c.Arg(0).(type) {
+	case Atom:
+		return f, nil, true
+	case Compound:
+		args := make([]Term, f.Arity())
+		for i := range args {
+			args[i] = f.Arg(i)
+		}
+		return f.Functor(), args, true
+	default:
+		return "", nil, false
+	}
+}
+
+// formalArgs returns the arguments of Formal if err is or wraps an Exception error(Formal, Context) where Formal is
+// a compound of the error class with the given arity.
+func formalArgs(err error, class error, arity int) ([]Term, bool) {
+	var e Exception
+	if !errors.As(err, &e) {
+		return nil, false
+	}
+	name, args, ok := e.formal()
+	if !ok || name != Atom(class.(errorClass)) || len(args) != arity {
+		return nil, false
+	}
+	return args, true
+}
+
+// IsInstantiationError reports whether err is or wraps an instantiation error.
+func IsInstantiationError(err error) bool {
+	return errors.Is(err, ErrInstantiation)
+}
+
+// AsTypeError returns the type and the culprit if err is or wraps an Exception error(type_error(Type, Culprit), _).
+func AsTypeError(err error) (typ, culprit Term, ok bool) {
+	args, ok := formalArgs(err, ErrType, 2)
+	if !ok {
+		return nil, nil, false
+	}
+	return args[0], args[1], true
+}
+
+// AsDomainError returns the domain and the culprit if err is or wraps an Exception
+// error(domain_error(Domain, Culprit), _).
+func AsDomainError(err error) (domain, culprit Term, ok bool) {
+	args, ok := formalArgs(err, ErrDomain, 2)
+	if !ok {
+		return nil, nil, false
+	}
+	return args[0], args[1], true
+}
+
+// AsExistenceError returns the object type and the culprit if err is or wraps an Exception
+// error(existence_error(ObjectType, Culprit), _).
+func AsExistenceError(err error) (objectType, culprit Term, ok bool) {
+	args, ok := formalArgs(err, ErrExistence, 2)
+	if !ok {
+		return nil, nil, false
+	}
+	return args[0], args[1], true
+}
+
+// AsPermissionError returns the operation, the permission type, and the culprit if err is or wraps an Exception
+// error(permission_error(Operation, PermissionType, Culprit), _).
+func AsPermissionError(err error) (operation, permissionType, culprit Term, ok bool) {
+	args, ok := formalArgs(err, ErrPermission, 3)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	return args[0], args[1], args[2], true
+}
+
+// AsRepresentationError returns the limit if err is or wraps an Exception error(representation_error(Limit), _).
+func AsRepresentationError(err error) (limit Term, ok bool) {
+	args, ok := formalArgs(err, ErrRepresentation, 1)
+	if !ok {
+		return nil, false
+	}
+	return args[0], true
+}
+
+// AsEvaluationError returns the exceptional value if err is or wraps an Exception
+// error(evaluation_error(Value), _).
+func AsEvaluationError(err error) (value Term, ok bool) {
+	args, ok := formalArgs(err, ErrEvaluation, 1)
+	if !ok {
+		return nil, false
+	}
+	return args[0], true
+}
+
+// AsResourceError returns the resource if err is or wraps an Exception error(resource_error(Resource), _).
+func AsResourceError(err error) (resource Term, ok bool) {
+	args, ok := formalArgs(err, ErrResource, 1)
+	if !ok {
+		return nil, false
+	}
+	return args[0], true
+}
+
+// AsSyntaxError returns the description if err is or wraps an Exception error(syntax_error(Description), _).
+func AsSyntaxError(err error) (description Term, ok bool) {
+	args, ok := formalArgs(err, ErrSyntax, 1)
+	if !ok {
+		return nil, false
+	}
+	return args[0], true
+}
+
+// exceptionEncodingVersion is the version of the encoding of MarshalTerm.
+const exceptionEncodingVersion = 1
+
+var errExceptionEncoding = errors.New("invalid exception encoding")
+
+// MarshalTerm returns the canonical encoding of the Exception so that it can be reconstructed by UnmarshalTerm in
+// another process.
+// The encoding is the Prolog text exception(Version, Term). where Term is written with quoted(true) and
+// ignore_ops(true) and its variables are named _0, _1, ... in the order of their first occurrence.
+// It returns an error if Term can't be read back as is, i.e. if it's cyclic or contains a dict or a host object such
+// as a stream.
+func (e Exception) MarshalTerm() ([]byte, error) {
+	if e.term == nil {
+		return nil, fmt.Errorf("%w: empty exception", errExceptionEncoding)
+	}
+	if err := checkEncodable(e.term, map[termID]struct{}{}); err != nil {
+		return nil, err
+	}
+
+	opts := WriteOptions{
+		ignoreOps:     true,
+		quoted:        true,
+		variableNames: map[Variable]Atom{},
+		priority:      1200,
+	}
+	for i, v := range (*Env)(nil).freeVariables(e.term) {
+		opts.variableNames[v] = NewAtom("_" + strconv.Itoa(i))
+	}
+
+	var buf bytes.Buffer
+	t := atomException.Apply(Integer(exceptionEncodingVersion), e.term)
+	if err := t.WriteTerm(&buf, &opts, nil); err != nil {
+		return nil, err
+	}
+	_, _ = buf.WriteString(".\n")
+	return buf.Bytes(), nil
+}
+
+// checkEncodable returns an error if t can't be written as a Prolog text which reads back as t.
+func checkEncodable(t Term, visiting map[termID]struct{}) error {
+	switch t := t.(type) {
+	case Variable, Atom, Integer, Float, Rational, String:
+		return nil
+	case Dict:
+		return fmt.Errorf("%w: dict", errExceptionEncoding)
+	case Compound:
+		if _, ok := visiting[id(t)]; ok {
+			return fmt.Errorf("%w: cyclic term", errExceptionEncoding)
+		}
+		visiting[id(t)] = struct{}{}
+		defer delete(visiting, id(t))
+		for i := 0; i < t.Arity(); i++ {
+			if err := checkEncodable(t.Arg(i), visiting); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %T", errExceptionEncoding, t)
+	}
+}
+
+// UnmarshalTerm sets the Exception to the one encoded in data by MarshalTerm.
+func (e *Exception) UnmarshalTerm(data []byte) error {
+	var vm VM
+	p := NewParser(&vm, bytes.NewReader(data))
+	p.doubleQuotes = doubleQuotesString
+	t, err := p.Term()
+	if err != nil {
+		return fmt.Errorf("%w: %w", errExceptionEncoding, err)
+	}
+
+	c, ok := t.(Compound)
+	if !ok || c.Functor() != atomException || c.Arity() != 2 {
+		return fmt.Errorf("%w: not exception/2", errExceptionEncoding)
+	}
+	if v, ok := c.Arg(0).(Integer); !ok || v != exceptionEncodingVersion {
+		return fmt.Errorf("%w: unsupported version", errExceptionEncoding)
+	}
+
+	*e = Exception{term: c.Arg(1)}
+	return nil
+}
+
 // InstantiationError returns an instantiation error exception.
 func InstantiationError(env *Env) Exception {
 	return NewException(atomError.Apply(atomInstantiationError, varContext), env)
 }
 
+// UninstantiationError returns an uninstantiation error exception.
+func UninstantiationError(culprit Term, env *Env) Exception {
+	return NewException(atomError.Apply(atomUninstantiationError.Apply(culprit), varContext), env)
+}
+
 // validType is the correct type for an argument or one of its components.
 type validType uint8
 
@@ -54,6 +361,14 @@ const (
 	validTypePair
 	validTypeFloat
 	validTypeDict
+	validTypeString
+	validTypeRational
+	validTypeStringBuilder
+	validTypeBag
+	validTypeJSON
+	validTypeCBOR
+	validTypeDBReference
+	validTypeKey
 )
 
 var validTypeAtoms = [...]Atom{
@@ -73,6 +388,14 @@ var validTypeAtoms = [...]Atom{
 	validTypePair:               atomPair,
 	validTypeFloat:              atomFloat,
 	validTypeDict:               atomDict,
+	validTypeString:             atomString,
+	validTypeRational:           atomRational,
+	validTypeStringBuilder:      atomStringBuilder,
+	validTypeBag:                atomBag,
+	validTypeJSON:               atomJSON,
+	validTypeCBOR:               atomCBOR,
+	validTypeDBReference:        atomDBReference,
+	validTypeKey:                atomKey,
 }
 
 // Term returns an Atom for the validType.
@@ -114,6 +437,20 @@ const (
 
 	validDomainOrder
 	validDomainDictKey
+	validDomainOutputSink
+	validDomainLogLevel
+	validDomainStatisticsKey
+	validDomainSetRandom
+	validDomainHex
+	validDomainBase64
+	validDomainBase64URL
+	validDomainBech32
+	validDomainTableMode
+	validDomainJSONOption
+	validDomainCBOR
+	validDomainLoadOption
+	validDomainModuleFile
+	validDomainAcyclicTerm
 )
 
 var validDomainAtoms = [...]Atom{
@@ -136,6 +473,20 @@ var validDomainAtoms = [...]Atom{
 	validDomainWriteOption:       atomWriteOption,
 	validDomainOrder:             atomOrder,
 	validDomainDictKey:           atomDictKey,
+	validDomainOutputSink:        atomOutputSink,
+	validDomainLogLevel:          atomLogLevel,
+	validDomainStatisticsKey:     atomStatisticsKey,
+	validDomainSetRandom:         atomSetRandom,
+	validDomainHex:               atomHex,
+	validDomainBase64:            atomBase64,
+	validDomainBase64URL:         atomBase64URL,
+	validDomainBech32:            atomBech32,
+	validDomainTableMode:         atomTableMode,
+	validDomainJSONOption:        atomJSONOption,
+	validDomainCBOR:              atomCBOR,
+	validDomainLoadOption:        atomLoadOption,
+	validDomainModuleFile:        atomModuleFile,
+	validDomainAcyclicTerm:       atomAcyclicTerm,
 }
 
 // Term returns an Atom for the validDomain.
@@ -157,15 +508,19 @@ func domainError(validDomain validDomain, culprit Term, env *Env) Exception {
 type objectType uint8
 
 const (
-	objectTypeProcedure objectType = iota
+	objectTypeModule objectType = iota
+	objectTypeProcedure
 	objectTypeSourceSink
 	objectTypeStream
+	objectTypeVariable
 )
 
 var objectTypeAtoms = [...]Atom{
+	objectTypeModule:     atomModule,
 	objectTypeProcedure:  atomProcedure,
 	objectTypeSourceSink: atomSourceSink,
 	objectTypeStream:     atomStream,
+	objectTypeVariable:   atomVariable,
 }
 
 // Term returns an Atom for the objectType.
@@ -217,6 +572,7 @@ type permissionType uint8
 const (
 	permissionTypeBinaryStream permissionType = iota
 	permissionTypeFlag
+	permissionTypeModule
 	permissionTypeOperator
 	permissionTypePastEndOfStream
 	permissionTypePrivateProcedure
@@ -229,6 +585,7 @@ const (
 var permissionTypeAtoms = [...]Atom{
 	permissionTypeBinaryStream:     atomBinaryStream,
 	permissionTypeFlag:             atomFlag,
+	permissionTypeModule:           atomModule,
 	permissionTypeOperator:         atomOperator,
 	permissionTypePastEndOfStream:  atomPastEndOfStream,
 	permissionTypePrivateProcedure: atomPrivateProcedure,
@@ -324,8 +681,11 @@ func SyntaxError(error Term, env *Env) Exception {
 	return NewException(atomError.Apply(atomSyntaxError.Apply(error), varContext), env)
 }
 
-// syntaxError creates a new syntax error exception.
+// syntaxError creates a new syntax error exception. Its context is position(Line, Column) if err is located.
 func syntaxError(err error, env *Env) Exception {
+	if e, ok := err.(unexpectedTokenError); ok && e.pos != (position{}) {
+		return NewException(atomError.Apply(atomSyntaxError.Apply(NewAtom(err.Error())), e.pos.term()), env)
+	}
 	return SyntaxError(NewAtom(err.Error()), env)
 }
 
@@ -1,6 +1,9 @@
 package engine
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -24,6 +27,109 @@ func TestException_Error(t *testing.T) {
 	assert.Equal(t, "foo", e.Error())
 }
 
+func TestException_Trace(t *testing.T) {
+	var vm VM
+	vm.Register1(NewAtom("must_be_integer"), func(_ *VM, t Term, k Cont, env *Env) *Promise {
+		if _, ok := env.Resolve(t).(Integer); !ok {
+			return Error(typeError(validTypeInteger, t, env))
+		}
+		return k(env)
+	})
+	vm.Register0(atomTrue, func(_ *VM, k Cont, env *Env) *Promise {
+		return k(env)
+	})
+	assert.NoError(t, vm.Compile(context.Background(), `:-(p, ','(q, true)).
+:-(q, ','(must_be_integer(a), true)).
+`))
+
+	t.Run("disabled", func(t *testing.T) {
+		_, err := Call(&vm, NewAtom("p"), Success, nil).Force(context.Background())
+		e, ok := err.(Exception)
+		assert.True(t, ok)
+		assert.Equal(t, atomError.Apply(
+			atomTypeError.Apply(atomInteger, NewAtom("a")),
+			atomSlash.Apply(NewAtom("must_be_integer"), Integer(1)),
+		), e.Term())
+		assert.Empty(t, e.Trace())
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		vm.SetBacktraces(true)
+		defer vm.SetBacktraces(false)
+
+		_, err := Call(&vm, NewAtom("p"), Success, nil).Force(context.Background())
+		e, ok := err.(Exception)
+		assert.True(t, ok)
+		assert.Equal(t, []StackFrame{
+			{PI: atomSlash.Apply(NewAtom("must_be_integer"), Integer(1))},
+			{PI: atomSlash.Apply(NewAtom("q"), Integer(0)), Line: 2},
+			{PI: atomSlash.Apply(NewAtom("p"), Integer(0)), Line: 1},
+		}, e.Trace())
+		assert.Equal(t, atomError.Apply(
+			atomTypeError.Apply(atomInteger, NewAtom("a")),
+			atomContext.Apply(
+				atomSlash.Apply(NewAtom("must_be_integer"), Integer(1)),
+				List(
+					atomFrame.Apply(atomSlash.Apply(NewAtom("must_be_integer"), Integer(1)), NewAtom(""), Integer(0)),
+					atomFrame.Apply(atomSlash.Apply(NewAtom("q"), Integer(0)), NewAtom(""), Integer(2)),
+					atomFrame.Apply(atomSlash.Apply(NewAtom("p"), Integer(0)), NewAtom(""), Integer(1)),
+				),
+			),
+		), e.Term())
+	})
+}
+
+func TestException_Is(t *testing.T) {
+	tests := []struct {
+		err    error
+		target error
+		ok     bool
+	}{
+		{err: InstantiationError(nil), target: ErrInstantiation, ok: true},
+		{err: InstantiationError(nil), target: ErrType, ok: false},
+		{err: typeError(validTypeAtom, Integer(0), nil), target: ErrType, ok: true},
+		{err: fmt.Errorf("wrapped: %w", domainError(validDomainOrder, NewAtom("foo"), nil)), target: ErrDomain, ok: true},
+		{err: existenceError(objectTypeProcedure, NewAtom("foo"), nil), target: ErrExistence, ok: true},
+		{err: permissionError(operationModify, permissionTypeStaticProcedure, NewAtom("foo"), nil), target: ErrPermission, ok: true},
+		{err: representationError(flagMaxArity, nil), target: ErrRepresentation, ok: true},
+		{err: evaluationError(exceptionalValueZeroDivisor, nil), target: ErrEvaluation, ok: true},
+		{err: resourceError(resourceMemory, nil), target: ErrResource, ok: true},
+		{err: syntaxError(errors.New("foo"), nil), target: ErrSyntax, ok: true},
+		{err: UninstantiationError(NewAtom("foo"), nil), target: ErrUninstantiation, ok: true},
+		{err: NewException(NewAtom("type_error"), nil), target: ErrType, ok: false},
+		{err: errors.New("type_error"), target: ErrType, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tt.ok, errors.Is(tt.err, tt.target))
+		})
+	}
+}
+
+func TestAsTypeError(t *testing.T) {
+	typ, culprit, ok := AsTypeError(fmt.Errorf("wrapped: %w", typeError(validTypeAtom, Integer(0), nil)))
+	assert.True(t, ok)
+	assert.Equal(t, atomAtom, typ)
+	assert.Equal(t, Integer(0), culprit)
+
+	_, _, ok = AsTypeError(domainError(validDomainOrder, NewAtom("foo"), nil))
+	assert.False(t, ok)
+
+	assert.True(t, IsInstantiationError(InstantiationError(nil)))
+	assert.False(t, IsInstantiationError(errors.New("instantiation_error")))
+
+	operation, permissionType, culprit, ok := AsPermissionError(permissionError(operationModify, permissionTypeStaticProcedure, NewAtom("foo"), nil))
+	assert.True(t, ok)
+	assert.Equal(t, operationModify.Term(), operation)
+	assert.Equal(t, permissionTypeStaticProcedure.Term(), permissionType)
+	assert.Equal(t, NewAtom("foo"), culprit)
+
+	value, ok := AsEvaluationError(evaluationError(exceptionalValueZeroDivisor, nil))
+	assert.True(t, ok)
+	assert.Equal(t, atomZeroDivisor, value)
+}
+
 func TestInstantiationError(t *testing.T) {
 	assert.Equal(t, Exception{
 		term: atomError.Apply(atomInstantiationError, rootContext),
@@ -51,3 +157,71 @@ func TestTypeError(t *testing.T) {
 func TestExceptionalValue_Error(t *testing.T) {
 	assert.Equal(t, "int_overflow", exceptionalValueIntOverflow.Error())
 }
+
+func TestException_MarshalTerm(t *testing.T) {
+	x, y := NewVariable(), NewVariable()
+	tests := []struct {
+		title string
+		exc   Exception
+		data  string
+	}{
+		{title: "type error", exc: typeError(validTypeInteger, NewAtom("a"), nil), data: "exception(1,error(type_error(integer,a),root)).\n"},
+		{title: "context", exc: NewException(atomError.Apply(atomExistenceError.Apply(atomProcedure, atomSlash.Apply(NewAtom("foo bar"), Integer(1))), atomSlash.Apply(NewAtom("call"), Integer(1))), nil), data: "exception(1,error(existence_error(procedure,/('foo bar',1)),/(call,1))).\n"},
+		{title: "variables", exc: Exception{term: NewAtom("f").Apply(y, x, y)}, data: "exception(1,f(_0,_1,_0)).\n"},
+		{title: "numbers and strings", exc: Exception{term: NewAtom("f").Apply(Integer(-1), newFloatFromStringMust("1.5"), String("a\"b"), List(Integer(1), NewAtom("[]")))}, data: "exception(1,f(-1,1.5,\"a\\\"b\",'.'(1,'.'([],[])))).\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			data, err := tt.exc.MarshalTerm()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.data, string(data))
+
+			var e Exception
+			assert.NoError(t, e.UnmarshalTerm(data))
+
+			again, err := e.MarshalTerm()
+			assert.NoError(t, err)
+			assert.Equal(t, data, again)
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := Exception{}.MarshalTerm()
+		assert.ErrorIs(t, err, errExceptionEncoding)
+	})
+
+	t.Run("not encodable", func(t *testing.T) {
+		d, err := NewDict([]Term{NewAtom("tag"), NewAtom("a"), Integer(1)})
+		assert.NoError(t, err)
+		cyclic := NewAtom("f").Apply(x)
+		for _, term := range []Term{
+			NewAtom("f").Apply(&Stream{}),
+			List(NewAtom("a"), &clauseRef{}),
+			d,
+			cyclic,
+		} {
+			_, err := NewException(atomError.Apply(term, NewAtom("root")), NewEnv().bind(x, cyclic)).MarshalTerm()
+			assert.ErrorIs(t, err, errExceptionEncoding)
+		}
+	})
+}
+
+func TestException_UnmarshalTerm(t *testing.T) {
+	tests := []struct {
+		title string
+		data  string
+	}{
+		{title: "syntax error", data: "exception(1,"},
+		{title: "not an exception", data: "foo(1,bar)."},
+		{title: "unsupported version", data: "exception(2,bar)."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			var e Exception
+			assert.ErrorIs(t, e.UnmarshalTerm([]byte(tt.data)), errExceptionEncoding)
+			assert.Nil(t, e.Term())
+		})
+	}
+}
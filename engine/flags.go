@@ -0,0 +1,45 @@
+package engine
+
+import "context"
+
+// queryFlags are the prolog flags which set_prolog_flag/2 changes only for the rest of the query it's called in so
+// that concurrent queries don't race on them. The changes made by a directive become the defaults of the VM.
+type queryFlags struct {
+	unknown      unknownAction
+	doubleQuotes doubleQuotes
+	occursCheck  bool
+	metrics      *metrics // the counters of the VM if it has a metrics sink.
+}
+
+// flags returns the query flags in effect in env.
+func (vm *VM) flags(env *Env) queryFlags {
+	if env != nil && env.flags != nil {
+		return *env.flags
+	}
+	if vm == nil {
+		return queryFlags{}
+	}
+	return queryFlags{
+		unknown:      vm.unknown,
+		doubleQuotes: vm.doubleQuotes,
+		occursCheck:  vm.occursCheck,
+		metrics:      vm.metrics,
+	}
+}
+
+// setDefaultFlags makes the query flags in effect in env the defaults of the VM.
+func (vm *VM) setDefaultFlags(env *Env) {
+	f := vm.flags(env)
+	vm.unknown = f.unknown
+	vm.doubleQuotes = f.doubleQuotes
+	vm.occursCheck = f.occursCheck
+}
+
+// SetDefaultFlag sets the prolog flag to value for the VM so that it affects the queries executed afterwards.
+func (vm *VM) SetDefaultFlag(flag, value Atom) error {
+	_, err := SetPrologFlag(vm, flag, value, func(env *Env) *Promise {
+		vm.setDefaultFlags(env)
+		return Bool(true)
+	}, nil).Force(context.Background())
+	return err
+}
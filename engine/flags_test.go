@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_SetDefaultFlag(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		var vm VM
+		assert.NoError(t, vm.SetDefaultFlag(atomDoubleQuotes, atomAtom))
+		assert.NoError(t, vm.SetDefaultFlag(atomUnknown, atomFail))
+		assert.NoError(t, vm.SetDefaultFlag(atomOccursCheck, atomTrue))
+		assert.NoError(t, vm.SetDefaultFlag(atomDebug, atomOn))
+		assert.Equal(t, doubleQuotesAtom, vm.doubleQuotes)
+		assert.Equal(t, unknownFail, vm.unknown)
+		assert.True(t, vm.occursCheck)
+		assert.True(t, vm.debug)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var vm VM
+		assert.Error(t, vm.SetDefaultFlag(atomDoubleQuotes, NewAtom("foo")))
+		assert.Equal(t, doubleQuotesChars, vm.doubleQuotes)
+	})
+}
+
+func TestVM_flags(t *testing.T) {
+	vm := VM{unknown: unknownWarning, doubleQuotes: doubleQuotesAtom}
+
+	env := vm.prepareEnv(nil)
+	assert.Equal(t, queryFlags{unknown: unknownWarning, doubleQuotes: doubleQuotesAtom}, vm.flags(env))
+
+	vm.unknown = unknownFail
+	assert.Equal(t, unknownWarning, vm.flags(env).unknown, "a running query keeps its snapshot")
+
+	x := NewVariable()
+	env = env.withFlags(&queryFlags{occursCheck: true})
+	_, ok := env.Unify(x, NewAtom("f").Apply(x))
+	assert.False(t, ok)
+
+	ok, err := Unify(&vm, x, NewAtom("f").Apply(x), Success, env).Force(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
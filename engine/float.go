@@ -113,9 +113,9 @@ func (f Float) Compare(t Term, env *Env) int {
 	switch t := env.Resolve(t).(type) {
 	case Variable:
 		return 1
-	case Float:
-		return f.dec.Cmp(t.dec)
-	default: // Integer, Atom, custom atomic terms, Compound.
+	case Number:
+		return compareNumbers(f, t)
+	default: // Atom, custom atomic terms, Compound.
 		return -1
 	}
 }
@@ -48,6 +48,8 @@ func TestFloat_Compare(t *testing.T) {
 		{title: `1.0 = 1.0`, f: NewFloatFromInt64(1), t: NewFloatFromInt64(1), o: 0},
 		{title: `1.0 < 2.0`, f: NewFloatFromInt64(1), t: NewFloatFromInt64(2), o: -1},
 		{title: `1.0 < 1`, f: NewFloatFromInt64(1), t: Integer(1), o: -1},
+		{title: `1.0 > 0`, f: NewFloatFromInt64(1), t: Integer(0), o: 1},
+		{title: `1.0 < 2`, f: NewFloatFromInt64(1), t: Integer(2), o: -1},
 		{title: `1.0 < a`, f: NewFloatFromInt64(1), t: NewAtom("a"), o: -1},
 		{title: `1.0 < f(a)`, f: NewFloatFromInt64(1), t: NewAtom("f").Apply(NewAtom("a")), o: -1},
 	}
@@ -0,0 +1,505 @@
+package engine
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/apd/v3"
+)
+
+// Format writes args to the stream represented by streamOrAlias according to the directives of format.
+// format is an atom, a string, a list of characters, or a list of codes. If args is not a list, it's the only argument.
+//
+// The supported directives are:
+//
+//	~w	write the next argument
+//	~p	print the next argument
+//	~q	write the next argument quoted
+//	~a	write the next argument which is atomic
+//	~Nd	write the next argument which is an integer, with a decimal point N digits from the right
+//	~ND	same as ~Nd but with a comma every three digits left of the decimal point
+//	~Nr	write the next argument which is an integer in radix N, from 2 to 36, with lowercase letters
+//	~NR	same as ~Nr but with uppercase letters
+//	~s	write the next argument which is a string, a list of characters, or a list of codes
+//	~Ne	write the next argument which is a number in exponential notation with N digits after the decimal point
+//	~Nf	write the next argument which is a number with N digits after the decimal point
+//	~Ng	write the next argument which is a number in the shortest of ~e and ~f
+//	~Nc	write the next argument which is a code N times
+//	~Nn	write N newlines
+//	~i	skip the next argument
+//	~~	write ~
+//	~Nt	insert fill characters of code N, or spaces, between the column stops
+//	~N|	set a column stop at column N, or at the current column
+//	~N+	set a column stop N columns after the previous one, 8 by default
+//
+// The numeric argument N is either digits, * to take it from the next argument, or `c for the code of c.
+// Columns are counted from the beginning of the output of format and the line breaks it writes.
+// The output is limited to formatMaxLength characters, beyond which it raises a resource error.
+// The output doesn't depend on the locale: the decimal point is always . and the digit group separator is always ,.
+func Format(vm *VM, streamOrAlias, format, args Term, k Cont, env *Env) *Promise {
+	s, err := stream(vm, streamOrAlias, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	text, err := formatText(vm, format, args, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	w, err := s.textWriter()
+	switch {
+	case errors.Is(err, errWrongIOMode):
+		return Error(permissionError(operationOutput, permissionTypeStream, streamOrAlias, env))
+	case errors.Is(err, errWrongStreamType):
+		return Error(permissionError(operationOutput, permissionTypeBinaryStream, streamOrAlias, env))
+	case err != nil:
+		return Error(err)
+	}
+
+	if _, err := io.WriteString(w, text); err != nil {
+		return Error(err)
+	}
+
+	return k(env)
+}
+
+// formatText renders format with args as Format does.
+func formatText(vm *VM, format, args Term, env *Env) (string, error) {
+	f, err := textOf(format, env)
+	if err != nil {
+		return "", err
+	}
+
+	fo := formatter{vm: vm, env: env}
+	switch a := env.Resolve(args).(type) {
+	case Atom:
+		if a != atomEmptyList {
+			fo.args = []Term{a}
+		}
+	case Compound:
+		if a.Functor() != atomDot || a.Arity() != 2 {
+			fo.args = []Term{a}
+			break
+		}
+		iter := ListIterator{List: a, Env: env}
+		for iter.Next() {
+			fo.args = append(fo.args, iter.Current())
+		}
+		if err := iter.Err(); err != nil {
+			return "", err
+		}
+	default:
+		fo.args = []Term{a}
+	}
+
+	if err := fo.format(f); err != nil {
+		return "", err
+	}
+	return fo.String(), nil
+}
+
+// formatMaxLength is the maximum number of characters of the output of format so that a numeric argument such as
+// ~1000000000c can't exhaust the memory.
+const formatMaxLength = 1 << 24
+
+// formatError creates a new format error exception error(format(Message), _).
+func formatError(msg string, env *Env) Exception {
+	return NewException(atomError.Apply(atomFormat.Apply(NewAtom(msg)), varContext), env)
+}
+
+// formatter renders the directives of format into a string.
+type formatter struct {
+	vm   *VM
+	env  *Env
+	args []Term
+
+	out    strings.Builder // text up to the last column stop.
+	seg    []rune          // text since the last column stop.
+	col    int             // column of the last column stop.
+	fills  []formatFill    // fill points since the last column stop.
+	length int             // number of characters of the output so far.
+}
+
+type formatFill struct {
+	pos  int
+	char rune
+}
+
+func (f *formatter) String() string {
+	f.flush()
+	return f.out.String()
+}
+
+func (f *formatter) format(s string) error {
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		if rs[i] != '~' {
+			f.writeRune(rs[i])
+			continue
+		}
+
+		i++
+		if i == len(rs) {
+			return formatError("truncated format specification", f.env)
+		}
+
+		var (
+			n   int
+			arg bool
+		)
+		switch {
+		case rs[i] == '*':
+			a, err := f.next()
+			if err != nil {
+				return err
+			}
+			m, ok := f.env.Resolve(a).(Integer)
+			if !ok || m < 0 {
+				return formatError("no or negative integer for `*' argument", f.env)
+			}
+			n, arg = int(m), true
+			i++
+		case rs[i] == '`':
+			if i+1 == len(rs) {
+				return formatError("truncated format specification", f.env)
+			}
+			n, arg = int(rs[i+1]), true
+			i += 2
+		case '0' <= rs[i] && rs[i] <= '9':
+			for ; i < len(rs) && '0' <= rs[i] && rs[i] <= '9'; i++ {
+				if n <= formatMaxLength { // Larger ones are out of range anyway.
+					n = 10*n + int(rs[i]-'0')
+				}
+			}
+			arg = true
+		}
+		if i == len(rs) {
+			return formatError("truncated format specification", f.env)
+		}
+
+		if err := f.directive(rs[i], n, arg); err != nil {
+			return err
+		}
+	}
+
+	if len(f.args) > 0 {
+		return formatError("too many arguments", f.env)
+	}
+	return nil
+}
+
+func (f *formatter) directive(d rune, n int, arg bool) error {
+	switch d {
+	case '~':
+		f.writeRune('~')
+	case 'n':
+		if !arg {
+			n = 1
+		}
+		if err := f.reserve(n); err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			f.writeRune('\n')
+		}
+	case 't':
+		c := ' '
+		if arg {
+			c = rune(n)
+		}
+		f.fills = append(f.fills, formatFill{pos: len(f.seg), char: c})
+	case '|':
+		if !arg {
+			n = f.column()
+		}
+		return f.stop(n)
+	case '+':
+		if !arg {
+			n = 8
+		}
+		if n > formatMaxLength {
+			return resourceError(resourceMemory, f.env)
+		}
+		return f.stop(f.col + n)
+	default:
+		if !strings.ContainsRune("wpqadDrRsefgci", d) {
+			return formatError("unknown directive: ~"+string(d), f.env)
+		}
+		a, err := f.next()
+		if err != nil {
+			return err
+		}
+		return f.directiveArg(d, n, arg, a)
+	}
+	return nil
+}
+
+func (f *formatter) directiveArg(d rune, n int, arg bool, a Term) error {
+	a = f.env.Resolve(a)
+	switch d {
+	case 'w':
+		return f.writeTerm(a, WriteOptions{numberVars: true})
+	case 'p', 'q':
+		return f.writeTerm(a, WriteOptions{quoted: true, numberVars: true})
+	case 'a':
+		switch a := a.(type) {
+		case Variable:
+			return InstantiationError(f.env)
+		case Atom:
+			f.writeString(a.String())
+			return nil
+		case String:
+			f.writeString(string(a))
+			return nil
+		case Integer, Float, Rational:
+			return f.writeTerm(a, WriteOptions{})
+		default:
+			return typeError(validTypeAtomic, a, f.env)
+		}
+	case 'd', 'D':
+		switch a := a.(type) {
+		case Variable:
+			return InstantiationError(f.env)
+		case Integer:
+			if err := f.reserve(n); err != nil {
+				return err
+			}
+			s := formatDecimal(a, n)
+			if d == 'D' {
+				s = groupDigits(s)
+			}
+			f.writeString(s)
+			return nil
+		default:
+			return typeError(validTypeInteger, a, f.env)
+		}
+	case 'r', 'R':
+		if !arg {
+			return formatError("no radix for ~"+string(d), f.env)
+		}
+		if n < 2 || n > 36 {
+			return formatError("radix out of range: "+strconv.Itoa(n), f.env)
+		}
+		switch a := a.(type) {
+		case Variable:
+			return InstantiationError(f.env)
+		case Integer:
+			s := strconv.FormatInt(int64(a), n)
+			if d == 'R' {
+				s = strings.ToUpper(s)
+			}
+			f.writeString(s)
+			return nil
+		default:
+			return typeError(validTypeInteger, a, f.env)
+		}
+	case 's':
+		s, err := textOf(a, f.env)
+		if err != nil {
+			return err
+		}
+		f.writeString(s)
+		return nil
+	case 'e', 'f', 'g':
+		if !arg {
+			n = 6
+		}
+		var x Float
+		switch a := a.(type) {
+		case Variable:
+			return InstantiationError(f.env)
+		case Integer:
+			x = NewFloatFromInt64(int64(a))
+		case Float:
+			x = a
+		default:
+			return typeError(validTypeNumber, a, f.env)
+		}
+		if err := f.reserve(n); err != nil {
+			return err
+		}
+		s, err := formatFloat(x, byte(d), n)
+		if err != nil {
+			return err
+		}
+		f.writeString(s)
+		return nil
+	case 'c':
+		if !arg {
+			n = 1
+		}
+		switch a := a.(type) {
+		case Variable:
+			return InstantiationError(f.env)
+		case Integer:
+			if a < 0 || a > utf8.MaxRune {
+				return representationError(flagCharacterCode, f.env)
+			}
+			if err := f.reserve(n); err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				f.writeRune(rune(a))
+			}
+			return nil
+		default:
+			return typeError(validTypeInteger, a, f.env)
+		}
+	default: // i
+		return nil
+	}
+}
+
+func (f *formatter) next() (Term, error) {
+	if len(f.args) == 0 {
+		return nil, formatError("not enough arguments", f.env)
+	}
+	a := f.args[0]
+	f.args = f.args[1:]
+	return a, nil
+}
+
+func (f *formatter) writeTerm(t Term, opts WriteOptions) error {
+	opts._ops = f.vm.getOperators()
+	opts.priority = 1200
+	var sb strings.Builder
+	if err := t.WriteTerm(&sb, &opts, f.env); err != nil {
+		return err
+	}
+	f.writeString(sb.String())
+	return nil
+}
+
+func (f *formatter) writeString(s string) {
+	for _, r := range s {
+		f.writeRune(r)
+	}
+}
+
+// reserve checks that n more characters fit in the output.
+func (f *formatter) reserve(n int) error {
+	if n > formatMaxLength-f.length {
+		return resourceError(resourceMemory, f.env)
+	}
+	return nil
+}
+
+func (f *formatter) writeRune(r rune) {
+	f.env.charge(MeterOutputChar, 1)
+	f.length++
+	if r != '\n' {
+		f.seg = append(f.seg, r)
+		return
+	}
+	f.flush()
+	_, _ = f.out.WriteRune(r)
+	f.col = 0
+}
+
+// column returns the current column.
+func (f *formatter) column() int {
+	return f.col + len(f.seg)
+}
+
+// stop sets a column stop at column n. The text since the previous column stop is padded up to n by distributing
+// the fill characters among its fill points, or by appending spaces if it has none.
+func (f *formatter) stop(n int) error {
+	pad := n - f.column()
+	if pad > 0 {
+		if err := f.reserve(pad); err != nil {
+			return err
+		}
+		f.env.charge(MeterOutputChar, uint64(pad))
+		f.length += pad
+		if len(f.fills) == 0 {
+			f.fills = append(f.fills, formatFill{pos: len(f.seg), char: ' '})
+		}
+		seg := make([]rune, 0, len(f.seg)+pad)
+		prev := 0
+		for i, fill := range f.fills {
+			seg = append(seg, f.seg[prev:fill.pos]...)
+			w := pad / len(f.fills)
+			if i >= len(f.fills)-pad%len(f.fills) {
+				w++
+			}
+			for j := 0; j < w; j++ {
+				seg = append(seg, fill.char)
+			}
+			prev = fill.pos
+		}
+		f.seg = append(seg, f.seg[prev:]...)
+	}
+	col := f.column()
+	f.flush()
+	f.col = col
+	return nil
+}
+
+func (f *formatter) flush() {
+	_, _ = f.out.WriteString(string(f.seg))
+	f.col += len(f.seg)
+	f.seg = f.seg[:0]
+	f.fills = f.fills[:0]
+}
+
+// formatDecimal returns the decimal representation of i with a decimal point n digits from the right.
+func formatDecimal(i Integer, n int) string {
+	s := strconv.FormatInt(int64(i), 10)
+	if n <= 0 {
+		return s
+	}
+	sign := ""
+	if i < 0 {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= n {
+		s = strings.Repeat("0", n-len(s)+1) + s
+	}
+	return sign + s[:len(s)-n] + "." + s[len(s)-n:]
+}
+
+// groupDigits inserts a comma every three digits left of the decimal point of the decimal representation s.
+func groupDigits(s string) string {
+	sign, frac := "", ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s, frac = s[:i], s[i:]
+	}
+	var sb strings.Builder
+	_, _ = sb.WriteString(sign)
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			_ = sb.WriteByte(',')
+		}
+		_, _ = sb.WriteRune(c)
+	}
+	_, _ = sb.WriteString(frac)
+	return sb.String()
+}
+
+// formatFloat returns the representation of x in the format of the directive ~Nd with d in e, f, or g.
+func formatFloat(x Float, d byte, n int) (string, error) {
+	if d == 'f' {
+		ctx := decimal128Ctx
+		ctx.Precision = uint32(x.dec.NumDigits()) + uint32(n) + 1
+		if x.dec.Exponent > 0 {
+			ctx.Precision += uint32(x.dec.Exponent)
+		}
+		var q apd.Decimal
+		if _, err := ctx.Quantize(&q, x.dec, -int32(n)); err != nil {
+			return "", err
+		}
+		return q.Text('f'), nil
+	}
+	v, err := strconv.ParseFloat(x.dec.String(), 64)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(v, d, n, 64), nil
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	x := NewVariable()
+
+	tests := []struct {
+		title  string
+		format Term
+		args   Term
+		output string
+		err    error
+	}{
+		{title: "plain", format: NewAtom("hello"), args: List(), output: "hello"},
+		{title: "chars", format: charList("a~w"), args: List(Integer(1)), output: "a1"},
+		{title: "codes", format: List(Integer('~'), Integer('a')), args: List(NewAtom("b")), output: "b"},
+		{title: "not a list", format: NewAtom("~w"), args: NewAtom("a"), output: "a"},
+		{title: "write", format: NewAtom("~w ~q ~p"), args: List(NewAtom("a b"), NewAtom("a b"), atomVar.Apply(Integer(0))), output: "a b 'a b' A"},
+		{title: "atomic", format: NewAtom("~a~a"), args: List(NewAtom("a b"), Integer(1)), output: "a b1"},
+		{title: "integer", format: NewAtom("~d ~2d ~3d ~1d"), args: List(Integer(42), Integer(1234), Integer(-5), Integer(0)), output: "42 12.34 -0.005 0.0"},
+		{title: "grouped integer", format: NewAtom("~D ~D ~2D ~D"), args: List(Integer(1234567), Integer(-123), Integer(-123456789), Integer(100000)), output: "1,234,567 -123 -1,234,567.89 100,000"},
+		{title: "radix", format: NewAtom("~2r ~16r ~16R ~36r ~8r"), args: List(Integer(5), Integer(255), Integer(255), Integer(35), Integer(-8)), output: "101 ff FF z -10"},
+		{title: "float", format: NewAtom("~2f ~f ~1e ~g"), args: List(newFloatFromStringMust("3.14159"), Integer(1), newFloatFromStringMust("1234.5"), newFloatFromStringMust("0.5")), output: "3.14 1.000000 1.2e+03 0.5"},
+		{title: "string", format: NewAtom("~s"), args: List(codeList("abc")), output: "abc"},
+		{title: "code", format: NewAtom("~c~3c"), args: List(Integer('a'), Integer('b')), output: "abbb"},
+		{title: "newline", format: NewAtom("a~nb~2n"), args: List(), output: "a\nb\n\n"},
+		{title: "ignore and tilde", format: NewAtom("~i~w~~"), args: List(Integer(1), Integer(2)), output: "2~"},
+		{title: "star", format: NewAtom("~*c"), args: List(Integer(2), Integer('x')), output: "xx"},
+		{title: "left aligned", format: NewAtom("~w~10|~w"), args: List(NewAtom("abc"), NewAtom("d")), output: "abc       d"},
+		{title: "right aligned", format: NewAtom("~t~w~10|"), args: List(NewAtom("abc")), output: "       abc"},
+		{title: "centered", format: NewAtom("~t~w~t~11|"), args: List(NewAtom("abc")), output: "    abc    "},
+		{title: "fill character", format: NewAtom("~`-t~30|"), args: List(), output: "------------------------------"},
+		{title: "relative column", format: NewAtom("~w~t~4+~w~t~4+|"), args: List(Integer(1), Integer(2)), output: "1   2   |"},
+		{title: "column after newline", format: NewAtom("a~nb~t~3|c"), args: List(), output: "a\nb  c"},
+		{title: "overflow", format: NewAtom("~w~2|~w"), args: List(NewAtom("abc"), NewAtom("d")), output: "abcd"},
+
+		{title: "not enough arguments", format: NewAtom("~w"), args: List(), err: formatError("not enough arguments", nil)},
+		{title: "too many arguments", format: NewAtom("~w"), args: List(Integer(1), Integer(2)), err: formatError("too many arguments", nil)},
+		{title: "unknown directive", format: NewAtom("~y"), args: List(), err: formatError("unknown directive: ~y", nil)},
+		{title: "truncated", format: NewAtom("~"), args: List(), err: formatError("truncated format specification", nil)},
+		{title: "format variable", format: x, args: List(), err: InstantiationError(nil)},
+		{title: "format not text", format: NewAtom("f").Apply(Integer(1)), args: List(), err: typeError(validTypeString, NewAtom("f").Apply(Integer(1)), nil)},
+		{title: "integer expected", format: NewAtom("~d"), args: List(NewAtom("a")), err: typeError(validTypeInteger, NewAtom("a"), nil)},
+		{title: "integer expected for radix", format: NewAtom("~8r"), args: List(NewAtom("a")), err: typeError(validTypeInteger, NewAtom("a"), nil)},
+		{title: "no radix", format: NewAtom("~r"), args: List(Integer(1)), err: formatError("no radix for ~r", nil)},
+		{title: "radix out of range", format: NewAtom("~37R"), args: List(Integer(1)), err: formatError("radix out of range: 37", nil)},
+		{title: "atomic expected", format: NewAtom("~a"), args: List(List(NewAtom("a"))), err: typeError(validTypeAtomic, List(NewAtom("a")), nil)},
+		{title: "argument variable", format: NewAtom("~a"), args: List(x), err: InstantiationError(nil)},
+		{title: "too many codes", format: NewAtom("~1000000000c"), args: List(Integer('a')), err: resourceError(resourceMemory, nil)},
+		{title: "too many codes from argument", format: NewAtom("~*c"), args: List(Integer(1<<40), Integer('a')), err: resourceError(resourceMemory, nil)},
+		{title: "too many digits", format: NewAtom("~99999999999999999999999d"), args: List(Integer(1)), err: resourceError(resourceMemory, nil)},
+		{title: "column too far", format: NewAtom("~1000000000|"), args: List(), err: resourceError(resourceMemory, nil)},
+		{title: "relative column too far", format: NewAtom("~*+"), args: List(Integer(1 << 62)), err: resourceError(resourceMemory, nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			var buf bytes.Buffer
+			s := &Stream{sink: &buf, mode: ioModeWrite}
+			var vm VM
+			ok, err := Format(&vm, s, tt.format, tt.args, Success, nil).Force(context.Background())
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.err == nil, ok)
+			assert.Equal(t, tt.output, buf.String())
+		})
+	}
+
+	t.Run("meter", func(t *testing.T) {
+		var (
+			vm    VM
+			units uint64
+		)
+		vm.InstallMeter(func(kind MeterKind, n uint64) Term {
+			if kind == MeterOutputChar {
+				units += n
+			}
+			return nil
+		})
+		var buf bytes.Buffer
+		s := &Stream{sink: &buf, mode: ioModeWrite}
+		ok, err := Format(&vm, s, NewAtom("~3c~w~10|"), List(Integer('a'), NewAtom("bc")), Success, vm.prepareEnv(nil)).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(10), units)
+	})
+
+	t.Run("binary stream", func(t *testing.T) {
+		var buf bytes.Buffer
+		s := &Stream{sink: &buf, mode: ioModeWrite, streamType: streamTypeBinary}
+		var vm VM
+		_, err := Format(&vm, s, NewAtom("a"), List(), Success, nil).Force(context.Background())
+		assert.Equal(t, permissionError(operationOutput, permissionTypeBinaryStream, s, nil), err)
+	})
+}
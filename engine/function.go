@@ -0,0 +1,72 @@
+package engine
+
+// UnaryFunction is an evaluable functor of arity 1 defined by the embedder.
+// x is the value of the argument, which is always a Number. It returns the value of the expression.
+type UnaryFunction func(x Term, env *Env) (Term, error)
+
+// BinaryFunction is an evaluable functor of arity 2 defined by the embedder.
+// x and y are the values of the arguments, which are always Numbers. It returns the value of the expression.
+type BinaryFunction func(x, y Term, env *Env) (Term, error)
+
+// RegisterUnaryFunction registers an evaluable functor name/1 so that arithmetic, e.g. is/2, can evaluate name(X).
+// The functors defined by the standard can't be redefined.
+func (vm *VM) RegisterUnaryFunction(name Atom, f UnaryFunction) {
+	if vm.unaryFunctions == nil {
+		vm.unaryFunctions = map[Atom]UnaryFunction{}
+	}
+	vm.unaryFunctions[name] = f
+}
+
+// RegisterBinaryFunction registers an evaluable functor name/2 so that arithmetic, e.g. is/2, can evaluate name(X, Y).
+// The functors defined by the standard can't be redefined.
+func (vm *VM) RegisterBinaryFunction(name Atom, f BinaryFunction) {
+	if vm.binaryFunctions == nil {
+		vm.binaryFunctions = map[Atom]BinaryFunction{}
+	}
+	vm.binaryFunctions[name] = f
+}
+
+// unaryFunction returns the evaluable functor name/1 registered by RegisterUnaryFunction.
+func (vm *VM) unaryFunction(name Atom, env *Env) (func(Number) (Number, error), bool) {
+	if vm == nil {
+		return nil, false
+	}
+	f, ok := vm.unaryFunctions[name]
+	if !ok {
+		return nil, false
+	}
+	return func(x Number) (Number, error) {
+		v, err := f(x, env)
+		return functionResult(v, err, env)
+	}, true
+}
+
+// binaryFunction returns the evaluable functor name/2 registered by RegisterBinaryFunction.
+func (vm *VM) binaryFunction(name Atom, env *Env) (func(Number, Number) (Number, error), bool) {
+	if vm == nil {
+		return nil, false
+	}
+	f, ok := vm.binaryFunctions[name]
+	if !ok {
+		return nil, false
+	}
+	return func(x, y Number) (Number, error) {
+		v, err := f(x, y, env)
+		return functionResult(v, err, env)
+	}, true
+}
+
+// functionResult checks that the value v of an evaluable functor defined by the embedder is a Number.
+func functionResult(v Term, err error, env *Env) (Number, error) {
+	if err != nil {
+		return nil, err
+	}
+	switch v := env.Resolve(v).(type) {
+	case Variable:
+		return nil, InstantiationError(env)
+	case Number:
+		return v, nil
+	default:
+		return nil, typeError(validTypeNumber, v, env)
+	}
+}
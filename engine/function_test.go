@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_RegisterUnaryFunction(t *testing.T) {
+	var vm VM
+	vm.RegisterUnaryFunction(NewAtom("uatom_to_uusd"), func(x Term, env *Env) (Term, error) {
+		return mul(x.(Number), Integer(3))
+	})
+	vm.RegisterUnaryFunction(NewAtom("foo"), func(Term, *Env) (Term, error) {
+		return NewAtom("foo"), nil
+	})
+	vm.RegisterUnaryFunction(NewAtom("bar"), func(Term, *Env) (Term, error) {
+		return nil, errors.New("bar")
+	})
+	vm.RegisterUnaryFunction(atomAbs, func(Term, *Env) (Term, error) {
+		return Integer(0), nil
+	})
+
+	tests := []struct {
+		title      string
+		expression Term
+		ok         bool
+		err        error
+		result     Term
+	}{
+		{title: "ok", expression: NewAtom("uatom_to_uusd").Apply(atomPlus.Apply(Integer(1), Integer(1))), ok: true, result: Integer(6)},
+		{title: "builtin", expression: atomAbs.Apply(Integer(-1)), ok: true, result: Integer(1)},
+		{title: "not a number", expression: NewAtom("foo").Apply(Integer(1)), err: typeError(validTypeNumber, NewAtom("foo"), nil)},
+		{title: "error", expression: NewAtom("bar").Apply(Integer(1)), err: errors.New("bar")},
+		{title: "argument", expression: NewAtom("uatom_to_uusd").Apply(NewAtom("a")), err: typeError(validTypeEvaluable, atomSlash.Apply(NewAtom("a"), Integer(0)), nil)},
+		{title: "unknown", expression: NewAtom("baz").Apply(Integer(1)), err: typeError(validTypeEvaluable, atomSlash.Apply(NewAtom("baz"), Integer(1)), nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			ok, err := Is(&vm, tt.result, tt.expression, Success, nil).Force(context.Background())
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestVM_RegisterBinaryFunction(t *testing.T) {
+	var vm VM
+	vm.RegisterBinaryFunction(NewAtom("hypot2"), func(x, y Term, env *Env) (Term, error) {
+		x2, err := mul(x.(Number), x.(Number))
+		if err != nil {
+			return nil, err
+		}
+		y2, err := mul(y.(Number), y.(Number))
+		if err != nil {
+			return nil, err
+		}
+		return add(x2, y2)
+	})
+
+	ok, err := Is(&vm, Integer(25), NewAtom("hypot2").Apply(Integer(3), atomMinus.Apply(Integer(6), Integer(2))), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = Is(&vm, NewVariable(), NewAtom("hypot2").Apply(maxInt, Integer(1)), Success, nil).Force(context.Background())
+	assert.Equal(t, evaluationError(exceptionalValueIntOverflow, nil), err)
+
+	_, err = Is(&vm, NewVariable(), NewAtom("hypot3").Apply(Integer(1), Integer(1)), Success, nil).Force(context.Background())
+	assert.Equal(t, typeError(validTypeEvaluable, atomSlash.Apply(NewAtom("hypot3"), Integer(2)), nil), err)
+}
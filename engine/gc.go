@@ -0,0 +1,343 @@
+package engine
+
+import "sync/atomic"
+
+// ClauseGCStats are the statistics of the garbage collection of retracted clauses.
+type ClauseGCStats struct {
+	// Retired is the number of retracted clauses which are not collected yet.
+	Retired uint64
+	// Collected is the number of retracted clauses collected so far.
+	Collected uint64
+	// Collections is the number of collections so far.
+	Collections uint64
+}
+
+// ClauseGCStats returns the statistics of the garbage collection of retracted clauses.
+func (vm *VM) ClauseGCStats() ClauseGCStats {
+	return vm.clauseGC
+}
+
+// CollectClauses removes the retracted clauses from all the procedures.
+// The calls in progress keep trying the clauses they could see when they started, so a retracted clause is freed
+// once no such call references it anymore.
+func (vm *VM) CollectClauses() {
+	collect := func(p procedure) {
+		if u, ok := p.(*userDefined); ok {
+			vm.collect(u)
+		}
+	}
+	if vm.procedures != nil {
+		for p := vm.procedures.Oldest(); p != nil; p = p.Next() {
+			collect(p.Value)
+		}
+	}
+	for _, m := range vm.modules {
+		for p := m.procedures.Oldest(); p != nil; p = p.Next() {
+			collect(p.Value)
+		}
+	}
+}
+
+// GarbageCollectClauses removes the retracted clauses from all the procedures.
+func GarbageCollectClauses(vm *VM, k Cont, env *Env) *Promise {
+	vm.CollectClauses()
+	return k(env)
+}
+
+// retire retracts the clause c of u in a new generation of the database. c stays in u so that the calls made in
+// the previous generations still see it, and u is collected once half of its clauses are retired.
+func (vm *VM) retire(u *userDefined, c *clause) {
+	vm.generation++
+	c.erased = vm.generation
+	delete(vm.clauseRefs, c)
+	u.retired++
+	vm.clauseGC.Retired++
+	if 2*u.retired > len(u.clauses) {
+		vm.collect(u)
+	}
+}
+
+// collect removes the retired clauses of u. Since it allocates new clauses instead of compacting them in place,
+// the calls in progress are unaffected.
+func (vm *VM) collect(u *userDefined) {
+	if u.retired == 0 {
+		return
+	}
+	live := make(clauses, 0, len(u.clauses)-u.retired)
+	for _, c := range u.clauses {
+		if c.erased == 0 {
+			live = append(live, c)
+		}
+	}
+	u.setClauses(live)
+	vm.clauseGC.Retired -= uint64(u.retired)
+	vm.clauseGC.Collected += uint64(u.retired)
+	vm.clauseGC.Collections++
+	u.retired = 0
+}
+
+// EnvGCStats are the statistics of the garbage collection of variable bindings.
+type EnvGCStats struct {
+	// Collections is the number of collections so far.
+	Collections uint64
+	// Kept is the number of bindings which survived the collections so far.
+	Kept uint64
+	// Collected is the number of bindings dropped by the collections so far.
+	Collected uint64
+}
+
+// envGC is the state of the garbage collection of variable bindings.
+type envGC struct {
+	interval uint64
+	last     uint64
+	stats    EnvGCStats
+}
+
+// SetEnvGCInterval makes the VM collect the variable bindings which are no longer reachable every n inferences.
+// The collection takes place at the last calls of clauses, where the bindings made by the caller often become
+// garbage, so that long-running deterministic recursions run in bounded memory. Zero value disables it.
+// Foreign predicates must reach the terms they refer to after calling a goal from their arguments or from the goal
+// itself, since their continuations are opaque to the collector.
+func (vm *VM) SetEnvGCInterval(n uint64) {
+	vm.envGC.interval = n
+}
+
+// EnvGCStats returns the statistics of the garbage collection of variable bindings.
+func (vm *VM) EnvGCStats() EnvGCStats {
+	return vm.envGC.stats
+}
+
+// frame is a call in progress. The terms of a frame may be referenced by its continuation, so they are the roots
+// of the garbage collection of variable bindings along with the ones of its ancestors.
+type frame struct {
+	parent *frame
+	args   []Term
+	vars   []Variable
+	born   Variable // the last variable created before the frame.
+
+	// foreign is true if the frame is a call to a foreign predicate, a tabled or a traced procedure whose
+	// continuation is an arbitrary Go closure.
+	foreign bool
+
+	// backtrace is true if the frame is recorded for the backtraces of exceptions. Then pi is the called foreign
+	// predicate, or clause is the running clause.
+	backtrace bool
+	pi        procedureIndicator
+	clause    *clause
+}
+
+// owned reports whether the continuation of the clause frame f refers to nothing but the terms of the ancestors
+// of f, i.e. f was called from the body of a clause and f can be dropped at its last call.
+func (f *frame) owned() bool {
+	return f.parent != nil && !f.parent.foreign
+}
+
+func (e *Env) topFrame() *frame {
+	if e == nil {
+		return nil
+	}
+	return e.frames
+}
+
+func (e *Env) withFrames(f *frame) *Env {
+	var ret Env
+	if e == nil {
+		ret = *rootEnv
+	} else {
+		ret = *e
+	}
+	ret.frames = f
+	return &ret
+}
+
+// framed reports whether the VM keeps track of the frames of the calls in progress.
+func (vm *VM) framed() bool {
+	return vm.envGC.interval != 0 || vm.backtraces
+}
+
+// enterClause pushes the frame of the clause c with the arguments args and the variables vars.
+func (vm *VM) enterClause(c *clause, args []Term, vars []Variable, env *Env) *Env {
+	if !vm.framed() {
+		return env
+	}
+	f := frame{parent: env.topFrame(), args: args, vars: vars, born: lastVariable()}
+	if vm.backtraces {
+		f.backtrace, f.clause = true, c
+	}
+	return env.withFrames(&f)
+}
+
+// exitClause pops the frame of the exiting clause.
+func (vm *VM) exitClause(env *Env) *Env {
+	if !vm.framed() {
+		return env
+	}
+	if f := env.topFrame(); f != nil {
+		return env.withFrames(f.parent)
+	}
+	return env
+}
+
+// enterForeign pushes the frame of a call to a foreign predicate and returns k which pops it.
+// pi is the zero value if the callee is a user-defined procedure since its clauses push their own frames.
+func (vm *VM) enterForeign(pi procedureIndicator, args []Term, k Cont, env *Env) (Cont, *Env) {
+	if !vm.framed() {
+		return k, env
+	}
+	parent := env.topFrame()
+	f := frame{parent: parent, args: args, born: lastVariable(), foreign: true}
+	if vm.backtraces {
+		f.backtrace, f.pi = true, pi
+	}
+	return func(env *Env) *Promise {
+		return k(env.withFrames(parent))
+	}, env.withFrames(&f)
+}
+
+// lastCall pops the frame of the clause making the last call with the arguments args if it's owned, and collects
+// the variable bindings if it's time to do so.
+func (vm *VM) lastCall(args []Term, env *Env) *Env {
+	if !vm.framed() {
+		return env
+	}
+	if f := env.topFrame(); f != nil && f.owned() {
+		env = env.withFrames(f.parent)
+	}
+	if vm.envGC.interval == 0 {
+		return env
+	}
+	if n := atomic.LoadUint64(&vm.inferences); n-vm.envGC.last >= vm.envGC.interval {
+		vm.envGC.last = n
+		env = vm.collectEnv(args, env)
+	}
+	return env
+}
+
+// collectEnv returns an environment with the bindings of env which are reachable from args, the frames, the
+// attributes of variables, and the variables created before the outermost frame, e.g. by the host.
+func (vm *VM) collectEnv(args []Term, env *Env) *Env {
+	if env == nil {
+		return nil
+	}
+
+	var (
+		kept    *Env
+		visited = map[envKey]struct{}{}
+		seen    = map[termID]struct{}{} // compounds might be cyclic.
+		n       uint64
+		mark    func(Term)
+	)
+	mark = func(t Term) {
+		for {
+			switch u := t.(type) {
+			case Variable:
+				k := newEnvKey(u)
+				if _, ok := visited[k]; ok {
+					return
+				}
+				visited[k] = struct{}{}
+				v, ok := env.find(k)
+				if !ok {
+					return
+				}
+				kept = kept.insert(k, v, nil)
+				kept.color = black
+				n++
+				t = v
+			case list:
+				for _, e := range u {
+					mark(e)
+				}
+				return
+			case Compound:
+				if _, ok := seen[id(u)]; ok {
+					return
+				}
+				seen[id(u)] = struct{}{}
+				arity := u.Arity()
+				if arity == 0 {
+					return
+				}
+				for i := 0; i < arity-1; i++ {
+					mark(u.Arg(i))
+				}
+				t = u.Arg(arity - 1) // iterate on the last argument so that long lists don't nest calls.
+			default:
+				return
+			}
+		}
+	}
+
+	mark(varContext)
+	for _, a := range args {
+		mark(a)
+	}
+	var born Variable
+	for f := env.frames; f != nil; f = f.parent {
+		for _, a := range f.args {
+			mark(a)
+		}
+		for _, v := range f.vars {
+			mark(v)
+		}
+		born = f.born
+	}
+	var markOld func(*Env)
+	markOld = func(e *Env) {
+		if e == nil {
+			return
+		}
+		if v := e.key.variable(); v <= born {
+			mark(v)
+		}
+		markOld(e.left)
+		markOld(e.right)
+	}
+	markOld(env)
+	for _, t := range env.globals {
+		mark(t)
+	}
+	if env.attrs != nil {
+		var markAttrs func(*Env)
+		markAttrs = func(e *Env) {
+			if e == nil {
+				return
+			}
+			mark(e.value)
+			markAttrs(e.left)
+			markAttrs(e.right)
+		}
+		markAttrs(env.attrs.vars)
+		for _, w := range env.attrs.pending {
+			mark(w.attrs)
+			mark(w.value)
+		}
+	}
+
+	total := env.size()
+	vm.envGC.stats.Collections++
+	vm.envGC.stats.Kept += n
+	vm.envGC.stats.Collected += total - n
+
+	var ret Env
+	if kept != nil {
+		ret = *kept
+	} else {
+		ret = *rootEnv
+	}
+	ret.meter = env.meter
+	ret.attrs = env.attrs
+	ret.flags = env.flags
+	ret.globals = env.globals
+	ret.frames = env.frames
+	ret.depth = env.depth
+	return &ret
+}
+
+// size returns the number of bindings in e.
+func (e *Env) size() uint64 {
+	if e == nil {
+		return 0
+	}
+	return 1 + e.left.size() + e.right.size()
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_ClauseGCStats(t *testing.T) {
+	foo := NewAtom("foo")
+	newVM := func() *VM {
+		var vm VM
+		for i := 1; i <= 4; i++ {
+			_, err := Assertz(&vm, foo.Apply(Integer(i)), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+		}
+		return &vm
+	}
+	retract := func(vm *VM, i Integer) {
+		ok, err := Retract(vm, foo.Apply(i), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+	u := func(vm *VM) *userDefined {
+		p, _ := vm.getProcedure(procedureIndicator{name: foo, arity: 1})
+		return p.(*userDefined)
+	}
+
+	t.Run("collect once half of the clauses are retired", func(t *testing.T) {
+		vm := newVM()
+		retract(vm, 1)
+		assert.Equal(t, ClauseGCStats{Retired: 1}, vm.ClauseGCStats())
+		assert.Len(t, u(vm).clauses, 4)
+
+		retract(vm, 2)
+		assert.Equal(t, ClauseGCStats{Retired: 2}, vm.ClauseGCStats())
+		assert.Len(t, u(vm).clauses, 4)
+
+		retract(vm, 3)
+		assert.Equal(t, ClauseGCStats{Collected: 3, Collections: 1}, vm.ClauseGCStats())
+		assert.Len(t, u(vm).clauses, 1)
+	})
+
+	t.Run("collect all", func(t *testing.T) {
+		vm := newVM()
+		retract(vm, 1)
+		vm.CollectClauses()
+		assert.Equal(t, ClauseGCStats{Collected: 1, Collections: 1}, vm.ClauseGCStats())
+		assert.Len(t, u(vm).clauses, 3)
+
+		_, err := GarbageCollectClauses(vm, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, ClauseGCStats{Collected: 1, Collections: 1}, vm.ClauseGCStats())
+	})
+
+	t.Run("logical update view", func(t *testing.T) {
+		vm := newVM()
+		x := NewVariable()
+		var got []Term
+		_, err := Call(vm, foo.Apply(x), func(env *Env) *Promise {
+			got = append(got, env.Resolve(x))
+			if len(got) == 1 {
+				// Retires and collects the clauses the call is about to try.
+				retract(vm, 2)
+				retract(vm, 3)
+				retract(vm, 4)
+			}
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []Term{Integer(1), Integer(2), Integer(3), Integer(4)}, got)
+
+		ok, err := Call(vm, foo.Apply(Integer(2)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, uint64(3), vm.ClauseGCStats().Collected)
+	})
+}
+
+func TestVM_SetEnvGCInterval(t *testing.T) {
+	var vm VM
+	assert.NoError(t, vm.Compile(context.Background(), `
+down(z, R, R).
+:-(down(s(N), R0, R), down(N, s(R0), R)).
+`))
+
+	s, z := NewAtom("s"), NewAtom("z")
+	var n Term = z
+	for i := 0; i < 1000; i++ {
+		n = s.Apply(n)
+	}
+
+	run := func(interval uint64) EnvGCStats {
+		vm.SetEnvGCInterval(interval)
+		r := NewVariable()
+		ok, err := vm.Arrive(NewAtom("down"), []Term{n, z, r}, func(env *Env) *Promise {
+			assert.Equal(t, n, env.simplify(r))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		return vm.EnvGCStats()
+	}
+
+	assert.Equal(t, EnvGCStats{}, run(0))
+
+	stats := run(100)
+	assert.Equal(t, uint64(10), stats.Collections)
+	assert.NotZero(t, stats.Collected)
+}
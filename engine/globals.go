@@ -0,0 +1,91 @@
+package engine
+
+import "maps"
+
+// BSetval associates value with the atom key as a backtrackable global variable. The association is undone on
+// backtracking. Unlike NbSetval, value isn't copied, so its variables are shared with the ones of the caller.
+func BSetval(_ *VM, key, value Term, k Cont, env *Env) *Promise {
+	a, err := scratchpadKeyOf(key, env)
+	if err != nil {
+		return Error(err)
+	}
+	return k(env.setGlobal(a, env.Resolve(value)))
+}
+
+// BGetval unifies value with the term associated with the atom key by BSetval or NbSetval, whichever is the latest.
+func BGetval(vm *VM, key, value Term, k Cont, env *Env) *Promise {
+	a, err := scratchpadKeyOf(key, env)
+	if err != nil {
+		return Error(err)
+	}
+	t, ok := env.getGlobal(a)
+	if !ok && vm != nil {
+		t, ok = vm.globals[a]
+	}
+	if !ok {
+		return Error(existenceError(objectTypeVariable, a, env))
+	}
+	return Unify(vm, value, t, k, env)
+}
+
+// NbSetval associates a copy of value with the atom key as a global variable of the VM. Unlike BSetval, the
+// association isn't undone on backtracking and outlives the query.
+func NbSetval(vm *VM, key, value Term, k Cont, env *Env) *Promise {
+	a, err := scratchpadKeyOf(key, env)
+	if err != nil {
+		return Error(err)
+	}
+	c, err := renamedCopy(value, nil, env)
+	if err != nil {
+		return Error(err)
+	}
+	if vm.globals == nil {
+		vm.globals = map[Atom]Term{}
+	}
+	vm.globals[a] = c
+	vm.record(atomNbSetval, []Term{a, c}, env)
+	// The value by NbSetval supersedes the one by BSetval until backtracking.
+	return k(env.deleteGlobal(a))
+}
+
+// NbGetval is the same as BGetval.
+func NbGetval(vm *VM, key, value Term, k Cont, env *Env) *Promise {
+	return BGetval(vm, key, value, k, env)
+}
+
+// getGlobal returns the term associated with the backtrackable global variable key.
+func (e *Env) getGlobal(key Atom) (Term, bool) {
+	if e == nil {
+		return nil, false
+	}
+	t, ok := e.globals[key]
+	return t, ok
+}
+
+// setGlobal returns an environment in which the backtrackable global variable key is associated with t.
+// The global variables are copied on write since the environments are persistent.
+func (e *Env) setGlobal(key Atom, t Term) *Env {
+	var ret Env
+	if e == nil {
+		ret = *rootEnv
+	} else {
+		ret = *e
+	}
+	ret.globals = maps.Clone(ret.globals)
+	if ret.globals == nil {
+		ret.globals = map[Atom]Term{}
+	}
+	ret.globals[key] = t
+	return &ret
+}
+
+// deleteGlobal returns an environment without the backtrackable global variable key.
+func (e *Env) deleteGlobal(key Atom) *Env {
+	if _, ok := e.getGlobal(key); !ok {
+		return e
+	}
+	ret := *e
+	ret.globals = maps.Clone(ret.globals)
+	delete(ret.globals, key)
+	return &ret
+}
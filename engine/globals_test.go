@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBSetval(t *testing.T) {
+	k := NewAtom("k")
+
+	t.Run("set and get", func(t *testing.T) {
+		x, v := NewVariable(), NewVariable()
+		ok, err := BSetval(nil, k, NewAtom("f").Apply(x), func(env *Env) *Promise {
+			env = env.bind(x, NewAtom("a"))
+			return BGetval(nil, k, v, func(env *Env) *Promise {
+				// The value isn't copied.
+				assert.Equal(t, NewAtom("f").Apply(NewAtom("a")), env.simplify(v))
+				return Bool(true)
+			}, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("undone on backtracking", func(t *testing.T) {
+		var vm VM
+		ok, err := Delay(func(context.Context) *Promise {
+			return BSetval(&vm, k, Integer(1), Failure, nil)
+		}, func(context.Context) *Promise {
+			return BGetval(&vm, k, NewVariable(), Success, nil)
+		}).Force(context.Background())
+		assert.Equal(t, existenceError(objectTypeVariable, k, nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("key is not an atom", func(t *testing.T) {
+		ok, err := BSetval(nil, Integer(1), Integer(1), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeAtom, Integer(1), nil), err)
+		assert.False(t, ok)
+	})
+}
+
+func TestNbSetval(t *testing.T) {
+	k := NewAtom("k")
+
+	t.Run("survives backtracking and queries", func(t *testing.T) {
+		var vm VM
+		x, v := NewVariable(), NewVariable()
+		ok, err := Delay(func(context.Context) *Promise {
+			return NbSetval(&vm, k, NewAtom("f").Apply(x), Failure, nil)
+		}, func(context.Context) *Promise {
+			return Bool(true)
+		}).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = NbGetval(&vm, k, v, func(env *Env) *Promise {
+			// The value is copied.
+			f, ok := env.Resolve(v).(Compound)
+			assert.True(t, ok)
+			assert.NotEqual(t, x, f.Arg(0))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("supersedes b_setval", func(t *testing.T) {
+		var vm VM
+		v := NewVariable()
+		ok, err := BSetval(&vm, k, Integer(1), func(env *Env) *Promise {
+			return NbSetval(&vm, k, Integer(2), func(env *Env) *Promise {
+				return BGetval(&vm, k, v, func(env *Env) *Promise {
+					assert.Equal(t, Integer(2), env.Resolve(v))
+					return Bool(true)
+				}, env)
+			}, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		var vm VM
+		_, err := NbSetval(&vm, k, Integer(1), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		c := vm.Clone()
+		_, err = NbSetval(c, k, Integer(2), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		ok, err := NbGetval(&vm, k, Integer(1), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("key is a variable", func(t *testing.T) {
+		var vm VM
+		ok, err := NbGetval(&vm, NewVariable(), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"strings"
+	"sync"
+)
+
+// goalHistoryDepth is the maximum depth of the arguments written in the goal history.
+const goalHistoryDepth = 3
+
+// goalHistory is a ring buffer of the last goals entered by the VM.
+// The goals are written only when an error report needs them, so recording a goal costs no more than a few stores.
+type goalHistory struct {
+	mu    sync.Mutex
+	goals []enteredGoal
+	next  int
+	full  bool
+}
+
+type enteredGoal struct {
+	pi   procedureIndicator
+	args []Term
+	env  *Env
+}
+
+func (h *goalHistory) record(pi procedureIndicator, args []Term, env *Env) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.goals[h.next] = enteredGoal{pi: pi, args: args, env: env}
+	h.next++
+	if h.next == len(h.goals) {
+		h.next, h.full = 0, true
+	}
+}
+
+// entered returns the recorded goals, the oldest first.
+func (h *goalHistory) entered() []enteredGoal {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]enteredGoal(nil), h.goals[:h.next]...)
+	}
+	return append(append([]enteredGoal(nil), h.goals[h.next:]...), h.goals[:h.next]...)
+}
+
+// SetGoalHistory makes the VM remember the last n goals it entered so that the errors surfaced by Compile,
+// CompileProgram, LoadProgram, and AttachGoalHistory carry them. Zero value disables it.
+// The remembered goals keep their arguments from being garbage collected.
+func (vm *VM) SetGoalHistory(n int) {
+	if n <= 0 {
+		vm.history = nil
+		return
+	}
+	vm.history = &goalHistory{goals: make([]enteredGoal, n)}
+}
+
+// GoalHistory returns the last goals entered by the VM, the oldest first, written with their arguments truncated.
+func (vm *VM) GoalHistory() []string {
+	if vm.history == nil {
+		return nil
+	}
+	entered := vm.history.entered()
+	ret := make([]string, len(entered))
+	opts := WriteOptions{quoted: true, _ops: vm.getOperators(), priority: 999, maxDepth: goalHistoryDepth}
+	for i, g := range entered {
+		var sb strings.Builder
+		var t Term = g.pi.name
+		if len(g.args) > 0 {
+			t = g.pi.name.Apply(g.args...)
+		}
+		_ = t.WriteTerm(&sb, &opts, g.env)
+		ret[i] = sb.String()
+	}
+	return ret
+}
+
+// AttachGoalHistory returns err with the goal history of the VM if err is an Exception which escaped the query and
+// doesn't have one yet. Otherwise, it returns err as is.
+func (vm *VM) AttachGoalHistory(err error) error {
+	e, ok := err.(Exception)
+	if vm.history == nil || !ok || e.history != nil {
+		return err
+	}
+	e.history = &goalTrail{goals: vm.GoalHistory()}
+	return e
+}
+
+// goalTrail is the goal history attached to an Exception.
+type goalTrail struct {
+	goals []string
+}
+
+// GoalHistory returns the last goals entered by the VM before the Exception escaped the query, the oldest first, if
+// the VM remembers them.
+func (e Exception) GoalHistory() []string {
+	if e.history == nil {
+		return nil
+	}
+	return e.history.goals
+}
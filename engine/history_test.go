@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_SetGoalHistory(t *testing.T) {
+	newVM := func() *VM {
+		var vm VM
+		vm.Register1(NewAtom("fail_on"), func(_ *VM, t Term, k Cont, env *Env) *Promise {
+			if env.Resolve(t) == NewAtom("c") {
+				return Error(typeError(validTypeInteger, t, env))
+			}
+			return k(env)
+		})
+		vm.Register1(NewAtom("foo"), func(vm *VM, t Term, k Cont, env *Env) *Promise {
+			return k(env)
+		})
+		return &vm
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		vm := newVM()
+		err := vm.Compile(context.Background(), `:-(initialization(fail_on(c))).`)
+		assert.Error(t, err)
+		assert.Nil(t, vm.GoalHistory())
+		var e Exception
+		assert.True(t, errors.As(err, &e))
+		assert.Nil(t, e.GoalHistory())
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		vm := newVM()
+		vm.SetGoalHistory(3)
+		err := vm.Compile(context.Background(), `
+:-(p, ','(foo(f(g(h(i)))), ','(fail_on(a), ','(fail_on(b), fail_on(c))))).
+:-(initialization(p)).
+`)
+		var e Exception
+		assert.True(t, errors.As(err, &e))
+		assert.Equal(t, []string{"fail_on(a)", "fail_on(b)", "fail_on(c)"}, e.GoalHistory())
+	})
+
+	t.Run("truncated arguments", func(t *testing.T) {
+		vm := newVM()
+		vm.SetGoalHistory(2)
+		x := NewVariable()
+		env := NewEnv().bind(x, NewAtom("f").Apply(NewAtom("g").Apply(NewAtom("h").Apply(NewAtom("i")))))
+		ok, err := vm.Arrive(NewAtom("foo"), []Term{x}, Success, env).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"foo(f(g(...)))"}, vm.GoalHistory())
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		vm := newVM()
+		vm.SetGoalHistory(2)
+		_, err := vm.Arrive(NewAtom("foo"), []Term{NewAtom("a")}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Empty(t, vm.Clone().GoalHistory())
+		assert.Equal(t, []string{"foo(a)"}, vm.GoalHistory())
+	})
+}
@@ -0,0 +1,230 @@
+package engine
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+)
+
+// minIndexedClauses is the number of clauses from which a procedure is indexed.
+// Below that, trying all the clauses is cheaper than looking them up.
+const minIndexedClauses = 8
+
+// clauseIndex is an index of clauses by keys K.
+type clauseIndex[K comparable] struct {
+	// base and n identify the indexed clauses: the address of their first element and their number.
+	// Since clauses are only appended in place, the index stays valid for the clauses sharing the same base.
+	base *(*clause)
+	n    int
+
+	keys map[K][]int // the positions of the clauses of the key.
+	vars []int       // the positions of the clauses which may match any key.
+}
+
+// update makes the index cover cs. key returns the key of a clause or false if it may match any key.
+func (idx *clauseIndex[K]) update(cs clauses, key func(*clause) (K, bool)) {
+	if idx.base != &cs[0] || idx.n > len(cs) {
+		*idx = clauseIndex[K]{base: &cs[0], keys: map[K][]int{}}
+	}
+	for ; idx.n < len(cs); idx.n++ {
+		k, ok := key(cs[idx.n])
+		if !ok {
+			idx.vars = append(idx.vars, idx.n)
+			continue
+		}
+		idx.keys[k] = append(idx.keys[k], idx.n)
+	}
+}
+
+// covers reports whether the index is up to date with cs.
+func (idx *clauseIndex[K]) covers(cs clauses) bool {
+	return idx.base == &cs[0] && idx.n == len(cs)
+}
+
+// lookup returns the clauses of cs which may match key, in their order.
+func (idx *clauseIndex[K]) lookup(cs clauses, key K) clauses {
+	keyed, vars := idx.keys[key], idx.vars
+	ret := make(clauses, 0, len(keyed)+len(vars))
+	for len(keyed) > 0 || len(vars) > 0 {
+		switch {
+		case len(vars) == 0 || (len(keyed) > 0 && keyed[0] < vars[0]):
+			ret, keyed = append(ret, cs[keyed[0]]), keyed[1:]
+		default:
+			ret, vars = append(ret, cs[vars[0]]), vars[1:]
+		}
+	}
+	return ret
+}
+
+// argIndex is a hash index of clauses on a combination of their arguments declared by index/1.
+type argIndex struct {
+	args []int // the 0-based positions of the indexed arguments.
+	clauseIndex[uint64]
+}
+
+// key returns the hash of the indexed arguments of c. It returns false if any of them isn't ground.
+func (a *argIndex) key(c *clause) (uint64, bool) {
+	head, ok := c.head()
+	if !ok || head.Arity() != int(c.pi.arity) {
+		return 0, false
+	}
+	return a.hash(head.Arg, nil)
+}
+
+// hash returns the hash of the indexed arguments. It returns false if any of them isn't ground.
+func (a *argIndex) hash(arg func(int) Term, env *Env) (uint64, bool) {
+	var h maphash.Hash
+	h.SetSeed(indexSeed)
+	for _, i := range a.args {
+		if !hashTerm(&h, arg(i), env) {
+			return 0, false
+		}
+	}
+	return h.Sum64(), true
+}
+
+var indexSeed = maphash.MakeSeed()
+
+// hashTerm writes a ground term t to h so that terms which unify have the same hash.
+// It returns false if t isn't ground or contains terms which may unify without being identical, e.g. floats.
+func hashTerm(h *maphash.Hash, t Term, env *Env) bool {
+	var b [8]byte
+	switch t := env.Resolve(t).(type) {
+	case Atom:
+		_ = h.WriteByte('a')
+		_, _ = h.WriteString(t.String())
+		_ = h.WriteByte(0)
+	case Integer:
+		_ = h.WriteByte('i')
+		_, _ = h.Write(binary.LittleEndian.AppendUint64(b[:0], uint64(t)))
+	case Rational:
+		_ = h.WriteByte('r')
+		_, _ = h.Write(binary.LittleEndian.AppendUint64(b[:0], uint64(t.num)))
+		_, _ = h.Write(binary.LittleEndian.AppendUint64(b[:0], uint64(t.den)))
+	case String:
+		_ = h.WriteByte('s')
+		_, _ = h.WriteString(string(t))
+		_ = h.WriteByte(0)
+	case Dict:
+		return false
+	case Compound:
+		_ = h.WriteByte('c')
+		_, _ = h.WriteString(t.Functor().String())
+		_ = h.WriteByte(0)
+		_, _ = h.Write(binary.LittleEndian.AppendUint64(b[:0], uint64(t.Arity())))
+		for i := 0; i < t.Arity(); i++ {
+			if !hashTerm(h, t.Arg(i), env) {
+				return false
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// setClauses replaces the clauses of u with cs and updates the indexes accordingly.
+func (u *userDefined) setClauses(cs clauses) {
+	u.clauses = cs
+	u.reindex()
+}
+
+// reindex makes the indexes of u cover its clauses. It's called whenever the clauses change so that the indexes are
+// only read while the clauses are called, e.g. by the clones of a frozen VM in parallel.
+func (u *userDefined) reindex() {
+	cs := u.clauses
+	if len(cs) < minIndexedClauses {
+		return
+	}
+	u.index.update(cs, (*clause).firstArgKey)
+	for _, a := range u.argIndexes {
+		a.update(cs, a.key)
+	}
+}
+
+// candidates returns the clauses of u which may match args, in their order. It never updates the indexes and falls
+// back to all the clauses if they're not up to date.
+func (u *userDefined) candidates(args []Term, env *Env) clauses {
+	cs := u.clauses
+	if len(cs) < minIndexedClauses || len(args) == 0 {
+		return cs
+	}
+
+	// Prefer the declared index of the most arguments which are all ground.
+	var (
+		best *argIndex
+		hash uint64
+	)
+	for _, a := range u.argIndexes {
+		if best != nil && len(a.args) <= len(best.args) {
+			continue
+		}
+		if a.args[len(a.args)-1] >= len(args) || !a.covers(cs) {
+			continue
+		}
+		if h, ok := a.hash(func(i int) Term { return args[i] }, env); ok {
+			best, hash = a, h
+		}
+	}
+	if best != nil {
+		return best.lookup(cs, hash)
+	}
+
+	key, ok := indexKey(env.Resolve(args[0]))
+	if !ok || !u.index.covers(cs) {
+		return cs
+	}
+	return u.index.lookup(cs, key)
+}
+
+// head returns the head of c.
+func (c *clause) head() (Compound, bool) {
+	t := c.raw
+	for {
+		h, ok := t.(Compound)
+		if !ok {
+			return nil, false
+		}
+		switch {
+		case h.Functor() == atomColon && h.Arity() == 2:
+			t = h.Arg(1)
+		case h.Functor() == atomIf && h.Arity() == 2:
+			t = h.Arg(0)
+		default:
+			return h, true
+		}
+	}
+}
+
+// firstArgKey returns the index key of the first argument of the head of c.
+// It returns false if the first argument may match any key, e.g. a variable.
+func (c *clause) firstArgKey() (Term, bool) {
+	if c.pi.arity == 0 || len(c.bytecode) == 0 {
+		return nil, false
+	}
+	switch i := c.bytecode[0]; i.opcode {
+	case OpGetConst:
+		return indexKey(i.operand)
+	case OpGetFunctor:
+		return i.operand, true
+	case OpGetList, OpGetPartial:
+		return procedureIndicator{name: atomDot, arity: 2}, true
+	default:
+		return nil, false
+	}
+}
+
+// indexKey returns the index key of t. Atoms, integers, rationals, and strings are keys by themselves while
+// compounds are indexed by their principal functors.
+// It returns false if t may unify with terms of different keys or if it's not worth indexing, e.g. floats.
+func indexKey(t Term) (Term, bool) {
+	switch t := t.(type) {
+	case Atom, Integer, Rational, String:
+		return t, true
+	case Dict:
+		return nil, false
+	case Compound:
+		return procedureIndicator{name: t.Functor(), arity: Integer(t.Arity())}, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserDefined_candidates(t *testing.T) {
+	var (
+		x, y = NewVariable(), NewVariable()
+		foo  = NewAtom("foo")
+		p    = NewAtom("p")
+	)
+	heads := []Term{
+		p.Apply(NewAtom("a"), Integer(0)),
+		p.Apply(x, Integer(1)),
+		p.Apply(Integer(1), Integer(2)),
+		p.Apply(foo.Apply(x), Integer(3)),
+		p.Apply(List(x), Integer(4)),
+		p.Apply(PartialList(y, x), Integer(5)),
+		p.Apply(CharList("ab"), Integer(6)),
+		p.Apply(String("a"), Integer(7)),
+		p.Apply(NewAtom("a"), Integer(8)),
+		p.Apply(newFloatFromStringMust("1.0"), Integer(9)),
+		p.Apply(NewAtom("[]"), Integer(10)),
+	}
+
+	var u userDefined
+	for _, h := range heads {
+		cs, err := compile(h, nil)
+		assert.NoError(t, err)
+		u.clauses = append(u.clauses, cs...)
+	}
+	u.reindex()
+
+	tests := []struct {
+		title string
+		arg   Term
+		want  []Integer
+	}{
+		{title: "variable", arg: NewVariable(), want: []Integer{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{title: "atom", arg: NewAtom("a"), want: []Integer{0, 1, 8, 9}},
+		{title: "integer", arg: Integer(1), want: []Integer{1, 2, 9}},
+		{title: "compound", arg: foo.Apply(NewAtom("a")), want: []Integer{1, 3, 9}},
+		{title: "list", arg: List(NewAtom("a"), NewAtom("b")), want: []Integer{1, 4, 5, 6, 9}},
+		{title: "empty list", arg: atomEmptyList, want: []Integer{1, 9, 10}},
+		{title: "string", arg: String("a"), want: []Integer{1, 7, 9}},
+		{title: "float", arg: newFloatFromStringMust("1.0"), want: []Integer{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{title: "unknown", arg: NewAtom("b"), want: []Integer{1, 9}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			var got []Integer
+			for _, c := range u.candidates([]Term{tt.arg, NewVariable()}, nil) {
+				got = append(got, c.raw.(Compound).Arg(1).(Integer))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("few clauses", func(t *testing.T) {
+		u := userDefined{clauses: u.clauses[:minIndexedClauses-1]}
+		assert.Len(t, u.candidates([]Term{NewAtom("b"), NewVariable()}, nil), minIndexedClauses-1)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		vm := VM{procedures: buildOrderedMap(procedurePair{Key: procedureIndicator{name: p, arity: 2}, Value: &userDefined{dynamic: true}})}
+		ok, err := Assertz(&vm, p.Apply(Integer(0), Integer(0)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		count := func(arg Term) int {
+			var n int
+			_, err := Call(&vm, p.Apply(arg, NewVariable()), func(*Env) *Promise {
+				n++
+				return Bool(false)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			return n
+		}
+
+		for i := 1; i < 2*minIndexedClauses; i++ {
+			_, err := Assertz(&vm, p.Apply(Integer(i%2), Integer(i)), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, i/2+1, count(Integer(0)))
+		}
+
+		_, err = Asserta(&vm, p.Apply(Integer(0), Integer(-1)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, minIndexedClauses+1, count(Integer(0)))
+		assert.Equal(t, minIndexedClauses, count(Integer(1)))
+
+		_, err = Retract(&vm, p.Apply(Integer(0), NewVariable()), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, minIndexedClauses, count(Integer(0)))
+		assert.Equal(t, 2*minIndexedClauses, count(NewVariable()))
+	})
+}
+
+func TestUserDefined_candidates_argIndexes(t *testing.T) {
+	var vm VM
+	assert.NoError(t, vm.Compile(context.Background(), `
+:-(index(p(1,0,1))).
+:-(index([p(1,1,1), q(0,1)])).
+p(a, 1, x).
+p(a, 2, y).
+p(b, 3, x).
+p(X, 4, x).
+p(a, 5, f(1)).
+p(a, 6, f(X)).
+p(b, 7, "x").
+p(a, 8, x).
+p(a, 9, 1.0).
+`))
+
+	p := NewAtom("p")
+	proc, ok := vm.getProcedure(procedureIndicator{name: p, arity: 3})
+	assert.True(t, ok)
+	u := proc.(*userDefined)
+	assert.Len(t, u.argIndexes, 2)
+	assert.Equal(t, []int{0, 2}, u.argIndexes[0].args)
+	assert.Equal(t, []int{0, 1, 2}, u.argIndexes[1].args)
+
+	tests := []struct {
+		title string
+		args  []Term
+		want  []Integer
+	}{
+		{title: "declared", args: []Term{NewAtom("a"), NewVariable(), NewAtom("x")}, want: []Integer{1, 4, 6, 8, 9}},
+		{title: "compound", args: []Term{NewAtom("a"), NewVariable(), NewAtom("f").Apply(Integer(1))}, want: []Integer{4, 5, 6, 9}},
+		{title: "all declared", args: []Term{NewAtom("b"), Integer(3), NewAtom("x")}, want: []Integer{3, 4, 6, 9}},
+		{title: "not ground", args: []Term{NewAtom("b"), NewVariable(), NewAtom("f").Apply(NewVariable())}, want: []Integer{3, 4, 7}},
+		{title: "list", args: []Term{NewAtom("b"), NewVariable(), List(NewAtom("x"))}, want: []Integer{4, 6, 7, 9}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			var got []Integer
+			for _, c := range u.candidates(tt.args, nil) {
+				got = append(got, c.raw.(Compound).Arg(1).(Integer))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, ok = vm.getProcedure(procedureIndicator{name: NewAtom("q"), arity: 2})
+	assert.True(t, ok)
+
+	t.Run("errors", func(t *testing.T) {
+		for _, d := range []struct {
+			text string
+			err  error
+		}{
+			{text: `:-(index(_)).`, err: InstantiationError(nil)},
+			{text: `:-(index(p)).`, err: typeError(validTypeCompound, p, nil)},
+			{text: `:-(index(p(_))).`, err: InstantiationError(nil)},
+			{text: `:-(index(p(a))).`, err: typeError(validTypeInteger, NewAtom("a"), nil)},
+			{text: `:-(index(p(-1))).`, err: domainError(validDomainNotLessThanZero, Integer(-1), nil)},
+		} {
+			var vm VM
+			assert.Equal(t, d.err, vm.Compile(context.Background(), d.text), d.text)
+		}
+	})
+}
@@ -43,17 +43,10 @@ func (i Integer) WriteTerm(w io.Writer, opts *WriteOptions, _ *Env) error {
 func (i Integer) Compare(t Term, env *Env) int {
 	env.charge(MeterCompareStep, 1)
 	switch t := env.Resolve(t).(type) {
-	case Variable, Float:
+	case Variable:
 		return 1
-	case Integer:
-		switch {
-		case i > t:
-			return 1
-		case i < t:
-			return -1
-		default:
-			return 0
-		}
+	case Number:
+		return compareNumbers(i, t)
 	default: // Atom, custom atomic terms, Compound.
 		return -1
 	}
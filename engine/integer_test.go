@@ -47,6 +47,9 @@ func TestInteger_Compare(t *testing.T) {
 	}{
 		{title: `1 > X`, i: 1, t: x, o: 1},
 		{title: `1 > 1.0`, i: 1, t: NewFloatFromInt64(1), o: 1},
+		{title: `1 < 2.0`, i: 1, t: NewFloatFromInt64(2), o: -1},
+		{title: `1 > 0.0`, i: 1, t: NewFloatFromInt64(0), o: 1},
+		{title: `0 < 1r2`, i: 0, t: mustRational(1, 2), o: -1},
 		{title: `1 > 0`, i: 1, t: Integer(0), o: 1},
 		{title: `1 = 1`, i: 1, t: Integer(1), o: 0},
 		{title: `1 < 2`, i: 1, t: Integer(2), o: -1},
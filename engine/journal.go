@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errJournalDiverged = errors.New("journal diverged")
+
+// SetJournaling makes the VM record its successful mutations in the journal: the clauses added by asserta/1 and
+// assertz/1, the ones removed by retract/1, retractall/1, abolish/1, and erase/1, the procedures replaced by
+// ReplacePredicate, the records added by recorda/3 and recordz/3 and removed by erase/1, the global variables set by
+// nb_setval/2, the changes of the flags of the VM by set_prolog_flag/2, and the changes of the operators by op/3. The
+// changes which are local to a query, e.g. of the flag unknown or by b_setval/2, aren't recorded. Turning it off keeps
+// the journal so far.
+func (vm *VM) SetJournaling(on bool) {
+	vm.journaling = on
+}
+
+// Journal returns a copy of the journal. The entries are the goals which redo the mutations in order, e.g.
+// assertz(foo(1)), retract((foo(1):-true)), recordz(k, bar), nb_setval(k, 1), or op(700, xfx, ===). The clause removed
+// by erase/1 is recorded as erase((foo(1):-true), N) where N is its position among the clauses of its procedure, from 1,
+// and the record as erase(recorded(k, bar), N) where N is its position among the records of its key. The procedure
+// replaced by ReplacePredicate is recorded as replace_predicate(foo/1, Clauses).
+func (vm *VM) Journal() []Term {
+	return append([]Term(nil), vm.journal...)
+}
+
+// Replay redoes the mutations in journal in order, e.g. on a fresh VM with the same program as the VM the journal was
+// recorded on. It fails if a mutation doesn't succeed as it did when it was recorded.
+func (vm *VM) Replay(ctx context.Context, journal []Term) error {
+	for _, e := range journal {
+		c, ok := e.(Compound)
+		if !ok {
+			return fmt.Errorf("%w: %s", errJournalDiverged, journalEntryString(vm, e))
+		}
+		var p *Promise
+		switch pi := (procedureIndicator{name: c.Functor(), arity: Integer(c.Arity())}); pi {
+		case procedureIndicator{name: atomAsserta, arity: 1}:
+			p = Asserta(vm, c.Arg(0), Success, nil)
+		case procedureIndicator{name: atomAssertz, arity: 1}:
+			p = Assertz(vm, c.Arg(0), Success, nil)
+		case procedureIndicator{name: atomRetract, arity: 1}:
+			p = Retract(vm, c.Arg(0), Success, nil)
+		case procedureIndicator{name: atomErase, arity: 2}:
+			if r, ok := c.Arg(0).(Compound); ok && r.Functor() == atomRecorded && r.Arity() == 2 {
+				p = eraseRecordNth(vm, r.Arg(0), r.Arg(1), c.Arg(1), Success, nil)
+				break
+			}
+			p = eraseNth(vm, c.Arg(0), c.Arg(1), Success, nil)
+		case procedureIndicator{name: atomRetractAll, arity: 1}:
+			p = RetractAll(vm, c.Arg(0), Success, nil)
+		case procedureIndicator{name: atomAbolish, arity: 1}:
+			p = Abolish(vm, c.Arg(0), Success, nil)
+		case procedureIndicator{name: atomRecorda, arity: 2}:
+			p = RecordA(vm, c.Arg(0), c.Arg(1), NewVariable(), Success, nil)
+		case procedureIndicator{name: atomRecordz, arity: 2}:
+			p = RecordZ(vm, c.Arg(0), c.Arg(1), NewVariable(), Success, nil)
+		case procedureIndicator{name: atomNbSetval, arity: 2}:
+			p = NbSetval(vm, c.Arg(0), c.Arg(1), Success, nil)
+		case procedureIndicator{name: atomReplacePredicate, arity: 2}:
+			var cs []Term
+			iter := ListIterator{List: c.Arg(1)}
+			for iter.Next() {
+				cs = append(cs, iter.Current())
+			}
+			if err := iter.Err(); err != nil {
+				return err
+			}
+			if err := vm.ReplacePredicate(c.Arg(0), cs); err != nil {
+				return err
+			}
+			continue
+		case procedureIndicator{name: atomSetPrologFlag, arity: 2}:
+			p = SetPrologFlag(vm, c.Arg(0), c.Arg(1), Success, nil)
+		case procedureIndicator{name: atomOp, arity: 3}:
+			p = Op(vm, c.Arg(0), c.Arg(1), c.Arg(2), Success, nil)
+		default:
+			return fmt.Errorf("%w: %s", errJournalDiverged, journalEntryString(vm, e))
+		}
+		ok, err := p.Force(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s", errJournalDiverged, journalEntryString(vm, e))
+		}
+	}
+	return nil
+}
+
+// record appends the goal name(args...) to the journal if the VM is journaling.
+func (vm *VM) record(name Atom, args []Term, env *Env) {
+	if !vm.journaling {
+		return
+	}
+	for i, a := range args {
+		args[i] = env.simplify(a)
+	}
+	vm.journal = append(vm.journal, name.Apply(args...))
+}
+
+func journalEntryString(vm *VM, e Term) string {
+	var sb strings.Builder
+	_, _ = WriteTerm(vm, NewOutputTextStream(&sb), e, List(atomQuoted.Apply(atomTrue)), Success, nil).Force(context.Background())
+	return sb.String()
+}
@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_Journal(t *testing.T) {
+	foo := NewAtom("foo")
+	x := NewVariable()
+
+	var vm VM
+	vm.SetJournaling(true)
+	for _, p := range []func() *Promise{
+		func() *Promise { return Assertz(&vm, foo.Apply(Integer(1)), Success, nil) },
+		func() *Promise { return Assertz(&vm, foo.Apply(Integer(2)), Success, nil) },
+		func() *Promise { return Asserta(&vm, foo.Apply(Integer(0)), Success, nil) },
+		func() *Promise {
+			return Retract(&vm, foo.Apply(x), func(env *Env) *Promise {
+				// Only the second retraction satisfies the goal but the first one is done anyway.
+				return Bool(env.Resolve(x) == Integer(1))
+			}, nil)
+		},
+		func() *Promise { return Op(&vm, Integer(700), atomXFX, NewAtom("==="), Success, nil) },
+		func() *Promise { return SetPrologFlag(&vm, atomDoubleQuotes, atomAtom, Success, nil) },
+		func() *Promise { return SetPrologFlag(&vm, atomPreferRationals, atomTrue, Success, nil) },
+		func() *Promise { return Abolish(&vm, atomSlash.Apply(NewAtom("bar"), Integer(0)), Success, nil) },
+	} {
+		_, _ = p().Force(context.Background())
+	}
+
+	assert.Equal(t, []Term{
+		atomAssertz.Apply(foo.Apply(Integer(1))),
+		atomAssertz.Apply(foo.Apply(Integer(2))),
+		atomAsserta.Apply(foo.Apply(Integer(0))),
+		atomRetract.Apply(atomIf.Apply(foo.Apply(Integer(0)), atomTrue)),
+		atomRetract.Apply(atomIf.Apply(foo.Apply(Integer(1)), atomTrue)),
+		atomOp.Apply(Integer(700), atomXFX, NewAtom("===")),
+		atomSetPrologFlag.Apply(atomPreferRationals, atomTrue),
+	}, vm.Journal())
+
+	t.Run("replay", func(t *testing.T) {
+		var replica VM
+		assert.NoError(t, replica.Replay(context.Background(), vm.Journal()))
+		p, ok := replica.getProcedure(procedureIndicator{name: foo, arity: 1})
+		assert.True(t, ok)
+		assert.Len(t, p.(*userDefined).clauses, 1)
+		assert.Equal(t, foo.Apply(Integer(2)), p.(*userDefined).clauses[0].raw)
+		assert.True(t, replica.getOperators().definedInClass(NewAtom("==="), operatorClassInfix))
+		assert.True(t, replica.preferRationals)
+	})
+
+	t.Run("diverged", func(t *testing.T) {
+		var replica VM
+		err := replica.Replay(context.Background(), []Term{atomRetract.Apply(foo.Apply(Integer(1)))})
+		assert.ErrorIs(t, err, errJournalDiverged)
+		err = replica.Replay(context.Background(), []Term{NewAtom("halt")})
+		assert.ErrorIs(t, err, errJournalDiverged)
+	})
+
+	t.Run("records, globals, and replaced procedures", func(t *testing.T) {
+		k, bar := NewAtom("k"), NewAtom("bar")
+		var vm VM
+		vm.SetJournaling(true)
+		ref := NewVariable()
+		for _, p := range []func() *Promise{
+			func() *Promise { return RecordZ(&vm, k, Integer(1), NewVariable(), Success, nil) },
+			func() *Promise {
+				return RecordZ(&vm, k, Integer(2), ref, func(env *Env) *Promise {
+					return Erase(&vm, ref, Success, env)
+				}, nil)
+			},
+			func() *Promise { return RecordA(&vm, k, Integer(0), NewVariable(), Success, nil) },
+			func() *Promise { return NbSetval(&vm, k, foo, Success, nil) },
+			func() *Promise { return BSetval(&vm, k, bar, Success, nil) },
+		} {
+			ok, err := p().Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+		assert.NoError(t, vm.ReplacePredicate(atomSlash.Apply(bar, Integer(0)), []Term{bar}))
+
+		assert.Equal(t, []Term{
+			atomRecordz.Apply(k, Integer(1)),
+			atomRecordz.Apply(k, Integer(2)),
+			atomErase.Apply(atomRecorded.Apply(k, Integer(2)), Integer(2)),
+			atomRecorda.Apply(k, Integer(0)),
+			atomNbSetval.Apply(k, foo),
+			atomReplacePredicate.Apply(atomSlash.Apply(bar, Integer(0)), List(bar)),
+		}, vm.Journal())
+
+		var replica VM
+		assert.NoError(t, replica.Replay(context.Background(), vm.Journal()))
+		var got []Term
+		for _, r := range refs(replica.records.Value(recordKey{pi: procedureIndicator{name: k}})) {
+			got = append(got, r.term)
+		}
+		assert.Equal(t, []Term{Integer(0), Integer(1)}, got)
+		assert.Equal(t, foo, replica.globals[k])
+		_, ok := replica.getProcedure(procedureIndicator{name: bar})
+		assert.True(t, ok)
+	})
+
+	t.Run("off", func(t *testing.T) {
+		vm.SetJournaling(false)
+		_, err := Assertz(&vm, foo.Apply(Integer(3)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, vm.Journal(), 7)
+	})
+}
@@ -0,0 +1,412 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// JSONOptions configures the conversion between JSON values and terms.
+//
+// Objects are dicts if Dict is true and json([Key=Value, ...]) otherwise. Arrays are lists and numbers are integers or
+// floats. Strings are strings if Dict is true and atoms otherwise.
+type JSONOptions struct {
+	Dict bool
+	Tag  Term // the tag of the dicts. If nil, the tags are fresh variables.
+
+	// The terms of the JSON constants. If nil, they're the atoms null, true, and false if Dict is true and @(null),
+	// @(true), and @(false) otherwise.
+	Null, True, False Term
+}
+
+func (o *JSONOptions) constant(name Atom) Term {
+	var t Term
+	switch name {
+	case atomNull:
+		t = o.Null
+	case atomTrue:
+		t = o.True
+	case atomFalse:
+		t = o.False
+	}
+	switch {
+	case t != nil:
+		return t
+	case o.Dict:
+		return name
+	default:
+		return atomAtSign.Apply(name)
+	}
+}
+
+var errJSONTrailingData = errors.New("trailing data after JSON value")
+
+// TermFromJSON returns the term of the JSON value data.
+func TermFromJSON(data []byte, opts JSONOptions) (Term, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	t, err := readJSON(d, &opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errJSONTrailingData
+	}
+	return t, nil
+}
+
+// TermToJSON returns the JSON encoding of t. It's the inverse of TermFromJSON except that it accepts both the dicts and
+// the json([Key=Value, ...]) terms as objects, Key-Value and Key(Value) as well as Key=Value, and atoms as strings.
+func TermToJSON(t Term, opts JSONOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, t, &opts, map[termID]struct{}{}, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func readJSON(d *json.Decoder, opts *JSONOptions) (Term, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '[':
+			var elems []Term
+			for d.More() {
+				e, err := readJSON(d, opts)
+				if err != nil {
+					return nil, err
+				}
+				elems = append(elems, e)
+			}
+			if _, err := d.Token(); err != nil {
+				return nil, err
+			}
+			return List(elems...), nil
+		default: // '{'
+			return readJSONObject(d, opts)
+		}
+	case string:
+		if opts.Dict {
+			return String(tok), nil
+		}
+		return NewAtom(tok), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(tok), 10, 64); err == nil {
+			return Integer(i), nil
+		}
+		return NewFloatFromString(string(tok))
+	case bool:
+		if tok {
+			return opts.constant(atomTrue), nil
+		}
+		return opts.constant(atomFalse), nil
+	default: // nil
+		return opts.constant(atomNull), nil
+	}
+}
+
+func readJSONObject(d *json.Decoder, opts *JSONOptions) (Term, error) {
+	var kvs []Term
+	for d.More() {
+		k, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		v, err := readJSON(d, opts)
+		if err != nil {
+			return nil, err
+		}
+		kvs = append(kvs, NewAtom(k.(string)), v)
+	}
+	if _, err := d.Token(); err != nil {
+		return nil, err
+	}
+
+	if !opts.Dict {
+		pairs := make([]Term, 0, len(kvs)/2)
+		for i := 0; i < len(kvs); i += 2 {
+			pairs = append(pairs, atomEqual.Apply(kvs[i], kvs[i+1]))
+		}
+		return atomJSON.Apply(List(pairs...)), nil
+	}
+	tag := opts.Tag
+	if tag == nil {
+		tag = NewVariable()
+	}
+	return NewDict(append([]Term{tag}, kvs...))
+}
+
+// writeJSON writes t to w. visiting is the set of the objects and arrays enclosing t so that a cyclic term results in a
+// domain error instead of an infinite recursion.
+func writeJSON(w *bytes.Buffer, t Term, opts *JSONOptions, visiting map[termID]struct{}, env *Env) error {
+	t = env.Resolve(t)
+	if _, ok := t.(Compound); ok {
+		if _, ok := visiting[id(t)]; ok {
+			return domainError(validDomainAcyclicTerm, t, env)
+		}
+		visiting[id(t)] = struct{}{}
+		defer delete(visiting, id(t))
+	}
+	for _, c := range [...]Atom{atomNull, atomTrue, atomFalse} {
+		if _, ok := t.(Variable); !ok && t.Compare(opts.constant(c), env) == 0 {
+			_, _ = w.WriteString(c.String())
+			return nil
+		}
+	}
+
+	switch t := t.(type) {
+	case Variable:
+		return InstantiationError(env)
+	case Integer:
+		_, _ = w.WriteString(strconv.FormatInt(int64(t), 10))
+	case Float:
+		_, _ = w.WriteString(t.String())
+	case String:
+		writeJSONString(w, string(t))
+	case Atom:
+		if t == atomEmptyList {
+			_, _ = w.WriteString("[]")
+			break
+		}
+		writeJSONString(w, t.String())
+	case Dict:
+		_ = w.WriteByte('{')
+		i := 0
+		for k, v := range t.All() {
+			if i > 0 {
+				_ = w.WriteByte(',')
+			}
+			i++
+			writeJSONString(w, k.String())
+			_ = w.WriteByte(':')
+			if err := writeJSON(w, v, opts, visiting, env); err != nil {
+				return err
+			}
+		}
+		_ = w.WriteByte('}')
+	case Compound:
+		if t.Functor() == atomJSON && t.Arity() == 1 {
+			return writeJSONObject(w, t.Arg(0), opts, visiting, env)
+		}
+		if t.Functor() != atomDot || t.Arity() != 2 {
+			return typeError(validTypeJSON, t, env)
+		}
+		_ = w.WriteByte('[')
+		iter := ListIterator{List: t, Env: env}
+		for i := 0; iter.Next(); i++ {
+			if i > 0 {
+				_ = w.WriteByte(',')
+			}
+			if err := writeJSON(w, iter.Current(), opts, visiting, env); err != nil {
+				return err
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+		_ = w.WriteByte(']')
+	default:
+		return typeError(validTypeJSON, t, env)
+	}
+	return nil
+}
+
+func writeJSONObject(w *bytes.Buffer, pairs Term, opts *JSONOptions, visiting map[termID]struct{}, env *Env) error {
+	_ = w.WriteByte('{')
+	iter := ListIterator{List: pairs, Env: env}
+	for i := 0; iter.Next(); i++ {
+		if i > 0 {
+			_ = w.WriteByte(',')
+		}
+		var k, v Term
+		switch p := env.Resolve(iter.Current()).(type) {
+		case Variable:
+			return InstantiationError(env)
+		case Compound:
+			switch {
+			case (p.Functor() == atomEqual || p.Functor() == atomMinus) && p.Arity() == 2:
+				k, v = p.Arg(0), p.Arg(1)
+			case p.Arity() == 1:
+				k, v = p.Functor(), p.Arg(0)
+			default:
+				return typeError(validTypePair, p, env)
+			}
+		default:
+			return typeError(validTypePair, p, env)
+		}
+		switch k := env.Resolve(k).(type) {
+		case Variable:
+			return InstantiationError(env)
+		case Atom:
+			writeJSONString(w, k.String())
+		case String:
+			writeJSONString(w, string(k))
+		default:
+			return typeError(validTypeAtom, k, env)
+		}
+		_ = w.WriteByte(':')
+		if err := writeJSON(w, v, opts, visiting, env); err != nil {
+			return err
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	_ = w.WriteByte('}')
+	return nil
+}
+
+func writeJSONString(w *bytes.Buffer, s string) {
+	e := json.NewEncoder(w)
+	e.SetEscapeHTML(false)
+	_ = e.Encode(s)
+	w.Truncate(w.Len() - 1) // the newline added by Encode.
+}
+
+// JSONRead reads a JSON value from the stream represented by streamOrAlias and unifies it with term.
+// Objects are read as json([Key=Value, ...]), strings as atoms, and the constants as @(null), @(true), and @(false).
+// options are null(Null), true(True), and false(False) to represent the constants otherwise.
+// If the stream is at its end, term is unified with end_of_file.
+func JSONRead(vm *VM, streamOrAlias, term, options Term, k Cont, env *Env) *Promise {
+	return jsonRead(vm, streamOrAlias, term, options, JSONOptions{}, k, env)
+}
+
+// JSONReadDict is like JSONRead but reads objects as dicts, strings as strings, and the constants as the atoms null,
+// true, and false. The option tag(Tag) sets the tag of the dicts.
+func JSONReadDict(vm *VM, streamOrAlias, dict, options Term, k Cont, env *Env) *Promise {
+	return jsonRead(vm, streamOrAlias, dict, options, JSONOptions{Dict: true}, k, env)
+}
+
+func jsonRead(vm *VM, streamOrAlias, term, options Term, opts JSONOptions, k Cont, env *Env) *Promise {
+	s, err := stream(vm, streamOrAlias, env)
+	if err != nil {
+		return Error(err)
+	}
+	if err := jsonOptions(&opts, options, env); err != nil {
+		return Error(err)
+	}
+
+	d := json.NewDecoder(runeReader{s: s})
+	d.UseNumber()
+	t, err := readJSON(d, &opts)
+	if b, _ := io.ReadAll(d.Buffered()); len(b) > 0 {
+		_ = s.UnreadRune() // the decoder reads one rune past a number.
+	}
+	switch {
+	case err == nil:
+		return Unify(vm, term, t, k, env)
+	case errors.Is(err, io.EOF):
+		return Unify(vm, term, atomEndOfFile, k, env)
+	case errors.Is(err, errWrongIOMode):
+		return Error(permissionError(operationInput, permissionTypeStream, streamOrAlias, env))
+	case errors.Is(err, errWrongStreamType):
+		return Error(permissionError(operationInput, permissionTypeBinaryStream, streamOrAlias, env))
+	case errors.Is(err, errPastEndOfStream):
+		return Error(permissionError(operationInput, permissionTypePastEndOfStream, streamOrAlias, env))
+	default:
+		var e Exception
+		if errors.As(err, &e) {
+			return Error(e)
+		}
+		return Error(syntaxError(err, env))
+	}
+}
+
+// runeReader reads a stream one rune at a time so that a JSON decoder doesn't consume more than the JSON value.
+type runeReader struct {
+	s *Stream
+}
+
+func (r runeReader) Read(p []byte) (int, error) {
+	if len(p) < utf8.UTFMax {
+		return 0, io.ErrShortBuffer
+	}
+	c, _, err := r.s.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	return utf8.EncodeRune(p, c), nil
+}
+
+// JSONWrite writes term to the stream represented by streamOrAlias as a JSON value.
+// Objects are dicts or json(Pairs) where Pairs is a list of Key=Value, Key-Value, or Key(Value). Lists are arrays,
+// numbers are numbers, and atoms and strings are strings, except [] which is an empty array, and @(null), @(true), and
+// @(false) which are the constants. options are null(Null), true(True), and false(False) to represent the constants
+// otherwise.
+func JSONWrite(vm *VM, streamOrAlias, term, options Term, k Cont, env *Env) *Promise {
+	return jsonWrite(vm, streamOrAlias, term, options, JSONOptions{}, k, env)
+}
+
+// JSONWriteDict is like JSONWrite but the constants are represented by the atoms null, true, and false.
+func JSONWriteDict(vm *VM, streamOrAlias, dict, options Term, k Cont, env *Env) *Promise {
+	return jsonWrite(vm, streamOrAlias, dict, options, JSONOptions{Dict: true}, k, env)
+}
+
+func jsonWrite(vm *VM, streamOrAlias, term, options Term, opts JSONOptions, k Cont, env *Env) *Promise {
+	s, err := stream(vm, streamOrAlias, env)
+	if err != nil {
+		return Error(err)
+	}
+	if err := jsonOptions(&opts, options, env); err != nil {
+		return Error(err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, term, &opts, map[termID]struct{}{}, env); err != nil {
+		return Error(err)
+	}
+
+	w, err := s.textWriter()
+	switch {
+	case errors.Is(err, errWrongIOMode):
+		return Error(permissionError(operationOutput, permissionTypeStream, streamOrAlias, env))
+	case errors.Is(err, errWrongStreamType):
+		return Error(permissionError(operationOutput, permissionTypeBinaryStream, streamOrAlias, env))
+	case err != nil:
+		return Error(err)
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return Error(err)
+	}
+	return k(env)
+}
+
+func jsonOptions(opts *JSONOptions, options Term, env *Env) error {
+	iter := ListIterator{List: options, Env: env}
+	for iter.Next() {
+		switch o := env.Resolve(iter.Current()).(type) {
+		case Variable:
+			return InstantiationError(env)
+		case Compound:
+			if o.Arity() != 1 {
+				return domainError(validDomainJSONOption, o, env)
+			}
+			v := env.Resolve(o.Arg(0))
+			switch o.Functor() {
+			case atomNull:
+				opts.Null = v
+			case atomTrue:
+				opts.True = v
+			case atomFalse:
+				opts.False = v
+			case atomTag:
+				if !opts.Dict {
+					return domainError(validDomainJSONOption, o, env)
+				}
+				opts.Tag = v
+			default:
+				return domainError(validDomainJSONOption, o, env)
+			}
+		default:
+			return domainError(validDomainJSONOption, o, env)
+		}
+	}
+	return iter.Err()
+}
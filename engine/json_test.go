@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTermFromJSON(t *testing.T) {
+	f, err := NewFloatFromString("1.5")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		title string
+		json  string
+		opts  JSONOptions
+		term  Term
+		err   bool
+	}{
+		{title: "object", json: `{"b": [1, 1.5], "a": "x"}`, term: atomJSON.Apply(List(
+			atomEqual.Apply(NewAtom("b"), List(Integer(1), f)),
+			atomEqual.Apply(NewAtom("a"), NewAtom("x")),
+		))},
+		{title: "constants", json: `[null, true, false]`, term: List(
+			atomAtSign.Apply(atomNull),
+			atomAtSign.Apply(atomTrue),
+			atomAtSign.Apply(atomFalse),
+		)},
+		{title: "custom constants", json: `[null, true]`, opts: JSONOptions{Null: NewAtom("nil"), True: Integer(1)}, term: List(NewAtom("nil"), Integer(1))},
+		{title: "dict", json: `{"b": "y", "a": null}`, opts: JSONOptions{Dict: true, Tag: NewAtom("t")}, term: newDict([]Term{
+			NewAtom("t"),
+			NewAtom("a"), atomNull,
+			NewAtom("b"), String("y"),
+		})},
+		{title: "empty array", json: `[]`, term: atomEmptyList},
+		{title: "trailing data", json: `1 2`, err: true},
+		{title: "invalid", json: `{"a"}`, err: true},
+		{title: "duplicate key", json: `{"a": 1, "a": 2}`, opts: JSONOptions{Dict: true}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			term, err := TermFromJSON([]byte(tt.json), tt.opts)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.term, term)
+		})
+	}
+}
+
+func TestTermToJSON(t *testing.T) {
+	f, err := NewFloatFromString("1.5")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		title string
+		term  Term
+		opts  JSONOptions
+		json  string
+		err   error
+	}{
+		{title: "object", term: atomJSON.Apply(List(
+			atomEqual.Apply(NewAtom("b"), List(Integer(1), f)),
+			atomMinus.Apply(String("a"), NewAtom(`"x"`)),
+			NewAtom("c").Apply(atomEmptyList),
+		)), json: `{"b":[1,1.5],"a":"\"x\"","c":[]}`},
+		{title: "dict", term: newDict([]Term{
+			NewVariable(),
+			NewAtom("a"), atomNull,
+			NewAtom("b"), String("<y>"),
+		}), opts: JSONOptions{Dict: true}, json: `{"a":null,"b":"<y>"}`},
+		{title: "constants", term: List(atomAtSign.Apply(atomNull), atomTrue), json: `[null,"true"]`},
+		{title: "custom constants", term: List(NewAtom("nil"), atomNull), opts: JSONOptions{Null: NewAtom("nil")}, json: `[null,"null"]`},
+		{title: "variable", term: List(NewVariable()), err: InstantiationError(nil)},
+		{title: "not json", term: NewAtom("f").Apply(Integer(1), Integer(2)), err: typeError(validTypeJSON, NewAtom("f").Apply(Integer(1), Integer(2)), nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			b, err := TermToJSON(tt.term, tt.opts)
+			assert.Equal(t, tt.err, err)
+			if tt.err == nil {
+				assert.Equal(t, tt.json, string(b))
+			}
+		})
+	}
+}
+
+func TestJSONRead(t *testing.T) {
+	t.Run("values one after another", func(t *testing.T) {
+		s := NewInputTextStream(strings.NewReader(`{"a": 1} 42 "x"`))
+		var got []Term
+		for i := 0; i < 4; i++ {
+			v := NewVariable()
+			ok, err := JSONRead(nil, s, v, List(), func(env *Env) *Promise {
+				got = append(got, env.Resolve(v))
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+		assert.Equal(t, []Term{
+			atomJSON.Apply(List(atomEqual.Apply(NewAtom("a"), Integer(1)))),
+			Integer(42),
+			NewAtom("x"),
+			atomEndOfFile,
+		}, got)
+	})
+
+	t.Run("dict with options", func(t *testing.T) {
+		s := NewInputTextStream(strings.NewReader(`{"a": [true, null]}`))
+		v := NewVariable()
+		ok, err := JSONReadDict(nil, s, v, List(atomTag.Apply(NewAtom("t")), atomNull.Apply(NewAtom("none"))), func(env *Env) *Promise {
+			assert.Equal(t, newDict([]Term{NewAtom("t"), NewAtom("a"), List(atomTrue, NewAtom("none"))}), env.Resolve(v))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		s := NewInputTextStream(strings.NewReader(`{"a" 1}`))
+		_, err := JSONRead(nil, s, NewVariable(), List(), Success, nil).Force(context.Background())
+		_, ok := err.(Exception)
+		assert.True(t, ok)
+	})
+
+	t.Run("unknown option", func(t *testing.T) {
+		s := NewInputTextStream(strings.NewReader(`1`))
+		_, err := JSONRead(nil, s, NewVariable(), List(atomTag.Apply(NewAtom("t"))), Success, nil).Force(context.Background())
+		assert.Equal(t, domainError(validDomainJSONOption, atomTag.Apply(NewAtom("t")), nil), err)
+	})
+
+	t.Run("output stream", func(t *testing.T) {
+		s := NewOutputTextStream(&bytes.Buffer{})
+		_, err := JSONRead(nil, s, NewVariable(), List(), Success, nil).Force(context.Background())
+		assert.Equal(t, permissionError(operationInput, permissionTypeStream, s, nil), err)
+	})
+}
+
+func TestJSONWrite(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		s := NewOutputTextStream(&buf)
+		ok, err := JSONWrite(nil, s, atomJSON.Apply(List(atomEqual.Apply(NewAtom("a"), atomAtSign.Apply(atomTrue)))), List(), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, `{"a":true}`, buf.String())
+	})
+
+	t.Run("dict", func(t *testing.T) {
+		var buf bytes.Buffer
+		s := NewOutputTextStream(&buf)
+		ok, err := JSONWriteDict(nil, s, newDict([]Term{NewVariable(), NewAtom("a"), atomFalse}), List(atomFalse.Apply(Integer(0))), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, `{"a":"false"}`, buf.String())
+	})
+
+	t.Run("cyclic", func(t *testing.T) {
+		x, y := NewVariable(), NewVariable()
+		c := atomJSON.Apply(List(atomEqual.Apply(NewAtom("a"), x)))
+		l := List(Integer(1), y)
+		env := NewEnv().bind(x, c).bind(y, l)
+
+		var buf bytes.Buffer
+		s := NewOutputTextStream(&buf)
+		_, err := JSONWrite(nil, s, x, List(), Success, env).Force(context.Background())
+		assert.Equal(t, domainError(validDomainAcyclicTerm, c, env), err)
+
+		_, err = JSONWrite(nil, s, y, List(), Success, env).Force(context.Background())
+		assert.Equal(t, domainError(validDomainAcyclicTerm, l, env), err)
+		assert.Empty(t, buf.String())
+
+		shared := List(Integer(1))
+		ok, err := JSONWrite(nil, s, List(shared, shared), List(), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, `[[1],[1]]`, buf.String())
+	})
+
+	t.Run("input stream", func(t *testing.T) {
+		s := NewInputTextStream(strings.NewReader(""))
+		_, err := JSONWrite(nil, s, Integer(1), List(), Success, nil).Force(context.Background())
+		assert.Equal(t, permissionError(operationOutput, permissionTypeStream, s, nil), err)
+	})
+}
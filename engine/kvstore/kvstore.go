@@ -0,0 +1,198 @@
+// Package kvstore provides an engine.ClauseStore backed by an ordered key-value store such as the KVStore of the
+// Cosmos SDK, so that the dynamic procedures persist in the state of a chain.
+//
+// The clauses of a procedure are stored under the keys prefix | len(pi) | pi | key where pi is the deterministic CBOR
+// encoding of the predicate indicator name/arity, len(pi) is its length as a uvarint, and key is the key of the clause
+// as a big-endian uint64 with the sign bit flipped so that the negative keys come first. Since the keys are compared
+// bytewise, the procedures and their clauses are iterated in the same order on every node.
+package kvstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/axone-protocol/prolog/v3/engine"
+)
+
+// Iterator iterates over a range of the keys of a KVStore in ascending order. dbm.Iterator of CometBFT satisfies it.
+type Iterator interface {
+	Valid() bool
+	Next()
+	Key() []byte
+	Value() []byte
+	Error() error
+	Close() error
+}
+
+// KVStore is an ordered key-value store. The KVStore of the Cosmos SDK satisfies KVStore[storetypes.Iterator].
+type KVStore[I Iterator] interface {
+	Set(key, value []byte)
+	Delete(key []byte)
+	// Iterator returns an iterator over the keys in [start, end). A nil end means no upper bound.
+	Iterator(start, end []byte) I
+}
+
+var errMalformedKey = errors.New("malformed clause key")
+
+// Store is an engine.ClauseStore over the keys with a prefix in a KVStore.
+type Store[I Iterator] struct {
+	kv     KVStore[I]
+	prefix []byte
+}
+
+var _ engine.ClauseStore = (*Store[Iterator])(nil)
+
+// New returns a clause store over the keys starting with prefix in kv, e.g.
+//
+//	err := vm.SetClauseStore(kvstore.New[storetypes.Iterator](ctx.KVStore(key), []byte("clauses/")))
+func New[I Iterator](kv KVStore[I], prefix []byte) *Store[I] {
+	return &Store[I]{kv: kv, prefix: prefix}
+}
+
+// Get returns the clauses of the procedure pi in the order of their keys.
+func (s *Store[I]) Get(pi string) ([]engine.StoredClause, error) {
+	p, err := s.procedureKey(pi)
+	if err != nil {
+		return nil, err
+	}
+	var clauses []engine.StoredClause
+	err = s.iterate(p, func(key, value []byte) bool {
+		clauses = append(clauses, engine.StoredClause{
+			Key:    int64(binary.BigEndian.Uint64(key[len(p):]) ^ signBit),
+			Record: value,
+		})
+		return true
+	})
+	return clauses, err
+}
+
+// Put stores record as the clause of the procedure pi under key.
+func (s *Store[I]) Put(pi string, key int64, record []byte) error {
+	k, err := s.clauseKey(pi, key)
+	if err != nil {
+		return err
+	}
+	s.kv.Set(k, record)
+	return nil
+}
+
+// Delete removes the clause of the procedure pi under key.
+func (s *Store[I]) Delete(pi string, key int64) error {
+	k, err := s.clauseKey(pi, key)
+	if err != nil {
+		return err
+	}
+	s.kv.Delete(k)
+	return nil
+}
+
+// Scan calls f with the stored procedures in the order of their keys.
+func (s *Store[I]) Scan(f func(pi string) bool) error {
+	var (
+		last []byte
+		err  error
+	)
+	if iterErr := s.iterate(s.prefix, func(key, _ []byte) bool {
+		var p []byte
+		if p, err = procedure(key[len(s.prefix):]); err != nil {
+			return false
+		}
+		if string(p) == string(last) {
+			return true
+		}
+		last = bytes.Clone(p)
+
+		var t engine.Term
+		if t, err = engine.DecodeTerm(p); err != nil {
+			return false
+		}
+		var pi string
+		if pi, err = indicator(t); err != nil {
+			return false
+		}
+		return f(pi)
+	}); iterErr != nil {
+		return iterErr
+	}
+	return err
+}
+
+// signBit flips the sign of the keys of the clauses so that they're ordered bytewise.
+const signBit = 1 << 63
+
+// clauseKey returns the key of the clause of the procedure pi under key.
+func (s *Store[I]) clauseKey(pi string, key int64) ([]byte, error) {
+	p, err := s.procedureKey(pi)
+	if err != nil {
+		return nil, err
+	}
+	return binary.BigEndian.AppendUint64(p, uint64(key)^signBit), nil
+}
+
+// procedureKey returns the prefix of the keys of the clauses of the procedure pi.
+func (s *Store[I]) procedureKey(pi string) ([]byte, error) {
+	t, err := engine.ParsePI(pi)
+	if err != nil {
+		return nil, err
+	}
+	p, err := engine.EncodeTerm(t, nil)
+	if err != nil {
+		return nil, err
+	}
+	k := binary.AppendUvarint(bytes.Clone(s.prefix), uint64(len(p)))
+	return append(k, p...), nil
+}
+
+// iterate calls f with the entries whose keys start with prefix until f returns false.
+func (s *Store[I]) iterate(prefix []byte, f func(key, value []byte) bool) error {
+	it := s.kv.Iterator(prefix, prefixEnd(prefix))
+	for ; it.Valid(); it.Next() {
+		if !f(it.Key(), it.Value()) {
+			break
+		}
+	}
+	if err := it.Error(); err != nil {
+		_ = it.Close()
+		return err
+	}
+	return it.Close()
+}
+
+// procedure returns the encoding of the predicate indicator in the key of a clause without the prefix of the store.
+func procedure(key []byte) ([]byte, error) {
+	l, n := binary.Uvarint(key)
+	if n <= 0 || l > uint64(len(key)-n) || uint64(len(key)-n)-l != 8 {
+		return nil, fmt.Errorf("%w: %x", errMalformedKey, key)
+	}
+	return key[n : n+int(l)], nil
+}
+
+func indicator(t engine.Term) (string, error) {
+	c, ok := t.(engine.Compound)
+	if !ok || c.Functor() != engine.NewAtom("/") || c.Arity() != 2 {
+		return "", errMalformedKey
+	}
+	name, ok := c.Arg(0).(engine.Atom)
+	if !ok {
+		return "", errMalformedKey
+	}
+	arity, ok := c.Arg(1).(engine.Integer)
+	if !ok {
+		return "", errMalformedKey
+	}
+	return fmt.Sprint(engine.PI(name.String(), int(arity))), nil
+}
+
+// prefixEnd returns the smallest key greater than all the keys starting with prefix, or nil if there's no such key.
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package kvstore
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/axone-protocol/prolog/v3/engine"
+)
+
+// memKV is an in-memory KVStore which iterates over a snapshot of the keys like an IAVL store.
+type memKV map[string][]byte
+
+func (m memKV) Set(key, value []byte) {
+	m[string(key)] = value
+}
+
+func (m memKV) Delete(key []byte) {
+	delete(m, string(key))
+}
+
+func (m memKV) Iterator(start, end []byte) *memIterator {
+	var keys []string
+	for k := range m {
+		if k >= string(start) && (end == nil || k < string(end)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return &memIterator{kv: m, keys: keys}
+}
+
+type memIterator struct {
+	kv   memKV
+	keys []string
+}
+
+func (i *memIterator) Valid() bool   { return len(i.keys) > 0 }
+func (i *memIterator) Next()         { i.keys = i.keys[1:] }
+func (i *memIterator) Key() []byte   { return []byte(i.keys[0]) }
+func (i *memIterator) Value() []byte { return i.kv[i.keys[0]] }
+func (i *memIterator) Error() error  { return nil }
+func (i *memIterator) Close() error  { return nil }
+
+func TestStore(t *testing.T) {
+	kv := memKV{"other": []byte("x")}
+	s := New[*memIterator](kv, []byte("clauses/"))
+
+	assert.NoError(t, s.Put("foo/1", 0, []byte("a")))
+	assert.NoError(t, s.Put("foo/1", 1, []byte("b")))
+	assert.NoError(t, s.Put("foo/1", -1, []byte("c")))
+	assert.NoError(t, s.Put("'=..'/2", 0, []byte("d")))
+	assert.NoError(t, s.Put("bar/0", 0, []byte("e")))
+
+	cs, err := s.Get("foo/1")
+	assert.NoError(t, err)
+	assert.Equal(t, []engine.StoredClause{
+		{Key: -1, Record: []byte("c")},
+		{Key: 0, Record: []byte("a")},
+		{Key: 1, Record: []byte("b")},
+	}, cs)
+
+	assert.NoError(t, s.Delete("foo/1", 0))
+	assert.NoError(t, s.Put("foo/1", 1, []byte("f")))
+	cs, err = s.Get("foo/1")
+	assert.NoError(t, err)
+	assert.Equal(t, []engine.StoredClause{
+		{Key: -1, Record: []byte("c")},
+		{Key: 1, Record: []byte("f")},
+	}, cs)
+
+	cs, err = s.Get("baz/3")
+	assert.NoError(t, err)
+	assert.Nil(t, cs)
+
+	var pis []string
+	assert.NoError(t, s.Scan(func(pi string) bool {
+		pis = append(pis, pi)
+		return true
+	}))
+	assert.Equal(t, []string{"=../2", "bar/0", "foo/1"}, pis)
+
+	assert.NoError(t, s.Delete("bar/0", 0))
+	pis = nil
+	assert.NoError(t, s.Scan(func(pi string) bool {
+		pis = append(pis, pi)
+		return false
+	}))
+	assert.Equal(t, []string{"=../2"}, pis)
+	assert.Equal(t, []byte("x"), kv["other"])
+
+	_, err = s.Get("foo")
+	assert.Error(t, err)
+	assert.Error(t, s.Put("foo", 0, nil))
+	assert.Error(t, s.Delete("foo", 0))
+}
+
+func TestStore_malformed(t *testing.T) {
+	kv := memKV{"clauses/\x05": nil}
+	err := New[*memIterator](kv, []byte("clauses/")).Scan(func(string) bool {
+		return true
+	})
+	assert.ErrorIs(t, err, errMalformedKey)
+}
+
+func TestStore_clauseStore(t *testing.T) {
+	kv := memKV{}
+	foo := engine.NewAtom("foo")
+
+	var vm engine.VM
+	assert.NoError(t, vm.SetClauseStore(New[*memIterator](kv, nil)))
+	for i := range 3 {
+		_, err := engine.Assertz(&vm, foo.Apply(engine.Integer(i)), engine.Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+	}
+	ok, err := engine.Retract(&vm, foo.Apply(engine.Integer(1)), engine.Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	var restored engine.VM
+	assert.NoError(t, restored.SetClauseStore(New[*memIterator](kv, nil)))
+	x := engine.NewVariable()
+	var got []engine.Term
+	_, err = engine.Call(&restored, foo.Apply(x), func(env *engine.Env) *engine.Promise {
+		got = append(got, env.Resolve(x))
+		return engine.Bool(false)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []engine.Term{engine.Integer(0), engine.Integer(2)}, got)
+
+	// The keys are the same whatever the order of the map iteration.
+	var keys []string
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	assert.Len(t, keys, 2)
+	assert.True(t, bytes.HasSuffix([]byte(keys[0]), []byte{0x80, 0, 0, 0, 0, 0, 0, 0}))
+	assert.True(t, bytes.HasSuffix([]byte(keys[1]), []byte{0x80, 0, 0, 0, 0, 0, 0, 2}))
+
+	// Asserting a clause writes its key only.
+	before := maps.Clone(kv)
+	_, err = engine.Asserta(&restored, foo.Apply(engine.Integer(3)), engine.Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, kv, 3)
+	for k, v := range before {
+		assert.Equal(t, v, kv[k])
+	}
+	cs, err := New[*memIterator](kv, nil).Get("foo/1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), cs[0].Key)
+}
@@ -0,0 +1,48 @@
+package engine
+
+// Lambda calls a lambda expression Params>>Body or Free/Params>>Body with the additional arguments as (>>)/N does.
+// args are Params, Body, and the additional arguments in this order.
+//
+// The lambda expression is copied before the call so that its variables are renamed apart for every call, except the
+// variables of Free which are shared with the context. The parameters are unified with the additional arguments from
+// the first, and the additional arguments left over are appended to Body as call/N does. The parameters left over stay
+// unbound. A cut inside Body doesn't affect outside of Lambda.
+func Lambda(vm *VM, args []Term, k Cont, env *Env) *Promise {
+	if len(args) < 2 {
+		return Error(&wrongNumberOfArgumentsError{expected: 2, actual: args})
+	}
+
+	params, body, additional := args[0], args[1], args[2:]
+
+	copied := map[termID]Term{}
+	if c, ok := env.Resolve(params).(Compound); ok && c.Functor() == atomSlash && c.Arity() == 2 {
+		for _, v := range env.freeVariables(c.Arg(0)) {
+			copied[id(v)] = v
+		}
+		params = c.Arg(1)
+	}
+
+	lambda, err := renamedCopy(tuple(params, body), copied, env)
+	if err != nil {
+		return Error(err)
+	}
+	l := lambda.(Compound)
+	params, body = l.Arg(0), l.Arg(1)
+
+	var ps []Term
+	iter := ListIterator{List: params, Env: env}
+	for iter.Next() {
+		ps = append(ps, iter.Current())
+	}
+	if err := iter.Err(); err != nil {
+		return Error(err)
+	}
+
+	n := len(ps)
+	if len(additional) < n {
+		n = len(additional)
+	}
+	return Unify(vm, List(ps[:n]...), List(additional[:n]...), func(env *Env) *Promise {
+		return callN(vm, body, additional[n:], k, env)
+	}, env)
+}
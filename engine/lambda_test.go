@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLambda(t *testing.T) {
+	var vm VM
+	vm.Register2(atomEqual, Unify)
+	vm.Register2(NewAtom("succ"), Succ)
+	vm.RegisterVariadic(NewAtom(">>"), 2, 9, Lambda)
+
+	a, b := NewAtom("a"), NewAtom("b")
+	x, y, z, n := NewVariable(), NewVariable(), NewVariable(), NewVariable()
+
+	t.Run("parameters", func(t *testing.T) {
+		ok, err := Lambda(&vm, []Term{List(x, y), atomEqual.Apply(x, y), a, z}, func(env *Env) *Promise {
+			assert.Equal(t, a, env.Resolve(z))
+			assert.Equal(t, x, env.Resolve(x))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("renamed apart for every call", func(t *testing.T) {
+		w := NewVariable()
+		l := atomBitwiseRightShift.Apply(List(x), atomEqual.Apply(x, y))
+		ok, err := Call1(&vm, l, a, func(env *Env) *Promise {
+			return Call1(&vm, l, b, func(env *Env) *Promise {
+				_, ok := env.Resolve(y).(Variable)
+				assert.True(t, ok)
+				return Bool(true)
+			}, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = Call1(&vm, atomBitwiseRightShift.Apply(List(x), atomEqual.Apply(x, w)), a, func(env *Env) *Promise {
+			_, ok := env.Resolve(w).(Variable)
+			assert.True(t, ok)
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("free variables", func(t *testing.T) {
+		ok, err := Call2(&vm, atomBitwiseRightShift.Apply(atomSlash.Apply(n, List(x, y)), atomComma.Apply(NewAtom("succ").Apply(x, n), atomEqual.Apply(y, n))), Integer(1), z, func(env *Env) *Promise {
+			assert.Equal(t, Integer(2), env.Resolve(n))
+			assert.Equal(t, Integer(2), env.Resolve(z))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("more arguments than parameters", func(t *testing.T) {
+		ok, err := Call2(&vm, atomBitwiseRightShift.Apply(List(x), NewAtom("succ").Apply(x)), Integer(1), z, func(env *Env) *Promise {
+			assert.Equal(t, Integer(2), env.Resolve(z))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("fewer arguments than parameters", func(t *testing.T) {
+		ok, err := Call1(&vm, atomBitwiseRightShift.Apply(List(x, y), atomEqual.Apply(y, b)), a, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("parameters don't match", func(t *testing.T) {
+		ok, err := Call1(&vm, atomBitwiseRightShift.Apply(List(a), atomEqual.Apply(x, x)), b, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("parameters are a partial list", func(t *testing.T) {
+		_, err := Lambda(&vm, []Term{PartialList(NewVariable(), x), atomEqual.Apply(x, x), a}, Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+	})
+
+	t.Run("parameters are not a list", func(t *testing.T) {
+		_, err := Lambda(&vm, []Term{a, atomEqual.Apply(x, x), a}, Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeList, a, nil), err)
+	})
+
+	t.Run("body is a variable", func(t *testing.T) {
+		_, err := Lambda(&vm, []Term{List(), NewVariable()}, Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+	})
+}
@@ -1,9 +1,11 @@
 package engine
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"io"
+	"iter"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -17,12 +19,68 @@ type Lexer struct {
 
 	buf    bytes.Buffer
 	offset int
+	start  position   // the position of the first rune of the last token.
+	span   SourceSpan // the span of the last token.
 }
 
 // Token returns the next token.
 func (l *Lexer) Token() (Token, error) {
 	l.offset = l.buf.Len()
-	return l.layoutTextSequence(false)
+	t, err := l.layoutTextSequence(false)
+	l.span.End = l.input.offset()
+	return t, err
+}
+
+// Tokenize returns the tokens of the Prolog text read from r along with their positions, e.g. for syntax
+// highlighting. The layout text and the comments are skipped. The sequence ends at the end of r or with the first
+// error, which comes with the zero Token.
+func Tokenize(r io.Reader) iter.Seq2[Token, error] {
+	return func(yield func(Token, error) bool) {
+		rr, ok := r.(io.RuneReader)
+		if !ok {
+			rr = bufio.NewReader(r)
+		}
+		l := Lexer{input: newRuneRingBuffer(rr)}
+		for {
+			l.buf.Reset() // The tokens are copied so that the buffer doesn't grow with the text.
+			t, err := l.Token()
+			switch {
+			case err == io.EOF && l.buf.Len() == 0:
+				return
+			case err == io.EOF:
+				yield(Token{}, io.ErrUnexpectedEOF) // The text ends in the middle of a token.
+				return
+			case err != nil:
+				yield(Token{}, err)
+				return
+			}
+			t.val = strings.Clone(t.val)
+			t.pos = l.start
+			if !yield(t, nil) {
+				return
+			}
+		}
+	}
+}
+
+// position is the location of a rune in a text. Both the line and the column count from 1.
+type position struct {
+	line, column int
+}
+
+func (p position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.line, p.column)
+}
+
+// term returns position(Line, Column).
+func (p position) term() Term {
+	return atomPosition.Apply(Integer(p.line), Integer(p.column))
+}
+
+// SourceSpan is the range of a text in bytes, from the offset of its first byte to the offset just after its last
+// byte. The offsets count from the start of the input of the parser.
+type SourceSpan struct {
+	Start, End int
 }
 
 func (l *Lexer) next() (rune, error) {
@@ -60,124 +118,153 @@ func (l *Lexer) chunk() string {
 
 // Token is a smallest meaningful unit of prolog program.
 type Token struct {
-	kind tokenKind
+	kind TokenKind
 	val  string
+	pos  position // the position of the first rune, if the token is obtained by Tokenize.
+}
+
+// Kind returns the kind of the token.
+func (t Token) Kind() TokenKind {
+	return t.kind
+}
+
+// Text returns the text of the token as it's read, e.g. with the quotes of a quoted token.
+func (t Token) Text() string {
+	return t.val
+}
+
+// Line returns the line of the first character of the token, counting from 1, or 0 if the token isn't obtained by
+// Tokenize.
+func (t Token) Line() int {
+	return t.pos.line
+}
+
+// Column returns the column of the first character of the token in its line, counting from 1, or 0 if the token isn't
+// obtained by Tokenize. The columns count characters, not bytes.
+func (t Token) Column() int {
+	return t.pos.column
 }
 
 func (t Token) String() string {
 	return fmt.Sprintf("%s(%s)", t.kind.String(), t.val)
 }
 
-// tokenKind is a type of Token.
-type tokenKind byte
+// TokenKind is a type of Token.
+type TokenKind byte
 
 const (
-	// tokenInvalid represents an invalid token.
-	tokenInvalid tokenKind = iota
+	// TokenInvalid represents an invalid token.
+	TokenInvalid TokenKind = iota
 
-	// tokenLetterDigit represents a letter digit token.
-	tokenLetterDigit
+	// TokenLetterDigit represents a letter digit token.
+	TokenLetterDigit
 
-	// tokenGraphic represents a graphical token.
-	tokenGraphic
+	// TokenGraphic represents a graphical token.
+	TokenGraphic
 
-	// tokenQuoted represents a quoted token.
-	tokenQuoted
+	// TokenQuoted represents a quoted token.
+	TokenQuoted
 
-	// tokenSemicolon represents a semicolon token.
-	tokenSemicolon
+	// TokenSemicolon represents a semicolon token.
+	TokenSemicolon
 
-	// tokenCut represents a cut token.
-	tokenCut
+	// TokenCut represents a cut token.
+	TokenCut
 
-	// tokenVariable represents a variable token.
-	tokenVariable
+	// TokenVariable represents a variable token.
+	TokenVariable
 
-	// tokenInteger represents an integer token.
-	tokenInteger
+	// TokenInteger represents an integer token.
+	TokenInteger
 
-	// tokenFloatNumber represents a floating-point token.
-	tokenFloatNumber
+	// TokenFloatNumber represents a floating-point token.
+	TokenFloatNumber
 
-	// tokenDoubleQuotedList represents a double-quoted string.
-	tokenDoubleQuotedList
+	// TokenRational represents a rational token, e.g. 1r3.
+	TokenRational
 
-	// tokenOpen represents an open parenthesis.
-	tokenOpen
+	// TokenDoubleQuotedList represents a double-quoted string.
+	TokenDoubleQuotedList
 
-	// tokenOpenCT represents an open CT parenthesis.
-	tokenOpenCT
+	// TokenOpen represents an open parenthesis.
+	TokenOpen
 
-	// tokenClose represents a close parenthesis.
-	tokenClose
+	// TokenOpenCT represents an open CT parenthesis.
+	TokenOpenCT
 
-	// tokenOpenList represents an open bracket.
-	tokenOpenList
+	// TokenClose represents a close parenthesis.
+	TokenClose
 
-	// tokenCloseList represents a close bracket.
-	tokenCloseList
+	// TokenOpenList represents an open bracket.
+	TokenOpenList
 
-	// tokenOpenCurly represents an open brace.
-	tokenOpenCurly
+	// TokenCloseList represents a close bracket.
+	TokenCloseList
 
-	// tokenCloseCurly represents a close brace.
-	tokenCloseCurly
+	// TokenOpenCurly represents an open brace.
+	TokenOpenCurly
 
-	// tokenBar represents a bar.
-	tokenBar
+	// TokenCloseCurly represents a close brace.
+	TokenCloseCurly
 
-	// tokenComma represents a comma.
-	tokenComma
+	// TokenBar represents a bar.
+	TokenBar
 
-	// tokenEnd represents a period.
-	tokenEnd
+	// TokenComma represents a comma.
+	TokenComma
+
+	// TokenEnd represents a period.
+	TokenEnd
 )
 
 // GoString returns a string representation of tokenKind.
-func (k tokenKind) GoString() string {
+func (k TokenKind) GoString() string {
 	return k.String()
 }
 
-func (k tokenKind) String() string {
+func (k TokenKind) String() string {
 	return [...]string{
-		tokenInvalid:          "invalid",
-		tokenLetterDigit:      "letter digit",
-		tokenGraphic:          "graphic",
-		tokenQuoted:           "quoted",
-		tokenSemicolon:        "semicolon",
-		tokenCut:              "cut",
-		tokenVariable:         "variable",
-		tokenInteger:          "integer",
-		tokenFloatNumber:      "float number",
-		tokenDoubleQuotedList: "double quoted list",
-		tokenOpen:             "open",
-		tokenOpenCT:           "open ct",
-		tokenClose:            "close",
-		tokenOpenList:         "open list",
-		tokenCloseList:        "close list",
-		tokenOpenCurly:        "open curly",
-		tokenCloseCurly:       "close curly",
-		tokenBar:              "bar",
-		tokenComma:            "comma",
-		tokenEnd:              "end",
+		TokenInvalid:          "invalid",
+		TokenLetterDigit:      "letter digit",
+		TokenGraphic:          "graphic",
+		TokenQuoted:           "quoted",
+		TokenSemicolon:        "semicolon",
+		TokenCut:              "cut",
+		TokenVariable:         "variable",
+		TokenInteger:          "integer",
+		TokenFloatNumber:      "float number",
+		TokenRational:         "rational",
+		TokenDoubleQuotedList: "double quoted list",
+		TokenOpen:             "open",
+		TokenOpenCT:           "open ct",
+		TokenClose:            "close",
+		TokenOpenList:         "open list",
+		TokenCloseList:        "close list",
+		TokenOpenCurly:        "open curly",
+		TokenCloseCurly:       "close curly",
+		TokenBar:              "bar",
+		TokenComma:            "comma",
+		TokenEnd:              "end",
 	}[k]
 }
 
 // Tokens
 
-var soloTokenKinds = [...]tokenKind{
-	';': tokenSemicolon,
-	'!': tokenCut,
-	')': tokenClose,
-	'[': tokenOpenList,
-	']': tokenCloseList,
-	'{': tokenOpenCurly,
-	'}': tokenCloseCurly,
-	'|': tokenBar,
-	',': tokenComma,
+var soloTokenKinds = [...]TokenKind{
+	';': TokenSemicolon,
+	'!': TokenCut,
+	')': TokenClose,
+	'[': TokenOpenList,
+	']': TokenCloseList,
+	'{': TokenOpenCurly,
+	'}': TokenCloseCurly,
+	'|': TokenBar,
+	',': TokenComma,
 }
 
 func (l *Lexer) token(afterLayout bool) (Token, error) {
+	l.start = l.input.position()
+	l.span.Start = l.input.offset()
 	switch r, err := l.next(); {
 	case err != nil:
 		return Token{}, err
@@ -187,7 +274,7 @@ func (l *Lexer) token(afterLayout bool) (Token, error) {
 	case r == '.':
 		l.accept(r)
 		if l.wasEndChar() {
-			return Token{kind: tokenEnd, val: l.chunk()}, nil
+			return Token{kind: TokenEnd, val: l.chunk()}, nil
 		}
 		return l.graphicToken()
 	case isGraphicChar(r), r == '\\':
@@ -207,11 +294,11 @@ func (l *Lexer) token(afterLayout bool) (Token, error) {
 	case r == '(':
 		l.accept(r)
 		if afterLayout {
-			return Token{kind: tokenOpen, val: l.chunk()}, nil
+			return Token{kind: TokenOpen, val: l.chunk()}, nil
 		}
-		return Token{kind: tokenOpenCT, val: l.chunk()}, nil
+		return Token{kind: TokenOpenCT, val: l.chunk()}, nil
 	default:
-		k := tokenInvalid
+		k := TokenInvalid
 		if int(r) < len(soloTokenKinds) {
 			k = soloTokenKinds[r]
 		}
@@ -309,14 +396,14 @@ func (l *Lexer) letterDigitToken() (Token, error) {
 	for {
 		switch r, err := l.next(); {
 		case err == io.EOF:
-			return Token{kind: tokenLetterDigit, val: l.chunk()}, nil
+			return Token{kind: TokenLetterDigit, val: l.chunk()}, nil
 		case err != nil:
 			return Token{}, err
 		case isAlphanumericChar(r):
 			l.accept(r)
 		default:
 			l.backup()
-			return Token{kind: tokenLetterDigit, val: l.chunk()}, nil
+			return Token{kind: TokenLetterDigit, val: l.chunk()}, nil
 		}
 	}
 }
@@ -325,14 +412,14 @@ func (l *Lexer) graphicToken() (Token, error) {
 	for {
 		switch r, err := l.next(); {
 		case err == io.EOF:
-			return Token{kind: tokenGraphic, val: l.chunk()}, nil
+			return Token{kind: TokenGraphic, val: l.chunk()}, nil
 		case err != nil:
 			return Token{}, err
 		case isGraphicChar(r), r == '\\':
 			l.accept(r)
 		default:
 			l.backup()
-			return Token{kind: tokenGraphic, val: l.chunk()}, nil
+			return Token{kind: TokenGraphic, val: l.chunk()}, nil
 		}
 	}
 }
@@ -363,10 +450,10 @@ func (l *Lexer) quotedToken() (Token, error) {
 
 			// Checks if it contains invalid octal or hexadecimal escape sequences.
 			if strings.ContainsRune(unquote(s), utf8.RuneError) {
-				return Token{kind: tokenInvalid, val: s}, nil
+				return Token{kind: TokenInvalid, val: s}, nil
 			}
 
-			return Token{kind: tokenQuoted, val: s}, nil
+			return Token{kind: TokenQuoted, val: s}, nil
 		case r == '\\':
 			l.accept(r)
 			switch r, err := l.rawNext(); {
@@ -384,7 +471,7 @@ func (l *Lexer) quotedToken() (Token, error) {
 			return l.escapeSequence(l.quotedToken)
 		default:
 			l.accept(r)
-			return Token{kind: tokenInvalid, val: l.chunk()}, nil
+			return Token{kind: TokenInvalid, val: l.chunk()}, nil
 		}
 	}
 }
@@ -404,7 +491,7 @@ func (l *Lexer) escapeSequence(cont func() (Token, error)) (Token, error) {
 		return l.hexadecimalEscapeSequence(cont)
 	default:
 		l.accept(r)
-		return Token{kind: tokenInvalid, val: l.chunk()}, nil
+		return Token{kind: TokenInvalid, val: l.chunk()}, nil
 	}
 }
 
@@ -421,7 +508,7 @@ func (l *Lexer) octalEscapeSequence(cont func() (Token, error)) (Token, error) {
 			continue
 		default:
 			l.accept(r)
-			return Token{kind: tokenInvalid, val: l.chunk()}, nil
+			return Token{kind: TokenInvalid, val: l.chunk()}, nil
 		}
 	}
 }
@@ -434,7 +521,7 @@ func (l *Lexer) hexadecimalEscapeSequence(cont func() (Token, error)) (Token, er
 		l.accept(r)
 	default:
 		l.accept(r)
-		return Token{kind: tokenInvalid, val: l.chunk()}, nil
+		return Token{kind: TokenInvalid, val: l.chunk()}, nil
 	}
 
 	for {
@@ -449,7 +536,7 @@ func (l *Lexer) hexadecimalEscapeSequence(cont func() (Token, error)) (Token, er
 			continue
 		default:
 			l.accept(r)
-			return Token{kind: tokenInvalid, val: l.chunk()}, nil
+			return Token{kind: TokenInvalid, val: l.chunk()}, nil
 		}
 	}
 }
@@ -460,14 +547,14 @@ func (l *Lexer) variableToken() (Token, error) {
 	for {
 		switch r, err := l.next(); {
 		case err == io.EOF:
-			return Token{kind: tokenVariable, val: l.chunk()}, nil
+			return Token{kind: TokenVariable, val: l.chunk()}, nil
 		case err != nil:
 			return Token{}, err
 		case isAlphanumericChar(r):
 			l.accept(r)
 		default:
 			l.backup()
-			return Token{kind: tokenVariable, val: l.chunk()}, nil
+			return Token{kind: TokenVariable, val: l.chunk()}, nil
 		}
 	}
 }
@@ -512,7 +599,7 @@ func (l *Lexer) integerTokenCharacterCode(r rune) (Token, error) {
 		case err == io.EOF:
 			l.backup()
 			l.backup()
-			return Token{kind: tokenInteger, val: l.chunk()}, nil // 0
+			return Token{kind: TokenInteger, val: l.chunk()}, nil // 0
 		case err != nil:
 			return Token{}, err
 		case r == '\'': // 0'''
@@ -522,7 +609,7 @@ func (l *Lexer) integerTokenCharacterCode(r rune) (Token, error) {
 			l.backup()
 			l.backup()
 			l.backup()
-			return Token{kind: tokenInteger, val: l.chunk()}, nil // 0
+			return Token{kind: TokenInteger, val: l.chunk()}, nil // 0
 		}
 	case r == '\\':
 		switch r, err := l.next(); {
@@ -534,7 +621,7 @@ func (l *Lexer) integerTokenCharacterCode(r rune) (Token, error) {
 			l.backup()
 			l.backup()
 			l.backup()
-			return Token{kind: tokenInteger, val: l.chunk()}, nil // 0
+			return Token{kind: TokenInteger, val: l.chunk()}, nil // 0
 		default:
 			l.backup()
 			l.backup()
@@ -550,7 +637,7 @@ func (l *Lexer) integerTokenBinary(r rune) (Token, error) {
 	switch r, err := l.next(); {
 	case err == io.EOF:
 		l.backup()
-		return Token{kind: tokenInteger, val: l.chunk()}, nil
+		return Token{kind: TokenInteger, val: l.chunk()}, nil
 	case err != nil:
 		return Token{}, err
 	case isBinaryDigitChar(r):
@@ -558,7 +645,7 @@ func (l *Lexer) integerTokenBinary(r rune) (Token, error) {
 	default:
 		l.backup()
 		l.backup()
-		return Token{kind: tokenInteger, val: l.chunk()}, nil
+		return Token{kind: TokenInteger, val: l.chunk()}, nil
 	}
 	l.accept(r)
 	return l.binaryConstant()
@@ -568,7 +655,7 @@ func (l *Lexer) integerTokenOctal(r rune) (Token, error) {
 	switch r, err := l.next(); {
 	case err == io.EOF:
 		l.backup()
-		return Token{kind: tokenInteger, val: l.chunk()}, nil
+		return Token{kind: TokenInteger, val: l.chunk()}, nil
 	case err != nil:
 		return Token{}, err
 	case isOctalDigitChar(r):
@@ -576,7 +663,7 @@ func (l *Lexer) integerTokenOctal(r rune) (Token, error) {
 	default:
 		l.backup()
 		l.backup()
-		return Token{kind: tokenInteger, val: l.chunk()}, nil
+		return Token{kind: TokenInteger, val: l.chunk()}, nil
 	}
 	l.accept(r)
 	return l.octalConstant()
@@ -586,7 +673,7 @@ func (l *Lexer) integerTokenHexadecimal(r rune) (Token, error) {
 	switch r, err := l.next(); {
 	case err == io.EOF:
 		l.backup()
-		return Token{kind: tokenInteger, val: l.chunk()}, nil
+		return Token{kind: TokenInteger, val: l.chunk()}, nil
 	case err != nil:
 		return Token{}, err
 	case isHexadecimalDigitChar(r):
@@ -594,7 +681,7 @@ func (l *Lexer) integerTokenHexadecimal(r rune) (Token, error) {
 	default:
 		l.backup()
 		l.backup()
-		return Token{kind: tokenInteger, val: l.chunk()}, nil
+		return Token{kind: TokenInteger, val: l.chunk()}, nil
 	}
 	l.accept(r)
 	return l.hexadecimalConstant()
@@ -604,7 +691,7 @@ func (l *Lexer) integerConstant() (Token, error) {
 	for {
 		switch r, err := l.next(); {
 		case err == io.EOF:
-			return Token{kind: tokenInteger, val: l.chunk()}, nil
+			return Token{kind: TokenInteger, val: l.chunk()}, nil
 		case err != nil:
 			return Token{}, err
 		case isDecimalDigitChar(r):
@@ -613,7 +700,7 @@ func (l *Lexer) integerConstant() (Token, error) {
 			switch r, err := l.next(); {
 			case err == io.EOF:
 				l.backup()
-				return Token{kind: tokenInteger, val: l.chunk()}, nil
+				return Token{kind: TokenInteger, val: l.chunk()}, nil
 			case err != nil:
 				return Token{}, err
 			case isDecimalDigitChar(r):
@@ -623,11 +710,27 @@ func (l *Lexer) integerConstant() (Token, error) {
 			default:
 				l.backup()
 				l.backup()
-				return Token{kind: tokenInteger, val: l.chunk()}, nil
+				return Token{kind: TokenInteger, val: l.chunk()}, nil
+			}
+		case r == 'r':
+			switch r, err := l.next(); {
+			case err == io.EOF:
+				l.backup()
+				return Token{kind: TokenInteger, val: l.chunk()}, nil
+			case err != nil:
+				return Token{}, err
+			case isDecimalDigitChar(r) && r != '0':
+				l.accept('r')
+				l.accept(r)
+				return l.denominator()
+			default:
+				l.backup()
+				l.backup()
+				return Token{kind: TokenInteger, val: l.chunk()}, nil
 			}
 		default:
 			l.backup()
-			return Token{kind: tokenInteger, val: l.chunk()}, nil
+			return Token{kind: TokenInteger, val: l.chunk()}, nil
 		}
 	}
 }
@@ -640,18 +743,18 @@ func (l *Lexer) characterCodeConstant() (Token, error) {
 		l.accept(r)
 		r, _ := l.next() // r == '\''
 		l.accept(r)
-		return Token{kind: tokenInteger, val: l.chunk()}, nil
+		return Token{kind: TokenInteger, val: l.chunk()}, nil
 	case r == '\\':
 		l.accept(r)
 		return l.escapeSequence(func() (Token, error) {
-			return Token{kind: tokenInteger, val: l.chunk()}, nil
+			return Token{kind: TokenInteger, val: l.chunk()}, nil
 		})
 	case isGraphicChar(r), isAlphanumericChar(r), isSoloChar(r), r == ' ':
 		l.accept(r)
-		return Token{kind: tokenInteger, val: l.chunk()}, nil
+		return Token{kind: TokenInteger, val: l.chunk()}, nil
 	default:
 		l.accept(r)
-		return Token{kind: tokenInvalid, val: l.chunk()}, nil
+		return Token{kind: TokenInvalid, val: l.chunk()}, nil
 	}
 }
 
@@ -659,14 +762,14 @@ func (l *Lexer) binaryConstant() (Token, error) {
 	for {
 		switch r, err := l.next(); {
 		case err == io.EOF:
-			return Token{kind: tokenInteger, val: l.chunk()}, nil
+			return Token{kind: TokenInteger, val: l.chunk()}, nil
 		case err != nil:
 			return Token{}, err
 		case isBinaryDigitChar(r):
 			l.accept(r)
 		default:
 			l.backup()
-			return Token{kind: tokenInteger, val: l.chunk()}, nil
+			return Token{kind: TokenInteger, val: l.chunk()}, nil
 		}
 	}
 }
@@ -675,14 +778,14 @@ func (l *Lexer) octalConstant() (Token, error) {
 	for {
 		switch r, err := l.next(); {
 		case err == io.EOF:
-			return Token{kind: tokenInteger, val: l.chunk()}, nil
+			return Token{kind: TokenInteger, val: l.chunk()}, nil
 		case err != nil:
 			return Token{}, err
 		case isOctalDigitChar(r):
 			l.accept(r)
 		default:
 			l.backup()
-			return Token{kind: tokenInteger, val: l.chunk()}, nil
+			return Token{kind: TokenInteger, val: l.chunk()}, nil
 		}
 	}
 }
@@ -691,14 +794,30 @@ func (l *Lexer) hexadecimalConstant() (Token, error) {
 	for {
 		switch r, err := l.next(); {
 		case err == io.EOF:
-			return Token{kind: tokenInteger, val: l.chunk()}, nil
+			return Token{kind: TokenInteger, val: l.chunk()}, nil
 		case err != nil:
 			return Token{}, err
 		case isHexadecimalDigitChar(r):
 			l.accept(r)
 		default:
 			l.backup()
-			return Token{kind: tokenInteger, val: l.chunk()}, nil
+			return Token{kind: TokenInteger, val: l.chunk()}, nil
+		}
+	}
+}
+
+func (l *Lexer) denominator() (Token, error) {
+	for {
+		switch r, err := l.next(); {
+		case err == io.EOF:
+			return Token{kind: TokenRational, val: l.chunk()}, nil
+		case err != nil:
+			return Token{}, err
+		case isDecimalDigitChar(r):
+			l.accept(r)
+		default:
+			l.backup()
+			return Token{kind: TokenRational, val: l.chunk()}, nil
 		}
 	}
 }
@@ -709,7 +828,7 @@ func (l *Lexer) fraction() (Token, error) {
 	for {
 		switch r, err := l.next(); {
 		case err == io.EOF:
-			return Token{kind: tokenFloatNumber, val: l.chunk()}, nil
+			return Token{kind: TokenFloatNumber, val: l.chunk()}, nil
 		case err != nil:
 			return Token{}, err
 		case isDecimalDigitChar(r):
@@ -719,7 +838,7 @@ func (l *Lexer) fraction() (Token, error) {
 			switch r, err := l.next(); {
 			case err == io.EOF:
 				l.backup() // for 'e' or 'E'
-				return Token{kind: tokenFloatNumber, val: l.chunk()}, nil
+				return Token{kind: TokenFloatNumber, val: l.chunk()}, nil
 			case err != nil:
 				return Token{}, err
 			case isSignChar(r):
@@ -734,7 +853,7 @@ func (l *Lexer) fraction() (Token, error) {
 					l.backup()
 				}
 				l.backup() // for 'e' or 'E'
-				return Token{kind: tokenFloatNumber, val: l.chunk()}, nil
+				return Token{kind: TokenFloatNumber, val: l.chunk()}, nil
 			case err != nil:
 				return Token{}, err
 			case isDecimalDigitChar(r):
@@ -745,7 +864,7 @@ func (l *Lexer) fraction() (Token, error) {
 					l.backup()
 				}
 				l.backup() // for 'e' or 'E'
-				return Token{kind: tokenFloatNumber, val: l.chunk()}, nil
+				return Token{kind: TokenFloatNumber, val: l.chunk()}, nil
 			}
 
 			l.accept(r) // 'e' or 'E'
@@ -755,7 +874,7 @@ func (l *Lexer) fraction() (Token, error) {
 			return l.exponent()
 		default:
 			l.backup()
-			return Token{kind: tokenFloatNumber, val: l.chunk()}, nil
+			return Token{kind: TokenFloatNumber, val: l.chunk()}, nil
 		}
 	}
 }
@@ -764,14 +883,14 @@ func (l *Lexer) exponent() (Token, error) {
 	for {
 		switch r, err := l.next(); {
 		case err == io.EOF:
-			return Token{kind: tokenFloatNumber, val: l.chunk()}, nil
+			return Token{kind: TokenFloatNumber, val: l.chunk()}, nil
 		case err != nil:
 			return Token{}, err
 		case isDecimalDigitChar(r):
 			l.accept(r)
 		default:
 			l.backup()
-			return Token{kind: tokenFloatNumber, val: l.chunk()}, nil
+			return Token{kind: TokenFloatNumber, val: l.chunk()}, nil
 		}
 	}
 }
@@ -787,14 +906,14 @@ func (l *Lexer) doubleQuotedListToken() (Token, error) {
 			l.accept(r)
 			switch r, err := l.next(); {
 			case err == io.EOF:
-				return Token{kind: tokenDoubleQuotedList, val: l.chunk()}, nil
+				return Token{kind: TokenDoubleQuotedList, val: l.chunk()}, nil
 			case err != nil:
 				return Token{}, err
 			case r == '"':
 				l.accept(r)
 			default:
 				l.backup()
-				return Token{kind: tokenDoubleQuotedList, val: l.chunk()}, nil
+				return Token{kind: TokenDoubleQuotedList, val: l.chunk()}, nil
 			}
 		case r == '\\':
 			l.accept(r)
@@ -895,11 +1014,15 @@ func isSignChar(r rune) bool {
 type runeRingBuffer struct {
 	base       io.RuneReader
 	buf        [4]rune
+	pos        [4]position // the positions of the runes in buf.
+	offs       [4]int      // the byte offsets of the runes in buf.
 	start, end int
+	next       position // the position of the next rune read from base.
+	nextOffset int      // the byte offset of the next rune read from base.
 }
 
 func newRuneRingBuffer(r io.RuneReader) runeRingBuffer {
-	return runeRingBuffer{base: r}
+	return runeRingBuffer{base: r, next: position{line: 1, column: 1}}
 }
 
 func (b *runeRingBuffer) ReadRune() (rune, int, error) {
@@ -908,18 +1031,43 @@ func (b *runeRingBuffer) ReadRune() (rune, int, error) {
 		if err != nil {
 			return r, n, err
 		}
-		b.put(r)
+		b.put(r, n)
 	}
 	return b.get(), 0, nil
 }
 
+// position returns the position of the rune ReadRune returns next.
+func (b *runeRingBuffer) position() position {
+	if b.empty() {
+		return b.next
+	}
+	return b.pos[b.start]
+}
+
+// offset returns the byte offset of the rune ReadRune returns next.
+func (b *runeRingBuffer) offset() int {
+	if b.empty() {
+		return b.nextOffset
+	}
+	return b.offs[b.start]
+}
+
 func (b *runeRingBuffer) UnreadRune() error {
 	b.backup()
 	return nil
 }
 
-func (b *runeRingBuffer) put(r rune) {
+func (b *runeRingBuffer) put(r rune, size int) {
 	b.buf[b.end] = r
+	b.pos[b.end] = b.next
+	b.offs[b.end] = b.nextOffset
+	b.nextOffset += size
+	if r == '\n' {
+		b.next.line++
+		b.next.column = 1
+	} else {
+		b.next.column++
+	}
 	b.end++
 	b.end %= len(b.buf)
 }
@@ -19,111 +19,111 @@ func TestLexer_Token(t *testing.T) {
 		{input: ``, err: io.EOF},
 		{input: `🙈`, err: errMonkey}, // In this test, we use a see-no-evil monkey emoji to denote a non-EOF error.
 
-		{input: ".", token: Token{kind: tokenEnd, val: "."}},
-		{input: ";", token: Token{kind: tokenSemicolon, val: ";"}},
-		{input: "!", token: Token{kind: tokenCut, val: "!"}},
-		{input: "(", token: Token{kind: tokenOpenCT, val: "("}},
-		{input: " (", token: Token{kind: tokenOpen, val: "("}},
-		{input: ")", token: Token{kind: tokenClose, val: ")"}},
-		{input: "[", token: Token{kind: tokenOpenList, val: "["}},
-		{input: "]", token: Token{kind: tokenCloseList, val: "]"}},
-		{input: "{", token: Token{kind: tokenOpenCurly, val: "{"}},
-		{input: "}", token: Token{kind: tokenCloseCurly, val: "}"}},
-		{input: "|", token: Token{kind: tokenBar, val: "|"}},
-		{input: ",", token: Token{kind: tokenComma, val: ","}},
-
-		{input: "% comment\nfoo", token: Token{kind: tokenLetterDigit, val: "foo"}},
+		{input: ".", token: Token{kind: TokenEnd, val: "."}},
+		{input: ";", token: Token{kind: TokenSemicolon, val: ";"}},
+		{input: "!", token: Token{kind: TokenCut, val: "!"}},
+		{input: "(", token: Token{kind: TokenOpenCT, val: "("}},
+		{input: " (", token: Token{kind: TokenOpen, val: "("}},
+		{input: ")", token: Token{kind: TokenClose, val: ")"}},
+		{input: "[", token: Token{kind: TokenOpenList, val: "["}},
+		{input: "]", token: Token{kind: TokenCloseList, val: "]"}},
+		{input: "{", token: Token{kind: TokenOpenCurly, val: "{"}},
+		{input: "}", token: Token{kind: TokenCloseCurly, val: "}"}},
+		{input: "|", token: Token{kind: TokenBar, val: "|"}},
+		{input: ",", token: Token{kind: TokenComma, val: ","}},
+
+		{input: "% comment\nfoo", token: Token{kind: TokenLetterDigit, val: "foo"}},
 		{input: "% comment", err: io.EOF},
-		{input: "/* comment \n * also comment \n */foo", token: Token{kind: tokenLetterDigit, val: "foo"}},
+		{input: "/* comment \n * also comment \n */foo", token: Token{kind: TokenLetterDigit, val: "foo"}},
 		{input: "/* comment ", err: io.EOF},
-		{input: `/`, token: Token{kind: tokenGraphic, val: `/`}},
-		{input: `/ *`, token: Token{kind: tokenGraphic, val: `/`}},
+		{input: `/`, token: Token{kind: TokenGraphic, val: `/`}},
+		{input: `/ *`, token: Token{kind: TokenGraphic, val: `/`}},
 		{input: "/* comment *", err: io.EOF},
 		{input: `/🙈`, err: errMonkey},
-		{input: `/* **/foo`, token: Token{kind: tokenLetterDigit, val: "foo"}}, // https://github.com/ichiban/prolog/issues/326
-
-		{input: `改善`, token: Token{kind: tokenLetterDigit, val: `改善`}},
-		{input: `プロログ`, token: Token{kind: tokenLetterDigit, val: `プロログ`}},
-		{input: `ぷろろぐ`, token: Token{kind: tokenLetterDigit, val: `ぷろろぐ`}},
-		{input: `프롤로그`, token: Token{kind: tokenLetterDigit, val: `프롤로그`}},
-		{input: `برولوغ`, token: Token{kind: tokenLetterDigit, val: `برولوغ`}},
-		{input: `פרולוג`, token: Token{kind: tokenLetterDigit, val: `פרולוג`}},
-		{input: `ゴー`, token: Token{kind: tokenLetterDigit, val: `ゴー`}},
-		{input: `prolog.`, token: Token{kind: tokenLetterDigit, val: `prolog`}},
+		{input: `/* **/foo`, token: Token{kind: TokenLetterDigit, val: "foo"}}, // https://github.com/ichiban/prolog/issues/326
+
+		{input: `改善`, token: Token{kind: TokenLetterDigit, val: `改善`}},
+		{input: `プロログ`, token: Token{kind: TokenLetterDigit, val: `プロログ`}},
+		{input: `ぷろろぐ`, token: Token{kind: TokenLetterDigit, val: `ぷろろぐ`}},
+		{input: `프롤로그`, token: Token{kind: TokenLetterDigit, val: `프롤로그`}},
+		{input: `برولوغ`, token: Token{kind: TokenLetterDigit, val: `برولوغ`}},
+		{input: `פרולוג`, token: Token{kind: TokenLetterDigit, val: `פרולוג`}},
+		{input: `ゴー`, token: Token{kind: TokenLetterDigit, val: `ゴー`}},
+		{input: `prolog.`, token: Token{kind: TokenLetterDigit, val: `prolog`}},
 		{input: `prolog🙈`, err: errMonkey},
 
-		{input: `..`, token: Token{kind: tokenGraphic, val: `..`}},
-		{input: `#`, token: Token{kind: tokenGraphic, val: `#`}},
-		{input: `\`, token: Token{kind: tokenGraphic, val: `\`}},
-		{input: `∀`, token: Token{kind: tokenGraphic, val: `∀`}},
-		{input: `⨀`, token: Token{kind: tokenGraphic, val: `⨀`}},
+		{input: `..`, token: Token{kind: TokenGraphic, val: `..`}},
+		{input: `#`, token: Token{kind: TokenGraphic, val: `#`}},
+		{input: `\`, token: Token{kind: TokenGraphic, val: `\`}},
+		{input: `∀`, token: Token{kind: TokenGraphic, val: `∀`}},
+		{input: `⨀`, token: Token{kind: TokenGraphic, val: `⨀`}},
 		{input: `+🙈`, err: errMonkey},
 
-		{input: `'abc'`, token: Token{kind: tokenQuoted, val: "'abc'"}},
-		{input: `'abc'.`, token: Token{kind: tokenQuoted, val: "'abc'"}},
-		{input: `'don''t panic'`, token: Token{kind: tokenQuoted, val: "'don''t panic'"}},
+		{input: `'abc'`, token: Token{kind: TokenQuoted, val: "'abc'"}},
+		{input: `'abc'.`, token: Token{kind: TokenQuoted, val: "'abc'"}},
+		{input: `'don''t panic'`, token: Token{kind: TokenQuoted, val: "'don''t panic'"}},
 		{input: `'this is \
-a quoted ident'`, token: Token{kind: tokenQuoted, val: "'this is \\\na quoted ident'"}},
-		{input: `'\a'`, token: Token{kind: tokenQuoted, val: "'\\a'"}},
-		{input: `'\b'`, token: Token{kind: tokenQuoted, val: "'\\b'"}},
-		{input: `'\f'`, token: Token{kind: tokenQuoted, val: "'\\f'"}},
-		{input: `'\n'`, token: Token{kind: tokenQuoted, val: "'\\n'"}},
-		{input: `'\r'`, token: Token{kind: tokenQuoted, val: "'\\r'"}},
-		{input: `'\t'`, token: Token{kind: tokenQuoted, val: "'\\t'"}},
-		{input: `'\v'`, token: Token{kind: tokenQuoted, val: "'\\v'"}},
-		{input: `'\xa3\'`, token: Token{kind: tokenQuoted, val: "'\\xa3\\'"}},
-		{input: `'\xa333333333\'`, token: Token{kind: tokenInvalid, val: `'\xa333333333\'`}},
-		{input: `'\xa333333333\'.`, token: Token{kind: tokenInvalid, val: `'\xa333333333\'`}},
-		{input: `'\43333333\'`, token: Token{kind: tokenInvalid, val: `'\43333333\'`}},
-		{input: `'\\'`, token: Token{kind: tokenQuoted, val: `'\\'`}},
-		{input: `'\''`, token: Token{kind: tokenQuoted, val: `'\''`}},
-		{input: `'\"'`, token: Token{kind: tokenQuoted, val: `'\"'`}},
-		{input: "'`'", token: Token{kind: tokenQuoted, val: "'`'"}},
-		{input: "'\\`'", token: Token{kind: tokenQuoted, val: "'\\`'"}},
+a quoted ident'`, token: Token{kind: TokenQuoted, val: "'this is \\\na quoted ident'"}},
+		{input: `'\a'`, token: Token{kind: TokenQuoted, val: "'\\a'"}},
+		{input: `'\b'`, token: Token{kind: TokenQuoted, val: "'\\b'"}},
+		{input: `'\f'`, token: Token{kind: TokenQuoted, val: "'\\f'"}},
+		{input: `'\n'`, token: Token{kind: TokenQuoted, val: "'\\n'"}},
+		{input: `'\r'`, token: Token{kind: TokenQuoted, val: "'\\r'"}},
+		{input: `'\t'`, token: Token{kind: TokenQuoted, val: "'\\t'"}},
+		{input: `'\v'`, token: Token{kind: TokenQuoted, val: "'\\v'"}},
+		{input: `'\xa3\'`, token: Token{kind: TokenQuoted, val: "'\\xa3\\'"}},
+		{input: `'\xa333333333\'`, token: Token{kind: TokenInvalid, val: `'\xa333333333\'`}},
+		{input: `'\xa333333333\'.`, token: Token{kind: TokenInvalid, val: `'\xa333333333\'`}},
+		{input: `'\43333333\'`, token: Token{kind: TokenInvalid, val: `'\43333333\'`}},
+		{input: `'\\'`, token: Token{kind: TokenQuoted, val: `'\\'`}},
+		{input: `'\''`, token: Token{kind: TokenQuoted, val: `'\''`}},
+		{input: `'\"'`, token: Token{kind: TokenQuoted, val: `'\"'`}},
+		{input: "'`'", token: Token{kind: TokenQuoted, val: "'`'"}},
+		{input: "'\\`'", token: Token{kind: TokenQuoted, val: "'\\`'"}},
 		{input: `'`, err: io.EOF},
 		{input: `'\`, err: io.EOF},
 		{input: `'\x`, err: io.EOF},
-		{input: `'\xG`, token: Token{kind: tokenInvalid, val: `'\xG`}},
+		{input: `'\xG`, token: Token{kind: TokenInvalid, val: `'\xG`}},
 		{input: `'\0`, err: io.EOF},
-		{input: `'\08`, token: Token{kind: tokenInvalid, val: `'\08`}},
-		{input: "'\x01'", token: Token{kind: tokenInvalid, val: "'\x01"}},
+		{input: `'\08`, token: Token{kind: TokenInvalid, val: `'\08`}},
+		{input: "'\x01'", token: Token{kind: TokenInvalid, val: "'\x01"}},
 		{input: `'abc'🙈`, err: errMonkey},
 		{input: `'this is \🙈'`, err: errMonkey},
 
-		{input: `X`, token: Token{kind: tokenVariable, val: `X`}},
-		{input: `X.`, token: Token{kind: tokenVariable, val: `X`}},
-		{input: `_123`, token: Token{kind: tokenVariable, val: `_123`}},
+		{input: `X`, token: Token{kind: TokenVariable, val: `X`}},
+		{input: `X.`, token: Token{kind: TokenVariable, val: `X`}},
+		{input: `_123`, token: Token{kind: TokenVariable, val: `_123`}},
 		{input: `X🙈`, err: errMonkey},
 
-		{input: `012345`, token: Token{kind: tokenInteger, val: "012345"}},
-		{input: `012345,`, token: Token{kind: tokenInteger, val: "012345"}},
-		{input: `012345..`, token: Token{kind: tokenInteger, val: "012345"}},
-		{input: `0b10110101`, token: Token{kind: tokenInteger, val: "0b10110101"}},
-		{input: `0b10110101.`, token: Token{kind: tokenInteger, val: "0b10110101"}},
-		{input: `0b`, token: Token{kind: tokenInteger, val: "0"}},
-		{input: `0b.`, token: Token{kind: tokenInteger, val: "0"}},
-		{input: `0o567`, token: Token{kind: tokenInteger, val: "0o567"}},
-		{input: `0o567.`, token: Token{kind: tokenInteger, val: "0o567"}},
-		{input: `0o`, token: Token{kind: tokenInteger, val: "0"}},
-		{input: `0o.`, token: Token{kind: tokenInteger, val: "0"}},
-		{input: `0x89ABC`, token: Token{kind: tokenInteger, val: "0x89ABC"}},
-		{input: `0x89ABC.`, token: Token{kind: tokenInteger, val: "0x89ABC"}},
-		{input: `0x`, token: Token{kind: tokenInteger, val: "0"}},
-		{input: `0x.`, token: Token{kind: tokenInteger, val: "0"}},
-		{input: `0'a`, token: Token{kind: tokenInteger, val: "0'a"}},
-		{input: `0'''`, token: Token{kind: tokenInteger, val: "0'''"}},
-		{input: `0''`, token: Token{kind: tokenInteger, val: "0"}},
-		{input: `0''.`, token: Token{kind: tokenInteger, val: "0"}},
-		{input: `0'\n`, token: Token{kind: tokenInteger, val: `0'\n`}},
+		{input: `012345`, token: Token{kind: TokenInteger, val: "012345"}},
+		{input: `012345,`, token: Token{kind: TokenInteger, val: "012345"}},
+		{input: `012345..`, token: Token{kind: TokenInteger, val: "012345"}},
+		{input: `0b10110101`, token: Token{kind: TokenInteger, val: "0b10110101"}},
+		{input: `0b10110101.`, token: Token{kind: TokenInteger, val: "0b10110101"}},
+		{input: `0b`, token: Token{kind: TokenInteger, val: "0"}},
+		{input: `0b.`, token: Token{kind: TokenInteger, val: "0"}},
+		{input: `0o567`, token: Token{kind: TokenInteger, val: "0o567"}},
+		{input: `0o567.`, token: Token{kind: TokenInteger, val: "0o567"}},
+		{input: `0o`, token: Token{kind: TokenInteger, val: "0"}},
+		{input: `0o.`, token: Token{kind: TokenInteger, val: "0"}},
+		{input: `0x89ABC`, token: Token{kind: TokenInteger, val: "0x89ABC"}},
+		{input: `0x89ABC.`, token: Token{kind: TokenInteger, val: "0x89ABC"}},
+		{input: `0x`, token: Token{kind: TokenInteger, val: "0"}},
+		{input: `0x.`, token: Token{kind: TokenInteger, val: "0"}},
+		{input: `0'a`, token: Token{kind: TokenInteger, val: "0'a"}},
+		{input: `0'''`, token: Token{kind: TokenInteger, val: "0'''"}},
+		{input: `0''`, token: Token{kind: TokenInteger, val: "0"}},
+		{input: `0''.`, token: Token{kind: TokenInteger, val: "0"}},
+		{input: `0'\n`, token: Token{kind: TokenInteger, val: `0'\n`}},
 		{input: `0'\
-`, token: Token{kind: tokenInteger, val: `0`}},
+`, token: Token{kind: TokenInteger, val: `0`}},
 		{input: `0'\`, err: io.EOF},
-		{input: `0'\q`, token: Token{kind: tokenInvalid, val: `0'\q`}},
-		{input: `0'\😀`, token: Token{kind: tokenInvalid, val: `0'\😀`}},
+		{input: `0'\q`, token: Token{kind: TokenInvalid, val: `0'\q`}},
+		{input: `0'\😀`, token: Token{kind: TokenInvalid, val: `0'\😀`}},
 		{input: `0'`, err: io.EOF},
-		{input: "0'\x01", token: Token{kind: tokenInvalid, val: "0'\x01"}},
-		{input: `0`, token: Token{kind: tokenInteger, val: "0"}},
-		{input: `0.`, token: Token{kind: tokenInteger, val: "0"}},
+		{input: "0'\x01", token: Token{kind: TokenInvalid, val: "0'\x01"}},
+		{input: `0`, token: Token{kind: TokenInteger, val: "0"}},
+		{input: `0.`, token: Token{kind: TokenInteger, val: "0"}},
 		{input: `0🙈`, err: errMonkey},
 		{input: `0'🙈`, err: errMonkey},
 		{input: `0''🙈`, err: errMonkey},
@@ -137,21 +137,28 @@ a quoted ident'`, token: Token{kind: tokenQuoted, val: "'this is \\\na quoted id
 		{input: `0o567🙈`, err: errMonkey},
 		{input: `0x89ABC🙈`, err: errMonkey},
 
-		{input: `2.34`, token: Token{kind: tokenFloatNumber, val: "2.34"}},
-		{input: `2.34.`, token: Token{kind: tokenFloatNumber, val: "2.34"}},
-		{input: `2.34E5`, token: Token{kind: tokenFloatNumber, val: "2.34E5"}},
-		{input: `2.34E5.`, token: Token{kind: tokenFloatNumber, val: "2.34E5"}},
-		{input: `2.34E`, token: Token{kind: tokenFloatNumber, val: "2.34"}},
-		{input: `2.34E.`, token: Token{kind: tokenFloatNumber, val: "2.34"}},
-		{input: `2.34E+5`, token: Token{kind: tokenFloatNumber, val: "2.34E+5"}},
-		{input: `2.34E+5.`, token: Token{kind: tokenFloatNumber, val: "2.34E+5"}},
-		{input: `2.34E+`, token: Token{kind: tokenFloatNumber, val: "2.34"}},
-		{input: `2.34E+.`, token: Token{kind: tokenFloatNumber, val: "2.34"}},
-		{input: `2.34E-10`, token: Token{kind: tokenFloatNumber, val: "2.34E-10"}},
-		{input: `2.34E-10.`, token: Token{kind: tokenFloatNumber, val: "2.34E-10"}},
-		{input: `2.34E-`, token: Token{kind: tokenFloatNumber, val: "2.34"}},
-		{input: `2.34E-.`, token: Token{kind: tokenFloatNumber, val: "2.34"}},
-		{input: `0.333`, token: Token{kind: tokenFloatNumber, val: "0.333"}},
+		{input: `1r3`, token: Token{kind: TokenRational, val: "1r3"}},
+		{input: `12r34.`, token: Token{kind: TokenRational, val: "12r34"}},
+		{input: `1r0`, token: Token{kind: TokenInteger, val: "1"}},
+		{input: `1r`, token: Token{kind: TokenInteger, val: "1"}},
+		{input: `1ra`, token: Token{kind: TokenInteger, val: "1"}},
+		{input: `1r3🙈`, err: errMonkey},
+
+		{input: `2.34`, token: Token{kind: TokenFloatNumber, val: "2.34"}},
+		{input: `2.34.`, token: Token{kind: TokenFloatNumber, val: "2.34"}},
+		{input: `2.34E5`, token: Token{kind: TokenFloatNumber, val: "2.34E5"}},
+		{input: `2.34E5.`, token: Token{kind: TokenFloatNumber, val: "2.34E5"}},
+		{input: `2.34E`, token: Token{kind: TokenFloatNumber, val: "2.34"}},
+		{input: `2.34E.`, token: Token{kind: TokenFloatNumber, val: "2.34"}},
+		{input: `2.34E+5`, token: Token{kind: TokenFloatNumber, val: "2.34E+5"}},
+		{input: `2.34E+5.`, token: Token{kind: TokenFloatNumber, val: "2.34E+5"}},
+		{input: `2.34E+`, token: Token{kind: TokenFloatNumber, val: "2.34"}},
+		{input: `2.34E+.`, token: Token{kind: TokenFloatNumber, val: "2.34"}},
+		{input: `2.34E-10`, token: Token{kind: TokenFloatNumber, val: "2.34E-10"}},
+		{input: `2.34E-10.`, token: Token{kind: TokenFloatNumber, val: "2.34E-10"}},
+		{input: `2.34E-`, token: Token{kind: TokenFloatNumber, val: "2.34"}},
+		{input: `2.34E-.`, token: Token{kind: TokenFloatNumber, val: "2.34"}},
+		{input: `0.333`, token: Token{kind: TokenFloatNumber, val: "0.333"}},
 		{input: `2.34🙈`, err: errMonkey},
 		{input: `2.34E🙈`, err: errMonkey},
 		{input: `2.34E+🙈`, err: errMonkey},
@@ -160,37 +167,37 @@ a quoted ident'`, token: Token{kind: tokenQuoted, val: "'this is \\\na quoted id
 		{input: `2.34E+5🙈`, err: errMonkey},
 		{input: `2.34E-10🙈`, err: errMonkey},
 
-		{input: `"abc"`, token: Token{kind: tokenDoubleQuotedList, val: `"abc"`}},
-		{input: `"abc".`, token: Token{kind: tokenDoubleQuotedList, val: `"abc"`}},
-		{input: `"don""t panic"`, token: Token{kind: tokenDoubleQuotedList, val: `"don""t panic"`}},
+		{input: `"abc"`, token: Token{kind: TokenDoubleQuotedList, val: `"abc"`}},
+		{input: `"abc".`, token: Token{kind: TokenDoubleQuotedList, val: `"abc"`}},
+		{input: `"don""t panic"`, token: Token{kind: TokenDoubleQuotedList, val: `"don""t panic"`}},
 		{input: `"this is \
-a quoted ident"`, token: Token{kind: tokenDoubleQuotedList, val: `"this is \
+a quoted ident"`, token: Token{kind: TokenDoubleQuotedList, val: `"this is \
 a quoted ident"`}},
-		{input: `"\a"`, token: Token{kind: tokenDoubleQuotedList, val: `"\a"`}},
-		{input: `"\b"`, token: Token{kind: tokenDoubleQuotedList, val: `"\b"`}},
-		{input: `"\f"`, token: Token{kind: tokenDoubleQuotedList, val: `"\f"`}},
-		{input: `"\n"`, token: Token{kind: tokenDoubleQuotedList, val: `"\n"`}},
-		{input: `"\r"`, token: Token{kind: tokenDoubleQuotedList, val: `"\r"`}},
-		{input: `"\t"`, token: Token{kind: tokenDoubleQuotedList, val: `"\t"`}},
-		{input: `"\v"`, token: Token{kind: tokenDoubleQuotedList, val: `"\v"`}},
-		{input: `"\xa3\"`, token: Token{kind: tokenDoubleQuotedList, val: `"\xa3\"`}},
+		{input: `"\a"`, token: Token{kind: TokenDoubleQuotedList, val: `"\a"`}},
+		{input: `"\b"`, token: Token{kind: TokenDoubleQuotedList, val: `"\b"`}},
+		{input: `"\f"`, token: Token{kind: TokenDoubleQuotedList, val: `"\f"`}},
+		{input: `"\n"`, token: Token{kind: TokenDoubleQuotedList, val: `"\n"`}},
+		{input: `"\r"`, token: Token{kind: TokenDoubleQuotedList, val: `"\r"`}},
+		{input: `"\t"`, token: Token{kind: TokenDoubleQuotedList, val: `"\t"`}},
+		{input: `"\v"`, token: Token{kind: TokenDoubleQuotedList, val: `"\v"`}},
+		{input: `"\xa3\"`, token: Token{kind: TokenDoubleQuotedList, val: `"\xa3\"`}},
 		{input: `"\xa3`, err: io.EOF},
-		{input: `"\xa3g`, token: Token{kind: tokenInvalid, val: `"\xa3g`}},
-		{input: `"\43\"`, token: Token{kind: tokenDoubleQuotedList, val: `"\43\"`}},
+		{input: `"\xa3g`, token: Token{kind: TokenInvalid, val: `"\xa3g`}},
+		{input: `"\43\"`, token: Token{kind: TokenDoubleQuotedList, val: `"\43\"`}},
 		{input: `"\43`, err: io.EOF},
-		{input: `"\438`, token: Token{kind: tokenInvalid, val: `"\438`}},
-		{input: `"\\"`, token: Token{kind: tokenDoubleQuotedList, val: `"\\"`}},
-		{input: `"\'"`, token: Token{kind: tokenDoubleQuotedList, val: `"\'"`}},
-		{input: `"\""`, token: Token{kind: tokenDoubleQuotedList, val: `"\""`}},
-		{input: "\"\\`\"", token: Token{kind: tokenDoubleQuotedList, val: "\"\\`\""}},
+		{input: `"\438`, token: Token{kind: TokenInvalid, val: `"\438`}},
+		{input: `"\\"`, token: Token{kind: TokenDoubleQuotedList, val: `"\\"`}},
+		{input: `"\'"`, token: Token{kind: TokenDoubleQuotedList, val: `"\'"`}},
+		{input: `"\""`, token: Token{kind: TokenDoubleQuotedList, val: `"\""`}},
+		{input: "\"\\`\"", token: Token{kind: TokenDoubleQuotedList, val: "\"\\`\""}},
 		{input: `"`, err: io.EOF},
 		{input: `"\`, err: io.EOF},
 		{input: `"abc"🙈`, err: errMonkey},
 
-		{input: "\x01", token: Token{kind: tokenInvalid, val: "\x01"}},
+		{input: "\x01", token: Token{kind: TokenInvalid, val: "\x01"}},
 
-		{input: `abc`, charConversions: map[rune]rune{'b': 'a'}, token: Token{kind: tokenLetterDigit, val: "aac"}},
-		{input: `'abc'`, charConversions: map[rune]rune{'b': 'a'}, token: Token{kind: tokenQuoted, val: "'abc'"}},
+		{input: `abc`, charConversions: map[rune]rune{'b': 'a'}, token: Token{kind: TokenLetterDigit, val: "aac"}},
+		{input: `'abc'`, charConversions: map[rune]rune{'b': 'a'}, token: Token{kind: TokenQuoted, val: "'abc'"}},
 	}
 
 	for _, tt := range tests {
@@ -219,5 +226,70 @@ func (n noMonkeyReader) ReadRune() (rune, int, error) {
 }
 
 func TestTokenKind_GoString(t *testing.T) {
-	assert.Equal(t, "invalid", tokenInvalid.GoString())
+	assert.Equal(t, "invalid", TokenInvalid.GoString())
+}
+
+func TestTokenize(t *testing.T) {
+	type token struct {
+		kind         TokenKind
+		text         string
+		line, column int
+	}
+	tokenize := func(s string) ([]token, error) {
+		var ret []token
+		for tok, err := range Tokenize(strings.NewReader(s)) {
+			if err != nil {
+				return ret, err
+			}
+			ret = append(ret, token{kind: tok.Kind(), text: tok.Text(), line: tok.Line(), column: tok.Column()})
+		}
+		return ret, nil
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		tokens, err := tokenize(`% comment
+foo(X, 'é b') :-
+	X = "abc". /* comment */ bar.`)
+		assert.NoError(t, err)
+		assert.Equal(t, []token{
+			{kind: TokenLetterDigit, text: "foo", line: 2, column: 1},
+			{kind: TokenOpenCT, text: "(", line: 2, column: 4},
+			{kind: TokenVariable, text: "X", line: 2, column: 5},
+			{kind: TokenComma, text: ",", line: 2, column: 6},
+			{kind: TokenQuoted, text: "'é b'", line: 2, column: 8},
+			{kind: TokenClose, text: ")", line: 2, column: 13},
+			{kind: TokenGraphic, text: ":-", line: 2, column: 15},
+			{kind: TokenVariable, text: "X", line: 3, column: 2},
+			{kind: TokenGraphic, text: "=", line: 3, column: 4},
+			{kind: TokenDoubleQuotedList, text: `"abc"`, line: 3, column: 6},
+			{kind: TokenEnd, text: ".", line: 3, column: 11},
+			{kind: TokenLetterDigit, text: "bar", line: 3, column: 27},
+			{kind: TokenEnd, text: ".", line: 3, column: 30},
+		}, tokens)
+	})
+
+	t.Run("trailing comments", func(t *testing.T) {
+		for _, s := range []string{"foo. % comment", "foo. /* comment */ ", "foo.\n\n"} {
+			tokens, err := tokenize(s)
+			assert.NoError(t, err)
+			assert.Len(t, tokens, 2)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		tokens, err := tokenize(`foo. 'bar`)
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+		assert.Len(t, tokens, 2)
+	})
+
+	t.Run("break", func(t *testing.T) {
+		var n int
+		for range Tokenize(strings.NewReader(`a b c`)) {
+			n++
+			if n == 2 {
+				break
+			}
+		}
+		assert.Equal(t, 2, n)
+	})
 }
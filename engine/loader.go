@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupportedSource is an error that a Loader doesn't recognize the form of a source, e.g. a compound for a Loader
+// of file names.
+var ErrUnsupportedSource = errors.New("unsupported source")
+
+// Loader resolves the sources of Prolog texts, e.g. the argument of ensure_loaded/1, to the names and the contents of
+// the texts. The name identifies the text, e.g. for source_file/1 and the reloads, so a Loader must resolve the same
+// source to the same name.
+//
+// Load returns an error wrapping ErrUnsupportedSource if it doesn't recognize the form of source, fs.ErrNotExist if
+// there's no such text, and fs.ErrPermission if the text can't be read.
+type Loader interface {
+	Load(ctx context.Context, source Term, env *Env) (name string, content []byte, err error)
+}
+
+// LoaderFunc is a function which implements Loader.
+type LoaderFunc func(ctx context.Context, source Term, env *Env) (string, []byte, error)
+
+// Load calls f.
+func (f LoaderFunc) Load(ctx context.Context, source Term, env *Env) (string, []byte, error) {
+	return f(ctx, source, env)
+}
+
+// FSLoader resolves atoms to the files of FS named after them, either as is or with the extension .pl, in this order.
+type FSLoader struct {
+	FS fs.FS
+}
+
+// Load reads the file which source refers to.
+func (l FSLoader) Load(_ context.Context, source Term, env *Env) (string, []byte, error) {
+	a, ok := env.Resolve(source).(Atom)
+	if !ok {
+		return "", nil, ErrUnsupportedSource
+	}
+	if l.FS == nil {
+		return "", nil, fs.ErrPermission
+	}
+	s := a.String()
+	for _, f := range []string{s, s + ".pl"} {
+		b, err := fs.ReadFile(l.FS, f)
+		if err != nil {
+			continue
+		}
+		return f, b, nil
+	}
+	return "", nil, fs.ErrNotExist
+}
+
+// Loaders tries the loaders in order and returns the text of the first one which resolves the source. The order
+// makes the resolution deterministic even if several loaders would resolve the same source.
+type Loaders []Loader
+
+// Load returns the text of the first loader which neither doesn't recognize source nor lacks it.
+func (ls Loaders) Load(ctx context.Context, source Term, env *Env) (string, []byte, error) {
+	err := ErrUnsupportedSource
+	for _, l := range ls {
+		name, b, e := l.Load(ctx, source, env)
+		switch {
+		case e == nil:
+			return name, b, nil
+		case errors.Is(e, ErrUnsupportedSource):
+			continue
+		case errors.Is(e, fs.ErrNotExist):
+			err = e
+			continue
+		default:
+			return "", nil, e
+		}
+	}
+	return "", nil, err
+}
+
+// CachedLoader remembers the texts resolved by Loader so that a source is resolved at most once, e.g. if fetching it
+// is expensive. The failures aren't remembered. A CachedLoader is safe for concurrent use and may be shared by VMs.
+type CachedLoader struct {
+	Loader Loader
+
+	mu    sync.Mutex
+	cache map[string]cachedText
+}
+
+type cachedText struct {
+	name    string
+	content []byte
+}
+
+// NewCachedLoader returns a loader which caches the texts resolved by l.
+func NewCachedLoader(l Loader) *CachedLoader {
+	return &CachedLoader{Loader: l}
+}
+
+// Load returns the text cached for source or resolves it with the underlying Loader.
+func (l *CachedLoader) Load(ctx context.Context, source Term, env *Env) (string, []byte, error) {
+	var sb strings.Builder
+	if err := env.Resolve(source).WriteTerm(&sb, &WriteOptions{quoted: true, ignoreOps: true}, env); err != nil {
+		return "", nil, err
+	}
+	key := sb.String()
+
+	l.mu.Lock()
+	t, ok := l.cache[key]
+	l.mu.Unlock()
+	if ok {
+		return t.name, t.content, nil
+	}
+
+	name, b, err := l.Loader.Load(ctx, source, env)
+	if err != nil {
+		return "", nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cache == nil {
+		l.cache = map[string]cachedText{}
+	}
+	l.cache[key] = cachedText{name: name, content: b}
+	return name, b, nil
+}
@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFSLoader_Load(t *testing.T) {
+	l := FSLoader{FS: fstest.MapFS{
+		"foo":    {Data: []byte(`foo(a).`)},
+		"foo.pl": {Data: []byte(`foo(b).`)},
+		"bar.pl": {Data: []byte(`bar.`)},
+	}}
+
+	name, b, err := l.Load(context.Background(), NewAtom("foo"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", name)
+	assert.Equal(t, `foo(a).`, string(b))
+
+	name, _, err = l.Load(context.Background(), NewAtom("bar"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "bar.pl", name)
+
+	_, _, err = l.Load(context.Background(), NewAtom("baz"), nil)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, _, err = l.Load(context.Background(), NewAtom("library").Apply(NewAtom("foo")), nil)
+	assert.ErrorIs(t, err, ErrUnsupportedSource)
+
+	_, _, err = FSLoader{}.Load(context.Background(), NewAtom("foo"), nil)
+	assert.ErrorIs(t, err, fs.ErrPermission)
+}
+
+// libraryLoader resolves library(Name) to the text mapped from Name.
+type libraryLoader map[string]string
+
+func (l libraryLoader) Load(_ context.Context, source Term, env *Env) (string, []byte, error) {
+	c, ok := env.Resolve(source).(Compound)
+	if !ok || c.Functor() != NewAtom("library") || c.Arity() != 1 {
+		return "", nil, ErrUnsupportedSource
+	}
+	a, ok := env.Resolve(c.Arg(0)).(Atom)
+	if !ok {
+		return "", nil, ErrUnsupportedSource
+	}
+	s, ok := l[a.String()]
+	if !ok {
+		return "", nil, fs.ErrNotExist
+	}
+	return "library/" + a.String(), []byte(s), nil
+}
+
+func TestLoaders_Load(t *testing.T) {
+	errBroken := errors.New("broken")
+	l := Loaders{
+		libraryLoader{"lists": `lists.`},
+		FSLoader{FS: fstest.MapFS{"lists.pl": {Data: []byte(`fs.`)}}},
+		LoaderFunc(func(_ context.Context, source Term, env *Env) (string, []byte, error) {
+			switch env.Resolve(source).(type) {
+			case Atom:
+				if env.Resolve(source) == NewAtom("broken") {
+					return "", nil, errBroken
+				}
+				return "", nil, fs.ErrNotExist
+			default:
+				return "", nil, ErrUnsupportedSource
+			}
+		}),
+	}
+
+	name, b, err := l.Load(context.Background(), NewAtom("library").Apply(NewAtom("lists")), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "library/lists", name)
+	assert.Equal(t, `lists.`, string(b))
+
+	name, _, err = l.Load(context.Background(), NewAtom("lists"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "lists.pl", name)
+
+	_, _, err = l.Load(context.Background(), NewAtom("library").Apply(NewAtom("sets")), nil)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, _, err = l.Load(context.Background(), NewAtom("broken"), nil)
+	assert.Equal(t, errBroken, err)
+
+	_, _, err = l.Load(context.Background(), Integer(1), nil)
+	assert.ErrorIs(t, err, ErrUnsupportedSource)
+}
+
+func TestCachedLoader_Load(t *testing.T) {
+	var calls int
+	l := NewCachedLoader(LoaderFunc(func(_ context.Context, source Term, env *Env) (string, []byte, error) {
+		calls++
+		a, ok := env.Resolve(source).(Atom)
+		if !ok || !strings.HasPrefix(a.String(), "axone://") {
+			return "", nil, fs.ErrNotExist
+		}
+		return a.String(), []byte(`foo.`), nil
+	}))
+
+	for i := 0; i < 2; i++ {
+		name, b, err := l.Load(context.Background(), NewAtom("axone://dataverse/1"), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "axone://dataverse/1", name)
+		assert.Equal(t, `foo.`, string(b))
+	}
+	assert.Equal(t, 1, calls)
+
+	for i := 0; i < 2; i++ {
+		_, _, err := l.Load(context.Background(), NewAtom("foo"), nil)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	}
+	assert.Equal(t, 3, calls)
+}
+
+func TestVM_Loader(t *testing.T) {
+	vm := VM{Loader: Loaders{
+		libraryLoader{"lists": `:-(ensure_loaded('axone://dataverse/1')). member(X, [X|_]).`},
+		LoaderFunc(func(_ context.Context, source Term, env *Env) (string, []byte, error) {
+			switch env.Resolve(source) {
+			case NewAtom("axone://dataverse/1"):
+				break
+			case Integer(1):
+				return "", nil, ErrUnsupportedSource
+			default:
+				return "", nil, fs.ErrNotExist
+			}
+			return "axone://dataverse/1", []byte(`foo.`), nil
+		}),
+	}}
+
+	_, err := Consult(&vm, NewAtom("library").Apply(NewAtom("lists")), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"library/lists", "axone://dataverse/1"}, vm.LoadedSources())
+	_, ok := vm.getProcedure(procedureIndicator{name: NewAtom("foo"), arity: 0})
+	assert.True(t, ok)
+
+	_, err = Consult(&vm, NewAtom("library").Apply(NewAtom("sets")), Success, nil).Force(context.Background())
+	assert.Equal(t, existenceError(objectTypeSourceSink, NewAtom("library").Apply(NewAtom("sets")), nil), err)
+
+	_, err = Consult(&vm, Integer(1), Success, nil).Force(context.Background())
+	assert.Equal(t, typeError(validTypeAtom, Integer(1), nil), err)
+}
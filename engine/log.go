@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// SetLogger makes the VM emit structured events to h: load started/finished, directive executed, unknown procedure, the
+// messages printed by print_message/2 or by the VM itself unless MessageHook handles them, and the events of log/2 and
+// log/3.
+// A nil handler disables logging.
+func (vm *VM) SetLogger(h slog.Handler) {
+	if h == nil {
+		vm.logger = nil
+		return
+	}
+	vm.logger = slog.New(h)
+}
+
+// Logger returns the logger set by SetLogger or a logger discarding every event if there's none.
+func (vm *VM) Logger() *slog.Logger {
+	if vm.logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return vm.logger
+}
+
+func (vm *VM) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if vm == nil || vm.logger == nil {
+		return
+	}
+	vm.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// LogTerm returns an attribute whose value is the quoted representation of t.
+// The term is written only if the event is actually logged.
+func (vm *VM) LogTerm(key string, t Term, env *Env) slog.Attr {
+	return slog.Any(key, termValuer{t: t, env: env, ops: vm.getOperators()})
+}
+
+type termValuer struct {
+	t   Term
+	env *Env
+	ops *operators
+}
+
+func (v termValuer) LogValue() slog.Value {
+	var sb strings.Builder
+	_ = v.t.WriteTerm(&sb, &WriteOptions{quoted: true, _ops: v.ops, priority: 1200}, v.env)
+	return slog.StringValue(sb.String())
+}
+
+func errorAttr(err error) slog.Attr {
+	if err == nil {
+		return slog.Attr{}
+	}
+	return slog.String("error", err.Error())
+}
+
+// Log2 emits an event with the text message at level to the logger set by SetLogger.
+func Log2(vm *VM, level, message Term, k Cont, env *Env) *Promise {
+	return Log3(vm, level, message, List(), k, env)
+}
+
+// Log3 emits an event with the text message and the attributes at level to the logger set by SetLogger.
+// level is either debug, info, warning, error, or an integer as in log/slog. attrs is either a dict or a list of
+// Key-Value pairs whose integers, atoms, and strings become attributes of the corresponding types while the other
+// values become their quoted representations.
+func Log3(vm *VM, level, message, attrs Term, k Cont, env *Env) *Promise {
+	l, err := logLevel(level, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	msg, err := textOf(message, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	var as []slog.Attr
+	switch a := env.Resolve(attrs).(type) {
+	case Variable:
+		return Error(InstantiationError(env))
+	case Dict:
+		for key, value := range a.All() {
+			as = append(as, vm.logAttr(key, value, env))
+		}
+	default:
+		iter := ListIterator{List: a, Env: env}
+		for iter.Next() {
+			key, value, err := assertPair(env.Resolve(iter.Current()), env)
+			if err != nil {
+				return Error(err)
+			}
+			as = append(as, vm.logAttr(key, value, env))
+		}
+		if err := iter.Err(); err != nil {
+			return Error(err)
+		}
+	}
+
+	return Delay(func(ctx context.Context) *Promise {
+		vm.log(ctx, l, msg, as...)
+		return k(env)
+	})
+}
+
+func logLevel(level Term, env *Env) (slog.Level, error) {
+	switch l := env.Resolve(level).(type) {
+	case Variable:
+		return 0, InstantiationError(env)
+	case Atom:
+		switch l {
+		case atomDebug:
+			return slog.LevelDebug, nil
+		case atomInfo:
+			return slog.LevelInfo, nil
+		case atomWarning:
+			return slog.LevelWarn, nil
+		case atomError:
+			return slog.LevelError, nil
+		default:
+			return 0, domainError(validDomainLogLevel, l, env)
+		}
+	case Integer:
+		return slog.Level(l), nil
+	default:
+		return 0, typeError(validTypeAtom, l, env)
+	}
+}
+
+func (vm *VM) logAttr(key Atom, value Term, env *Env) slog.Attr {
+	switch v := env.Resolve(value).(type) {
+	case Integer:
+		return slog.Int64(key.String(), int64(v))
+	case Atom:
+		return slog.String(key.String(), v.String())
+	case String:
+		return slog.String(key.String(), string(v))
+	default:
+		return vm.LogTerm(key.String(), v, env)
+	}
+}
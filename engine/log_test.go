@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLogHandler(buf *bytes.Buffer) slog.Handler {
+	return slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+}
+
+func TestVM_SetLogger(t *testing.T) {
+	t.Run("load and directive", func(t *testing.T) {
+		var buf bytes.Buffer
+		vm := VM{FS: testdata}
+		vm.Register1(NewAtom("consult"), Consult)
+		vm.SetLogger(newTestLogHandler(&buf))
+
+		assert.NoError(t, vm.Compile(context.Background(), `:-(consult('testdata/empty.txt')).`))
+		assert.Equal(t, `level=INFO msg="load started" file=testdata/empty.txt
+level=INFO msg="load finished" file=testdata/empty.txt
+level=DEBUG msg="directive executed" directive=consult('testdata/empty.txt')
+`, buf.String())
+	})
+
+	t.Run("durations", func(t *testing.T) {
+		var buf bytes.Buffer
+		vm := VM{FS: testdata}
+		vm.Register1(NewAtom("consult"), Consult)
+		vm.SetLogger(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+			ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}))
+		now := time.Unix(0, 0)
+		vm.Clock = func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		}
+
+		assert.NoError(t, vm.Compile(context.Background(), `:-(consult('testdata/empty.txt')).`))
+		assert.Equal(t, `level=INFO msg="load started" file=testdata/empty.txt
+level=INFO msg="load finished" file=testdata/empty.txt duration=1s
+level=DEBUG msg="directive executed" directive=consult('testdata/empty.txt') duration=3s
+`, buf.String())
+	})
+
+	t.Run("unknown procedure", func(t *testing.T) {
+		var buf bytes.Buffer
+		var vm VM
+		vm.unknown = unknownWarning
+		vm.SetLogger(newTestLogHandler(&buf))
+
+		ok, err := vm.Arrive(NewAtom("foo"), []Term{NewAtom("a")}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, `level=WARN msg="Unknown procedure: /(foo,1)" kind=warning message=unknown_procedure(/(foo,1))
+`, buf.String())
+
+		buf.Reset()
+		vm.unknown = unknownFail
+		ok, err = vm.Arrive(NewAtom("foo"), []Term{NewAtom("a")}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, `level=DEBUG msg="unknown procedure" pi=foo/1 unknown=fail
+`, buf.String())
+	})
+
+	t.Run("no logger", func(t *testing.T) {
+		var vm VM
+		vm.SetLogger(nil)
+		assert.Nil(t, vm.logger)
+		assert.NotNil(t, vm.Logger())
+		assert.False(t, vm.Logger().Enabled(context.Background(), slog.LevelError))
+	})
+}
+
+func TestLog3(t *testing.T) {
+	x := NewVariable()
+	attrs, err := NewDict([]Term{NewAtom("tag"), NewAtom("count"), Integer(3), NewAtom("who"), NewAtom("alice")})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		title                 string
+		level, message, attrs Term
+		ok                    bool
+		err                   error
+		output                string
+	}{
+		{title: "dict", level: atomInfo, message: NewAtom("hello"), attrs: attrs, ok: true, output: `level=INFO msg=hello count=3 who=alice
+`},
+		{title: "pairs", level: atomWarning, message: String("low disk"), attrs: List(atomMinus.Apply(NewAtom("free"), Integer(42)), atomColon.Apply(NewAtom("path"), String("/tmp")), NewAtom("mount").Apply(NewAtom("f").Apply(NewAtom("a b")))), ok: true, output: `level=WARN msg="low disk" free=42 path=/tmp mount="f('a b')"
+`},
+		{title: "integer level", level: Integer(-4), message: NewAtom("trace"), attrs: List(), ok: true, output: `level=DEBUG msg=trace
+`},
+		{title: "error level", level: atomError, message: CodeList("failed"), attrs: List(), ok: true, output: `level=ERROR msg=failed
+`},
+		{title: "level is a variable", level: x, message: NewAtom("hello"), attrs: List(), err: InstantiationError(nil)},
+		{title: "level is unknown", level: NewAtom("fatal"), message: NewAtom("hello"), attrs: List(), err: domainError(validDomainLogLevel, NewAtom("fatal"), nil)},
+		{title: "level is neither an atom nor an integer", level: String("info"), message: NewAtom("hello"), attrs: List(), err: typeError(validTypeAtom, String("info"), nil)},
+		{title: "message is a variable", level: atomInfo, message: x, attrs: List(), err: InstantiationError(nil)},
+		{title: "attrs is a variable", level: atomInfo, message: NewAtom("hello"), attrs: x, err: InstantiationError(nil)},
+		{title: "attrs has a non-pair", level: atomInfo, message: NewAtom("hello"), attrs: List(Integer(1)), err: typeError(validTypePair, Integer(1), nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			var buf bytes.Buffer
+			var vm VM
+			vm.SetLogger(newTestLogHandler(&buf))
+
+			ok, err := Log3(&vm, tt.level, tt.message, tt.attrs, Success, nil).Force(context.Background())
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.output, buf.String())
+		})
+	}
+}
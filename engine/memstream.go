@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"context"
+	"strings"
+)
+
+// OpenString opens the text str as an input text stream and unifies it with stream.
+func OpenString(vm *VM, str, stream Term, k Cont, env *Env) *Promise {
+	s, err := textOf(str, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	if _, ok := env.Resolve(stream).(Variable); !ok {
+		return Error(UninstantiationError(env.Resolve(stream), env))
+	}
+
+	m := NewMemoryStream(s)
+	m.vm = vm
+	return Unify(vm, stream, m, k, env)
+}
+
+// WithOutputTo runs goal once with the current output redirected to memory and unifies what it wrote with sink.
+// sink is either atom(A), string(S), codes(Cs), or chars(Cs).
+// The current output is restored whether goal succeeds, fails, or raises an exception.
+func WithOutputTo(vm *VM, sink, goal Term, k Cont, env *Env) *Promise {
+	var conv func(string) Term
+	switch s := env.Resolve(sink).(type) {
+	case Variable:
+		return Error(InstantiationError(env))
+	case Compound:
+		if s.Arity() != 1 {
+			return Error(domainError(validDomainOutputSink, s, env))
+		}
+		switch s.Functor() {
+		case atomAtom:
+			conv = func(s string) Term { return NewAtom(s) }
+		case atomString:
+			conv = func(s string) Term { return String(s) }
+		case atomCodes:
+			conv = CodeList
+		case atomChars:
+			conv = CharList
+		default:
+			return Error(domainError(validDomainOutputSink, s, env))
+		}
+		sink = s.Arg(0)
+	default:
+		return Error(domainError(validDomainOutputSink, s, env))
+	}
+
+	return Delay(func(ctx context.Context) *Promise {
+		text, ok, env, err := vm.captureOutput(ctx, goal, env)
+		if err != nil {
+			return Error(err)
+		}
+		if !ok {
+			return Bool(false)
+		}
+		return Unify(vm, sink, conv(text), k, env)
+	})
+}
+
+// captureOutput calls goal once with the current output redirected to memory and returns what it wrote, whether it
+// succeeded, and the environment of the solution.
+func (vm *VM) captureOutput(ctx context.Context, goal Term, env *Env) (string, bool, *Env, error) {
+	var sb strings.Builder
+	s := NewOutputTextStream(&sb)
+	s.vm = vm
+
+	output := vm.output
+	vm.output = s
+	defer func() {
+		vm.output = output
+	}()
+
+	var solution *Env
+	ok, err := Call(vm, goal, func(env *Env) *Promise {
+		solution = env
+		return Bool(true)
+	}, env).Force(ctx)
+	if err != nil || !ok {
+		return "", false, nil, err
+	}
+	return sb.String(), true, solution, nil
+}
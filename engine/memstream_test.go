@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenString(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		var vm VM
+		s, c := NewVariable(), NewVariable()
+		ok, err := OpenString(&vm, String("ab"), s, func(env *Env) *Promise {
+			return GetChar(&vm, env.Resolve(s), c, func(env *Env) *Promise {
+				assert.Equal(t, NewAtom("a"), env.Resolve(c))
+				return Bool(true)
+			}, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("stream is not a variable", func(t *testing.T) {
+		ok, err := OpenString(nil, String("ab"), NewAtom("s"), Success, nil).Force(context.Background())
+		assert.Equal(t, UninstantiationError(NewAtom("s"), nil), err)
+		assert.False(t, ok)
+	})
+}
+
+func TestWithOutputTo(t *testing.T) {
+	var out bytes.Buffer
+	vm := VM{}
+	vm.SetUserOutput(NewOutputTextStream(&out))
+	vm.Register1(NewAtom("write"), func(vm *VM, t Term, k Cont, env *Env) *Promise {
+		return WriteTerm(vm, vm.output, t, List(), k, env)
+	})
+	vm.Register0(NewAtom("foo"), func(vm *VM, k Cont, env *Env) *Promise {
+		return Error(NewException(NewAtom("foo"), nil))
+	})
+	vm.Register0(NewAtom("bar"), func(*VM, Cont, *Env) *Promise {
+		return Bool(false)
+	})
+	a := NewVariable()
+
+	tests := []struct {
+		title   string
+		sink    Term
+		goal    Term
+		ok      bool
+		err     error
+		capture Term
+	}{
+		{title: "atom", sink: atomAtom.Apply(a), goal: NewAtom("write").Apply(NewAtom("a b")), ok: true, capture: NewAtom("a b")},
+		{title: "string", sink: atomString.Apply(a), goal: NewAtom("write").Apply(Integer(1)), ok: true, capture: String("1")},
+		{title: "codes", sink: atomCodes.Apply(a), goal: NewAtom("write").Apply(NewAtom("ab")), ok: true, capture: CodeList("ab")},
+		{title: "chars", sink: atomChars.Apply(a), goal: NewAtom("write").Apply(NewAtom("ab")), ok: true, capture: CharList("ab")},
+		{title: "compare", sink: atomAtom.Apply(NewAtom("ab")), goal: NewAtom("write").Apply(NewAtom("ab")), ok: true},
+		{title: "mismatch", sink: atomAtom.Apply(NewAtom("ab")), goal: NewAtom("write").Apply(NewAtom("cd")), ok: false},
+		{title: "failure", sink: atomAtom.Apply(a), goal: NewAtom("bar"), ok: false},
+		{title: "exception", sink: atomAtom.Apply(a), goal: NewAtom("foo"), err: NewException(NewAtom("foo"), nil)},
+		{title: "sink is a variable", sink: a, goal: atomTrue, err: InstantiationError(nil)},
+		{title: "unknown sink", sink: NewAtom("foo").Apply(NewAtom("a")), goal: atomTrue, err: domainError(validDomainOutputSink, NewAtom("foo").Apply(NewAtom("a")), nil)},
+		{title: "sink is not compound", sink: NewAtom("foo"), goal: atomTrue, err: domainError(validDomainOutputSink, NewAtom("foo"), nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			output := vm.output
+			ok, err := WithOutputTo(&vm, tt.sink, tt.goal, func(env *Env) *Promise {
+				if tt.capture != nil {
+					assert.Equal(t, tt.capture, env.Resolve(a))
+				}
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, output, vm.output)
+			assert.Empty(t, out.String())
+		})
+	}
+}
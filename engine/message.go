@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// MessageText returns the human-readable text of message:
+//
+//	format(Format, Args)	the text rendered by format/2
+//	error(Formal, Context)	the formal and the context of the exception
+//	unknown_procedure(PI)	the procedure which is unknown
+//	time(Goal, Port, Stats)	the resources used by Goal as reported by time/1
+//
+// and "Unknown message: " followed by message otherwise.
+func (vm *VM) MessageText(message Term, env *Env) string {
+	var sb strings.Builder
+	write := func(t Term) {
+		_ = env.Resolve(t).WriteTerm(&sb, &WriteOptions{quoted: true, _ops: vm.getOperators(), priority: 1200}, env)
+	}
+
+	switch m := env.Resolve(message).(type) {
+	case Compound:
+		switch {
+		case m.Functor() == atomFormat && m.Arity() == 2:
+			if s, err := formatText(vm, m.Arg(0), m.Arg(1), env); err == nil {
+				return s
+			}
+		case m.Functor() == atomError && m.Arity() == 2:
+			ctx := env.Resolve(m.Arg(1))
+			if c, ok := ctx.(Compound); ok && c.Functor() == atomContext && c.Arity() == 2 {
+				ctx = env.Resolve(c.Arg(0))
+			}
+			if _, ok := ctx.(Variable); !ok {
+				write(ctx)
+				_, _ = sb.WriteString(": ")
+			}
+			write(m.Arg(0))
+			return sb.String()
+		case m.Functor() == atomUnknownProcedure && m.Arity() == 1:
+			_, _ = sb.WriteString("Unknown procedure: ")
+			write(m.Arg(0))
+			return sb.String()
+		case m.Functor() == atomTime && m.Arity() == 3:
+			stats, ok := env.Resolve(m.Arg(2)).(Dict)
+			if !ok {
+				break
+			}
+			inferences, ok1 := stats.Value(atomInferences)
+			wall, ok2 := stats.Value(atomWall)
+			gas, ok3 := stats.Value(atomGas)
+			if !ok1 || !ok2 || !ok3 {
+				break
+			}
+			write(m.Arg(0))
+			_, _ = sb.WriteString(" timed at ")
+			write(m.Arg(1))
+			_, _ = sb.WriteString(": ")
+			write(inferences)
+			_, _ = sb.WriteString(" inferences, ")
+			write(wall)
+			_, _ = sb.WriteString(" seconds, ")
+			write(gas)
+			_, _ = sb.WriteString(" gas")
+			return sb.String()
+		}
+	}
+
+	_, _ = sb.WriteString("Unknown message: ")
+	write(message)
+	return sb.String()
+}
+
+// printMessage passes message of kind to the message hook and, if it's not handled, logs it.
+func (vm *VM) printMessage(ctx context.Context, kind, message Term, env *Env) {
+	if vm.MessageHook != nil && vm.MessageHook(kind, message, env) {
+		return
+	}
+
+	level, ok := messageLevel(kind, env)
+	if !ok {
+		return
+	}
+	vm.log(ctx, level, vm.MessageText(message, env), vm.LogTerm("kind", kind, env), vm.LogTerm("message", message, env))
+}
+
+// messageLevel returns the logging level of the messages of kind, or false if they're not printed.
+func messageLevel(kind Term, env *Env) (slog.Level, bool) {
+	switch k := env.Resolve(kind).(type) {
+	case Atom:
+		switch k {
+		case atomError:
+			return slog.LevelError, true
+		case atomWarning:
+			return slog.LevelWarn, true
+		case atomSilent:
+			return 0, false
+		}
+	case Compound:
+		if k.Functor() == atomDebug && k.Arity() == 1 {
+			return slog.LevelDebug, true
+		}
+	}
+	return slog.LevelInfo, true
+}
+
+// messagePrefix returns the prefix of the lines of the messages of kind.
+func messagePrefix(kind Term, env *Env) string {
+	switch env.Resolve(kind) {
+	case atomError:
+		return "ERROR: "
+	case atomWarning:
+		return "Warning: "
+	default:
+		return ""
+	}
+}
+
+// PrintMessage prints message of kind, e.g. error, warning, informational, silent, or debug(Topic).
+// The message is passed to VM.MessageHook and, unless it handles it, logged at the level of kind.
+func PrintMessage(vm *VM, kind, message Term, k Cont, env *Env) *Promise {
+	if err := checkMessageKind(kind, env); err != nil {
+		return Error(err)
+	}
+
+	return Delay(func(ctx context.Context) *Promise {
+		vm.printMessage(ctx, kind, message, env)
+		return k(env)
+	})
+}
+
+// MessageToCodes unifies codes with the text of message of kind as it's printed, e.g. "Warning: " followed by the text
+// of message for a warning.
+func MessageToCodes(vm *VM, message, kind, codes Term, k Cont, env *Env) *Promise {
+	if err := checkMessageKind(kind, env); err != nil {
+		return Error(err)
+	}
+
+	return Unify(vm, codes, CodeList(messagePrefix(kind, env)+vm.MessageText(message, env)), k, env)
+}
+
+func checkMessageKind(kind Term, env *Env) error {
+	switch k := env.Resolve(kind).(type) {
+	case Variable:
+		return InstantiationError(env)
+	case Atom, Compound:
+		return nil
+	default:
+		return typeError(validTypeCallable, k, env)
+	}
+}
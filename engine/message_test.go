@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintMessage(t *testing.T) {
+	t.Run("logged", func(t *testing.T) {
+		var buf bytes.Buffer
+		var vm VM
+		vm.SetLogger(newTestLogHandler(&buf))
+
+		for _, kind := range []Term{atomWarning, atomSilent, NewAtom("informational")} {
+			ok, err := PrintMessage(&vm, kind, NewAtom("format").Apply(String("~a is ~d"), List(NewAtom("x"), Integer(1))), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+		assert.Equal(t, `level=WARN msg="x is 1" kind=warning message="format(\"~a is ~d\",[x,1])"
+level=INFO msg="x is 1" kind=informational message="format(\"~a is ~d\",[x,1])"
+`, buf.String())
+	})
+
+	t.Run("hook", func(t *testing.T) {
+		var buf bytes.Buffer
+		var vm VM
+		vm.SetLogger(newTestLogHandler(&buf))
+		var messages []Term
+		vm.MessageHook = func(kind, message Term, env *Env) bool {
+			messages = append(messages, atomMinus.Apply(kind, env.Resolve(message)))
+			return kind == atomWarning
+		}
+
+		ok, err := PrintMessage(&vm, atomWarning, NewAtom("foo"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		ok, err = PrintMessage(&vm, atomError, NewAtom("bar"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		assert.Equal(t, []Term{atomMinus.Apply(atomWarning, NewAtom("foo")), atomMinus.Apply(atomError, NewAtom("bar"))}, messages)
+		assert.Equal(t, `level=ERROR msg="Unknown message: bar" kind=error message=bar
+`, buf.String())
+	})
+
+	t.Run("unknown procedure", func(t *testing.T) {
+		var (
+			vm  VM
+			buf bytes.Buffer
+		)
+		vm.unknown = unknownWarning
+		vm.SetLogger(newTestLogHandler(&buf))
+		var messages []Term
+		vm.MessageHook = func(kind, message Term, env *Env) bool {
+			messages = append(messages, atomMinus.Apply(kind, message))
+			return true
+		}
+
+		ok, err := vm.Arrive(NewAtom("foo"), []Term{NewAtom("a")}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, []Term{atomMinus.Apply(atomWarning, atomUnknownProcedure.Apply(atomSlash.Apply(NewAtom("foo"), Integer(1))))}, messages)
+		assert.Empty(t, buf.String(), "the hook handled the warning")
+	})
+
+	t.Run("kind is a variable", func(t *testing.T) {
+		var vm VM
+		ok, err := PrintMessage(&vm, NewVariable(), NewAtom("foo"), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("kind is not callable", func(t *testing.T) {
+		var vm VM
+		ok, err := PrintMessage(&vm, Integer(0), NewAtom("foo"), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeCallable, Integer(0), nil), err)
+		assert.False(t, ok)
+	})
+}
+
+func TestMessageToCodes(t *testing.T) {
+	var vm VM
+	vm.getOperators().define(400, operatorSpecifierYFX, atomSlash)
+
+	tests := []struct {
+		title   string
+		message Term
+		kind    Term
+		text    string
+	}{
+		{title: "format", message: NewAtom("format").Apply(String("~w"), List(NewAtom("a"))), kind: NewAtom("informational"), text: "a"},
+		{title: "error", message: atomError.Apply(atomTypeError.Apply(atomInteger, NewAtom("a")), atomSlash.Apply(NewAtom("foo"), Integer(1))), kind: atomError, text: "ERROR: foo/1: type_error(integer,a)"},
+		{title: "error without context", message: atomError.Apply(atomInstantiationError, NewVariable()), kind: atomError, text: "ERROR: instantiation_error"},
+		{title: "unknown procedure", message: atomUnknownProcedure.Apply(atomSlash.Apply(NewAtom("foo"), Integer(1))), kind: atomWarning, text: "Warning: Unknown procedure: foo/1"},
+		{title: "unknown message", message: NewAtom("foo bar"), kind: atomWarning, text: "Warning: Unknown message: 'foo bar'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			codes := NewVariable()
+			ok, err := MessageToCodes(&vm, tt.message, tt.kind, codes, func(env *Env) *Promise {
+				assert.Equal(t, CodeList(tt.text), env.Resolve(codes))
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+	}
+}
@@ -27,6 +27,10 @@ const (
 	// MeterCompareStep charges one unit per structural comparison step in the standard order of terms.
 	// It captures work performed by compare/3, sort/2, keysort/2, setof/3, and related operations.
 	MeterCompareStep
+
+	// MeterOutputChar charges one unit per character rendered by format/1,2,3.
+	// It covers the output whose length is given by the format rather than by the arguments, e.g. ~Nc or ~N|.
+	MeterOutputChar
 )
 
 // MeterFunc is called by the VM whenever it consumes a metered resource.
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"context"
+	"expvar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_SetMetricsSink(t *testing.T) {
+	var vm VM
+	assert.NoError(t, vm.Compile(context.Background(), `
+len([], 0).
+:-(len('.'(_, T), s(N)), len(T, N)).
+`))
+
+	var sink expvar.Map
+	vm.SetMetricsSink(&sink)
+
+	query := func() {
+		ok, err := Call(&vm, NewAtom("len").Apply(List(NewAtom("a"), NewAtom("b")), NewVariable()), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+	value := func(key string) int64 {
+		v, ok := sink.Get(key).(*expvar.Int)
+		if !ok {
+			return 0
+		}
+		return v.Value()
+	}
+
+	query()
+	vm.FlushMetrics()
+	instructions := value(MetricInstructions)
+	assert.Equal(t, int64(vm.Instructions()), instructions)
+	assert.Positive(t, value(MetricUnifications))
+	assert.Positive(t, value(MetricEnvNodes))
+	assert.Positive(t, value(MetricPromises))
+
+	t.Run("flushed once", func(t *testing.T) {
+		vm.FlushMetrics()
+		assert.Equal(t, instructions, value(MetricInstructions))
+	})
+
+	t.Run("accumulated", func(t *testing.T) {
+		unifications, promises := value(MetricUnifications), value(MetricPromises)
+		query()
+		vm.FlushMetrics()
+		assert.Equal(t, 2*instructions, value(MetricInstructions))
+		assert.Equal(t, 2*unifications, value(MetricUnifications))
+		assert.Equal(t, 2*promises, value(MetricPromises))
+	})
+
+	t.Run("cleared", func(t *testing.T) {
+		vm.SetMetricsSink(nil)
+		instructions := value(MetricInstructions)
+		query()
+		vm.FlushMetrics()
+		assert.Equal(t, instructions, value(MetricInstructions))
+	})
+}
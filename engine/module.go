@@ -0,0 +1,418 @@
+package engine
+
+import (
+	"context"
+
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+)
+
+// module is a namespace of user-defined predicates introduced by the module/2 directive.
+// The default module user is the VM's global namespace and has no module of its own.
+type module struct {
+	file       string
+	exports    []Term // predicate indicators and op/3 terms.
+	procedures *orderedmap.OrderedMap[procedureIndicator, procedure]
+}
+
+func (m *module) exported(pi procedureIndicator) bool {
+	for _, e := range m.exports {
+		if e, ok := e.(procedureIndicator); ok && e == pi {
+			return true
+		}
+	}
+	return false
+}
+
+// metaArgs maps meta predicates to the indices of their goal arguments, which are qualified with the module they
+// appear in so that they run in the context of that module.
+var metaArgs = map[procedureIndicator][]int{
+	{name: atomCall, arity: 1}:                      {0},
+	{name: atomCall, arity: 2}:                      {0},
+	{name: atomCall, arity: 3}:                      {0},
+	{name: atomCall, arity: 4}:                      {0},
+	{name: atomCall, arity: 5}:                      {0},
+	{name: atomCall, arity: 6}:                      {0},
+	{name: atomCall, arity: 7}:                      {0},
+	{name: atomCall, arity: 8}:                      {0},
+	{name: atomNegation, arity: 1}:                  {0},
+	{name: NewAtom("once"), arity: 1}:               {0},
+	{name: NewAtom("ignore"), arity: 1}:             {0},
+	{name: NewAtom("forall"), arity: 2}:             {0, 1},
+	{name: NewAtom("catch"), arity: 3}:              {0, 2},
+	{name: NewAtom("findall"), arity: 3}:            {1},
+	{name: NewAtom("findall"), arity: 4}:            {1},
+	{name: NewAtom("bagof"), arity: 3}:              {1},
+	{name: NewAtom("setof"), arity: 3}:              {1},
+	{name: NewAtom("aggregate_all"), arity: 3}:      {1},
+	{name: atomPhrase, arity: 2}:                    {0},
+	{name: atomPhrase, arity: 3}:                    {0},
+	{name: NewAtom("call_nth"), arity: 2}:           {0},
+	{name: NewAtom("call_cleanup"), arity: 2}:       {0, 1},
+	{name: NewAtom("setup_call_cleanup"), arity: 3}: {0, 1, 2},
+	{name: NewAtom("with_output_to"), arity: 2}:     {1},
+	{name: atomTime, arity: 1}:                      {0},
+	{name: atomTime, arity: 2}:                      {0},
+	{name: NewAtom("maplist"), arity: 2}:            {0},
+	{name: NewAtom("maplist"), arity: 3}:            {0},
+	{name: NewAtom("maplist"), arity: 4}:            {0},
+	{name: NewAtom("maplist"), arity: 5}:            {0},
+	{name: NewAtom("maplist"), arity: 6}:            {0},
+	{name: NewAtom("maplist"), arity: 7}:            {0},
+	{name: NewAtom("foldl"), arity: 4}:              {0},
+	{name: NewAtom("foldl"), arity: 5}:              {0},
+	{name: NewAtom("foldl"), arity: 6}:              {0},
+	{name: NewAtom("include"), arity: 3}:            {0},
+	{name: NewAtom("exclude"), arity: 3}:            {0},
+	{name: NewAtom(">>"), arity: 2}:                 {1},
+	{name: NewAtom(">>"), arity: 3}:                 {1},
+	{name: NewAtom(">>"), arity: 4}:                 {1},
+	{name: NewAtom(">>"), arity: 5}:                 {1},
+	{name: NewAtom(">>"), arity: 6}:                 {1},
+	{name: NewAtom(">>"), arity: 7}:                 {1},
+	{name: NewAtom(">>"), arity: 8}:                 {1},
+	{name: NewAtom(">>"), arity: 9}:                 {1},
+}
+
+// qualifyGoal returns goal whose subgoals run in module m.
+func qualifyGoal(m Atom, goal Term, env *Env) Term {
+	switch g := env.Resolve(goal).(type) {
+	case Variable:
+		return atomColon.Apply(m, g)
+	case Atom:
+		if g == atomCut {
+			return g
+		}
+		return atomColon.Apply(m, g)
+	case Compound:
+		switch pi := (procedureIndicator{name: g.Functor(), arity: Integer(g.Arity())}); pi {
+		case procedureIndicator{name: atomColon, arity: 2}:
+			return g
+		case procedureIndicator{name: atomComma, arity: 2},
+			procedureIndicator{name: atomSemiColon, arity: 2},
+			procedureIndicator{name: atomThen, arity: 2}:
+			return pi.name.Apply(qualifyGoal(m, g.Arg(0), env), qualifyGoal(m, g.Arg(1), env))
+		case procedureIndicator{name: atomCaret, arity: 2}:
+			return atomCaret.Apply(g.Arg(0), qualifyGoal(m, g.Arg(1), env))
+		default:
+			if is, ok := metaArgs[pi]; ok {
+				args := make([]Term, g.Arity())
+				for i := range args {
+					args[i] = g.Arg(i)
+				}
+				for _, i := range is {
+					args[i] = qualifyGoal(m, args[i], env)
+				}
+				g = pi.name.Apply(args...).(Compound)
+			}
+			return atomColon.Apply(m, g)
+		}
+	default:
+		return g
+	}
+}
+
+// qualifyClause returns clause t defined in module m whose body goals run in module m.
+func qualifyClause(m Atom, t Term, env *Env) Term {
+	if c, ok := env.Resolve(t).(Compound); ok && c.Functor() == atomIf && c.Arity() == 2 {
+		return atomIf.Apply(c.Arg(0), qualifyGoal(m, c.Arg(1), env))
+	}
+	return t
+}
+
+// resolveQualified returns the module, name, and arguments of the goal to call for module:goal.
+// If goal is a control construct, it's called in user with its subgoals qualified with module.
+func resolveQualified(module, goal Term, env *Env) (Atom, Atom, []Term, error) {
+	var m Atom
+	switch module := env.Resolve(module).(type) {
+	case Variable:
+		return "", "", nil, InstantiationError(env)
+	case Atom:
+		m = module
+	default:
+		return "", "", nil, typeError(validTypeAtom, module, env)
+	}
+
+	g := qualifyGoal(m, goal, env)
+	if c, ok := g.(Compound); ok && c.Functor() == atomColon && c.Arity() == 2 && c.Arg(0) == m {
+		g = c.Arg(1)
+	} else {
+		m = atomUser
+	}
+
+	pi, arg, err := piArg(g, env)
+	if err != nil {
+		return "", "", nil, err
+	}
+	args := make([]Term, pi.arity)
+	for i := range args {
+		args[i] = arg(i)
+	}
+	return m, pi.name, args, nil
+}
+
+func (vm *VM) getModule(name Atom) (*module, bool) {
+	m, ok := vm.modules[name]
+	return m, ok
+}
+
+// lookupProcedure finds the procedure pi visible from module m. A module sees its own procedures, then the ones it
+// imported, then the ones of user.
+func (vm *VM) lookupProcedure(m Atom, pi procedureIndicator) (procedure, bool) {
+	if m == atomUser {
+		if p, ok := vm.getProcedure(pi); ok {
+			return p, true
+		}
+	} else if mod, ok := vm.getModule(m); ok {
+		if p, ok := mod.procedures.Get(pi); ok {
+			return p, true
+		}
+	}
+	if d, ok := vm.imports[m][pi]; ok {
+		if mod, ok := vm.getModule(d); ok {
+			if p, ok := mod.procedures.Get(pi); ok {
+				return p, true
+			}
+		}
+	}
+	if m != atomUser {
+		return vm.lookupProcedure(atomUser, pi)
+	}
+	return nil, false
+}
+
+// compileModuleClause adds the clause M:Head or M:Head :- Body to the procedure Head of module M.
+// M is created if it doesn't exist yet.
+func (vm *VM) compileModuleClause(t Term) error {
+	head, body := t, Term(nil)
+	if c, ok := t.(Compound); ok && c.Functor() == atomIf && c.Arity() == 2 {
+		head, body = c.Arg(0), c.Arg(1)
+	}
+	q := head.(Compound)
+	m, ok := q.Arg(0).(Atom)
+	if !ok {
+		if _, ok := q.Arg(0).(Variable); ok {
+			return InstantiationError(nil)
+		}
+		return typeError(validTypeAtom, q.Arg(0), nil)
+	}
+
+	clause := q.Arg(1)
+	if body != nil {
+		clause = atomIf.Apply(clause, body)
+	}
+	get, set := vm.getProcedure, vm.setProcedure
+	if m != atomUser {
+		clause = qualifyClause(m, clause, nil)
+		mod, ok := vm.getModule(m)
+		if !ok {
+			if vm.modules == nil {
+				vm.modules = map[Atom]*module{}
+			}
+			mod = &module{procedures: orderedmap.New[procedureIndicator, procedure]()}
+			vm.modules[m] = mod
+		}
+		get, set = mod.procedures.Get, mod.procedures.Set
+	}
+
+	cs, err := compile(clause, nil)
+	if err != nil {
+		return err
+	}
+	if err := vm.verify(cs); err != nil {
+		return err
+	}
+	for _, c := range cs {
+		p, _ := get(c.pi)
+		u, ok := p.(*userDefined)
+		if !ok {
+			u = &userDefined{}
+			set(c.pi, u)
+		}
+		u.setClauses(append(u.clauses, c))
+	}
+	return nil
+}
+
+// declareModule makes the text define the module name which exports exports. module/2 has to be the first term of
+// the text since the clauses before it would be added to user otherwise.
+func (vm *VM) declareModule(ctx context.Context, text *text, name, exports Term) error {
+	n, ok := name.(Atom)
+	if !ok {
+		if _, ok := name.(Variable); ok {
+			return InstantiationError(nil)
+		}
+		return typeError(validTypeAtom, name, nil)
+	}
+	if text.terms != 1 {
+		return permissionError(operationCreate, permissionTypeModule, n, nil)
+	}
+
+	m := module{procedures: orderedmap.New[procedureIndicator, procedure]()}
+	iter := ListIterator{List: exports}
+	for iter.Next() {
+		e, err := exportSpec(iter.Current())
+		if err != nil {
+			return err
+		}
+		m.exports = append(m.exports, e)
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	if vm.modules == nil {
+		vm.modules = map[Atom]*module{}
+	}
+	vm.modules[n] = &m
+	text.module = n
+	text.ops = vm.getOperators().clone()
+	return vm.defineOperators(ctx, m.exports)
+}
+
+func (vm *VM) defineOperators(ctx context.Context, specs []Term) error {
+	for _, s := range specs {
+		if s, ok := s.(Compound); ok && s.Functor() == atomOp {
+			if _, err := Op(vm, s.Arg(0), s.Arg(1), s.Arg(2), Success, nil).Force(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func exportSpec(e Term) (Term, error) {
+	switch e := e.(type) {
+	case Variable:
+		return nil, InstantiationError(nil)
+	case Compound:
+		switch {
+		case e.Functor() == atomSlash && e.Arity() == 2:
+			return toProcedureIndicator(e, nil)
+		case e.Functor() == atomSlashSlash && e.Arity() == 2:
+			pi, err := toProcedureIndicator(atomSlash.Apply(e.Arg(0), e.Arg(1)), nil)
+			if err != nil {
+				return nil, err
+			}
+			pi.arity += 2
+			return pi, nil
+		case e.Functor() == atomOp && e.Arity() == 3:
+			return e, nil
+		}
+	}
+	return nil, typeError(validTypePredicateIndicator, e, nil)
+}
+
+// useModule loads the module file and imports the procedures and operators it exports into module into.
+// If imports is not nil, only the listed procedures are imported.
+func (vm *VM) useModule(ctx context.Context, into Atom, file, imports Term, env *Env) error {
+	if err := vm.checkFrozen(permissionTypeSourceSink, file, env); err != nil {
+		return err
+	}
+
+	f, err := vm.ensureLoaded(ctx, file, env)
+	if err != nil {
+		return err
+	}
+
+	return vm.importModule(ctx, into, f, file, imports, env)
+}
+
+// moduleOfFile returns the module defined by the file f, if any.
+func (vm *VM) moduleOfFile(f string) (Atom, *module) {
+	n, ok := vm.moduleFiles[f]
+	if !ok {
+		return "", nil
+	}
+	m, ok := vm.getModule(n)
+	if !ok || m.file != f { // Another file defined the module since.
+		return "", nil
+	}
+	return n, m
+}
+
+// setModuleFile records that the file f defines the module name, if any.
+func (vm *VM) setModuleFile(name Atom, f string) {
+	m, ok := vm.getModule(name)
+	if !ok {
+		return
+	}
+	m.file = f
+	if vm.moduleFiles == nil {
+		vm.moduleFiles = map[string]Atom{}
+	}
+	vm.moduleFiles[f] = name
+}
+
+// dropImports removes the imports of the procedures of the module name which the file f doesn't export anymore, either
+// because its module stopped exporting them or because it doesn't define the module anymore.
+func (vm *VM) dropImports(name Atom, f string) {
+	m, ok := vm.getModule(name)
+	for _, is := range vm.imports {
+		for pi, d := range is {
+			if d == name && (!ok || m.file != f || !m.exported(pi)) {
+				delete(is, pi)
+			}
+		}
+	}
+}
+
+// importModule imports the procedures and operators exported by the module defined by the file f into module into.
+// If imports is not nil, only the listed procedures are imported.
+func (vm *VM) importModule(ctx context.Context, into Atom, f string, file, imports Term, env *Env) error {
+	name, m := vm.moduleOfFile(f)
+	if m == nil {
+		return existenceError(objectTypeModule, env.Resolve(file), env)
+	}
+
+	specs := m.exports
+	if imports != nil {
+		specs = nil
+		iter := ListIterator{List: imports, Env: env}
+		for iter.Next() {
+			e, err := exportSpec(env.Resolve(iter.Current()))
+			if err != nil {
+				return err
+			}
+			if pi, ok := e.(procedureIndicator); ok && !m.exported(pi) {
+				return permissionError(operationAccess, permissionTypePrivateProcedure, atomColon.Apply(name, pi.Term()), env)
+			}
+			specs = append(specs, e)
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range specs {
+		if pi, ok := s.(procedureIndicator); ok {
+			if vm.imports == nil {
+				vm.imports = map[Atom]map[procedureIndicator]Atom{}
+			}
+			if vm.imports[into] == nil {
+				vm.imports[into] = map[procedureIndicator]Atom{}
+			}
+			vm.imports[into][pi] = name
+		}
+	}
+	return vm.defineOperators(ctx, specs)
+}
+
+// UseModule loads the module file and imports all the procedures and operators it exports into user.
+func UseModule(vm *VM, file Term, k Cont, env *Env) *Promise {
+	return Delay(func(ctx context.Context) *Promise {
+		if err := vm.useModule(ctx, atomUser, file, nil, env); err != nil {
+			return Error(err)
+		}
+		return k(env)
+	})
+}
+
+// UseModule2 loads the module file and imports the procedures in imports into user.
+func UseModule2(vm *VM, file, imports Term, k Cont, env *Env) *Promise {
+	return Delay(func(ctx context.Context) *Promise {
+		if err := vm.useModule(ctx, atomUser, file, imports, env); err != nil {
+			return Error(err)
+		}
+		return k(env)
+	})
+}
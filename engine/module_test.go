@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQualifyGoal(t *testing.T) {
+	m := NewAtom("m")
+	foo, bar := NewAtom("foo"), NewAtom("bar")
+
+	tests := []struct {
+		title string
+		goal  Term
+		want  Term
+	}{
+		{title: "atom", goal: foo, want: atomColon.Apply(m, foo)},
+		{title: "cut", goal: atomCut, want: atomCut},
+		{title: "qualified", goal: atomColon.Apply(NewAtom("n"), foo), want: atomColon.Apply(NewAtom("n"), foo)},
+		{title: "control", goal: atomComma.Apply(foo, atomSemiColon.Apply(bar, atomCut)), want: atomComma.Apply(
+			atomColon.Apply(m, foo),
+			atomSemiColon.Apply(atomColon.Apply(m, bar), atomCut),
+		)},
+		{title: "meta", goal: NewAtom("findall").Apply(Integer(1), foo, bar), want: atomColon.Apply(m, NewAtom("findall").Apply(Integer(1), atomColon.Apply(m, foo), bar))},
+		{title: "not callable", goal: Integer(1), want: Integer(1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.Equal(t, tt.want, qualifyGoal(m, tt.goal, nil))
+		})
+	}
+}
+
+func TestVM_Arrive_qualified(t *testing.T) {
+	var vm VM
+	vm.Register1(NewAtom("call"), Call)
+	assert.NoError(t, vm.Compile(context.Background(), `
+foo(user).
+:(m, foo(m)).
+:-(:(m, bar(X)), foo(X)).
+`))
+
+	var got []Term
+	vm.Register1(NewAtom("got"), func(_ *VM, t Term, k Cont, env *Env) *Promise {
+		got = append(got, env.Resolve(t))
+		return k(env)
+	})
+
+	x := NewVariable()
+	for _, g := range []Term{
+		NewAtom("foo").Apply(x),
+		atomColon.Apply(NewAtom("m"), NewAtom("foo").Apply(x)),
+		atomColon.Apply(NewAtom("m"), NewAtom("bar").Apply(x)),
+		atomColon.Apply(NewAtom("m"), NewAtom("call").Apply(NewAtom("foo").Apply(x))),
+		atomColon.Apply(NewAtom("n"), NewAtom("foo").Apply(x)),
+	} {
+		ok, err := Call(&vm, atomComma.Apply(g, NewAtom("got").Apply(x)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+	assert.Equal(t, []Term{NewAtom("user"), NewAtom("m"), NewAtom("m"), NewAtom("m"), NewAtom("user")}, got)
+
+	_, err := Call(&vm, atomColon.Apply(NewVariable(), NewAtom("foo").Apply(x)), Success, nil).Force(context.Background())
+	assert.ErrorContains(t, err, "instantiation_error")
+	_, err = Call(&vm, atomColon.Apply(Integer(1), NewAtom("foo").Apply(x)), Success, nil).Force(context.Background())
+	assert.ErrorContains(t, err, "type_error(atom,1)")
+}
+
+func TestVM_Compile_qualifiedClauses(t *testing.T) {
+	var vm VM
+	assert.NoError(t, vm.Compile(context.Background(), `
+:(n, baz(1)).
+`))
+	assert.NoError(t, vm.Compile(context.Background(), `
+:(user, foo(1)).
+foo(2).
+:(n, baz(2)).
+`))
+	assert.NoError(t, vm.Compile(context.Background(), `
+:-(module(m, [])).
+:(m, bar(1)).
+bar(2).
+`))
+
+	for _, tt := range []struct {
+		goal Term
+		want []Term
+	}{
+		{goal: NewAtom("foo"), want: []Term{Integer(1), Integer(2)}},
+		{goal: atomColon.Apply(NewAtom("m"), NewAtom("bar")), want: []Term{Integer(1), Integer(2)}},
+		{goal: atomColon.Apply(NewAtom("n"), NewAtom("baz")), want: []Term{Integer(1), Integer(2)}},
+	} {
+		var got []Term
+		x := NewVariable()
+		g := tt.goal
+		if q, ok := g.(Compound); ok {
+			g = atomColon.Apply(q.Arg(0), q.Arg(1).(Atom).Apply(x))
+		} else {
+			g = g.(Atom).Apply(x)
+		}
+		_, err := Call(&vm, g, func(env *Env) *Promise {
+			got = append(got, env.Resolve(x))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestVM_Compile_module(t *testing.T) {
+	t.Run("not first", func(t *testing.T) {
+		var vm VM
+		err := vm.Compile(context.Background(), `
+foo(1, a).
+:-(module(m, [])).
+`)
+		assert.Equal(t, permissionError(operationCreate, permissionTypeModule, NewAtom("m"), nil), err)
+		_, ok := vm.getModule(NewAtom("m"))
+		assert.False(t, ok)
+	})
+
+	t.Run("twice", func(t *testing.T) {
+		var vm VM
+		err := vm.Compile(context.Background(), `
+:-(module(m, [])).
+:-(module(n, [])).
+`)
+		assert.Equal(t, permissionError(operationCreate, permissionTypeModule, NewAtom("n"), nil), err)
+		_, ok := vm.getModule(NewAtom("n"))
+		assert.False(t, ok)
+	})
+}
+
+func TestVM_moduleOfFile(t *testing.T) {
+	var vm VM
+	vm.FS = fstest.MapFS{
+		"a.pl": {Data: []byte(":-(module(m, [])).\n")},
+		"b.pl": {Data: []byte(":-(module(n, [])).\n")},
+		"c.pl": {Data: []byte(":-(module(m, [])).\n")},
+	}
+	for _, f := range []string{"a", "b"} {
+		_, err := vm.loadFile(context.Background(), NewAtom(f), loadAlways, false, nil)
+		assert.NoError(t, err)
+	}
+	n, m := vm.moduleOfFile("a.pl")
+	assert.Equal(t, NewAtom("m"), n)
+	assert.NotNil(t, m)
+	n, _ = vm.moduleOfFile("b.pl")
+	assert.Equal(t, NewAtom("n"), n)
+
+	_, err := vm.loadFile(context.Background(), NewAtom("c"), loadAlways, false, nil)
+	assert.NoError(t, err)
+	_, m = vm.moduleOfFile("a.pl")
+	assert.Nil(t, m)
+	n, _ = vm.moduleOfFile("c.pl")
+	assert.Equal(t, NewAtom("m"), n)
+}
+
+func TestVM_loadFile_reloadModule(t *testing.T) {
+	fs := fstest.MapFS{
+		"m.pl": {Data: []byte(":-(module(m, [/(foo, 0), /(bar, 0)])).\nfoo.\nbar.\n")},
+	}
+	var vm VM
+	vm.FS = fs
+	ok, err := UseModule(&vm, NewAtom("m"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	fs["m.pl"] = &fstest.MapFile{Data: []byte(":-(module(m, [/(foo, 0)])).\nfoo.\nbar.\n")}
+	_, err = vm.loadFile(context.Background(), NewAtom("m"), loadAlways, false, nil)
+	assert.NoError(t, err)
+
+	ok, err = Call(&vm, NewAtom("foo"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, ok = vm.imports[atomUser][procedureIndicator{name: NewAtom("bar"), arity: 0}]
+	assert.False(t, ok)
+}
@@ -1,9 +1,12 @@
 package engine
 
 import (
+	"cmp"
 	"errors"
 	"github.com/cockroachdb/apd/v3"
 	"math"
+	"math/big"
+	"math/bits"
 )
 
 var (
@@ -21,6 +24,14 @@ var constants = map[Atom]Number{
 		}
 		return f
 	}(),
+	atomSmallE: func() Number {
+		f, err := NewFloatFromString("2.71828182845904523536028747135266249775724709369995957496696763")
+		// should not occur
+		if err != nil {
+			panic(err)
+		}
+		return f
+	}(),
 }
 
 var unaryFunctors = map[Atom]func(Number) (Number, error){
@@ -39,6 +50,8 @@ var unaryFunctors = map[Atom]func(Number) (Number, error){
 	atomSqrt:                sqrt,
 	atomBackSlash:           bitwiseComplement,
 	atomPlus:                pos,
+	atomMsb:                 msb,
+	atomAtan:                atan,
 }
 
 var binaryFunctors = map[Atom]func(Number, Number) (Number, error){
@@ -56,18 +69,23 @@ var binaryFunctors = map[Atom]func(Number, Number) (Number, error){
 	atomBitwiseOr:         bitwiseOr,
 	atomDiv:               intFloorDiv,
 	atomMax:               max,
+	atomRdiv:              rdiv,
 	atomMin:               min,
 	atomCaret:             integerPower,
 	atomXor:               xor,
+	atomGcd:               gcd,
+	atomCopysign:          copysign,
+	atomAtan:              atan2,
+	atomAtan2:             atan2,
 }
 
-// Number is a prolog number, either Integer or Float.
+// Number is a prolog number, either Integer, Float, or Rational.
 type Number interface {
 	Term
 	number()
 }
 
-func eval(expression Term, env *Env) (_ Number, err error) {
+func eval(vm *VM, expression Term, env *Env) (_ Number, err error) {
 	defer func() {
 		var ev exceptionalValue
 		if errors.As(err, &ev) {
@@ -92,24 +110,33 @@ func eval(expression Term, env *Env) (_ Number, err error) {
 		switch arity := t.Arity(); arity {
 		case 1:
 			f, ok := unaryFunctors[t.Functor()]
+			if !ok {
+				f, ok = vm.unaryFunction(t.Functor(), env)
+			}
 			if !ok {
 				return nil, typeError(validTypeEvaluable, atomSlash.Apply(t.Functor(), Integer(1)), env)
 			}
-			x, err := eval(t.Arg(0), env)
+			x, err := eval(vm, t.Arg(0), env)
 			if err != nil {
 				return nil, err
 			}
 			return f(x)
 		case 2:
 			f, ok := binaryFunctors[t.Functor()]
+			if !ok {
+				f, ok = vm.binaryFunction(t.Functor(), env)
+			}
 			if !ok {
 				return nil, typeError(validTypeEvaluable, atomSlash.Apply(t.Functor(), Integer(2)), env)
 			}
-			x, err := eval(t.Arg(0), env)
+			if t.Functor() == atomSlash && vm != nil && vm.preferRationals {
+				f = divPreferringRationals
+			}
+			x, err := eval(vm, t.Arg(0), env)
 			if err != nil {
 				return nil, err
 			}
-			y, err := eval(t.Arg(1), env)
+			y, err := eval(vm, t.Arg(1), env)
 			if err != nil {
 				return nil, err
 			}
@@ -124,7 +151,7 @@ func eval(expression Term, env *Env) (_ Number, err error) {
 
 // Is evaluates expression and unifies the result with result.
 func Is(vm *VM, result, expression Term, k Cont, env *Env) *Promise {
-	v, err := eval(expression, env)
+	v, err := eval(vm, expression, env)
 	if err != nil {
 		return Error(err)
 	}
@@ -132,13 +159,13 @@ func Is(vm *VM, result, expression Term, k Cont, env *Env) *Promise {
 }
 
 // Equal succeeds iff e1 equals to e2.
-func Equal(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
-	ev1, err := eval(e1, env)
+func Equal(vm *VM, e1, e2 Term, k Cont, env *Env) *Promise {
+	ev1, err := eval(vm, e1, env)
 	if err != nil {
 		return Error(err)
 	}
 
-	ev2, err := eval(e2, env)
+	ev2, err := eval(vm, e2, env)
 	if err != nil {
 		return Error(err)
 	}
@@ -151,6 +178,8 @@ func Equal(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 			ok = eqI(ev1, ev2)
 		case Float:
 			ok = eqIF(ev1, ev2)
+		case Rational:
+			ok = cmpR(ev1, ev2) == 0
 		}
 	case Float:
 		switch ev2 := ev2.(type) {
@@ -158,6 +187,13 @@ func Equal(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 			ok = eqFI(ev1, ev2)
 		case Float:
 			ok = eqF(ev1, ev2)
+		case Rational:
+			ok = cmpR(ev1, ev2) == 0
+		}
+	case Rational:
+		switch ev2.(type) {
+		case Integer, Float, Rational:
+			ok = cmpR(ev1, ev2) == 0
 		}
 	}
 	if !ok {
@@ -167,13 +203,13 @@ func Equal(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 }
 
 // NotEqual succeeds iff e1 doesn't equal to e2.
-func NotEqual(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
-	ev1, err := eval(e1, env)
+func NotEqual(vm *VM, e1, e2 Term, k Cont, env *Env) *Promise {
+	ev1, err := eval(vm, e1, env)
 	if err != nil {
 		return Error(err)
 	}
 
-	ev2, err := eval(e2, env)
+	ev2, err := eval(vm, e2, env)
 	if err != nil {
 		return Error(err)
 	}
@@ -186,6 +222,8 @@ func NotEqual(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 			ok = neqI(ev1, ev2)
 		case Float:
 			ok = neqIF(ev1, ev2)
+		case Rational:
+			ok = cmpR(ev1, ev2) != 0
 		}
 	case Float:
 		switch ev2 := ev2.(type) {
@@ -193,6 +231,13 @@ func NotEqual(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 			ok = neqFI(ev1, ev2)
 		case Float:
 			ok = neqF(ev1, ev2)
+		case Rational:
+			ok = cmpR(ev1, ev2) != 0
+		}
+	case Rational:
+		switch ev2.(type) {
+		case Integer, Float, Rational:
+			ok = cmpR(ev1, ev2) != 0
 		}
 	}
 	if !ok {
@@ -202,13 +247,13 @@ func NotEqual(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 }
 
 // LessThan succeeds iff e1 is less than e2.
-func LessThan(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
-	ev1, err := eval(e1, env)
+func LessThan(vm *VM, e1, e2 Term, k Cont, env *Env) *Promise {
+	ev1, err := eval(vm, e1, env)
 	if err != nil {
 		return Error(err)
 	}
 
-	ev2, err := eval(e2, env)
+	ev2, err := eval(vm, e2, env)
 	if err != nil {
 		return Error(err)
 	}
@@ -221,6 +266,8 @@ func LessThan(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 			ok = lssI(ev1, ev2)
 		case Float:
 			ok = lssIF(ev1, ev2)
+		case Rational:
+			ok = cmpR(ev1, ev2) < 0
 		}
 	case Float:
 		switch ev2 := ev2.(type) {
@@ -228,6 +275,13 @@ func LessThan(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 			ok = lssFI(ev1, ev2)
 		case Float:
 			ok = lssF(ev1, ev2)
+		case Rational:
+			ok = cmpR(ev1, ev2) < 0
+		}
+	case Rational:
+		switch ev2.(type) {
+		case Integer, Float, Rational:
+			ok = cmpR(ev1, ev2) < 0
 		}
 	}
 	if !ok {
@@ -237,13 +291,13 @@ func LessThan(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 }
 
 // GreaterThan succeeds iff e1 is greater than e2.
-func GreaterThan(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
-	ev1, err := eval(e1, env)
+func GreaterThan(vm *VM, e1, e2 Term, k Cont, env *Env) *Promise {
+	ev1, err := eval(vm, e1, env)
 	if err != nil {
 		return Error(err)
 	}
 
-	ev2, err := eval(e2, env)
+	ev2, err := eval(vm, e2, env)
 	if err != nil {
 		return Error(err)
 	}
@@ -256,6 +310,8 @@ func GreaterThan(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 			ok = gtrI(ev1, ev2)
 		case Float:
 			ok = gtrIF(ev1, ev2)
+		case Rational:
+			ok = cmpR(ev1, ev2) > 0
 		}
 	case Float:
 		switch ev2 := ev2.(type) {
@@ -263,6 +319,13 @@ func GreaterThan(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 			ok = gtrFI(ev1, ev2)
 		case Float:
 			ok = gtrF(ev1, ev2)
+		case Rational:
+			ok = cmpR(ev1, ev2) > 0
+		}
+	case Rational:
+		switch ev2.(type) {
+		case Integer, Float, Rational:
+			ok = cmpR(ev1, ev2) > 0
 		}
 	}
 	if !ok {
@@ -272,13 +335,13 @@ func GreaterThan(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 }
 
 // LessThanOrEqual succeeds iff e1 is less than or equal to e2.
-func LessThanOrEqual(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
-	ev1, err := eval(e1, env)
+func LessThanOrEqual(vm *VM, e1, e2 Term, k Cont, env *Env) *Promise {
+	ev1, err := eval(vm, e1, env)
 	if err != nil {
 		return Error(err)
 	}
 
-	ev2, err := eval(e2, env)
+	ev2, err := eval(vm, e2, env)
 	if err != nil {
 		return Error(err)
 	}
@@ -291,6 +354,8 @@ func LessThanOrEqual(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 			ok = leqI(ev1, ev2)
 		case Float:
 			ok = leqIF(ev1, ev2)
+		case Rational:
+			ok = cmpR(ev1, ev2) <= 0
 		}
 	case Float:
 		switch ev2 := ev2.(type) {
@@ -298,6 +363,13 @@ func LessThanOrEqual(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 			ok = leqFI(ev1, ev2)
 		case Float:
 			ok = leqF(ev1, ev2)
+		case Rational:
+			ok = cmpR(ev1, ev2) <= 0
+		}
+	case Rational:
+		switch ev2.(type) {
+		case Integer, Float, Rational:
+			ok = cmpR(ev1, ev2) <= 0
 		}
 	}
 	if !ok {
@@ -307,13 +379,13 @@ func LessThanOrEqual(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 }
 
 // GreaterThanOrEqual succeeds iff e1 is greater than or equal to e2.
-func GreaterThanOrEqual(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
-	ev1, err := eval(e1, env)
+func GreaterThanOrEqual(vm *VM, e1, e2 Term, k Cont, env *Env) *Promise {
+	ev1, err := eval(vm, e1, env)
 	if err != nil {
 		return Error(err)
 	}
 
-	ev2, err := eval(e2, env)
+	ev2, err := eval(vm, e2, env)
 	if err != nil {
 		return Error(err)
 	}
@@ -326,6 +398,8 @@ func GreaterThanOrEqual(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 			ok = geqI(ev1, ev2)
 		case Float:
 			ok = geqIF(ev1, ev2)
+		case Rational:
+			ok = cmpR(ev1, ev2) >= 0
 		}
 	case Float:
 		switch ev2 := ev2.(type) {
@@ -333,6 +407,13 @@ func GreaterThanOrEqual(_ *VM, e1, e2 Term, k Cont, env *Env) *Promise {
 			ok = geqFI(ev1, ev2)
 		case Float:
 			ok = geqF(ev1, ev2)
+		case Rational:
+			ok = cmpR(ev1, ev2) >= 0
+		}
+	case Rational:
+		switch ev2.(type) {
+		case Integer, Float, Rational:
+			ok = cmpR(ev1, ev2) >= 0
 		}
 	}
 	if !ok {
@@ -350,6 +431,8 @@ func add(x, y Number) (Number, error) {
 			return addI(x, y)
 		case Float:
 			return addIF(x, y)
+		case Rational:
+			return addR(x, y)
 		}
 	case Float:
 		switch y := y.(type) {
@@ -357,6 +440,15 @@ func add(x, y Number) (Number, error) {
 			return addFI(x, y)
 		case Float:
 			return addF(x, y)
+		case Rational:
+			return addFR(x, y)
+		}
+	case Rational:
+		switch y := y.(type) {
+		case Integer, Rational:
+			return addR(x, y)
+		case Float:
+			return addRF(x, y)
 		}
 	}
 	return nil, exceptionalValueUndefined
@@ -371,6 +463,8 @@ func sub(x, y Number) (Number, error) {
 			return subI(x, y)
 		case Float:
 			return subIF(x, y)
+		case Rational:
+			return subR(x, y)
 		}
 	case Float:
 		switch y := y.(type) {
@@ -378,6 +472,15 @@ func sub(x, y Number) (Number, error) {
 			return subFI(x, y)
 		case Float:
 			return subF(x, y)
+		case Rational:
+			return subFR(x, y)
+		}
+	case Rational:
+		switch y := y.(type) {
+		case Integer, Rational:
+			return subR(x, y)
+		case Float:
+			return subRF(x, y)
 		}
 	}
 	return nil, exceptionalValueUndefined
@@ -392,6 +495,8 @@ func mul(x, y Number) (Number, error) {
 			return mulI(x, y)
 		case Float:
 			return mulIF(x, y)
+		case Rational:
+			return mulR(x, y)
 		}
 	case Float:
 		switch y := y.(type) {
@@ -399,6 +504,15 @@ func mul(x, y Number) (Number, error) {
 			return mulFI(x, y)
 		case Float:
 			return mulF(x, y)
+		case Rational:
+			return mulFR(x, y)
+		}
+	case Rational:
+		switch y := y.(type) {
+		case Integer, Rational:
+			return mulR(x, y)
+		case Float:
+			return mulRF(x, y)
 		}
 	}
 	return nil, exceptionalValueUndefined
@@ -428,6 +542,8 @@ func div(x, y Number) (Number, error) {
 			return divII(x, y)
 		case Float:
 			return divIF(x, y)
+		case Rational:
+			return divR(x, y)
 		}
 	case Float:
 		switch y := y.(type) {
@@ -435,6 +551,15 @@ func div(x, y Number) (Number, error) {
 			return divFI(x, y)
 		case Float:
 			return divF(x, y)
+		case Rational:
+			return divFR(x, y)
+		}
+	case Rational:
+		switch y := y.(type) {
+		case Integer, Rational:
+			return divR(x, y)
+		case Float:
+			return divRF(x, y)
 		}
 	}
 	return nil, exceptionalValueUndefined
@@ -477,6 +602,8 @@ func neg(x Number) (Number, error) {
 		return negI(x)
 	case Float:
 		return negF(x)
+	case Rational:
+		return negR(x)
 	default:
 		return nil, exceptionalValueUndefined
 	}
@@ -489,6 +616,8 @@ func abs(x Number) (Number, error) {
 		return absI(x)
 	case Float:
 		return absF(x), nil
+	case Rational:
+		return absR(x)
 	default:
 		return nil, exceptionalValueUndefined
 	}
@@ -501,6 +630,8 @@ func sign(x Number) (Number, error) {
 		return signI(x), nil
 	case Float:
 		return signF(x), nil
+	case Rational:
+		return signR(x), nil
 	default:
 		return nil, exceptionalValueUndefined
 	}
@@ -533,6 +664,8 @@ func asFloat(x Number) (Number, error) {
 		return floatItoF(x), nil
 	case Float:
 		return floatFtoF(x), nil
+	case Rational:
+		return floatRtoF(x)
 	default:
 		return nil, exceptionalValueUndefined
 	}
@@ -543,6 +676,8 @@ func floor(x Number) (Number, error) {
 	switch x := x.(type) {
 	case Float:
 		return floorFtoI(x)
+	case Rational:
+		return floorRtoI(x), nil
 	default:
 		return nil, typeError(validTypeFloat, x, nil)
 	}
@@ -553,6 +688,8 @@ func truncate(x Number) (Number, error) {
 	switch x := x.(type) {
 	case Float:
 		return truncateFtoI(x)
+	case Rational:
+		return truncateRtoI(x), nil
 	default:
 		return nil, typeError(validTypeFloat, x, nil)
 	}
@@ -563,6 +700,8 @@ func round(x Number) (Number, error) {
 	switch x := x.(type) {
 	case Float:
 		return roundFtoI(x)
+	case Rational:
+		return roundRtoI(x), nil
 	default:
 		return nil, typeError(validTypeFloat, x, nil)
 	}
@@ -573,6 +712,8 @@ func ceiling(x Number) (Number, error) {
 	switch x := x.(type) {
 	case Float:
 		return ceilingFtoI(x)
+	case Rational:
+		return ceilingRtoI(x), nil
 	default:
 		return nil, typeError(validTypeFloat, x, nil)
 	}
@@ -660,13 +801,113 @@ func sqrt(x Number) (Number, error) {
 	return Float{dec: &dec}, nil
 }
 
+// atan returns the arc tangent of x.
+func atan(x Number) (Number, error) {
+	return atan2(x, Integer(1))
+}
+
+// atan2 returns the arc tangent of y/x in the range [-pi, pi] using the signs of both to determine the quadrant.
+func atan2(y, x Number) (Number, error) {
+	vy, err := numberToFloat(y)
+	if err != nil {
+		return nil, err
+	}
+
+	vx, err := numberToFloat(x)
+	if err != nil {
+		return nil, err
+	}
+
+	if vx.Zero() && vy.Zero() {
+		return nil, exceptionalValueUndefined
+	}
+
+	ed := apd.MakeErrDecimal(atanCtx)
+	var dec apd.Decimal
+	switch {
+	case vx.Zero():
+		ed.Quo(&dec, piDec, apd.New(2, 0))
+		dec.Negative = vy.Negative()
+	default:
+		atanDec(&ed, &dec, ed.Quo(&dec, vy.dec, vx.dec))
+		if vx.Negative() {
+			if vy.Negative() {
+				ed.Sub(&dec, &dec, piDec)
+			} else {
+				ed.Add(&dec, &dec, piDec)
+			}
+		}
+	}
+	ed.Ctx = &decimal128Ctx
+	ed.Round(&dec, &dec)
+	if ed.Err() != nil {
+		return nil, decimalConditionAsErr(ed.Flags)
+	}
+
+	return Float{dec: &dec}, nil
+}
+
+// atanCtx is the context of the intermediate computations of atan2 which need some guard digits.
+var atanCtx = decimal128Ctx.WithPrecision(decimal128Ctx.Precision + 16)
+
+// piDec is pi with the precision of atanCtx.
+var piDec, _, _ = apd.NewFromString("3.14159265358979323846264338327950288419716939937510582097494459")
+
+// atanDec sets d to the arc tangent of x and returns d.
+func atanDec(ed *apd.ErrDecimal, d, x *apd.Decimal) *apd.Decimal {
+	negative := x.Negative
+	var r apd.Decimal
+	r.Abs(x)
+	one := apd.New(1, 0)
+
+	// atan(x) = pi/2 - atan(1/x) for x > 1.
+	inverted := r.Cmp(one) > 0
+	if inverted {
+		ed.Quo(&r, one, &r)
+	}
+
+	// atan(x) = 2*atan(x/(1+sqrt(1+x^2))) until x is small enough for the Taylor series to converge quickly.
+	var halvings int64
+	var t apd.Decimal
+	for threshold := apd.New(1, -2); r.Cmp(threshold) > 0; halvings++ {
+		ed.Mul(&t, &r, &r)
+		ed.Add(&t, &t, one)
+		ed.Sqrt(&t, &t)
+		ed.Add(&t, &t, one)
+		ed.Quo(&r, &r, &t)
+	}
+
+	// atan(x) = x - x^3/3 + x^5/5 - ...
+	var sum, pow, x2, term apd.Decimal
+	sum.Set(&r)
+	pow.Set(&r)
+	ed.Mul(&x2, &r, &r)
+	epsilon := apd.New(1, -int32(atanCtx.Precision)-4)
+	for n := int64(3); ed.Err() == nil; n += 2 {
+		ed.Neg(&pow, ed.Mul(&pow, &pow, &x2))
+		ed.Quo(&term, &pow, apd.New(n, 0))
+		if t.Abs(&term).Cmp(epsilon) < 0 {
+			break
+		}
+		ed.Add(&sum, &sum, &term)
+	}
+	ed.Mul(&sum, &sum, apd.New(1<<halvings, 0))
+
+	if inverted {
+		ed.Sub(&sum, ed.Quo(&t, piDec, apd.New(2, 0)), &sum)
+	}
+	d.Set(&sum)
+	d.Negative = negative && !d.IsZero()
+	return d
+}
+
 // bitwiseRightShift returns n bit-shifted by s to the right.
 func bitwiseRightShift(n, s Number) (Number, error) {
 	switch n := n.(type) {
 	case Integer:
 		switch s := s.(type) {
 		case Integer:
-			return Integer(n >> s), nil
+			return shrI(n, s)
 		default:
 			return nil, typeError(validTypeInteger, s, nil)
 		}
@@ -681,7 +922,7 @@ func bitwiseLeftShift(n, s Number) (Number, error) {
 	case Integer:
 		switch s := s.(type) {
 		case Integer:
-			return Integer(n << s), nil
+			return shlI(n, s)
 		default:
 			return nil, typeError(validTypeInteger, s, nil)
 		}
@@ -737,6 +978,8 @@ func pos(x Number) (Number, error) {
 		return posI(x)
 	case Float:
 		return posF(x)
+	case Rational:
+		return x, nil
 	default:
 		return nil, exceptionalValueUndefined
 	}
@@ -772,6 +1015,11 @@ func max(x, y Number) (Number, error) {
 				return y, nil
 			}
 			return x, nil
+		case Rational:
+			if cmpR(x, y) < 0 {
+				return y, nil
+			}
+			return x, nil
 		default:
 			return nil, exceptionalValueUndefined
 		}
@@ -787,6 +1035,21 @@ func max(x, y Number) (Number, error) {
 				return y, nil
 			}
 			return x, nil
+		case Rational:
+			if cmpR(x, y) < 0 {
+				return y, nil
+			}
+			return x, nil
+		default:
+			return nil, exceptionalValueUndefined
+		}
+	case Rational:
+		switch y.(type) {
+		case Integer, Float, Rational:
+			if cmpR(x, y) < 0 {
+				return y, nil
+			}
+			return x, nil
 		default:
 			return nil, exceptionalValueUndefined
 		}
@@ -810,6 +1073,11 @@ func min(x, y Number) (Number, error) {
 				return y, nil
 			}
 			return x, nil
+		case Rational:
+			if cmpR(x, y) > 0 {
+				return y, nil
+			}
+			return x, nil
 		default:
 			return nil, exceptionalValueUndefined
 		}
@@ -825,6 +1093,21 @@ func min(x, y Number) (Number, error) {
 				return y, nil
 			}
 			return x, nil
+		case Rational:
+			if cmpR(x, y) > 0 {
+				return y, nil
+			}
+			return x, nil
+		default:
+			return nil, exceptionalValueUndefined
+		}
+	case Rational:
+		switch y.(type) {
+		case Integer, Float, Rational:
+			if cmpR(x, y) > 0 {
+				return y, nil
+			}
+			return x, nil
 		default:
 			return nil, exceptionalValueUndefined
 		}
@@ -835,6 +1118,12 @@ func min(x, y Number) (Number, error) {
 
 // integerPower returns x raised to the power of y.
 func integerPower(x, y Number) (Number, error) {
+	if r, ok := x.(Rational); ok {
+		if n, ok := y.(Integer); ok {
+			return powR(r, n)
+		}
+	}
+
 	vx, ok := x.(Integer)
 	if !ok {
 		return power(x, y)
@@ -906,8 +1195,103 @@ func xor(x, y Number) (Number, error) {
 	return vx ^ vy, nil
 }
 
+// gcd returns the greatest common divisor of x and y.
+func gcd(x, y Number) (Number, error) {
+	vx, ok := x.(Integer)
+	if !ok {
+		return nil, typeError(validTypeInteger, x, nil)
+	}
+
+	vy, ok := y.(Integer)
+	if !ok {
+		return nil, typeError(validTypeInteger, y, nil)
+	}
+
+	var r big.Int
+	r.GCD(nil, nil, new(big.Int).Abs(big.NewInt(int64(vx))), new(big.Int).Abs(big.NewInt(int64(vy))))
+	if !r.IsInt64() {
+		return nil, exceptionalValueIntOverflow
+	}
+	return Integer(r.Int64()), nil
+}
+
+// msb returns the position of the most significant bit of a positive integer x.
+func msb(x Number) (Number, error) {
+	vx, ok := x.(Integer)
+	if !ok {
+		return nil, typeError(validTypeInteger, x, nil)
+	}
+
+	if vx <= 0 {
+		return nil, exceptionalValueUndefined
+	}
+
+	return Integer(63 - bits.LeadingZeros64(uint64(vx))), nil
+}
+
+// copysign returns a float with the magnitude of x and the sign of y.
+func copysign(x, y Number) (Number, error) {
+	vx, err := numberToFloat(x)
+	if err != nil {
+		return nil, err
+	}
+
+	vy, err := numberToFloat(y)
+	if err != nil {
+		return nil, err
+	}
+
+	var dec apd.Decimal
+	dec.Abs(vx.dec)
+	dec.Negative = vy.dec.Negative
+	return Float{dec: &dec}, nil
+}
+
 // Comparison
 
+// compareNumbers compares x and y in the standard order of terms. Numbers are ordered by their exact values, so that
+// no conversion loses precision. The numbers of the same value are ordered by type: Float < Integer < Rational.
+func compareNumbers(x, y Number) int {
+	var c int
+	switch x := x.(type) {
+	case Integer:
+		switch y := y.(type) {
+		case Integer:
+			c = cmp.Compare(x, y)
+		case Float:
+			c = floatItoF(x).dec.Cmp(y.dec)
+		default:
+			c = cmpR(x, y)
+		}
+	case Float:
+		switch y := y.(type) {
+		case Integer:
+			c = x.dec.Cmp(floatItoF(y).dec)
+		case Float:
+			c = x.dec.Cmp(y.dec)
+		default:
+			c = cmpR(x, y)
+		}
+	default:
+		c = cmpR(x, y)
+	}
+	if c != 0 {
+		return c
+	}
+	return cmp.Compare(numberRank(x), numberRank(y))
+}
+
+func numberRank(x Number) int {
+	switch x.(type) {
+	case Float:
+		return 0
+	case Integer:
+		return 1
+	default:
+		return 2
+	}
+}
+
 func eqF(x, y Float) bool {
 	return x.Eq(y)
 }
@@ -1175,6 +1559,37 @@ func negI(x Integer) (Integer, error) {
 	return -x, nil
 }
 
+// shlI returns x shifted by s to the left, or to the right if s is negative.
+func shlI(x, s Integer) (Integer, error) {
+	switch {
+	case s < 0:
+		if s < -64 {
+			s = -64
+		}
+		return x >> -s, nil
+	case x == 0:
+		return 0, nil
+	case s >= 64:
+		return 0, exceptionalValueIntOverflow
+	}
+	r := x << s
+	if r>>s != x {
+		return 0, exceptionalValueIntOverflow
+	}
+	return r, nil
+}
+
+// shrI returns x arithmetically shifted by s to the right, or to the left if s is negative.
+func shrI(x, s Integer) (Integer, error) {
+	if s < 0 {
+		if s < -64 {
+			s = -64
+		}
+		return shlI(x, -s)
+	}
+	return x >> s, nil
+}
+
 func absI(x Integer) (Integer, error) {
 	switch {
 	case x == minInt:
@@ -1345,6 +1760,8 @@ func numberToFloat(x Number) (Float, error) {
 		return NewFloatFromInt64(int64(x)), nil
 	case Float:
 		return x, nil
+	case Rational:
+		return floatRtoF(x)
 	default:
 		return Float{}, exceptionalValueUndefined
 	}
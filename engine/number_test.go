@@ -52,6 +52,7 @@ func TestIs(t *testing.T) {
 		{title: "float", result: NewFloatFromInt64(1), expression: NewFloatFromInt64(1), ok: true},
 
 		{title: "pi", result: pi, expression: atomPi, ok: true},
+		{title: "e", result: newFloatFromStringMust("2.718281828459045235360287471352662"), expression: atomSmallE, ok: true},
 
 		{title: "1 + 1", result: Integer(2), expression: atomPlus.Apply(Integer(1), Integer(1)), ok: true},
 		{title: "maxInt + 1", expression: atomPlus.Apply(Integer(math.MaxInt64), Integer(1)), err: evaluationError(exceptionalValueIntOverflow, nil)},
@@ -96,6 +97,35 @@ func TestIs(t *testing.T) {
 		{title: "1 div mock", expression: atomSlash.Apply(Integer(1), &mockNumber{}), err: evaluationError(exceptionalValueUndefined, nil)},
 		{title: "mock div 1", expression: atomSlash.Apply(&mockNumber{}, Integer(1)), err: evaluationError(exceptionalValueUndefined, nil)},
 
+		{title: "1 rdiv 3", result: mustRational(1, 3), expression: atomRdiv.Apply(Integer(1), Integer(3)), ok: true},
+		{title: "4 rdiv 2", result: Integer(2), expression: atomRdiv.Apply(Integer(4), Integer(2)), ok: true},
+		{title: "1r3 rdiv 2", result: mustRational(1, 6), expression: atomRdiv.Apply(mustRational(1, 3), Integer(2)), ok: true},
+		{title: "1 rdiv 0", expression: atomRdiv.Apply(Integer(1), Integer(0)), err: evaluationError(exceptionalValueZeroDivisor, nil)},
+		{title: "1.0 rdiv 3", expression: atomRdiv.Apply(NewFloatFromInt64(1), Integer(3)), err: typeError(validTypeRational, NewFloatFromInt64(1), nil)},
+		{title: "1 rdiv 3.0", expression: atomRdiv.Apply(Integer(1), NewFloatFromInt64(3)), err: typeError(validTypeRational, NewFloatFromInt64(3), nil)},
+		{title: "1r3 + 2r3", result: Integer(1), expression: atomPlus.Apply(mustRational(1, 3), mustRational(2, 3)), ok: true},
+		{title: "1r3 + 1", result: mustRational(4, 3), expression: atomPlus.Apply(mustRational(1, 3), Integer(1)), ok: true},
+		{title: "1 - 1r3", result: mustRational(2, 3), expression: atomMinus.Apply(Integer(1), mustRational(1, 3)), ok: true},
+		{title: "1r3 * 3", result: Integer(1), expression: atomAsterisk.Apply(mustRational(1, 3), Integer(3)), ok: true},
+		{title: "1r2 + 0.25", result: newFloatFromStringMust("0.75"), expression: atomPlus.Apply(mustRational(1, 2), newFloatFromStringMust("0.25")), ok: true},
+		{title: "0.5 * 1r2", result: newFloatFromStringMust("0.25"), expression: atomAsterisk.Apply(newFloatFromStringMust("0.5"), mustRational(1, 2)), ok: true},
+		{title: "1r2 / 1r4", result: Integer(2), expression: atomSlash.Apply(mustRational(1, 2), mustRational(1, 4)), ok: true},
+		{title: "maxInt rdiv 2 + maxInt rdiv 3", expression: atomPlus.Apply(mustRational(math.MaxInt64, 2), mustRational(math.MaxInt64, 3)), err: evaluationError(exceptionalValueIntOverflow, nil)},
+		{title: "- 1r3", result: mustRational(-1, 3), expression: atomMinus.Apply(mustRational(1, 3)), ok: true},
+		{title: "abs(-1r3)", result: mustRational(1, 3), expression: atomAbs.Apply(mustRational(-1, 3)), ok: true},
+		{title: "sign(-1r3)", result: Integer(-1), expression: atomSign.Apply(mustRational(-1, 3)), ok: true},
+		{title: "float(1r4)", result: newFloatFromStringMust("0.25"), expression: atomFloat.Apply(mustRational(1, 4)), ok: true},
+		{title: "floor(-5r2)", result: Integer(-3), expression: atomFloor.Apply(mustRational(-5, 2)), ok: true},
+		{title: "truncate(-5r2)", result: Integer(-2), expression: atomTruncate.Apply(mustRational(-5, 2)), ok: true},
+		{title: "ceiling(5r2)", result: Integer(3), expression: atomCeiling.Apply(mustRational(5, 2)), ok: true},
+		{title: "round(5r2)", result: Integer(3), expression: atomRound.Apply(mustRational(5, 2)), ok: true},
+		{title: "round(-5r2)", result: Integer(-3), expression: atomRound.Apply(mustRational(-5, 2)), ok: true},
+		{title: "round(7r3)", result: Integer(2), expression: atomRound.Apply(mustRational(7, 3)), ok: true},
+		{title: "2r3 ^ 2", result: mustRational(4, 9), expression: atomCaret.Apply(mustRational(2, 3), Integer(2)), ok: true},
+		{title: "2r3 ^ -2", result: mustRational(9, 4), expression: atomCaret.Apply(mustRational(2, 3), Integer(-2)), ok: true},
+		{title: "max(1r3, 1r2)", result: mustRational(1, 2), expression: atomMax.Apply(mustRational(1, 3), mustRational(1, 2)), ok: true},
+		{title: "min(1r3, 0.5)", result: mustRational(1, 3), expression: atomMin.Apply(mustRational(1, 3), newFloatFromStringMust("0.5")), ok: true},
+
 		{title: "1 rem 1", result: Integer(0), expression: atomRem.Apply(Integer(1), Integer(1)), ok: true},
 		{title: "1 rem 0", expression: atomRem.Apply(Integer(1), Integer(0)), err: evaluationError(exceptionalValueZeroDivisor, nil)},
 		{title: "1.0 rem 1", expression: atomRem.Apply(NewFloatFromInt64(1), Integer(1)), err: typeError(validTypeInteger, NewFloatFromInt64(1), nil)},
@@ -249,7 +279,19 @@ func TestIs(t *testing.T) {
 		{title: "16 >> 2.0", expression: atomBitwiseRightShift.Apply(Integer(16), NewFloatFromInt64(2)), err: typeError(validTypeInteger, NewFloatFromInt64(2), nil)},
 		{title: "16.0 >> 2", expression: atomBitwiseRightShift.Apply(NewFloatFromInt64(16), Integer(2)), err: typeError(validTypeInteger, NewFloatFromInt64(16), nil)},
 
+		{title: "16 >> -2", result: Integer(64), expression: atomBitwiseRightShift.Apply(Integer(16), Integer(-2)), ok: true},
+		{title: "-16 >> 70", result: Integer(-1), expression: atomBitwiseRightShift.Apply(Integer(-16), Integer(70)), ok: true},
+		{title: "1 >> -63", expression: atomBitwiseRightShift.Apply(Integer(1), Integer(-63)), err: evaluationError(exceptionalValueIntOverflow, nil)},
+
 		{title: "16 << 2", result: Integer(64), expression: atomBitwiseLeftShift.Apply(Integer(16), Integer(2)), ok: true},
+		{title: "-1 << 63", result: Integer(math.MinInt64), expression: atomBitwiseLeftShift.Apply(Integer(-1), Integer(63)), ok: true},
+		{title: "16 << -2", result: Integer(4), expression: atomBitwiseLeftShift.Apply(Integer(16), Integer(-2)), ok: true},
+		{title: "-16 << minInt", result: Integer(-1), expression: atomBitwiseLeftShift.Apply(Integer(-16), Integer(math.MinInt64)), ok: true},
+		{title: "0 << 100", result: Integer(0), expression: atomBitwiseLeftShift.Apply(Integer(0), Integer(100)), ok: true},
+		{title: "1 << 63", expression: atomBitwiseLeftShift.Apply(Integer(1), Integer(63)), err: evaluationError(exceptionalValueIntOverflow, nil)},
+		{title: "maxInt << 1", expression: atomBitwiseLeftShift.Apply(Integer(math.MaxInt64), Integer(1)), err: evaluationError(exceptionalValueIntOverflow, nil)},
+		{title: "-3 << 62", expression: atomBitwiseLeftShift.Apply(Integer(-3), Integer(62)), err: evaluationError(exceptionalValueIntOverflow, nil)},
+		{title: "1 << 64", expression: atomBitwiseLeftShift.Apply(Integer(1), Integer(64)), err: evaluationError(exceptionalValueIntOverflow, nil)},
 		{title: "16 << 2.0", expression: atomBitwiseLeftShift.Apply(Integer(16), NewFloatFromInt64(2)), err: typeError(validTypeInteger, NewFloatFromInt64(2), nil)},
 		{title: "16.0 << 2", expression: atomBitwiseLeftShift.Apply(NewFloatFromInt64(16), Integer(2)), err: typeError(validTypeInteger, NewFloatFromInt64(16), nil)},
 
@@ -267,6 +309,30 @@ func TestIs(t *testing.T) {
 		{title: "xor(10, 12)", result: Integer(6), expression: atomXor.Apply(Integer(10), Integer(12)), ok: true},
 		{title: "xor(10, 12.0)", expression: atomXor.Apply(Integer(10), NewFloatFromInt64(12)), err: typeError(validTypeInteger, NewFloatFromInt64(12), nil)},
 		{title: "xor(10.0, 12)", expression: atomXor.Apply(NewFloatFromInt64(10), Integer(12)), err: typeError(validTypeInteger, NewFloatFromInt64(10), nil)},
+
+		{title: "gcd(12, -18)", result: Integer(6), expression: atomGcd.Apply(Integer(12), Integer(-18)), ok: true},
+		{title: "gcd(0, 0)", result: Integer(0), expression: atomGcd.Apply(Integer(0), Integer(0)), ok: true},
+		{title: "gcd(min_integer, 0)", expression: atomGcd.Apply(minInt, Integer(0)), err: evaluationError(exceptionalValueIntOverflow, nil)},
+		{title: "gcd(12.0, 18)", expression: atomGcd.Apply(NewFloatFromInt64(12), Integer(18)), err: typeError(validTypeInteger, NewFloatFromInt64(12), nil)},
+		{title: "gcd(12, 18.0)", expression: atomGcd.Apply(Integer(12), NewFloatFromInt64(18)), err: typeError(validTypeInteger, NewFloatFromInt64(18), nil)},
+
+		{title: "msb(1)", result: Integer(0), expression: atomMsb.Apply(Integer(1)), ok: true},
+		{title: "msb(1000)", result: Integer(9), expression: atomMsb.Apply(Integer(1000)), ok: true},
+		{title: "msb(max_integer)", result: Integer(62), expression: atomMsb.Apply(maxInt), ok: true},
+		{title: "msb(0)", expression: atomMsb.Apply(Integer(0)), err: evaluationError(exceptionalValueUndefined, nil)},
+		{title: "msb(1.0)", expression: atomMsb.Apply(NewFloatFromInt64(1)), err: typeError(validTypeInteger, NewFloatFromInt64(1), nil)},
+
+		{title: "copysign(2, -1)", result: NewFloatFromInt64(-2), expression: atomCopysign.Apply(Integer(2), Integer(-1)), ok: true},
+		{title: "copysign(-2.0, 1.0)", result: NewFloatFromInt64(2), expression: atomCopysign.Apply(NewFloatFromInt64(-2), NewFloatFromInt64(1)), ok: true},
+		{title: "copysign(foo, 1)", expression: atomCopysign.Apply(foo, Integer(1)), err: typeError(validTypeEvaluable, atomSlash.Apply(foo, Integer(0)), nil)},
+
+		{title: "atan(1, 1) * 4", result: newFloatFromStringMust("3.141592653589793238462643383279503"), expression: atomAsterisk.Apply(atomAtan.Apply(Integer(1), Integer(1)), Integer(4)), ok: true},
+		{title: "atan2(-1, -1)", result: newFloatFromStringMust("-2.356194490192344928846982537459627"), expression: atomAtan2.Apply(Integer(-1), Integer(-1)), ok: true},
+		{title: "atan(0, -1)", result: newFloatFromStringMust("3.141592653589793238462643383279503"), expression: atomAtan.Apply(Integer(0), Integer(-1)), ok: true},
+		{title: "atan(-1, 0)", result: newFloatFromStringMust("-1.570796326794896619231321691639751"), expression: atomAtan.Apply(Integer(-1), Integer(0)), ok: true},
+		{title: "atan(0, 0)", expression: atomAtan.Apply(Integer(0), Integer(0)), err: evaluationError(exceptionalValueUndefined, nil)},
+		{title: "atan(-0.5)", result: newFloatFromStringMust("-0.4636476090008061162142562314612144"), expression: atomAtan.Apply(newFloatFromStringMust("-0.5")), ok: true},
+		{title: "atan(1.0e10)", result: newFloatFromStringMust("1.570796326694896619231321691640085"), expression: atomAtan.Apply(newFloatFromStringMust("1.0e10")), ok: true},
 	}
 
 	for _, tt := range tests {
@@ -342,6 +408,8 @@ func TestNotEqual(t *testing.T) {
 		{title: `X =\= 1`, e1: x, e2: Integer(1), err: InstantiationError(nil)},
 		{title: `1 =\= X`, e1: Integer(1), e2: x, err: InstantiationError(nil)},
 		{title: `1 =\= 1`, e1: Integer(1), e2: Integer(1), ok: false},
+		{title: `1r2 =\= 0.5`, e1: mustRational(1, 2), e2: newFloatFromStringMust("0.5"), ok: false},
+		{title: `1r3 =\= 1`, e1: mustRational(1, 3), e2: Integer(1), ok: true},
 	}
 
 	for _, tt := range tests {
@@ -369,6 +437,11 @@ func TestLessThan(t *testing.T) {
 		{title: `X < 1`, e1: x, e2: Integer(1), err: InstantiationError(nil)},
 		{title: `1 < X`, e1: Integer(1), e2: x, err: InstantiationError(nil)},
 		{title: `1 < 1`, e1: Integer(1), e2: Integer(1), ok: false},
+		{title: `1r3 < 1r2`, e1: mustRational(1, 3), e2: mustRational(1, 2), ok: true},
+		{title: `1r3 < 1`, e1: mustRational(1, 3), e2: Integer(1), ok: true},
+		{title: `0 < 1r3`, e1: Integer(0), e2: mustRational(1, 3), ok: true},
+		{title: `0.3 < 1r3`, e1: newFloatFromStringMust("0.3"), e2: mustRational(1, 3), ok: true},
+		{title: `1r3 < 0.3`, e1: mustRational(1, 3), e2: newFloatFromStringMust("0.3"), ok: false},
 	}
 
 	for _, tt := range tests {
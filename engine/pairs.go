@@ -0,0 +1,74 @@
+package engine
+
+// PairsKeysValues succeeds if pairs is a list of K-V pairs whose keys are keys and whose values are values.
+func PairsKeysValues(vm *VM, pairs, keys, values Term, k Cont, env *Env) *Promise {
+	n, ok := properListLength(env, pairs, keys, values)
+	switch {
+	case !ok:
+		return Bool(false)
+	case n < 0:
+		return Error(InstantiationError(env))
+	}
+
+	ps, ks, vs := make([]Term, n), make([]Term, n), make([]Term, n)
+	for i := range ps {
+		ks[i], vs[i] = NewVariable(), NewVariable()
+		ps[i] = pair(ks[i], vs[i])
+	}
+	return Unify(vm, tuple(pairs, keys, values), tuple(List(ps...), List(ks...), List(vs...)), k, env)
+}
+
+// PairsKeys succeeds if keys are the keys of the K-V pairs of pairs.
+func PairsKeys(vm *VM, pairs, keys Term, k Cont, env *Env) *Promise {
+	return PairsKeysValues(vm, pairs, keys, NewVariable(), k, env)
+}
+
+// PairsValues succeeds if values are the values of the K-V pairs of pairs.
+func PairsValues(vm *VM, pairs, values Term, k Cont, env *Env) *Promise {
+	return PairsKeysValues(vm, pairs, NewVariable(), values, k, env)
+}
+
+// TransposePairs succeeds if transposed is the V-K pairs flipped from the K-V pairs of pairs and sorted by their keys.
+// The order of the pairs with the same key is kept as keysort/2 does.
+func TransposePairs(vm *VM, pairs, transposed Term, k Cont, env *Env) *Promise {
+	var flipped []Term
+	iter := ListIterator{List: pairs, Env: env}
+	for iter.Next() {
+		switch e := env.Resolve(iter.Current()).(type) {
+		case Variable:
+			return Error(InstantiationError(env))
+		case Compound:
+			if e.Functor() != atomMinus || e.Arity() != 2 {
+				return Error(typeError(validTypePair, e, env))
+			}
+			flipped = append(flipped, pair(e.Arg(1), e.Arg(0)))
+		default:
+			return Error(typeError(validTypePair, e, env))
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return Error(err)
+	}
+
+	return KeySort(vm, List(flipped...), transposed, k, env)
+}
+
+// properListLength returns the length of the first proper list among lists, or -1 if there's none. It returns false if
+// any of lists is neither a proper list nor a partial list.
+func properListLength(env *Env, lists ...Term) (int, bool) {
+	n := -1
+	for _, l := range lists {
+		var m int
+		iter := ListIterator{List: l, Env: env, AllowPartial: true}
+		for iter.Next() {
+			m++
+		}
+		if iter.Err() != nil {
+			return 0, false
+		}
+		if _, ok := iter.Suffix().(Variable); !ok && n < 0 {
+			n = m
+		}
+	}
+	return n, true
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPairsKeysValues(t *testing.T) {
+	a, b, c := NewAtom("a"), NewAtom("b"), NewAtom("c")
+	k, v, ps, ks, vs := NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable()
+
+	tests := []struct {
+		title               string
+		pairs, keys, values Term
+		ok                  bool
+		err                 error
+		env                 map[Variable]Term
+	}{
+		{
+			title: "pairs to keys and values",
+			pairs: List(pair(a, Integer(1)), pair(b, Integer(2))), keys: ks, values: vs,
+			ok: true, env: map[Variable]Term{ks: List(a, b), vs: List(Integer(1), Integer(2))},
+		},
+		{
+			title: "keys and values to pairs",
+			pairs: ps, keys: List(a, b), values: List(Integer(1), Integer(2)),
+			ok: true, env: map[Variable]Term{ps: List(pair(a, Integer(1)), pair(b, Integer(2)))},
+		},
+		{
+			title: "partial pairs",
+			pairs: PartialList(ps, pair(k, v)), keys: List(a, b), values: List(Integer(1), Integer(2)),
+			ok: true, env: map[Variable]Term{k: a, v: Integer(1), ps: List(pair(b, Integer(2)))},
+		},
+		{title: "empty", pairs: List(), keys: ks, values: vs, ok: true, env: map[Variable]Term{ks: List(), vs: List()}},
+		{title: "not a pair", pairs: List(a), keys: ks, values: vs, ok: false},
+		{title: "different lengths", pairs: ps, keys: List(a, b), values: List(c), ok: false},
+		{title: "not a list", pairs: a, keys: ks, values: vs, ok: false},
+		{title: "no proper lists", pairs: ps, keys: PartialList(NewVariable(), a), values: vs, err: InstantiationError(nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			ok, err := PairsKeysValues(nil, tt.pairs, tt.keys, tt.values, func(env *Env) *Promise {
+				for k, v := range tt.env {
+					assert.Equal(t, v, env.simplify(k))
+				}
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestPairsKeys(t *testing.T) {
+	ks := NewVariable()
+	ok, err := PairsKeys(nil, List(pair(NewAtom("a"), Integer(1)), pair(NewAtom("b"), Integer(2))), ks, func(env *Env) *Promise {
+		assert.Equal(t, List(NewAtom("a"), NewAtom("b")), env.simplify(ks))
+		return Bool(true)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = PairsKeys(nil, List(pair(NewAtom("a"), Integer(1))), List(NewAtom("b")), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestPairsValues(t *testing.T) {
+	ok, err := PairsValues(nil, List(pair(NewAtom("a"), Integer(1)), pair(NewAtom("b"), Integer(2))), List(Integer(1), Integer(2)), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = PairsValues(nil, NewVariable(), NewVariable(), Success, nil).Force(context.Background())
+	assert.Equal(t, InstantiationError(nil), err)
+}
+
+func TestTransposePairs(t *testing.T) {
+	a, b, c := NewAtom("a"), NewAtom("b"), NewAtom("c")
+
+	t.Run("ok", func(t *testing.T) {
+		ts := NewVariable()
+		ok, err := TransposePairs(nil, List(pair(a, Integer(2)), pair(b, Integer(1)), pair(c, Integer(2))), ts, func(env *Env) *Promise {
+			assert.Equal(t, List(pair(Integer(1), b), pair(Integer(2), a), pair(Integer(2), c)), env.Resolve(ts))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("pairs is a partial list", func(t *testing.T) {
+		_, err := TransposePairs(nil, PartialList(NewVariable(), pair(a, Integer(1))), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+	})
+
+	t.Run("element is a variable", func(t *testing.T) {
+		_, err := TransposePairs(nil, List(NewVariable()), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+	})
+
+	t.Run("element is not a pair", func(t *testing.T) {
+		_, err := TransposePairs(nil, List(a), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypePair, a, nil), err)
+	})
+}
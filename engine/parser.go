@@ -1,14 +1,17 @@
 package engine
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"io"
 	"math/big"
 	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	orderedmap "github.com/wk8/go-ordered-map/v2"
 )
@@ -36,6 +39,9 @@ type Parser struct {
 	args        []Term
 
 	buf tokenRingBuffer
+
+	spans bool        // whether the parser records the spans of the terms.
+	node  SpannedTerm // the last term parsed along with its span, if spans is set.
 }
 
 // ParsedVariable is a set of information regarding a variable in a parsed term.
@@ -81,6 +87,8 @@ func (p *Parser) termOf(o reflect.Value) (Term, error) {
 			return CodeList(o.String()), nil
 		case doubleQuotesAtom:
 			return NewAtom(o.String()), nil
+		case doubleQuotesString:
+			return String(o.String()), nil
 		default:
 			return CharList(o.String()), nil
 		}
@@ -106,7 +114,7 @@ func (p *Parser) next() (Token, error) {
 		if err != nil {
 			return Token{}, err
 		}
-		p.buf.put(t)
+		p.buf.put(t, p.lexer.start, p.lexer.span)
 	}
 	return p.buf.get(), nil
 }
@@ -126,17 +134,17 @@ func (p *Parser) Term() (Term, error) {
 	case nil:
 		break
 	case errExpectation:
-		return nil, unexpectedTokenError{actual: p.current()}
+		return nil, unexpectedTokenError{actual: p.current(), pos: p.buf.position()}
 	default:
-		return nil, err
+		return nil, p.locate(err)
 	}
 
 	switch t, _ := p.next(); t.kind {
-	case tokenEnd:
+	case TokenEnd:
 		break
 	default:
 		p.backup()
-		return nil, unexpectedTokenError{actual: p.current()}
+		return nil, unexpectedTokenError{actual: p.current(), pos: p.buf.position()}
 	}
 
 	if len(p.args) != 0 {
@@ -146,6 +154,87 @@ func (p *Parser) Term() (Term, error) {
 	return t, nil
 }
 
+// SpannedTerm is a term read by Parser.TermWithPos along with the span of its source text.
+type SpannedTerm struct {
+	Term Term
+	Span SourceSpan
+
+	// Args are the spanned arguments of Term if it's a compound written in the functional, operator, list, curly
+	// bracket, or dict notation. They're in the order of the source text, which may differ from the order of the
+	// arguments of a dict. Args is empty for the other terms, e.g. the lists of double-quoted text.
+	Args []SpannedTerm
+}
+
+// TermWithPos parses a term followed by a full stop like Term and returns it along with the spans of itself and its
+// subterms, e.g. for linters and formatters. The span of a term in parentheses includes the parentheses.
+func (p *Parser) TermWithPos() (SpannedTerm, error) {
+	p.spans = true
+	defer func() {
+		p.spans = false
+		p.node = SpannedTerm{}
+	}()
+	if _, err := p.Term(); err != nil {
+		return SpannedTerm{}, err
+	}
+	return p.node, nil
+}
+
+// peekStart returns the start of the next token if the parser records the spans.
+func (p *Parser) peekStart() int {
+	if !p.spans {
+		return 0
+	}
+	if _, err := p.next(); err != nil {
+		return 0
+	}
+	p.backup()
+	return p.buf.span().Start
+}
+
+// spanned records t, which ends with the last token got, as the last term parsed if the parser records the spans.
+func (p *Parser) spanned(t Term, start int, args ...SpannedTerm) {
+	if !p.spans {
+		return
+	}
+	p.node = SpannedTerm{
+		Term: t,
+		Span: SourceSpan{Start: start, End: p.buf.previousSpan().End},
+		Args: slices.Clone(args),
+	}
+}
+
+// leaf records t, which consists of the tokens from start to the last token got, as the last term parsed and returns
+// it.
+func (p *Parser) leaf(start int, t Term) Term {
+	p.spanned(t, start)
+	return t
+}
+
+// locate returns err with the position of the last token read as its context if it's an error of the parser without
+// context, e.g. an integer which exceeds max_integer.
+func (p *Parser) locate(err error) error {
+	e, ok := err.(Exception)
+	if !ok {
+		return err
+	}
+	c, ok := e.term.(Compound)
+	if !ok || c.Functor() != atomError || c.Arity() != 2 || c.Arg(1) != rootContext {
+		return err
+	}
+	return Exception{term: atomError.Apply(c.Arg(0), p.buf.last.term())}
+}
+
+// SkipToEnd discards the tokens up to the next end token. After a syntax error reported by Term, it skips the rest of
+// the erroneous term so that the next call to Term resumes with the following one.
+func (p *Parser) SkipToEnd() {
+	for {
+		t, err := p.next()
+		if err != nil || t.kind == TokenEnd {
+			return
+		}
+	}
+}
+
 // Number parses a number term.
 func (p *Parser) number() (Number, error) {
 	var (
@@ -157,10 +246,12 @@ func (p *Parser) number() (Number, error) {
 		return nil, err
 	}
 	switch t.kind {
-	case tokenInteger:
+	case TokenInteger:
 		n, err = integer(1, t.val)
-	case tokenFloatNumber:
+	case TokenFloatNumber:
 		n, err = float(1, t.val)
+	case TokenRational:
+		n, err = rational(1, t.val)
 	default:
 		p.backup()
 		var a Atom
@@ -180,10 +271,12 @@ func (p *Parser) number() (Number, error) {
 			return nil, errNotANumber
 		}
 		switch t.kind {
-		case tokenInteger:
+		case TokenInteger:
 			n, err = integer(-1, t.val)
-		case tokenFloatNumber:
+		case TokenFloatNumber:
 			n, err = float(-1, t.val)
+		case TokenRational:
+			n, err = rational(-1, t.val)
 		default:
 			p.backup()
 			p.backup()
@@ -269,6 +362,14 @@ func newOperators() *operators {
 	return &operators{OrderedMap: orderedmap.New[Atom, [_operatorClassLen]operator]()}
 }
 
+func (ops *operators) clone() *operators {
+	c := newOperators()
+	for p := ops.Oldest(); p != nil; p = p.Next() {
+		c.Set(p.Key, p.Value)
+	}
+	return c
+}
+
 func (ops *operators) defined(name Atom) bool {
 	_, ok := ops.Get(name)
 	return ok
@@ -295,6 +396,27 @@ func (ops *operators) define(p Integer, spec operatorSpecifier, op Atom) {
 	ops.Set(op, os)
 }
 
+// canonical returns the defined operators ordered by priority, specifier (fx, fy, xf, yf, xfx, xfy, yfx), and name
+// regardless of the order in which they were defined.
+func (ops *operators) canonical() []operator {
+	ret := make([]operator, 0, ops.Len())
+	for p := ops.Oldest(); p != nil; p = p.Next() {
+		for _, o := range p.Value {
+			if o != (operator{}) {
+				ret = append(ret, o)
+			}
+		}
+	}
+	slices.SortFunc(ret, func(a, b operator) int {
+		return cmp.Or(
+			cmp.Compare(a.priority, b.priority),
+			cmp.Compare(a.specifier, b.specifier),
+			strings.Compare(a.name.String(), b.name.String()),
+		)
+	})
+	return ret
+}
+
 func (ops *operators) remove(name Atom, class operatorClass) {
 	os, _ := ops.Get(name)
 	os[class] = operator{}
@@ -335,16 +457,108 @@ const (
 	doubleQuotesChars doubleQuotes = iota
 	doubleQuotesCodes
 	doubleQuotesAtom
+	doubleQuotesString
 )
 
 func (d doubleQuotes) String() string {
 	return [...]string{
-		doubleQuotesCodes: "codes",
-		doubleQuotesChars: "chars",
-		doubleQuotesAtom:  "atom",
+		doubleQuotesCodes:  "codes",
+		doubleQuotesChars:  "chars",
+		doubleQuotesAtom:   "atom",
+		doubleQuotesString: "string",
 	}[d]
 }
 
+// numberSyntax is the syntax of the text of a number accepted by number_chars/2 and number_codes/2.
+type numberSyntax int
+
+const (
+	numberSyntaxStandard numberSyntax = iota
+	numberSyntaxStrict
+	numberSyntaxExtended
+)
+
+func (n numberSyntax) String() string {
+	return [...]string{
+		numberSyntaxStandard: "standard",
+		numberSyntaxStrict:   "strict",
+		numberSyntaxExtended: "extended",
+	}[n]
+}
+
+// parseNumber parses s as the text of a number in the syntax.
+// In strict mode, a negative sign must be immediately followed by the digits.
+// In extended mode, a leading '+' and underscores between decimal digits, e.g. 1_000_000, are also accepted.
+func parseNumber(s string, syntax numberSyntax) (Number, error) {
+	switch syntax {
+	case numberSyntaxStrict:
+		t := trimLayoutText(s)
+		if t == "" {
+			return nil, errNotANumber
+		}
+		if r, n := utf8.DecodeRuneInString(t); r == '-' {
+			if r, _ := utf8.DecodeRuneInString(t[n:]); !isDecimalDigitChar(r) {
+				return nil, errNotANumber
+			}
+		}
+	case numberSyntaxExtended:
+		s = extendedNumberText(s)
+	}
+
+	p := Parser{
+		lexer: Lexer{
+			input: newRuneRingBuffer(strings.NewReader(s)),
+		},
+	}
+	return p.number()
+}
+
+// trimLayoutText returns s without the leading layout text, i.e. layout characters and comments.
+func trimLayoutText(s string) string {
+	for {
+		t := strings.TrimLeftFunc(s, isLayoutChar)
+		switch {
+		case strings.HasPrefix(t, "%"):
+			i := strings.IndexRune(t, '\n')
+			if i < 0 {
+				return ""
+			}
+			s = t[i+1:]
+		case strings.HasPrefix(t, "/*"):
+			i := strings.Index(t[2:], "*/")
+			if i < 0 {
+				return t
+			}
+			s = t[2+i+2:]
+		default:
+			return t
+		}
+	}
+}
+
+// extendedNumberText rewrites the text of a number in the extended syntax to the standard syntax.
+func extendedNumberText(s string) string {
+	t := trimLayoutText(s)
+	if strings.HasPrefix(t, "+") {
+		if r, _ := utf8.DecodeRuneInString(t[1:]); isDecimalDigitChar(r) {
+			t = t[1:]
+		}
+	}
+	if strings.HasPrefix(strings.TrimPrefix(t, "-"), "0'") {
+		return t
+	}
+
+	var sb strings.Builder
+	rs := []rune(t)
+	for i, r := range rs {
+		if r == '_' && i > 0 && i < len(rs)-1 && isDecimalDigitChar(rs[i-1]) && isDecimalDigitChar(rs[i+1]) {
+			continue
+		}
+		_, _ = sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
 func (p *Parser) getOperators() *operators {
 	if p._operators == nil {
 		p._operators = newOperators()
@@ -354,6 +568,7 @@ func (p *Parser) getOperators() *operators {
 
 // Loosely based on Pratt parser explained in this article: https://matklad.github.io/2020/04/13/simple-but-powerful-pratt-parsing.html
 func (p *Parser) term(maxPriority Integer) (Term, error) {
+	start := p.peekStart()
 	var lhs Term
 	switch op, err := p.prefix(maxPriority); err {
 	case nil:
@@ -364,6 +579,7 @@ func (p *Parser) term(maxPriority Integer) (Term, error) {
 			return p.term0(maxPriority)
 		}
 		lhs = op.name.Apply(t)
+		p.spanned(lhs, start, p.node)
 	case errNoOp:
 		lhs, err = p.term0(maxPriority)
 		if err != nil {
@@ -374,6 +590,7 @@ func (p *Parser) term(maxPriority Integer) (Term, error) {
 	}
 
 	for {
+		l := p.node
 		op, err := p.infix(maxPriority)
 		if err != nil {
 			break
@@ -381,12 +598,14 @@ func (p *Parser) term(maxPriority Integer) (Term, error) {
 		switch _, rbp := op.bindingPriorities(); {
 		case rbp > 1200:
 			lhs = op.name.Apply(lhs)
+			p.spanned(lhs, start, l)
 		default:
 			rhs, err := p.term(rbp)
 			if err != nil {
 				return nil, err
 			}
 			lhs = op.name.Apply(lhs, rhs)
+			p.spanned(lhs, start, l, p.node)
 		}
 	}
 
@@ -405,7 +624,7 @@ func (p *Parser) prefix(maxPriority Integer) (operator, error) {
 			return operator{}, err
 		}
 		switch t.kind {
-		case tokenInteger, tokenFloatNumber:
+		case TokenInteger, TokenFloatNumber, TokenRational:
 			p.backup()
 			p.backup()
 			return operator{}, errNoOp
@@ -419,7 +638,7 @@ func (p *Parser) prefix(maxPriority Integer) (operator, error) {
 		return operator{}, err
 	}
 	switch t.kind {
-	case tokenOpenCT:
+	case TokenOpenCT:
 		p.backup()
 		p.backup()
 		return operator{}, errNoOp
@@ -478,13 +697,13 @@ func (p *Parser) op(maxPriority Integer) (Atom, error) {
 		switch a {
 		case atomEmptyList:
 			p.backup()
-			if p.current().kind == tokenCloseList {
+			if p.current().kind == TokenCloseList {
 				p.backup()
 			}
 			return "", errNoOp
 		case atomEmptyBlock:
 			p.backup()
-			if p.current().kind == tokenCloseCurly {
+			if p.current().kind == TokenCloseCurly {
 				p.backup()
 			}
 			return "", errNoOp
@@ -498,11 +717,11 @@ func (p *Parser) op(maxPriority Integer) (Atom, error) {
 		return "", err
 	}
 	switch t.kind {
-	case tokenComma:
+	case TokenComma:
 		if maxPriority >= 1000 {
 			return NewAtom(t.val), nil
 		}
-	case tokenBar:
+	case TokenBar:
 		return NewAtom(t.val), nil
 	}
 
@@ -511,52 +730,69 @@ func (p *Parser) op(maxPriority Integer) (Atom, error) {
 }
 
 func (p *Parser) term0(maxPriority Integer) (Term, error) {
+	start := p.peekStart()
 	t, err := p.next()
 	if err != nil {
 		return nil, err
 	}
 	switch t.kind {
-	case tokenOpen, tokenOpenCT:
-		return p.openClose()
-	case tokenInteger:
-		return integer(1, t.val)
-	case tokenFloatNumber:
-		return float(1, t.val)
-	case tokenVariable:
-		if t, _ := p.next(); t.kind == tokenOpenCurly {
+	case TokenOpen, TokenOpenCT:
+		return p.openClose(start)
+	case TokenInteger:
+		n, err := integer(1, t.val)
+		if err != nil {
+			return nil, err
+		}
+		return p.leaf(start, n), nil
+	case TokenFloatNumber:
+		n, err := float(1, t.val)
+		if err != nil {
+			return nil, err
+		}
+		return p.leaf(start, n), nil
+	case TokenRational:
+		n, err := rational(1, t.val)
+		if err != nil {
+			return nil, err
+		}
+		return p.leaf(start, n), nil
+	case TokenVariable:
+		if t, _ := p.next(); t.kind == TokenOpenCurly {
 			p.backup()
 			p.backup()
 			return p.dict()
 		}
 		p.backup()
-		return p.variable(t.val), nil
-	case tokenOpenList:
-		if t, _ := p.next(); t.kind == tokenCloseList {
+		return p.leaf(start, p.variable(t.val)), nil
+	case TokenOpenList:
+		if t, _ := p.next(); t.kind == TokenCloseList {
 			p.backup()
 			p.backup()
 			break
 		}
 		p.backup()
-		return p.list()
-	case tokenOpenCurly:
-		if t, _ := p.next(); t.kind == tokenCloseCurly {
+		return p.list(start)
+	case TokenOpenCurly:
+		if t, _ := p.next(); t.kind == TokenCloseCurly {
 			p.backup()
 			p.backup()
 			break
 		}
 		p.backup()
-		return p.curlyBracketedTerm()
-	case tokenDoubleQuotedList:
+		return p.curlyBracketedTerm(start)
+	case TokenDoubleQuotedList:
 		switch p.doubleQuotes {
 		case doubleQuotesChars:
-			return CharList(unDoubleQuote(t.val)), nil
+			return p.leaf(start, CharList(unDoubleQuote(t.val))), nil
 		case doubleQuotesCodes:
-			return CodeList(unDoubleQuote(t.val)), nil
+			return p.leaf(start, CodeList(unDoubleQuote(t.val))), nil
+		case doubleQuotesString:
+			return p.leaf(start, String(unDoubleQuote(t.val))), nil
 		default:
 			p.backup()
 		}
-	case tokenLetterDigit:
-		if t, _ := p.next(); t.kind == tokenOpenCurly {
+	case TokenLetterDigit:
+		if t, _ := p.next(); t.kind == TokenOpenCurly {
 			p.backup()
 			p.backup()
 			return p.dict()
@@ -571,6 +807,7 @@ func (p *Parser) term0(maxPriority Integer) (Term, error) {
 }
 
 func (p *Parser) term0Atom(maxPriority Integer) (Term, error) {
+	start := p.peekStart()
 	a, err := p.atom()
 	if err != nil {
 		return nil, err
@@ -581,17 +818,26 @@ func (p *Parser) term0Atom(maxPriority Integer) (Term, error) {
 		if err != nil {
 			return nil, err
 		}
+		var n Number
 		switch t.kind {
-		case tokenInteger:
-			return integer(-1, t.val)
-		case tokenFloatNumber:
-			return float(-1, t.val)
+		case TokenInteger:
+			n, err = integer(-1, t.val)
+		case TokenFloatNumber:
+			n, err = float(-1, t.val)
+		case TokenRational:
+			n, err = rational(-1, t.val)
 		default:
 			p.backup()
 		}
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			return p.leaf(start, n), nil
+		}
 	}
 
-	t, err := p.functionalNotation(a)
+	t, err := p.functionalNotation(start, a)
 	if err != nil {
 		return nil, err
 	}
@@ -607,6 +853,7 @@ func (p *Parser) term0Atom(maxPriority Integer) (Term, error) {
 			return nil, errPlaceholder
 		}
 		t, p.args = p.args[0], p.args[1:]
+		p.leaf(start, t)
 	}
 
 	return t, nil
@@ -628,15 +875,16 @@ func (p *Parser) variable(s string) Term {
 	return v
 }
 
-func (p *Parser) openClose() (Term, error) {
+func (p *Parser) openClose(start int) (Term, error) {
 	t, err := p.term(1201)
 	if err != nil {
 		return nil, err
 	}
-	if t, _ := p.next(); t.kind != tokenClose {
+	if t, _ := p.next(); t.kind != TokenClose {
 		p.backup()
 		return nil, errExpectation
 	}
+	p.spanned(t, start, p.node.Args...)
 	return t, nil
 }
 
@@ -650,33 +898,33 @@ func (p *Parser) atom() (Atom, error) {
 		return "", err
 	}
 	switch t.kind {
-	case tokenOpenList:
+	case TokenOpenList:
 		t, err := p.next()
 		if err != nil {
 			return "", err
 		}
 		switch t.kind {
-		case tokenCloseList:
+		case TokenCloseList:
 			return atomEmptyList, nil
 		default:
 			p.backup()
 			p.backup()
 			return "", errExpectation
 		}
-	case tokenOpenCurly:
+	case TokenOpenCurly:
 		t, err := p.next()
 		if err != nil {
 			return "", err
 		}
 		switch t.kind {
-		case tokenCloseCurly:
+		case TokenCloseCurly:
 			return atomEmptyBlock, nil
 		default:
 			p.backup()
 			p.backup()
 			return "", errExpectation
 		}
-	case tokenDoubleQuotedList:
+	case TokenDoubleQuotedList:
 		switch p.doubleQuotes {
 		case doubleQuotesAtom:
 			return NewAtom(unDoubleQuote(t.val)), nil
@@ -696,9 +944,9 @@ func (p *Parser) name() (Atom, error) {
 		return "", err
 	}
 	switch t.kind {
-	case tokenLetterDigit, tokenGraphic, tokenSemicolon, tokenCut:
+	case TokenLetterDigit, TokenGraphic, TokenSemicolon, TokenCut:
 		return NewAtom(t.val), nil
-	case tokenQuoted:
+	case TokenQuoted:
 		return NewAtom(unquote(t.val)), nil
 	default:
 		p.backup()
@@ -706,38 +954,42 @@ func (p *Parser) name() (Atom, error) {
 	}
 }
 
-func (p *Parser) list() (Term, error) {
+func (p *Parser) list(start int) (Term, error) {
 	arg, err := p.arg()
 	if err != nil {
 		return nil, err
 	}
 	args := []Term{arg}
+	elems := []SpannedTerm{p.node}
 	for {
 		switch t, _ := p.next(); t.kind {
-		case tokenComma:
+		case TokenComma:
 			arg, err := p.arg()
 			if err != nil {
 				return nil, err
 			}
 			args = append(args, arg)
-		case tokenBar:
+			elems = append(elems, p.node)
+		case TokenBar:
 			rest, err := p.arg()
 			if err != nil {
 				return nil, err
 			}
+			tail := p.node
 
 			switch t, _ := p.next(); t.kind {
-			case tokenCloseList:
+			case TokenCloseList:
 				if len(args) == 1 {
-					return Cons(args[0], rest), nil
+					return p.spannedList(start, Cons(args[0], rest), elems, tail), nil
 				}
-				return PartialList(rest, args...), nil
+				return p.spannedList(start, PartialList(rest, args...), elems, tail), nil
 			default:
 				p.backup()
 				return nil, errExpectation
 			}
-		case tokenCloseList:
-			return List(args...), nil
+		case TokenCloseList:
+			p.leaf(p.buf.previousSpan().Start, atomEmptyList) // The empty list at the end is spanned by ].
+			return p.spannedList(start, List(args...), elems, p.node), nil
 		default:
 			p.backup()
 			return nil, errExpectation
@@ -745,38 +997,73 @@ func (p *Parser) list() (Term, error) {
 	}
 }
 
-func (p *Parser) curlyBracketedTerm() (Term, error) {
+// spannedList records the spans of list, whose elements are spanned by elems followed by tail, and of its sublists
+// if the parser records the spans. The sublists end with the closing bracket.
+func (p *Parser) spannedList(start int, list Term, elems []SpannedTerm, tail SpannedTerm) Term {
+	if !p.spans {
+		return list
+	}
+	subs := make([]Term, len(elems))
+	for i, t := 0, list; i < len(elems); i++ {
+		subs[i] = t
+		t = t.(Compound).Arg(1)
+	}
+	end := p.buf.previousSpan().End
+	node := tail
+	for i := len(elems) - 1; i >= 0; i-- {
+		s := elems[i].Span.Start
+		if i == 0 {
+			s = start
+		}
+		node = SpannedTerm{
+			Term: subs[i],
+			Span: SourceSpan{Start: s, End: end},
+			Args: []SpannedTerm{elems[i], node},
+		}
+	}
+	p.node = node
+	return list
+}
+
+func (p *Parser) curlyBracketedTerm(start int) (Term, error) {
 	t, err := p.term(1201)
 	if err != nil {
 		return nil, err
 	}
+	arg := p.node
 
-	if t, _ := p.next(); t.kind != tokenCloseCurly {
+	if t, _ := p.next(); t.kind != TokenCloseCurly {
 		p.backup()
 		return nil, errExpectation
 	}
 
-	return atomEmptyBlock.Apply(t), nil
+	c := atomEmptyBlock.Apply(t)
+	p.spanned(c, start, arg)
+	return c, nil
 }
 
-func (p *Parser) functionalNotation(functor Atom) (Term, error) {
+func (p *Parser) functionalNotation(start int, functor Atom) (Term, error) {
 	switch t, _ := p.next(); t.kind {
-	case tokenOpenCT:
+	case TokenOpenCT:
 		arg, err := p.arg()
 		if err != nil {
 			return nil, err
 		}
 		args := []Term{arg}
+		nodes := []SpannedTerm{p.node}
 		for {
 			switch t, _ := p.next(); t.kind {
-			case tokenComma:
+			case TokenComma:
 				arg, err := p.arg()
 				if err != nil {
 					return nil, err
 				}
 				args = append(args, arg)
-			case tokenClose:
-				return functor.Apply(args...), nil
+				nodes = append(nodes, p.node)
+			case TokenClose:
+				c := functor.Apply(args...)
+				p.spanned(c, start, nodes...)
+				return c, nil
 			default:
 				p.backup()
 				return nil, errExpectation
@@ -784,24 +1071,25 @@ func (p *Parser) functionalNotation(functor Atom) (Term, error) {
 		}
 	default:
 		p.backup()
-		return functor, nil
+		return p.leaf(start, functor), nil
 	}
 }
 
 func (p *Parser) arg() (Term, error) {
+	start := p.peekStart()
 	if arg, err := p.atom(); err == nil {
 		if p.getOperators().defined(arg) {
 			// Check if this atom is not followed by its own arguments.
 			switch t, _ := p.next(); t.kind {
-			case tokenComma, tokenClose, tokenBar, tokenCloseList:
+			case TokenComma, TokenClose, TokenBar, TokenCloseList:
 				p.backup()
-				return arg, nil
+				return p.leaf(start, arg), nil
 			default:
 				p.backup()
 			}
 		}
 		p.backup()
-		if p.current().kind == tokenCloseList || p.current().kind == tokenCloseCurly {
+		if p.current().kind == TokenCloseList || p.current().kind == TokenCloseCurly {
 			p.backup() // Unquoted [] or {} consist of 2 tokens.
 		}
 	}
@@ -815,6 +1103,7 @@ func (p *Parser) dict() (Term, error) {
 	var err error
 	var tag Term
 
+	start := p.peekStart()
 	tag, err = p.atom()
 	switch err {
 	case nil:
@@ -826,7 +1115,7 @@ func (p *Parser) dict() (Term, error) {
 			return nil, err
 		}
 		switch t.kind {
-		case tokenVariable:
+		case TokenVariable:
 			tag = p.variable(t.val)
 
 		default:
@@ -835,28 +1124,29 @@ func (p *Parser) dict() (Term, error) {
 	}
 
 	args = append(args, tag)
+	nodes := []SpannedTerm{{Term: p.leaf(start, tag), Span: p.node.Span}}
 
-	if t, _ := p.next(); t.kind != tokenOpenCurly {
+	if t, _ := p.next(); t.kind != TokenOpenCurly {
 		p.backup()
 		return nil, errExpectation
 	}
 
-	if t, _ := p.next(); t.kind == tokenCloseCurly {
-		return NewDict(args)
+	if t, _ := p.next(); t.kind == TokenCloseCurly {
+		return p.spannedDict(start, args, nodes)
 	}
 	p.backup()
 
 	for {
-		k, v, err := p.keyValue()
+		k, v, err := p.keyValue(&nodes)
 		if err != nil {
 			return nil, err
 		}
 		args = append(args, k, v)
 
 		switch t, _ := p.next(); t.kind {
-		case tokenComma:
-		case tokenCloseCurly:
-			return NewDict(args)
+		case TokenComma:
+		case TokenCloseCurly:
+			return p.spannedDict(start, args, nodes)
 		default:
 			p.backup()
 			return nil, errExpectation
@@ -864,13 +1154,27 @@ func (p *Parser) dict() (Term, error) {
 	}
 }
 
-func (p *Parser) keyValue() (Atom, Term, error) {
+// spannedDict returns the dict of args and records its span if the parser records the spans. The keys and the values
+// are spanned by nodes in the order of the source text.
+func (p *Parser) spannedDict(start int, args []Term, nodes []SpannedTerm) (Term, error) {
+	d, err := NewDict(args)
+	if err != nil {
+		return nil, err
+	}
+	p.spanned(d, start, nodes...)
+	return d, nil
+}
+
+// keyValue parses a key-value pair of a dict and appends the spanned key and value to nodes.
+func (p *Parser) keyValue(nodes *[]SpannedTerm) (Atom, Term, error) {
+	start := p.peekStart()
 	key, err := p.atom()
 	if err != nil {
 		return "", nil, err
 	}
+	k := SpannedTerm{Term: p.leaf(start, key), Span: p.node.Span}
 	switch t, _ := p.next(); t.kind {
-	case tokenGraphic:
+	case TokenGraphic:
 		if t.val != ":" {
 			p.backup()
 			return "", nil, errExpectation
@@ -883,6 +1187,7 @@ func (p *Parser) keyValue() (Atom, Term, error) {
 	if err != nil {
 		return "", nil, err
 	}
+	*nodes = append(*nodes, k, p.node)
 
 	return key, value, nil
 }
@@ -918,6 +1223,19 @@ func integer(sign int64, s string) (Integer, error) {
 	}
 }
 
+func rational(sign int64, s string) (Number, error) {
+	n, d, _ := strings.Cut(s, "r")
+	num, err := integer(sign, n)
+	if err != nil {
+		return nil, err
+	}
+	den, err := integer(1, d)
+	if err != nil {
+		return nil, err
+	}
+	return NewRational(num, den) // den > 0 and the result is in canonical form.
+}
+
 func float(sign float64, s string) (Float, error) {
 	if sign < 0 {
 		s = "-" + s
@@ -1025,17 +1343,23 @@ func doubleQuotedUnescape(s string) string {
 
 type tokenRingBuffer struct {
 	buf        [4]Token
+	pos        [4]position   // the positions of the tokens in buf.
+	spans      [4]SourceSpan // the spans of the tokens in buf.
 	start, end int
+	last       position // the position of the last token got.
 }
 
-func (b *tokenRingBuffer) put(t Token) {
+func (b *tokenRingBuffer) put(t Token, pos position, span SourceSpan) {
 	b.buf[b.end] = t
+	b.pos[b.end] = pos
+	b.spans[b.end] = span
 	b.end++
 	b.end %= len(b.buf)
 }
 
 func (b *tokenRingBuffer) get() Token {
 	t := b.buf[b.start]
+	b.last = b.pos[b.start]
 	b.start++
 	b.start %= len(b.buf)
 	return t
@@ -1045,6 +1369,21 @@ func (b *tokenRingBuffer) current() Token {
 	return b.buf[b.start]
 }
 
+// position returns the position of the current token.
+func (b *tokenRingBuffer) position() position {
+	return b.pos[b.start]
+}
+
+// span returns the span of the current token.
+func (b *tokenRingBuffer) span() SourceSpan {
+	return b.spans[b.start]
+}
+
+// previousSpan returns the span of the token before the current one, i.e. the last token got unless it's backed up.
+func (b *tokenRingBuffer) previousSpan() SourceSpan {
+	return b.spans[(b.start+len(b.spans)-1)%len(b.spans)]
+}
+
 func (b *tokenRingBuffer) empty() bool {
 	return b.start == b.end
 }
@@ -1059,8 +1398,12 @@ func (b *tokenRingBuffer) backup() {
 
 type unexpectedTokenError struct {
 	actual Token
+	pos    position
 }
 
 func (e unexpectedTokenError) Error() string {
-	return fmt.Sprintf("unexpected token: %s", e.actual)
+	if e.pos == (position{}) {
+		return fmt.Sprintf("unexpected token: %s", e.actual)
+	}
+	return fmt.Sprintf("unexpected token: %s at %s", e.actual, e.pos)
 }
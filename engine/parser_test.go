@@ -39,10 +39,10 @@ func TestParser_Term(t *testing.T) {
 	}{
 		{input: ``, err: io.EOF},
 		{input: `foo`, err: io.EOF},
-		{input: `.`, err: unexpectedTokenError{actual: Token{kind: tokenEnd, val: "."}}},
+		{input: `.`, err: unexpectedTokenError{actual: Token{kind: TokenEnd, val: "."}, pos: position{line: 1, column: 1}}},
 
 		{input: `(foo).`, term: NewAtom("foo")},
-		{input: `(a b).`, err: unexpectedTokenError{actual: Token{kind: tokenLetterDigit, val: "b"}}},
+		{input: `(a b).`, err: unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "b"}, pos: position{line: 1, column: 4}}},
 
 		{input: `foo.`, term: NewAtom("foo")},
 		{input: `[].`, term: atomEmptyList},
@@ -76,11 +76,16 @@ func TestParser_Term(t *testing.T) {
 		{input: `-1.`, term: Integer(-1)},
 		{input: `- 1.`, term: Integer(-1)},
 		{input: `'-'1.`, term: Integer(-1)},
-		{input: `9223372036854775808.`, err: representationError(flagMaxInteger, nil)},
-		{input: `-9223372036854775809.`, err: representationError(flagMinInteger, nil)},
+		{input: `9223372036854775808.`, err: NewException(atomError.Apply(atomRepresentationError.Apply(flagMaxInteger.Term()), atomPosition.Apply(Integer(1), Integer(1))), nil)},
+		{input: `-9223372036854775809.`, err: NewException(atomError.Apply(atomRepresentationError.Apply(flagMinInteger.Term()), atomPosition.Apply(Integer(1), Integer(2))), nil)},
 		{input: `-`, err: io.EOF},
 		{input: `- -`, err: io.EOF},
 
+		{input: `1r3.`, term: Rational{num: 1, den: 3}},
+		{input: `-1r3.`, term: Rational{num: -1, den: 3}},
+		{input: `2r4.`, term: Rational{num: 1, den: 2}},
+		{input: `4r2.`, term: Integer(2)},
+
 		{input: `1.0.`, term: NewFloatFromInt64(1)},
 		{input: `-1.0.`, term: NewFloatFromInt64(-1)},
 		{input: `- 1.0.`, term: NewFloatFromInt64(-1)},
@@ -100,16 +105,16 @@ func TestParser_Term(t *testing.T) {
 		{input: `foo(a, b).`, term: &compound{functor: NewAtom("foo"), args: []Term{NewAtom("a"), NewAtom("b")}}},
 		{input: `foo(-(a)).`, term: &compound{functor: NewAtom("foo"), args: []Term{&compound{functor: atomMinus, args: []Term{NewAtom("a")}}}}},
 		{input: `foo(-).`, term: &compound{functor: NewAtom("foo"), args: []Term{atomMinus}}},
-		{input: `foo((), b).`, err: unexpectedTokenError{actual: Token{kind: tokenClose, val: ")"}}},
+		{input: `foo((), b).`, err: unexpectedTokenError{actual: Token{kind: TokenClose, val: ")"}, pos: position{line: 1, column: 6}}},
 		{input: `foo([]).`, term: &compound{functor: NewAtom("foo"), args: []Term{atomEmptyList}}},
-		{input: `foo(a, ()).`, err: unexpectedTokenError{actual: Token{kind: tokenClose, val: ")"}}},
-		{input: `foo(a b).`, err: unexpectedTokenError{actual: Token{kind: tokenLetterDigit, val: "b"}}},
+		{input: `foo(a, ()).`, err: unexpectedTokenError{actual: Token{kind: TokenClose, val: ")"}, pos: position{line: 1, column: 9}}},
+		{input: `foo(a b).`, err: unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "b"}, pos: position{line: 1, column: 7}}},
 		{input: `foo(a, b`, err: io.EOF},
 
 		{input: `[a, b].`, term: List(NewAtom("a"), NewAtom("b"))},
-		{input: `[(), b].`, err: unexpectedTokenError{actual: Token{kind: tokenClose, val: ")"}}},
-		{input: `[a, ()].`, err: unexpectedTokenError{actual: Token{kind: tokenClose, val: ")"}}},
-		{input: `[a b].`, err: unexpectedTokenError{actual: Token{kind: tokenLetterDigit, val: "b"}}},
+		{input: `[(), b].`, err: unexpectedTokenError{actual: Token{kind: TokenClose, val: ")"}, pos: position{line: 1, column: 3}}},
+		{input: `[a, ()].`, err: unexpectedTokenError{actual: Token{kind: TokenClose, val: ")"}, pos: position{line: 1, column: 6}}},
+		{input: `[a b].`, err: unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "b"}, pos: position{line: 1, column: 4}}},
 		{input: `[a|X].`, termLazy: func() Term {
 			return Cons(NewAtom("a"), lastVariable())
 		}, vars: func() []ParsedVariable {
@@ -124,13 +129,13 @@ func TestParser_Term(t *testing.T) {
 				{Name: NewAtom("X"), Variable: lastVariable(), Count: 1},
 			}
 		}},
-		{input: `[a, b|()].`, err: unexpectedTokenError{actual: Token{kind: tokenClose, val: ")"}}},
-		{input: `[a, b|c d].`, err: unexpectedTokenError{actual: Token{kind: tokenLetterDigit, val: "d"}}},
+		{input: `[a, b|()].`, err: unexpectedTokenError{actual: Token{kind: TokenClose, val: ")"}, pos: position{line: 1, column: 8}}},
+		{input: `[a, b|c d].`, err: unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "d"}, pos: position{line: 1, column: 9}}},
 		{input: `[a `, err: io.EOF},
 
 		{input: `{a}.`, term: &compound{functor: atomEmptyBlock, args: []Term{NewAtom("a")}}},
-		{input: `{()}.`, err: unexpectedTokenError{actual: Token{kind: tokenClose, val: ")"}}},
-		{input: `{a b}.`, err: unexpectedTokenError{actual: Token{kind: tokenLetterDigit, val: "b"}}},
+		{input: `{()}.`, err: unexpectedTokenError{actual: Token{kind: TokenClose, val: ")"}, pos: position{line: 1, column: 3}}},
+		{input: `{a b}.`, err: unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "b"}, pos: position{line: 1, column: 4}}},
 
 		{input: `-a.`, term: &compound{functor: atomMinus, args: []Term{NewAtom("a")}}},
 		{input: `- .`, term: atomMinus},
@@ -138,16 +143,17 @@ func TestParser_Term(t *testing.T) {
 		{input: `a-- .`, term: &compound{functor: NewAtom(`--`), args: []Term{NewAtom(`a`)}}},
 
 		{input: `a + b.`, term: &compound{functor: atomPlus, args: []Term{NewAtom("a"), NewAtom("b")}}},
-		{input: `a + ().`, err: unexpectedTokenError{actual: Token{kind: tokenClose, val: ")"}}},
+		{input: `a + ().`, err: unexpectedTokenError{actual: Token{kind: TokenClose, val: ")"}, pos: position{line: 1, column: 6}}},
 		{input: `a * b + c.`, term: &compound{functor: atomPlus, args: []Term{&compound{functor: NewAtom("*"), args: []Term{NewAtom("a"), NewAtom("b")}}, NewAtom("c")}}},
-		{input: `a [] b.`, err: unexpectedTokenError{actual: Token{kind: tokenOpenList, val: "["}}},
-		{input: `a {} b.`, err: unexpectedTokenError{actual: Token{kind: tokenLetterDigit, val: "b"}}},
+		{input: `a [] b.`, err: unexpectedTokenError{actual: Token{kind: TokenOpenList, val: "["}, pos: position{line: 1, column: 3}}},
+		{input: `a {} b.`, err: unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "b"}, pos: position{line: 1, column: 6}}},
 		{input: `a, b.`, term: &compound{functor: atomComma, args: []Term{NewAtom("a"), NewAtom("b")}}},
-		{input: `+ * + .`, err: unexpectedTokenError{actual: Token{kind: tokenGraphic, val: "+"}}},
+		{input: `+ * + .`, err: unexpectedTokenError{actual: Token{kind: TokenGraphic, val: "+"}, pos: position{line: 1, column: 5}}},
 
 		{input: `"abc".`, doubleQuotes: doubleQuotesChars, term: charList("abc")},
 		{input: `"abc".`, doubleQuotes: doubleQuotesCodes, term: codeList("abc")},
 		{input: `"abc".`, doubleQuotes: doubleQuotesAtom, term: NewAtom("abc")},
+		{input: `"abc".`, doubleQuotes: doubleQuotesString, term: String("abc")},
 		{input: `"don""t panic".`, doubleQuotes: doubleQuotesAtom, term: NewAtom("don\"t panic")},
 		{input: "\"this is \\\na double-quoted string\".", doubleQuotes: doubleQuotesAtom, term: NewAtom("this is a double-quoted string")},
 		{input: `"\a".`, doubleQuotes: doubleQuotesAtom, term: NewAtom("\a")},
@@ -194,14 +200,14 @@ func TestParser_Term(t *testing.T) {
 				}
 			},
 		},
-		{input: `tag{.`, err: unexpectedTokenError{actual: Token{kind: tokenEnd, val: "."}}},
-		{input: `tag{{.`, err: unexpectedTokenError{actual: Token{kind: tokenOpenCurly, val: "{"}}},
-		{input: `tag{x}.`, err: unexpectedTokenError{actual: Token{kind: tokenCloseCurly, val: "}"}}},
-		{input: `tag{x:}.`, err: unexpectedTokenError{actual: Token{kind: tokenCloseCurly, val: "}"}}},
-		{input: `tag{x/1}.`, err: unexpectedTokenError{actual: Token{kind: tokenGraphic, val: "/"}}},
-		{input: `tag{1:2}.`, err: unexpectedTokenError{actual: Token{kind: tokenInteger, val: "1"}}},
-		{input: `tag{x: ,}.`, err: unexpectedTokenError{actual: Token{kind: tokenComma, val: ","}}},
-		{input: `tag{x:1 y:2}.`, err: unexpectedTokenError{actual: Token{kind: tokenLetterDigit, val: "y"}}},
+		{input: `tag{.`, err: unexpectedTokenError{actual: Token{kind: TokenEnd, val: "."}, pos: position{line: 1, column: 5}}},
+		{input: `tag{{.`, err: unexpectedTokenError{actual: Token{kind: TokenOpenCurly, val: "{"}, pos: position{line: 1, column: 5}}},
+		{input: `tag{x}.`, err: unexpectedTokenError{actual: Token{kind: TokenCloseCurly, val: "}"}, pos: position{line: 1, column: 6}}},
+		{input: `tag{x:}.`, err: unexpectedTokenError{actual: Token{kind: TokenCloseCurly, val: "}"}, pos: position{line: 1, column: 7}}},
+		{input: `tag{x/1}.`, err: unexpectedTokenError{actual: Token{kind: TokenGraphic, val: "/"}, pos: position{line: 1, column: 5}}},
+		{input: `tag{1:2}.`, err: unexpectedTokenError{actual: Token{kind: TokenInteger, val: "1"}, pos: position{line: 1, column: 5}}},
+		{input: `tag{x: ,}.`, err: unexpectedTokenError{actual: Token{kind: TokenComma, val: ","}, pos: position{line: 1, column: 8}}},
+		{input: `tag{x:1 y:2}.`, err: unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "y"}, pos: position{line: 1, column: 9}}},
 	}
 
 	for _, tc := range tests {
@@ -354,6 +360,38 @@ func TestParser_Number(t *testing.T) {
 	}
 }
 
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		input  string
+		syntax numberSyntax
+		number Number
+		err    error
+	}{
+		{input: `- 1`, syntax: numberSyntaxStandard, number: Integer(-1)},
+		{input: `- 1`, syntax: numberSyntaxStrict, err: errNotANumber},
+		{input: `-/**/1`, syntax: numberSyntaxStrict, err: errNotANumber},
+		{input: `/**/ -1`, syntax: numberSyntaxStrict, number: Integer(-1)},
+		{input: "% comment\n-1", syntax: numberSyntaxStrict, number: Integer(-1)},
+		{input: `% comment`, syntax: numberSyntaxStrict, err: errNotANumber},
+		{input: ``, syntax: numberSyntaxStrict, err: errNotANumber},
+		{input: `+1`, syntax: numberSyntaxStandard, err: errNotANumber},
+		{input: `+1`, syntax: numberSyntaxExtended, number: Integer(1)},
+		{input: ` +1_000_000`, syntax: numberSyntaxExtended, number: Integer(1000000)},
+		{input: `1_000.0_5`, syntax: numberSyntaxExtended, number: newFloatFromStringMust("1000.05")},
+		{input: `1__0`, syntax: numberSyntaxExtended, err: errNotANumber},
+		{input: `0'_`, syntax: numberSyntaxExtended, number: Integer('_')},
+		{input: `1_0`, syntax: numberSyntaxStandard, err: errNotANumber},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.syntax.String()+" "+tc.input, func(t *testing.T) {
+			n, err := parseNumber(tc.input, tc.syntax)
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.number, n)
+		})
+	}
+}
+
 func TestParser_More(t *testing.T) {
 	p := Parser{
 		lexer: Lexer{
@@ -369,3 +407,105 @@ func TestParser_More(t *testing.T) {
 	assert.Equal(t, NewAtom("bar"), term)
 	assert.False(t, p.More())
 }
+
+func TestParser_SkipToEnd(t *testing.T) {
+	p := Parser{
+		lexer: Lexer{
+			input: newRuneRingBuffer(strings.NewReader(`foo bar baz. qux(. quux.`)),
+		},
+	}
+	_, err := p.Term()
+	assert.Equal(t, unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "bar"}, pos: position{line: 1, column: 5}}, err)
+	p.SkipToEnd()
+	_, err = p.Term()
+	assert.Equal(t, unexpectedTokenError{actual: Token{kind: TokenEnd, val: "."}, pos: position{line: 1, column: 18}}, err)
+	p.SkipToEnd()
+	term, err := p.Term()
+	assert.NoError(t, err)
+	assert.Equal(t, NewAtom("quux"), term)
+	assert.False(t, p.More())
+}
+
+func TestParser_TermWithPos(t *testing.T) {
+	ops := newOperators()
+	ops.define(1000, operatorSpecifierXFY, NewAtom(`,`))
+	ops.define(500, operatorSpecifierYFX, NewAtom(`+`))
+	ops.define(200, operatorSpecifierFY, NewAtom(`-`))
+
+	// spans maps the terms to the source texts in their spans.
+	var spans func(input string, t SpannedTerm) []string
+	spans = func(input string, t SpannedTerm) []string {
+		ret := []string{input[t.Span.Start:t.Span.End]}
+		for _, a := range t.Args {
+			ret = append(ret, spans(input, a)...)
+		}
+		return ret
+	}
+
+	tests := []struct {
+		input string
+		spans []string
+	}{
+		{input: `foo.`, spans: []string{`foo`}},
+		{input: ` X .`, spans: []string{`X`}},
+		{input: `foo(a, 'b c').`, spans: []string{`foo(a, 'b c')`, `a`, `'b c'`}},
+		{input: `a + b + c.`, spans: []string{`a + b + c`, `a + b`, `a`, `b`, `c`}},
+		{input: `a + (b + c).`, spans: []string{`a + (b + c)`, `a`, `(b + c)`, `b`, `c`}},
+		{input: `- a.`, spans: []string{`- a`, `a`}},
+		{input: `- 1.`, spans: []string{`- 1`}},
+		{input: `f(-, []).`, spans: []string{`f(-, [])`, `-`, `[]`}},
+		{input: `[a, b].`, spans: []string{`[a, b]`, `a`, `b]`, `b`, `]`}},
+		{input: `[a|T].`, spans: []string{`[a|T]`, `a`, `T`}},
+		{input: `{a, b}.`, spans: []string{`{a, b}`, `a, b`, `a`, `b`}},
+		{input: `"abc".`, spans: []string{`"abc"`}},
+		{input: `t{b: 1, a: x}.`, spans: []string{`t{b: 1, a: x}`, `t`, `b`, `1`, `a`, `x`}},
+		{input: "% comment\nf(\n\tx).", spans: []string{"f(\n\tx)", `x`}},
+		{input: `'é'(ü).`, spans: []string{`'é'(ü)`, `ü`}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			p := Parser{
+				lexer: Lexer{
+					input: newRuneRingBuffer(strings.NewReader(tc.input)),
+				},
+				_operators: ops,
+			}
+			term, err := p.TermWithPos()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.spans, spans(tc.input, term))
+		})
+	}
+
+	t.Run("terms", func(t *testing.T) {
+		p := Parser{
+			lexer: Lexer{
+				input: newRuneRingBuffer(strings.NewReader(`foo(a + b, [c]). bar.`)),
+			},
+			_operators: ops,
+		}
+		term, err := p.TermWithPos()
+		assert.NoError(t, err)
+		assert.Equal(t, NewAtom("foo").Apply(atomPlus.Apply(NewAtom("a"), NewAtom("b")), List(NewAtom("c"))), term.Term)
+		assert.Equal(t, atomPlus.Apply(NewAtom("a"), NewAtom("b")), term.Args[0].Term)
+		assert.Equal(t, NewAtom("c"), term.Args[1].Args[0].Term)
+		assert.Equal(t, atomEmptyList, term.Args[1].Args[1].Term)
+
+		term, err = p.TermWithPos()
+		assert.NoError(t, err)
+		assert.Equal(t, SpannedTerm{Term: NewAtom("bar"), Span: SourceSpan{Start: 17, End: 20}}, term)
+
+		_, err = p.TermWithPos()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		p := Parser{
+			lexer: Lexer{
+				input: newRuneRingBuffer(strings.NewReader(`foo(a b).`)),
+			},
+		}
+		_, err := p.TermWithPos()
+		assert.Equal(t, unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "b"}, pos: position{line: 1, column: 7}}, err)
+	})
+}
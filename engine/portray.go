@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// portrayed is the output of portray/1 for a subterm, written as is.
+type portrayed string
+
+func (p portrayed) WriteTerm(w io.Writer, _ *WriteOptions, _ *Env) error {
+	_, err := io.WriteString(w, string(p))
+	return err
+}
+
+func (p portrayed) Compare(t Term, env *Env) int {
+	return CompareAtomic(p, t, func(a, b portrayed) int {
+		return strings.Compare(string(a), string(b))
+	}, env)
+}
+
+// portray returns t whose subterms, for which the user-defined portray/1 succeeds, are replaced by what it wrote.
+// Lists are portrayed as a whole and element by element, but not tail by tail. As write_term/3 does, it doesn't go
+// deeper than maxDepth if it's positive, and it replaces the compounds which contain themselves by '...'.
+func (vm *VM) portray(ctx context.Context, t Term, maxDepth Integer, env *Env) (Term, error) {
+	if _, ok := vm.getProcedure(procedureIndicator{name: atomPortray, arity: 1}); !ok {
+		return t, nil
+	}
+	p := portrayer{vm: vm, visiting: map[termID]struct{}{}}
+	return p.subterm(ctx, t, maxDepth, env)
+}
+
+type portrayer struct {
+	vm       *VM
+	visiting map[termID]struct{}
+}
+
+// subterm portrays t which is depth levels deep from the limit, or unlimited if depth is not positive.
+func (p *portrayer) subterm(ctx context.Context, t Term, depth Integer, env *Env) (Term, error) {
+	t = env.Resolve(t)
+	if _, ok := t.(Variable); ok {
+		return t, nil
+	}
+	if c, ok := t.(Compound); ok {
+		if _, ok := p.visiting[id(c)]; ok {
+			return atomElipsis, nil
+		}
+	}
+
+	s, ok, _, err := p.vm.captureOutput(ctx, atomPortray.Apply(t), env)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return portrayed(s), nil
+	}
+
+	return p.args(ctx, t, depth, env)
+}
+
+func (p *portrayer) args(ctx context.Context, t Term, depth Integer, env *Env) (Term, error) {
+	c, ok := t.(Compound)
+	if !ok {
+		return t, nil
+	}
+	if _, ok := c.(Dict); ok {
+		return t, nil
+	}
+	p.visiting[id(c)] = struct{}{}
+	defer delete(p.visiting, id(c))
+
+	// A level deeper, or the same level if depth is unlimited.
+	deeper := depth
+	if depth > 1 {
+		deeper--
+	}
+
+	if c.Functor() == atomDot && c.Arity() == 2 {
+		// As write_term/3 does, the elements are a level deeper than the previous ones.
+		car, err := p.subterm(ctx, c.Arg(0), depth, env)
+		if err != nil {
+			return nil, err
+		}
+		cdr := env.Resolve(c.Arg(1))
+		l, ok := cdr.(Compound)
+		switch {
+		case depth == 1: // The rest is written as '...'.
+		case ok && l.Functor() == atomDot && l.Arity() == 2:
+			if _, ok := p.visiting[id(l)]; ok {
+				return Cons(car, atomElipsis), nil
+			}
+			cdr, err = p.args(ctx, l, deeper, env)
+		default:
+			cdr, err = p.subterm(ctx, cdr, depth, env)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return Cons(car, cdr), nil
+	}
+
+	if depth == 1 { // The arguments are written as '...'.
+		return t, nil
+	}
+	args := make([]Term, c.Arity())
+	for i := range args {
+		var err error
+		args[i], err = p.subterm(ctx, c.Arg(i), deeper, env)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Functor().Apply(args...), nil
+}
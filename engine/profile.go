@@ -0,0 +1,269 @@
+package engine
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/apd/v3"
+)
+
+// ProfileEntry is the profile of a predicate.
+// Inferences and Time are cumulative: they include the predicates called by the predicate. The recursive calls don't
+// count twice.
+type ProfileEntry struct {
+	Predicate  string // the predicate indicator, e.g. foo/1.
+	Calls      uint64
+	Redos      uint64
+	Inferences uint64
+	Time       time.Duration
+
+	pi procedureIndicator
+}
+
+// ProfileReport is the profile of the predicates called while the VM was profiling.
+type ProfileReport struct {
+	Entries []ProfileEntry // sorted by Time, the longest first.
+}
+
+// profiler collects the profiles of the predicates.
+type profiler struct {
+	mu      sync.Mutex
+	entries map[procedureIndicator]*profileEntry
+}
+
+type profileEntry struct {
+	calls, redos, inferences uint64
+	time                     time.Duration
+	active                   int // the number of the calls in progress.
+}
+
+// SetProfiling makes the VM collect the call counts, the inferences, and the time of every predicate it calls if on
+// is true. The collected profiles are kept until ResetProfile.
+func (vm *VM) SetProfiling(on bool) {
+	if on && vm.profiler == nil {
+		vm.profiler = &profiler{}
+	}
+	vm.profiling = on
+}
+
+// ResetProfile discards the profiles collected so far.
+func (vm *VM) ResetProfile() {
+	if vm.profiler == nil {
+		return
+	}
+	vm.profiler.mu.Lock()
+	defer vm.profiler.mu.Unlock()
+	vm.profiler.entries = nil
+}
+
+// Profile returns the profiles collected so far.
+func (vm *VM) Profile() ProfileReport {
+	var r ProfileReport
+	if vm.profiler == nil {
+		return r
+	}
+	vm.profiler.mu.Lock()
+	defer vm.profiler.mu.Unlock()
+	for pi, e := range vm.profiler.entries {
+		r.Entries = append(r.Entries, ProfileEntry{
+			Predicate:  pi.String(),
+			Calls:      e.calls,
+			Redos:      e.redos,
+			Inferences: e.inferences,
+			Time:       e.time,
+			pi:         pi,
+		})
+	}
+	slices.SortFunc(r.Entries, func(a, b ProfileEntry) int {
+		if c := cmp.Compare(b.Time, a.Time); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Predicate, b.Predicate)
+	})
+	return r
+}
+
+func (p *profiler) entry(pi procedureIndicator) *profileEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.entries[pi]
+	if !ok {
+		if p.entries == nil {
+			p.entries = map[procedureIndicator]*profileEntry{}
+		}
+		e = &profileEntry{}
+		p.entries[pi] = e
+	}
+	return e
+}
+
+// profileCall calls p and accounts the time and the inferences from the call or the redo to the exit, the failure, or
+// the error to the profile of pi.
+func (vm *VM) profileCall(pi procedureIndicator, p procedure, args []Term, traced bool, k Cont, env *Env) *Promise {
+	var (
+		prof       = vm.profiler
+		e          = prof.entry(pi)
+		outer      bool
+		inside     bool
+		start      time.Time
+		inferences uint64
+	)
+	enter := func(redo bool) {
+		prof.mu.Lock()
+		defer prof.mu.Unlock()
+		if redo {
+			e.redos++
+		} else {
+			e.calls++
+			outer = e.active == 0
+		}
+		e.active++
+		inside = true
+		start, inferences = vm.Now(), atomic.LoadUint64(&vm.inferences)
+		if !redo {
+			inferences-- // the call itself.
+		}
+	}
+	leave := func() {
+		prof.mu.Lock()
+		defer prof.mu.Unlock()
+		e.active--
+		inside = false
+		if outer {
+			e.time += vm.Now().Sub(start)
+			e.inferences += atomic.LoadUint64(&vm.inferences) - inferences
+		}
+	}
+
+	enter(false)
+	return catch(func(error) *Promise {
+		if inside {
+			leave()
+		}
+		return nil
+	}, func(context.Context) *Promise {
+		return Delay(func(context.Context) *Promise {
+			return vm.callProcedure(pi, p, args, traced, func(env *Env) *Promise {
+				leave()
+				return Delay(func(context.Context) *Promise {
+					return k(env)
+				}, func(context.Context) *Promise {
+					enter(true)
+					return Bool(false)
+				})
+			}, env)
+		}, func(context.Context) *Promise {
+			leave()
+			return Bool(false)
+		})
+	})
+}
+
+// Profile1 calls goal once with the VM profiling and logs the profiles of the predicates called by goal as
+// "predicate profiled" events at the info level. The profiles are available through statistics(profile, Profiles) until
+// the next call to profile/1.
+func Profile1(vm *VM, goal Term, k Cont, env *Env) *Promise {
+	return Delay(func(ctx context.Context) *Promise {
+		profiling := vm.profiling
+		vm.SetProfiling(true)
+		vm.ResetProfile()
+		var solution *Env
+		ok, err := Call(vm, goal, func(env *Env) *Promise {
+			solution = env
+			return Bool(true)
+		}, env).Force(ctx)
+		vm.SetProfiling(profiling)
+
+		for _, e := range vm.Profile().Entries {
+			vm.log(ctx, slog.LevelInfo, "predicate profiled",
+				slog.String("predicate", e.Predicate),
+				slog.Uint64("calls", e.Calls),
+				slog.Uint64("redos", e.Redos),
+				slog.Uint64("inferences", e.Inferences),
+				slog.Duration("duration", e.Time),
+			)
+		}
+
+		if err != nil {
+			return Error(err)
+		}
+		if !ok {
+			return Bool(false)
+		}
+		return k(solution)
+	})
+}
+
+// Inferences returns the number of the predicate calls so far.
+func (vm *VM) Inferences() uint64 {
+	return atomic.LoadUint64(&vm.inferences)
+}
+
+// Instructions returns the number of the bytecode instructions executed so far.
+func (vm *VM) Instructions() uint64 {
+	return atomic.LoadUint64(&vm.instructions)
+}
+
+// Statistics succeeds iff value unifies with the statistic identified by key:
+//
+//	inferences	the number of the predicate calls so far
+//	instructions	the number of the bytecode instructions executed so far
+//	gas	the units charged to the meter so far
+//	walltime	[T, D] where T is the milliseconds since the first statistics(walltime, _) and D is the milliseconds
+//		since the previous one, according to VM.Clock
+//	profile	the list of profile{calls: C, inferences: I, predicate: PI, redos: R, time: T} of the predicates called
+//		while the VM was profiling, the longest T first. T is in seconds.
+func Statistics(vm *VM, key, value Term, k Cont, env *Env) *Promise {
+	var v Term
+	switch key := env.Resolve(key).(type) {
+	case Variable:
+		return Error(InstantiationError(env))
+	case Atom:
+		switch key {
+		case atomInferences:
+			v = Integer(vm.Inferences())
+		case atomInstructions:
+			v = Integer(vm.Instructions())
+		case atomGas:
+			v = Integer(vm.GasUsed())
+		case atomWalltime:
+			now := vm.Now()
+			if vm.walltime.start.IsZero() {
+				vm.walltime.start, vm.walltime.last = now, now
+			}
+			v = List(Integer(now.Sub(vm.walltime.start).Milliseconds()), Integer(now.Sub(vm.walltime.last).Milliseconds()))
+			vm.walltime.last = now
+		case atomProfile:
+			entries := vm.Profile().Entries
+			ps := make([]Term, len(entries))
+			for i, e := range entries {
+				ps[i] = newDict(e.dictArgs())
+			}
+			v = List(ps...)
+		default:
+			return Error(domainError(validDomainStatisticsKey, key, env))
+		}
+	default:
+		return Error(typeError(validTypeAtom, key, env))
+	}
+	return Unify(vm, value, v, k, env)
+}
+
+// dictArgs returns the tag, the keys, and the values of the dict describing e.
+func (e ProfileEntry) dictArgs() []Term {
+	t := apd.New(e.Time.Nanoseconds(), -9)
+	t.Reduce(t)
+	return []Term{
+		atomProfile,
+		atomCalls, Integer(e.Calls),
+		atomInferences, Integer(e.Inferences),
+		atomPredicate, e.pi.Term(),
+		atomRedos, Integer(e.Redos),
+		atomTime, Float{dec: t},
+	}
+}
@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_SetProfiling(t *testing.T) {
+	newVM := func() *VM {
+		var vm VM
+		vm.Register2(NewAtom("is"), Is)
+		vm.Register1(NewAtom("throw"), Throw)
+		assert.NoError(t, vm.Compile(context.Background(), `
+:-(foo(X), bar(X)).
+bar(a).
+bar(b).
+len([], 0).
+:-(len('.'(_, T), N), ','(len(T, M), is(N, +(M, 1)))).
+:-(oops, throw(oops)).
+`))
+		vm.SetProfiling(true)
+		return &vm
+	}
+
+	counts := func(r ProfileReport) map[string][3]uint64 {
+		ret := map[string][3]uint64{}
+		for _, e := range r.Entries {
+			ret[e.Predicate] = [3]uint64{e.Calls, e.Redos, e.Inferences}
+		}
+		return ret
+	}
+
+	t.Run("nondeterministic", func(t *testing.T) {
+		vm := newVM()
+		ok, err := Call(vm, NewAtom("foo").Apply(NewVariable()), Failure, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, map[string][3]uint64{
+			"foo/1": {1, 2, 2},
+			"bar/1": {1, 2, 1},
+		}, counts(vm.Profile()))
+	})
+
+	t.Run("recursive", func(t *testing.T) {
+		vm := newVM()
+		ok, err := Call(vm, NewAtom("len").Apply(List(NewAtom("a"), NewAtom("b")), NewVariable()), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, map[string][3]uint64{
+			"len/2": {3, 0, 5},
+			"is/2":  {2, 0, 2},
+		}, counts(vm.Profile()))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		vm := newVM()
+		_, err := Call(vm, NewAtom("oops"), Success, nil).Force(context.Background())
+		assert.Error(t, err)
+		for _, e := range vm.profiler.entries {
+			assert.Zero(t, e.active)
+		}
+		assert.Equal(t, map[string][3]uint64{
+			"oops/0":  {1, 0, 2},
+			"throw/1": {1, 0, 1},
+		}, counts(vm.Profile()))
+	})
+
+	t.Run("off", func(t *testing.T) {
+		vm := newVM()
+		vm.SetProfiling(false)
+		ok, err := Call(vm, NewAtom("foo").Apply(NewAtom("a")), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Empty(t, vm.Profile().Entries)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		vm := newVM()
+		ok, err := Call(vm, NewAtom("foo").Apply(NewAtom("a")), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.NotEmpty(t, vm.Profile().Entries)
+		vm.ResetProfile()
+		assert.Empty(t, vm.Profile().Entries)
+	})
+}
+
+func TestProfile1(t *testing.T) {
+	var buf bytes.Buffer
+	var vm VM
+	vm.SetLogger(newTestLogHandler(&buf))
+	assert.NoError(t, vm.Compile(context.Background(), `
+:-(foo(X), bar(X)).
+bar(a).
+bar(b).
+`))
+
+	x := NewVariable()
+	ok, err := Profile1(&vm, NewAtom("foo").Apply(x), func(env *Env) *Promise {
+		assert.Equal(t, NewAtom("a"), env.Resolve(x))
+		return Bool(true)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, vm.profiling)
+	assert.Contains(t, buf.String(), `level=INFO msg="predicate profiled" predicate=foo/1 calls=1 redos=0 inferences=2
+`)
+	assert.Contains(t, buf.String(), `level=INFO msg="predicate profiled" predicate=bar/1 calls=1 redos=0 inferences=1
+`)
+
+	v := NewVariable()
+	ok, err = Statistics(&vm, atomProfile, v, func(env *Env) *Promise {
+		iter := ListIterator{List: v, Env: env}
+		assert.True(t, iter.Next())
+		d, ok := env.Resolve(iter.Current()).(Dict)
+		assert.True(t, ok)
+		assert.Equal(t, atomProfile, d.Tag())
+		calls, _ := d.Value(atomCalls)
+		assert.Equal(t, Integer(1), calls)
+		return Bool(true)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	t.Run("error", func(t *testing.T) {
+		ok, err := Profile1(&vm, NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		assert.False(t, ok)
+		assert.False(t, vm.profiling)
+	})
+}
+
+func TestStatistics(t *testing.T) {
+	var vm VM
+	vm.inferences = 42
+	vm.instructions = 1000
+
+	tests := []struct {
+		title      string
+		key, value Term
+		ok         bool
+		err        error
+	}{
+		{title: "inferences", key: atomInferences, value: Integer(42), ok: true},
+		{title: "instructions", key: atomInstructions, value: Integer(1000), ok: true},
+		{title: "gas", key: atomGas, value: Integer(0), ok: true},
+		{title: "profile", key: atomProfile, value: List(), ok: true},
+		{title: "key is a variable", key: NewVariable(), value: Integer(0), err: InstantiationError(nil)},
+		{title: "key is not an atom", key: Integer(0), value: Integer(0), err: typeError(validTypeAtom, Integer(0), nil)},
+		{title: "key is unknown", key: NewAtom("foo"), value: Integer(0), err: domainError(validDomainStatisticsKey, NewAtom("foo"), nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			ok, err := Statistics(&vm, tt.key, tt.value, Success, nil).Force(context.Background())
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+
+}
+
+func TestVM_Inferences(t *testing.T) {
+	var vm VM
+	assert.NoError(t, vm.Compile(context.Background(), `
+:-(foo, bar).
+bar.
+`))
+	assert.Zero(t, vm.Inferences())
+	assert.Zero(t, vm.Instructions())
+
+	ok, err := Call(&vm, NewAtom("foo"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), vm.Inferences())
+	instructions := vm.Instructions()
+	assert.NotZero(t, instructions)
+
+	ok, err = Call(&vm, NewAtom("foo"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(4), vm.Inferences())
+	assert.Equal(t, 2*instructions, vm.Instructions())
+}
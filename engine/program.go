@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"slices"
+
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+)
+
+// ErrProgramMismatch indicates that a Program can't be loaded into a VM whose syntax differs from the one of the VM
+// which compiled it, or which expands the terms it reads.
+var ErrProgramMismatch = errors.New("program compiled with a different syntax")
+
+// Program is a compiled Prolog text which can be loaded into VMs without parsing and compiling it again.
+// The directives of the text are executed again each time it's loaded.
+type Program struct {
+	syntax   [sha256.Size]byte
+	steps    []programStep
+	volatile bool
+}
+
+// programStep is either a directive, a module-qualified clause, or the compiled clauses of a procedure.
+type programStep struct {
+	directive    Term
+	moduleClause Term
+	pi           procedureIndicator
+	clauses      clauses
+}
+
+func (p *Program) record(s programStep) {
+	if p == nil {
+		return
+	}
+	p.steps = append(p.steps, s)
+}
+
+// CompileProgram compiles the Prolog text and updates the DB accordingly as Compile does.
+// It also returns the compiled text so that LoadProgram can load it later, or nil if it can't be replayed, e.g. if
+// the text is subject to term_expansion/2.
+func (vm *VM) CompileProgram(ctx context.Context, s string) (*Program, error) {
+	p := Program{syntax: vm.syntax()}
+	t := text{program: &p}
+	if _, err := vm.loadText(ctx, &t, func(t *text) error {
+		return vm.compile(ctx, t, s)
+	}); err != nil {
+		return nil, vm.AttachGoalHistory(err)
+	}
+	if p.volatile {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+// LoadProgram updates the DB with the Prolog text compiled by CompileProgram as if it were compiled again.
+// It returns ErrProgramMismatch without updating the DB if the operators, the flag double_quotes, or the character
+// conversions differ from the ones of the VM which compiled p since the text might have been read differently. So it
+// does if the VM defines term_expansion/2 or goal_expansion/2 since p holds the clauses and the directives unexpanded.
+func (vm *VM) LoadProgram(ctx context.Context, p *Program) error {
+	if p.syntax != vm.syntax() || vm.expanding() {
+		return ErrProgramMismatch
+	}
+	var t text
+	_, err := vm.loadText(ctx, &t, func(t *text) error {
+		return vm.replay(ctx, t, p)
+	})
+	return vm.AttachGoalHistory(err)
+}
+
+func (vm *VM) replay(ctx context.Context, text *text, p *Program) error {
+	if text.clauses == nil {
+		text.clauses = orderedmap.New[procedureIndicator, *userDefined]()
+	}
+
+	for _, s := range p.steps {
+		text.terms++
+		switch {
+		case s.directive != nil:
+			if err := vm.directive(ctx, text, s.directive); err != nil {
+				return err
+			}
+		case s.moduleClause != nil:
+			if err := vm.compileModuleClause(s.moduleClause); err != nil {
+				return err
+			}
+		default:
+			if err := text.add(s.pi, copyClauses(s.clauses)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyClauses returns a copy of cs which can be retracted independently.
+func copyClauses(cs clauses) clauses {
+	ret := make(clauses, len(cs))
+	for i, c := range cs {
+		c := *c
+		ret[i] = &c
+	}
+	return ret
+}
+
+// expanding reports whether the VM defines term_expansion/2 or goal_expansion/2.
+func (vm *VM) expanding() bool {
+	for _, name := range []Atom{atomTermExpansion, atomGoalExpansion} {
+		if _, ok := vm.getProcedure(procedureIndicator{name: name, arity: 2}); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// syntax returns a digest of the state of the VM which affects how Prolog texts are read.
+func (vm *VM) syntax() [sha256.Size]byte {
+	h := sha256.New()
+	for _, o := range vm.getOperators().canonical() {
+		_, _ = fmt.Fprintf(h, "%d %d %q;", o.priority, o.specifier, o.name)
+	}
+	_, _ = fmt.Fprintf(h, "%d %t", vm.doubleQuotes, vm.charConvEnabled)
+	convs := make([]rune, 0, len(vm.charConversions))
+	for r := range vm.charConversions {
+		convs = append(convs, r)
+	}
+	slices.Sort(convs)
+	for _, r := range convs {
+		_, _ = fmt.Fprintf(h, " %d:%d", r, vm.charConversions[r])
+	}
+	var ret [sha256.Size]byte
+	h.Sum(ret[:0])
+	return ret
+}
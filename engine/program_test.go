@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_CompileProgram(t *testing.T) {
+	text := `
+:-(op(700, xfx, ===>)).
+===>(a, b).
+===>(b, c).
+:-(dynamic('/'(counter, 1))).
+counter(0).
+:-(initialization(counter(0))).
+:(m, p(1)).
+`
+	newVM := func() *VM {
+		vm := VM{procedures: buildOrderedMap(
+			procedurePair{Key: procedureIndicator{name: atomOp, arity: 3}, Value: Predicate3(Op)},
+		)}
+		return &vm
+	}
+
+	solutions := func(vm *VM, goal Term, v Variable) []Term {
+		var ret []Term
+		_, err := Call(vm, goal, func(env *Env) *Promise {
+			ret = append(ret, env.Resolve(v))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		return ret
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		vm := newVM()
+		p, err := vm.CompileProgram(context.Background(), text)
+		assert.NoError(t, err)
+		assert.NotNil(t, p)
+
+		other := newVM()
+		assert.NoError(t, other.LoadProgram(context.Background(), p))
+
+		x := NewVariable()
+		for _, vm := range []*VM{vm, other} {
+			assert.Equal(t, []Term{NewAtom("a"), NewAtom("b")}, solutions(vm, NewAtom("===>").Apply(x, NewVariable()), x))
+			assert.Equal(t, []Term{Integer(1)}, solutions(vm, atomColon.Apply(NewAtom("m"), NewAtom("p").Apply(x)), x))
+			_, ok := vm.getOperators().Get(NewAtom("===>"))
+			assert.True(t, ok)
+		}
+
+		ok, err := Retract(other, NewAtom("counter").Apply(Integer(0)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Empty(t, solutions(other, NewAtom("counter").Apply(x), x))
+		assert.Equal(t, []Term{Integer(0)}, solutions(vm, NewAtom("counter").Apply(x), x))
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		vm := newVM()
+		p, err := vm.CompileProgram(context.Background(), `foo(`)
+		assert.Error(t, err)
+		assert.Nil(t, p)
+	})
+
+	t.Run("term_expansion", func(t *testing.T) {
+		vm := newVM()
+		assert.NoError(t, vm.Compile(context.Background(), `term_expansion(foo, bar).`))
+		p, err := vm.CompileProgram(context.Background(), `foo.`)
+		assert.NoError(t, err)
+		assert.Nil(t, p)
+	})
+
+	t.Run("expansion", func(t *testing.T) {
+		vm := newVM()
+		p, err := vm.CompileProgram(context.Background(), `foo.`)
+		assert.NoError(t, err)
+		assert.NotNil(t, p)
+
+		for _, hook := range []string{`term_expansion(foo, bar).`, `goal_expansion(foo, bar).`} {
+			other := newVM()
+			assert.NoError(t, other.Compile(context.Background(), hook))
+			assert.Equal(t, ErrProgramMismatch, other.LoadProgram(context.Background(), p))
+			_, ok := other.getProcedure(procedureIndicator{name: NewAtom("foo"), arity: 0})
+			assert.False(t, ok)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		vm := newVM()
+		p, err := vm.CompileProgram(context.Background(), text)
+		assert.NoError(t, err)
+
+		other := newVM()
+		other.doubleQuotes = doubleQuotesAtom
+		assert.Equal(t, ErrProgramMismatch, other.LoadProgram(context.Background(), p))
+		_, ok := other.getProcedure(procedureIndicator{name: NewAtom("===>"), arity: 2})
+		assert.False(t, ok)
+	})
+	t.Run("operators", func(t *testing.T) {
+		vm := newVM()
+		vm.getOperators().define(700, operatorSpecifierXFX, NewAtom("===>"))
+		vm.getOperators().define(200, operatorSpecifierFY, NewAtom("~~"))
+		p, err := vm.CompileProgram(context.Background(), `foo.`)
+		assert.NoError(t, err)
+
+		other := newVM()
+		other.getOperators().define(200, operatorSpecifierFY, NewAtom("~~"))
+		other.getOperators().define(700, operatorSpecifierXFX, NewAtom("===>"))
+		assert.NoError(t, other.LoadProgram(context.Background(), p))
+
+		other.getOperators().define(700, operatorSpecifierXFY, NewAtom("===>"))
+		assert.Equal(t, ErrProgramMismatch, other.LoadProgram(context.Background(), p))
+	})
+}
@@ -0,0 +1,252 @@
+// Package prologtest provides test doubles for the I/O of a VM: an in-memory file system, and a reader and a writer
+// whose results are scripted, so that predicates doing I/O can be tested without touching the real file system.
+package prologtest
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/axone-protocol/prolog/v3/engine"
+)
+
+// MemFS is a flat file system in memory. It's both readable and writable, e.g. by open/4 in write or append mode.
+// It has no directories other than the root, so the names of the files are the paths themselves.
+// The zero value is an empty file system. A MemFS is safe for concurrent use.
+type MemFS struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+var _ engine.OpenFileFS = (*MemFS)(nil)
+
+// NewMemFS returns a file system with the files mapped from their names to their contents.
+func NewMemFS(files map[string]string) *MemFS {
+	var m MemFS
+	for name, content := range files {
+		m.WriteFile(name, content)
+	}
+	return &m
+}
+
+// Open opens the file name for reading.
+func (m *MemFS) Open(name string) (fs.File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the file name with the flags of os.OpenFile, e.g. os.O_CREATE|os.O_WRONLY. perm is ignored.
+func (m *MemFS) OpenFile(name string, flag int, _ fs.FileMode) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[name]; !ok {
+		if flag&os.O_CREATE == 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		if m.files == nil {
+			m.files = map[string][]byte{}
+		}
+		m.files[name] = nil
+	}
+	if flag&os.O_TRUNC != 0 {
+		m.files[name] = nil
+	}
+	return &memFile{fs: m, name: name, flag: flag}, nil
+}
+
+// ReadFile returns the content of the file name.
+func (m *MemFS) ReadFile(name string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.files[name]
+	return string(b), ok
+}
+
+// WriteFile creates or replaces the file name with content.
+func (m *MemFS) WriteFile(name, content string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.files == nil {
+		m.files = map[string][]byte{}
+	}
+	m.files[name] = []byte(content)
+}
+
+// Names returns the names of the files in lexical order.
+func (m *MemFS) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+var errClosed = errors.New("file already closed")
+
+type memFile struct {
+	fs     *MemFS
+	name   string
+	flag   int
+	offset int64
+	closed bool
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	return memFileInfo{name: path.Base(f.name), size: int64(len(f.fs.files[f.name]))}, nil
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errClosed}
+	}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == os.O_WRONLY {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrPermission}
+	}
+
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	b := f.fs.files[f.name]
+	if f.offset >= int64(len(b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, b[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: errClosed}
+	}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrPermission}
+	}
+
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	b := f.fs.files[f.name]
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(b))
+	}
+	if end := f.offset + int64(len(p)); end > int64(len(b)) {
+		b = append(b, make([]byte, end-int64(len(b)))...)
+	}
+	copy(b[f.offset:], p)
+	f.fs.files[f.name] = b
+	f.offset += int64(len(p))
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.fs.files[f.name]))
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: errClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+type memFileInfo struct {
+	name string
+	size int64
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() fs.FileMode  { return 0644 }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return false }
+func (i memFileInfo) Sys() any           { return nil }
+
+// Chunk is a step of a ScriptedReader.
+type Chunk struct {
+	// Data is what the read returns. If it doesn't fit the buffer of the read, the rest is returned by the next reads.
+	Data string
+	// Err is the error the read returns with the last part of Data.
+	Err error
+}
+
+// ScriptedReader is an io.Reader which returns the chunks in order, e.g. to simulate a slow or failing source. It
+// returns io.EOF once the chunks are exhausted.
+type ScriptedReader struct {
+	chunks []Chunk
+}
+
+// NewScriptedReader returns a reader which returns chunks in order.
+func NewScriptedReader(chunks ...Chunk) *ScriptedReader {
+	return &ScriptedReader{chunks: chunks}
+}
+
+// Read returns the next chunk or the part of it which fits p.
+func (r *ScriptedReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	c := &r.chunks[0]
+	n := copy(p, c.Data)
+	if n < len(c.Data) {
+		c.Data = c.Data[n:]
+		return n, nil
+	}
+	err := c.Err
+	r.chunks = r.chunks[1:]
+	return n, err
+}
+
+// ScriptedWriter is an io.Writer which keeps what's written and fails the writes with scripted errors, e.g. to
+// simulate a full disk or a broken pipe. The zero value never fails.
+type ScriptedWriter struct {
+	// Errs are the errors of the successive writes. A write with a nil error or past Errs succeeds. A failed write
+	// writes nothing.
+	Errs []error
+
+	sb     strings.Builder
+	writes int
+}
+
+// Write appends p to the content unless the write is scripted to fail.
+func (w *ScriptedWriter) Write(p []byte) (int, error) {
+	i := w.writes
+	w.writes++
+	if i < len(w.Errs) && w.Errs[i] != nil {
+		return 0, w.Errs[i]
+	}
+	return w.sb.Write(p)
+}
+
+// String returns the content written so far.
+func (w *ScriptedWriter) String() string {
+	return w.sb.String()
+}
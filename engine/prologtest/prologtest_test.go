@@ -0,0 +1,97 @@
+package prologtest
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/axone-protocol/prolog/v3"
+)
+
+func TestMemFS(t *testing.T) {
+	t.Run("open/4", func(t *testing.T) {
+		fsys := NewMemFS(map[string]string{"in.txt": "hello."})
+		p := prolog.New(nil, nil)
+		p.FS = fsys
+		assert.NoError(t, p.Exec(`
+:- initialization((
+	open('in.txt', read, In), read_term(In, T, []), close(In),
+	open('out.txt', write, Out), write(Out, T), write(Out, '.'), close(Out),
+	open('out.txt', append, Out2), write(Out2, ' bye.'), close(Out2)
+)).
+`))
+		content, ok := fsys.ReadFile("out.txt")
+		assert.True(t, ok)
+		assert.Equal(t, "hello. bye.", content)
+		assert.Equal(t, []string{"in.txt", "out.txt"}, fsys.Names())
+
+		assert.Error(t, p.QuerySolution(`open('none.txt', read, _).`).Err())
+	})
+
+	t.Run("files", func(t *testing.T) {
+		var fsys MemFS
+		_, err := fsys.Open("foo")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		_, err = fsys.Open("../foo")
+		assert.ErrorIs(t, err, os.ErrInvalid)
+
+		f, err := fsys.OpenFile("foo", os.O_CREATE|os.O_RDWR, 0)
+		assert.NoError(t, err)
+		_, err = f.(io.Writer).Write([]byte("abcdef"))
+		assert.NoError(t, err)
+		_, err = f.(io.Seeker).Seek(2, io.SeekStart)
+		assert.NoError(t, err)
+		b, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, "cdef", string(b))
+		fi, err := f.Stat()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(6), fi.Size())
+		assert.NoError(t, f.Close())
+		assert.Error(t, f.Close())
+
+		f, err = fsys.OpenFile("foo", os.O_WRONLY|os.O_TRUNC, 0)
+		assert.NoError(t, err)
+		_, err = f.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, os.ErrPermission)
+		content, _ := fsys.ReadFile("foo")
+		assert.Empty(t, content)
+
+		f, err = fsys.Open("foo")
+		assert.NoError(t, err)
+		_, err = f.(io.Writer).Write([]byte("x"))
+		assert.ErrorIs(t, err, os.ErrPermission)
+	})
+}
+
+func TestScriptedReader(t *testing.T) {
+	errBroken := errors.New("broken")
+	r := NewScriptedReader(Chunk{Data: "abc"}, Chunk{Data: "de", Err: errBroken})
+	buf := make([]byte, 2)
+
+	n, err := r.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", string(buf[:n]))
+	n, err = r.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "c", string(buf[:n]))
+	n, err = r.Read(buf)
+	assert.Equal(t, errBroken, err)
+	assert.Equal(t, "de", string(buf[:n]))
+	_, err = r.Read(buf)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestScriptedWriter(t *testing.T) {
+	errFull := errors.New("full")
+	w := ScriptedWriter{Errs: []error{nil, errFull}}
+
+	p := prolog.New(nil, &w)
+	assert.NoError(t, p.Exec(`:- initialization(write(foo)).`))
+	assert.Error(t, p.QuerySolution(`write(bar), flush_output.`).Err())
+	assert.NoError(t, p.QuerySolution(`write(baz), flush_output.`).Err())
+	assert.Equal(t, "foobaz", w.String())
+}
@@ -99,7 +99,10 @@ func catch(recover func(error) *Promise, k PromiseFunc) *Promise {
 }
 
 // Force enforces the delayed execution and returns the result. (i.e. trampoline)
+// Unless ctx already carries one, the execution gets its own scratchpad which is discarded once Force returns.
 func (p *Promise) Force(ctx context.Context) (ok bool, err error) {
+	ctx = withScratchpad(ctx)
+	pad := scratchpadOf(ctx)
 	stack := promiseStack{p}
 	for len(stack) > 0 {
 		select {
@@ -133,6 +136,7 @@ func (p *Promise) Force(ctx context.Context) (ok bool, err error) {
 			if q == nil {
 				stack = append(stack, p)
 			} else {
+				pad.metrics.countPromise()
 				stack = append(stack, p, q)
 			}
 		}
@@ -187,6 +191,7 @@ func makeNextFunc(k ...PromiseFunc) NextFunc {
 			return nil, false
 		}
 		f := k[0]
+		k[0] = nil // release the consumed function so that what it captures can be collected.
 		k = k[1:]
 		return f, true
 	}
@@ -0,0 +1,89 @@
+package engine_test
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/axone-protocol/prolog/v3/engine"
+	"github.com/axone-protocol/prolog/v3/engine/termtest"
+)
+
+func TestCompareNumbers(t *testing.T) {
+	maxFloat, err := engine.NewFloatFromString("9223372036854775807.5")
+	assert.NoError(t, err)
+	minFloat, err := engine.NewFloatFromString("-9223372036854775808.5")
+	assert.NoError(t, err)
+	q, err := engine.NewRational(math.MaxInt64, math.MaxInt64-1)
+	assert.NoError(t, err)
+	ns := []engine.Number{engine.Integer(math.MaxInt64), engine.Integer(math.MinInt64), maxFloat, minFloat, q}
+	g := termtest.NewGenerator(1, termtest.Config{})
+	for range 75 {
+		ns = append(ns, g.Number())
+	}
+
+	less := func(x, y engine.Number) bool {
+		ok, err := engine.LessThan(nil, x, y, engine.Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		return ok
+	}
+
+	t.Run("trichotomy", func(t *testing.T) {
+		for _, x := range ns {
+			for _, y := range ns {
+				c := x.Compare(y, nil)
+				assert.Equal(t, -sign(c), sign(y.Compare(x, nil)), "%s %s", x, y)
+				assert.Equal(t, c == 0, x == y || floatEq(x, y), "%s %s", x, y)
+				if less(x, y) {
+					assert.Equal(t, -1, c, "%s %s", x, y)
+				}
+			}
+		}
+	})
+
+	t.Run("transitivity", func(t *testing.T) {
+		for _, x := range ns {
+			for _, y := range ns {
+				for _, z := range ns {
+					if x.Compare(y, nil) <= 0 && y.Compare(z, nil) <= 0 {
+						assert.LessOrEqual(t, x.Compare(z, nil), 0, "%s %s %s", x, y, z)
+					}
+				}
+			}
+		}
+	})
+}
+
+func TestTerm_Compare(t *testing.T) {
+	g := termtest.NewGenerator(2, termtest.Config{})
+
+	t.Run("trichotomy", func(t *testing.T) {
+		termtest.Check(t, g, 500, func(x engine.Term) bool {
+			y := g.Term()
+			return x.Compare(x, nil) == 0 && sign(x.Compare(y, nil)) == -sign(y.Compare(x, nil))
+		})
+	})
+
+	t.Run("transitivity", func(t *testing.T) {
+		termtest.Check(t, g, 500, func(x engine.Term) bool {
+			y, z := g.Term(), g.Term()
+			return x.Compare(y, nil) > 0 || y.Compare(z, nil) > 0 || x.Compare(z, nil) <= 0
+		})
+	})
+}
+
+func sign(c int) int {
+	return cmp.Compare(c, 0)
+}
+
+func floatEq(x, y engine.Number) bool {
+	fx, ok := x.(engine.Float)
+	if !ok {
+		return false
+	}
+	fy, ok := y.(engine.Float)
+	return ok && fx.Eq(fy)
+}
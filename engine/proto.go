@@ -0,0 +1,440 @@
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+
+	"github.com/cockroachdb/apd/v3"
+)
+
+// Terms are encoded in the protobuf wire format as the message prolog.v1.Term of proto/prolog/v1/term.proto.
+
+// Field numbers of prolog.v1.Term.
+const (
+	protoTermAtom     = 1
+	protoTermInteger  = 2
+	protoTermFloat    = 3
+	protoTermCompound = 4
+	protoTermDict     = 5
+	protoTermVariable = 6
+	protoTermString   = 7
+	protoTermRational = 8
+	protoTermList     = 9
+)
+
+// Wire types.
+const (
+	protoVarint = 0
+	protoI64    = 1
+	protoLen    = 2
+	protoI32    = 5
+)
+
+// protoMaxNesting is the maximum depth of the decoded messages so that a malicious input can't exhaust the stack.
+const protoMaxNesting = 1 << 10
+
+var (
+	errProtoMalformed   = errors.New("malformed protobuf term")
+	errProtoUnsupported = errors.New("term not representable in protobuf")
+)
+
+// TermToProto returns the encoding of t as a prolog.v1.Term message. The floats which compare equal, e.g. 1.0 and
+// 1.00, have the same encoding. Cyclic terms are not representable.
+func TermToProto(t Term, env *Env) ([]byte, error) {
+	e := protoEncoder{vars: map[Variable]uint64{}, visiting: map[termID]struct{}{}, env: env}
+	return e.term(nil, t)
+}
+
+// TermFromProto returns the term encoded in the prolog.v1.Term message data. The variables are fresh.
+// The unknown fields are skipped.
+func TermFromProto(data []byte) (Term, error) {
+	d := protoDecoder{vars: map[uint64]Variable{}}
+	return d.term(data, 0)
+}
+
+func appendProtoTag(b []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	return binary.AppendUvarint(appendProtoTag(b, field, protoVarint), v)
+}
+
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(appendProtoTag(b, field, protoLen), uint64(len(v)))
+	return append(b, v...)
+}
+
+func zigzag(i int64) uint64 {
+	return uint64(i<<1) ^ uint64(i>>63)
+}
+
+func unzigzag(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+type protoEncoder struct {
+	vars     map[Variable]uint64
+	visiting map[termID]struct{} // the compounds enclosing the term being encoded.
+	env      *Env
+}
+
+// enter marks the compound c as being encoded. It fails if c encloses itself.
+func (e *protoEncoder) enter(c Compound) error {
+	if _, ok := e.visiting[id(c)]; ok {
+		return errProtoUnsupported
+	}
+	e.visiting[id(c)] = struct{}{}
+	return nil
+}
+
+// term appends the fields of the message Term of t to b.
+func (e *protoEncoder) term(b []byte, t Term) ([]byte, error) {
+	t = e.env.Resolve(t)
+	if c, ok := t.(Compound); ok {
+		if err := e.enter(c); err != nil {
+			return nil, err
+		}
+		defer delete(e.visiting, id(c))
+	}
+
+	switch t := t.(type) {
+	case Variable:
+		n, ok := e.vars[t]
+		if !ok {
+			n = uint64(len(e.vars))
+			e.vars[t] = n
+		}
+		return appendProtoVarint(b, protoTermVariable, n), nil
+	case Atom:
+		return appendProtoBytes(b, protoTermAtom, []byte(t.String())), nil
+	case Integer:
+		return appendProtoVarint(b, protoTermInteger, zigzag(int64(t))), nil
+	case Float:
+		var dec apd.Decimal // Without the trailing zeros of the coefficient so that equal floats are encoded alike.
+		dec.Reduce(t.dec)
+		var m []byte // The fields with their default values are omitted as in proto3.
+		if c := dec.Coeff.MathBigInt().Bytes(); len(c) > 0 {
+			m = appendProtoBytes(m, 1, c)
+		}
+		if dec.Exponent != 0 {
+			m = appendProtoVarint(m, 2, zigzag(int64(dec.Exponent)))
+		}
+		if dec.Negative && !dec.IsZero() {
+			m = appendProtoVarint(m, 3, 1)
+		}
+		return appendProtoBytes(b, protoTermFloat, m), nil
+	case Rational:
+		var m []byte
+		m = appendProtoVarint(m, 1, zigzag(int64(t.num)))
+		m = appendProtoVarint(m, 2, zigzag(int64(t.den)))
+		return appendProtoBytes(b, protoTermRational, m), nil
+	case String:
+		return appendProtoBytes(b, protoTermString, []byte(t)), nil
+	case Dict:
+		m, err := e.message(nil, 1, t.Tag())
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range t.All() {
+			entry := appendProtoBytes(nil, 1, []byte(k.String()))
+			if entry, err = e.message(entry, 2, v); err != nil {
+				return nil, err
+			}
+			m = appendProtoBytes(m, 2, entry)
+		}
+		return appendProtoBytes(b, protoTermDict, m), nil
+	case Compound:
+		if t.Functor() == atomDot && t.Arity() == 2 {
+			return e.list(b, t)
+		}
+		m := appendProtoBytes(nil, 1, []byte(t.Functor().String()))
+		for i := 0; i < t.Arity(); i++ {
+			var err error
+			if m, err = e.message(m, 2, t.Arg(i)); err != nil {
+				return nil, err
+			}
+		}
+		return appendProtoBytes(b, protoTermCompound, m), nil
+	default:
+		return nil, errProtoUnsupported
+	}
+}
+
+// message appends t as the message Term in the field of b.
+func (e *protoEncoder) message(b []byte, field int, t Term) ([]byte, error) {
+	m, err := e.term(nil, t)
+	if err != nil {
+		return nil, err
+	}
+	return appendProtoBytes(b, field, m), nil
+}
+
+func (e *protoEncoder) list(b []byte, t Term) ([]byte, error) {
+	var (
+		m       []byte
+		entered []termID
+	)
+	defer func() {
+		for _, id := range entered {
+			delete(e.visiting, id)
+		}
+	}()
+	for {
+		c, ok := e.env.Resolve(t).(Compound)
+		if !ok || c.Functor() != atomDot || c.Arity() != 2 {
+			break
+		}
+		if len(m) > 0 { // The first cell is entered by term.
+			if err := e.enter(c); err != nil {
+				return nil, err
+			}
+			entered = append(entered, id(c))
+		}
+		var err error
+		if m, err = e.message(m, 1, c.Arg(0)); err != nil {
+			return nil, err
+		}
+		t = c.Arg(1)
+	}
+	if t := e.env.Resolve(t); t != atomEmptyList {
+		var err error
+		if m, err = e.message(m, 2, t); err != nil {
+			return nil, err
+		}
+	}
+	return appendProtoBytes(b, protoTermList, m), nil
+}
+
+type protoDecoder struct {
+	vars map[uint64]Variable
+}
+
+// protoField is a field of a message. v is the value of a varint field and data is the value of a length-delimited
+// field.
+type protoField struct {
+	num  int
+	v    uint64
+	data []byte
+}
+
+// protoFields calls f with the varint and the length-delimited fields of the message b in order and skips the others.
+func protoFields(b []byte, f func(protoField) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 || tag>>3 == 0 || tag>>3 > math.MaxInt32 {
+			return errProtoMalformed
+		}
+		b = b[n:]
+		field := protoField{num: int(tag >> 3)}
+		switch tag & 7 {
+		case protoVarint:
+			if field.v, n = binary.Uvarint(b); n <= 0 {
+				return errProtoMalformed
+			}
+			b = b[n:]
+		case protoLen:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return errProtoMalformed
+			}
+			field.data = b[n : n+int(l)]
+			b = b[n+int(l):]
+		case protoI64, protoI32:
+			size := 8
+			if tag&7 == protoI32 {
+				size = 4
+			}
+			if len(b) < size {
+				return errProtoMalformed
+			}
+			b = b[size:]
+			continue
+		default:
+			return errProtoMalformed
+		}
+		if err := f(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *protoDecoder) term(b []byte, depth int) (Term, error) {
+	if depth > protoMaxNesting {
+		return nil, errProtoMalformed
+	}
+	var t Term
+	err := protoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case protoTermAtom:
+			t = NewAtom(string(f.data))
+		case protoTermInteger:
+			t = Integer(unzigzag(f.v))
+		case protoTermFloat:
+			t, err = d.float(f.data)
+		case protoTermCompound:
+			t, err = d.compound(f.data, depth)
+		case protoTermDict:
+			t, err = d.dict(f.data, depth)
+		case protoTermVariable:
+			v, ok := d.vars[f.v]
+			if !ok {
+				v = NewVariable()
+				d.vars[f.v] = v
+			}
+			t = v
+		case protoTermString:
+			t = String(f.data)
+		case protoTermRational:
+			t, err = d.rational(f.data)
+		case protoTermList:
+			t, err = d.list(f.data, depth)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, errProtoMalformed
+	}
+	return t, nil
+}
+
+func (d *protoDecoder) float(b []byte) (Term, error) {
+	var dec apd.Decimal
+	if err := protoFields(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			dec.Coeff.SetMathBigInt(new(big.Int).SetBytes(f.data))
+		case 2:
+			e := unzigzag(f.v)
+			if e < math.MinInt32 || e > math.MaxInt32 {
+				return errProtoMalformed
+			}
+			dec.Exponent = int32(e)
+		case 3:
+			dec.Negative = f.v != 0
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return Float{dec: &dec}, nil
+}
+
+func (d *protoDecoder) rational(b []byte) (Term, error) {
+	var num, den Integer
+	if err := protoFields(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			num = Integer(unzigzag(f.v))
+		case 2:
+			den = Integer(unzigzag(f.v))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	r, err := NewRational(num, den)
+	if err != nil {
+		return nil, errProtoMalformed
+	}
+	return r, nil
+}
+
+func (d *protoDecoder) compound(b []byte, depth int) (Term, error) {
+	var (
+		functor Atom
+		args    []Term
+	)
+	if err := protoFields(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			functor = NewAtom(string(f.data))
+		case 2:
+			arg, err := d.term(f.data, depth+1)
+			if err != nil {
+				return err
+			}
+			args = append(args, arg)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, errProtoMalformed
+	}
+	return functor.Apply(args...), nil
+}
+
+func (d *protoDecoder) list(b []byte, depth int) (Term, error) {
+	var (
+		elems []Term
+		tail  Term = atomEmptyList
+	)
+	if err := protoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1:
+			var e Term
+			if e, err = d.term(f.data, depth+1); err == nil {
+				elems = append(elems, e)
+			}
+		case 2:
+			tail, err = d.term(f.data, depth+1)
+		}
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return PartialList(tail, elems...), nil
+}
+
+func (d *protoDecoder) dict(b []byte, depth int) (Term, error) {
+	args := []Term{nil}
+	if err := protoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1:
+			args[0], err = d.term(f.data, depth+1)
+		case 2:
+			var (
+				key   Atom
+				value Term
+			)
+			if err := protoFields(f.data, func(f protoField) error {
+				var err error
+				switch f.num {
+				case 1:
+					key = NewAtom(string(f.data))
+				case 2:
+					value, err = d.term(f.data, depth+1)
+				}
+				return err
+			}); err != nil {
+				return err
+			}
+			if value == nil {
+				return errProtoMalformed
+			}
+			args = append(args, key, value)
+		}
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if args[0] == nil {
+		args[0] = NewVariable()
+	}
+	dict, err := NewDict(args)
+	if err != nil {
+		return nil, errProtoMalformed
+	}
+	return dict, nil
+}
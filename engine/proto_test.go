@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTermToProto(t *testing.T) {
+	x, y := NewVariable(), NewVariable()
+	f, err := NewFloatFromString("-1.25")
+	assert.NoError(t, err)
+	big, err := NewFloatFromString("123456789012345678901234567890.5")
+	assert.NoError(t, err)
+	zero, err := NewFloatFromString("0")
+	assert.NoError(t, err)
+	r, err := NewRational(-1, 3)
+	assert.NoError(t, err)
+	d, err := NewDict([]Term{NewAtom("t"), NewAtom("b"), x, NewAtom("a"), String("s")})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		title string
+		term  Term
+		proto []byte
+	}{
+		{title: "atom", term: NewAtom("a"), proto: []byte{0x0a, 0x01, 'a'}},
+		{title: "integer", term: Integer(-1), proto: []byte{0x10, 0x01}},
+		{title: "float", term: f, proto: []byte{0x1a, 0x07, 0x0a, 0x01, 0x7d, 0x10, 0x03, 0x18, 0x01}},
+		{title: "zero", term: zero, proto: []byte{0x1a, 0x00}},
+		{title: "compound", term: NewAtom("f").Apply(x, y, x), proto: []byte{
+			0x22, 0x0f, 0x0a, 0x01, 'f',
+			0x12, 0x02, 0x30, 0x00,
+			0x12, 0x02, 0x30, 0x01,
+			0x12, 0x02, 0x30, 0x00,
+		}},
+		{title: "string", term: String("s"), proto: []byte{0x3a, 0x01, 's'}},
+		{title: "rational", term: r, proto: []byte{0x42, 0x04, 0x08, 0x01, 0x10, 0x06}},
+		{title: "list", term: List(Integer(1), Integer(2)), proto: []byte{0x4a, 0x08, 0x0a, 0x02, 0x10, 0x02, 0x0a, 0x02, 0x10, 0x04}},
+		{title: "partial list", term: PartialList(x, Integer(1)), proto: []byte{0x4a, 0x08, 0x0a, 0x02, 0x10, 0x02, 0x12, 0x02, 0x30, 0x00}},
+		{title: "dict", term: d},
+		{title: "bignum", term: big},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			b, err := TermToProto(tt.term, nil)
+			assert.NoError(t, err)
+			if tt.proto != nil {
+				assert.Equal(t, tt.proto, b)
+			}
+
+			d, err := TermFromProto(b)
+			assert.NoError(t, err)
+			assert.Equal(t, variantKey(tt.term, nil), variantKey(d, nil))
+		})
+	}
+
+	t.Run("bindings", func(t *testing.T) {
+		b, err := TermToProto(x, NewEnv().bind(x, NewAtom("a")))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0x0a, 0x01, 'a'}, b)
+	})
+
+	t.Run("not representable", func(t *testing.T) {
+		_, err := TermToProto(&Stream{}, nil)
+		assert.Equal(t, errProtoUnsupported, err)
+	})
+
+	t.Run("cyclic", func(t *testing.T) {
+		x := NewVariable()
+		f := NewAtom("f").Apply(x)
+		_, err := TermToProto(f, NewEnv().bind(x, f))
+		assert.Equal(t, errProtoUnsupported, err)
+
+		l := PartialList(x, NewAtom("a"))
+		_, err = TermToProto(l, NewEnv().bind(x, l))
+		assert.Equal(t, errProtoUnsupported, err)
+	})
+
+	t.Run("equal floats", func(t *testing.T) {
+		for _, s := range []string{"1.0", "100.0", "0.0", "-0.0"} {
+			f, err := NewFloatFromString(s)
+			assert.NoError(t, err)
+			g, err := NewFloatFromString(s + "0")
+			assert.NoError(t, err)
+			a, err := TermToProto(f, nil)
+			assert.NoError(t, err)
+			b, err := TermToProto(g, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, a, b, s)
+		}
+	})
+}
+
+func TestTermFromProto(t *testing.T) {
+	t.Run("unknown fields", func(t *testing.T) {
+		term, err := TermFromProto([]byte{
+			0x78, 0x01, // field 15, varint
+			0x81, 0x01, 0, 0, 0, 0, 0, 0, 0, 0, // field 16, i64
+			0x0a, 0x01, 'a',
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, NewAtom("a"), term)
+	})
+
+	t.Run("dict without tag", func(t *testing.T) {
+		term, err := TermFromProto([]byte{0x2a, 0x07, 0x12, 0x05, 0x0a, 0x01, 'k', 0x12, 0x00})
+		assert.Equal(t, errProtoMalformed, err) // The value of k is an empty Term.
+		assert.Nil(t, term)
+
+		term, err = TermFromProto([]byte{0x2a, 0x09, 0x12, 0x07, 0x0a, 0x01, 'k', 0x12, 0x02, 0x10, 0x02})
+		assert.NoError(t, err)
+		d, ok := term.(Dict)
+		assert.True(t, ok)
+		v, ok := d.Value(NewAtom("k"))
+		assert.True(t, ok)
+		assert.Equal(t, Integer(1), v)
+	})
+
+	for _, tt := range []struct {
+		title string
+		proto []byte
+	}{
+		{title: "empty", proto: nil},
+		{title: "truncated", proto: []byte{0x0a, 0x02, 'a'}},
+		{title: "bad varint", proto: []byte{0x10, 0x80}},
+		{title: "field zero", proto: []byte{0x00, 0x01}},
+		{title: "group", proto: []byte{0x0b}},
+		{title: "compound without arguments", proto: []byte{0x22, 0x03, 0x0a, 0x01, 'f'}},
+		{title: "zero denominator", proto: []byte{0x42, 0x02, 0x08, 0x02}},
+		{title: "duplicate keys", proto: []byte{
+			0x2a, 0x12,
+			0x12, 0x07, 0x0a, 0x01, 'k', 0x12, 0x02, 0x10, 0x02,
+			0x12, 0x07, 0x0a, 0x01, 'k', 0x12, 0x02, 0x10, 0x04,
+		}},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			_, err := TermFromProto(tt.proto)
+			assert.Equal(t, errProtoMalformed, err)
+		})
+	}
+}
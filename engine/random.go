@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"math/rand/v2"
+)
+
+// SetRandomSeed seeds the pseudo-random number generator of the VM, e.g. with a block hash.
+// The generator never draws from the entropy of the system: until seeded, it behaves as if seeded with 0. So, the
+// random predicates give the same results on every node running the same program with the same seed.
+func (vm *VM) SetRandomSeed(seed uint64) {
+	vm.random.Seed(seed, 0)
+}
+
+func (vm *VM) rand() *rand.Rand {
+	return rand.New(&vm.random)
+}
+
+// SetRandom seeds the pseudo-random number generator of the VM with seed(S) where S is an integer.
+func SetRandom(vm *VM, option Term, k Cont, env *Env) *Promise {
+	switch o := env.Resolve(option).(type) {
+	case Variable:
+		return Error(InstantiationError(env))
+	case Compound:
+		if o.Functor() != atomSeed || o.Arity() != 1 {
+			return Error(domainError(validDomainSetRandom, o, env))
+		}
+		switch s := env.Resolve(o.Arg(0)).(type) {
+		case Variable:
+			return Error(InstantiationError(env))
+		case Integer:
+			vm.SetRandomSeed(uint64(s))
+			return k(env)
+		default:
+			return Error(typeError(validTypeInteger, s, env))
+		}
+	default:
+		return Error(domainError(validDomainSetRandom, o, env))
+	}
+}
+
+// RandomBetween unifies value with a pseudo-random integer such that lower <= value <= upper. It fails if lower >
+// upper.
+func RandomBetween(vm *VM, lower, upper, value Term, k Cont, env *Env) *Promise {
+	var low, high Integer
+
+	switch lower := env.Resolve(lower).(type) {
+	case Integer:
+		low = lower
+	case Variable:
+		return Error(InstantiationError(env))
+	default:
+		return Error(typeError(validTypeInteger, lower, env))
+	}
+
+	switch upper := env.Resolve(upper).(type) {
+	case Integer:
+		high = upper
+	case Variable:
+		return Error(InstantiationError(env))
+	default:
+		return Error(typeError(validTypeInteger, upper, env))
+	}
+
+	if low > high {
+		return Bool(false)
+	}
+
+	var n uint64
+	if d := uint64(high) - uint64(low); d == ^uint64(0) {
+		n = vm.rand().Uint64()
+	} else {
+		n = vm.rand().Uint64N(d + 1)
+	}
+	return Unify(vm, value, low+Integer(n), k, env)
+}
+
+// RandomMember unifies elem with a pseudo-randomly chosen element of list. It fails if list is empty.
+func RandomMember(vm *VM, elem, list Term, k Cont, env *Env) *Promise {
+	var elems []Term
+	iter := ListIterator{List: list, Env: env}
+	for iter.Next() {
+		elems = append(elems, iter.Current())
+	}
+	if err := iter.Err(); err != nil {
+		return Error(err)
+	}
+
+	if len(elems) == 0 {
+		return Bool(false)
+	}
+	return Unify(vm, elem, elems[vm.rand().IntN(len(elems))], k, env)
+}
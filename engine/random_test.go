@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetRandom(t *testing.T) {
+	tests := []struct {
+		title  string
+		option Term
+		err    error
+	}{
+		{title: "seed", option: atomSeed.Apply(Integer(42))},
+		{title: "negative seed", option: atomSeed.Apply(Integer(-1))},
+		{title: "option is a variable", option: NewVariable(), err: InstantiationError(nil)},
+		{title: "seed is a variable", option: atomSeed.Apply(NewVariable()), err: InstantiationError(nil)},
+		{title: "seed is not an integer", option: atomSeed.Apply(NewAtom("foo")), err: typeError(validTypeInteger, NewAtom("foo"), nil)},
+		{title: "unknown option", option: NewAtom("random"), err: domainError(validDomainSetRandom, NewAtom("random"), nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			var vm VM
+			ok, err := SetRandom(&vm, tt.option, Success, nil).Force(context.Background())
+			assert.Equal(t, tt.err == nil, ok)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestRandomBetween(t *testing.T) {
+	draw := func(vm *VM, low, high Integer) Integer {
+		v := NewVariable()
+		var n Integer
+		ok, err := RandomBetween(vm, low, high, v, func(env *Env) *Promise {
+			n = env.Resolve(v).(Integer)
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		return n
+	}
+
+	t.Run("deterministic", func(t *testing.T) {
+		var a, b VM
+		a.SetRandomSeed(42)
+		b.SetRandomSeed(42)
+		for range 10 {
+			n := draw(&a, 1, 6)
+			assert.Equal(t, n, draw(&b, 1, 6))
+			assert.GreaterOrEqual(t, n, Integer(1))
+			assert.LessOrEqual(t, n, Integer(6))
+		}
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		var vm VM
+		vm.SetRandomSeed(7)
+		c := vm.Clone()
+		assert.Equal(t, draw(&vm, 0, 1000), draw(c, 0, 1000))
+	})
+
+	t.Run("single value", func(t *testing.T) {
+		var vm VM
+		assert.Equal(t, Integer(3), draw(&vm, 3, 3))
+	})
+
+	t.Run("full range", func(t *testing.T) {
+		var vm VM
+		_ = draw(&vm, Integer(-1<<63), Integer(1<<63-1))
+	})
+
+	t.Run("empty range", func(t *testing.T) {
+		var vm VM
+		ok, err := RandomBetween(&vm, Integer(2), Integer(1), NewVariable(), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("lower is not an integer", func(t *testing.T) {
+		var vm VM
+		_, err := RandomBetween(&vm, NewAtom("a"), Integer(1), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeInteger, NewAtom("a"), nil), err)
+	})
+
+	t.Run("upper is a variable", func(t *testing.T) {
+		var vm VM
+		_, err := RandomBetween(&vm, Integer(1), NewVariable(), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+	})
+}
+
+func TestRandomMember(t *testing.T) {
+	t.Run("member", func(t *testing.T) {
+		var vm VM
+		vm.SetRandomSeed(1)
+		v := NewVariable()
+		ok, err := RandomMember(&vm, v, List(NewAtom("a"), NewAtom("b"), NewAtom("c")), func(env *Env) *Promise {
+			assert.Contains(t, []Term{NewAtom("a"), NewAtom("b"), NewAtom("c")}, env.Resolve(v))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		var vm VM
+		ok, err := RandomMember(&vm, NewVariable(), List(), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("partial list", func(t *testing.T) {
+		var vm VM
+		_, err := RandomMember(&vm, NewVariable(), PartialList(NewVariable(), NewAtom("a")), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+	})
+}
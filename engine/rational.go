@@ -0,0 +1,288 @@
+package engine
+
+import (
+	"io"
+	"math/big"
+	"strconv"
+
+	"github.com/cockroachdb/apd/v3"
+)
+
+// Rational is a prolog rational number which is not an integer.
+//
+// It's always in its canonical form: the denominator is greater than 1 and coprime with the numerator.
+// Both the numerator and the denominator are bounded as Integer is.
+type Rational struct {
+	num, den Integer
+}
+
+// NewRational returns num/den in its canonical form. It returns an Integer if den divides num.
+func NewRational(num, den Integer) (Number, error) {
+	if den == 0 {
+		return nil, exceptionalValueZeroDivisor
+	}
+	return ratToNumber(new(big.Rat).SetFrac(big.NewInt(int64(num)), big.NewInt(int64(den))))
+}
+
+// Num returns the numerator of r.
+func (r Rational) Num() Integer {
+	return r.num
+}
+
+// Den returns the denominator of r.
+func (r Rational) Den() Integer {
+	return r.den
+}
+
+func (r Rational) number() {}
+
+// WriteTerm outputs the Rational to an io.Writer in the form NrD.
+func (r Rational) WriteTerm(w io.Writer, opts *WriteOptions, env *Env) error {
+	ew := errWriter{w: w}
+	_ = r.num.WriteTerm(&ew, opts.withRight(operator{}), env)
+	_, _ = ew.Write([]byte("r" + strconv.FormatInt(int64(r.den), 10)))
+	if opts.right != (operator{}) && (letterDigit(opts.right.name) || (needQuoted(opts.right.name) && opts.right.name != atomComma && opts.right.name != atomBar)) {
+		_, _ = ew.Write([]byte(" "))
+	}
+	return ew.err
+}
+
+// Compare compares the Rational with a Term.
+func (r Rational) Compare(t Term, env *Env) int {
+	env.charge(MeterCompareStep, 1)
+	switch t := env.Resolve(t).(type) {
+	case Variable:
+		return 1
+	case Number:
+		return compareNumbers(r, t)
+	default: // Atom, custom atomic terms, Compound.
+		return -1
+	}
+}
+
+func (r Rational) rat() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(int64(r.num)), big.NewInt(int64(r.den)))
+}
+
+// ratToNumber returns x as either Integer or Rational.
+func ratToNumber(x *big.Rat) (Number, error) {
+	num, den := x.Num(), x.Denom()
+	if !num.IsInt64() || !den.IsInt64() {
+		return nil, exceptionalValueIntOverflow
+	}
+	if x.IsInt() {
+		return Integer(num.Int64()), nil
+	}
+	return Rational{num: Integer(num.Int64()), den: Integer(den.Int64())}, nil
+}
+
+// ratOf returns x as an exact big.Rat. x must be either Integer, Float, or Rational.
+func ratOf(x Number) *big.Rat {
+	switch x := x.(type) {
+	case Integer:
+		return new(big.Rat).SetInt64(int64(x))
+	case Rational:
+		return x.rat()
+	case Float:
+		coeff := new(big.Int).Set(x.dec.Coeff.MathBigInt())
+		if x.dec.Negative {
+			coeff.Neg(coeff)
+		}
+		pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(absInt32(x.dec.Exponent))), nil)
+		r := new(big.Rat).SetInt(coeff)
+		if x.dec.Exponent < 0 {
+			return r.Quo(r, new(big.Rat).SetInt(pow))
+		}
+		return r.Mul(r, new(big.Rat).SetInt(pow))
+	default:
+		return nil
+	}
+}
+
+func absInt32(n int32) int64 {
+	if n < 0 {
+		return -int64(n)
+	}
+	return int64(n)
+}
+
+// isRational tells if x is either Integer or Rational.
+func isRational(x Number) bool {
+	switch x.(type) {
+	case Integer, Rational:
+		return true
+	default:
+		return false
+	}
+}
+
+// rdiv returns the exact division of 2 integers or rationals.
+func rdiv(x, y Number) (Number, error) {
+	if !isRational(x) {
+		return nil, typeError(validTypeRational, x, nil)
+	}
+	if !isRational(y) {
+		return nil, typeError(validTypeRational, y, nil)
+	}
+	return divR(x, y)
+}
+
+// cmpR compares 2 numbers exactly. At least one of them is expected to be Rational.
+func cmpR(x, y Number) int {
+	return ratOf(x).Cmp(ratOf(y))
+}
+
+func addR(x, y Number) (Number, error) {
+	return ratToNumber(new(big.Rat).Add(ratOf(x), ratOf(y)))
+}
+
+func subR(x, y Number) (Number, error) {
+	return ratToNumber(new(big.Rat).Sub(ratOf(x), ratOf(y)))
+}
+
+func mulR(x, y Number) (Number, error) {
+	return ratToNumber(new(big.Rat).Mul(ratOf(x), ratOf(y)))
+}
+
+func divR(x, y Number) (Number, error) {
+	d := ratOf(y)
+	if d.Sign() == 0 {
+		return nil, exceptionalValueZeroDivisor
+	}
+	return ratToNumber(new(big.Rat).Quo(ratOf(x), d))
+}
+
+func negR(x Rational) (Number, error) {
+	return ratToNumber(new(big.Rat).Neg(x.rat()))
+}
+
+func absR(x Rational) (Number, error) {
+	return ratToNumber(new(big.Rat).Abs(x.rat()))
+}
+
+func signR(x Rational) Integer {
+	return signI(x.num)
+}
+
+func floatRtoF(x Rational) (Float, error) {
+	var dec apd.Decimal
+	c, err := decimal128Ctx.Quo(&dec, apd.New(int64(x.num), 0), apd.New(int64(x.den), 0))
+	if err != nil {
+		return Float{}, decimalConditionAsErr(c)
+	}
+	return Float{dec: &dec}, nil
+}
+
+func floorRtoI(x Rational) Integer {
+	q := x.num / x.den // truncated toward zero
+	if x.num < 0 {
+		q--
+	}
+	return q
+}
+
+func truncateRtoI(x Rational) Integer {
+	return x.num / x.den
+}
+
+func ceilingRtoI(x Rational) Integer {
+	q := x.num / x.den
+	if x.num > 0 {
+		q++
+	}
+	return q
+}
+
+func roundRtoI(x Rational) Integer {
+	// Round half away from zero: sign(x) * floor((2|num| + den) / 2den).
+	num := new(big.Int).Abs(big.NewInt(int64(x.num)))
+	num.Lsh(num, 1).Add(num, big.NewInt(int64(x.den)))
+	den := new(big.Int).Lsh(big.NewInt(int64(x.den)), 1)
+	q := Integer(num.Quo(num, den).Int64()) // |x| < maxInt since den > 1.
+	if x.num < 0 {
+		return -q
+	}
+	return q
+}
+
+// powR returns x raised to the power of an integer n exactly.
+func powR(x Rational, n Integer) (Number, error) {
+	if n < -64 || n > 64 { // The denominator wouldn't fit in Integer.
+		return nil, exceptionalValueIntOverflow
+	}
+	num, den := big.NewInt(int64(x.num)), big.NewInt(int64(x.den))
+	if n < 0 {
+		num, den = den, num
+		n = -n
+	}
+	e := big.NewInt(int64(n))
+	num.Exp(num, e, nil)
+	den.Exp(den, e, nil)
+	return ratToNumber(new(big.Rat).SetFrac(num, den))
+}
+
+// divPreferringRationals is the division / when the prolog flag prefer_rationals is true.
+func divPreferringRationals(x, y Number) (Number, error) {
+	if isRational(x) && isRational(y) {
+		return divR(x, y)
+	}
+	return div(x, y)
+}
+
+// Mixed mode operations
+
+func addFR(x Float, r Rational) (Float, error) {
+	y, err := floatRtoF(r)
+	if err != nil {
+		return Float{}, err
+	}
+	return addF(x, y)
+}
+
+func addRF(r Rational, y Float) (Float, error) {
+	return addFR(y, r)
+}
+
+func subFR(x Float, r Rational) (Float, error) {
+	y, err := floatRtoF(r)
+	if err != nil {
+		return Float{}, err
+	}
+	return subF(x, y)
+}
+
+func subRF(r Rational, y Float) (Float, error) {
+	x, err := floatRtoF(r)
+	if err != nil {
+		return Float{}, err
+	}
+	return subF(x, y)
+}
+
+func mulFR(x Float, r Rational) (Float, error) {
+	y, err := floatRtoF(r)
+	if err != nil {
+		return Float{}, err
+	}
+	return mulF(x, y)
+}
+
+func mulRF(r Rational, y Float) (Float, error) {
+	return mulFR(y, r)
+}
+
+func divFR(x Float, r Rational) (Float, error) {
+	y, err := floatRtoF(r)
+	if err != nil {
+		return Float{}, err
+	}
+	return divF(x, y)
+}
+
+func divRF(r Rational, y Float) (Float, error) {
+	x, err := floatRtoF(r)
+	if err != nil {
+		return Float{}, err
+	}
+	return divF(x, y)
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustRational(num, den Integer) Rational {
+	n, err := NewRational(num, den)
+	if err != nil {
+		panic(err)
+	}
+	return n.(Rational)
+}
+
+func TestRationalNumber(t *testing.T) {
+	assert.Implements(t, (*Number)(nil), Rational{})
+}
+
+func TestNewRational(t *testing.T) {
+	tests := []struct {
+		title    string
+		num, den Integer
+		n        Number
+		err      error
+	}{
+		{title: "canonical", num: 1, den: 3, n: Rational{num: 1, den: 3}},
+		{title: "reduced", num: 2, den: 6, n: Rational{num: 1, den: 3}},
+		{title: "negative denominator", num: 1, den: -3, n: Rational{num: -1, den: 3}},
+		{title: "integer", num: 6, den: 3, n: Integer(2)},
+		{title: "zero divisor", num: 1, den: 0, err: exceptionalValueZeroDivisor},
+		{title: "overflow", num: math.MinInt64, den: -3, err: exceptionalValueIntOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			n, err := NewRational(tt.num, tt.den)
+			assert.Equal(t, tt.n, n)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestRational_WriteTerm(t *testing.T) {
+	tests := []struct {
+		title  string
+		r      Rational
+		opts   WriteOptions
+		output string
+	}{
+		{title: "positive", r: mustRational(1, 3), output: `1r3`},
+		{title: "negative", r: mustRational(-1, 3), output: `-1r3`},
+		{title: "following unary minus", r: mustRational(1, 3), opts: WriteOptions{left: operator{name: atomMinus, specifier: operatorSpecifierFX}}, output: ` (1)r3`},
+		{title: "followed by a letter", r: mustRational(1, 3), opts: WriteOptions{right: operator{name: NewAtom(`e`)}}, output: `1r3 `},
+	}
+
+	var buf bytes.Buffer
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			buf.Reset()
+			assert.NoError(t, tt.r.WriteTerm(&buf, &tt.opts, nil))
+			assert.Equal(t, tt.output, buf.String())
+		})
+	}
+}
+
+func TestRational_Compare(t *testing.T) {
+	x := NewVariable()
+
+	tests := []struct {
+		title string
+		r     Rational
+		t     Term
+		o     int
+	}{
+		{title: `1r3 > X`, r: mustRational(1, 3), t: x, o: 1},
+		{title: `1r3 < 1.0`, r: mustRational(1, 3), t: NewFloatFromInt64(1), o: -1},
+		{title: `1r3 > 0.25`, r: mustRational(1, 3), t: newFloatFromFloat64Must(0.25), o: 1},
+		{title: `1r2 > 0.5`, r: mustRational(1, 2), t: newFloatFromFloat64Must(0.5), o: 1},
+		{title: `1r3 < 1`, r: mustRational(1, 3), t: Integer(1), o: -1},
+		{title: `1r3 > 0`, r: mustRational(1, 3), t: Integer(0), o: 1},
+		{title: `1r3 > 1r4`, r: mustRational(1, 3), t: mustRational(1, 4), o: 1},
+		{title: `1r3 = 1r3`, r: mustRational(1, 3), t: mustRational(1, 3), o: 0},
+		{title: `1r3 < 1r2`, r: mustRational(1, 3), t: mustRational(1, 2), o: -1},
+		{title: `1r3 < a`, r: mustRational(1, 3), t: NewAtom("a"), o: -1},
+		{title: `1r3 < f(a)`, r: mustRational(1, 3), t: NewAtom("f").Apply(NewAtom("a")), o: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.Equal(t, tt.o, tt.r.Compare(tt.t, nil))
+		})
+	}
+}
+
+func TestIs_preferRationals(t *testing.T) {
+	tests := []struct {
+		title           string
+		preferRationals bool
+		expression      Term
+		result          Term
+	}{
+		{title: "1 / 3", expression: atomSlash.Apply(Integer(1), Integer(3)), result: newFloatFromStringMust("0.3333333333333333333333333333333333")},
+		{title: "1 / 3 preferring rationals", preferRationals: true, expression: atomSlash.Apply(Integer(1), Integer(3)), result: mustRational(1, 3)},
+		{title: "4 / 2 preferring rationals", preferRationals: true, expression: atomSlash.Apply(Integer(4), Integer(2)), result: Integer(2)},
+		{title: "1.0 / 4 preferring rationals", preferRationals: true, expression: atomSlash.Apply(NewFloatFromInt64(1), Integer(4)), result: newFloatFromStringMust("0.25")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			vm := VM{preferRationals: tt.preferRationals}
+			ok, err := Is(&vm, tt.result, tt.expression, Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+)
+
+var recordRefIDCounter uint64
+
+// recordKey is the key of records in the recorded database: an atom, an integer, or the name and arity of a compound.
+type recordKey struct {
+	pi      procedureIndicator // the name and arity of an atom or compound key.
+	integer Integer
+	isInt   bool
+}
+
+func toRecordKey(key Term, env *Env) (recordKey, error) {
+	switch key := env.Resolve(key).(type) {
+	case Variable:
+		return recordKey{}, InstantiationError(env)
+	case Atom:
+		return recordKey{pi: procedureIndicator{name: key}}, nil
+	case Integer:
+		return recordKey{integer: key, isInt: true}, nil
+	case Compound:
+		return recordKey{pi: procedureIndicator{name: key.Functor(), arity: Integer(key.Arity())}}, nil
+	default:
+		return recordKey{}, typeError(validTypeKey, key, env)
+	}
+}
+
+// term returns the key as a term. A compound key is a most general term.
+func (k recordKey) term() Term {
+	if k.isInt {
+		return k.integer
+	}
+	args := make([]Term, k.pi.arity)
+	for i := range args {
+		args[i] = NewVariable()
+	}
+	return k.pi.name.Apply(args...)
+}
+
+// recordList is the records of a key in order. Adding, erasing, and looking up a record take constant time.
+type recordList = orderedmap.OrderedMap[*recordRef, struct{}]
+
+// newRecordList returns an empty list of records.
+func newRecordList() *recordList {
+	return orderedmap.New[*recordRef, struct{}]()
+}
+
+// refs returns the records of l in order.
+func refs(l *recordList) []*recordRef {
+	ret := make([]*recordRef, 0, l.Len())
+	for p := l.Oldest(); p != nil; p = p.Next() {
+		ret = append(ret, p.Key)
+	}
+	return ret
+}
+
+// cloneRecordList returns a copy of l which can be changed independently.
+func cloneRecordList(l *recordList) *recordList {
+	ret := newRecordList()
+	for p := l.Oldest(); p != nil; p = p.Next() {
+		ret.Set(p.Key, struct{}{})
+	}
+	return ret
+}
+
+// recordRef is a reference to a record in the recorded database. It's valid only in the VM it's obtained from.
+type recordRef struct {
+	id   uint64
+	key  recordKey
+	term Term
+}
+
+// WriteTerm outputs the recordRef to an io.Writer.
+func (r *recordRef) WriteTerm(w io.Writer, _ *WriteOptions, _ *Env) error {
+	_, err := fmt.Fprintf(w, "<record>(0x%x)", r.id)
+	return err
+}
+
+// Compare compares the recordRef with a Term.
+func (r *recordRef) Compare(t Term, env *Env) int {
+	return CompareAtomic[*recordRef](r, t, func(r *recordRef, s *recordRef) int {
+		switch {
+		case r.id > s.id:
+			return 1
+		case r.id < s.id:
+			return -1
+		default:
+			return 0
+		}
+	}, env)
+}
+
+// RecordA adds a copy of value as the first record of key in the recorded database and unifies ref with the reference
+// to it. Unlike assertions, records are plain terms that aren't compiled, which makes them cheap scratch data.
+func RecordA(vm *VM, key, value, ref Term, k Cont, env *Env) *Promise {
+	return record(vm, atomRecorda, key, value, ref, func(l *recordList, r *recordRef) {
+		l.Set(r, struct{}{})
+		_ = l.MoveToFront(r)
+	}, k, env)
+}
+
+// RecordZ adds a copy of value as the last record of key in the recorded database and unifies ref with the reference
+// to it.
+func RecordZ(vm *VM, key, value, ref Term, k Cont, env *Env) *Promise {
+	return record(vm, atomRecordz, key, value, ref, func(l *recordList, r *recordRef) {
+		l.Set(r, struct{}{})
+	}, k, env)
+}
+
+func record(vm *VM, name Atom, key, value, ref Term, add func(*recordList, *recordRef), k Cont, env *Env) *Promise {
+	rk, err := toRecordKey(key, env)
+	if err != nil {
+		return Error(err)
+	}
+	if _, ok := env.Resolve(ref).(Variable); !ok {
+		return Error(UninstantiationError(ref, env))
+	}
+	c, err := renamedCopy(value, nil, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	if vm.records == nil {
+		vm.records = orderedmap.New[recordKey, *recordList]()
+	}
+	r := recordRef{id: atomic.AddUint64(&recordRefIDCounter, 1), key: rk, term: c}
+	l, ok := vm.records.Get(rk)
+	if !ok {
+		l = newRecordList()
+		vm.records.Set(rk, l)
+	}
+	add(l, &r)
+	vm.record(name, []Term{key, c}, env)
+	return Unify(vm, ref, &r, k, env)
+}
+
+// Recorded enumerates the records of key in the recorded database and their references in order. key is either an
+// atom, an integer, or a compound of which only the name and arity count. If key is a variable, it enumerates the
+// records of all the keys. If ref is a reference, it unifies key and value with the record it references.
+// The records added or erased during the enumeration don't affect it.
+func Recorded(vm *VM, key, value, ref Term, k Cont, env *Env) *Promise {
+	switch r := env.Resolve(ref).(type) {
+	case Variable:
+		break
+	case *recordRef:
+		if !vm.recorded(r) {
+			return Bool(false)
+		}
+		c, err := renamedCopy(r.term, nil, env)
+		if err != nil {
+			return Error(err)
+		}
+		return Unify(vm, tuple(key, value), tuple(r.key.term(), c), k, env)
+	default:
+		return Error(typeError(validTypeDBReference, ref, env))
+	}
+
+	var keys []recordKey
+	switch env.Resolve(key).(type) {
+	case Variable:
+		if vm.records != nil {
+			for p := vm.records.Oldest(); p != nil; p = p.Next() {
+				keys = append(keys, p.Key)
+			}
+		}
+	default:
+		rk, err := toRecordKey(key, env)
+		if err != nil {
+			return Error(err)
+		}
+		keys = append(keys, rk)
+	}
+
+	var ks []func(context.Context) *Promise
+	for _, rk := range keys {
+		var rs []*recordRef
+		if vm.records != nil {
+			if l, ok := vm.records.Get(rk); ok {
+				rs = refs(l)
+			}
+		}
+		for _, r := range rs {
+			ks = append(ks, func(context.Context) *Promise {
+				c, err := renamedCopy(r.term, nil, env)
+				if err != nil {
+					return Error(err)
+				}
+				return Unify(vm, tuple(key, value, ref), tuple(rk.term(), c, r), k, env)
+			})
+		}
+	}
+	return Delay(ks...)
+}
+
+// recorded reports whether r is a record of the VM which is not erased.
+func (vm *VM) recorded(r *recordRef) bool {
+	if vm.records == nil {
+		return false
+	}
+	l, ok := vm.records.Get(r.key)
+	if !ok {
+		return false
+	}
+	_, ok = l.Get(r)
+	return ok
+}
+
+// eraseRecord removes the record r from the recorded database. It fails if r is already erased.
+func (vm *VM) eraseRecord(r *recordRef, k Cont, env *Env) *Promise {
+	if !vm.recorded(r) {
+		return Bool(false)
+	}
+	l, _ := vm.records.Get(r.key)
+	if vm.journaling {
+		n := 1
+		for p := l.Oldest(); p.Key != r; p = p.Next() {
+			n++
+		}
+		vm.record(atomErase, []Term{atomRecorded.Apply(r.key.term(), r.term), Integer(n)}, env)
+	}
+	l.Delete(r)
+	if l.Len() == 0 {
+		vm.records.Delete(r.key)
+	}
+	return k(env)
+}
+
+// eraseRecordNth removes the n-th record, from 1, of key if it's a variant of value. It's how the journal redoes erase/1
+// of a record since a reference doesn't survive a replay.
+func eraseRecordNth(vm *VM, key, value, n Term, k Cont, env *Env) *Promise {
+	rk, err := toRecordKey(key, env)
+	if err != nil {
+		return Error(err)
+	}
+	i, ok := env.Resolve(n).(Integer)
+	if !ok {
+		return Error(typeError(validTypeInteger, n, env))
+	}
+	if vm.records == nil {
+		return Bool(false)
+	}
+	l, ok := vm.records.Get(rk)
+	if !ok {
+		return Bool(false)
+	}
+	rs := refs(l)
+	if i < 1 || int(i) > len(rs) {
+		return Bool(false)
+	}
+	r := rs[i-1]
+	if !variant(r.term, value, env) {
+		return Bool(false)
+	}
+	return vm.eraseRecord(r, k, env)
+}
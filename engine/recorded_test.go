@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorded(t *testing.T) {
+	foo, bar := NewAtom("foo"), NewAtom("bar")
+	newVM := func() (*VM, []Term) {
+		var (
+			vm   VM
+			refs []Term
+		)
+		for _, r := range []struct {
+			record     func(*VM, Term, Term, Term, Cont, *Env) *Promise
+			key, value Term
+		}{
+			{record: RecordZ, key: foo, value: Integer(1)},
+			{record: RecordZ, key: foo, value: Integer(2)},
+			{record: RecordA, key: foo, value: Integer(0)},
+			{record: RecordZ, key: bar.Apply(NewVariable()), value: bar.Apply(NewVariable())},
+			{record: RecordZ, key: Integer(42), value: NewAtom("answer")},
+		} {
+			ref := NewVariable()
+			ok, err := r.record(&vm, r.key, r.value, ref, func(env *Env) *Promise {
+				refs = append(refs, env.Resolve(ref))
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+		return &vm, refs
+	}
+	values := func(vm *VM, key Term) []Term {
+		var ret []Term
+		v := NewVariable()
+		_, err := Recorded(vm, key, v, NewVariable(), func(env *Env) *Promise {
+			ret = append(ret, env.Resolve(v))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		return ret
+	}
+
+	t.Run("recorded", func(t *testing.T) {
+		vm, _ := newVM()
+		assert.Equal(t, []Term{Integer(0), Integer(1), Integer(2)}, values(vm, foo))
+		assert.Len(t, values(vm, bar.Apply(Integer(1))), 1)
+		assert.Equal(t, []Term{NewAtom("answer")}, values(vm, Integer(42)))
+		assert.Empty(t, values(vm, NewAtom("baz")))
+		assert.Len(t, values(vm, NewVariable()), 5)
+	})
+
+	t.Run("recorded with a reference", func(t *testing.T) {
+		vm, refs := newVM()
+		k, v := NewVariable(), NewVariable()
+		ok, err := Recorded(vm, k, v, refs[3], func(env *Env) *Promise {
+			assert.Equal(t, bar, env.Resolve(k).(Compound).Functor())
+			assert.Equal(t, bar, env.Resolve(v).(Compound).Functor())
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("erase", func(t *testing.T) {
+		vm, refs := newVM()
+		ok, err := Erase(vm, refs[0], Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []Term{Integer(0), Integer(2)}, values(vm, foo))
+
+		ok, err = Erase(vm, refs[0], Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		ok, err = Recorded(vm, NewVariable(), NewVariable(), refs[0], Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("erase during enumeration", func(t *testing.T) {
+		vm, refs := newVM()
+		var vs []Term
+		v := NewVariable()
+		_, err := Recorded(vm, foo, v, NewVariable(), func(env *Env) *Promise {
+			vs = append(vs, env.Resolve(v))
+			return Erase(vm, refs[1], func(*Env) *Promise {
+				return Bool(false)
+			}, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []Term{Integer(0), Integer(1), Integer(2)}, vs)
+		assert.Equal(t, []Term{Integer(0), Integer(1)}, values(vm, foo))
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		var vm VM
+		x := NewVariable()
+		env := NewEnv().bind(x, foo)
+		_, err := RecordZ(&vm, bar, tuple(x, NewVariable()), NewVariable(), Success, env).Force(context.Background())
+		assert.NoError(t, err)
+		vs := values(&vm, bar)
+		assert.Len(t, vs, 1)
+		assert.Equal(t, foo, vs[0].(Compound).Arg(0))
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		vm, refs := newVM()
+		c := vm.Clone()
+		ok, err := Erase(c, refs[1], Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		_, err = RecordZ(c, foo, Integer(3), NewVariable(), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []Term{Integer(0), Integer(1), Integer(2)}, values(vm, foo))
+		assert.Equal(t, []Term{Integer(0), Integer(1), Integer(3)}, values(c, foo))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		vm, refs := newVM()
+		_, err := RecordZ(vm, NewVariable(), foo, NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		_, err = RecordZ(vm, NewFloatFromInt64(1), foo, NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeKey, NewFloatFromInt64(1), nil), err)
+		_, err = RecordZ(vm, foo, foo, refs[0], Success, nil).Force(context.Background())
+		assert.Equal(t, UninstantiationError(refs[0], nil), err)
+		_, err = Recorded(vm, foo, NewVariable(), foo, Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeDBReference, foo, nil), err)
+	})
+}
+
+func TestRecordRef_WriteTerm(t *testing.T) {
+	var sb bytes.Buffer
+	assert.NoError(t, (&recordRef{id: 42}).WriteTerm(&sb, nil, nil))
+	assert.Equal(t, "<record>(0x2a)", sb.String())
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errOtherPredicate = errors.New("clause of another predicate")
+
+// ReplacePredicate replaces the clauses of the user-defined procedure identified by the predicate indicator pi with
+// cs at once, or defines the procedure if it doesn't exist yet. The properties of the procedure, e.g. dynamic, are
+// kept. The calls in progress keep trying the clauses they could see when they started while the later calls see cs
+// only, so that long-running services can patch rules without reconsulting whole texts. The answer tables of the
+// procedure are removed.
+// cs is refused as a whole if any of its clauses is malformed or belongs to another procedure.
+func (vm *VM) ReplacePredicate(pi Term, cs []Term) error {
+	key, err := toProcedureIndicator(pi, nil)
+	if err != nil {
+		return err
+	}
+	if err := vm.checkFrozen(permissionTypeStaticProcedure, key.Term(), nil); err != nil {
+		return err
+	}
+
+	var replaced clauses
+	for _, t := range cs {
+		added, err := compile(t, nil)
+		if err != nil {
+			return err
+		}
+		for _, c := range added {
+			if c.pi != key {
+				return fmt.Errorf("%w: %s", errOtherPredicate, c.pi)
+			}
+		}
+		replaced = append(replaced, added...)
+	}
+	if err := vm.verify(replaced); err != nil {
+		return err
+	}
+
+	u := userDefined{}
+	if p, ok := vm.getProcedure(key); ok {
+		old, ok := p.(*userDefined)
+		if !ok {
+			return permissionError(operationModify, permissionTypeStaticProcedure, key.Term(), nil)
+		}
+
+		// The calls in progress hold the clauses of old. So, old is left as is and replaced with a copy.
+		u.public, u.dynamic, u.multifile, u.discontiguous, u.tabled, u.builtIn = old.public, old.dynamic, old.multifile, old.discontiguous, old.tabled, old.builtIn
+		u.modes = old.modes
+		u.argIndexes = make([]*argIndex, len(old.argIndexes))
+		for i, a := range old.argIndexes {
+			u.argIndexes[i] = &argIndex{args: a.args}
+		}
+		vm.clauseGC.Retired -= uint64(old.retired)
+		vm.clauseGC.Collected += uint64(old.retired)
+		for k := range vm.tables {
+			if k.u == old {
+				delete(vm.tables, k)
+			}
+		}
+	}
+	if err := vm.store(key, &u, clauseChange{added: replaced, reset: true}); err != nil {
+		return err
+	}
+	u.setClauses(replaced)
+	vm.setProcedure(key, &u)
+	vm.record(atomReplacePredicate, []Term{key.Term(), List(cs...)}, nil)
+	return nil
+}
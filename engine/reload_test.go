@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_ReplacePredicate(t *testing.T) {
+	newVM := func() *VM {
+		var vm VM
+		vm.Register1(NewAtom("foreign"), func(_ *VM, _ Term, k Cont, env *Env) *Promise {
+			return k(env)
+		})
+		assert.NoError(t, vm.Compile(context.Background(), `
+:-(dynamic(/(p, 1))).
+p(1).
+p(2).
+`))
+		return &vm
+	}
+
+	solutions := func(vm *VM, during func()) []Term {
+		var ret []Term
+		x := NewVariable()
+		_, err := Call(vm, NewAtom("p").Apply(x), func(env *Env) *Promise {
+			ret = append(ret, env.Resolve(x))
+			if during != nil {
+				during()
+				during = nil
+			}
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		return ret
+	}
+
+	t.Run("logical update view", func(t *testing.T) {
+		vm := newVM()
+		assert.Equal(t, []Term{Integer(1), Integer(2)}, solutions(vm, func() {
+			assert.NoError(t, vm.ReplacePredicate(PI("p", 1), []Term{
+				NewAtom("p").Apply(Integer(3)),
+				atomIf.Apply(NewAtom("p").Apply(Integer(4)), NewAtom("foreign").Apply(Integer(4))),
+			}))
+		}))
+		assert.Equal(t, []Term{Integer(3), Integer(4)}, solutions(vm, nil))
+	})
+
+	t.Run("properties are kept", func(t *testing.T) {
+		vm := newVM()
+		assert.NoError(t, vm.ReplacePredicate(PI("p", 1), nil))
+		assert.Empty(t, solutions(vm, nil))
+
+		ok, err := Assertz(vm, NewAtom("p").Apply(Integer(5)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []Term{Integer(5)}, solutions(vm, nil))
+	})
+
+	t.Run("built-in", func(t *testing.T) {
+		vm := newVM()
+		vm.MarkBuiltIn()
+		assert.NoError(t, vm.ReplacePredicate(PI("p", 1), []Term{NewAtom("p").Apply(Integer(3))}))
+		p, ok := vm.getProcedure(procedureIndicator{name: NewAtom("p"), arity: 1})
+		assert.True(t, ok)
+		assert.True(t, p.(*userDefined).builtIn)
+	})
+
+	t.Run("new procedure", func(t *testing.T) {
+		vm := newVM()
+		assert.NoError(t, vm.ReplacePredicate(PI("q", 0), []Term{NewAtom("q")}))
+		ok, err := Call(vm, NewAtom("q"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("clause of another procedure", func(t *testing.T) {
+		vm := newVM()
+		assert.ErrorIs(t, vm.ReplacePredicate(PI("p", 1), []Term{NewAtom("p").Apply(Integer(3)), NewAtom("q")}), errOtherPredicate)
+		assert.Equal(t, []Term{Integer(1), Integer(2)}, solutions(vm, nil))
+	})
+
+	t.Run("malformed clause", func(t *testing.T) {
+		vm := newVM()
+		assert.Equal(t, typeError(validTypeCallable, Integer(1), nil), vm.ReplacePredicate(PI("p", 1), []Term{atomIf.Apply(NewAtom("p").Apply(Integer(3)), Integer(1))}))
+	})
+
+	t.Run("foreign predicate", func(t *testing.T) {
+		vm := newVM()
+		assert.Equal(t, permissionError(operationModify, permissionTypeStaticProcedure, PI("foreign", 1), nil), vm.ReplacePredicate(PI("foreign", 1), nil))
+	})
+
+	t.Run("frozen", func(t *testing.T) {
+		vm := newVM()
+		vm.Freeze()
+		assert.Error(t, vm.ReplacePredicate(PI("p", 1), nil))
+	})
+}
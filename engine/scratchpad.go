@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"context"
+)
+
+// scratchpadKey is the context key of the scratchpad of a query.
+type scratchpadKey struct{}
+
+// scratchpad holds the query scoped global variables.
+// Since it lives in the context of the outermost Force, it's neither shared with concurrent queries nor retained
+// after the query completes.
+type scratchpad struct {
+	vals    map[Atom]Term
+	metrics *metrics // the counters of the VM running the query if it has a metrics sink.
+}
+
+func withScratchpad(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(scratchpadKey{}).(*scratchpad); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, scratchpadKey{}, &scratchpad{})
+}
+
+func scratchpadOf(ctx context.Context) *scratchpad {
+	s, _ := ctx.Value(scratchpadKey{}).(*scratchpad)
+	if s == nil {
+		s = &scratchpad{}
+	}
+	return s
+}
+
+// QSetval associates a copy of value with the atom key in the scratchpad of the running query.
+// Unlike assertions, the association isn't undone on backtracking and is discarded once the query completes.
+func QSetval(_ *VM, key, value Term, k Cont, env *Env) *Promise {
+	a, err := scratchpadKeyOf(key, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	c, err := renamedCopy(value, nil, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	return Delay(func(ctx context.Context) *Promise {
+		s := scratchpadOf(ctx)
+		if s.vals == nil {
+			s.vals = map[Atom]Term{}
+		}
+		s.vals[a] = c
+		return k(env)
+	})
+}
+
+// QGetval unifies value with the term associated with the atom key in the scratchpad of the running query.
+func QGetval(vm *VM, key, value Term, k Cont, env *Env) *Promise {
+	a, err := scratchpadKeyOf(key, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	return Delay(func(ctx context.Context) *Promise {
+		t, ok := scratchpadOf(ctx).vals[a]
+		if !ok {
+			return Error(existenceError(objectTypeVariable, a, env))
+		}
+		return Unify(vm, value, t, k, env)
+	})
+}
+
+func scratchpadKeyOf(key Term, env *Env) (Atom, error) {
+	switch k := env.Resolve(key).(type) {
+	case Variable:
+		return "", InstantiationError(env)
+	case Atom:
+		return k, nil
+	default:
+		return "", typeError(validTypeAtom, k, env)
+	}
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQSetval(t *testing.T) {
+	t.Run("set and get", func(t *testing.T) {
+		x, v := NewVariable(), NewVariable()
+		env := NewEnv().bind(x, NewAtom("a"))
+		ok, err := QSetval(nil, NewAtom("k"), NewAtom("f").Apply(x), func(env *Env) *Promise {
+			return QGetval(nil, NewAtom("k"), v, func(env *Env) *Promise {
+				assert.Equal(t, NewAtom("f").Apply(NewAtom("a")), env.Resolve(v))
+				return Bool(true)
+			}, env)
+		}, env).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("survives backtracking", func(t *testing.T) {
+		v := NewVariable()
+		ok, err := Delay(func(context.Context) *Promise {
+			return QSetval(nil, NewAtom("k"), Integer(1), Failure, nil)
+		}, func(context.Context) *Promise {
+			return QGetval(nil, NewAtom("k"), v, func(env *Env) *Promise {
+				assert.Equal(t, Integer(1), env.Resolve(v))
+				return Bool(true)
+			}, nil)
+		}).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("discarded after force", func(t *testing.T) {
+		ctx := context.Background()
+		ok, err := QSetval(nil, NewAtom("k"), Integer(1), Success, nil).Force(ctx)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = QGetval(nil, NewAtom("k"), NewVariable(), Success, nil).Force(ctx)
+		assert.Equal(t, existenceError(objectTypeVariable, NewAtom("k"), nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("key is a variable", func(t *testing.T) {
+		ok, err := QSetval(nil, NewVariable(), Integer(1), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("key is not an atom", func(t *testing.T) {
+		ok, err := QGetval(nil, Integer(1), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeAtom, Integer(1), nil), err)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SliceOptions configures Slice.
+type SliceOptions struct {
+	// Trace makes Slice run the query on a clone of the VM up to its first solution, failure, or error, and keep the
+	// clauses it entered instead of the clauses reachable in the static call graph. It catches the goals built at
+	// runtime, e.g. call(G), which the static call graph misses, and leaves out the clauses the query didn't need.
+	Trace bool
+}
+
+// Slice returns a standalone Prolog text defining the user-defined procedures of the module user which query may call,
+// so that it reproduces query with a minimal database, e.g. for a bug report or a light client.
+// Without SliceOptions.Trace, the procedures are found by following the goals in the clause bodies, including the
+// goal arguments of the meta predicates, from query. The goals qualified with a module are not followed.
+// The text starts with the op/3 directives defining the operators which differ from the built-in ones. The procedures
+// are written in the order they were defined, preceded by their dynamic and table declarations. The built-in
+// procedures, e.g. the ones of the bootstrap library, are left out. See MarkBuiltIn.
+func Slice(ctx context.Context, vm *VM, query Term, opts SliceOptions) (string, error) {
+	switch q := query.(type) {
+	case Variable:
+		return "", InstantiationError(nil)
+	case Atom, Compound:
+		break
+	default:
+		return "", typeError(validTypeCallable, q, nil)
+	}
+
+	var s slicer
+	if opts.Trace {
+		s.trace(ctx, vm, query)
+	} else {
+		s.static(vm, query)
+	}
+	return s.program(vm), nil
+}
+
+type slicer struct {
+	procedures map[procedureIndicator]struct{}
+	clauses    map[*instruction]struct{} // the clauses entered by the traced query, identified by their bytecode.
+}
+
+// trace runs query on a clone of vm so that the database of vm isn't modified by its side effects. The clone reads
+// nothing from and writes nothing to the streams of vm.
+// Since the clones share the bytecode of their clauses, the entered clauses are identified by it.
+func (s *slicer) trace(ctx context.Context, vm *VM, query Term) {
+	s.procedures = map[procedureIndicator]struct{}{}
+	s.clauses = map[*instruction]struct{}{}
+
+	c := vm.Clone()
+	c.slicing = s
+	c.streams = streams{}
+	c.SetUserInput(NewInputTextStream(strings.NewReader("")))
+	c.SetUserOutput(NewOutputTextStream(io.Discard))
+	_, _ = Call(c, query, Success, nil).Force(ctx)
+}
+
+func (s *slicer) called(pi procedureIndicator) {
+	s.procedures[pi] = struct{}{}
+}
+
+func (s *slicer) entered(c *clause) {
+	s.clauses[&c.bytecode[0]] = struct{}{}
+}
+
+func (s *slicer) static(vm *VM, query Term) {
+	s.procedures = map[procedureIndicator]struct{}{}
+
+	var queue []procedureIndicator
+	reach := func(pi procedureIndicator) {
+		if _, ok := s.procedures[pi]; ok {
+			return
+		}
+		p, ok := vm.getProcedure(pi)
+		if !ok {
+			return
+		}
+		if u, ok := p.(*userDefined); ok && u.builtIn {
+			return
+		}
+		s.procedures[pi] = struct{}{}
+		queue = append(queue, pi)
+	}
+
+	reachGoal(query, reach)
+	for len(queue) > 0 {
+		pi := queue[0]
+		queue = queue[1:]
+		p, _ := vm.getProcedure(pi)
+		u, ok := p.(*userDefined)
+		if !ok {
+			continue
+		}
+		for _, c := range u.clauses {
+			if c.erased != 0 {
+				continue
+			}
+			if r, ok := c.raw.(Compound); ok && r.Functor() == atomIf && r.Arity() == 2 {
+				reachGoal(r.Arg(1), reach)
+			}
+		}
+	}
+}
+
+// reachGoal calls reach with the predicate indicators of goal and its subgoals known before runtime.
+func reachGoal(goal Term, reach func(procedureIndicator)) {
+	switch g := goal.(type) {
+	case Atom:
+		reach(procedureIndicator{name: g, arity: 0})
+	case Compound:
+		pi := procedureIndicator{name: g.Functor(), arity: Integer(g.Arity())}
+		switch {
+		case pi.name == atomColon && pi.arity == 2:
+			return
+		case pi == procedureIndicator{name: atomComma, arity: 2},
+			pi == procedureIndicator{name: atomSemiColon, arity: 2},
+			pi == procedureIndicator{name: atomThen, arity: 2},
+			pi == procedureIndicator{name: NewAtom("*->"), arity: 2}:
+			reachGoal(g.Arg(0), reach)
+			reachGoal(g.Arg(1), reach)
+		case pi == procedureIndicator{name: atomCaret, arity: 2}:
+			reachGoal(g.Arg(1), reach)
+		case pi.name == atomCall && pi.arity > 1:
+			reachClosure(g.Arg(0), pi.arity-1, reach)
+		default:
+			for _, i := range metaArgs[pi] {
+				reachGoal(g.Arg(i), reach)
+			}
+		}
+		reach(pi)
+	}
+}
+
+// reachClosure calls reach with the predicate indicator of closure called with extra more arguments.
+func reachClosure(closure Term, extra Integer, reach func(procedureIndicator)) {
+	switch c := closure.(type) {
+	case Atom:
+		reach(procedureIndicator{name: c, arity: extra})
+	case Compound:
+		if c.Functor() == atomColon && c.Arity() == 2 {
+			return
+		}
+		reach(procedureIndicator{name: c.Functor(), arity: Integer(c.Arity()) + extra})
+	}
+}
+
+// program writes the procedures and the clauses of the slice.
+func (s *slicer) program(vm *VM) string {
+	var sb strings.Builder
+	s.operators(&sb, vm)
+	if vm.procedures == nil {
+		return sb.String()
+	}
+	for p := vm.procedures.Oldest(); p != nil; p = p.Next() {
+		if _, ok := s.procedures[p.Key]; !ok {
+			continue
+		}
+		u, ok := p.Value.(*userDefined)
+		if !ok || u.builtIn {
+			continue
+		}
+		if u.dynamic {
+			writeDirective(&sb, vm, atomDynamic.Apply(p.Key.Term()))
+		}
+		if u.tabled {
+			writeDirective(&sb, vm, atomTable.Apply(u.tableSpec(p.Key)))
+		}
+		for _, c := range u.clauses {
+			if c.erased != 0 {
+				continue
+			}
+			if s.clauses != nil {
+				if _, ok := s.clauses[&c.bytecode[0]]; !ok {
+					continue
+				}
+			}
+			writeClause(&sb, vm, c.raw)
+		}
+	}
+	return sb.String()
+}
+
+// operators writes the op/3 directives which turn the built-in operators into the ones of vm.
+func (s *slicer) operators(sb *strings.Builder, vm *VM) {
+	ops, builtIn := vm.getOperators(), vm.builtInOperators
+	if builtIn == nil {
+		builtIn = newOperators()
+	}
+	for _, o := range builtIn.canonical() {
+		if os, _ := ops.Get(o.name); os[o.specifier.class()] == (operator{}) {
+			writeDirective(sb, vm, atomOp.Apply(Integer(0), o.specifier.term(), o.name))
+		}
+	}
+	for _, o := range ops.canonical() {
+		if os, _ := builtIn.Get(o.name); os[o.specifier.class()] != o {
+			writeDirective(sb, vm, atomOp.Apply(o.priority, o.specifier.term(), o.name))
+		}
+	}
+}
+
+func writeDirective(sb *strings.Builder, vm *VM, t Term) {
+	writeClause(sb, vm, atomIf.Apply(t))
+}
+
+// writeClause writes t followed by a full stop with its variables named A, B, ..., Z, A1, B1, ...
+func writeClause(sb *strings.Builder, vm *VM, t Term) {
+	opts := WriteOptions{
+		quoted:        true,
+		variableNames: map[Variable]Atom{},
+		_ops:          vm.getOperators(),
+		priority:      1200,
+	}
+	for i, v := range (*Env)(nil).freeVariables(t) {
+		name := string(rune('A' + i%26))
+		if i >= 26 {
+			name += strconv.Itoa(i / 26)
+		}
+		opts.variableNames[v] = NewAtom(name)
+	}
+	_ = t.WriteTerm(sb, &opts, nil)
+	_, _ = sb.WriteString(".\n")
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlice(t *testing.T) {
+	var vm VM
+	vm.Register1(NewAtom("call"), func(vm *VM, g Term, k Cont, env *Env) *Promise {
+		return Call(vm, g, k, env)
+	})
+	assert.NoError(t, vm.Compile(context.Background(), `
+:-(dynamic(/(count, 1))).
+:-(foo(X), ','(bar(X), baz)).
+bar(a).
+bar(b).
+baz.
+:-(baz, count(_)).
+unused.
+:-(meta, call(baz)).
+:-(dyn(G), call(G)).
+`))
+
+	tests := []struct {
+		title   string
+		query   Term
+		opts    SliceOptions
+		program string
+		err     error
+	}{
+		{title: "static", query: NewAtom("foo").Apply(NewVariable()), program: `:-(dynamic(/(count,1))).
+:-(foo(A),','(bar(A),baz)).
+bar(a).
+bar(b).
+baz.
+:-(baz,count(A)).
+`},
+		{title: "meta predicate", query: NewAtom("meta"), program: `:-(dynamic(/(count,1))).
+baz.
+:-(baz,count(A)).
+:-(meta,call(baz)).
+`},
+		{title: "static misses runtime goals", query: NewAtom("dyn").Apply(NewAtom("baz")), program: `:-(dyn(A),call(A)).
+`},
+		{title: "trace", query: NewAtom("dyn").Apply(NewAtom("baz")), opts: SliceOptions{Trace: true}, program: `baz.
+:-(dyn(A),call(A)).
+`},
+		{title: "trace leaves out the clauses not tried", query: NewAtom("foo").Apply(NewAtom("a")), opts: SliceOptions{Trace: true}, program: `:-(foo(A),','(bar(A),baz)).
+bar(a).
+baz.
+`},
+		{title: "query is a variable", query: NewVariable(), err: InstantiationError(nil)},
+		{title: "query is not callable", query: Integer(1), err: typeError(validTypeCallable, Integer(1), nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			program, err := Slice(context.Background(), &vm, tt.query, tt.opts)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.program, program)
+		})
+	}
+
+	t.Run("built-in procedures and operators", func(t *testing.T) {
+		vm := VM{_operators: newOperators()}
+		vm.Register3(atomOp, Op)
+		vm.getOperators().define(700, operatorSpecifierXFX, NewAtom("=="))
+		vm.getOperators().define(200, operatorSpecifierXFY, NewAtom("^"))
+		assert.NoError(t, vm.Compile(context.Background(), `lib.`))
+		vm.MarkBuiltIn()
+		assert.NoError(t, vm.Compile(context.Background(), `
+:-(op(700, xfx, ===>)).
+:-(op(0, xfy, ^)).
+:-(foo, ','(lib, ===>(a, b))).
+===>(a, b).
+`))
+
+		for _, opts := range []SliceOptions{{}, {Trace: true}} {
+			program, err := Slice(context.Background(), &vm, NewAtom("foo"), opts)
+			assert.NoError(t, err)
+			assert.Equal(t, `:-(op(0,xfy,^)).
+:-(op(700,xfx,===>)).
+:-(foo,','(lib,a===>b)).
+a===>b.
+`, program)
+		}
+	})
+
+	t.Run("trace on null streams", func(t *testing.T) {
+		var (
+			vm  VM
+			out bytes.Buffer
+		)
+		vm.SetUserOutput(NewOutputTextStream(&out))
+		vm.Register1(NewAtom("write"), func(vm *VM, t Term, k Cont, env *Env) *Promise {
+			return WriteTerm(vm, vm.output, t, List(), k, env)
+		})
+		assert.NoError(t, vm.Compile(context.Background(), `:-(foo, write(hello)).`))
+
+		program, err := Slice(context.Background(), &vm, NewAtom("foo"), SliceOptions{Trace: true})
+		assert.NoError(t, err)
+		assert.Equal(t, ":-(foo,write(hello)).\n", program)
+		assert.Empty(t, out.String())
+	})
+}
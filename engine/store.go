@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ClauseStore is a persistent storage of the clauses of the dynamic procedures, e.g. a KV store of a chain.
+// The procedures are keyed by their predicate indicators such as foo/2 or '=..'/2, which ParsePI parses back.
+// Each clause is an opaque record under a key which orders it among the clauses of its procedure, so that asserting
+// or retracting a clause writes its record only.
+type ClauseStore interface {
+	// Get returns the clauses of the procedure pi in the order of their keys, or nil if it's not stored.
+	Get(pi string) ([]StoredClause, error)
+	// Put stores record as the clause of the procedure pi under key.
+	Put(pi string, key int64, record []byte) error
+	// Delete removes the clause of the procedure pi under key.
+	Delete(pi string, key int64) error
+	// Scan calls f with the stored procedures until f returns false.
+	Scan(f func(pi string) bool) error
+}
+
+// StoredClause is the record of a clause and its key in a ClauseStore.
+type StoredClause struct {
+	Key    int64
+	Record []byte
+}
+
+var errClauseRecord = errors.New("malformed clause record")
+
+// SetClauseStore backs the dynamic procedures with s. It defines the procedures stored in s as dynamic procedures,
+// replacing the clauses of the existing dynamic ones. From then on, the asserting and retracting builtins, erase/1,
+// abolish/1, ReplacePredicate, and the loading and unloading of Prolog texts write the changes of the clauses of the
+// dynamic procedures through to s before they take effect. A nil s stops the persistence.
+func (vm *VM) SetClauseStore(s ClauseStore) error {
+	vm.clauseStore = s
+	if s == nil {
+		return nil
+	}
+	vm.clauseStoreGen++
+
+	var pis []string
+	if err := s.Scan(func(pi string) bool {
+		pis = append(pis, pi)
+		return true
+	}); err != nil {
+		return err
+	}
+	for _, pi := range pis {
+		if err := vm.loadStored(s, pi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (vm *VM) loadStored(s ClauseStore, key string) error {
+	t, err := ParsePI(key)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errClauseRecord, key)
+	}
+	pi, err := toProcedureIndicator(t, nil)
+	if err != nil {
+		return err
+	}
+
+	stored, err := s.Get(key)
+	if err != nil {
+		return err
+	}
+	cs := make(clauses, 0, len(stored))
+	for _, s := range stored {
+		c, err := decodeClause(pi, s.Record)
+		if err != nil {
+			return err
+		}
+		c.storeKey = s.Key
+		cs = append(cs, c)
+	}
+	if err := vm.verify(cs); err != nil {
+		return err
+	}
+
+	p, ok := vm.getProcedure(pi)
+	if !ok {
+		u := userDefined{public: true, dynamic: true, storeGen: vm.clauseStoreGen}
+		u.setClauses(cs)
+		vm.setProcedure(pi, &u)
+		return nil
+	}
+	u, ok := p.(*userDefined)
+	if !ok || !u.dynamic {
+		return permissionError(operationModify, permissionTypeStaticProcedure, pi.Term(), nil)
+	}
+	vm.collect(u)
+	u.setClauses(cs)
+	u.storeGen = vm.clauseStoreGen
+	return nil
+}
+
+// encodeClause returns the record of c, that is the CBOR encoding of Raw-Alt where Alt is the index of c among the
+// clauses compiled from Raw.
+func encodeClause(c *clause) ([]byte, error) {
+	return EncodeTerm(atomMinus.Apply(c.raw, Integer(c.alt)), nil)
+}
+
+func decodeClause(pi procedureIndicator, record []byte) (*clause, error) {
+	t, err := DecodeTerm(record)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", errClauseRecord, pi, err)
+	}
+	r, ok := t.(Compound)
+	if !ok || r.Functor() != atomMinus || r.Arity() != 2 {
+		return nil, fmt.Errorf("%w: %s", errClauseRecord, pi)
+	}
+	alt, ok := r.Arg(1).(Integer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errClauseRecord, pi)
+	}
+	cs, err := compile(r.Arg(0), nil)
+	if err != nil {
+		return nil, err
+	}
+	if alt < 0 || int(alt) >= len(cs) || cs[alt].pi != pi {
+		return nil, fmt.Errorf("%w: %s", errClauseRecord, pi)
+	}
+	return cs[alt], nil
+}
+
+// clauseChange is a change of the clauses of a procedure.
+type clauseChange struct {
+	removed clauses // the clauses removed.
+	added   clauses // the clauses added after the others, or before them if front.
+	front   bool
+	reset   bool // whether added replace all the clauses.
+}
+
+// store writes the change ch of the clauses of the procedure pi of user through to the clause store, if any, before
+// it takes effect. u is the procedure which has the clauses after the change while vm still has the one before it.
+// Only the clauses of the dynamic procedures are stored. The first change of a procedure whose clauses are not stored
+// yet, e.g. the ones loaded from a Prolog text before the clause store is set, stores all of them.
+// Every change of the clauses of the procedures of user goes through store.
+func (vm *VM) store(pi procedureIndicator, u *userDefined, ch clauseChange) error {
+	if vm.clauseStore == nil {
+		return nil
+	}
+
+	if !ch.reset && u.storeGen != vm.clauseStoreGen {
+		live := make(clauses, 0, len(u.clauses)+len(ch.added))
+		for _, c := range u.clauses {
+			if c.erased == 0 && !slices.Contains(ch.removed, c) {
+				live = append(live, c)
+			}
+		}
+		if ch.front {
+			live = append(slices.Clone(ch.added), live...)
+		} else {
+			live = append(live, ch.added...)
+		}
+		ch = clauseChange{added: live, reset: true}
+	}
+
+	key := pi.String()
+	var next int64 // the key of the first clause added.
+	switch {
+	case ch.reset:
+		if p, ok := vm.getProcedure(pi); ok {
+			if old, ok := p.(*userDefined); ok && old.storeGen == vm.clauseStoreGen {
+				stored, err := vm.clauseStore.Get(key)
+				if err != nil {
+					return err
+				}
+				for _, s := range stored {
+					if err := vm.clauseStore.Delete(key, s.Key); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if !u.dynamic {
+			return nil
+		}
+	case ch.front && len(u.clauses) > 0:
+		next = u.clauses[0].storeKey - int64(len(ch.added))
+	case len(u.clauses) > 0:
+		next = u.clauses[len(u.clauses)-1].storeKey + 1
+	}
+
+	for _, c := range ch.removed {
+		if err := vm.clauseStore.Delete(key, c.storeKey); err != nil {
+			return err
+		}
+	}
+	for _, c := range ch.added {
+		r, err := encodeClause(c)
+		if err != nil {
+			return err
+		}
+		if err := vm.clauseStore.Put(key, next, r); err != nil {
+			return err
+		}
+		c.storeKey = next
+		next++
+	}
+	u.storeGen = vm.clauseStoreGen
+	return nil
+}
@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mapClauseStore struct {
+	records map[string]map[int64][]byte
+	err     error
+}
+
+func (m *mapClauseStore) Get(pi string) ([]StoredClause, error) {
+	var cs []StoredClause
+	for k, r := range m.records[pi] {
+		cs = append(cs, StoredClause{Key: k, Record: r})
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].Key < cs[j].Key
+	})
+	return cs, m.err
+}
+
+func (m *mapClauseStore) Put(pi string, key int64, record []byte) error {
+	if m.err != nil {
+		return m.err
+	}
+	if m.records == nil {
+		m.records = map[string]map[int64][]byte{}
+	}
+	if m.records[pi] == nil {
+		m.records[pi] = map[int64][]byte{}
+	}
+	m.records[pi][key] = record
+	return nil
+}
+
+func (m *mapClauseStore) Delete(pi string, key int64) error {
+	if m.err != nil {
+		return m.err
+	}
+	delete(m.records[pi], key)
+	if len(m.records[pi]) == 0 {
+		delete(m.records, pi)
+	}
+	return nil
+}
+
+func (m *mapClauseStore) Scan(f func(pi string) bool) error {
+	pis := make([]string, 0, len(m.records))
+	for pi := range m.records {
+		pis = append(pis, pi)
+	}
+	sort.Strings(pis)
+	for _, pi := range pis {
+		if !f(pi) {
+			break
+		}
+	}
+	return m.err
+}
+
+func TestVM_SetClauseStore(t *testing.T) {
+	foo, bar := NewAtom("foo"), NewAtom("bar")
+	solutions := func(vm *VM, goal Term, x Variable) []Term {
+		var ret []Term
+		_, err := Call(vm, goal, func(env *Env) *Promise {
+			ret = append(ret, env.Resolve(x))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		return ret
+	}
+
+	newVM := func() *VM {
+		var vm VM
+		vm.Register0(atomTrue, func(_ *VM, k Cont, env *Env) *Promise {
+			return k(env)
+		})
+		vm.Register0(atomFail, func(*VM, Cont, *Env) *Promise {
+			return Bool(false)
+		})
+		return &vm
+	}
+
+	t.Run("persist and restore", func(t *testing.T) {
+		var s mapClauseStore
+
+		vm := newVM()
+		assert.NoError(t, vm.SetClauseStore(&s))
+		for _, c := range []Term{
+			foo.Apply(Integer(1)),
+			foo.Apply(Integer(2)),
+			atomIf.Apply(foo.Apply(NewVariable()), atomSemiColon.Apply(atomTrue, atomFail)),
+			bar,
+		} {
+			_, err := Assertz(vm, c, Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+		}
+		ok, err := Retract(vm, foo.Apply(Integer(1)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Len(t, s.records["foo/1"], 3)
+		_, err = Abolish(vm, atomSlash.Apply(bar, Integer(0)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.NotContains(t, s.records, "bar/0")
+
+		restored := newVM()
+		assert.NoError(t, restored.SetClauseStore(&s))
+		x := NewVariable()
+		for _, vm := range []*VM{vm, restored} {
+			sols := solutions(vm, foo.Apply(x), x)
+			assert.Len(t, sols, 2)
+			assert.Equal(t, Integer(2), sols[0])
+		}
+
+		// Retracting an alternative of a disjunction keeps the other one.
+		y := NewVariable()
+		ok, err = Retract(restored, atomIf.Apply(foo.Apply(y), atomSemiColon.Apply(atomTrue, atomFail)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		var again VM
+		assert.NoError(t, again.SetClauseStore(&s))
+		p, ok := again.getProcedure(procedureIndicator{name: foo, arity: 1})
+		assert.True(t, ok)
+		assert.Len(t, p.(*userDefined).clauses, 2)
+		assert.Equal(t, 1, p.(*userDefined).clauses[1].alt)
+	})
+
+	t.Run("texts, asserta/1, and ReplacePredicate", func(t *testing.T) {
+		var s mapClauseStore
+		vm := newVM()
+		vm.FS = fstest.MapFS{"foo.pl": {Data: []byte(":-(dynamic(/(foo, 1))).\nfoo(1).\nfoo(2).\n")}}
+		assert.NoError(t, vm.SetClauseStore(&s))
+		x := NewVariable()
+		restored := func() []Term {
+			r := newVM()
+			assert.NoError(t, r.SetClauseStore(&s))
+			return solutions(r, foo.Apply(x), x)
+		}
+
+		_, err := vm.loadFile(context.Background(), NewAtom("foo"), loadAlways, false, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []Term{Integer(1), Integer(2)}, restored())
+
+		_, err = Asserta(vm, foo.Apply(Integer(0)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []Term{Integer(0), Integer(1), Integer(2)}, restored())
+
+		assert.NoError(t, vm.ReplacePredicate(atomSlash.Apply(foo, Integer(1)), []Term{foo.Apply(Integer(3))}))
+		assert.Equal(t, []Term{Integer(3)}, restored())
+
+		_, err = vm.loadFile(context.Background(), NewAtom("foo"), loadAlways, false, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []Term{Integer(1), Integer(2)}, restored())
+		assert.Equal(t, solutions(vm, foo.Apply(x), x), restored())
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		var s mapClauseStore
+		vm := newVM()
+		assert.NoError(t, vm.SetClauseStore(&s))
+		_, err := Assertz(vm, foo.Apply(Integer(1)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+
+		c := vm.Clone()
+		_, err = Assertz(c, foo.Apply(Integer(2)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		_, err = Retract(c, foo.Apply(Integer(1)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, s.records["foo/1"], 1, "the changes of the clone are not persisted")
+
+		var other mapClauseStore
+		assert.NoError(t, c.SetClauseStore(&other))
+		_, err = Assertz(c, foo.Apply(Integer(3)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, s.records["foo/1"], 1)
+		assert.Len(t, other.records["foo/1"], 2)
+
+		x := NewVariable()
+		assert.Equal(t, []Term{Integer(1)}, solutions(vm, foo.Apply(x), x))
+	})
+
+	t.Run("store failure", func(t *testing.T) {
+		var vm VM
+		s := mapClauseStore{}
+		assert.NoError(t, vm.SetClauseStore(&s))
+		_, err := Assertz(&vm, foo.Apply(Integer(1)), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+
+		s.err = errors.New("store")
+		_, err = Assertz(&vm, foo.Apply(Integer(2)), Success, nil).Force(context.Background())
+		assert.Equal(t, s.err, err)
+		_, err = Retract(&vm, foo.Apply(Integer(1)), Success, nil).Force(context.Background())
+		assert.Equal(t, s.err, err)
+
+		p, _ := vm.getProcedure(procedureIndicator{name: foo, arity: 1})
+		assert.Len(t, p.(*userDefined).clauses, 1)
+		assert.Zero(t, p.(*userDefined).clauses[0].erased)
+	})
+
+	t.Run("static procedure", func(t *testing.T) {
+		s := mapClauseStore{}
+		var vm VM
+		assert.NoError(t, vm.SetClauseStore(&s))
+		_, err := Assertz(&vm, foo, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+
+		other := VM{}
+		other.setProcedure(procedureIndicator{name: foo, arity: 0}, &userDefined{})
+		assert.Error(t, other.SetClauseStore(&s))
+	})
+
+	t.Run("malformed record", func(t *testing.T) {
+		s := mapClauseStore{records: map[string]map[int64][]byte{"foo/0": {0: {0x01}}}}
+		var vm VM
+		assert.ErrorIs(t, vm.SetClauseStore(&s), errClauseRecord)
+	})
+}
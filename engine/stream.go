@@ -2,11 +2,14 @@ package engine
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"strings"
+	"sync/atomic"
 )
 
 var (
@@ -21,13 +24,12 @@ var streamIDCounter uint64
 
 // nextStreamID returns a new unique stream ID.
 func nextStreamID() uint64 {
-	streamIDCounter++
-	return streamIDCounter
+	return atomic.AddUint64(&streamIDCounter, 1)
 }
 
 // resetStreamIDCounter resets the stream ID counter to 0.
 func resetStreamIDCounter() {
-	streamIDCounter = 0
+	atomic.StoreUint64(&streamIDCounter, 0)
 }
 
 // Stream is a prolog stream.
@@ -39,6 +41,8 @@ type Stream struct {
 	sink         io.Writer
 	buf          bufReader
 	lastRuneSize int
+	counts       streamCounts
+	lastCounts   streamCounts // the counts before the last rune read.
 
 	mode        ioMode
 	alias       Atom
@@ -48,6 +52,7 @@ type Stream struct {
 	eofAction   eofAction
 	reposition  bool
 	streamType  streamType
+	newline     newlineMode
 }
 
 // NewInputTextStream creates a new input text stream backed by the given io.Reader.
@@ -98,6 +103,19 @@ func NewOutputBinaryStream(w io.Writer) *Stream {
 	}
 }
 
+// NewMemoryStream creates a new input text stream which reads text from memory.
+// Unlike a stream created by NewInputTextStream, it's repositionable.
+func NewMemoryStream(text string) *Stream {
+	return &Stream{
+		id:         nextStreamID(),
+		source:     strings.NewReader(text),
+		mode:       ioModeRead,
+		eofAction:  eofActionReset,
+		reposition: true,
+		streamType: streamTypeText,
+	}
+}
+
 // WriteTerm outputs the Stream to an io.Writer.
 func (s *Stream) WriteTerm(w io.Writer, _ *WriteOptions, _ *Env) error {
 	if s.alias != "" {
@@ -157,6 +175,7 @@ func (s *Stream) ReadByte() (byte, error) {
 	b, err := s.buf.ReadByte()
 	if err == nil {
 		s.position += 1
+		s.counts.chars += 1
 	}
 	s.checkEOS(err)
 	return b, err
@@ -174,6 +193,7 @@ func (s *Stream) UnreadByte() error {
 	err := s.buf.UnreadByte()
 	if err == nil {
 		s.position -= 1
+		s.counts.chars -= 1
 		s.endOfStream = endOfStreamNot
 	}
 	return err
@@ -193,6 +213,10 @@ func (s *Stream) ReadRune() (r rune, size int, err error) {
 	r, n, err := s.buf.ReadRune()
 	s.position += int64(n)
 	s.lastRuneSize = n
+	if n > 0 {
+		s.lastCounts = s.counts
+		s.counts.add(r)
+	}
 	s.checkEOS(err)
 	return r, n, err
 }
@@ -209,6 +233,7 @@ func (s *Stream) UnreadRune() error {
 	err := s.buf.UnreadRune()
 	if err == nil {
 		s.position -= int64(s.lastRuneSize)
+		s.counts = s.lastCounts
 		s.endOfStream = endOfStreamNot
 		s.lastRuneSize = 0
 	}
@@ -235,11 +260,58 @@ func (s *Stream) Seek(offset int64, whence int) (int64, error) {
 	}
 
 	s.position = n
+	if s.streamType == streamTypeBinary {
+		s.counts = streamCounts{chars: n}
+	} else {
+		s.counts = countPrefix(s.source, n)
+	}
 	s.reset()
 
 	return n, nil
 }
 
+// streamCounts are the counts of the characters, the bytes in case of a binary stream, and the lines which have gone
+// through a stream.
+type streamCounts struct {
+	chars   int64
+	lines   int64 // the number of the newlines.
+	linePos int64 // the number of the characters since the last newline.
+}
+
+func (c *streamCounts) add(r rune) {
+	c.chars++
+	if r == '\n' {
+		c.lines++
+		c.linePos = 0
+		return
+	}
+	c.linePos++
+}
+
+func (c *streamCounts) addText(p []byte) {
+	for _, r := range string(p) {
+		c.add(r)
+	}
+}
+
+// countPrefix returns the counts of the first n bytes of r if it's an io.ReaderAt, e.g. a memory stream or a file.
+// Otherwise, it returns the counts of n characters in a single line.
+func countPrefix(r io.Reader, n int64) streamCounts {
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		return streamCounts{chars: n, linePos: n}
+	}
+	var c streamCounts
+	br := bufio.NewReader(io.NewSectionReader(ra, 0, n))
+	for {
+		r, _, err := br.ReadRune()
+		if err != nil {
+			return c
+		}
+		c.add(r)
+	}
+}
+
 // WriteByte writes the byte c to the underlying sink.
 // It throws an error if the stream is not an output binary stream,.
 func (s *Stream) WriteByte(c byte) error {
@@ -371,7 +443,7 @@ func fileSize(r io.Reader) int64 {
 }
 
 func (s *Stream) properties() []Term {
-	ps := make([]Term, 0, 9)
+	ps := make([]Term, 0, 12)
 
 	if n := s.Name(); n != "" {
 		ps = append(ps, atomFileName.Apply(NewAtom(n)))
@@ -394,6 +466,9 @@ func (s *Stream) properties() []Term {
 
 	ps = append(ps,
 		atomPosition.Apply(Integer(s.position)),
+		atomLineCount.Apply(Integer(s.counts.lines+1)),
+		atomLinePosition.Apply(Integer(s.counts.linePos)),
+		atomCharacterCount.Apply(Integer(s.counts.chars)),
 		atomEndOfStream.Apply(s.endOfStream.Term()),
 		atomEOFAction.Apply(s.eofAction.Term()),
 	)
@@ -406,6 +481,10 @@ func (s *Stream) properties() []Term {
 
 	ps = append(ps, atomType.Apply(s.streamType.Term()))
 
+	if s.streamType == streamTypeText {
+		ps = append(ps, atomNewline.Apply(s.newline.Term()))
+	}
+
 	return ps
 }
 
@@ -437,15 +516,34 @@ type textWriter struct {
 	stream *Stream
 }
 
-// Write writes to the underlying sink.
+// Write writes to the underlying sink. The newlines are translated according to the newline mode of the stream.
 // It throws an error if the stream is not an output text stream.
 func (t textWriter) Write(p []byte) (int, error) {
 	s := t.stream
+	if s.newline == newlineDOS && bytes.IndexByte(p, '\n') >= 0 {
+		return t.writeDOS(p)
+	}
 	n, err := s.sink.Write(p)
 	s.position += int64(n)
+	s.counts.addText(p[:n])
 	return n, err
 }
 
+// writeDOS writes p with the newlines translated to CRLF. It returns the number of the bytes of p written.
+// The counts are those of p since \r isn't a character of the text but a part of the newline.
+func (t textWriter) writeDOS(p []byte) (int, error) {
+	s := t.stream
+	q := bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))
+	n, err := s.sink.Write(q)
+	s.position += int64(n)
+	m := n - bytes.Count(q[:n], []byte("\r\n"))
+	if n > 0 && n < len(q) && q[n-1] == '\r' && q[n] == '\n' {
+		m-- // The newline is written halfway, i.e. only the \r inserted before it.
+	}
+	s.counts.addText(p[:m])
+	return m, err
+}
+
 type binaryWriter struct {
 	stream *Stream
 }
@@ -457,6 +555,7 @@ func (b binaryWriter) Write(p []byte) (int, error) {
 
 	n, err := s.sink.Write(p)
 	s.position += int64(n)
+	s.counts.chars += int64(n)
 	return n, err
 }
 
@@ -520,6 +619,23 @@ func (t streamType) Term() Term {
 	}[t]
 }
 
+// newlineMode describes how the newlines are written to a text stream.
+type newlineMode int
+
+const (
+	// newlinePOSIX means \n is written as is.
+	newlinePOSIX newlineMode = iota
+	// newlineDOS means \n is written as \r\n.
+	newlineDOS
+)
+
+func (m newlineMode) Term() Term {
+	return [...]Atom{
+		newlinePOSIX: atomPOSIX,
+		newlineDOS:   atomDOS,
+	}[m]
+}
+
 type endOfStream uint8
 
 const (
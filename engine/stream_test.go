@@ -61,6 +61,23 @@ func TestNewOutputBinaryStream(t *testing.T) {
 	}, NewOutputBinaryStream(os.Stdout))
 }
 
+func TestNewMemoryStream(t *testing.T) {
+	s := NewMemoryStream("héllo")
+
+	r, _, err := s.ReadRune()
+	assert.NoError(t, err)
+	assert.Equal(t, 'h', r)
+	r, _, err = s.ReadRune()
+	assert.NoError(t, err)
+	assert.Equal(t, 'é', r)
+
+	_, err = s.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+	r, _, err = s.ReadRune()
+	assert.NoError(t, err)
+	assert.Equal(t, 'h', r)
+}
+
 func TestStream_WriteTerm(t *testing.T) {
 	resetStreamIDCounter()
 
@@ -560,6 +577,56 @@ func TestStream_Seek(t *testing.T) {
 	}
 }
 
+func TestStream_counts(t *testing.T) {
+	t.Run("read", func(t *testing.T) {
+		s := NewMemoryStream("ab\nçd")
+		for range 4 {
+			_, _, err := s.ReadRune()
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, streamCounts{chars: 4, lines: 1, linePos: 1}, s.counts)
+
+		assert.NoError(t, s.UnreadRune())
+		assert.Equal(t, streamCounts{chars: 3, lines: 1, linePos: 0}, s.counts)
+
+		_, _, err := s.ReadRune()
+		assert.NoError(t, err)
+		_, _, err = s.ReadRune()
+		assert.NoError(t, err)
+		_, _, err = s.ReadRune()
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, streamCounts{chars: 5, lines: 1, linePos: 2}, s.counts)
+	})
+
+	t.Run("write", func(t *testing.T) {
+		var sb bytes.Buffer
+		s := NewOutputTextStream(&sb)
+		_, err := s.WriteRune('a')
+		assert.NoError(t, err)
+		_, err = textWriter{stream: s}.Write([]byte("ç\n\nde"))
+		assert.NoError(t, err)
+		assert.Equal(t, streamCounts{chars: 6, lines: 2, linePos: 2}, s.counts)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		var buf bytes.Buffer
+		s := NewOutputBinaryStream(&buf)
+		assert.NoError(t, s.WriteByte('\n'))
+		assert.Equal(t, streamCounts{chars: 1}, s.counts)
+	})
+
+	t.Run("seek", func(t *testing.T) {
+		s := NewMemoryStream("aç\nbc\nd")
+		_, err := s.Seek(7, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, streamCounts{chars: 6, lines: 2, linePos: 0}, s.counts)
+
+		_, err = s.Seek(0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, streamCounts{}, s.counts)
+	})
+}
+
 func TestStream_WriteByte(t *testing.T) {
 	var m mockWriter
 	m.On("Write", []byte("a")).Return(1, nil).Twice()
@@ -664,6 +731,61 @@ func TestStream_WriteRune(t *testing.T) {
 	}
 }
 
+func TestStream_newline(t *testing.T) {
+	t.Run("dos", func(t *testing.T) {
+		var sb bytes.Buffer
+		s := NewOutputTextStream(&sb)
+		s.newline = newlineDOS
+		w, err := s.textWriter()
+		assert.NoError(t, err)
+		n, err := w.Write([]byte("a\nb\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, 4, n)
+		assert.Equal(t, "a\r\nb\r\n", sb.String())
+		assert.Equal(t, int64(6), s.position)
+		assert.Equal(t, streamCounts{chars: 4, lines: 2}, s.counts)
+	})
+
+	t.Run("short write", func(t *testing.T) {
+		var m mockWriter
+		m.On("Write", []byte("a\r\nb\r\n")).Return(5, io.ErrShortWrite).Once()
+		defer m.AssertExpectations(t)
+
+		s := &Stream{sink: &m, mode: ioModeAppend, streamType: streamTypeText, newline: newlineDOS}
+		w, err := s.textWriter()
+		assert.NoError(t, err)
+		n, err := w.Write([]byte("a\nb\n"))
+		assert.Equal(t, io.ErrShortWrite, err)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, streamCounts{chars: 3, lines: 1, linePos: 1}, s.counts)
+	})
+
+	t.Run("halfway", func(t *testing.T) {
+		var m mockWriter
+		m.On("Write", []byte("\r\n")).Return(1, io.ErrShortWrite).Once()
+		defer m.AssertExpectations(t)
+
+		s := &Stream{sink: &m, mode: ioModeAppend, streamType: streamTypeText, newline: newlineDOS}
+		n, err := s.WriteRune('\n')
+		assert.Equal(t, io.ErrShortWrite, err)
+		assert.Equal(t, 0, n)
+		assert.Equal(t, streamCounts{}, s.counts)
+	})
+
+	t.Run("carriage return", func(t *testing.T) {
+		var m mockWriter
+		m.On("Write", []byte("a\r\r\n")).Return(2, io.ErrShortWrite).Once()
+		defer m.AssertExpectations(t)
+
+		s := &Stream{sink: &m, mode: ioModeAppend, streamType: streamTypeText, newline: newlineDOS}
+		w, err := s.textWriter()
+		assert.NoError(t, err)
+		n, err := w.Write([]byte("a\r\n"))
+		assert.Equal(t, io.ErrShortWrite, err)
+		assert.Equal(t, 2, n)
+	})
+}
+
 type mockFlusher struct {
 	mock.Mock
 }
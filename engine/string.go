@@ -0,0 +1,286 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var quotedStringEscapePattern = regexp.MustCompile(`[[:cntrl:]]|\\|"`)
+
+// String is a prolog string, a sequence of characters which is atomic unlike a list of characters or codes.
+type String string
+
+// WriteTerm outputs the String to an io.Writer.
+func (s String) WriteTerm(w io.Writer, opts *WriteOptions, _ *Env) error {
+	if !opts.quoted {
+		_, err := io.WriteString(w, string(s))
+		return err
+	}
+	_, err := fmt.Fprintf(w, `"%s"`, quotedStringEscapePattern.ReplaceAllStringFunc(string(s), func(s string) string {
+		if s == `"` {
+			return `\"`
+		}
+		return quotedIdentEscape(s)
+	}))
+	return err
+}
+
+// Compare compares the String with a Term.
+// Strings come after atoms and before compound terms in the standard order.
+func (s String) Compare(t Term, env *Env) int {
+	return CompareAtomic(s, t, func(a, b String) int {
+		return strings.Compare(string(a), string(b))
+	}, env)
+}
+
+func (s String) String() string {
+	return string(s)
+}
+
+// textOf returns the text of an atom, a string, a number, a list of characters, or a list of codes.
+func textOf(t Term, env *Env) (string, error) {
+	switch t := env.Resolve(t).(type) {
+	case Variable:
+		return "", InstantiationError(env)
+	case Atom:
+		if t == atomEmptyList {
+			return "", nil
+		}
+		return t.String(), nil
+	case String:
+		return string(t), nil
+	case Integer, Float, Rational:
+		var sb strings.Builder
+		if err := t.WriteTerm(&sb, &defaultWriteOptions, nil); err != nil {
+			return "", err
+		}
+		return sb.String(), nil
+	case charList:
+		return string(t), nil
+	case codeList:
+		return string(t), nil
+	case Compound:
+		if t.Functor() != atomDot || t.Arity() != 2 {
+			return "", typeError(validTypeString, t, env)
+		}
+		var sb strings.Builder
+		iter := ListIterator{List: t, Env: env}
+		for iter.Next() {
+			switch e := env.Resolve(iter.Current()).(type) {
+			case Variable:
+				return "", InstantiationError(env)
+			case Atom:
+				r, n := utf8.DecodeRuneInString(e.String())
+				if r == utf8.RuneError || n != len(e.String()) {
+					return "", typeError(validTypeCharacter, e, env)
+				}
+				_, _ = sb.WriteRune(r)
+			case Integer:
+				if e < 0 || e > utf8.MaxRune {
+					return "", representationError(flagCharacterCode, env)
+				}
+				_, _ = sb.WriteRune(rune(e))
+			default:
+				return "", typeError(validTypeCharacter, e, env)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return "", err
+		}
+		return sb.String(), nil
+	default:
+		return "", typeError(validTypeString, t, env)
+	}
+}
+
+// TypeString checks if t is a string.
+func TypeString(_ *VM, t Term, k Cont, env *Env) *Promise {
+	if _, ok := env.Resolve(t).(String); !ok {
+		return Bool(false)
+	}
+	return k(env)
+}
+
+// StringLength counts the characters of the text str and unifies the result with length.
+func StringLength(vm *VM, str, length Term, k Cont, env *Env) *Promise {
+	s, err := textOf(str, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	if err := checkPositiveInteger(length, env); err != nil {
+		return Error(err)
+	}
+
+	return Unify(vm, length, Integer(utf8.RuneCountInString(s)), k, env)
+}
+
+// StringConcat concatenates the texts str1 and str2 and unifies the resulting string with str3, or enumerates the
+// pairs of strings str1 and str2 which concatenate to the text str3.
+func StringConcat(vm *VM, str1, str2, str3 Term, k Cont, env *Env) *Promise {
+	if _, ok := env.Resolve(str3).(Variable); ok {
+		s1, err := textOf(str1, env)
+		if err != nil {
+			return Error(err)
+		}
+		s2, err := textOf(str2, env)
+		if err != nil {
+			return Error(err)
+		}
+		return Unify(vm, str3, String(s1+s2), k, env)
+	}
+
+	s3, err := textOf(str3, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	pattern := tuple(str1, str2)
+	ks := make([]func(context.Context) *Promise, 0, len(s3)+1)
+	for i := range s3 {
+		s1, s2 := String(s3[:i]), String(s3[i:])
+		ks = append(ks, func(context.Context) *Promise {
+			return Unify(vm, pattern, tuple(s1, s2), k, env)
+		})
+	}
+	ks = append(ks, func(context.Context) *Promise {
+		return Unify(vm, pattern, tuple(String(s3), String("")), k, env)
+	})
+	return Delay(ks...)
+}
+
+// StringChars breaks down the text str into a list of characters and unifies it with chars, or constructs a string
+// from chars and unifies it with str.
+func StringChars(vm *VM, str, chars Term, k Cont, env *Env) *Promise {
+	return stringList(vm, str, chars, CharList, k, env)
+}
+
+// StringCodes breaks down the text str into a list of codes and unifies it with codes, or constructs a string from
+// codes and unifies it with str.
+func StringCodes(vm *VM, str, codes Term, k Cont, env *Env) *Promise {
+	return stringList(vm, str, codes, CodeList, k, env)
+}
+
+func stringList(vm *VM, str, list Term, toList func(string) Term, k Cont, env *Env) *Promise {
+	if _, ok := env.Resolve(str).(Variable); ok {
+		s, err := textOf(list, env)
+		if err != nil {
+			return Error(err)
+		}
+		return Unify(vm, str, String(s), k, env)
+	}
+
+	s, err := textOf(str, env)
+	if err != nil {
+		return Error(err)
+	}
+	return Unify(vm, list, toList(s), k, env)
+}
+
+// StringToAtom converts the text str to an atom and unifies it with atom, or converts the text atom to a string and
+// unifies it with str.
+func StringToAtom(vm *VM, str, atom Term, k Cont, env *Env) *Promise {
+	if _, ok := env.Resolve(str).(Variable); ok {
+		a, err := textOf(atom, env)
+		if err != nil {
+			return Error(err)
+		}
+		return Unify(vm, str, String(a), k, env)
+	}
+
+	s, err := textOf(str, env)
+	if err != nil {
+		return Error(err)
+	}
+	return Unify(vm, atom, NewAtom(s), k, env)
+}
+
+// SplitString breaks the text str into the strings separated by any of the characters of sepChars, removes any of
+// the characters of pad from both ends of them, and unifies the list of them with subStrings.
+// If sepChars is empty, str is only stripped.
+func SplitString(vm *VM, str, sepChars, pad, subStrings Term, k Cont, env *Env) *Promise {
+	s, err := textOf(str, env)
+	if err != nil {
+		return Error(err)
+	}
+	sep, err := textOf(sepChars, env)
+	if err != nil {
+		return Error(err)
+	}
+	p, err := textOf(pad, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	fields := []string{s}
+	if sep != "" {
+		fields = fields[:0]
+		start := 0
+		for i, r := range s {
+			if strings.ContainsRune(sep, r) {
+				fields = append(fields, s[start:i])
+				start = i + utf8.RuneLen(r)
+			}
+		}
+		fields = append(fields, s[start:])
+	}
+
+	subs := make([]Term, len(fields))
+	for i, f := range fields {
+		subs[i] = String(strings.Trim(f, p))
+	}
+	return Unify(vm, subStrings, List(subs...), k, env)
+}
+
+// SubString unifies subString with a substring of the text str of length characters which appears with before
+// characters preceding it and after characters following it.
+func SubString(vm *VM, str, before, length, after, subString Term, k Cont, env *Env) *Promise {
+	s, err := textOf(str, env)
+	if err != nil {
+		return Error(err)
+	}
+	rs := []rune(s)
+
+	for _, n := range []Term{before, length, after} {
+		if err := checkPositiveInteger(n, env); err != nil {
+			return Error(err)
+		}
+	}
+
+	// If the substring is known, only its occurrences are enumerated.
+	if _, ok := env.Resolve(subString).(Variable); !ok {
+		sub, err := textOf(subString, env)
+		if err != nil {
+			return Error(err)
+		}
+		n := utf8.RuneCountInString(sub)
+		pattern := tuple(before, length, after)
+		var ks []func(context.Context) *Promise
+		for i := 0; i+n <= len(rs); i++ {
+			if string(rs[i:i+n]) != sub {
+				continue
+			}
+			b, a := Integer(i), Integer(len(rs)-i-n)
+			ks = append(ks, func(context.Context) *Promise {
+				return Unify(vm, pattern, tuple(b, Integer(n), a), k, env)
+			})
+		}
+		return Delay(ks...)
+	}
+
+	pattern := tuple(before, length, after, subString)
+	var ks []func(context.Context) *Promise
+	for i := 0; i <= len(rs); i++ {
+		for j := i; j <= len(rs); j++ {
+			b, l, a, sub := Integer(i), Integer(j-i), Integer(len(rs)-j), String(rs[i:j])
+			ks = append(ks, func(context.Context) *Promise {
+				return Unify(vm, pattern, tuple(b, l, a, sub), k, env)
+			})
+		}
+	}
+	return Delay(ks...)
+}
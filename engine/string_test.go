@@ -0,0 +1,286 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestString_WriteTerm(t *testing.T) {
+	tests := []struct {
+		s      String
+		opts   WriteOptions
+		output string
+	}{
+		{s: `abc`, output: `abc`},
+		{s: `abc`, opts: WriteOptions{quoted: true}, output: `"abc"`},
+		{s: `say "hi"`, opts: WriteOptions{quoted: true}, output: `"say \"hi\""`},
+		{s: "a\nb\\", opts: WriteOptions{quoted: true}, output: `"a\nb\\"`},
+		{s: `don't`, opts: WriteOptions{quoted: true}, output: `"don't"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, tt.s.WriteTerm(&buf, &tt.opts, nil))
+			assert.Equal(t, tt.output, buf.String())
+		})
+	}
+}
+
+func TestString_Compare(t *testing.T) {
+	assert.Equal(t, 0, String("abc").Compare(String("abc"), nil))
+	assert.Equal(t, -1, String("abc").Compare(String("abd"), nil))
+	assert.Equal(t, 1, String("b").Compare(String("abc"), nil))
+	assert.Equal(t, 1, String("a").Compare(NewAtom("z"), nil))
+	assert.Equal(t, 1, String("a").Compare(Integer(1), nil))
+	assert.Equal(t, -1, String("a").Compare(NewAtom("f").Apply(Integer(1)), nil))
+	assert.Equal(t, 1, String("a").Compare(NewVariable(), nil))
+}
+
+func TestTypeString(t *testing.T) {
+	ok, err := TypeString(nil, String("abc"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = TypeString(nil, NewAtom("abc"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestStringLength(t *testing.T) {
+	tests := []struct {
+		title  string
+		str    Term
+		length Term
+		ok     bool
+		err    error
+	}{
+		{title: "string", str: String("héllo"), length: Integer(5), ok: true},
+		{title: "atom", str: NewAtom("abc"), length: Integer(3), ok: true},
+		{title: "codes", str: codeList("ab"), length: Integer(2), ok: true},
+		{title: "empty list", str: atomEmptyList, length: Integer(0), ok: true},
+		{title: "wrong length", str: String("abc"), length: Integer(2), ok: false},
+		{title: "variable", str: NewVariable(), length: Integer(2), err: InstantiationError(nil)},
+		{title: "not text", str: NewAtom("f").Apply(Integer(1)), length: Integer(2), err: typeError(validTypeString, NewAtom("f").Apply(Integer(1)), nil)},
+		{title: "not an integer", str: String("abc"), length: NewAtom("three"), err: typeError(validTypeInteger, NewAtom("three"), nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			ok, err := StringLength(nil, tt.str, tt.length, Success, nil).Force(context.Background())
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func TestStringConcat(t *testing.T) {
+	t.Run("str3 is a variable", func(t *testing.T) {
+		str3 := NewVariable()
+		ok, err := StringConcat(nil, NewAtom("foo"), String("bar"), str3, func(env *Env) *Promise {
+			assert.Equal(t, String("foobar"), env.Resolve(str3))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("str3 is a string", func(t *testing.T) {
+		var got [][2]Term
+		v1, v2 := NewVariable(), NewVariable()
+		ok, err := StringConcat(nil, v1, v2, String("añb"), func(env *Env) *Promise {
+			got = append(got, [2]Term{env.Resolve(v1), env.Resolve(v2)})
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, [][2]Term{
+			{String(""), String("añb")},
+			{String("a"), String("ñb")},
+			{String("añ"), String("b")},
+			{String("añb"), String("")},
+		}, got)
+	})
+
+	t.Run("str1 and str3 are variables", func(t *testing.T) {
+		ok, err := StringConcat(nil, NewVariable(), String("bar"), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		assert.False(t, ok)
+	})
+}
+
+func TestStringChars(t *testing.T) {
+	t.Run("str is a string", func(t *testing.T) {
+		chars := NewVariable()
+		ok, err := StringChars(nil, String("abc"), chars, func(env *Env) *Promise {
+			assert.Equal(t, charList("abc"), env.Resolve(chars))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("str is a variable", func(t *testing.T) {
+		str := NewVariable()
+		ok, err := StringChars(nil, str, List(NewAtom("a"), NewAtom("b")), func(env *Env) *Promise {
+			assert.Equal(t, String("ab"), env.Resolve(str))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		ok, err := StringChars(nil, String(""), atomEmptyList, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("not a character", func(t *testing.T) {
+		ok, err := StringChars(nil, NewVariable(), List(NewAtom("ab")), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeCharacter, NewAtom("ab"), nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("partial list", func(t *testing.T) {
+		ok, err := StringChars(nil, NewVariable(), PartialList(NewVariable(), NewAtom("a")), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		assert.False(t, ok)
+	})
+}
+
+func TestStringCodes(t *testing.T) {
+	t.Run("str is a string", func(t *testing.T) {
+		codes := NewVariable()
+		ok, err := StringCodes(nil, String("ab"), codes, func(env *Env) *Promise {
+			assert.Equal(t, codeList("ab"), env.Resolve(codes))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("str is a variable", func(t *testing.T) {
+		str := NewVariable()
+		ok, err := StringCodes(nil, str, List(Integer('a'), Integer('b')), func(env *Env) *Promise {
+			assert.Equal(t, String("ab"), env.Resolve(str))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		ok, err := StringCodes(nil, NewVariable(), List(Integer(-1)), Success, nil).Force(context.Background())
+		assert.Equal(t, representationError(flagCharacterCode, nil), err)
+		assert.False(t, ok)
+	})
+}
+
+func TestStringToAtom(t *testing.T) {
+	t.Run("str is a string", func(t *testing.T) {
+		atom := NewVariable()
+		ok, err := StringToAtom(nil, String("abc"), atom, func(env *Env) *Promise {
+			assert.Equal(t, NewAtom("abc"), env.Resolve(atom))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("str is a variable", func(t *testing.T) {
+		str := NewVariable()
+		ok, err := StringToAtom(nil, str, NewAtom("abc"), func(env *Env) *Promise {
+			assert.Equal(t, String("abc"), env.Resolve(str))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("both are variables", func(t *testing.T) {
+		ok, err := StringToAtom(nil, NewVariable(), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		assert.False(t, ok)
+	})
+}
+
+func TestSplitString(t *testing.T) {
+	tests := []struct {
+		title    string
+		str, sep Term
+		pad      Term
+		subs     Term
+	}{
+		{title: "separators", str: String("a,b,,c"), sep: String(","), pad: String(""), subs: List(String("a"), String("b"), String(""), String("c"))},
+		{title: "separators and padding", str: String("/home//jan///nice/path"), sep: String("/"), pad: String(""), subs: List(String(""), String("home"), String(""), String("jan"), String(""), String(""), String("nice"), String("path"))},
+		{title: "strip", str: String("  a word "), sep: String(""), pad: String(" "), subs: List(String("a word"))},
+		{title: "fields", str: String("SWI-Prolog, 7.0"), sep: String(","), pad: String(" "), subs: List(String("SWI-Prolog"), String("7.0"))},
+		{title: "atoms", str: NewAtom("a b"), sep: NewAtom(" "), pad: atomEmptyList, subs: List(String("a"), String("b"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			subs := NewVariable()
+			ok, err := SplitString(nil, tt.str, tt.sep, tt.pad, subs, func(env *Env) *Promise {
+				assert.Equal(t, tt.subs, env.Resolve(subs))
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+	}
+
+	t.Run("variable", func(t *testing.T) {
+		ok, err := SplitString(nil, NewVariable(), String(""), String(""), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		assert.False(t, ok)
+	})
+}
+
+func TestSubString(t *testing.T) {
+	t.Run("substring is known", func(t *testing.T) {
+		var got [][3]Term
+		b, l, a := NewVariable(), NewVariable(), NewVariable()
+		ok, err := SubString(nil, String("abcab"), b, l, a, String("ab"), func(env *Env) *Promise {
+			got = append(got, [3]Term{env.Resolve(b), env.Resolve(l), env.Resolve(a)})
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, [][3]Term{
+			{Integer(0), Integer(2), Integer(3)},
+			{Integer(3), Integer(2), Integer(0)},
+		}, got)
+	})
+
+	t.Run("substring is a variable", func(t *testing.T) {
+		sub := NewVariable()
+		ok, err := SubString(nil, String("héllo"), Integer(1), Integer(3), NewVariable(), sub, func(env *Env) *Promise {
+			assert.Equal(t, String("éll"), env.Resolve(sub))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("enumerate", func(t *testing.T) {
+		var c int
+		ok, err := SubString(nil, String("ab"), NewVariable(), NewVariable(), NewVariable(), NewVariable(), func(*Env) *Promise {
+			c++
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, 6, c)
+	})
+
+	t.Run("negative length", func(t *testing.T) {
+		ok, err := SubString(nil, String("ab"), NewVariable(), Integer(-1), NewVariable(), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, domainError(validDomainNotLessThanZero, Integer(-1), nil), err)
+		assert.False(t, ok)
+	})
+}
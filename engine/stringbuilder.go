@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// stringBuilderIDCounter is a counter for generating unique string builder IDs.
+var stringBuilderIDCounter uint64
+
+// stringBuilder is an opaque term which accumulates text in amortized linear time.
+// Unlike the terms built by atom_concat/3, its content is updated in place and the updates aren't undone on
+// backtracking.
+type stringBuilder struct {
+	id uint64
+	sb strings.Builder
+}
+
+// WriteTerm outputs the stringBuilder to an io.Writer.
+func (b *stringBuilder) WriteTerm(w io.Writer, _ *WriteOptions, _ *Env) error {
+	_, err := fmt.Fprintf(w, "<string_builder>(0x%x)", b.id)
+	return err
+}
+
+// Compare compares the stringBuilder with a Term.
+func (b *stringBuilder) Compare(t Term, env *Env) int {
+	return CompareAtomic[*stringBuilder](b, t, func(b *stringBuilder, t *stringBuilder) int {
+		switch {
+		case b.id > t.id:
+			return 1
+		case b.id < t.id:
+			return -1
+		default:
+			return 0
+		}
+	}, env)
+}
+
+// StringBuilderNew unifies builder with a new empty string builder.
+func StringBuilderNew(vm *VM, builder Term, k Cont, env *Env) *Promise {
+	if _, ok := env.Resolve(builder).(Variable); !ok {
+		return Error(UninstantiationError(env.Resolve(builder), env))
+	}
+
+	b := stringBuilder{id: atomic.AddUint64(&stringBuilderIDCounter, 1)}
+	return Unify(vm, builder, &b, k, env)
+}
+
+// StringBuilderAppend appends the text of text, which is either an atom, a string, a number, or a list of characters
+// or codes, to builder.
+func StringBuilderAppend(_ *VM, builder, text Term, k Cont, env *Env) *Promise {
+	b, err := stringBuilderOf(builder, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	s, err := textOf(text, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	_, _ = b.sb.WriteString(s)
+	return k(env)
+}
+
+// StringBuilderToAtom unifies atom with the text accumulated in builder so far.
+func StringBuilderToAtom(vm *VM, builder, atom Term, k Cont, env *Env) *Promise {
+	b, err := stringBuilderOf(builder, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	switch a := env.Resolve(atom).(type) {
+	case Variable, Atom:
+		break
+	default:
+		return Error(typeError(validTypeAtom, a, env))
+	}
+
+	return Unify(vm, atom, NewAtom(b.sb.String()), k, env)
+}
+
+func stringBuilderOf(builder Term, env *Env) (*stringBuilder, error) {
+	switch b := env.Resolve(builder).(type) {
+	case Variable:
+		return nil, InstantiationError(env)
+	case *stringBuilder:
+		return b, nil
+	default:
+		return nil, typeError(validTypeStringBuilder, b, env)
+	}
+}
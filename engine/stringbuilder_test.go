@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringBuilder(t *testing.T) {
+	var vm VM
+
+	t.Run("ok", func(t *testing.T) {
+		b, a := NewVariable(), NewVariable()
+		ok, err := StringBuilderNew(&vm, b, func(env *Env) *Promise {
+			return StringBuilderAppend(&vm, b, NewAtom("foo"), func(env *Env) *Promise {
+				return StringBuilderAppend(&vm, b, Integer(1), func(env *Env) *Promise {
+					return StringBuilderAppend(&vm, b, CodeList("bar"), func(env *Env) *Promise {
+						return StringBuilderToAtom(&vm, b, a, func(env *Env) *Promise {
+							assert.Equal(t, NewAtom("foo1bar"), env.Resolve(a))
+							return Bool(true)
+						}, env)
+					}, env)
+				}, env)
+			}, env)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("builder is not a variable", func(t *testing.T) {
+		ok, err := StringBuilderNew(&vm, NewAtom("b"), Success, nil).Force(context.Background())
+		assert.Equal(t, UninstantiationError(NewAtom("b"), nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("builder is a variable", func(t *testing.T) {
+		ok, err := StringBuilderAppend(&vm, NewVariable(), NewAtom("foo"), Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("builder is not a string builder", func(t *testing.T) {
+		ok, err := StringBuilderToAtom(&vm, NewAtom("b"), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeStringBuilder, NewAtom("b"), nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("atom is neither a variable nor an atom", func(t *testing.T) {
+		b := &stringBuilder{}
+		ok, err := StringBuilderToAtom(&vm, b, Integer(0), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeAtom, Integer(0), nil), err)
+		assert.False(t, ok)
+	})
+
+	t.Run("text is not a text", func(t *testing.T) {
+		b := &stringBuilder{}
+		ok, err := StringBuilderAppend(&vm, b, NewAtom("f").Apply(NewAtom("a")), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeString, NewAtom("f").Apply(NewAtom("a")), nil), err)
+		assert.False(t, ok)
+	})
+}
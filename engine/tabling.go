@@ -0,0 +1,400 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Tabled predicates are evaluated by iterating their clauses to a fixpoint. A call to a tabled predicate whose
+// variant is already being evaluated consumes the answers found so far instead of recursing, which makes
+// left-recursive and mutually recursive predicates terminate as long as they have finitely many answers.
+// A table is complete once its evaluation doesn't depend on any older table under evaluation. Otherwise, it's
+// completed along with the oldest table it depends on.
+//
+// A tabled predicate declared with modes, e.g. :- table path(_, _, min)., keeps one answer per variant of its indexed
+// arguments, the ones with _, and aggregates the other argument of the answers by its mode:
+//
+//	min, max	the least or the greatest in the standard order of terms
+//	first	the first answer found
+//	po(Name/2)	the new answer if call(Name, Old, New) succeeds
+//	lattice(Name/3)	Joined where call(Name, Old, New, Joined)
+//
+// So, a shortest path computation terminates even on a cyclic graph since the longer paths are subsumed.
+// Since the clauses are iterated until no answer changes, the aggregation must converge: call(Name, Old, New) must be
+// a strict order and call(Name, Old, New, Joined) an idempotent join, e.g. the maximum rather than the sum.
+
+type tableKey struct {
+	u    *userDefined
+	call string
+}
+
+type answerTable struct {
+	answers  []Term
+	keys     map[string]int // the indices of the answers by their variant keys.
+	complete bool
+	frame    int // index in the evaluation stack while being evaluated, -1 otherwise.
+}
+
+type tableFrame struct {
+	low int            // index of the oldest frame whose table was consumed by this evaluation.
+	scc []*answerTable // incomplete tables evaluated on top of this frame.
+}
+
+// AbolishAllTables removes all the answer tables.
+func AbolishAllTables(vm *VM, k Cont, env *Env) *Promise {
+	vm.tables = nil
+	return k(env)
+}
+
+func (vm *VM) callTabled(u *userDefined, args []Term, k Cont, env *Env) *Promise {
+	return Delay(func(ctx context.Context) *Promise {
+		// The moded arguments are aggregated over all the answers. So, they're free in the evaluated call and the
+		// aggregated answers are unified with them, e.g. path(a, c, 5) fails if the shortest path costs 3.
+		call := list(args)
+		if u.modes != nil {
+			call = make(list, len(args))
+			for i, m := range u.modes {
+				if m.kind == tableModeIndex {
+					call[i] = args[i]
+				} else {
+					call[i] = NewVariable()
+				}
+			}
+		}
+		key := tableKey{u: u, call: variantKey(call, env)}
+		t, ok := vm.tables[key]
+		if !ok {
+			if vm.tables == nil {
+				vm.tables = map[tableKey]*answerTable{}
+			}
+			t = &answerTable{keys: map[string]int{}, frame: -1}
+			vm.tables[key] = t
+		}
+
+		switch {
+		case t.complete:
+		case t.frame >= 0:
+			if top := vm.tableStack[len(vm.tableStack)-1]; t.frame < top.low {
+				top.low = t.frame
+			}
+		default:
+			if err := vm.evaluateTable(ctx, u, t, call, env); err != nil {
+				return Error(err)
+			}
+		}
+
+		answers := t.answers
+		ks := make([]PromiseFunc, len(answers))
+		for i := range answers {
+			a := answers[i]
+			ks[i] = func(context.Context) *Promise {
+				c, err := renamedCopy(a, nil, nil)
+				if err != nil {
+					return Error(err)
+				}
+				return Unify(vm, list(args), c, k, env)
+			}
+		}
+		return Delay(ks...)
+	})
+}
+
+func (vm *VM) evaluateTable(ctx context.Context, u *userDefined, t *answerTable, call list, env *Env) error {
+	i := len(vm.tableStack)
+	f := tableFrame{low: i}
+	vm.tableStack = append(vm.tableStack, &f)
+	t.frame = i
+	defer func() {
+		vm.tableStack = vm.tableStack[:i]
+		t.frame = -1
+	}()
+
+	for {
+		gen := vm.tableGen
+		c, err := renamedCopy(call, nil, env)
+		if err != nil {
+			return err
+		}
+		args := c.(list)
+		if _, err := u.clauses.call(vm, args, func(env *Env) *Promise {
+			a, err := renamedCopy(args, nil, env)
+			if err != nil {
+				return Error(err)
+			}
+			if err := vm.addAnswer(ctx, u, t, a.(list)); err != nil {
+				return Error(err)
+			}
+			return Bool(false)
+		}, env).Force(ctx); err != nil {
+			return err
+		}
+		if vm.tableGen == gen {
+			break
+		}
+	}
+
+	if f.low < i {
+		parent := vm.tableStack[i-1]
+		if f.low < parent.low {
+			parent.low = f.low
+		}
+		parent.scc = append(parent.scc, t)
+		parent.scc = append(parent.scc, f.scc...)
+		return nil
+	}
+
+	t.complete = true
+	for _, t := range f.scc {
+		t.complete = true
+	}
+	return nil
+}
+
+// addAnswer adds the answer a to t unless it's subsumed by the answers of t.
+func (vm *VM) addAnswer(ctx context.Context, u *userDefined, t *answerTable, a list) error {
+	moded := -1
+	for i, m := range u.modes {
+		if m.kind != tableModeIndex {
+			moded = i
+		}
+	}
+	if moded < 0 {
+		key := variantKey(a, nil)
+		if _, ok := t.keys[key]; !ok {
+			t.keys[key] = len(t.answers)
+			t.answers = append(t.answers, a)
+			vm.tableGen++
+		}
+		return nil
+	}
+
+	indexed := make(list, 0, len(a)-1)
+	indexed = append(indexed, a[:moded]...)
+	indexed = append(indexed, a[moded+1:]...)
+	key := variantKey(indexed, nil)
+	i, ok := t.keys[key]
+	if !ok {
+		t.keys[key] = len(t.answers)
+		t.answers = append(t.answers, a)
+		vm.tableGen++
+		return nil
+	}
+
+	old := t.answers[i].(list)
+	v, err := u.modes[moded].aggregate(ctx, vm, old[moded], a[moded])
+	if err != nil {
+		return err
+	}
+	if variantKey(v, nil) == variantKey(old[moded], nil) {
+		return nil
+	}
+	updated := make(list, len(a))
+	copy(updated, a)
+	updated[moded] = v
+	t.answers[i] = updated
+	vm.tableGen++
+	return nil
+}
+
+// tableModeKind is how an argument of the answers of a tabled predicate is aggregated.
+type tableModeKind int
+
+const (
+	tableModeIndex tableModeKind = iota
+	tableModeMin
+	tableModeMax
+	tableModeFirst
+	tableModePo
+	tableModeLattice
+)
+
+type tableMode struct {
+	kind tableModeKind
+	name Atom // the predicate of po/1 and lattice/1.
+}
+
+// aggregate returns the value of the argument of an answer subsuming the old and the new ones.
+func (m tableMode) aggregate(ctx context.Context, vm *VM, old, new Term) (Term, error) {
+	switch m.kind {
+	case tableModeMin:
+		if new.Compare(old, nil) < 0 {
+			return new, nil
+		}
+		return old, nil
+	case tableModeMax:
+		if new.Compare(old, nil) > 0 {
+			return new, nil
+		}
+		return old, nil
+	case tableModePo:
+		ok, err := Call(vm, m.name.Apply(old, new), Success, nil).Force(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return new, nil
+		}
+		return old, nil
+	case tableModeLattice:
+		joined := old
+		j := NewVariable()
+		if _, err := Call(vm, m.name.Apply(old, new, j), func(env *Env) *Promise {
+			var err error
+			joined, err = renamedCopy(j, nil, env)
+			if err != nil {
+				return Error(err)
+			}
+			return Bool(true)
+		}, nil).Force(ctx); err != nil {
+			return nil, err
+		}
+		return joined, nil
+	default: // tableModeFirst
+		return old, nil
+	}
+}
+
+// Term returns the mode as it's declared.
+func (m tableMode) Term() Term {
+	switch m.kind {
+	case tableModeMin:
+		return atomMin
+	case tableModeMax:
+		return atomMax
+	case tableModeFirst:
+		return atomFirst
+	case tableModePo:
+		return atomPo.Apply(atomSlash.Apply(m.name, Integer(2)))
+	case tableModeLattice:
+		return atomLattice.Apply(atomSlash.Apply(m.name, Integer(3)))
+	default:
+		return NewVariable()
+	}
+}
+
+// tableSpec returns how the tabled procedure identified by pi is declared.
+func (u *userDefined) tableSpec(pi procedureIndicator) Term {
+	if u.modes == nil {
+		return pi.Term()
+	}
+	args := make([]Term, len(u.modes))
+	for i, m := range u.modes {
+		args[i] = m.Term()
+	}
+	return pi.name.Apply(args...)
+}
+
+// declareTables makes the procedures tabled. Each declaration is either a predicate indicator or a head whose
+// arguments are the modes of the arguments, e.g. path(_, _, min).
+func (t *text) declareTables(decls Term) error {
+	iter := anyIterator{Any: decls}
+	for iter.Next() {
+		d, ok := iter.Current().(Compound)
+		if !ok || d.Functor() == atomSlash && d.Arity() == 2 {
+			if err := t.forEachUserDefined(iter.Current(), func(u *userDefined) {
+				u.tabled = true
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		modes := make([]tableMode, d.Arity())
+		moded := false
+		for i := range modes {
+			m, err := parseTableMode(d.Arg(i))
+			if err != nil {
+				return err
+			}
+			if m.kind != tableModeIndex {
+				if moded {
+					return domainError(validDomainTableMode, d, nil)
+				}
+				moded = true
+			}
+			modes[i] = m
+		}
+
+		pi := procedureIndicator{name: d.Functor(), arity: Integer(d.Arity())}
+		u, ok := t.getClause(pi)
+		if !ok {
+			u = &userDefined{}
+			t.setClause(pi, u)
+		}
+		u.tabled = true
+		u.modes = modes
+	}
+	return iter.Err()
+}
+
+func parseTableMode(t Term) (tableMode, error) {
+	switch m := t.(type) {
+	case Variable:
+		return tableMode{kind: tableModeIndex}, nil
+	case Atom:
+		switch m {
+		case atomMin:
+			return tableMode{kind: tableModeMin}, nil
+		case atomMax:
+			return tableMode{kind: tableModeMax}, nil
+		case atomFirst:
+			return tableMode{kind: tableModeFirst}, nil
+		}
+	case Compound:
+		if m.Arity() != 1 {
+			break
+		}
+		var (
+			kind  tableModeKind
+			arity Integer
+		)
+		switch m.Functor() {
+		case atomPo:
+			kind, arity = tableModePo, 2
+		case atomLattice:
+			kind, arity = tableModeLattice, 3
+		default:
+			return tableMode{}, domainError(validDomainTableMode, m, nil)
+		}
+		pi, ok := m.Arg(0).(Compound)
+		if !ok || pi.Functor() != atomSlash || pi.Arity() != 2 {
+			break
+		}
+		name, ok := pi.Arg(0).(Atom)
+		if !ok || pi.Arg(1) != arity {
+			break
+		}
+		return tableMode{kind: kind, name: name}, nil
+	}
+	return tableMode{}, domainError(validDomainTableMode, t, nil)
+}
+
+// variantKey returns a string which is the same for variants of t.
+func variantKey(t Term, env *Env) string {
+	var sb strings.Builder
+	vars := map[Variable]int{}
+	stack := []Term{t}
+	for len(stack) > 0 {
+		t, stack = stack[len(stack)-1], stack[:len(stack)-1]
+		switch t := env.Resolve(t).(type) {
+		case Variable:
+			n, ok := vars[t]
+			if !ok {
+				n = len(vars)
+				vars[t] = n
+			}
+			_, _ = fmt.Fprintf(&sb, "_%d ", n)
+		case Compound:
+			_, _ = fmt.Fprintf(&sb, "%q/%d ", t.Functor(), t.Arity())
+			for i := t.Arity() - 1; i >= 0; i-- {
+				stack = append(stack, t.Arg(i))
+			}
+		default:
+			_, _ = fmt.Fprintf(&sb, "%T:", t)
+			_ = t.WriteTerm(&sb, &WriteOptions{quoted: true}, nil)
+			sb.WriteByte(' ')
+		}
+	}
+	return sb.String()
+}
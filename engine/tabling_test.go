@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_callTabled(t *testing.T) {
+	var vm VM
+	vm.Register3(NewAtom("findall"), FindAll)
+	vm.Register2(NewAtom("sort"), Sort)
+	vm.Register0(NewAtom("abolish_all_tables"), AbolishAllTables)
+	vm.Register1(NewAtom("assertz"), Assertz)
+	assert.NoError(t, vm.Compile(context.Background(), `
+:-(table(/(path, 2))).
+:-(path(X, Y), ','(path(X, Z), edge(Z, Y))).
+:-(path(X, Y), edge(X, Y)).
+
+:-(dynamic(/(edge, 2))).
+edge(a, b).
+edge(b, c).
+edge(c, a).
+edge(c, d).
+`))
+
+	solutions := func(goal string) Term {
+		t.Helper()
+		var result Term
+		vm.Register1(NewAtom("result"), func(_ *VM, r Term, k Cont, env *Env) *Promise {
+			result = env.Resolve(r)
+			return k(env)
+		})
+		assert.NoError(t, vm.Compile(context.Background(), `:-(','(findall(X, `+goal+`, Xs), ','(sort(Xs, Ys), result(Ys)))).`))
+		return result
+	}
+
+	t.Run("left recursion", func(t *testing.T) {
+		assert.Equal(t, List(NewAtom("a"), NewAtom("b"), NewAtom("c"), NewAtom("d")), solutions(`path(a, X)`))
+		assert.Equal(t, List(), solutions(`path(d, X)`))
+	})
+
+	t.Run("answers are reused", func(t *testing.T) {
+		n := len(vm.tables)
+		assert.Equal(t, List(NewAtom("a"), NewAtom("b"), NewAtom("c"), NewAtom("d")), solutions(`path(b, X)`))
+		assert.Len(t, vm.tables, n+1)
+		for _, tb := range vm.tables {
+			assert.True(t, tb.complete)
+		}
+	})
+
+	t.Run("abolish all tables", func(t *testing.T) {
+		assert.NoError(t, vm.Compile(context.Background(), `:-(','(assertz(edge(d, e)), abolish_all_tables)).`))
+		assert.Empty(t, vm.tables)
+		assert.Equal(t, List(NewAtom("a"), NewAtom("b"), NewAtom("c"), NewAtom("d"), NewAtom("e")), solutions(`path(a, X)`))
+	})
+}
+
+func TestVM_callTabled_mutualRecursion(t *testing.T) {
+	var vm VM
+	vm.Register3(NewAtom("findall"), FindAll)
+	var result Term
+	vm.Register1(NewAtom("result"), func(_ *VM, r Term, k Cont, env *Env) *Promise {
+		result = env.Resolve(r)
+		return k(env)
+	})
+	assert.NoError(t, vm.Compile(context.Background(), `
+:-(table(','(/(even, 1), /(odd, 1)))).
+:-(even(X), ','(odd(Y), succ(Y, X))).
+even(z).
+:-(odd(X), ','(even(Y), succ(Y, X))).
+
+succ(z, s(z)).
+succ(s(z), s(s(z))).
+succ(s(s(z)), s(s(s(z)))).
+`))
+	assert.NoError(t, vm.Compile(context.Background(), `:-(','(findall(X, odd(X), Xs), result(Xs))).`))
+	assert.Equal(t, List(NewAtom("s").Apply(NewAtom("z")), NewAtom("s").Apply(NewAtom("s").Apply(NewAtom("s").Apply(NewAtom("z"))))), result)
+	for _, tb := range vm.tables {
+		assert.True(t, tb.complete)
+	}
+}
+
+func TestVariantKey(t *testing.T) {
+	x, y := NewVariable(), NewVariable()
+	f := NewAtom("f")
+
+	assert.Equal(t, variantKey(f.Apply(x, y, x), nil), variantKey(f.Apply(y, x, y), nil))
+	assert.NotEqual(t, variantKey(f.Apply(x, y), nil), variantKey(f.Apply(x, x), nil))
+	assert.NotEqual(t, variantKey(f.Apply(Integer(1)), nil), variantKey(f.Apply(NewAtom("1")), nil))
+	assert.Equal(t, variantKey(List(NewAtom("a"), x), nil), variantKey(NewAtom(".").Apply(NewAtom("a"), NewAtom(".").Apply(y, atomEmptyList)), nil))
+
+	env := NewEnv().bind(x, NewAtom("a"))
+	assert.Equal(t, variantKey(f.Apply(NewAtom("a"), y), nil), variantKey(f.Apply(x, y), env))
+}
+
+func TestVM_callTabled_answerSubsumption(t *testing.T) {
+	var vm VM
+	vm.Register3(NewAtom("findall"), FindAll)
+	vm.Register2(NewAtom("sort"), Sort)
+	vm.Register2(NewAtom("is"), Is)
+	vm.Register2(NewAtom("<"), LessThan)
+	vm.Register2(NewAtom("="), Unify)
+	assert.NoError(t, vm.Compile(context.Background(), `
+:-(table(path(_, _, min))).
+:-(path(X, Y, C), ','(path(X, Z, C0), ','(edge(Z, Y, C1), is(C, +(C0, C1))))).
+:-(path(X, Y, C), edge(X, Y, C)).
+
+:-(table(longest(_, po(/(<, 2))))).
+:-(longest(X, C), edge(X, _, C)).
+
+:-(table(widest(_, lattice(/(join, 3))))).
+:-(widest(X, C), edge(X, _, C)).
+:-(join(X, Y, Z), is(Z, max(X, Y))).
+
+:-(table(first(_, first))).
+:-(first(X, C), edge(X, _, C)).
+
+edge(a, b, 1).
+edge(b, c, 2).
+edge(c, a, 1).
+edge(a, c, 4).
+edge(c, d, 1).
+`))
+
+	solutions := func(goal string) Term {
+		t.Helper()
+		var result Term
+		vm.Register1(NewAtom("result"), func(_ *VM, r Term, k Cont, env *Env) *Promise {
+			result = env.Resolve(r)
+			return k(env)
+		})
+		assert.NoError(t, vm.Compile(context.Background(), `:-(','(findall(-(Y, C), `+goal+`, Xs), ','(sort(Xs, Ys), result(Ys)))).`))
+		return result
+	}
+	pair := func(y string, c int) Term {
+		return atomMinus.Apply(NewAtom(y), Integer(c))
+	}
+
+	t.Run("min", func(t *testing.T) {
+		assert.Equal(t, List(pair("a", 4), pair("b", 1), pair("c", 3), pair("d", 4)), solutions(`path(a, Y, C)`))
+		assert.Equal(t, List(), solutions(`','(path(a, c, 4), =(C, 4))`), "the minimum is 3")
+		assert.Equal(t, List(pair("c", 3)), solutions(`','(path(a, c, 3), =(-(Y, C), -(c, 3)))`))
+	})
+
+	t.Run("po", func(t *testing.T) {
+		assert.Equal(t, List(pair("a", 4)), solutions(`','(longest(a, C), =(Y, a))`))
+	})
+
+	t.Run("lattice", func(t *testing.T) {
+		assert.Equal(t, List(pair("a", 4)), solutions(`','(widest(a, C), =(Y, a))`))
+	})
+
+	t.Run("first", func(t *testing.T) {
+		assert.Equal(t, List(pair("a", 1)), solutions(`','(first(a, C), =(Y, a))`))
+	})
+
+	t.Run("invalid modes", func(t *testing.T) {
+		for _, decl := range []string{`p(_, foo)`, `p(min, max)`, `p(po(/(<, 3)))`, `p(lattice(join))`} {
+			assert.Error(t, vm.Compile(context.Background(), `:-(table(`+decl+`)).`), decl)
+		}
+	})
+}
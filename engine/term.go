@@ -20,6 +20,9 @@ type WriteOptions struct {
 	quoted        bool
 	variableNames map[Variable]Atom
 	numberVars    bool
+	portray       bool
+	fullstop      bool
+	nl            bool
 
 	_ops        *operators
 	priority    Integer
@@ -89,13 +92,14 @@ var defaultWriteOptions = WriteOptions{
 }
 
 // CompareAtomic compares a custom atomic term of type T with a Term and returns -1, 0, or 1.
-// The order is Variable < Float < Integer < Atom < custom atomic terms < Compound
-// where different types of custom atomic terms are ordered by the Go-syntax representation of the types.
+// The order is Variable < Number < Atom < custom atomic terms < Compound where numbers are ordered by value, then
+// Float < Integer < Rational, and different types of custom atomic terms are ordered by the Go-syntax representation
+// of the types.
 // It compares values of the same custom atomic term type T by the provided comparison function.
 func CompareAtomic[T Term](a T, t Term, cmp func(T, T) int, env *Env) int {
 	env.charge(MeterCompareStep, 1)
 	switch t := env.Resolve(t).(type) {
-	case Variable, Float, Integer, Atom:
+	case Variable, Float, Integer, Rational, Atom:
 		return 1
 	case T:
 		return cmp(a, t)
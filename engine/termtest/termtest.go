@@ -0,0 +1,271 @@
+// Package termtest provides a generator of random terms and a shrinker for property-based tests of predicates and
+// other functions over terms.
+package termtest
+
+import (
+	"context"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/axone-protocol/prolog/v3/engine"
+)
+
+// Config configures a Generator. The zero value is usable.
+type Config struct {
+	// MaxDepth is the maximum nesting of compounds and lists. The default is 3.
+	MaxDepth int
+	// MaxArity is the maximum number of the arguments of a compound or the elements of a list. The default is 3.
+	MaxArity int
+	// Functors are the names of the compounds. The default is f, g, and h.
+	Functors []engine.Atom
+	// Atoms are the atoms. The default is a, b, c, and [].
+	Atoms []engine.Atom
+	// Variables is the number of the distinct variables a term may contain. The default is 3.
+	// The variables are shared within a term and fresh between terms.
+	Variables int
+	// NoVariables makes the terms ground.
+	NoVariables bool
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxDepth <= 0 {
+		c.MaxDepth = 3
+	}
+	if c.MaxArity <= 0 {
+		c.MaxArity = 3
+	}
+	if len(c.Functors) == 0 {
+		c.Functors = []engine.Atom{engine.NewAtom("f"), engine.NewAtom("g"), engine.NewAtom("h")}
+	}
+	if len(c.Atoms) == 0 {
+		c.Atoms = []engine.Atom{engine.NewAtom("a"), engine.NewAtom("b"), engine.NewAtom("c"), engine.NewAtom("[]")}
+	}
+	if c.Variables <= 0 {
+		c.Variables = 3
+	}
+	return c
+}
+
+// Generator generates random well-formed terms: numbers, atoms, strings, variables, compounds, lists, and dicts.
+type Generator struct {
+	config Config
+	rand   *rand.Rand
+	vars   []engine.Variable
+}
+
+// NewGenerator returns a generator of the terms described by config. The generators with the same seed and config
+// generate the same terms up to the renaming of their variables.
+func NewGenerator(seed uint64, config Config) *Generator {
+	return &Generator{
+		config: config.withDefaults(),
+		rand:   rand.New(rand.NewPCG(seed, seed)),
+	}
+}
+
+// Term returns a random term.
+func (g *Generator) Term() engine.Term {
+	g.vars = g.vars[:0]
+	return g.term(g.config.MaxDepth)
+}
+
+// Number returns a random number: an integer, a float, or a rational between -10 and 10. The floats are multiples of
+// 0.25 so that some of them equal integers or rationals.
+func (g *Generator) Number() engine.Number {
+	switch g.rand.IntN(3) {
+	case 0:
+		return engine.Integer(g.rand.IntN(21) - 10)
+	case 1:
+		f, _ := engine.NewFloatFromString(strconv.FormatFloat(float64(g.rand.IntN(81)-40)/4, 'f', -1, 64))
+		return f
+	default:
+		q, _ := engine.NewRational(engine.Integer(g.rand.IntN(21)-10), engine.Integer(g.rand.IntN(4)+2))
+		return q
+	}
+}
+
+func (g *Generator) term(depth int) engine.Term {
+	kinds := 4
+	if depth > 0 {
+		kinds += 3
+	}
+	switch g.rand.IntN(kinds) {
+	case 0:
+		return g.Number()
+	case 1:
+		return g.config.Atoms[g.rand.IntN(len(g.config.Atoms))]
+	case 2:
+		return engine.String(g.config.Atoms[g.rand.IntN(len(g.config.Atoms))].String())
+	case 3:
+		if g.config.NoVariables {
+			return g.term(0)
+		}
+		return g.variable()
+	case 4:
+		args := make([]engine.Term, 1+g.rand.IntN(g.config.MaxArity))
+		for i := range args {
+			args[i] = g.term(depth - 1)
+		}
+		return g.config.Functors[g.rand.IntN(len(g.config.Functors))].Apply(args...)
+	case 5:
+		elems := make([]engine.Term, g.rand.IntN(g.config.MaxArity+1))
+		for i := range elems {
+			elems[i] = g.term(depth - 1)
+		}
+		return engine.List(elems...)
+	default:
+		return g.dict(depth)
+	}
+}
+
+func (g *Generator) variable() engine.Variable {
+	i := g.rand.IntN(g.config.Variables)
+	for len(g.vars) <= i {
+		g.vars = append(g.vars, engine.NewVariable())
+	}
+	return g.vars[i]
+}
+
+// dict returns a dict whose tag is an atom or a variable and whose keys are distinct atoms.
+func (g *Generator) dict(depth int) engine.Dict {
+	var tag engine.Term = g.config.Atoms[g.rand.IntN(len(g.config.Atoms))]
+	if !g.config.NoVariables && g.rand.IntN(2) == 0 {
+		tag = g.variable()
+	}
+	args := []engine.Term{tag}
+	for _, i := range g.rand.Perm(len(g.config.Atoms))[:g.rand.IntN(min(g.config.MaxArity, len(g.config.Atoms))+1)] {
+		args = append(args, g.config.Atoms[i], g.term(depth-1))
+	}
+	d, _ := engine.NewDict(args)
+	return d
+}
+
+// Shrink returns the terms simpler than t, the simplest first: its subterms, then t with one of its arguments or
+// elements simplified or removed. An integer shrinks towards 0 and a rational to its integer part.
+func Shrink(t engine.Term) []engine.Term {
+	switch t := t.(type) {
+	case engine.Integer:
+		switch {
+		case t == 0:
+			return nil
+		case t/2 == 0:
+			return []engine.Term{engine.Integer(0)}
+		default:
+			return []engine.Term{engine.Integer(0), t / 2}
+		}
+	case engine.Rational:
+		return []engine.Term{t.Num() / t.Den()}
+	case engine.Dict:
+		return shrinkDict(t)
+	case engine.Compound:
+		if elems, ok := list(t); ok {
+			return shrinkList(elems)
+		}
+		args := make([]engine.Term, t.Arity())
+		for i := range args {
+			args[i] = t.Arg(i)
+		}
+		ret := append([]engine.Term{}, args...)
+		for i, a := range args {
+			for _, s := range Shrink(a) {
+				shrunk := append([]engine.Term{}, args...)
+				shrunk[i] = s
+				ret = append(ret, t.Functor().Apply(shrunk...))
+			}
+		}
+		return ret
+	default:
+		return nil
+	}
+}
+
+func shrinkDict(d engine.Dict) []engine.Term {
+	var (
+		ret   []engine.Term
+		pairs []engine.Term
+	)
+	for k, v := range d.All() {
+		ret = append(ret, v)
+		pairs = append(pairs, k, v)
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		removed := append(append([]engine.Term{d.Tag()}, pairs[:i]...), pairs[i+2:]...)
+		s, _ := engine.NewDict(removed)
+		ret = append(ret, s)
+	}
+	for i := 1; i < len(pairs); i += 2 {
+		for _, s := range Shrink(pairs[i]) {
+			shrunk := append([]engine.Term{d.Tag()}, pairs...)
+			shrunk[i+1] = s
+			s, _ := engine.NewDict(shrunk)
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func shrinkList(elems []engine.Term) []engine.Term {
+	ret := append([]engine.Term{}, elems...)
+	for i := range elems {
+		removed := append(append([]engine.Term{}, elems[:i]...), elems[i+1:]...)
+		ret = append(ret, engine.List(removed...))
+	}
+	for i, e := range elems {
+		for _, s := range Shrink(e) {
+			shrunk := append([]engine.Term{}, elems...)
+			shrunk[i] = s
+			ret = append(ret, engine.List(shrunk...))
+		}
+	}
+	return ret
+}
+
+// list returns the elements of t if it's a proper list.
+func list(t engine.Term) ([]engine.Term, bool) {
+	var elems []engine.Term
+	iter := engine.ListIterator{List: t}
+	for iter.Next() {
+		elems = append(elems, iter.Current())
+	}
+	return elems, iter.Err() == nil
+}
+
+// Check checks that prop holds for n terms generated by g. On the first counterexample, it shrinks it as long as prop
+// doesn't hold and fails tb with the simplest counterexample found.
+func Check(tb testing.TB, g *Generator, n int, prop func(engine.Term) bool) {
+	tb.Helper()
+	for range n {
+		t := g.Term()
+		if prop(t) {
+			continue
+		}
+		tb.Fatalf("property doesn't hold for %s", String(shrink(t, prop)))
+	}
+}
+
+// shrink returns the simplest counterexample of prop found from t.
+func shrink(t engine.Term, prop func(engine.Term) bool) engine.Term {
+	for {
+		simpler := false
+		for _, s := range Shrink(t) {
+			if !prop(s) {
+				t, simpler = s, true
+				break
+			}
+		}
+		if !simpler {
+			return t
+		}
+	}
+}
+
+// String returns t in the canonical syntax, e.g. f(a,[1,_G1]).
+func String(t engine.Term) string {
+	var sb strings.Builder
+	_, _ = engine.WriteTerm(&engine.VM{}, engine.NewOutputTextStream(&sb), t, engine.List(
+		engine.NewAtom("quoted").Apply(engine.NewAtom("true")),
+		engine.NewAtom("ignore_ops").Apply(engine.NewAtom("true")),
+	), engine.Success, nil).Force(context.Background())
+	return sb.String()
+}
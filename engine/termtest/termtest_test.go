@@ -0,0 +1,214 @@
+package termtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/axone-protocol/prolog/v3/engine"
+)
+
+func TestGenerator_Term(t *testing.T) {
+	t.Run("deterministic", func(t *testing.T) {
+		a, b := NewGenerator(1, Config{}), NewGenerator(1, Config{NoVariables: true})
+		c := NewGenerator(1, Config{NoVariables: true})
+		for range 100 {
+			_ = a.Term()
+			assert.Equal(t, String(b.Term()), String(c.Term()))
+		}
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		g := NewGenerator(2, Config{MaxDepth: 2, MaxArity: 2, Functors: []engine.Atom{engine.NewAtom("k")}})
+		var depth func(engine.Term) int
+		depth = func(term engine.Term) int {
+			c, ok := term.(engine.Compound)
+			if !ok {
+				return 0
+			}
+			args, ok := list(c)
+			if d, isDict := c.(engine.Dict); isDict {
+				assert.LessOrEqual(t, d.Len(), 2)
+				for _, v := range d.All() {
+					args = append(args, v)
+				}
+			} else if ok {
+				assert.LessOrEqual(t, len(args), 2)
+			} else {
+				assert.Equal(t, engine.NewAtom("k"), c.Functor())
+				assert.LessOrEqual(t, c.Arity(), 2)
+				for i := 0; i < c.Arity(); i++ {
+					args = append(args, c.Arg(i))
+				}
+			}
+			d := 0
+			for _, a := range args {
+				d = max(d, depth(a))
+			}
+			return d + 1
+		}
+		for range 100 {
+			assert.LessOrEqual(t, depth(g.Term()), 2)
+		}
+	})
+
+	t.Run("kinds", func(t *testing.T) {
+		g := NewGenerator(7, Config{})
+		var rationals, dicts int
+		var count func(engine.Term)
+		count = func(term engine.Term) {
+			switch term := term.(type) {
+			case engine.Rational:
+				rationals++
+			case engine.Dict:
+				dicts++
+				for _, v := range term.All() {
+					count(v)
+				}
+			case engine.Compound:
+				for i := 0; i < term.Arity(); i++ {
+					count(term.Arg(i))
+				}
+			}
+		}
+		for range 100 {
+			count(g.Term())
+		}
+		assert.Positive(t, rationals)
+		assert.Positive(t, dicts)
+	})
+
+	t.Run("shared variables", func(t *testing.T) {
+		g := NewGenerator(3, Config{Variables: 2})
+		seen := map[engine.Variable]struct{}{}
+		for range 100 {
+			vs := map[engine.Variable]struct{}{}
+			variables(g.Term(), vs)
+			assert.LessOrEqual(t, len(vs), 2)
+			for v := range vs {
+				_, ok := seen[v]
+				assert.False(t, ok)
+				seen[v] = struct{}{}
+			}
+		}
+		assert.NotEmpty(t, seen)
+	})
+}
+
+func variables(t engine.Term, vs map[engine.Variable]struct{}) {
+	switch t := t.(type) {
+	case engine.Variable:
+		vs[t] = struct{}{}
+	case engine.Compound:
+		for i := 0; i < t.Arity(); i++ {
+			variables(t.Arg(i), vs)
+		}
+	}
+}
+
+func TestShrink(t *testing.T) {
+	f := engine.NewAtom("f")
+	assert.Nil(t, Shrink(engine.Integer(0)))
+	assert.Equal(t, []engine.Term{engine.Integer(0), engine.Integer(5)}, Shrink(engine.Integer(10)))
+	assert.Equal(t, []engine.Term{
+		engine.NewAtom("a"), engine.Integer(1),
+		f.Apply(engine.NewAtom("a"), engine.Integer(0)),
+	}, Shrink(f.Apply(engine.NewAtom("a"), engine.Integer(1))))
+	assert.Equal(t, []engine.Term{
+		engine.Integer(1), engine.NewAtom("b"),
+		engine.List(engine.NewAtom("b")), engine.List(engine.Integer(1)),
+		engine.List(engine.Integer(0), engine.NewAtom("b")),
+	}, Shrink(engine.List(engine.Integer(1), engine.NewAtom("b"))))
+
+	q, err := engine.NewRational(engine.Integer(7), engine.Integer(2))
+	assert.NoError(t, err)
+	assert.Equal(t, []engine.Term{engine.Integer(3)}, Shrink(q))
+
+	a, b := engine.NewAtom("a"), engine.NewAtom("b")
+	d, err := engine.NewDict([]engine.Term{f, a, engine.Integer(1), b, engine.Integer(2)})
+	assert.NoError(t, err)
+	dict := func(args ...engine.Term) engine.Term {
+		d, err := engine.NewDict(append([]engine.Term{f}, args...))
+		assert.NoError(t, err)
+		return d
+	}
+	assert.Equal(t, []engine.Term{
+		engine.Integer(1), engine.Integer(2),
+		dict(b, engine.Integer(2)), dict(a, engine.Integer(1)),
+		dict(a, engine.Integer(0), b, engine.Integer(2)),
+		dict(a, engine.Integer(1), b, engine.Integer(0)), dict(a, engine.Integer(1), b, engine.Integer(1)),
+	}, Shrink(d))
+}
+
+func TestCheck(t *testing.T) {
+	t.Run("shrinks the counterexample", func(t *testing.T) {
+		var tb fakeTB
+		// Fails for any term containing a non-zero integer.
+		Check(&tb, NewGenerator(4, Config{}), 1000, func(t engine.Term) bool {
+			return !hasNonZeroInteger(t)
+		})
+		assert.Regexp(t, `^property doesn't hold for -?1$`, tb.msg)
+	})
+
+	t.Run("serialization round trip", func(t *testing.T) {
+		Check(t, NewGenerator(5, Config{MaxDepth: 4}), 500, func(term engine.Term) bool {
+			b, err := engine.EncodeTerm(term, nil)
+			if err != nil {
+				return false
+			}
+			d, err := engine.DecodeTerm(b)
+			if err != nil {
+				return false
+			}
+			again, err := engine.EncodeTerm(d, nil)
+			return err == nil && string(again) == string(b)
+		})
+	})
+
+	t.Run("standard order", func(t *testing.T) {
+		g := NewGenerator(6, Config{})
+		Check(t, g, 500, func(x engine.Term) bool {
+			y := g.Term()
+			return x.Compare(x, nil) == 0 && sign(x.Compare(y, nil)) == -sign(y.Compare(x, nil))
+		})
+	})
+}
+
+func hasNonZeroInteger(t engine.Term) bool {
+	switch t := t.(type) {
+	case engine.Integer:
+		return t != 0
+	case engine.Compound:
+		for i := 0; i < t.Arity(); i++ {
+			if hasNonZeroInteger(t.Arg(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sign(c int) int {
+	switch {
+	case c < 0:
+		return -1
+	case c > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+type fakeTB struct {
+	testing.TB
+	msg string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Fatalf(format string, args ...any) {
+	if f.msg == "" {
+		f.msg = fmt.Sprintf(format, args...)
+	}
+}
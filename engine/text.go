@@ -1,9 +1,16 @@
 package engine
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"log/slog"
+	"slices"
 	"strings"
 
 	orderedmap "github.com/wk8/go-ordered-map/v2"
@@ -18,40 +25,226 @@ func (e *discontiguousError) Error() string {
 	return fmt.Sprintf("%s is discontiguous", e.pi)
 }
 
+// LoadCycleError is an error that a Prolog text includes or loads itself, directly or indirectly.
+type LoadCycleError struct {
+	// Path is the chain of files from the first occurrence of the file to the repeated one, e.g. [a.pl b.pl a.pl].
+	Path []string
+}
+
+func (e LoadCycleError) Error() string {
+	return fmt.Sprintf("load cycle: %s", strings.Join(e.Path, " -> "))
+}
+
+// LoadConflictError is an error that a Prolog text is loaded again while its content changed since the first load.
+type LoadConflictError struct {
+	File string
+	// Loaded and Current are the SHA-256 digests of the content at the first load and now.
+	Loaded, Current [sha256.Size]byte
+}
+
+func (e LoadConflictError) Error() string {
+	return fmt.Sprintf("%s was loaded with content %x and its content is now %x", e.File, e.Loaded[:4], e.Current[:4])
+}
+
+// SyntaxErrors are the syntax errors of a Prolog text compiled with VM.RecoverSyntaxErrors set, in the order they
+// occur in the text.
+type SyntaxErrors struct {
+	// File is the file of the text, or "" if the text isn't loaded from a file e.g. by VM.Compile.
+	File string
+	Errs []error
+}
+
+func (e SyntaxErrors) Error() string {
+	var sb strings.Builder
+	if e.File != "" {
+		_, _ = fmt.Fprintf(&sb, "%s: ", e.File)
+	}
+	if len(e.Errs) == 1 {
+		sb.WriteString("1 syntax error")
+	} else {
+		_, _ = fmt.Fprintf(&sb, "%d syntax errors", len(e.Errs))
+	}
+	for _, err := range e.Errs {
+		_, _ = fmt.Fprintf(&sb, "\n%s", err)
+	}
+	return sb.String()
+}
+
+// Unwrap returns the syntax errors.
+func (e SyntaxErrors) Unwrap() []error {
+	return e.Errs
+}
+
+// CompileWarningKind is the kind of a CompileWarning.
+type CompileWarningKind int
+
+const (
+	// CompileWarningSingletonVariables is a clause with named variables which occur only once, e.g. foo(X).
+	CompileWarningSingletonVariables CompileWarningKind = iota
+	// CompileWarningDiscontiguous is a procedure of clauses which aren't consecutive without discontiguous/1.
+	CompileWarningDiscontiguous
+	// CompileWarningRedefinition is a procedure which replaces a static procedure already defined in the DB.
+	CompileWarningRedefinition
+)
+
+func (k CompileWarningKind) String() string {
+	return [...]string{
+		CompileWarningSingletonVariables: "singleton variables",
+		CompileWarningDiscontiguous:      "discontiguous procedure",
+		CompileWarningRedefinition:       "redefined procedure",
+	}[k]
+}
+
+// CompileWarning is a sloppy construct of a Prolog text reported to VM.CompileWarningHandler. It's also an error so
+// that the handler can return it to fail the load.
+type CompileWarning struct {
+	Kind CompileWarningKind
+	// File is the file of the text, or "" if the text isn't loaded from a file e.g. by VM.Compile.
+	File string
+	// Line is the line of the clause, or of the first clause of the procedure, the warning is about.
+	Line int
+	// Procedure is the procedure indicator of the clause or the procedure, e.g. foo/1.
+	Procedure string
+	// Variables are the names of the singleton variables.
+	Variables []string
+}
+
+func (w CompileWarning) Error() string {
+	var sb strings.Builder
+	if w.File != "" {
+		_, _ = fmt.Fprintf(&sb, "%s:%d: ", w.File, w.Line)
+	} else {
+		_, _ = fmt.Fprintf(&sb, "line %d: ", w.Line)
+	}
+	sb.WriteString(w.Kind.String())
+	if len(w.Variables) > 0 {
+		_, _ = fmt.Fprintf(&sb, " %s", strings.Join(w.Variables, ", "))
+	}
+	_, _ = fmt.Fprintf(&sb, " in %s", w.Procedure)
+	return sb.String()
+}
+
+// LoadStatus is the progress of the load of a Prolog text reported to VM.LoadProgress.
+type LoadStatus struct {
+	// File is the file of the text, or "" if the text isn't loaded from a file e.g. by VM.Compile.
+	File string
+	// Clauses is the number of clauses compiled so far.
+	Clauses int
+	// Bytes is the number of bytes of the text read so far.
+	Bytes int
+}
+
+// loadProgressInterval is the number of clauses between the calls of VM.LoadProgress.
+const loadProgressInterval = 100
+
 // Compile compiles the Prolog text and updates the DB accordingly.
+// The clauses and the directives of the text are expanded by the term_expansion/2 and goal_expansion/2 already in the
+// DB. Since the clauses of a text are added to the DB once it's compiled, the ones the text defines itself only expand
+// the texts compiled after it.
 func (vm *VM) Compile(ctx context.Context, s string, args ...interface{}) error {
+	_, err := vm.load(ctx, s, args...)
+	return vm.AttachGoalHistory(err)
+}
+
+// load compiles the Prolog text and returns the module it defines, if any.
+func (vm *VM) load(ctx context.Context, s string, args ...interface{}) (Atom, error) {
 	var t text
-	if err := vm.compile(ctx, &t, s, args...); err != nil {
-		return err
+	return vm.loadText(ctx, &t, func(t *text) error {
+		return vm.compile(ctx, t, s, args...)
+	})
+}
+
+// loadText fills t by compile, updates the DB accordingly, and returns the module it defines, if any.
+func (vm *VM) loadText(ctx context.Context, t *text, compile func(t *text) error) (Atom, error) {
+	if err := vm.checkFrozen(permissionTypeStaticProcedure, atomUser, nil); err != nil {
+		return "", err
+	}
+
+	if err := compile(t); err != nil {
+		t.restoreOperators(vm)
+		return "", err
 	}
 
 	if err := t.flush(); err != nil {
-		return err
+		t.restoreOperators(vm)
+		return "", err
+	}
+
+	t.restoreOperators(vm)
+
+	if t.mustBeModule && t.module == "" {
+		return "", errNotModule
 	}
 
+	get, set, store := vm.getProcedure, vm.setProcedure, vm.store
+	if m, ok := vm.getModule(t.module); ok {
+		get, set = m.procedures.Get, m.procedures.Set
+		store = func(procedureIndicator, *userDefined, clauseChange) error {
+			return nil // The procedures of the modules are not stored.
+		}
+	}
+	if err := vm.warnRedefinitions(t, get); err != nil {
+		return "", err
+	}
 	for c := t.clauses.Oldest(); c != nil; c = c.Next() {
-		p, _ := vm.getProcedure(c.Key)
+		p, _ := get(c.Key)
 		if existing, ok := p.(*userDefined); ok && existing.multifile && c.Value.multifile {
-			existing.clauses = append(existing.clauses, c.Value.clauses...)
+			if err := store(c.Key, existing, clauseChange{added: c.Value.clauses}); err != nil {
+				return "", err
+			}
+			existing.setClauses(append(existing.clauses, c.Value.clauses...))
 			continue
 		}
 
-		vm.setProcedure(c.Key, c.Value)
+		if err := store(c.Key, c.Value, clauseChange{added: c.Value.clauses, reset: true}); err != nil {
+			return "", err
+		}
+		set(c.Key, c.Value)
 	}
 
 	for _, g := range t.goals {
-		ok, err := Call(vm, g, Success, nil).Force(ctx)
+		ok, err := Call(vm, t.qualify(g), func(env *Env) *Promise {
+			vm.setDefaultFlags(env)
+			return Bool(true)
+		}, nil).Force(ctx)
 		if err != nil {
-			return err
+			return "", err
 		}
 		if !ok {
 			var sb strings.Builder
 			s := NewOutputTextStream(&sb)
 			_, _ = WriteTerm(vm, s, g, List(atomQuoted.Apply(atomTrue)), Success, nil).Force(ctx)
-			return fmt.Errorf("failed initialization goal: %s", sb.String())
+			return "", fmt.Errorf("failed initialization goal: %s", sb.String())
 		}
 	}
 
+	return t.module, nil
+}
+
+// warnRedefinitions reports the procedures of t which replace the static procedures with clauses got by get.
+func (vm *VM) warnRedefinitions(t *text, get func(procedureIndicator) (procedure, bool)) error {
+	if vm.CompileWarningHandler == nil {
+		return nil
+	}
+	for c := t.clauses.Oldest(); c != nil; c = c.Next() {
+		p, _ := get(c.Key)
+		existing, ok := p.(*userDefined)
+		if !ok || existing.dynamic || len(existing.clauses) == 0 || len(c.Value.clauses) == 0 {
+			continue
+		}
+		if existing.multifile && c.Value.multifile {
+			continue
+		}
+		first := c.Value.clauses[0]
+		if err := vm.CompileWarningHandler(CompileWarning{
+			Kind:      CompileWarningRedefinition,
+			File:      first.file,
+			Line:      first.line,
+			Procedure: c.Key.String(),
+		}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -68,7 +261,7 @@ func Consult(vm *VM, files Term, k Cont, env *Env) *Promise {
 
 	return Delay(func(ctx context.Context) *Promise {
 		for _, filename := range filenames {
-			if err := vm.ensureLoaded(ctx, filename, env); err != nil {
+			if _, err := vm.ensureLoaded(ctx, filename, env); err != nil {
 				return Error(err)
 			}
 		}
@@ -77,25 +270,181 @@ func Consult(vm *VM, files Term, k Cont, env *Env) *Promise {
 	})
 }
 
+// SourceFile enumerates the files loaded by consult/1 or ensure_loaded/1 in the order they were loaded.
+func SourceFile(vm *VM, file Term, k Cont, env *Env) *Promise {
+	switch env.Resolve(file).(type) {
+	case Variable, Atom:
+		break
+	default:
+		return Error(typeError(validTypeAtom, file, env))
+	}
+
+	var ks []func(context.Context) *Promise
+	for _, f := range vm.LoadedSources() {
+		ks = append(ks, func(context.Context) *Promise {
+			return Unify(vm, file, NewAtom(f), k, env)
+		})
+	}
+	return Delay(ks...)
+}
+
+// SourceFile2 succeeds iff the procedure of the head pred has clauses loaded from file. It enumerates the pairs of a
+// most general head and a file if they're not instantiated.
+func SourceFile2(vm *VM, pred, file Term, k Cont, env *Env) *Promise {
+	switch env.Resolve(pred).(type) {
+	case Variable, Atom, Compound:
+		break
+	default:
+		return Error(typeError(validTypeCallable, pred, env))
+	}
+	switch env.Resolve(file).(type) {
+	case Variable, Atom:
+		break
+	default:
+		return Error(typeError(validTypeAtom, file, env))
+	}
+
+	if vm.procedures == nil {
+		return Bool(false)
+	}
+	var ks []func(context.Context) *Promise
+	for e := vm.procedures.Oldest(); e != nil; e = e.Next() {
+		u, ok := e.Value.(*userDefined)
+		if !ok {
+			continue
+		}
+		pi := e.Key
+		var files []string
+		for _, c := range u.clauses {
+			if c.file != "" && !slices.Contains(files, c.file) {
+				files = append(files, c.file)
+			}
+		}
+		for _, f := range files {
+			ks = append(ks, func(context.Context) *Promise {
+				args := make([]Term, pi.arity)
+				for i := range args {
+					args[i] = NewVariable()
+				}
+				return Unify(vm, tuple(pred, file), tuple(pi.name.Apply(args...), NewAtom(f)), k, env)
+			})
+		}
+	}
+	return Delay(ks...)
+}
+
+// LoadedFileHash succeeds iff file is loaded and hash is the hexadecimal SHA-256 digest of its content as loaded.
+func LoadedFileHash(vm *VM, file, hash Term, k Cont, env *Env) *Promise {
+	switch env.Resolve(file).(type) {
+	case Variable, Atom:
+		break
+	default:
+		return Error(typeError(validTypeAtom, file, env))
+	}
+
+	if vm.loaded == nil {
+		return Bool(false)
+	}
+	var ks []func(context.Context) *Promise
+	for e := vm.loaded.Oldest(); e != nil; e = e.Next() {
+		f, sum := NewAtom(e.Key), e.Value
+		ks = append(ks, func(context.Context) *Promise {
+			return Unify(vm, tuple(file, hash), tuple(f, NewAtom(hex.EncodeToString(sum[:]))), k, env)
+		})
+	}
+	return Delay(ks...)
+}
+
+// CompileReader compiles the Prolog text read from r as the file name and updates the DB accordingly. Unlike Compile,
+// it reads the text as it compiles it, so a large text isn't held in memory at once. The clauses are recorded as
+// loaded from name at their lines, and name is recorded as loaded once the text is compiled.
+func (vm *VM) CompileReader(ctx context.Context, name string, r io.Reader) error {
+	if err := vm.checkCycle(name); err != nil {
+		return err
+	}
+	vm.loading = append(vm.loading, name)
+	defer func() {
+		vm.loading = vm.loading[:len(vm.loading)-1]
+	}()
+
+	h := sha256.New()
+	var t text
+	m, err := vm.loadText(ctx, &t, func(t *text) error {
+		return vm.compileReader(ctx, t, io.TeeReader(r, h))
+	})
+	if err != nil {
+		return vm.AttachGoalHistory(err)
+	}
+
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	if vm.loaded == nil {
+		vm.loaded = orderedmap.New[string, [sha256.Size]byte]()
+	}
+	vm.loaded.Set(name, sum)
+	vm.setModuleFile(m, name)
+	return nil
+}
+
 func (vm *VM) compile(ctx context.Context, text *text, s string, args ...interface{}) error {
+	return vm.compileReader(ctx, text, strings.NewReader(s), args...)
+}
+
+func (vm *VM) compileReader(ctx context.Context, text *text, r io.Reader, args ...interface{}) error {
 	if text.clauses == nil {
 		text.clauses = orderedmap.New[procedureIndicator, *userDefined]()
 	}
 
-	s = ignoreShebangLine(s)
-	p := NewParser(vm, strings.NewReader(s))
+	var file string
+	if n := len(vm.loading); n > 0 {
+		file = vm.loading[n-1]
+	}
+
+	text.warn = vm.CompileWarningHandler
+
+	lr := lineReader{r: bufio.NewReader(r), line: 1}
+	lr.skipShebangLine()
+	p := NewParser(vm, &lr)
 	if err := p.SetPlaceholder(NewAtom("?"), args...); err != nil {
 		return err
 	}
 
+	var (
+		n          int     // the number of clauses compiled so far.
+		syntaxErrs []error // the syntax errors skipped so far.
+	)
+	progress := func() error {
+		if vm.LoadProgress == nil {
+			return nil
+		}
+		return vm.LoadProgress(LoadStatus{File: file, Clauses: n, Bytes: lr.n})
+	}
+
 	for p.More() {
-		p.Vars = p.Vars[:]
+		line := lr.line // the line of the first token of the clause.
+		p.Vars = p.Vars[:0]
 		t, err := p.Term()
+		if lr.err != nil {
+			return lr.err // The text is truncated.
+		}
 		if err != nil {
-			return err
+			if !vm.RecoverSyntaxErrors {
+				return err
+			}
+			syntaxErrs = append(syntaxErrs, err)
+			p.SkipToEnd()
+			continue
 		}
 
-		et, err := expand(vm, t, nil)
+		text.terms++
+
+		if text.program != nil && vm.expanding() {
+			// The expansion by the user-defined term_expansion/2 or goal_expansion/2 may depend on anything, so the text
+			// can't be replayed.
+			text.program.volatile = true
+		}
+
+		et, err := expand(ctx, vm, t, nil)
 		if err != nil {
 			return err
 		}
@@ -106,7 +455,13 @@ func (vm *VM) compile(ctx context.Context, text *text, s string, args ...interfa
 		}
 		switch pi {
 		case procedureIndicator{name: atomIf, arity: 1}: // Directive
-			if err := vm.directive(ctx, text, arg(0)); err != nil {
+			d, env, err := expandGoal(ctx, vm, arg(0), nil)
+			if err != nil {
+				return err
+			}
+			d = env.simplify(d)
+			text.program.record(programStep{directive: d})
+			if err := vm.directive(ctx, text, d); err != nil {
 				return err
 			}
 			continue
@@ -115,30 +470,71 @@ func (vm *VM) compile(ctx context.Context, text *text, s string, args ...interfa
 			if err != nil {
 				return err
 			}
+			body, env, err := expandGoal(ctx, vm, arg(1), nil)
+			if err != nil {
+				return err
+			}
+			et = env.simplify(atomIf.Apply(arg(0), body)) // The head and the body share the variables bound by the expansion.
 			fallthrough
 		default:
-			if len(text.buf) > 0 && pi != text.buf[0].pi {
-				if err := text.flush(); err != nil {
-					return err
+			if pi == (procedureIndicator{name: atomColon, arity: 2}) {
+				if c, upi, ok := text.unqualify(et); ok {
+					et, pi = c, upi
 				}
 			}
+			if pi == (procedureIndicator{name: atomColon, arity: 2}) {
+				text.program.record(programStep{moduleClause: et})
+				if err := vm.compileModuleClause(et); err != nil {
+					return err
+				}
+			} else {
+				cs, err := compile(text.qualifyClause(et), nil)
+				if err != nil {
+					return err
+				}
+				if err := vm.verify(cs); err != nil {
+					return err
+				}
+				for _, c := range cs {
+					c.file, c.line = file, line
+				}
+				if err := text.warnSingletons(p.Vars, pi, file, line); err != nil {
+					return err
+				}
 
-			cs, err := compile(et, nil)
-			if err != nil {
-				return err
+				text.program.record(programStep{pi: pi, clauses: copyClauses(cs)})
+				if err := text.add(pi, cs); err != nil {
+					return err
+				}
 			}
 
-			text.buf = append(text.buf, cs...)
+			n++
+			if n%loadProgressInterval == 0 {
+				if err := progress(); err != nil {
+					return err
+				}
+			}
 		}
 	}
-	return nil
+	if lr.err != nil {
+		return lr.err
+	}
+	if len(syntaxErrs) > 0 {
+		return SyntaxErrors{File: file, Errs: syntaxErrs}
+	}
+	return progress()
 }
 
-func (vm *VM) directive(ctx context.Context, text *text, d Term) error {
+func (vm *VM) directive(ctx context.Context, text *text, d Term) (err error) {
 	if err := text.flush(); err != nil {
 		return err
 	}
 
+	start := vm.Now()
+	defer func() {
+		vm.log(ctx, slog.LevelDebug, "directive executed", vm.LogTerm("directive", d, nil), slog.Duration("duration", vm.Now().Sub(start)), errorAttr(err))
+	}()
+
 	switch pi, arg, _ := piArg(d, nil); pi {
 	case procedureIndicator{name: atomDynamic, arity: 1}:
 		return text.forEachUserDefined(arg(0), func(u *userDefined) {
@@ -153,20 +549,57 @@ func (vm *VM) directive(ctx context.Context, text *text, d Term) error {
 		return text.forEachUserDefined(arg(0), func(u *userDefined) {
 			u.discontiguous = true
 		})
+	case procedureIndicator{name: atomTable, arity: 1}:
+		return text.declareTables(arg(0))
+	case procedureIndicator{name: atomIndex, arity: 1}:
+		return text.declareIndexes(arg(0))
 	case procedureIndicator{name: atomInitialization, arity: 1}:
 		text.goals = append(text.goals, arg(0))
 		return nil
 	case procedureIndicator{name: atomInclude, arity: 1}:
-		_, b, err := vm.open(arg(0), nil)
+		f, b, err := vm.open(ctx, arg(0), nil)
 		if err != nil {
 			return err
 		}
+		if err := vm.checkCycle(f); err != nil {
+			return err
+		}
+		vm.loading = append(vm.loading, f)
+		defer func() {
+			vm.loading = vm.loading[:len(vm.loading)-1]
+		}()
 
+		// The included text is compiled again when the directive is replayed since the file may have changed.
+		program := text.program
+		text.program = nil
+		defer func() {
+			text.program = program
+		}()
 		return vm.compile(ctx, text, string(b))
 	case procedureIndicator{name: atomEnsureLoaded, arity: 1}:
-		return vm.ensureLoaded(ctx, arg(0), nil)
+		_, err := vm.ensureLoaded(ctx, arg(0), nil)
+		return err
+	case procedureIndicator{name: atomModule, arity: 2}:
+		return vm.declareModule(ctx, text, arg(0), arg(1))
+	case procedureIndicator{name: atomUseModule, arity: 1}:
+		return vm.useModule(ctx, text.contextModule(), arg(0), nil, nil)
+	case procedureIndicator{name: atomUseModule, arity: 2}:
+		return vm.useModule(ctx, text.contextModule(), arg(0), arg(1), nil)
 	default:
-		ok, err := Call(vm, d, Success, nil).Force(ctx)
+		if vm.OnDirective != nil {
+			handled, err := vm.OnDirective(d, nil)
+			if err != nil {
+				return err
+			}
+			if handled {
+				return nil
+			}
+		}
+
+		ok, err := Call(vm, text.qualify(d), func(env *Env) *Promise {
+			vm.setDefaultFlags(env)
+			return Bool(true)
+		}, nil).Force(ctx)
 		if err != nil {
 			return err
 		}
@@ -180,50 +613,261 @@ func (vm *VM) directive(ctx context.Context, text *text, d Term) error {
 	}
 }
 
-func (vm *VM) ensureLoaded(ctx context.Context, file Term, env *Env) error {
-	f, b, err := vm.open(file, env)
+// LoadFiles loads the files with the options:
+//   - if(true), the default, loads the files even if they're already loaded, replacing the clauses they defined,
+//   - if(changed) loads the files unless they're already loaded with the same content,
+//   - if(not_loaded) loads the files unless they're already loaded,
+//   - must_be_module(true) requires the files to define modules, must_be_module(false), the default, doesn't,
+//   - imports(all), the default, imports the procedures exported by the modules the files define into user, and
+//     imports(List) imports only the ones in List.
+func LoadFiles(vm *VM, files, options Term, k Cont, env *Env) *Promise {
+	var filenames []Term
+	iter := ListIterator{List: files, Env: env}
+	for iter.Next() {
+		filenames = append(filenames, iter.Current())
+	}
+	if err := iter.Err(); err != nil {
+		filenames = []Term{files}
+	}
+
+	var (
+		cond         = loadAlways
+		mustBeModule bool
+		imports      Term
+	)
+	iter = ListIterator{List: options, Env: env}
+	for iter.Next() {
+		switch o := env.Resolve(iter.Current()).(type) {
+		case Variable:
+			return Error(InstantiationError(env))
+		case Compound:
+			if o.Arity() != 1 {
+				return Error(domainError(validDomainLoadOption, o, env))
+			}
+			switch v := env.Resolve(o.Arg(0)); o.Functor() {
+			case atomIfOption:
+				switch v {
+				case atomTrue:
+					cond = loadAlways
+				case atomChanged:
+					cond = loadIfChanged
+				case atomNotLoaded:
+					cond = loadIfNotLoaded
+				default:
+					return Error(domainError(validDomainLoadOption, o, env))
+				}
+			case atomMustBeModule:
+				switch v {
+				case atomTrue:
+					mustBeModule = true
+				case atomFalse:
+					mustBeModule = false
+				default:
+					return Error(domainError(validDomainLoadOption, o, env))
+				}
+			case atomImports:
+				switch v {
+				case atomAll:
+					imports = nil
+				default:
+					imports = v
+				}
+			default:
+				return Error(domainError(validDomainLoadOption, o, env))
+			}
+		default:
+			return Error(domainError(validDomainLoadOption, o, env))
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return Error(err)
+	}
+
+	return Delay(func(ctx context.Context) *Promise {
+		for _, filename := range filenames {
+			f, err := vm.loadFile(ctx, filename, cond, mustBeModule, env)
+			if err != nil {
+				return Error(err)
+			}
+			if _, m := vm.moduleOfFile(f); m == nil {
+				if mustBeModule { // It was loaded before.
+					return Error(domainError(validDomainModuleFile, filename, env))
+				}
+				continue
+			}
+			if err := vm.importModule(ctx, atomUser, f, filename, imports, env); err != nil {
+				return Error(err)
+			}
+		}
+		return k(env)
+	})
+}
+
+// errNotModule is the error of a text required to define a module which doesn't.
+var errNotModule = errors.New("not a module file")
+
+// loadCondition is the condition on which a file already loaded is loaded again.
+type loadCondition uint8
+
+const (
+	loadIfConsistent loadCondition = iota // never, and it's an error if its content changed e.g. ensure_loaded/1.
+	loadAlways                            // always.
+	loadIfChanged                         // if its content changed.
+	loadIfNotLoaded                       // never.
+)
+
+func (vm *VM) ensureLoaded(ctx context.Context, file Term, env *Env) (string, error) {
+	return vm.loadFile(ctx, file, loadIfConsistent, false, env)
+}
+
+// loadFile loads file unless it's already loaded and cond says otherwise. A file loaded again replaces the clauses it
+// defined the previous time, and the imports of the procedures its module doesn't export anymore are removed. If mustBeModule, a file which doesn't define a module raises a domain error before its
+// clauses are added to the DB.
+func (vm *VM) loadFile(ctx context.Context, file Term, cond loadCondition, mustBeModule bool, env *Env) (string, error) {
+	f, b, err := vm.open(ctx, file, env)
 	if err != nil {
-		return err
+		return "", err
 	}
 
+	if err := vm.checkCycle(f); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
 	if vm.loaded == nil {
-		vm.loaded = orderedmap.New[string, struct{}]()
+		vm.loaded = orderedmap.New[string, [sha256.Size]byte]()
 	}
-	if _, ok := vm.loaded.Get(f); ok {
-		return nil
+	loaded, reload := vm.loaded.Get(f)
+	if reload {
+		switch {
+		case cond == loadIfNotLoaded, cond != loadAlways && loaded == sum:
+			return f, nil
+		case cond == loadIfConsistent:
+			return "", LoadConflictError{File: f, Loaded: loaded, Current: sum}
+		}
+	}
+	if err := vm.checkFrozen(permissionTypeSourceSink, file, env); err != nil {
+		return "", err
 	}
+	var prev Atom // the module the file defined the previous time, if any.
+	if reload {
+		prev, _ = vm.moduleOfFile(f)
+		if err := vm.unload(f); err != nil {
+			return "", err
+		}
+	}
+
+	vm.loaded.Set(f, sum)
+	vm.loading = append(vm.loading, f)
+	defer func() {
+		vm.loading = vm.loading[:len(vm.loading)-1]
+	}()
 
-	// It's too early to say it's fully loaded. Yet this avoids recursive load of the same file.
-	vm.loaded.Set(f, struct{}{})
+	ctx, span := vm.StartSpan(ctx, SpanConsult, slog.String(AttrFile, f))
+	defer span.End()
 
-	if err := vm.Compile(ctx, string(b)); err != nil {
+	vm.log(ctx, slog.LevelInfo, "load started", slog.String("file", f))
+	start := vm.Now()
+	t := text{mustBeModule: mustBeModule}
+	m, err := vm.loadText(ctx, &t, func(t *text) error {
+		return vm.compile(ctx, t, string(b))
+	})
+	vm.log(ctx, slog.LevelInfo, "load finished", slog.String("file", f), slog.Duration("duration", vm.Now().Sub(start)), errorAttr(err))
+	if errors.Is(err, errNotModule) {
+		err = domainError(validDomainModuleFile, file, env)
+	}
+	if err != nil {
+		span.RecordError(err)
 		vm.loaded.Delete(f) // It wasn't fully loaded after all.
-		return err
+		return "", err
 	}
 
-	return nil
+	vm.setModuleFile(m, f)
+	if prev != "" {
+		vm.dropImports(prev, f)
+	}
+
+	return f, nil
 }
 
-func (vm *VM) open(file Term, env *Env) (string, []byte, error) {
-	switch f := env.Resolve(file).(type) {
-	case Variable:
-		return "", nil, InstantiationError(env)
-	case Atom:
-		if vm.FS == nil {
-			return "", nil, permissionError(operationOpen, permissionTypeSourceSink, file, env)
+// unload retracts the clauses loaded from the file f. The procedures left without clauses are removed unless they're
+// dynamic.
+func (vm *VM) unload(f string) error {
+	if vm.procedures == nil {
+		return nil
+	}
+	var empty []procedureIndicator
+	for e := vm.procedures.Oldest(); e != nil; e = e.Next() {
+		u, ok := e.Value.(*userDefined)
+		if !ok {
+			continue
 		}
-		s := f.String()
-		for _, f := range []string{s, s + ".pl"} {
-			b, err := fs.ReadFile(vm.FS, f)
-			if err != nil {
+		var (
+			retired clauses
+			live    int
+		)
+		for _, c := range u.clauses {
+			switch {
+			case c.erased != 0:
 				continue
+			case c.file == f:
+				retired = append(retired, c)
+			default:
+				live++
 			}
+		}
+		if len(retired) == 0 {
+			continue
+		}
+		if err := vm.store(e.Key, u, clauseChange{removed: retired}); err != nil {
+			return err
+		}
+		for _, c := range retired {
+			vm.retire(u, c)
+		}
+		if live == 0 && !u.dynamic {
+			empty = append(empty, e.Key)
+		}
+	}
+	for _, pi := range empty {
+		vm.procedures.Delete(pi)
+	}
+	return nil
+}
 
-			return f, b, nil
+// checkCycle returns LoadCycleError if the file f is being loaded or included.
+func (vm *VM) checkCycle(f string) error {
+	for i, l := range vm.loading {
+		if l == f {
+			path := append(append([]string(nil), vm.loading[i:]...), f)
+			return LoadCycleError{Path: path}
 		}
+	}
+	return nil
+}
+
+// open resolves file to the name and the content of a Prolog text with Loader, or with FS if Loader is nil.
+func (vm *VM) open(ctx context.Context, file Term, env *Env) (string, []byte, error) {
+	if _, ok := env.Resolve(file).(Variable); ok {
+		return "", nil, InstantiationError(env)
+	}
+
+	var l Loader = FSLoader{FS: vm.FS}
+	if vm.Loader != nil {
+		l = vm.Loader
+	}
+	name, b, err := l.Load(ctx, file, env)
+	switch {
+	case err == nil:
+		return name, b, nil
+	case errors.Is(err, ErrUnsupportedSource):
+		return "", nil, typeError(validTypeAtom, file, env)
+	case errors.Is(err, fs.ErrNotExist):
 		return "", nil, existenceError(objectTypeSourceSink, file, env)
+	case errors.Is(err, fs.ErrPermission):
+		return "", nil, permissionError(operationOpen, permissionTypeSourceSink, file, env)
 	default:
-		return "", nil, typeError(validTypeAtom, file, env)
+		return "", nil, err
 	}
 }
 
@@ -231,6 +875,98 @@ type text struct {
 	buf     clauses
 	clauses *orderedmap.OrderedMap[procedureIndicator, *userDefined]
 	goals   []Term
+
+	// program records the compilation of the text if it's not nil.
+	program *Program
+
+	// Module defined by the text, if any, and the operators to restore once the text is compiled.
+	module Atom
+	ops    *operators
+
+	// terms is the number of terms of the text read so far, including the current one.
+	terms int
+
+	// warn reports the sloppy constructs of the text if it's not nil.
+	warn func(w CompileWarning) error
+
+	// mustBeModule refuses the text before its clauses are added to the DB unless it defines a module.
+	mustBeModule bool
+}
+
+// warnSingletons reports the named variables of the clause of pi which occur only once. The variables starting with
+// _ are meant to be so.
+func (t *text) warnSingletons(vars []ParsedVariable, pi procedureIndicator, file string, line int) error {
+	if t.warn == nil {
+		return nil
+	}
+	var names []string
+	for _, v := range vars {
+		if n := v.Name.String(); v.Count == 1 && !strings.HasPrefix(n, "_") {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return t.warn(CompileWarning{
+		Kind:      CompileWarningSingletonVariables,
+		File:      file,
+		Line:      line,
+		Procedure: pi.String(),
+		Variables: names,
+	})
+}
+
+func (t *text) contextModule() Atom {
+	if t.module == "" {
+		return atomUser
+	}
+	return t.module
+}
+
+// qualify returns goal which runs in the module defined by the text, if any.
+func (t *text) qualify(goal Term) Term {
+	if t.module == "" {
+		return goal
+	}
+	return atomColon.Apply(t.module, goal)
+}
+
+func (t *text) qualifyClause(clause Term) Term {
+	if t.module == "" {
+		return clause
+	}
+	return qualifyClause(t.module, clause, nil)
+}
+
+// unqualify returns the clause M:Head or M:Head :- Body as Head or Head :- Body along with the procedure indicator of
+// Head if M is the module the text defines its clauses in, so that they're added along with the other clauses of the
+// text instead of being replaced by them.
+func (t *text) unqualify(clause Term) (Term, procedureIndicator, bool) {
+	head, body := clause, Term(nil)
+	if c, ok := clause.(Compound); ok && c.Functor() == atomIf && c.Arity() == 2 {
+		head, body = c.Arg(0), c.Arg(1)
+	}
+	q := head.(Compound)
+	if m, ok := q.Arg(0).(Atom); !ok || m != t.contextModule() {
+		return clause, procedureIndicator{}, false
+	}
+	pi, _, err := piArg(q.Arg(1), nil)
+	if err != nil {
+		return clause, procedureIndicator{}, false
+	}
+	if body != nil {
+		return atomIf.Apply(q.Arg(1), body), pi, true
+	}
+	return q.Arg(1), pi, true
+}
+
+func (t *text) restoreOperators(vm *VM) {
+	if t.ops == nil {
+		return
+	}
+	vm.getOperators().OrderedMap = t.ops.OrderedMap
+	t.ops = nil
 }
 
 func (t *text) forEachUserDefined(pi Term, f func(u *userDefined)) error {
@@ -271,6 +1007,64 @@ func (t *text) forEachUserDefined(pi Term, f func(u *userDefined)) error {
 	return iter.Err()
 }
 
+// declareIndexes adds hash indexes on combinations of arguments to procedures.
+// Each declaration is a head whose arguments are 1 for the indexed arguments and 0 for the others, e.g. p(1,0,1).
+func (t *text) declareIndexes(decls Term) error {
+	iter := anyIterator{Any: decls}
+	for iter.Next() {
+		var head Compound
+		switch h := iter.Current().(type) {
+		case Variable:
+			return InstantiationError(nil)
+		case Compound:
+			head = h
+		default:
+			return typeError(validTypeCompound, h, nil)
+		}
+
+		var args []int
+		for i := 0; i < head.Arity(); i++ {
+			switch a := head.Arg(i).(type) {
+			case Variable:
+				return InstantiationError(nil)
+			case Integer:
+				if a < 0 {
+					return domainError(validDomainNotLessThanZero, a, nil)
+				}
+				if a > 0 {
+					args = append(args, i)
+				}
+			default:
+				return typeError(validTypeInteger, a, nil)
+			}
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		pi := procedureIndicator{name: head.Functor(), arity: Integer(head.Arity())}
+		u, ok := t.getClause(pi)
+		if !ok {
+			u = &userDefined{}
+			t.setClause(pi, u)
+		}
+		u.argIndexes = append(u.argIndexes, &argIndex{args: args})
+		u.reindex()
+	}
+	return iter.Err()
+}
+
+// add adds the clauses cs of the procedure pi to the text.
+func (t *text) add(pi procedureIndicator, cs clauses) error {
+	if len(t.buf) > 0 && pi != t.buf[0].pi {
+		if err := t.flush(); err != nil {
+			return err
+		}
+	}
+	t.buf = append(t.buf, cs...)
+	return nil
+}
+
 func (t *text) flush() error {
 	if len(t.buf) == 0 {
 		return nil
@@ -283,9 +1077,19 @@ func (t *text) flush() error {
 		t.setClause(pi, u)
 	}
 	if len(u.clauses) > 0 && !u.discontiguous {
-		return &discontiguousError{pi: pi}
+		if t.warn == nil {
+			return &discontiguousError{pi: pi}
+		}
+		if err := t.warn(CompileWarning{
+			Kind:      CompileWarningDiscontiguous,
+			File:      t.buf[0].file,
+			Line:      t.buf[0].line,
+			Procedure: pi.String(),
+		}); err != nil {
+			return err
+		}
 	}
-	u.clauses = append(u.clauses, t.buf...)
+	u.setClauses(append(u.clauses, t.buf...))
 	t.buf = t.buf[:0]
 	return nil
 }
@@ -304,13 +1108,44 @@ func (t *text) setClause(key procedureIndicator, value *userDefined) (*userDefin
 	return t.clauses.Set(key, value)
 }
 
-func ignoreShebangLine(query string) string {
-	if !strings.HasPrefix(query, "#!") {
-		return query
+// lineReader counts the lines of the runes read so far.
+type lineReader struct {
+	r    *bufio.Reader
+	line int   // the line of the last rune read.
+	n    int   // the number of bytes read.
+	nl   bool  // whether the last rune read is a newline.
+	err  error // the error other than io.EOF which stopped the reads, if any.
+}
+
+func (l *lineReader) ReadRune() (rune, int, error) {
+	r, n, err := l.r.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			l.err = err
+		}
+		return r, n, err
+	}
+	if l.nl {
+		l.line++
+	}
+	l.n += n
+	l.nl = r == '\n'
+	return r, n, err
+}
+
+// skipShebangLine skips the first line if it starts with #!, except for its newline so that the lines are still counted.
+func (l *lineReader) skipShebangLine() {
+	if b, _ := l.r.Peek(2); string(b) != "#!" {
+		return
 	}
-	i := strings.Index(query, "\n")
-	if i < 0 {
-		i = len(query)
+	for {
+		r, _, err := l.r.ReadRune()
+		if err != nil {
+			return
+		}
+		if r == '\n' {
+			_ = l.r.UnreadRune()
+			return
+		}
 	}
-	return query[i:]
 }
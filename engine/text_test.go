@@ -2,11 +2,17 @@ package engine
 
 import (
 	"context"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 	orderedmap "github.com/wk8/go-ordered-map/v2"
@@ -47,6 +53,7 @@ foo(a).
 								{opcode: OpGetConst, operand: NewAtom("a")},
 								{opcode: OpExit},
 							},
+							line: 2,
 						},
 					},
 				},
@@ -85,6 +92,7 @@ foo(b).
 								{opcode: OpGetConst, operand: NewAtom("a")},
 								{opcode: OpExit},
 							},
+							line: 2,
 						},
 						{
 							pi:  procedureIndicator{name: NewAtom("foo"), arity: 1},
@@ -93,6 +101,7 @@ foo(b).
 								{opcode: OpGetConst, operand: NewAtom("b")},
 								{opcode: OpExit},
 							},
+							line: 3,
 						},
 					},
 				},
@@ -130,6 +139,7 @@ bar(X, "abc", [a, b], [a, b|Y], f(a)) :- X, !, foo(X, "abc", [a, b], [a, b|Y], f
 								{opcode: OpCall, operand: procedureIndicator{name: atomTrue, arity: 0}},
 								{opcode: OpExit},
 							},
+							line: 2,
 						},
 					},
 				},
@@ -185,6 +195,7 @@ bar(X, "abc", [a, b], [a, b|Y], f(a)) :- X, !, foo(X, "abc", [a, b], [a, b|Y], f
 								{opcode: OpCall, operand: procedureIndicator{name: NewAtom("foo"), arity: 5}},
 								{opcode: OpExit},
 							},
+							line: 3,
 						},
 					},
 				},
@@ -227,6 +238,7 @@ point(point{x: 5}).
 								{opcode: OpPop},
 								{opcode: OpExit},
 							},
+							line: 2,
 						},
 					},
 				},
@@ -277,6 +289,7 @@ point(point{x: 5}.x).
 								{opcode: OpCall, operand: procedureIndicator{name: atomDot, arity: Integer(3)}},
 								{opcode: OpExit},
 							},
+							line: 2,
 						},
 					},
 				},
@@ -329,6 +342,7 @@ point(point{x: 5}.x) :- true.
 								{opcode: OpCall, operand: procedureIndicator{name: atomDot, arity: Integer(3)}},
 								{opcode: OpExit},
 							},
+							line: 2,
 						},
 					},
 				},
@@ -375,6 +389,7 @@ p :- foo(point{x: 5}).
 								{opcode: OpCall, operand: procedureIndicator{name: NewAtom("foo"), arity: 1}},
 								{opcode: OpExit},
 							},
+							line: 2,
 						},
 					},
 				},
@@ -427,6 +442,7 @@ x(X) :- p(P), =(X, P.x).
 								{opcode: OpCall, operand: procedureIndicator{name: NewAtom("="), arity: 2}},
 								{opcode: OpExit},
 							},
+							line: 2,
 						},
 					},
 				},
@@ -450,6 +466,7 @@ foo(b).
 								{opcode: OpGetConst, operand: NewAtom("a")},
 								{opcode: OpExit},
 							},
+							line: 3,
 						},
 						{
 							pi:  procedureIndicator{name: NewAtom("foo"), arity: 1},
@@ -458,6 +475,7 @@ foo(b).
 								{opcode: OpGetConst, operand: NewAtom("b")},
 								{opcode: OpExit},
 							},
+							line: 4,
 						},
 					},
 				},
@@ -488,6 +506,7 @@ foo(b).
 								{opcode: OpGetConst, operand: NewAtom("a")},
 								{opcode: OpExit},
 							},
+							line: 3,
 						},
 						{
 							pi:  procedureIndicator{name: NewAtom("foo"), arity: 1},
@@ -496,6 +515,7 @@ foo(b).
 								{opcode: OpGetConst, operand: NewAtom("b")},
 								{opcode: OpExit},
 							},
+							line: 4,
 						},
 					},
 				},
@@ -519,6 +539,7 @@ foo(b).
 								{opcode: OpGetConst, operand: NewAtom("a")},
 								{opcode: OpExit},
 							},
+							line: 3,
 						},
 						{
 							pi:  procedureIndicator{name: NewAtom("foo"), arity: 1},
@@ -527,6 +548,7 @@ foo(b).
 								{opcode: OpGetConst, operand: NewAtom("b")},
 								{opcode: OpExit},
 							},
+							line: 5,
 						},
 					},
 				},
@@ -542,6 +564,7 @@ foo(b).
 								{opcode: OpGetConst, operand: NewAtom("a")},
 								{opcode: OpExit},
 							},
+							line: 4,
 						},
 					},
 				},
@@ -576,6 +599,8 @@ foo(b).
 							bytecode: bytecode{
 								{opcode: OpExit},
 							},
+							file: "testdata/foo.pl",
+							line: 1,
 						},
 					},
 				},
@@ -610,6 +635,8 @@ foo(b).
 							bytecode: bytecode{
 								{opcode: OpExit},
 							},
+							file: "testdata/foo.pl",
+							line: 1,
 						},
 					},
 				},
@@ -661,7 +688,7 @@ foo(?).
 `, args: []interface{}{nil}, err: errors.New("can't convert to term: <invalid reflect.Value>")},
 		{title: "error: syntax error", text: `
 foo().
-`, err: unexpectedTokenError{actual: Token{kind: tokenClose, val: ")"}}},
+`, err: unexpectedTokenError{actual: Token{kind: TokenClose, val: ")"}, pos: position{line: 2, column: 5}}},
 		{title: "error: expansion error", text: `
 :- ensure_loaded('testdata/break_term_expansion').
 foo(a).
@@ -806,7 +833,433 @@ func TestVM_Consult(t *testing.T) {
 	}
 }
 
+func TestVM_RecoverSyntaxErrors(t *testing.T) {
+	text := `
+foo(a).
+foo(b c).
+foo(c).
+bar(().
+bar(d).
+`
+
+	t.Run("recover", func(t *testing.T) {
+		vm := VM{RecoverSyntaxErrors: true}
+		err := vm.Compile(context.Background(), text)
+		assert.Equal(t, SyntaxErrors{Errs: []error{
+			unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "c"}, pos: position{line: 3, column: 7}},
+			unexpectedTokenError{actual: Token{kind: TokenClose, val: ")"}, pos: position{line: 5, column: 6}},
+		}}, err)
+		assert.EqualError(t, err, `2 syntax errors
+unexpected token: letter digit(c) at line 3, column 7
+unexpected token: close()) at line 5, column 6`)
+
+		var e unexpectedTokenError
+		assert.ErrorAs(t, err, &e)
+		_, ok := vm.getProcedure(procedureIndicator{name: NewAtom("foo"), arity: 1})
+		assert.False(t, ok)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		vm := VM{RecoverSyntaxErrors: true}
+		err := vm.CompileReader(context.Background(), "foo.pl", strings.NewReader(`foo(.`))
+		assert.EqualError(t, err, `foo.pl: 1 syntax error
+unexpected token: end(.) at line 1, column 5`)
+	})
+
+	t.Run("no errors", func(t *testing.T) {
+		vm := VM{RecoverSyntaxErrors: true}
+		assert.NoError(t, vm.Compile(context.Background(), `foo(a). foo(b).`))
+		p, ok := vm.getProcedure(procedureIndicator{name: NewAtom("foo"), arity: 1})
+		assert.True(t, ok)
+		assert.Len(t, p.(*userDefined).clauses, 2)
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		var vm VM
+		err := vm.Compile(context.Background(), text)
+		assert.Equal(t, unexpectedTokenError{actual: Token{kind: TokenLetterDigit, val: "c"}, pos: position{line: 3, column: 7}}, err)
+	})
+}
+
+func TestVM_OnDirective(t *testing.T) {
+	var params []Term
+	vm := VM{
+		OnDirective: func(goal Term, env *Env) (bool, error) {
+			c, ok := env.Resolve(goal).(Compound)
+			if !ok || c.Functor() != NewAtom("chain_param") || c.Arity() != 1 {
+				return false, nil
+			}
+			if _, ok := env.Resolve(c.Arg(0)).(Variable); ok {
+				return false, errors.New("unbound chain param")
+			}
+			params = append(params, env.Resolve(c.Arg(0)))
+			return true, nil
+		},
+	}
+	vm.Register0(NewAtom("true"), func(_ *VM, k Cont, env *Env) *Promise {
+		return k(env)
+	})
+
+	t.Run("handled", func(t *testing.T) {
+		assert.NoError(t, vm.Compile(context.Background(), `
+:-(chain_param(gas_limit)).
+:-(true).
+:-(chain_param(denom)).
+`))
+		assert.Equal(t, []Term{NewAtom("gas_limit"), NewAtom("denom")}, params)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		assert.EqualError(t, vm.Compile(context.Background(), `:-(chain_param(_)).`), "unbound chain param")
+	})
+
+	t.Run("not handled", func(t *testing.T) {
+		err := vm.Compile(context.Background(), `:-(foo).`)
+		assert.Equal(t, existenceError(objectTypeProcedure, atomSlash.Apply(NewAtom("foo"), Integer(0)), nil), err)
+	})
+}
+
+func TestVM_CompileWarningHandler(t *testing.T) {
+	t.Run("warnings", func(t *testing.T) {
+		var ws []CompileWarning
+		vm := VM{CompileWarningHandler: func(w CompileWarning) error {
+			ws = append(ws, w)
+			return nil
+		}}
+		assert.NoError(t, vm.Compile(context.Background(), `foo(a).`))
+		assert.Empty(t, ws)
+
+		assert.NoError(t, vm.CompileReader(context.Background(), "foo.pl", strings.NewReader(`
+:-(foo(X, Y, _Z, _), bar(Y)).
+baz(X, X).
+foo(a, b, c, d).
+qux.
+foo(a).
+`)))
+		assert.Equal(t, []CompileWarning{
+			{Kind: CompileWarningSingletonVariables, File: "foo.pl", Line: 2, Procedure: "foo/4", Variables: []string{"X"}},
+			{Kind: CompileWarningDiscontiguous, File: "foo.pl", Line: 4, Procedure: "foo/4"},
+			{Kind: CompileWarningRedefinition, File: "foo.pl", Line: 6, Procedure: "foo/1"},
+		}, ws)
+		assert.EqualError(t, ws[0], "foo.pl:2: singleton variables X in foo/4")
+
+		p, ok := vm.getProcedure(procedureIndicator{name: NewAtom("foo"), arity: 4})
+		assert.True(t, ok)
+		assert.Len(t, p.(*userDefined).clauses, 2)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		vm := VM{CompileWarningHandler: func(w CompileWarning) error {
+			return w
+		}}
+		err := vm.Compile(context.Background(), `foo(X).`)
+		assert.Equal(t, CompileWarning{Kind: CompileWarningSingletonVariables, Line: 1, Procedure: "foo/1", Variables: []string{"X"}}, err)
+		assert.EqualError(t, err, "line 1: singleton variables X in foo/1")
+		_, ok := vm.getProcedure(procedureIndicator{name: NewAtom("foo"), arity: 1})
+		assert.False(t, ok)
+	})
+
+	t.Run("dynamic", func(t *testing.T) {
+		vm := VM{CompileWarningHandler: func(w CompileWarning) error {
+			return w
+		}}
+		assert.NoError(t, vm.Compile(context.Background(), `:-(dynamic(/(foo, 1))). foo(a).`))
+		assert.NoError(t, vm.Compile(context.Background(), `foo(b).`))
+	})
+}
+
+func TestVM_LoadProgress(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 250; i++ {
+		_, _ = fmt.Fprintf(&sb, "foo(%d).\n", i)
+	}
+	text := sb.String()
+
+	t.Run("progress", func(t *testing.T) {
+		var statuses []LoadStatus
+		vm := VM{
+			FS: fstest.MapFS{"foo.pl": {Data: []byte(text)}},
+			LoadProgress: func(status LoadStatus) error {
+				statuses = append(statuses, status)
+				return nil
+			},
+		}
+		_, err := vm.ensureLoaded(context.Background(), NewAtom("foo"), nil)
+		assert.NoError(t, err)
+		assert.Len(t, statuses, 3)
+		for i, n := range []int{100, 200, 250} {
+			assert.Equal(t, "foo.pl", statuses[i].File)
+			assert.Equal(t, n, statuses[i].Clauses)
+		}
+		assert.Less(t, statuses[0].Bytes, statuses[1].Bytes)
+		assert.Equal(t, len(text), statuses[2].Bytes)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		errBudget := errors.New("budget exceeded")
+		vm := VM{
+			LoadProgress: func(status LoadStatus) error {
+				if status.Clauses >= 200 {
+					return errBudget
+				}
+				return nil
+			},
+		}
+		assert.Equal(t, errBudget, vm.Compile(context.Background(), text))
+		_, ok := vm.getProcedure(procedureIndicator{name: NewAtom("foo"), arity: 1})
+		assert.False(t, ok)
+	})
+}
+
+func TestVM_ensureLoaded_cycle(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.pl":    {Data: []byte(`:-(ensure_loaded(b)).`)},
+		"b.pl":    {Data: []byte(`:-(ensure_loaded(c)).`)},
+		"c.pl":    {Data: []byte(`:-(ensure_loaded(a)).`)},
+		"i.pl":    {Data: []byte(`:-(include(j)).`)},
+		"j.pl":    {Data: []byte(`:-(include(i)).`)},
+		"self.pl": {Data: []byte(`:-(include(self)).`)},
+	}
+
+	t.Run("ensure_loaded", func(t *testing.T) {
+		vm := VM{FS: fsys}
+		_, err := vm.ensureLoaded(context.Background(), NewAtom("a"), nil)
+		assert.Equal(t, LoadCycleError{Path: []string{"a.pl", "b.pl", "c.pl", "a.pl"}}, err)
+		assert.EqualError(t, err, "load cycle: a.pl -> b.pl -> c.pl -> a.pl")
+		assert.Empty(t, vm.LoadedSources())
+	})
+
+	t.Run("include", func(t *testing.T) {
+		vm := VM{FS: fsys}
+		err := vm.Compile(context.Background(), `:-(include(i)).`)
+		assert.Equal(t, LoadCycleError{Path: []string{"i.pl", "j.pl", "i.pl"}}, err)
+
+		err = vm.Compile(context.Background(), `:-(include(self)).`)
+		assert.Equal(t, LoadCycleError{Path: []string{"self.pl", "self.pl"}}, err)
+	})
+
+	t.Run("content changed", func(t *testing.T) {
+		fsys := fstest.MapFS{"d.pl": {Data: []byte(`d.`)}}
+		vm := VM{FS: fsys}
+		_, err := vm.ensureLoaded(context.Background(), NewAtom("d"), nil)
+		assert.NoError(t, err)
+
+		_, err = vm.ensureLoaded(context.Background(), NewAtom("d"), nil)
+		assert.NoError(t, err)
+
+		fsys["d.pl"] = &fstest.MapFile{Data: []byte(`d(changed).`)}
+		_, err = vm.ensureLoaded(context.Background(), NewAtom("d"), nil)
+		assert.Equal(t, LoadConflictError{
+			File:    "d.pl",
+			Loaded:  sha256.Sum256([]byte(`d.`)),
+			Current: sha256.Sum256([]byte(`d(changed).`)),
+		}, err)
+	})
+}
+
+func TestVM_CompileReader(t *testing.T) {
+	t.Run("positions", func(t *testing.T) {
+		text := "#!/usr/bin/env 1pl\nfoo(a).\n\nfoo(b).\n"
+		var vm VM
+		assert.NoError(t, vm.CompileReader(context.Background(), "foo.pl", iotest.OneByteReader(strings.NewReader(text))))
+
+		p, ok := vm.getProcedure(procedureIndicator{name: NewAtom("foo"), arity: 1})
+		assert.True(t, ok)
+		cs := p.(*userDefined).clauses
+		assert.Len(t, cs, 2)
+		for i, line := range []int{2, 4} {
+			assert.Equal(t, "foo.pl", cs[i].file)
+			assert.Equal(t, line, cs[i].line)
+		}
+
+		assert.Equal(t, []string{"foo.pl"}, vm.LoadedSources())
+		sum, _ := vm.loaded.Get("foo.pl")
+		assert.Equal(t, sha256.Sum256([]byte(text)), sum)
+	})
+
+	t.Run("large", func(t *testing.T) {
+		const n = 10000
+		pr, pw := io.Pipe()
+		go func() {
+			for i := 0; i < n; i++ {
+				_, _ = fmt.Fprintf(pw, "fact(%d).\n", i)
+			}
+			_ = pw.Close()
+		}()
+
+		var vm VM
+		assert.NoError(t, vm.CompileReader(context.Background(), "facts.pl", pr))
+		p, ok := vm.getProcedure(procedureIndicator{name: NewAtom("fact"), arity: 1})
+		assert.True(t, ok)
+		assert.Len(t, p.(*userDefined).clauses, n)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		errRead := errors.New("read failed")
+		var vm VM
+		err := vm.CompileReader(context.Background(), "foo.pl", io.MultiReader(strings.NewReader("foo(a).\n"), iotest.ErrReader(errRead)))
+		assert.ErrorIs(t, err, errRead)
+		_, ok := vm.getProcedure(procedureIndicator{name: NewAtom("foo"), arity: 1})
+		assert.False(t, ok)
+		assert.Empty(t, vm.LoadedSources())
+	})
+}
+
+func TestLoadFiles(t *testing.T) {
+	foo, d := NewAtom("foo"), NewAtom("d")
+	raws := func(vm *VM, pi procedureIndicator) []Term {
+		p, ok := vm.getProcedure(pi)
+		if !ok {
+			return nil
+		}
+		var ret []Term
+		for _, c := range p.(*userDefined).clauses {
+			if c.erased == 0 {
+				ret = append(ret, c.raw)
+			}
+		}
+		return ret
+	}
+	load := func(vm *VM, file string, options ...Term) error {
+		_, err := LoadFiles(vm, NewAtom(file), List(options...), Success, nil).Force(context.Background())
+		return err
+	}
+
+	t.Run("reload", func(t *testing.T) {
+		fsys := fstest.MapFS{"a.pl": {Data: []byte(`foo(a). bar.`)}}
+		vm := VM{FS: fsys}
+		assert.NoError(t, load(&vm, "a"))
+		assert.Equal(t, []Term{foo.Apply(NewAtom("a"))}, raws(&vm, procedureIndicator{name: foo, arity: 1}))
+
+		fsys["a.pl"] = &fstest.MapFile{Data: []byte(`foo(b).`)}
+		assert.NoError(t, load(&vm, "a"))
+		assert.Equal(t, []Term{foo.Apply(NewAtom("b"))}, raws(&vm, procedureIndicator{name: foo, arity: 1}))
+		_, ok := vm.getProcedure(procedureIndicator{name: NewAtom("bar"), arity: 0})
+		assert.False(t, ok)
+		assert.Equal(t, []string{"a.pl"}, vm.LoadedSources())
+	})
+
+	t.Run("if changed", func(t *testing.T) {
+		fsys := fstest.MapFS{"d.pl": {Data: []byte(`:-(dynamic(/(d, 1))). d(a).`)}}
+		vm := VM{FS: fsys}
+		assert.NoError(t, load(&vm, "d", NewAtom("if").Apply(NewAtom("changed"))))
+		_, err := Assertz(&vm, d.Apply(NewAtom("b")), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+
+		assert.NoError(t, load(&vm, "d", NewAtom("if").Apply(NewAtom("changed"))))
+		assert.Len(t, raws(&vm, procedureIndicator{name: d, arity: 1}), 2)
+
+		fsys["d.pl"] = &fstest.MapFile{Data: []byte(`:-(dynamic(/(d, 1))). d(c).`)}
+		assert.NoError(t, load(&vm, "d", NewAtom("if").Apply(NewAtom("changed"))))
+		assert.Equal(t, []Term{d.Apply(NewAtom("c"))}, raws(&vm, procedureIndicator{name: d, arity: 1}))
+	})
+
+	t.Run("if not loaded", func(t *testing.T) {
+		fsys := fstest.MapFS{"a.pl": {Data: []byte(`foo(a).`)}}
+		vm := VM{FS: fsys}
+		assert.NoError(t, load(&vm, "a", NewAtom("if").Apply(NewAtom("not_loaded"))))
+
+		fsys["a.pl"] = &fstest.MapFile{Data: []byte(`foo(b).`)}
+		assert.NoError(t, load(&vm, "a", NewAtom("if").Apply(NewAtom("not_loaded"))))
+		assert.Equal(t, []Term{foo.Apply(NewAtom("a"))}, raws(&vm, procedureIndicator{name: foo, arity: 1}))
+	})
+
+	t.Run("modules", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"a.pl": {Data: []byte(`foo(a).`)},
+			"m.pl": {Data: []byte(`:-(module(m, [/(p, 1), /(q, 1)])). p(1). q(1).`)},
+		}
+		vm := VM{FS: fsys}
+		assert.NoError(t, load(&vm, "m", NewAtom("must_be_module").Apply(atomTrue), NewAtom("imports").Apply(List(atomSlash.Apply(NewAtom("p"), Integer(1))))))
+		assert.Equal(t, map[procedureIndicator]Atom{{name: NewAtom("p"), arity: 1}: NewAtom("m")}, vm.imports[atomUser])
+
+		assert.Equal(t, domainError(validDomainModuleFile, NewAtom("a"), nil), load(&vm, "a", NewAtom("must_be_module").Apply(atomTrue)))
+		_, ok := vm.getProcedure(procedureIndicator{name: foo, arity: 1})
+		assert.False(t, ok, "the clauses are not added")
+		assert.Equal(t, []string{"m.pl"}, vm.LoadedSources())
+
+		assert.NoError(t, load(&vm, "a"))
+		assert.Equal(t, domainError(validDomainModuleFile, NewAtom("a"), nil), load(&vm, "a", NewAtom("if").Apply(NewAtom("not_loaded")), NewAtom("must_be_module").Apply(atomTrue)))
+	})
+
+	t.Run("options", func(t *testing.T) {
+		vm := VM{FS: fstest.MapFS{"a.pl": {Data: []byte(`foo(a).`)}}}
+		assert.Equal(t, InstantiationError(nil), load(&vm, "a", NewVariable()))
+		assert.Equal(t, domainError(validDomainLoadOption, foo.Apply(atomTrue), nil), load(&vm, "a", foo.Apply(atomTrue)))
+		assert.Equal(t, domainError(validDomainLoadOption, NewAtom("if").Apply(foo), nil), load(&vm, "a", NewAtom("if").Apply(foo)))
+		assert.Equal(t, domainError(validDomainLoadOption, foo, nil), load(&vm, "a", foo))
+	})
+}
+
 func TestDiscontiguousError_Error(t *testing.T) {
 	e := discontiguousError{pi: procedureIndicator{name: NewAtom("foo"), arity: 1}}
 	assert.Equal(t, "foo/1 is discontiguous", e.Error())
 }
+
+func TestSourceFile(t *testing.T) {
+	vm := VM{FS: testdata}
+	_, err := Consult(&vm, List(NewAtom("testdata/foo"), NewAtom("testdata/empty.txt")), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	_, err = Assertz(&vm, NewAtom("bar"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+
+	t.Run("enumerate", func(t *testing.T) {
+		var files []Term
+		f := NewVariable()
+		_, err := SourceFile(&vm, f, func(env *Env) *Promise {
+			files = append(files, env.Resolve(f))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []Term{NewAtom("testdata/foo.pl"), NewAtom("testdata/empty.txt")}, files)
+	})
+
+	t.Run("not an atom", func(t *testing.T) {
+		_, err := SourceFile(&vm, Integer(0), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeAtom, Integer(0), nil), err)
+	})
+}
+
+func TestSourceFile2(t *testing.T) {
+	vm := VM{FS: testdata}
+	_, err := Consult(&vm, NewAtom("testdata/foo"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	_, err = Assertz(&vm, NewAtom("bar"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+
+	t.Run("file of a predicate", func(t *testing.T) {
+		f := NewVariable()
+		ok, err := SourceFile2(&vm, NewAtom("foo"), f, func(env *Env) *Promise {
+			assert.Equal(t, NewAtom("testdata/foo.pl"), env.Resolve(f))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("asserted", func(t *testing.T) {
+		ok, err := SourceFile2(&vm, NewAtom("bar"), NewVariable(), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("not callable", func(t *testing.T) {
+		_, err := SourceFile2(&vm, Integer(0), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, typeError(validTypeCallable, Integer(0), nil), err)
+	})
+}
+
+func TestLoadedFileHash(t *testing.T) {
+	vm := VM{FS: testdata}
+	_, err := Consult(&vm, NewAtom("testdata/foo"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+
+	sum := sha256.Sum256([]byte("foo."))
+	ok, err := LoadedFileHash(&vm, NewAtom("testdata/foo.pl"), NewAtom(hex.EncodeToString(sum[:])), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = LoadedFileHash(&vm, NewAtom("testdata/empty.txt"), NewVariable(), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/apd/v3"
+)
+
+// Now returns the current time according to VM.Clock.
+func (vm *VM) Now() time.Time {
+	if vm.Clock != nil {
+		return vm.Clock()
+	}
+	return time.Now()
+}
+
+// GetTime unifies t with the current time according to VM.Clock as a float number of seconds since the Unix epoch.
+func GetTime(vm *VM, t Term, k Cont, env *Env) *Promise {
+	s := apd.New(vm.Now().UnixNano(), -9)
+	s.Reduce(s)
+	return Unify(vm, t, Float{dec: s}, k, env)
+}
+
+// Time calls goal and reports the inferences, the wall time, and the gas used by it each time it succeeds and when
+// it finally fails. The reports are printed as the informational messages time(Goal, Port, Stats) where Port is exit
+// or fail and Stats is the dict of Time2, so that VM.MessageHook can handle them instead of the logger.
+func Time(vm *VM, goal Term, k Cont, env *Env) *Promise {
+	return timeGoal(vm, goal, func(_ timing, env *Env) *Promise {
+		return k(env)
+	}, env)
+}
+
+// Time2 is like Time but also unifies stats with a dict time{inferences: I, wall: W, gas: G} each time goal succeeds.
+// W is in seconds.
+func Time2(vm *VM, goal, stats Term, k Cont, env *Env) *Promise {
+	return timeGoal(vm, goal, func(t timing, env *Env) *Promise {
+		return Unify(vm, stats, newDict(t.dictArgs()), k, env)
+	}, env)
+}
+
+// timing is the resources used by a goal since it was called.
+type timing struct {
+	inferences uint64
+	wall       time.Duration
+	gas        uint64
+}
+
+func timeGoal(vm *VM, goal Term, k func(timing, *Env) *Promise, env *Env) *Promise {
+	var (
+		start      = vm.Now()
+		inferences = atomic.LoadUint64(&vm.inferences)
+		gas        = vm.GasUsed()
+	)
+	report := func(ctx context.Context, port Atom, env *Env) timing {
+		t := timing{
+			inferences: atomic.LoadUint64(&vm.inferences) - inferences,
+			wall:       vm.Now().Sub(start),
+			gas:        vm.GasUsed() - gas,
+		}
+		vm.printMessage(ctx, atomInformational, atomTime.Apply(goal, port, newDict(t.dictArgs())), env)
+		return t
+	}
+	return Delay(func(ctx context.Context) *Promise {
+		return Call(vm, goal, func(env *Env) *Promise {
+			return k(report(ctx, atomExit, env), env)
+		}, env)
+	}, func(ctx context.Context) *Promise {
+		_ = report(ctx, atomFail, env)
+		return Bool(false)
+	})
+}
+
+// dictArgs returns the tag, the keys, and the values of the dict describing t.
+func (t timing) dictArgs() []Term {
+	wall := apd.New(t.wall.Nanoseconds(), -9)
+	wall.Reduce(wall)
+	return []Term{
+		atomTime,
+		atomGas, Integer(t.gas),
+		atomInferences, Integer(t.inferences),
+		atomWall, Float{dec: wall},
+	}
+}
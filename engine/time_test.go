@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTime(t *testing.T) {
+	var buf bytes.Buffer
+	var vm VM
+	vm.SetLogger(newTestLogHandler(&buf))
+	vm.Clock = func() time.Time {
+		return time.Unix(0, 0)
+	}
+	vm.Register0(NewAtom("foo"), func(vm *VM, k Cont, env *Env) *Promise {
+		return vm.Arrive(NewAtom("bar"), nil, k, env)
+	})
+	vm.Register0(NewAtom("bar"), func(_ *VM, k Cont, env *Env) *Promise {
+		return k(env)
+	})
+	vm.Register0(NewAtom("baz"), func(*VM, Cont, *Env) *Promise {
+		return Bool(false)
+	})
+
+	t.Run("exit", func(t *testing.T) {
+		buf.Reset()
+		ok, err := Time(&vm, NewAtom("foo"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, `level=INFO msg="foo timed at exit: 2 inferences, 0.0 seconds, 0 gas" kind=informational message=time(foo,exit,time{gas:0,inferences:2,wall:0.0})
+`, buf.String())
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		buf.Reset()
+		ok, err := Time(&vm, NewAtom("baz"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, `level=INFO msg="baz timed at fail: 1 inferences, 0.0 seconds, 0 gas" kind=informational message=time(baz,fail,time{gas:0,inferences:1,wall:0.0})
+`, buf.String())
+	})
+
+	t.Run("message hook", func(t *testing.T) {
+		buf.Reset()
+		var messages []Term
+		vm.MessageHook = func(kind, message Term, env *Env) bool {
+			assert.Equal(t, atomInformational, kind)
+			messages = append(messages, env.simplify(message))
+			return true
+		}
+		defer func() {
+			vm.MessageHook = nil
+		}()
+		ok, err := Time(&vm, NewAtom("foo"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Empty(t, buf.String())
+		assert.Len(t, messages, 1)
+		assert.Equal(t, atomTime.Apply(NewAtom("foo"), atomExit, newDict(timing{inferences: 2}.dictArgs())), messages[0])
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		stats := NewVariable()
+		ok, err := Time2(&vm, NewAtom("foo"), stats, func(env *Env) *Promise {
+			d, ok := env.Resolve(stats).(Dict)
+			assert.True(t, ok)
+			assert.Equal(t, atomTime, d.Tag())
+			v, _ := d.Value(atomInferences)
+			assert.Equal(t, Integer(2), v)
+			v, _ = d.Value(atomGas)
+			assert.Equal(t, Integer(0), v)
+			v, _ = d.Value(atomWall)
+			assert.IsType(t, Float{}, v)
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func TestTiming_dictArgs(t *testing.T) {
+	assert.Equal(t, []Term{
+		atomTime,
+		atomGas, Integer(3),
+		atomInferences, Integer(2),
+		atomWall, newFloatFromStringMust("1.5"),
+	}, timing{inferences: 2, wall: 1500 * time.Millisecond, gas: 3}.dictArgs())
+}
+
+func TestGetTime(t *testing.T) {
+	var vm VM
+	vm.Clock = func() time.Time {
+		return time.Unix(1700000000, 250000000)
+	}
+
+	ok, err := GetTime(&vm, newFloatFromStringMust("1700000000.25"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	t.Run("time/2", func(t *testing.T) {
+		now := time.Unix(0, 0)
+		vm := VM{Clock: func() time.Time {
+			now = now.Add(500 * time.Millisecond)
+			return now
+		}}
+		vm.Register0(NewAtom("foo"), func(_ *VM, k Cont, env *Env) *Promise {
+			return k(env)
+		})
+
+		stats := NewVariable()
+		ok, err := Time2(&vm, NewAtom("foo"), stats, func(env *Env) *Promise {
+			d, ok := env.Resolve(stats).(Dict)
+			assert.True(t, ok)
+			v, _ := d.Value(atomWall)
+			assert.Equal(t, newFloatFromStringMust("0.5"), v)
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("statistics(walltime, _)", func(t *testing.T) {
+		now := time.Unix(0, 0)
+		vm := VM{Clock: func() time.Time {
+			now = now.Add(1500 * time.Millisecond)
+			return now
+		}}
+
+		for _, want := range []Term{List(Integer(0), Integer(0)), List(Integer(1500), Integer(1500)), List(Integer(3000), Integer(1500))} {
+			ok, err := Statistics(&vm, atomWalltime, want, Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+	})
+}
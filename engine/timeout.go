@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WrapWithTimeout returns a foreign predicate which runs p with its own deadline d from the call and raises
+// time_limit_exceeded if p doesn't complete by then, e.g. if it blocks on I/O. p runs in another goroutine with the
+// context carrying the deadline and its solutions are collected before the continuation is called with each of them.
+// On a timeout, p is abandoned; it should stop as soon as the context is done and mustn't use the VM afterwards.
+//
+// It composes with WrapPredicate:
+//
+//	err := vm.WrapPredicate(PI("http_get", 2), func(next PredicateN) PredicateN {
+//		return WrapWithTimeout(next, 5*time.Second)
+//	})
+func WrapWithTimeout(p PredicateN, d time.Duration) PredicateN {
+	return func(vm *VM, args []Term, k Cont, env *Env) *Promise {
+		return Delay(func(parent context.Context) *Promise {
+			ctx, cancel := context.WithTimeout(parent, d)
+			defer cancel()
+
+			type result struct {
+				envs []*Env
+				err  error
+			}
+			done := make(chan result, 1)
+			go func() {
+				var r result
+				defer func() {
+					// The panic can't be recovered by the caller in another goroutine.
+					if v := recover(); v != nil {
+						r.err = panicError(v)
+					}
+					done <- r
+				}()
+				_, r.err = p(vm, args, func(env *Env) *Promise {
+					r.envs = append(r.envs, env)
+					return Bool(false)
+				}, env).Force(ctx)
+			}()
+
+			var r result
+			select {
+			case r = <-done:
+			case <-ctx.Done():
+				r.err = ctx.Err()
+			}
+			switch {
+			case errors.Is(r.err, context.DeadlineExceeded) && parent.Err() == nil:
+				return Error(NewException(atomTimeLimitExceeded, env))
+			case r.err != nil:
+				return Error(r.err)
+			}
+
+			ks := make([]func(context.Context) *Promise, len(r.envs))
+			for i, env := range r.envs {
+				ks[i] = func(context.Context) *Promise {
+					return k(env)
+				}
+			}
+			return Delay(ks...)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapWithTimeout(t *testing.T) {
+	t.Run("solutions", func(t *testing.T) {
+		p := WrapWithTimeout(func(vm *VM, args []Term, k Cont, env *Env) *Promise {
+			return Delay(func(context.Context) *Promise {
+				return Unify(vm, args[0], Integer(1), k, env)
+			}, func(context.Context) *Promise {
+				return Unify(vm, args[0], Integer(2), k, env)
+			})
+		}, time.Second)
+
+		x := NewVariable()
+		var got []Term
+		_, err := p(nil, []Term{x}, func(env *Env) *Promise {
+			got = append(got, env.Resolve(x))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []Term{Integer(1), Integer(2)}, got)
+	})
+
+	t.Run("blocking", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		p := WrapWithTimeout(func(_ *VM, _ []Term, k Cont, env *Env) *Promise {
+			<-release
+			return k(env)
+		}, 10*time.Millisecond)
+
+		_, err := p(nil, nil, Success, nil).Force(context.Background())
+		assert.Equal(t, NewException(atomTimeLimitExceeded, nil), err)
+	})
+
+	t.Run("respecting the deadline", func(t *testing.T) {
+		p := WrapWithTimeout(func(_ *VM, _ []Term, k Cont, env *Env) *Promise {
+			return Delay(func(ctx context.Context) *Promise {
+				<-ctx.Done()
+				return Error(ctx.Err())
+			})
+		}, 10*time.Millisecond)
+
+		_, err := p(nil, nil, Success, nil).Force(context.Background())
+		assert.Equal(t, NewException(atomTimeLimitExceeded, nil), err)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		p := WrapWithTimeout(func(_ *VM, _ []Term, k Cont, env *Env) *Promise {
+			cancel()
+			return Delay(func(ctx context.Context) *Promise {
+				<-ctx.Done()
+				return Error(ctx.Err())
+			})
+		}, time.Second)
+
+		_, err := p(nil, nil, Success, nil).Force(ctx)
+		assert.Equal(t, context.Canceled, err)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		p := WrapWithTimeout(func(_ *VM, _ []Term, _ Cont, env *Env) *Promise {
+			return Error(InstantiationError(env))
+		}, time.Second)
+
+		_, err := p(nil, nil, Success, nil).Force(context.Background())
+		assert.Equal(t, InstantiationError(nil), err)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		p := WrapWithTimeout(func(*VM, []Term, Cont, *Env) *Promise {
+			panic("oops")
+		}, time.Second)
+
+		_, err := p(nil, nil, Success, nil).Force(context.Background())
+		assert.Equal(t, panicError("oops"), err)
+	})
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+// Tracer starts spans around query execution, consult and expensive builtins.
+// It's small enough to be backed by OpenTelemetry or any other tracing library without the VM depending on it.
+type Tracer interface {
+	// Start starts a span named name as a child of the span in ctx, if any, and returns a context holding the new span.
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+}
+
+// Span is a unit of work started by a Tracer.
+type Span interface {
+	SetAttributes(attrs ...slog.Attr)
+	RecordError(err error)
+	End()
+}
+
+// Span names used by the VM and the interpreter.
+const (
+	SpanQuery   = "prolog.query"
+	SpanConsult = "prolog.consult"
+	SpanBuiltin = "prolog.builtin"
+)
+
+// Span attribute keys used by the VM and the interpreter.
+const (
+	AttrPredicateIndicator = "prolog.predicate_indicator"
+	AttrQuery              = "prolog.query"
+	AttrFile               = "prolog.file"
+	AttrSolutions          = "prolog.solutions"
+	AttrGasUsed            = "prolog.gas_used"
+)
+
+// expensivePredicates are the builtins which get a span without an explicit call to TracePredicate.
+var expensivePredicates = map[procedureIndicator]struct{}{
+	{name: NewAtom("findall"), arity: 3}:   {},
+	{name: NewAtom("findall"), arity: 4}:   {},
+	{name: NewAtom("bagof"), arity: 3}:     {},
+	{name: NewAtom("setof"), arity: 3}:     {},
+	{name: NewAtom("sort"), arity: 2}:      {},
+	{name: NewAtom("sort"), arity: 4}:      {},
+	{name: NewAtom("msort"), arity: 2}:     {},
+	{name: NewAtom("keysort"), arity: 2}:   {},
+	{name: NewAtom("copy_term"), arity: 2}: {},
+}
+
+// SetTracer makes the VM start spans with t. A nil tracer disables tracing.
+func (vm *VM) SetTracer(t Tracer) {
+	vm.tracer = t
+}
+
+// TracePredicate makes calls to the procedure identified by the predicate indicator pi start a span,
+// in addition to the expensive builtins such as findall/3, setof/3 or sort/2.
+func (vm *VM) TracePredicate(pi Term) error {
+	key, err := toProcedureIndicator(pi, nil)
+	if err != nil {
+		return err
+	}
+	if vm.traced == nil {
+		vm.traced = map[procedureIndicator]struct{}{}
+	}
+	vm.traced[key] = struct{}{}
+	return nil
+}
+
+// StartSpan starts a span with the tracer set by SetTracer. If there's none, it returns ctx and a span doing nothing.
+func (vm *VM) StartSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	if vm == nil || vm.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return vm.tracer.Start(ctx, name, attrs...)
+}
+
+// GasUsed returns the total units charged to the meter installed by InstallMeter.
+func (vm *VM) GasUsed() uint64 {
+	return atomic.LoadUint64(&vm.gasUsed)
+}
+
+func (vm *VM) isTraced(pi procedureIndicator) bool {
+	if vm.tracer == nil {
+		return false
+	}
+	if _, ok := expensivePredicates[pi]; ok {
+		return true
+	}
+	_, ok := vm.traced[pi]
+	return ok
+}
+
+// traceCall calls p within a span which ends as soon as p calls its continuation, fails or raises an error.
+func (vm *VM) traceCall(pi procedureIndicator, p procedure, args []Term, k Cont, env *Env) *Promise {
+	return Delay(func(ctx context.Context) *Promise {
+		_, span := vm.StartSpan(ctx, SpanBuiltin, slog.String(AttrPredicateIndicator, pi.String()))
+		gas := vm.GasUsed()
+		var ended bool
+		end := func(err error) {
+			if ended {
+				return
+			}
+			ended = true
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.SetAttributes(slog.Uint64(AttrGasUsed, vm.GasUsed()-gas))
+			span.End()
+		}
+		return catch(func(err error) *Promise {
+			end(err)
+			return nil
+		}, func(context.Context) *Promise {
+			return Delay(func(context.Context) *Promise {
+				return p.call(vm, args, func(env *Env) *Promise {
+					end(nil)
+					return k(env)
+				}, env)
+			}, func(context.Context) *Promise {
+				end(nil)
+				return Bool(false)
+			})
+		})
+	})
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...slog.Attr) {}
+
+func (noopSpan) RecordError(error) {}
+
+func (noopSpan) End() {}
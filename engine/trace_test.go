@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingTracer struct {
+	spans []*recordingSpan
+}
+
+func (r *recordingTracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	s := &recordingSpan{name: name, attrs: attrs}
+	r.spans = append(r.spans, s)
+	return ctx, s
+}
+
+type recordingSpan struct {
+	name  string
+	attrs []slog.Attr
+	err   error
+	ended int
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...slog.Attr) {
+	s.attrs = append(s.attrs, attrs...)
+}
+
+func (s *recordingSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *recordingSpan) End() {
+	s.ended++
+}
+
+func (s *recordingSpan) String() string {
+	var sb strings.Builder
+	sb.WriteString(s.name)
+	for _, a := range s.attrs {
+		_, _ = fmt.Fprintf(&sb, " %s", a)
+	}
+	if s.err != nil {
+		_, _ = fmt.Fprintf(&sb, " error=%v", s.err)
+	}
+	return sb.String()
+}
+
+func TestVM_SetTracer(t *testing.T) {
+	t.Run("expensive builtin", func(t *testing.T) {
+		var tr recordingTracer
+		var vm VM
+		vm.Register2(NewAtom("sort"), Sort)
+		vm.InstallMeter(func(MeterKind, uint64) Term {
+			return nil
+		})
+		vm.SetTracer(&tr)
+
+		ok, err := Call(&vm, NewAtom("sort").Apply(List(Integer(2), Integer(1)), NewVariable()), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Len(t, tr.spans, 1)
+		assert.Equal(t, 1, tr.spans[0].ended)
+		assert.Equal(t, SpanBuiltin, tr.spans[0].name)
+		assert.Equal(t, "sort/2", tr.spans[0].attrs[0].Value.String())
+		assert.Equal(t, AttrGasUsed, tr.spans[0].attrs[1].Key)
+		assert.NotZero(t, tr.spans[0].attrs[1].Value.Uint64())
+	})
+
+	t.Run("traced predicate", func(t *testing.T) {
+		foo := NewAtom("foo")
+		bar := NewAtom("bar")
+		baz := NewAtom("baz")
+
+		var tr recordingTracer
+		var vm VM
+		vm.Register0(foo, func(_ *VM, k Cont, env *Env) *Promise {
+			return Delay(func(context.Context) *Promise {
+				return k(env)
+			}, func(context.Context) *Promise {
+				return k(env)
+			})
+		})
+		vm.Register0(bar, func(*VM, Cont, *Env) *Promise {
+			return Delay(func(context.Context) *Promise {
+				return Bool(false)
+			})
+		})
+		vm.Register0(baz, func(*VM, Cont, *Env) *Promise {
+			return Error(errors.New("failed"))
+		})
+		vm.SetTracer(&tr)
+		assert.NoError(t, vm.TracePredicate(PI("foo", 0)))
+		assert.NoError(t, vm.TracePredicate(PI("bar", 0)))
+		assert.NoError(t, vm.TracePredicate(PI("baz", 0)))
+
+		ok, err := Call(&vm, foo, Failure, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		ok, err = Call(&vm, bar, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		_, err = Call(&vm, baz, Success, nil).Force(context.Background())
+		assert.Error(t, err)
+
+		assert.Len(t, tr.spans, 3)
+		for _, s := range tr.spans {
+			assert.Equal(t, 1, s.ended)
+		}
+		assert.Equal(t, "prolog.builtin prolog.predicate_indicator=foo/0 prolog.gas_used=0", tr.spans[0].String())
+		assert.Equal(t, "prolog.builtin prolog.predicate_indicator=bar/0 prolog.gas_used=0", tr.spans[1].String())
+		assert.Equal(t, "prolog.builtin prolog.predicate_indicator=baz/0 prolog.gas_used=0 error=failed", tr.spans[2].String())
+	})
+
+	t.Run("consult", func(t *testing.T) {
+		var tr recordingTracer
+		vm := VM{FS: testdata}
+		vm.SetTracer(&tr)
+
+		ok, err := Consult(&vm, NewAtom("testdata/empty.txt"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Len(t, tr.spans, 1)
+		assert.Equal(t, "prolog.consult prolog.file=testdata/empty.txt", tr.spans[0].String())
+		assert.Equal(t, 1, tr.spans[0].ended)
+	})
+
+	t.Run("no tracer", func(t *testing.T) {
+		var vm VM
+		vm.Register2(NewAtom("sort"), Sort)
+
+		ok, err := Call(&vm, NewAtom("sort").Apply(List(Integer(2), Integer(1)), List(Integer(1), Integer(2))), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ctx, span := vm.StartSpan(context.Background(), SpanQuery)
+		assert.Equal(t, context.Background(), ctx)
+		assert.Equal(t, noopSpan{}, span)
+	})
+
+	t.Run("invalid predicate indicator", func(t *testing.T) {
+		var vm VM
+		assert.Error(t, vm.TracePredicate(NewAtom("foo")))
+	})
+}
+
+func TestVM_GasUsed(t *testing.T) {
+	var vm VM
+	vm.Register0(NewAtom("foo"), func(_ *VM, k Cont, env *Env) *Promise {
+		return k(env)
+	})
+	vm.InstallMeter(func(MeterKind, uint64) Term {
+		return nil
+	})
+
+	ok, err := Call(&vm, NewAtom("foo"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), vm.GasUsed())
+
+	t.Run("clone", func(t *testing.T) {
+		c := vm.Clone()
+		ok, err := Call(c, NewAtom("foo"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(6), c.GasUsed())
+		assert.Equal(t, uint64(3), vm.GasUsed())
+	})
+}
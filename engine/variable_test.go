@@ -143,6 +143,9 @@ func Test_freeVariablesSet(t *testing.T) {
 }
 
 func Test_maxVariables(t *testing.T) {
+	t.Cleanup(func() {
+		maxVariables = 0
+	})
 	tests := []struct {
 		title         string
 		init          func()
@@ -2,10 +2,17 @@ package engine
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	orderedmap "github.com/wk8/go-ordered-map/v2"
 )
@@ -123,20 +130,78 @@ type VM struct {
 	// Unknown is a callback that is triggered when the VM reaches to an unknown predicate while current_prolog_flag(unknown, warning).
 	Unknown func(name Atom, args []Term, env *Env)
 
-	procedures *orderedmap.OrderedMap[procedureIndicator, procedure]
-	unknown    unknownAction
-
-	// FS is a file system that is referenced when the VM loads Prolog texts e.g. ensure_loaded/1
-	// and when open/3 or open/4 access a source/sink. Write modes are permitted only if FS
-	// supports OpenFile.
-	FS     fs.FS
-	loaded *orderedmap.OrderedMap[string, struct{}]
+	procedures     *orderedmap.OrderedMap[procedureIndicator, procedure]
+	unknown        unknownAction
+	clauseStore    ClauseStore // the persistent storage of the dynamic procedures, if any.
+	clauseStoreGen uint64      // incremented every time the clause store is set.
+	journaling     bool
+	journal        []Term                                         // the goals redoing the mutations of the VM in order.
+	clauseRefs     map[*clause]*clauseRef                         // the references to the clauses obtained from the VM.
+	arenas         map[procedureIndicator][]*TermArena            // the arenas held by the procedures of the facts they store.
+	records        *orderedmap.OrderedMap[recordKey, *recordList] // the recorded database.
+	globals        map[Atom]Term                                  // the non-backtrackable global variables.
+
+	// OnHalt is a callback that is triggered when the VM executes halt/1 with the requested exit code.
+	// If it returns an error, the error is propagated in place of HaltError, e.g. a catchable Exception.
+	// If it returns nil, halt/1 succeeds and the execution continues.
+	// If it is not set, halt/1 results in HaltError which bypasses catch/3 and is surfaced to the host.
+	OnHalt func(code int) error
+
+	// Clock is a callback that is triggered when the VM needs the current time, e.g. get_time/1, time/1, or the
+	// durations it logs. If it is not set, the VM uses the system clock. Set it to e.g. the time of the current block
+	// to make queries deterministic.
+	Clock func() time.Time
+
+	// OnDirective is a callback that is triggered when the VM loads a Prolog text and reaches a directive which is
+	// not handled by the VM itself, e.g. :- chain_param(...). If it returns true, the directive is considered done.
+	// Otherwise, the directive is executed as a goal. If it returns an error, the load fails with the error.
+	OnDirective func(goal Term, env *Env) (handled bool, err error)
+
+	// MessageHook is a callback that is triggered when the VM prints a message with print_message/2 or by itself, e.g.
+	// unknown_procedure(PI) of kind warning while current_prolog_flag(unknown, warning). If it returns true, the
+	// message is considered printed. Otherwise, it's logged at the level of its kind.
+	MessageHook func(kind, message Term, env *Env) bool
+
+	// RecoverSyntaxErrors, if set, makes the VM skip the clauses with syntax errors up to their end tokens and go on
+	// compiling Prolog texts, e.g. to validate a whole text at once. The errors of a text are returned together as
+	// SyntaxErrors once it's read, and the clauses of the text aren't added to the DB.
+	RecoverSyntaxErrors bool
+
+	// CompileWarningHandler is a callback that is triggered when the VM compiles a Prolog text with singleton
+	// variables, discontiguous clauses without discontiguous/1, or redefinitions of static procedures. If it returns an
+	// error, the load fails with the error, e.g. the warning itself. If it returns nil, the text is loaded as is, even
+	// the discontiguous clauses. If it is not set, the discontiguous clauses are errors and the others are ignored.
+	CompileWarningHandler func(w CompileWarning) error
+
+	// LoadProgress is a callback that is triggered while the VM loads a Prolog text, every 100 clauses and at the end
+	// of the text. If it returns an error, the load stops and fails with the error, and the clauses of the text aren't
+	// added to the DB. The directives run so far and the clauses added to other modules, e.g. m:foo, are not undone
+	// though.
+	LoadProgress func(status LoadStatus) error
+
+	// FS is a file system that is referenced when the VM loads Prolog texts e.g. ensure_loaded/1, unless Loader is
+	// set, and when open/3 or open/4 access a source/sink. Write modes are permitted only if FS supports OpenFile.
+	FS fs.FS
+	// Loader, if set, resolves the sources of the Prolog texts the VM loads instead of FS, e.g. library(lists) or
+	// 'axone://dataverse/<id>'.
+	Loader Loader
+
+	loaded    *orderedmap.OrderedMap[string, [sha256.Size]byte] // the digests of the contents of the loaded files.
+	loading   []string                                          // the files being loaded or included.
+	autoloads map[procedureIndicator]string                     // the files defining the procedures loaded on demand.
 
 	// Internal/external expression
-	_operators      *operators
-	charConversions map[rune]rune
-	charConvEnabled bool
-	doubleQuotes    doubleQuotes
+	_operators       *operators
+	builtInOperators *operators // the operators defined when MarkBuiltIn was called.
+	charConversions  map[rune]rune
+	charConvEnabled  bool
+	doubleQuotes     doubleQuotes
+	occursCheck      bool
+	preferRationals  bool
+	numberSyntaxMode numberSyntax
+	syntaxErrors     syntaxErrors
+	unaryFunctions   map[Atom]UnaryFunction
+	binaryFunctions  map[Atom]BinaryFunction
 
 	// I/O
 	streams       streams
@@ -146,13 +211,63 @@ type VM struct {
 	maxVariables uint64
 
 	// Hook
-	hook HookFunc
+	hook     HookFunc
+	portHook PortHookFunc
 
 	// Meter
 	meter MeterFunc
 
+	// Logging
+	logger *slog.Logger
+
+	// Tracing
+	tracer       Tracer
+	traced       map[procedureIndicator]struct{}
+	gasUsed      uint64
+	inferences   uint64
+	instructions uint64
+
+	// Metrics
+	metrics *metrics
+
+	// Random
+	random rand.PCG
+
+	// Slicing
+	slicing *slicer // records the procedures called and the clauses entered.
+
+	// Modules
+	modules     map[Atom]*module
+	moduleFiles map[string]Atom // the modules defined by the files.
+	imports     map[Atom]map[procedureIndicator]Atom
+
+	// Garbage collection
+	generation uint64
+	clauseGC   ClauseGCStats
+	envGC      envGC
+
+	// Tabling
+	tables     map[tableKey]*answerTable
+	tableStack []*tableFrame
+	tableGen   uint64
+
+	// Profiling
+	profiling bool
+	profiler  *profiler
+	covering  bool
+	coverage  *coverage
+	walltime  struct{ start, last time.Time }
+
+	// Debugger
+	tracing bool
+	spied   map[procedureIndicator]struct{}
+
 	// Misc
-	debug bool
+	debug      bool
+	audit      bool
+	frozen     bool
+	backtraces bool
+	history    *goalHistory
 }
 
 // Register0 registers a predicate of arity 0.
@@ -200,6 +315,83 @@ func (vm *VM) Register8(name Atom, p Predicate8) {
 	vm.setProcedure(procedureIndicator{name: name, arity: 8}, p)
 }
 
+// RegisterVariadic registers a predicate for every arity from minArity to maxArity, both inclusive.
+// The predicate receives the arguments of the call as a slice whose length is the arity it was called with.
+func (vm *VM) RegisterVariadic(name Atom, minArity, maxArity int, p PredicateN) {
+	if minArity < 0 {
+		minArity = 0
+	}
+	for arity := minArity; arity <= maxArity; arity++ {
+		vm.setProcedure(procedureIndicator{name: name, arity: Integer(arity)}, p)
+	}
+}
+
+// RegisterFlagPredicate registers a predicate of arity 0 which succeeds once if get returns true at the time of the
+// call, and fails otherwise. It reflects a host boolean, e.g. a feature toggle, without asserting and retracting facts
+// whenever it changes.
+func (vm *VM) RegisterFlagPredicate(name Atom, get func() bool) {
+	vm.Register0(name, func(_ *VM, k Cont, env *Env) *Promise {
+		if !get() {
+			return Bool(false)
+		}
+		return k(env)
+	})
+}
+
+// MarkBuiltIn marks the user-defined procedures and the operators defined so far, e.g. the ones of a bootstrap
+// library, as built-in so that predicate_property/2 reports the procedures as built_in without their clauses and
+// Slice leaves them out.
+func (vm *VM) MarkBuiltIn() {
+	vm.builtInOperators = vm.getOperators().clone()
+	if vm.procedures == nil {
+		return
+	}
+	for e := vm.procedures.Oldest(); e != nil; e = e.Next() {
+		if u, ok := e.Value.(*userDefined); ok {
+			u.builtIn = true
+		}
+	}
+}
+
+// Unregister removes the procedure identified by the predicate indicator pi e.g. PI("open", 4).
+func (vm *VM) Unregister(pi Term) error {
+	key, err := toProcedureIndicator(pi, nil)
+	if err != nil {
+		return err
+	}
+	if _, ok := vm.getProcedure(key); !ok {
+		return existenceError(objectTypeProcedure, key.Term(), nil)
+	}
+	vm.procedures.Delete(key)
+	return nil
+}
+
+// WrapPredicate replaces the foreign predicate identified by the predicate indicator pi with wrap(next)
+// where next calls the original one. It lets cross-cutting concerns such as metering, auditing or caching be
+// layered over a specific builtin. If wrap returns nil, the original predicate is kept.
+func (vm *VM) WrapPredicate(pi Term, wrap func(next PredicateN) PredicateN) error {
+	key, err := toProcedureIndicator(pi, nil)
+	if err != nil {
+		return err
+	}
+	p, ok := vm.getProcedure(key)
+	if !ok {
+		return existenceError(objectTypeProcedure, key.Term(), nil)
+	}
+	if _, ok := p.(*userDefined); ok {
+		return fmt.Errorf("%w: %s", errNotForeign, key)
+	}
+	next := func(vm *VM, args []Term, k Cont, env *Env) *Promise {
+		return p.call(vm, args, k, env)
+	}
+	if w := wrap(next); w != nil {
+		vm.setProcedure(key, w)
+	}
+	return nil
+}
+
+var errNotForeign = errors.New("not a foreign predicate")
+
 type unknownAction int
 
 const (
@@ -225,47 +417,140 @@ type Cont func(*Env) *Promise
 
 // Arrive is the entry point of the VM.
 func (vm *VM) Arrive(name Atom, args []Term, k Cont, env *Env) (promise *Promise) {
+	return vm.arrive(name, args, k, siteHost, env)
+}
+
+// callSite is where a procedure is called from.
+type callSite int
+
+const (
+	siteHost callSite = iota // a foreign predicate or the host.
+	siteBody                 // a goal of a clause body but the last one.
+	siteLast                 // the last goal of a clause body.
+)
+
+// arrive calls the procedure name/len(args) from site. If site is siteLast, k is the continuation of the clause,
+// which runs the wakeups pending at its exit.
+func (vm *VM) arrive(name Atom, args []Term, k Cont, site callSite, env *Env) (promise *Promise) {
 	defer ensurePromise(&promise)
 
 	if vm.Unknown == nil {
 		vm.Unknown = func(Atom, []Term, *Env) {}
 	}
 
+	m := atomUser
+	for name == atomColon && len(args) == 2 {
+		var err error
+		m, name, args, err = resolveQualified(args[0], args[1], env)
+		if err != nil {
+			return Error(err)
+		}
+	}
+
+	atomic.AddUint64(&vm.inferences, 1)
+
 	pi := procedureIndicator{name: name, arity: Integer(len(args))}
-	p, ok := vm.getProcedure(pi)
+	p, ok := vm.lookupProcedure(m, pi)
 	if !ok {
-		switch vm.unknown {
-		case unknownWarning:
+		if promise, ok := vm.autoload(m, pi, args, k, env); ok {
+			return promise
+		}
+
+		unknown := vm.flags(env).unknown
+		if unknown == unknownWarning {
+			// The warning is printed as print_message/2 does so that MessageHook can handle it instead of the logger.
+			vm.printMessage(context.Background(), atomWarning, atomUnknownProcedure.Apply(pi.Term()), env)
 			vm.Unknown(name, args, env)
-			fallthrough
-		case unknownFail:
 			return Bool(false)
-		default:
-			return Error(existenceError(objectTypeProcedure, pi.Term(), env))
 		}
+
+		vm.log(context.Background(), slog.LevelDebug, "unknown procedure", slog.String("pi", pi.String()), slog.String("unknown", unknown.String()))
+		if unknown == unknownFail {
+			return Bool(false)
+		}
+		return Error(existenceError(objectTypeProcedure, pi.Term(), env))
+	}
+
+	if vm.history != nil {
+		vm.history.record(pi, args, env)
 	}
 
+	query := env == nil || env.flags == nil // the procedure is the first one the query calls.
 	env = vm.prepareEnv(env)
 
 	// bind the special variable to inform the predicate about the context.
 	env = env.bind(varContext, pi.Term())
 
+	u, ok := p.(*userDefined)
+	if site == siteLast && (!ok || u.tabled) {
+		// Last-call optimization: a user-defined procedure runs the pending wakeups at the exit of its clauses, so the
+		// continuation of the caller is passed as is and deep recursions don't nest continuations.
+		k = vm.exit(k)
+	}
+
+	traced := vm.isTraced(pi)
+	if site == siteHost || !ok || u.tabled || traced {
+		var fpi procedureIndicator
+		if !ok {
+			fpi = pi
+		}
+		k, env = vm.enterForeign(fpi, args, k, env)
+	}
+
+	if vm.slicing != nil {
+		vm.slicing.called(pi)
+	}
+
+	call := vm.callProcedure
+	if vm.profiling {
+		call = vm.profileCall
+	}
+
+	if vm.covering && ok && m == atomUser {
+		promise = vm.coverCall(pi, call, p, args, traced, k, env)
+	} else {
+		promise = call(pi, p, args, traced, k, env)
+	}
+	if query && vm.metrics != nil {
+		// Count the promises of the query once it's forced.
+		q := promise
+		return Delay(func(ctx context.Context) *Promise {
+			scratchpadOf(ctx).metrics = vm.metrics
+			return q
+		})
+	}
+	return promise
+}
+
+// procedureCaller is a function which calls a procedure, e.g. VM.callProcedure.
+type procedureCaller func(pi procedureIndicator, p procedure, args []Term, traced bool, k Cont, env *Env) *Promise
+
+// callProcedure calls p observed by the port hook and the tracer if they're enabled.
+func (vm *VM) callProcedure(pi procedureIndicator, p procedure, args []Term, traced bool, k Cont, env *Env) *Promise {
+	if vm.debugging() {
+		return vm.debugCall(pi, p, args, traced, k, env)
+	}
+
+	if traced {
+		return vm.traceCall(pi, p, args, k, env)
+	}
+
 	return p.call(vm, args, k, env)
 }
 
-func (vm *VM) exec(pc bytecode, vars []Variable, cont Cont, args []Term, astack [][]Term, env *Env, cutParent *Promise) *Promise {
+func (vm *VM) exec(pc bytecode, vars []Variable, cont Cont, args []Term, astack [][]Term, env *Env, cutParent *Promise) (promise *Promise) {
 	var (
 		ok  = true
 		op  instruction
 		arg Term
 	)
+	if vm.auditing() {
+		defer recoverExec(&promise, &op)
+	}
 	for ok {
 		op, pc = pc[0], pc[1:]
-		vm.charge(MeterInstruction, 1, env)
-		if vm.hook != nil {
-			if err := vm.hook(op.opcode, op.operand, env); err != nil {
-				return Error(err)
-			}
+		if err := vm.step(op, env); err != nil {
+			return Error(err)
 		}
 
 		switch opcode, operand := op.opcode, op.operand; opcode {
@@ -299,14 +584,27 @@ func (vm *VM) exec(pc bytecode, vars []Variable, cont Cont, args []Term, astack
 		case OpPop:
 			args, astack = astack[len(astack)-1], astack[:len(astack)-1]
 		case OpEnter:
-			break
+			if env.hasWakeups() {
+				return vm.wakeUp(func(env *Env) *Promise {
+					return vm.exec(pc, vars, cont, args, astack, env, cutParent)
+				}, env)
+			}
 		case OpCall:
 			pi := operand.(procedureIndicator)
-			return vm.Arrive(pi.name, args, func(env *Env) *Promise {
+			if pc[0].opcode == OpExit {
+				// Last call: the exit of the clause is executed along with the call so that the callee continues
+				// with cont directly.
+				op = pc[0]
+				if err := vm.step(op, env); err != nil {
+					return Error(err)
+				}
+				return vm.arrive(pi.name, args, cont, siteLast, vm.lastCall(args, env))
+			}
+			return vm.arrive(pi.name, args, func(env *Env) *Promise {
 				return vm.exec(pc, vars, cont, nil, nil, env, cutParent)
-			}, env)
+			}, siteBody, env)
 		case OpExit:
-			return cont(env)
+			return vm.wakeUp(cont, vm.exitClause(env))
 		case OpCut:
 			return cut(cutParent, func(context.Context) *Promise {
 				return vm.exec(pc, vars, cont, args, astack, env, cutParent)
@@ -365,6 +663,18 @@ func (vm *VM) exec(pc bytecode, vars []Variable, cont Cont, args []Term, astack
 	return Bool(false)
 }
 
+// step accounts for the execution of op.
+func (vm *VM) step(op instruction, env *Env) error {
+	vm.charge(MeterInstruction, 1, env)
+	if n := atomic.AddUint64(&vm.instructions, 1); vm.metrics != nil && n%metricsFlushInterval == 0 {
+		vm.FlushMetrics()
+	}
+	if vm.hook != nil {
+		return vm.hook(op.opcode, op.operand, env)
+	}
+	return nil
+}
+
 // SetUserInput sets the given stream as user_input.
 func (vm *VM) SetUserInput(s *Stream) {
 	s.vm = vm
@@ -418,6 +728,19 @@ func (vm *VM) InstallMeter(f MeterFunc) {
 	vm.meter = f
 }
 
+// gasMeter returns the meter function of the VM which also adds the units charged to the ones of the VM, or nil.
+// Since clones share the meter function, the running VM passes its own to the queries it runs.
+func (vm *VM) gasMeter() MeterFunc {
+	f := vm.meter
+	if f == nil {
+		return nil
+	}
+	return func(kind MeterKind, units uint64) Term {
+		atomic.AddUint64(&vm.gasUsed, units)
+		return f(kind, units)
+	}
+}
+
 // ClearMeter removes the installed meter function from the VM.
 func (vm *VM) ClearMeter() {
 	vm.meter = nil
@@ -460,17 +783,75 @@ func (vm *VM) getOperators() *operators {
 }
 
 func (vm *VM) charge(kind MeterKind, units uint64, env *Env) {
+	if vm.meter == nil {
+		return
+	}
+	atomic.AddUint64(&vm.gasUsed, units)
 	chargeMeter(vm.meter, kind, units, env)
 }
 
 func (vm *VM) prepareEnv(env *Env) *Env {
-	if vm.meter == nil {
-		return env
+	if vm.meter != nil && (env == nil || env.meter == nil) {
+		env = env.withMeter(vm.gasMeter())
 	}
-	if env != nil && env.meter != nil {
-		return env
+	// Take a snapshot of the default query flags so that the query isn't affected by the later changes to them.
+	if env == nil || env.flags == nil {
+		f := vm.flags(env)
+		env = env.withFlags(&f)
 	}
-	return env.withMeter(vm.meter)
+	return env
+}
+
+// attrUnifyHook is called after an attributed variable with the attribute value got bound to other.
+// Attribute modules without such a hook are handled by calling Module:attr_unify_hook(Value, Other).
+type attrUnifyHook func(vm *VM, value, other Term, k Cont, env *Env) *Promise
+
+// attrUnifyHooks are the unification hooks of the attribute modules implemented by the engine.
+var attrUnifyHooks map[Atom]attrUnifyHook
+
+// exit returns k preceded by the wakeups pending at the exit of a clause.
+func (vm *VM) exit(k Cont) Cont {
+	return func(env *Env) *Promise {
+		return vm.wakeUp(k, env)
+	}
+}
+
+// wakeUp runs the unification hooks of the attributed variables which got bound since the last wake-up.
+func (vm *VM) wakeUp(k Cont, env *Env) *Promise {
+	ws, env := env.popWakeups()
+	if len(ws) == 0 {
+		return k(env)
+	}
+	return vm.runWakeups(ws, k, env)
+}
+
+func (vm *VM) runWakeups(ws []wakeup, k Cont, env *Env) *Promise {
+	if len(ws) == 0 {
+		// The hooks might have bound other attributed variables.
+		return vm.wakeUp(k, env)
+	}
+	w := ws[0]
+	return vm.runAttrUnifyHooks(w.attrs, w.value, func(env *Env) *Promise {
+		return vm.runWakeups(ws[1:], k, env)
+	}, env)
+}
+
+func (vm *VM) runAttrUnifyHooks(attrs list, other Term, k Cont, env *Env) *Promise {
+	if len(attrs) == 0 {
+		return k(env)
+	}
+	a := attrs[0].(Compound)
+	next := func(env *Env) *Promise {
+		return vm.runAttrUnifyHooks(attrs[1:], other, k, env)
+	}
+	module := a.Arg(0).(Atom)
+	if hook, ok := attrUnifyHooks[module]; ok {
+		return hook(vm, a.Arg(1), other, next, env)
+	}
+	if vm == nil {
+		return next(env)
+	}
+	return Call(vm, atomColon.Apply(module, atomAttrUnifyHook.Apply(a.Arg(1), other)), next, env)
 }
 
 // Predicate0 is a predicate of arity 0.
@@ -572,6 +953,13 @@ func (p Predicate8) call(vm *VM, args []Term, k Cont, env *Env) *Promise {
 	return p(vm, args[0], args[1], args[2], args[3], args[4], args[5], args[6], args[7], k, env)
 }
 
+// PredicateN is a predicate of variable arity.
+type PredicateN func(*VM, []Term, Cont, *Env) *Promise
+
+func (p PredicateN) call(vm *VM, args []Term, k Cont, env *Env) *Promise {
+	return p(vm, args, k, env)
+}
+
 // procedureIndicator identifies a procedure e.g. (=)/2.
 type procedureIndicator struct {
 	name  Atom
@@ -623,6 +1011,85 @@ func (p procedureIndicator) Apply(args ...Term) (Term, error) {
 	return p.name.Apply(args...), nil
 }
 
+// PI returns the predicate indicator name/arity.
+func PI(name string, arity int) Term {
+	return procedureIndicator{name: NewAtom(name), arity: Integer(arity)}
+}
+
+// ParsePI parses a predicate indicator such as foo/2, '=..'/2 or (=)/2.
+// A name which is neither quoted nor parenthesized is taken verbatim so that =/2 or \+/1 are accepted too.
+func ParsePI(s string) (Term, error) {
+	i := strings.LastIndex(s, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %q", errInvalidPI, s)
+	}
+	name, a := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	arity, err := strconv.Atoi(a)
+	if err != nil || arity < 0 {
+		return nil, fmt.Errorf("%w: %q", errInvalidPI, s)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%w: %q", errInvalidPI, s)
+	}
+	if name[0] == '\'' || name[0] == '(' {
+		var vm VM
+		t, err := NewParser(&vm, strings.NewReader(name+".")).Term()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", errInvalidPI, s)
+		}
+		n, ok := t.(Atom)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", errInvalidPI, s)
+		}
+		name = n.String()
+	}
+	return PI(name, arity), nil
+}
+
+// FormatPI returns the textual representation of the predicate indicator pi e.g. '=..'/2.
+func FormatPI(pi Term) (string, error) {
+	p, err := toProcedureIndicator(pi, nil)
+	if err != nil {
+		return "", err
+	}
+	return p.String(), nil
+}
+
+var errInvalidPI = errors.New("invalid predicate indicator")
+
+// toProcedureIndicator converts a predicate indicator term name/arity to procedureIndicator.
+func toProcedureIndicator(pi Term, env *Env) (procedureIndicator, error) {
+	switch pi := env.Resolve(pi).(type) {
+	case Variable:
+		return procedureIndicator{}, InstantiationError(env)
+	case Compound:
+		if pi.Functor() != atomSlash || pi.Arity() != 2 {
+			return procedureIndicator{}, typeError(validTypePredicateIndicator, pi, env)
+		}
+
+		switch name := env.Resolve(pi.Arg(0)).(type) {
+		case Variable:
+			return procedureIndicator{}, InstantiationError(env)
+		case Atom:
+			switch arity := env.Resolve(pi.Arg(1)).(type) {
+			case Variable:
+				return procedureIndicator{}, InstantiationError(env)
+			case Integer:
+				if arity < 0 {
+					return procedureIndicator{}, domainError(validDomainNotLessThanZero, arity, env)
+				}
+				return procedureIndicator{name: name, arity: arity}, nil
+			default:
+				return procedureIndicator{}, typeError(validTypeInteger, arity, env)
+			}
+		default:
+			return procedureIndicator{}, typeError(validTypeAtom, name, env)
+		}
+	default:
+		return procedureIndicator{}, typeError(validTypePredicateIndicator, pi, env)
+	}
+}
+
 func piArg(t Term, env *Env) (procedureIndicator, func(int) Term, error) {
 	switch f := env.Resolve(t).(type) {
 	case Variable:
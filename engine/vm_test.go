@@ -3,6 +3,7 @@ package engine
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"os"
 	"testing"
 
@@ -191,6 +192,55 @@ func TestVM_Register8(t *testing.T) {
 	})
 }
 
+func TestVM_RegisterVariadic(t *testing.T) {
+	var vm VM
+	vm.RegisterVariadic(NewAtom("foo"), 2, 3, func(_ *VM, args []Term, k Cont, env *Env) *Promise {
+		if len(args) == 3 {
+			return Unify(&vm, args[2], Integer(len(args)), k, env)
+		}
+		return k(env)
+	})
+
+	t.Run("registered arities", func(t *testing.T) {
+		for _, arity := range []Integer{2, 3} {
+			_, ok := vm.procedures.Get(procedureIndicator{name: NewAtom("foo"), arity: arity})
+			assert.True(t, ok)
+		}
+		for _, arity := range []Integer{1, 4} {
+			_, ok := vm.procedures.Get(procedureIndicator{name: NewAtom("foo"), arity: arity})
+			assert.False(t, ok)
+		}
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		p, _ := vm.procedures.Get(procedureIndicator{name: NewAtom("foo"), arity: 3})
+		ok, err := p.call(&vm, []Term{NewAtom("a"), NewAtom("b"), Integer(3)}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = p.call(&vm, []Term{NewAtom("a"), NewAtom("b"), Integer(2)}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestVM_RegisterFlagPredicate(t *testing.T) {
+	var vm VM
+	enabled := false
+	vm.RegisterFlagPredicate(NewAtom("enabled"), func() bool {
+		return enabled
+	})
+
+	ok, err := vm.Arrive(NewAtom("enabled"), nil, Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	enabled = true
+	ok, err = vm.Arrive(NewAtom("enabled"), nil, Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
 func TestVM_Arrive(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		vm := VM{
@@ -252,7 +302,7 @@ func TestVM_Arrive(t *testing.T) {
 func TestVM_open_nilFS(t *testing.T) {
 	var vm VM
 	env := NewEnv()
-	_, _, err := vm.open(NewAtom("foo"), env)
+	_, _, err := vm.open(context.Background(), NewAtom("foo"), env)
 	assert.Equal(t, permissionError(operationOpen, permissionTypeSourceSink, NewAtom("foo"), env), err)
 }
 
@@ -332,6 +382,9 @@ func TestProcedureIndicator_Apply(t *testing.T) {
 func TestVM_ResetEnv(t *testing.T) {
 	var vm VM
 	vm.SetMaxVariables(20)
+	t.Cleanup(func() {
+		maxVariables = 0
+	})
 
 	varCounter.count = 10
 	varContext = NewVariable()
@@ -434,3 +487,134 @@ func TestInstruction_String(t *testing.T) {
 		assert.Equal(t, expected, instr.String())
 	})
 }
+
+func TestPI(t *testing.T) {
+	assert.Equal(t, procedureIndicator{name: NewAtom("foo"), arity: 2}, PI("foo", 2))
+}
+
+func TestParsePI(t *testing.T) {
+	tests := []struct {
+		s   string
+		pi  Term
+		err bool
+	}{
+		{s: "foo/2", pi: PI("foo", 2)},
+		{s: " foo / 0 ", pi: PI("foo", 0)},
+		{s: "=../2", pi: PI("=..", 2)},
+		{s: `\+/1`, pi: PI(`\+`, 1)},
+		{s: "'hello world'/1", pi: PI("hello world", 1)},
+		{s: "'/'/2", pi: PI("/", 2)},
+		{s: "(=)/2", pi: PI("=", 2)},
+		{s: "foo", err: true},
+		{s: "/2", err: true},
+		{s: "foo/bar", err: true},
+		{s: "foo/-1", err: true},
+		{s: "'foo/1", err: true},
+		{s: "(foo(a))/1", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			pi, err := ParsePI(tt.s)
+			if tt.err {
+				assert.ErrorIs(t, err, errInvalidPI)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.pi, pi)
+		})
+	}
+}
+
+func TestFormatPI(t *testing.T) {
+	tests := []struct {
+		pi  Term
+		s   string
+		err error
+	}{
+		{pi: PI("foo", 2), s: "foo/2"},
+		{pi: atomSlash.Apply(NewAtom("=.."), Integer(2)), s: "=../2"},
+		{pi: PI("hello world", 1), s: "'hello world'/1"},
+		{pi: NewAtom("foo"), err: typeError(validTypePredicateIndicator, NewAtom("foo"), nil)},
+		{pi: atomSlash.Apply(NewAtom("foo"), Integer(-1)), err: domainError(validDomainNotLessThanZero, Integer(-1), nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			s, err := FormatPI(tt.pi)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.s, s)
+		})
+	}
+}
+
+func TestVM_MarkBuiltIn(t *testing.T) {
+	var vm VM
+	vm.MarkBuiltIn()
+	assert.NoError(t, vm.Compile(context.Background(), `foo.`))
+	vm.MarkBuiltIn()
+	assert.NoError(t, vm.Compile(context.Background(), `bar.`))
+
+	p, _ := vm.getProcedure(procedureIndicator{name: NewAtom("foo"), arity: 0})
+	assert.True(t, p.(*userDefined).builtIn)
+	p, _ = vm.getProcedure(procedureIndicator{name: NewAtom("bar"), arity: 0})
+	assert.False(t, p.(*userDefined).builtIn)
+}
+
+func TestVM_Unregister(t *testing.T) {
+	var vm VM
+	vm.Register4(NewAtom("open"), Open)
+
+	assert.NoError(t, vm.Unregister(PI("open", 4)))
+	_, ok := vm.getProcedure(procedureIndicator{name: NewAtom("open"), arity: 4})
+	assert.False(t, ok)
+
+	assert.Equal(t, existenceError(objectTypeProcedure, PI("open", 4).(procedureIndicator).Term(), nil), vm.Unregister(PI("open", 4)))
+	assert.Equal(t, typeError(validTypePredicateIndicator, NewAtom("open"), nil), vm.Unregister(NewAtom("open")))
+}
+
+func TestVM_WrapPredicate(t *testing.T) {
+	var vm VM
+	vm.Register1(NewAtom("foo"), func(_ *VM, a Term, k Cont, env *Env) *Promise {
+		return Unify(nil, a, NewAtom("a"), k, env)
+	})
+	vm.procedures.Set(procedureIndicator{name: NewAtom("bar"), arity: 0}, &userDefined{})
+
+	var calls int
+	assert.NoError(t, vm.WrapPredicate(PI("foo", 1), func(next PredicateN) PredicateN {
+		return func(vm *VM, args []Term, k Cont, env *Env) *Promise {
+			calls++
+			return next(vm, args, k, env)
+		}
+	}))
+	p, _ := vm.getProcedure(procedureIndicator{name: NewAtom("foo"), arity: 1})
+
+	ok, err := p.call(&vm, []Term{NewAtom("a")}, Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = p.call(&vm, []Term{NewAtom("b")}, Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 2, calls)
+
+	t.Run("nil wrapper keeps the original", func(t *testing.T) {
+		assert.NoError(t, vm.WrapPredicate(PI("foo", 1), func(PredicateN) PredicateN {
+			return nil
+		}))
+		q, _ := vm.getProcedure(procedureIndicator{name: NewAtom("foo"), arity: 1})
+		assert.Equal(t, fmt.Sprintf("%p", p), fmt.Sprintf("%p", q))
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		assert.Equal(t, existenceError(objectTypeProcedure, atomSlash.Apply(NewAtom("baz"), Integer(0)), nil), vm.WrapPredicate(PI("baz", 0), func(next PredicateN) PredicateN {
+			return next
+		}))
+	})
+
+	t.Run("user defined", func(t *testing.T) {
+		assert.ErrorIs(t, vm.WrapPredicate(PI("bar", 0), func(next PredicateN) PredicateN {
+			return next
+		}), errNotForeign)
+	})
+}
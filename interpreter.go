@@ -2,10 +2,12 @@ package prolog
 
 import (
 	"context"
+	"crypto/sha256"
 	_ "embed" // for go:embed
 	"errors"
 	"io"
 	"io/fs"
+	"log/slog"
 	"os"
 	"strings"
 
@@ -18,6 +20,9 @@ var bootstrap string
 // Interpreter is a Prolog interpreter.
 type Interpreter struct {
 	engine.VM
+
+	// CompileCache, if set, caches the Prolog texts executed by Exec and ExecContext without arguments.
+	CompileCache *CompileCache
 }
 
 // NewEmpty creates a new Prolog interpreter without any predicates/operators defined.
@@ -50,12 +55,14 @@ func New(in io.Reader, out io.Writer) *Interpreter {
 	i.Register1(engine.NewAtom("atom"), engine.TypeAtom)
 	i.Register1(engine.NewAtom("integer"), engine.TypeInteger)
 	i.Register1(engine.NewAtom("float"), engine.TypeFloat)
+	i.Register1(engine.NewAtom("rational"), engine.TypeRational)
 	i.Register1(engine.NewAtom("compound"), engine.TypeCompound)
 	i.Register1(engine.NewAtom("acyclic_term"), engine.AcyclicTerm)
 
 	// Term comparison
 	i.Register3(engine.NewAtom("compare"), engine.Compare)
 	i.Register2(engine.NewAtom("sort"), engine.Sort)
+	i.Register2(engine.NewAtom("msort"), engine.MSort)
 	i.Register2(engine.NewAtom("keysort"), engine.KeySort)
 
 	// Term creation and decomposition
@@ -65,12 +72,23 @@ func New(in io.Reader, out io.Writer) *Interpreter {
 	i.Register2(engine.NewAtom("copy_term"), engine.CopyTerm)
 	i.Register2(engine.NewAtom("term_variables"), engine.TermVariables)
 
+	// Query scoped global variables
+	i.Register2(engine.NewAtom("q_setval"), engine.QSetval)
+	i.Register2(engine.NewAtom("q_getval"), engine.QGetval)
+
+	// Global variables
+	i.Register2(engine.NewAtom("b_setval"), engine.BSetval)
+	i.Register2(engine.NewAtom("b_getval"), engine.BGetval)
+	i.Register2(engine.NewAtom("nb_setval"), engine.NbSetval)
+	i.Register2(engine.NewAtom("nb_getval"), engine.NbGetval)
+
 	// Dicts operator
 	i.Register3(engine.NewAtom("."), engine.Op3)
 	i.Register3(engine.NewAtom("get_dict"), engine.GetDict3)
 	i.Register4(engine.NewAtom("get_dict"), engine.GetDict4)
 	i.Register3(engine.NewAtom("put_dict"), engine.PutDict3)
 	i.Register4(engine.NewAtom("del_dict"), engine.DelDict4)
+	i.Register2(engine.NewAtom("dict_compound"), engine.DictCompound)
 
 	// Arithmetic evaluation
 	i.Register2(engine.NewAtom("is"), engine.Is)
@@ -85,13 +103,23 @@ func New(in io.Reader, out io.Writer) *Interpreter {
 
 	// Clause retrieval and information
 	i.Register2(engine.NewAtom("clause"), engine.Clause)
+	i.Register3(engine.NewAtom("clause"), engine.Clause3)
 	i.Register1(engine.NewAtom("current_predicate"), engine.CurrentPredicate)
+	i.Register2(engine.NewAtom("predicate_property"), engine.PredicateProperty)
 
 	// Clause creation and destruction
 	i.Register1(engine.NewAtom("asserta"), engine.Asserta)
 	i.Register1(engine.NewAtom("assertz"), engine.Assertz)
+	i.Register2(engine.NewAtom("asserta"), engine.Asserta2)
+	i.Register2(engine.NewAtom("assertz"), engine.Assertz2)
 	i.Register1(engine.NewAtom("retract"), engine.Retract)
+	i.Register1(engine.NewAtom("retractall"), engine.RetractAll)
+	i.Register1(engine.NewAtom("erase"), engine.Erase)
+	i.Register3(engine.NewAtom("recorda"), engine.RecordA)
+	i.Register3(engine.NewAtom("recordz"), engine.RecordZ)
+	i.Register3(engine.NewAtom("recorded"), engine.Recorded)
 	i.Register1(engine.NewAtom("abolish"), engine.Abolish)
+	i.Register0(engine.NewAtom("garbage_collect_clauses"), engine.GarbageCollectClauses)
 
 	// All solutions
 	i.Register3(engine.NewAtom("findall"), engine.FindAll)
@@ -108,6 +136,11 @@ func New(in io.Reader, out io.Writer) *Interpreter {
 	i.Register1(engine.NewAtom("flush_output"), engine.FlushOutput)
 	i.Register2(engine.NewAtom("stream_property"), engine.StreamProperty)
 	i.Register2(engine.NewAtom("set_stream_position"), engine.SetStreamPosition)
+	i.Register2(engine.NewAtom("line_count"), engine.LineCount)
+	i.Register2(engine.NewAtom("line_position"), engine.LinePosition)
+	i.Register2(engine.NewAtom("character_count"), engine.CharacterCount)
+	i.Register2(engine.NewAtom("open_string"), engine.OpenString)
+	i.Register2(engine.NewAtom("with_output_to"), engine.WithOutputTo)
 
 	// Character input/output
 	i.Register2(engine.NewAtom("get_char"), engine.GetChar)
@@ -122,6 +155,7 @@ func New(in io.Reader, out io.Writer) *Interpreter {
 	// Term input/output
 	i.Register3(engine.NewAtom("read_term"), engine.ReadTerm)
 	i.Register3(engine.NewAtom("write_term"), engine.WriteTerm)
+	i.Register3(engine.NewAtom("format"), engine.Format)
 	i.Register3(engine.NewAtom("op"), engine.Op)
 	i.Register3(engine.NewAtom("current_op"), engine.CurrentOp)
 	i.Register2(engine.NewAtom("char_conversion"), engine.CharConversion)
@@ -148,28 +182,131 @@ func New(in io.Reader, out io.Writer) *Interpreter {
 	i.Register2(engine.NewAtom("number_chars"), engine.NumberChars)
 	i.Register2(engine.NewAtom("number_codes"), engine.NumberCodes)
 
+	// Strings
+	i.Register1(engine.NewAtom("string"), engine.TypeString)
+	i.Register2(engine.NewAtom("string_length"), engine.StringLength)
+	i.Register3(engine.NewAtom("string_concat"), engine.StringConcat)
+	i.Register2(engine.NewAtom("string_chars"), engine.StringChars)
+	i.Register2(engine.NewAtom("string_codes"), engine.StringCodes)
+	i.Register2(engine.NewAtom("string_to_atom"), engine.StringToAtom)
+	i.Register4(engine.NewAtom("split_string"), engine.SplitString)
+	i.Register5(engine.NewAtom("sub_string"), engine.SubString)
+	i.Register1(engine.NewAtom("string_builder_new"), engine.StringBuilderNew)
+	i.Register2(engine.NewAtom("string_builder_append"), engine.StringBuilderAppend)
+	i.Register2(engine.NewAtom("string_builder_to_atom"), engine.StringBuilderToAtom)
+
+	// Bags
+	i.Register1(engine.NewAtom("bag_create"), engine.BagCreate)
+	i.Register2(engine.NewAtom("bag_add"), engine.BagAdd)
+	i.Register2(engine.NewAtom("bag_min"), engine.BagMin)
+	i.Register2(engine.NewAtom("bag_to_list"), engine.BagToList)
+
 	// Implementation defined hooks
 	i.Register2(engine.NewAtom("set_prolog_flag"), engine.SetPrologFlag)
 	i.Register2(engine.NewAtom("current_prolog_flag"), engine.CurrentPrologFlag)
 	i.Register1(engine.NewAtom("halt"), engine.Halt)
+	i.Register2(engine.NewAtom("print_message"), engine.PrintMessage)
+	i.Register3(engine.NewAtom("message_to_codes"), engine.MessageToCodes)
+	i.Register2(engine.NewAtom("log"), engine.Log2)
+	i.Register3(engine.NewAtom("log"), engine.Log3)
+	i.Register0(engine.NewAtom("trace"), engine.Trace)
+	i.Register0(engine.NewAtom("notrace"), engine.NoTrace)
+	i.Register1(engine.NewAtom("spy"), engine.Spy)
+	i.Register1(engine.NewAtom("nospy"), engine.NoSpy)
 
 	// Consult
 	i.Register1(engine.NewAtom("consult"), engine.Consult)
+	i.Register2(engine.NewAtom("load_files"), engine.LoadFiles)
+	i.Register1(engine.NewAtom("source_file"), engine.SourceFile)
+	i.Register2(engine.NewAtom("source_file"), engine.SourceFile2)
+	i.Register2(engine.NewAtom("loaded_file_hash"), engine.LoadedFileHash)
+
+	// Modules
+	i.Register1(engine.NewAtom("use_module"), engine.UseModule)
+	i.Register2(engine.NewAtom("use_module"), engine.UseModule2)
 
 	// Definite clause grammar
 	i.Register3(engine.NewAtom("phrase"), engine.Phrase)
 	i.Register2(engine.NewAtom("expand_term"), engine.ExpandTerm)
+	i.Register2(engine.NewAtom("expand_goal"), engine.ExpandGoal)
 
 	// Prolog prologue
 	i.Register3(engine.NewAtom("append"), engine.Append)
 	i.Register2(engine.NewAtom("length"), engine.Length)
 	i.Register3(engine.NewAtom("between"), engine.Between)
 	i.Register2(engine.NewAtom("succ"), engine.Succ)
+	i.Register3(engine.NewAtom("plus"), engine.Plus)
 	i.Register3(engine.NewAtom("nth0"), engine.Nth0)
 	i.Register3(engine.NewAtom("nth1"), engine.Nth1)
 	i.Register2(engine.NewAtom("call_nth"), engine.CallNth)
+	i.Register2(engine.NewAtom("forall"), engine.ForAll)
+	i.Register2(engine.NewAtom("maplist"), engine.MapList2)
+	i.Register3(engine.NewAtom("maplist"), engine.MapList3)
+	i.Register4(engine.NewAtom("maplist"), engine.MapList4)
+	i.Register5(engine.NewAtom("maplist"), engine.MapList5)
+	i.Register6(engine.NewAtom("maplist"), engine.MapList6)
+	i.Register7(engine.NewAtom("maplist"), engine.MapList7)
+	i.Register4(engine.NewAtom("foldl"), engine.FoldL4)
+	i.Register5(engine.NewAtom("foldl"), engine.FoldL5)
+	i.Register6(engine.NewAtom("foldl"), engine.FoldL6)
+	i.Register3(engine.NewAtom("include"), engine.Include)
+	i.Register3(engine.NewAtom("exclude"), engine.Exclude)
+	i.RegisterVariadic(engine.NewAtom(">>"), 2, 9, engine.Lambda)
+
+	// Pairs
+	i.Register3(engine.NewAtom("pairs_keys_values"), engine.PairsKeysValues)
+	i.Register2(engine.NewAtom("pairs_keys"), engine.PairsKeys)
+	i.Register2(engine.NewAtom("pairs_values"), engine.PairsValues)
+	i.Register2(engine.NewAtom("transpose_pairs"), engine.TransposePairs)
+
+	// Random
+	i.Register1(engine.NewAtom("set_random"), engine.SetRandom)
+	i.Register3(engine.NewAtom("random_between"), engine.RandomBetween)
+	i.Register2(engine.NewAtom("random_member"), engine.RandomMember)
+
+	// Encoding
+	i.Register2(engine.NewAtom("hex_bytes"), engine.HexBytes)
+	i.Register2(engine.NewAtom("base64"), engine.Base64)
+	i.Register2(engine.NewAtom("base64url"), engine.Base64URL)
+	i.Register3(engine.NewAtom("bech32_address"), engine.Bech32Address)
+	i.Register2(engine.NewAtom("term_to_cbor"), engine.TermToCBOR)
+
+	// JSON
+	i.Register3(engine.NewAtom("json_read"), engine.JSONRead)
+	i.Register3(engine.NewAtom("json_write"), engine.JSONWrite)
+	i.Register3(engine.NewAtom("json_read_dict"), engine.JSONReadDict)
+	i.Register3(engine.NewAtom("json_write_dict"), engine.JSONWriteDict)
+
+	// Statistics
+	i.Register1(engine.NewAtom("time"), engine.Time)
+	i.Register2(engine.NewAtom("time"), engine.Time2)
+	i.Register1(engine.NewAtom("get_time"), engine.GetTime)
+	i.Register1(engine.NewAtom("profile"), engine.Profile1)
+	i.Register2(engine.NewAtom("statistics"), engine.Statistics)
+
+	// Tabling
+	i.Register0(engine.NewAtom("abolish_all_tables"), engine.AbolishAllTables)
+
+	// Attributed variables
+	i.Register3(engine.NewAtom("put_attr"), engine.PutAttr)
+	i.Register3(engine.NewAtom("get_attr"), engine.GetAttr)
+	i.Register2(engine.NewAtom("del_attr"), engine.DelAttr)
+	i.Register1(engine.NewAtom("attvar"), engine.AttVar)
+
+	// Finite domain constraints
+	i.Register2(engine.NewAtom("#="), engine.FDEqual)
+	i.Register2(engine.NewAtom(`#\=`), engine.FDNotEqual)
+	i.Register2(engine.NewAtom("#<"), engine.FDLessThan)
+	i.Register2(engine.NewAtom("#=<"), engine.FDLessThanOrEqual)
+	i.Register2(engine.NewAtom("#>"), engine.FDGreaterThan)
+	i.Register2(engine.NewAtom("#>="), engine.FDGreaterThanOrEqual)
+	i.Register2(engine.NewAtom("in"), engine.FDIn)
+	i.Register2(engine.NewAtom("ins"), engine.FDIns)
+	i.Register1(engine.NewAtom("all_different"), engine.FDAllDifferent)
+	i.Register1(engine.NewAtom("label"), engine.FDLabel)
 
 	_ = i.Exec(bootstrap)
+	i.MarkBuiltIn()
 
 	return &i
 }
@@ -181,7 +318,29 @@ func (i *Interpreter) Exec(query string, args ...interface{}) error {
 
 // ExecContext executes a prolog program with context.
 func (i *Interpreter) ExecContext(ctx context.Context, query string, args ...interface{}) error {
-	return i.Compile(ctx, query, args...)
+	c := i.CompileCache
+	if c == nil || len(args) > 0 {
+		return i.Compile(ctx, query, args...)
+	}
+
+	key := sha256.Sum256([]byte(query))
+	if p, ok := c.get(key); ok {
+		err := i.LoadProgram(ctx, p)
+		if !errors.Is(err, engine.ErrProgramMismatch) {
+			c.count(true)
+			return err
+		}
+	}
+
+	c.count(false)
+	p, err := i.CompileProgram(ctx, query)
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		c.add(key, p)
+	}
+	return nil
 }
 
 // Query executes a prolog query and returns *Solutions.
@@ -217,12 +376,33 @@ func (i *Interpreter) QueryContext(ctx context.Context, query string, args ...in
 		if !<-more {
 			return
 		}
-		if _, err := engine.Call(&i.VM, t, func(env *engine.Env) *engine.Promise {
+
+		ctx, span := i.StartSpan(ctx, engine.SpanQuery, slog.String(engine.AttrQuery, query))
+		defer span.End()
+
+		logger := i.Logger()
+		logger.LogAttrs(ctx, slog.LevelDebug, "query started", slog.String("query", query))
+		var (
+			start = i.Now()
+			gas   = i.GasUsed()
+			n     int
+		)
+		_, err := engine.Call(&i.VM, t, func(env *engine.Env) *engine.Promise {
+			n++
 			next <- env
 			return engine.Bool(!<-more)
-		}, env).Force(ctx); err != nil {
+		}, env).Force(ctx)
+		if err != nil {
+			err = i.AttachGoalHistory(err)
 			sols.err = err
+			span.RecordError(err)
+		}
+		span.SetAttributes(slog.Int(engine.AttrSolutions, n), slog.Uint64(engine.AttrGasUsed, i.GasUsed()-gas))
+		attrs := []slog.Attr{slog.String("query", query), slog.Int("solutions", n), slog.Duration("duration", i.Now().Sub(start))}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
 		}
+		logger.LogAttrs(ctx, slog.LevelDebug, "query finished", attrs...)
 	}()
 
 	return &sols, nil
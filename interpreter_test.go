@@ -6,9 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/axone-protocol/prolog/v3/engine"
@@ -19,6 +23,14 @@ func TestNew(t *testing.T) {
 	i := New(nil, nil)
 	assert.NotNil(t, i)
 
+	t.Run("predicate_property", func(t *testing.T) {
+		p := New(nil, nil)
+		assert.NoError(t, p.Exec(`foo.`))
+		assert.NoError(t, p.QuerySolution(`predicate_property((_, _), built_in), predicate_property(once(_), built_in).`).Err())
+		assert.NoError(t, p.QuerySolution(`\+ predicate_property(once(_), number_of_clauses(_)).`).Err())
+		assert.NoError(t, p.QuerySolution(`\+ predicate_property(foo, built_in), predicate_property(foo, number_of_clauses(1)).`).Err())
+	})
+
 	t.Run("number_chars", func(t *testing.T) {
 		// http://www.complang.tuwien.ac.at/ulrich/iso-prolog/number_chars
 		p := New(nil, nil)
@@ -903,6 +915,80 @@ foo(a, 1, 2.0, [abc, def]).
 	}
 }
 
+type querySpan struct {
+	attrs []slog.Attr
+	ended chan struct{}
+}
+
+func (s *querySpan) Start(ctx context.Context, _ string, attrs ...slog.Attr) (context.Context, engine.Span) {
+	s.attrs = append(s.attrs, attrs...)
+	return ctx, s
+}
+
+func (s *querySpan) SetAttributes(attrs ...slog.Attr) {
+	s.attrs = append(s.attrs, attrs...)
+}
+
+func (s *querySpan) RecordError(error) {}
+
+func (s *querySpan) End() {
+	close(s.ended)
+}
+
+func TestInterpreter_Query_tracer(t *testing.T) {
+	span := querySpan{ended: make(chan struct{})}
+	i := New(nil, nil)
+	i.SetTracer(&span)
+	i.InstallMeter(func(engine.MeterKind, uint64) engine.Term {
+		return nil
+	})
+
+	sols, err := i.Query(`member(X, [a, b]).`)
+	assert.NoError(t, err)
+	for sols.Next() {
+	}
+	assert.NoError(t, sols.Close())
+	<-span.ended
+
+	assert.Len(t, span.attrs, 3)
+	assert.Equal(t, "prolog.query=member(X, [a, b]).", span.attrs[0].String())
+	assert.Equal(t, "prolog.solutions=2", span.attrs[1].String())
+	assert.Equal(t, engine.AttrGasUsed, span.attrs[2].Key)
+	assert.NotZero(t, span.attrs[2].Value.Uint64())
+}
+
+func TestInterpreter_Query_log(t *testing.T) {
+	var buf bytes.Buffer
+	span := querySpan{ended: make(chan struct{})}
+	i := New(nil, nil)
+	i.SetTracer(&span)
+	i.SetLogger(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	now := time.Unix(0, 0)
+	i.Clock = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	sols, err := i.Query(`true.`)
+	assert.NoError(t, err)
+	for sols.Next() {
+	}
+	assert.NoError(t, sols.Close())
+	<-span.ended
+
+	assert.Equal(t, `level=DEBUG msg="query started" query=true.
+level=DEBUG msg="query finished" query=true. solutions=1 duration=1s
+`, buf.String())
+}
+
 func TestInterpreter_Query_close(t *testing.T) {
 	var i Interpreter
 	i.Register0(engine.NewAtom("do_not_call"), func(_ *engine.VM, k engine.Cont, env *engine.Env) *engine.Promise {
@@ -928,39 +1014,39 @@ func TestDict(t *testing.T) {
 		// pathological
 		{
 			query:     "A = point{x }.",
-			wantError: fmt.Errorf("unexpected token: close curly(})"),
+			wantError: fmt.Errorf("unexpected token: close curly(}) at line 1, column 13"),
 		},
 		{
 			query:     "A = point{x: }.",
-			wantError: fmt.Errorf("unexpected token: close curly(})"),
+			wantError: fmt.Errorf("unexpected token: close curly(}) at line 1, column 14"),
 		},
 		{
 			query:     "A = point{x: 5, }.",
-			wantError: fmt.Errorf("unexpected token: close curly(})"),
+			wantError: fmt.Errorf("unexpected token: close curly(}) at line 1, column 17"),
 		},
 		{
 			query:     "A = point{x: 5,, }.",
-			wantError: fmt.Errorf("unexpected token: comma(,)"),
+			wantError: fmt.Errorf("unexpected token: comma(,) at line 1, column 16"),
 		},
 		{
 			query:     "A = point{x: 5 .",
-			wantError: fmt.Errorf("unexpected token: end(.)"),
+			wantError: fmt.Errorf("unexpected token: end(.) at line 1, column 16"),
 		},
 		{
 			query:     "A = point{}",
-			wantError: fmt.Errorf("unexpected token: close curly(})"),
+			wantError: fmt.Errorf("unexpected token: close curly(}) at line 1, column 11"),
 		},
 		{
 			query:     "A = point{}}.",
-			wantError: fmt.Errorf("unexpected token: close curly(})"),
+			wantError: fmt.Errorf("unexpected token: close curly(}) at line 1, column 12"),
 		},
 		{
 			query:     "A = point{x=1}.",
-			wantError: fmt.Errorf("unexpected token: graphic(=)"),
+			wantError: fmt.Errorf("unexpected token: graphic(=) at line 1, column 12"),
 		},
 		{
 			query:     "A = point{5=1}.",
-			wantError: fmt.Errorf("unexpected token: integer(5)"),
+			wantError: fmt.Errorf("unexpected token: integer(5) at line 1, column 11"),
 		},
 		// construction
 		{
@@ -2161,6 +2247,42 @@ func ExampleNew_arg() {
 	// error(type_error(compound,3),arg/3)
 }
 
+func TestInterpreter_attributedVariables(t *testing.T) {
+	i := New(nil, nil)
+	assert.NoError(t, i.Exec(`
+domain:attr_unify_hook(Domain, Y) :- member(Y, Domain).
+`))
+
+	t.Run("hook succeeds", func(t *testing.T) {
+		var s struct {
+			X int
+		}
+		sol := i.QuerySolution(`put_attr(X, domain, [1, 2, 3]), X = 2.`)
+		assert.NoError(t, sol.Err())
+		assert.NoError(t, sol.Scan(&s))
+		assert.Equal(t, 2, s.X)
+	})
+
+	t.Run("hook fails", func(t *testing.T) {
+		assert.Equal(t, ErrNoSolutions, i.QuerySolution(`put_attr(X, domain, [1, 2, 3]), X = 4.`).Err())
+	})
+
+	t.Run("hook in clause head", func(t *testing.T) {
+		assert.NoError(t, i.Exec(`four(4).`))
+		assert.Equal(t, ErrNoSolutions, i.QuerySolution(`put_attr(X, domain, [1, 2, 3]), four(X).`).Err())
+	})
+
+	t.Run("get_attr", func(t *testing.T) {
+		var s struct {
+			D []int
+		}
+		sol := i.QuerySolution(`put_attr(X, domain, [1, 2]), get_attr(X, domain, D), attvar(X).`)
+		assert.NoError(t, sol.Err())
+		assert.NoError(t, sol.Scan(&s))
+		assert.Equal(t, []int{1, 2}, s.D)
+	})
+}
+
 func TestDefaultFS_Open(t *testing.T) {
 	var fs defaultFS
 	f, err := fs.Open("interpreter.go")
@@ -2173,3 +2295,326 @@ type readFn func(p []byte) (n int, err error)
 func (f readFn) Read(p []byte) (n int, err error) {
 	return f(p)
 }
+
+func TestInterpreter_tabling(t *testing.T) {
+	i := New(nil, nil)
+	assert.NoError(t, i.Exec(`
+:- table reachable/2.
+reachable(X, Y) :- reachable(X, Z), edge(Z, Y).
+reachable(X, Y) :- edge(X, Y).
+
+edge(a, b).
+edge(b, a).
+edge(b, c).
+`))
+
+	var s struct {
+		Xs []string
+	}
+	sol := i.QuerySolution(`findall(X, reachable(a, X), Xs0), sort(Xs0, Xs).`)
+	assert.NoError(t, sol.Err())
+	assert.NoError(t, sol.Scan(&s))
+	assert.Equal(t, []string{"a", "b", "c"}, s.Xs)
+
+	assert.NoError(t, i.QuerySolution(`abolish_all_tables.`).Err())
+}
+
+func TestInterpreter_format(t *testing.T) {
+	var out bytes.Buffer
+	i := New(nil, &out)
+
+	assert.NoError(t, i.QuerySolution(`format("~a~t~8|~w~n", [total, 42]), format('done~n').`).Err())
+	assert.Equal(t, "total   42\ndone\n", out.String())
+}
+
+func TestInterpreter_modules(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lists2.pl": &fstest.MapFile{Data: []byte(`
+:- module(lists2, [rev/2, (===)/2, op(700, xfx, ===)]).
+:- op(700, xfx, ~~).
+
+rev(L, R) :- rev(L, [], R).
+
+rev([], A, A).
+rev([X|Xs], A, R) :- rev(Xs, [X|A], R).
+
+X === Y :- X ~~ Y.
+
+X ~~ X.
+`)},
+		"twice.pl": &fstest.MapFile{Data: []byte(`
+:- module(twice, [twice/2, evens/1]).
+:- use_module(lists2, [rev/2]).
+
+twice(L, R) :- rev(L, R0), rev(R0, R).
+
+even(0).
+even(2).
+
+evens(L) :- findall(X, even(X), L).
+`)},
+		"plain.pl": &fstest.MapFile{Data: []byte(`plain.`)},
+	}
+
+	newInterpreter := func(t *testing.T) *Interpreter {
+		i := New(nil, nil)
+		i.FS = fsys
+		return i
+	}
+
+	t.Run("exported predicates and operators", func(t *testing.T) {
+		i := newInterpreter(t)
+		assert.NoError(t, i.Exec(`:- use_module(lists2).`))
+
+		var s struct {
+			R []string
+		}
+		sol := i.QuerySolution(`rev([a, b, c], R).`)
+		assert.NoError(t, sol.Err())
+		assert.NoError(t, sol.Scan(&s))
+		assert.Equal(t, []string{"c", "b", "a"}, s.R)
+
+		assert.NoError(t, i.QuerySolution(`a === a.`).Err())
+	})
+
+	t.Run("private predicates and operators", func(t *testing.T) {
+		i := newInterpreter(t)
+		assert.NoError(t, i.Exec(`:- use_module(lists2).`))
+
+		assert.NoError(t, i.QuerySolution(`catch(rev([a], [], _), error(existence_error(procedure, rev/3), _), true).`).Err())
+		assert.NoError(t, i.QuerySolution(`lists2:rev([a], [], [a]).`).Err())
+		assert.NoError(t, i.QuerySolution(`lists2:(rev([a], [], R), R == [a]).`).Err())
+		assert.Error(t, i.QuerySolution(`X = (a ~~ a).`).Err())
+	})
+
+	t.Run("module importing a module", func(t *testing.T) {
+		i := newInterpreter(t)
+		assert.NoError(t, i.Exec(`:- use_module(twice).`))
+
+		var s struct {
+			R []string
+			L []int
+		}
+		sol := i.QuerySolution(`twice([a, b], R), evens(L).`)
+		assert.NoError(t, sol.Err())
+		assert.NoError(t, sol.Scan(&s))
+		assert.Equal(t, []string{"a", "b"}, s.R)
+		assert.Equal(t, []int{0, 2}, s.L)
+
+		assert.NoError(t, i.QuerySolution(`catch(rev([a], _), error(existence_error(procedure, rev/2), _), true).`).Err())
+		assert.NoError(t, i.QuerySolution(`catch(even(_), error(existence_error(procedure, even/1), _), true).`).Err())
+	})
+
+	t.Run("import list", func(t *testing.T) {
+		i := newInterpreter(t)
+		assert.NoError(t, i.QuerySolution(`use_module(lists2, [rev/2]), rev([a, b], [b, a]).`).Err())
+		assert.NoError(t, i.QuerySolution(`catch(use_module(lists2, [rev/3]), error(permission_error(access, private_procedure, lists2:rev/3), _), true).`).Err())
+	})
+
+	t.Run("not a module", func(t *testing.T) {
+		i := newInterpreter(t)
+		assert.NoError(t, i.QuerySolution(`catch(use_module(plain), error(existence_error(module, plain), _), true).`).Err())
+	})
+}
+
+func TestInterpreter_qSetval(t *testing.T) {
+	i := New(nil, nil)
+	assert.NoError(t, i.Exec(`
+count(N) :- q_setval(n, 0), ( between(1, N, _), q_getval(n, C0), C is C0 + 1, q_setval(n, C), fail ; true ).
+`))
+
+	var wg sync.WaitGroup
+	for n := 1; n <= 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var s struct{ C int }
+			assert.NoError(t, i.QuerySolution(`count(?), q_getval(n, C).`, n*100).Scan(&s))
+			assert.Equal(t, n*100, s.C)
+		}()
+	}
+	wg.Wait()
+
+	var s struct{ E TermString }
+	assert.NoError(t, i.QuerySolution(`catch(q_getval(n, _), error(E, _), true).`).Scan(&s))
+	assert.Equal(t, TermString("existence_error(variable,n)"), s.E)
+}
+
+func TestInterpreter_withOutputTo(t *testing.T) {
+	var out bytes.Buffer
+	i := New(nil, &out)
+
+	var s struct {
+		A       string
+		S, C, T TermString
+	}
+	assert.NoError(t, i.QuerySolution(`
+with_output_to(atom(A), (write(a), write(' '), writeq('B'))),
+with_output_to(string(S), format("~w-~w", [x, y])),
+with_output_to(codes(C), write(hi)),
+open_string("foo(bar). ", In), read(In, T).`).Scan(&s))
+	assert.Equal(t, "a 'B'", s.A)
+	assert.Equal(t, TermString(`"x-y"`), s.S)
+	assert.Equal(t, TermString("[104,105]"), s.C)
+	assert.Equal(t, TermString("foo(bar)"), s.T)
+	assert.Empty(t, out.String())
+}
+
+func TestInterpreter_stringBuilder(t *testing.T) {
+	i := New(nil, nil)
+
+	var s struct {
+		A string
+	}
+	assert.NoError(t, i.QuerySolution(`
+string_builder_new(B),
+(between(1, 1000, _), string_builder_append(B, ab), fail ; true),
+string_builder_append(B, "c"),
+string_builder_to_atom(B, A).`).Scan(&s))
+	assert.Equal(t, strings.Repeat("ab", 1000)+"c", s.A)
+}
+
+func TestInterpreter_bag(t *testing.T) {
+	i := New(nil, nil)
+	assert.NoError(t, i.Exec(`
+edge(a, b, 4).
+edge(a, c, 1).
+edge(c, b, 2).
+edge(b, d, 1).
+
+shortest(From, To, Dist) :- bag_create(Q), bag_add(Q, 0-From), dijkstra(Q, [], To, Dist).
+
+dijkstra(Q, Visited, To, Dist) :-
+	bag_min(Q, D-N),
+	(	N == To -> Dist = D
+	;	member(N, Visited) -> dijkstra(Q, Visited, To, Dist)
+	;	(edge(N, M, W), D1 is D + W, bag_add(Q, D1-M), fail ; true),
+		dijkstra(Q, [N|Visited], To, Dist)
+	).
+`))
+
+	var s struct {
+		Dist int
+	}
+	assert.NoError(t, i.QuerySolution(`shortest(a, d, Dist).`).Scan(&s))
+	assert.Equal(t, 4, s.Dist)
+}
+
+func TestInterpreter_spy(t *testing.T) {
+	var buf bytes.Buffer
+	i := New(nil, nil)
+	i.InstallPortHook(engine.DebugPortHookFn(&buf))
+	assert.NoError(t, i.Exec(`
+len([], 0).
+len([_|T], N) :- N > 0, M is N - 1, len(T, M).
+`))
+
+	assert.NoError(t, i.QuerySolution(`spy(len/2), len([a], 1), nospy(len/2).`).Err())
+	assert.Equal(t, `call(1) len([a],1)
+call(2) len([],0)
+exit(2) len([],0)
+exit(1) len([a],1)
+`, buf.String())
+}
+
+func TestInterpreter_time(t *testing.T) {
+	i := New(nil, nil)
+
+	var s struct {
+		X, I int
+	}
+	assert.NoError(t, i.QuerySolution(`time(between(1, 3, X)), X >= 2, time(length(_, 2), S), get_dict(inferences, S, I).`).Scan(&s))
+	assert.Equal(t, 2, s.X)
+	assert.Positive(t, s.I)
+}
+
+func TestInterpreter_rational(t *testing.T) {
+	i := New(nil, nil)
+
+	var s struct {
+		X, Y, Z TermString
+	}
+	assert.NoError(t, i.QuerySolution(`X is 1 rdiv 3, rational(X), number(X), Y is X + 2r3, Z is 1 / 4, float(Z).`).Scan(&s))
+	assert.Equal(t, TermString("1r3"), s.X)
+	assert.Equal(t, TermString("1"), s.Y)
+
+	assert.NoError(t, i.Exec(`:- set_prolog_flag(prefer_rationals, true).`))
+	assert.NoError(t, i.QuerySolution(`X is 1 / 3, Y is X * 3, Z is 1 / 4.`).Scan(&s))
+	assert.Equal(t, TermString("1r3"), s.X)
+	assert.Equal(t, TermString("1"), s.Y)
+	assert.Equal(t, TermString("1r4"), s.Z)
+}
+
+func TestInterpreter_flagScope(t *testing.T) {
+	i := New(nil, nil)
+
+	var s struct {
+		X TermString
+	}
+	assert.NoError(t, i.QuerySolution(`set_prolog_flag(double_quotes, atom), open_string('"abc". ', S), read(S, X).`).Scan(&s))
+	assert.Equal(t, TermString("abc"), s.X)
+
+	assert.NoError(t, i.QuerySolution(`current_prolog_flag(double_quotes, X).`).Scan(&s))
+	assert.Equal(t, TermString("chars"), s.X)
+
+	assert.NoError(t, i.Exec(`:- set_prolog_flag(double_quotes, atom).`))
+	assert.NoError(t, i.QuerySolution(`current_prolog_flag(double_quotes, X).`).Scan(&s))
+	assert.Equal(t, TermString("atom"), s.X)
+
+	assert.NoError(t, i.SetDefaultFlag(engine.NewAtom("occurs_check"), engine.NewAtom("true")))
+	assert.Equal(t, ErrNoSolutions, i.QuerySolution(`X = f(X).`).Err())
+}
+
+func TestInterpreter_RegisterUnaryFunction(t *testing.T) {
+	i := New(nil, nil)
+	i.RegisterUnaryFunction(engine.NewAtom("uatom_to_uusd"), func(x engine.Term, _ *engine.Env) (engine.Term, error) {
+		n, ok := x.(engine.Integer)
+		if !ok {
+			return nil, engine.TypeError(engine.NewAtom("integer"), x, nil)
+		}
+		return n / 1000000, nil
+	})
+
+	var s struct {
+		X int
+	}
+	assert.NoError(t, i.QuerySolution(`X is uatom_to_uusd(2000000) + 1.`).Scan(&s))
+	assert.Equal(t, 3, s.X)
+}
+
+func TestInterpreter_lastCall(t *testing.T) {
+	var out bytes.Buffer
+	i := New(nil, &out)
+	assert.NoError(t, i.Exec(`
+m:attr_unify_hook(_, _) :- write(woken).
+
+q(X) :- X = 1.
+r(X) :- q(X).
+p(X) :- r(X), write(after).
+
+count(0) :- !.
+count(N) :- N1 is N - 1, count(N1).
+`))
+
+	assert.NoError(t, i.QuerySolution(`put_attr(X, m, a), p(X).`).Err())
+	assert.Equal(t, "wokenafter", out.String())
+
+	assert.NoError(t, i.QuerySolution(`count(100000).`).Err())
+}
+
+func TestInterpreter_cyclicTerms(t *testing.T) {
+	var out bytes.Buffer
+	i := New(nil, &out)
+
+	for _, q := range []string{
+		`X = f(X), Y = f(Y), X = Y, X == Y.`,
+		`X = f(X, a), Y = f(Y, b), X \= Y, compare(<, X, Y).`,
+		`X = f(X), \+ acyclic_term(X).`,
+		`X = f(X), call(=(Y), X), Y == X.`,
+		`X = f(X), copy_term(X, Y), X = Y.`,
+		`X = [a|X], write(X).`,
+	} {
+		assert.NoError(t, i.QuerySolution(q).Err(), q)
+	}
+	assert.Equal(t, "[a,a|...]", out.String())
+}
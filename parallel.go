@@ -0,0 +1,94 @@
+package prolog
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// ParallelOptions configures ParallelQuery.
+type ParallelOptions struct {
+	// Workers is the maximum number of queries running at the same time. It defaults to runtime.GOMAXPROCS(0).
+	Workers int
+	// MaxSolutions is the maximum number of solutions collected for each query. It defaults to all of them.
+	MaxSolutions int
+}
+
+// ParallelResult is the result of one of the queries run by ParallelQuery.
+type ParallelResult struct {
+	// Solutions are the bindings of the variables of the query, one map per solution.
+	Solutions []map[string]TermString
+	// Err is the error the query ended with, if any.
+	Err error
+}
+
+// ParallelQuery runs the read-only queries across a pool of workers and returns their results in the order of
+// queries. Each worker runs its queries on its own clone of a frozen snapshot of i, so the queries can't modify
+// the database and i can still be used afterwards. If i is already frozen, its database is shared instead of copied.
+// Once ctx is done, the queries not started yet result in ctx.Err().
+func ParallelQuery(ctx context.Context, i *Interpreter, queries []string, opts ParallelOptions) ([]ParallelResult, error) {
+	base := &i.VM
+	if !base.Frozen() {
+		base = base.Clone()
+		base.Freeze()
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(queries))
+
+	results := make([]ParallelResult, len(queries))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < workers; n++ {
+		w := Interpreter{VM: *base.Clone()}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results[j] = w.collect(ctx, queries[j], opts.MaxSolutions)
+			}
+		}()
+	}
+
+dispatch:
+	for j := range queries {
+		select {
+		case <-ctx.Done():
+			for ; j < len(queries); j++ {
+				results[j].Err = ctx.Err()
+			}
+			break dispatch
+		case jobs <- j:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, ctx.Err()
+}
+
+// collect runs query and returns up to limit solutions, or all of them if limit is 0.
+func (i *Interpreter) collect(ctx context.Context, query string, limit int) ParallelResult {
+	sols, err := i.QueryContext(ctx, query)
+	if err != nil {
+		return ParallelResult{Err: err}
+	}
+	defer func() {
+		_ = sols.Close()
+	}()
+
+	var r ParallelResult
+	for (limit == 0 || len(r.Solutions) < limit) && sols.Next() {
+		s := map[string]TermString{}
+		if err := sols.Scan(s); err != nil {
+			r.Err = err
+			return r
+		}
+		r.Solutions = append(r.Solutions, s)
+	}
+	r.Err = sols.Err()
+	return r
+}
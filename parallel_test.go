@@ -0,0 +1,80 @@
+package prolog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelQuery(t *testing.T) {
+	i := New(nil, nil)
+	assert.NoError(t, i.Exec(`
+:- dynamic(counter/1).
+counter(0).
+color(red).
+color(green).
+color(blue).
+`))
+
+	queries := make([]string, 0, 20)
+	for n := 0; n < 20; n++ {
+		queries = append(queries, fmt.Sprintf(`X is %d * 2.`, n))
+	}
+	queries = append(queries,
+		`color(C).`,
+		`foo(`,
+		`retract(counter(_)).`,
+	)
+
+	results, err := ParallelQuery(context.Background(), i, queries, ParallelOptions{Workers: 4})
+	assert.NoError(t, err)
+	assert.Len(t, results, len(queries))
+	for n := 0; n < 20; n++ {
+		assert.NoError(t, results[n].Err)
+		assert.Equal(t, []map[string]TermString{{"X": TermString(fmt.Sprint(n * 2))}}, results[n].Solutions)
+	}
+	assert.Equal(t, ParallelResult{Solutions: []map[string]TermString{{"C": "red"}, {"C": "green"}, {"C": "blue"}}}, results[20])
+	assert.Error(t, results[21].Err)
+	assert.ErrorContains(t, results[22].Err, "permission_error(modify,static_procedure,counter/1)")
+
+	assert.False(t, i.Frozen())
+	assert.NoError(t, i.QuerySolution(`retract(counter(0)).`).Err())
+
+	t.Run("max solutions", func(t *testing.T) {
+		results, err := ParallelQuery(context.Background(), i, []string{`color(C).`}, ParallelOptions{MaxSolutions: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, []ParallelResult{{Solutions: []map[string]TermString{{"C": "red"}}}}, results)
+	})
+
+	t.Run("indexed", func(t *testing.T) {
+		var sb strings.Builder
+		sb.WriteString(":- index(edge(1, 1)).\n")
+		for n := 0; n < 1000; n++ {
+			_, _ = fmt.Fprintf(&sb, "edge(%d, %d).\n", n, n+1)
+		}
+		i := New(nil, nil)
+		assert.NoError(t, i.Exec(sb.String()))
+
+		queries := make([]string, 0, 64)
+		for n := 0; n < 64; n++ {
+			queries = append(queries, fmt.Sprintf(`edge(%d, X), edge(X, %d).`, n, n+2))
+		}
+		results, err := ParallelQuery(context.Background(), i, queries, ParallelOptions{Workers: 8})
+		assert.NoError(t, err)
+		for n, r := range results {
+			assert.NoError(t, r.Err)
+			assert.Equal(t, []map[string]TermString{{"X": TermString(fmt.Sprint(n + 1))}}, r.Solutions)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		results, err := ParallelQuery(ctx, i, []string{`true.`, `true.`}, ParallelOptions{})
+		assert.Equal(t, context.Canceled, err)
+		assert.Len(t, results, 2)
+	})
+}
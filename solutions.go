@@ -140,6 +140,9 @@ func convertAssignAny(d *interface{}, vm *engine.VM, t engine.Term, env *engine.
 	case engine.Integer:
 		*d = int(t)
 		return nil
+	case engine.String:
+		*d = string(t)
+		return nil
 	case engine.Float:
 		var s string
 		if err := convertAssignString(&s, t, env); err != nil {